@@ -0,0 +1,437 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/thedeveloperr/redis-clone/httpClient"
+	"github.com/thedeveloperr/redis-clone/respClient"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Settings of the cli given as flags, see runCLI
+type cliOptions struct {
+	host    string
+	port    int
+	httpURL string
+	raw     bool
+	noRaw   bool
+	history string
+	pipe    bool
+	scan    bool
+	pattern string
+	count   int
+	bigkeys bool
+	latency bool
+	samples int
+}
+
+// The cli talking to the server through one of the clients, the other
+// being nil
+type cli struct {
+	options cliOptions
+	resp    *respClient.Client
+	http    *httpClient.Client
+	// server shown in the prompt
+	address string
+	output  io.Writer
+	// whether replies are printed like redis-cli --raw
+	raw bool
+}
+
+// Runs the cli, eg. go run ./ cli -p 6379 or go run ./ cli --http http://localhost:8080,
+// and returns its exit code. The arguments after the flags are run as a
+// command, otherwise a mode flag picks what to do or commands are read from
+// stdin, with line editing when it's a terminal.
+func runCLI(arguments []string) int {
+	flags := flag.NewFlagSet("cli", flag.ContinueOnError)
+	var options cliOptions
+	flags.StringVar(&options.host, "h", "127.0.0.1", "host of the RESP listener")
+	flags.IntVar(&options.port, "p", 6379, "port of the RESP listener")
+	flags.StringVar(&options.httpURL, "http", "", "talk to the HTTP interface at this URL instead of RESP, eg. http://localhost:8080")
+	flags.BoolVar(&options.raw, "raw", false, "print replies as they are, which is the default when stdout isn't a terminal")
+	flags.BoolVar(&options.noRaw, "no-raw", false, "print replies formatted even if stdout isn't a terminal")
+	flags.StringVar(&options.history, "history", defaultHistoryFile(), "file keeping the commands typed")
+	flags.BoolVar(&options.pipe, "pipe", false, "send the commands read from stdin, as RESP or one per line, and report how many failed")
+	flags.BoolVar(&options.scan, "scan", false, "list the keys using SCAN")
+	flags.StringVar(&options.pattern, "pattern", "", "keys --scan lists, eg. user:*")
+	flags.IntVar(&options.count, "count", 100, "COUNT given to SCAN by --scan and --bigkeys")
+	flags.BoolVar(&options.bigkeys, "bigkeys", false, "find the biggest key of each type")
+	flags.BoolVar(&options.latency, "latency", false, "measure the latency of PING until interrupted")
+	flags.IntVar(&options.samples, "samples", 0, "PINGs sent by --latency before stopping, 0 for no limit")
+	if err := flags.Parse(arguments); err != nil {
+		return 2
+	}
+
+	c := createCLI(options, os.Stdout)
+	c.raw = options.raw || (!options.noRaw && !isTerminal(os.Stdout))
+	defer c.close()
+	ctx := context.Background()
+	switch {
+	case options.pipe:
+		return c.runPipe(ctx, os.Stdin)
+	case options.scan:
+		return c.runScan(ctx)
+	case options.bigkeys:
+		return c.runBigKeys(ctx)
+	case options.latency:
+		ctx, stop := interruptContext()
+		defer stop()
+		return c.runLatency(ctx, isTerminal(os.Stdout))
+	case flags.NArg() > 0:
+		return c.runCommand(ctx, flags.Args())
+	case isTerminal(os.Stdin):
+		return c.runInteractive(os.Stdin)
+	}
+	return c.runLines(ctx, os.Stdin)
+}
+
+func createCLI(options cliOptions, output io.Writer) *cli {
+	c := &cli{options: options, output: output}
+	if options.httpURL != "" {
+		c.http = httpClient.Create(options.httpURL, nil)
+		c.address = strings.TrimPrefix(strings.TrimPrefix(options.httpURL, "http://"), "https://")
+		return c
+	}
+	c.address = net.JoinHostPort(options.host, strconv.Itoa(options.port))
+	// a single connection, so MULTI and EXEC are sent on the same one
+	c.resp = respClient.Create(c.address, &respClient.Options{PoolSize: 1})
+	return c
+}
+
+func (c *cli) close() {
+	if c.resp != nil {
+		c.resp.Close()
+	} else {
+		c.http.Close()
+	}
+}
+
+func (c *cli) do(ctx context.Context, args ...string) (interface{}, error) {
+	if c.resp != nil {
+		return c.resp.Do(ctx, args...)
+	}
+	return c.http.Do(ctx, args...)
+}
+
+// History file in the home directory, none if there isn't one
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".redis-clone-cli-history")
+}
+
+// Context cancelled by ctrl-C, until stop is called
+func interruptContext() (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		select {
+		case <-interrupts:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(interrupts)
+		cancel()
+	}
+}
+
+// Message of an error reply of the server, ok is false for errors like the
+// server being unreachable
+func replyErrorMessage(err error) (message string, ok bool) {
+	var respError *respClient.Error
+	if errors.As(err, &respError) {
+		return respError.Message, true
+	}
+	var httpError *httpClient.Error
+	if errors.As(err, &httpError) {
+		return httpError.Message, true
+	}
+	return "", false
+}
+
+// Runs a command and prints its reply. The exit code is 1 if it failed.
+func (c *cli) runCommand(ctx context.Context, args []string) int {
+	// the server only knows uppercase command names
+	args = append([]string{strings.ToUpper(args[0])}, args[1:]...)
+	switch args[0] {
+	case "SUBSCRIBE", "PSUBSCRIBE", "SSUBSCRIBE":
+		ctx, stop := interruptContext()
+		defer stop()
+		return c.runSubscribe(ctx, args)
+	}
+	reply, err := c.do(ctx, args...)
+	if err != nil {
+		message, isReply := replyErrorMessage(err)
+		if !isReply {
+			fmt.Fprintf(c.output, "Could not connect to %s: %v\n", c.address, err)
+			return 1
+		}
+		reply = &respClient.Error{Message: message}
+	}
+	io.WriteString(c.output, c.formatReply(reply))
+	if _, failed := reply.(*respClient.Error); failed {
+		return 1
+	}
+	return 0
+}
+
+func (c *cli) formatReply(reply interface{}) string {
+	if c.raw {
+		return formatRawReply(reply)
+	}
+	return formatCLIReply(reply, 0)
+}
+
+// Formats a reply the way redis-cli does in a terminal: strings quoted,
+// integers as (integer) 1 and list items numbered, the items of a nested
+// list being aligned under its first one.
+func formatCLIReply(reply interface{}, indent int) string {
+	switch value := reply.(type) {
+	case nil:
+		return "(nil)\n"
+	case int64:
+		return "(integer) " + strconv.FormatInt(value, 10) + "\n"
+	case float64:
+		return "(double) " + strconv.FormatFloat(value, 'g', -1, 64) + "\n"
+	case error:
+		return "(error) " + value.Error() + "\n"
+	case string:
+		// the server only sends these as status replies, which aren't quoted
+		if value == "OK" || value == "PONG" || value == "QUEUED" {
+			return value + "\n"
+		}
+		return quoteCLIString(value) + "\n"
+	case []interface{}:
+		if len(value) == 0 {
+			return "(empty list or set)\n"
+		}
+		width := len(strconv.Itoa(len(value)))
+		var builder strings.Builder
+		for i, item := range value {
+			if i > 0 {
+				builder.WriteString(strings.Repeat(" ", indent))
+			}
+			index := strconv.Itoa(i + 1)
+			prefix := strings.Repeat(" ", width-len(index)) + index + ") "
+			builder.WriteString(prefix)
+			builder.WriteString(formatCLIReply(item, indent+len(prefix)))
+		}
+		return builder.String()
+	}
+	return fmt.Sprintf("%v\n", reply)
+}
+
+// Formats a reply like redis-cli --raw, for scripts: values as they are,
+// one per line, and nothing for nil
+func formatRawReply(reply interface{}) string {
+	switch value := reply.(type) {
+	case nil:
+		return "\n"
+	case int64:
+		return strconv.FormatInt(value, 10) + "\n"
+	case error:
+		return value.Error() + "\n"
+	case []interface{}:
+		var builder strings.Builder
+		for _, item := range value {
+			builder.WriteString(formatRawReply(item))
+		}
+		return builder.String()
+	}
+	return fmt.Sprintf("%v\n", reply)
+}
+
+// Quotes a value like redis-cli, escaping quotes, backslashes, line breaks
+// and bytes which aren't printable ASCII
+func quoteCLIString(value string) string {
+	var builder strings.Builder
+	builder.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case c == '\\' || c == '"':
+			builder.WriteByte('\\')
+			builder.WriteByte(c)
+		case c == '\n':
+			builder.WriteString("\\n")
+		case c == '\r':
+			builder.WriteString("\\r")
+		case c == '\t':
+			builder.WriteString("\\t")
+		case c == '\a':
+			builder.WriteString("\\a")
+		case c == '\b':
+			builder.WriteString("\\b")
+		case c < ' ' || c >= 0x7f:
+			builder.WriteString(fmt.Sprintf("\\x%02x", c))
+		default:
+			builder.WriteByte(c)
+		}
+	}
+	builder.WriteByte('"')
+	return builder.String()
+}
+
+// Prints the messages of channels, patterns or shard channels until ctx is
+// cancelled, like redis-cli does after SUBSCRIBE. Shard channels need HTTP
+// as the RESP client doesn't subscribe to them.
+func (c *cli) runSubscribe(ctx context.Context, args []string) int {
+	if len(args) < 2 {
+		io.WriteString(c.output, c.formatReply(&respClient.Error{Message: "ERR wrong number of arguments for '" + args[0] + "' command"}))
+		return 1
+	}
+	kind, names := args[0], args[1:]
+	fmt.Fprintln(c.output, "Reading messages... (press Ctrl-C to quit)")
+	if c.http != nil {
+		var channels, patterns, shardChannels []string
+		switch kind {
+		case "SUBSCRIBE":
+			channels = names
+		case "PSUBSCRIBE":
+			patterns = names
+		default:
+			shardChannels = names
+		}
+		err := c.http.Subscribe(ctx, channels, patterns, shardChannels, func(message httpClient.Message) {
+			c.printMessage(message.Kind, message.Pattern, message.Channel, message.Payload, int64(message.Count))
+		})
+		if err != nil && ctx.Err() == nil {
+			fmt.Fprintln(c.output, "Error:", err)
+			return 1
+		}
+		return 0
+	}
+
+	var subscription *respClient.PubSub
+	var err error
+	switch kind {
+	case "SUBSCRIBE":
+		subscription, err = c.resp.Subscribe(ctx, names...)
+	case "PSUBSCRIBE":
+		subscription, err = c.resp.PSubscribe(ctx, names...)
+	default:
+		err = errors.New("SSUBSCRIBE is only available with --http")
+	}
+	if err != nil {
+		fmt.Fprintln(c.output, "Error:", err)
+		return 1
+	}
+	defer subscription.Close()
+	for {
+		select {
+		case message := <-subscription.Channel():
+			c.printMessage(message.Kind, message.Pattern, message.Channel, message.Payload, message.Count)
+		case <-ctx.Done():
+			return 0
+		}
+	}
+}
+
+// Prints a message like the reply redis sends for it
+func (c *cli) printMessage(kind string, pattern string, channel string, payload string, count int64) {
+	reply := []interface{}{kind}
+	switch kind {
+	case "message", "smessage":
+		reply = append(reply, channel, payload)
+	case "pmessage":
+		reply = append(reply, pattern, channel, payload)
+	default:
+		reply = append(reply, channel, count)
+	}
+	io.WriteString(c.output, c.formatReply(reply))
+}
+
+// Runs the commands typed with line editing, history and completion until
+// quit, exit or ctrl-D. Commands are printed like redis-cli does.
+func (c *cli) runInteractive(terminal *os.File) int {
+	editor := &lineEditor{
+		input:    bufio.NewReader(terminal),
+		output:   c.output,
+		complete: completeCommand,
+		hint:     commandHint,
+	}
+	if c.options.history != "" {
+		if err := editor.loadHistory(c.options.history); err != nil {
+			fmt.Fprintln(c.output, "Could not read the history:", err)
+		}
+	}
+	prompt := c.address + "> "
+	for {
+		restore, err := makeRaw()
+		if err != nil {
+			fmt.Fprintln(c.output, "Could not set up the terminal:", err)
+			return c.runLines(context.Background(), terminal)
+		}
+		line, err := editor.readLine(prompt)
+		restore()
+		if err == errInterrupted {
+			continue
+		}
+		if err != nil {
+			return 0
+		}
+		editor.addHistory(line)
+		if c.options.history != "" {
+			editor.saveHistory(c.options.history)
+		}
+		if !c.runLine(context.Background(), line) {
+			return 0
+		}
+	}
+}
+
+// Runs the commands read from input one per line, without prompts. The exit
+// code is 1 if one failed.
+func (c *cli) runLines(ctx context.Context, input io.Reader) int {
+	code := 0
+	scanner := bufio.NewScanner(input)
+	for scanner.Scan() {
+		args, ok := splitArgs(scanner.Text())
+		if !ok {
+			fmt.Fprintln(c.output, "Invalid argument(s)")
+			code = 1
+			continue
+		}
+		if len(args) > 0 && c.runCommand(ctx, args) != 0 {
+			code = 1
+		}
+	}
+	return code
+}
+
+// Runs a line typed in the interactive mode, false to quit
+func (c *cli) runLine(ctx context.Context, line string) bool {
+	args, ok := splitArgs(line)
+	if !ok {
+		fmt.Fprintln(c.output, "Invalid argument(s)")
+		return true
+	}
+	if len(args) == 0 {
+		return true
+	}
+	switch strings.ToLower(args[0]) {
+	case "quit", "exit":
+		return false
+	case "help":
+		io.WriteString(c.output, cliHelp(strings.Join(args[1:], " ")))
+		return true
+	case "clear":
+		io.WriteString(c.output, "\x1b[H\x1b[2J")
+		return true
+	}
+	c.runCommand(ctx, args)
+	return true
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// A command of the server as described by the help of the cli. Subcommands
+// are commands of their own, like CONFIG GET.
+type cliCommand struct {
+	name      string
+	arguments string
+	group     string
+}
+
+// Commands the cli completes and describes, grouped like redis does
+var cliCommands = []cliCommand{
+	{"PING", "[message]", "connection"},
+	{"QUIT", "", "connection"},
+
+	{"SCAN", "cursor [MATCH pattern] [COUNT count] [TYPE type]", "generic"},
+	{"TYPE", "key", "generic"},
+	{"DEL", "key [key ...]", "generic"},
+	{"DBSIZE", "", "generic"},
+	{"EXPIRE", "key seconds", "generic"},
+	{"PEXPIREAT", "key unix-time-milliseconds", "generic"},
+	{"PERSIST", "key", "generic"},
+
+	{"GET", "key", "string"},
+	{"SET", "key value", "string"},
+	{"APPEND", "key value", "string"},
+	{"DECR", "key", "string"},
+	{"DECRBY", "key decrement", "string"},
+	{"GETDEL", "key", "string"},
+	{"GETEX", "key [EX seconds|PX milliseconds|EXAT unix-time-seconds|PXAT unix-time-milliseconds|PERSIST]", "string"},
+	{"GETRANGE", "key start end", "string"},
+	{"GETSET", "key value", "string"},
+	{"INCR", "key", "string"},
+	{"INCRBY", "key increment", "string"},
+	{"INCRBYFLOAT", "key increment", "string"},
+	{"MGET", "key [key ...]", "string"},
+	{"MSET", "key value [key value ...]", "string"},
+	{"MSETNX", "key value [key value ...]", "string"},
+	{"SETRANGE", "key offset value", "string"},
+	{"STRLEN", "key", "string"},
+
+	{"BITCOUNT", "key [start end [BYTE|BIT]]", "bitmap"},
+	{"BITFIELD", "key [GET encoding offset|SET encoding offset value|INCRBY encoding offset increment|OVERFLOW WRAP|SAT|FAIL ...]", "bitmap"},
+	{"BITOP", "AND|OR|XOR|NOT destkey key [key ...]", "bitmap"},
+	{"BITPOS", "key bit [start [end [BYTE|BIT]]]", "bitmap"},
+	{"GETBIT", "key offset", "bitmap"},
+	{"SETBIT", "key offset value", "bitmap"},
+
+	{"PFADD", "key [element [element ...]]", "hyperloglog"},
+	{"PFCOUNT", "key [key ...]", "hyperloglog"},
+	{"PFMERGE", "destkey [sourcekey [sourcekey ...]]", "hyperloglog"},
+
+	{"HDEL", "key field [field ...]", "hash"},
+	{"HEXISTS", "key field", "hash"},
+	{"HEXPIRE", "key seconds [NX|XX|GT|LT] FIELDS numfields field [field ...]", "hash"},
+	{"HEXPIREAT", "key unix-time-seconds [NX|XX|GT|LT] FIELDS numfields field [field ...]", "hash"},
+	{"HGET", "key field", "hash"},
+	{"HGETALL", "key", "hash"},
+	{"HINCRBY", "key field increment", "hash"},
+	{"HINCRBYFLOAT", "key field increment", "hash"},
+	{"HKEYS", "key", "hash"},
+	{"HLEN", "key", "hash"},
+	{"HMGET", "key field [field ...]", "hash"},
+	{"HPERSIST", "key FIELDS numfields field [field ...]", "hash"},
+	{"HPEXPIRE", "key milliseconds [NX|XX|GT|LT] FIELDS numfields field [field ...]", "hash"},
+	{"HPEXPIREAT", "key unix-time-milliseconds [NX|XX|GT|LT] FIELDS numfields field [field ...]", "hash"},
+	{"HPTTL", "key FIELDS numfields field [field ...]", "hash"},
+	{"HRANDFIELD", "key [count [WITHVALUES]]", "hash"},
+	{"HSCAN", "key cursor [MATCH pattern] [COUNT count] [NOVALUES]", "hash"},
+	{"HSET", "key field value [field value ...]", "hash"},
+	{"HSETNX", "key field value", "hash"},
+	{"HSTRLEN", "key field", "hash"},
+	{"HTTL", "key FIELDS numfields field [field ...]", "hash"},
+	{"HVALS", "key", "hash"},
+
+	{"BLMOVE", "source destination LEFT|RIGHT LEFT|RIGHT timeout", "list"},
+	{"BLPOP", "key [key ...] timeout", "list"},
+	{"BRPOP", "key [key ...] timeout", "list"},
+	{"LINDEX", "key index", "list"},
+	{"LINSERT", "key BEFORE|AFTER pivot element", "list"},
+	{"LLEN", "key", "list"},
+	{"LMOVE", "source destination LEFT|RIGHT LEFT|RIGHT", "list"},
+	{"LPOP", "key [count]", "list"},
+	{"LPOS", "key element [RANK rank] [COUNT num-matches] [MAXLEN len]", "list"},
+	{"LPUSH", "key element [element ...]", "list"},
+	{"LRANGE", "key start stop", "list"},
+	{"LREM", "key count element", "list"},
+	{"LSET", "key index element", "list"},
+	{"LTRIM", "key start stop", "list"},
+	{"RPOP", "key [count]", "list"},
+	{"RPUSH", "key element [element ...]", "list"},
+
+	{"SADD", "key member [member ...]", "set"},
+	{"SCARD", "key", "set"},
+	{"SDIFF", "key [key ...]", "set"},
+	{"SDIFFSTORE", "destination key [key ...]", "set"},
+	{"SINTER", "key [key ...]", "set"},
+	{"SINTERCARD", "numkeys key [key ...] [LIMIT limit]", "set"},
+	{"SINTERSTORE", "destination key [key ...]", "set"},
+	{"SISMEMBER", "key member", "set"},
+	{"SMEMBERS", "key", "set"},
+	{"SMISMEMBER", "key member [member ...]", "set"},
+	{"SMOVE", "source destination member", "set"},
+	{"SPOP", "key [count]", "set"},
+	{"SRANDMEMBER", "key [count]", "set"},
+	{"SREM", "key member [member ...]", "set"},
+	{"SSCAN", "key cursor [MATCH pattern] [COUNT count]", "set"},
+	{"SUNION", "key [key ...]", "set"},
+	{"SUNIONSTORE", "destination key [key ...]", "set"},
+
+	{"ZADD", "key score member [score member ...]", "sorted_set"},
+	{"ZCARD", "key", "sorted_set"},
+	{"ZRANGE", "key start stop [WITHSCORES]", "sorted_set"},
+	{"ZRANK", "key member", "sorted_set"},
+
+	{"GEOADD", "key longitude latitude member [longitude latitude member ...]", "geo"},
+	{"GEODIST", "key member1 member2 [M|KM|FT|MI]", "geo"},
+	{"GEOHASH", "key [member [member ...]]", "geo"},
+	{"GEOPOS", "key [member [member ...]]", "geo"},
+	{"GEOSEARCH", "key FROMMEMBER member|FROMLONLAT longitude latitude BYRADIUS radius M|KM|FT|MI|BYBOX width height M|KM|FT|MI [ASC|DESC] [COUNT count [ANY]] [WITHCOORD] [WITHDIST] [WITHHASH]", "geo"},
+	{"GEOSEARCHSTORE", "destination source FROMMEMBER member|FROMLONLAT longitude latitude BYRADIUS radius M|KM|FT|MI|BYBOX width height M|KM|FT|MI [ASC|DESC] [COUNT count [ANY]] [STOREDIST]", "geo"},
+
+	{"XACK", "key group id [id ...]", "stream"},
+	{"XADD", "key [NOMKSTREAM] [MAXLEN|MINID [=|~] threshold [LIMIT count]] *|id field value [field value ...]", "stream"},
+	{"XAUTOCLAIM", "key group consumer min-idle-time start [COUNT count] [JUSTID]", "stream"},
+	{"XCLAIM", "key group consumer min-idle-time id [id ...] [IDLE ms] [TIME unix-time-milliseconds] [RETRYCOUNT count] [FORCE] [JUSTID] [LASTID lastid]", "stream"},
+	{"XDEL", "key id [id ...]", "stream"},
+	{"XGROUP CREATE", "key group id|$ [MKSTREAM]", "stream"},
+	{"XGROUP CREATECONSUMER", "key group consumer", "stream"},
+	{"XGROUP DELCONSUMER", "key group consumer", "stream"},
+	{"XGROUP DESTROY", "key group", "stream"},
+	{"XGROUP SETID", "key group id|$", "stream"},
+	{"XLEN", "key", "stream"},
+	{"XPENDING", "key group [[IDLE min-idle-time] start end count [consumer]]", "stream"},
+	{"XRANGE", "key start end [COUNT count]", "stream"},
+	{"XREAD", "[COUNT count] [BLOCK milliseconds] STREAMS key [key ...] id [id ...]", "stream"},
+	{"XREADGROUP", "GROUP group consumer [COUNT count] [BLOCK milliseconds] [NOACK] STREAMS key [key ...] id [id ...]", "stream"},
+	{"XREVRANGE", "key end start [COUNT count]", "stream"},
+	{"XTRIM", "key MAXLEN|MINID [=|~] threshold [LIMIT count]", "stream"},
+
+	{"PSUBSCRIBE", "pattern [pattern ...]", "pubsub"},
+	{"PUBLISH", "channel message", "pubsub"},
+	{"PUBSUB CHANNELS", "[pattern]", "pubsub"},
+	{"PUBSUB NUMPAT", "", "pubsub"},
+	{"PUBSUB NUMSUB", "[channel [channel ...]]", "pubsub"},
+	{"PUBSUB SHARDCHANNELS", "[pattern]", "pubsub"},
+	{"PUBSUB SHARDNUMSUB", "[shardchannel [shardchannel ...]]", "pubsub"},
+	{"PUNSUBSCRIBE", "[pattern [pattern ...]]", "pubsub"},
+	{"SPUBLISH", "shardchannel message", "pubsub"},
+	{"SSUBSCRIBE", "shardchannel [shardchannel ...]", "pubsub"},
+	{"SUBSCRIBE", "channel [channel ...]", "pubsub"},
+	{"SUNSUBSCRIBE", "[shardchannel [shardchannel ...]]", "pubsub"},
+	{"UNSUBSCRIBE", "[channel [channel ...]]", "pubsub"},
+
+	{"DISCARD", "", "transactions"},
+	{"EXEC", "", "transactions"},
+	{"MULTI", "", "transactions"},
+	{"UNWATCH", "", "transactions"},
+	{"WATCH", "key [key ...]", "transactions"},
+
+	{"EVAL", "script numkeys [key [key ...]] [arg [arg ...]]", "scripting"},
+	{"EVALSHA", "sha1 numkeys [key [key ...]] [arg [arg ...]]", "scripting"},
+	{"FCALL", "function numkeys [key [key ...]] [arg [arg ...]]", "scripting"},
+	{"FCALL_RO", "function numkeys [key [key ...]] [arg [arg ...]]", "scripting"},
+	{"FUNCTION DELETE", "library-name", "scripting"},
+	{"FUNCTION DUMP", "", "scripting"},
+	{"FUNCTION FLUSH", "[ASYNC|SYNC]", "scripting"},
+	{"FUNCTION LIST", "[LIBRARYNAME library-name-pattern] [WITHCODE]", "scripting"},
+	{"FUNCTION LOAD", "[REPLACE] function-code", "scripting"},
+	{"FUNCTION RESTORE", "serialized-value [FLUSH|APPEND|REPLACE]", "scripting"},
+	{"SCRIPT EXISTS", "sha1 [sha1 ...]", "scripting"},
+	{"SCRIPT FLUSH", "[ASYNC|SYNC]", "scripting"},
+	{"SCRIPT LOAD", "script", "scripting"},
+
+	{"CONFIG GET", "parameter [parameter ...]", "server"},
+	{"CONFIG RESETSTAT", "", "server"},
+	{"CONFIG REWRITE", "", "server"},
+	{"CONFIG SET", "parameter value [parameter value ...]", "server"},
+	{"INFO", "[section [section ...]]", "server"},
+}
+
+// Commands whose name starts with the line typed so far, ignoring case.
+// They are in the case the line is typed in.
+func completeCommand(line string) []string {
+	prefix := strings.TrimLeft(line, " ")
+	if prefix == "" {
+		return nil
+	}
+	lower := strings.ToLower(prefix) == prefix
+	var completions []string
+	for _, command := range cliCommands {
+		if strings.HasPrefix(command.name, strings.ToUpper(prefix)) {
+			name := command.name
+			if lower {
+				name = strings.ToLower(name)
+			}
+			completions = append(completions, name)
+		}
+	}
+	sort.Strings(completions)
+	return completions
+}
+
+// Arguments of the command typed so far, shown after it while typing
+// arguments aren't typed yet
+func commandHint(line string) string {
+	name := strings.ToUpper(strings.TrimSpace(line))
+	for _, command := range cliCommands {
+		if command.name != name || command.arguments == "" {
+			continue
+		}
+		if strings.HasSuffix(line, " ") {
+			return command.arguments
+		}
+		return " " + command.arguments
+	}
+	return ""
+}
+
+// Text of the help command: the syntax of a command, or the commands of a
+// group given as @group. Without a topic it lists the groups.
+func cliHelp(topic string) string {
+	var builder strings.Builder
+	if topic == "" {
+		groups := make(map[string]bool)
+		var names []string
+		for _, command := range cliCommands {
+			if !groups[command.group] {
+				groups[command.group] = true
+				names = append(names, "@"+command.group)
+			}
+		}
+		builder.WriteString("Type \"help <command>\" for the syntax of a command, \"help @<group>\" for the commands of a group among:\n")
+		builder.WriteString("  " + strings.Join(names, " ") + "\n")
+		builder.WriteString("TAB completes command names, \"quit\" exits.\n")
+		return builder.String()
+	}
+	topic = strings.ToUpper(topic)
+	for _, command := range cliCommands {
+		if command.name == topic || "@"+strings.ToUpper(command.group) == topic ||
+			strings.HasPrefix(command.name, topic+" ") {
+			builder.WriteString("\n  " + command.name + " " + command.arguments + "\n")
+			builder.WriteString("  group: " + command.group + "\n")
+		}
+	}
+	if builder.Len() == 0 {
+		return "No help for " + topic + "\n"
+	}
+	return builder.String()
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+var errInterrupted = errors.New("interrupted")
+
+// Most lines kept in the history file
+const MAX_HISTORY_LENGTH = 1000
+
+// Edits lines typed in a terminal put in raw mode, like linenoise used by
+// redis-cli: arrows and the usual emacs keys move the cursor and browse the
+// history, TAB goes through the completions of the line and the arguments
+// of a command are hinted after its name.
+type lineEditor struct {
+	input    *bufio.Reader
+	output   io.Writer
+	history  []string
+	complete func(line string) []string
+	hint     func(line string) string
+}
+
+// Reads a line once Enter is typed, io.EOF if ctrl-D is typed on an empty
+// line and errInterrupted for ctrl-C. The line isn't added to the history.
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	// the history being browsed, the last entry being the line typed
+	entries := append(append([]string(nil), e.history...), "")
+	index := len(entries) - 1
+	var line []rune
+	position := 0
+	// completions of the line TAB was first typed on, and the one shown
+	var completions []string
+	completion := -1
+
+	e.refresh(prompt, line, position)
+	for {
+		key, _, err := e.input.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		if key == '\t' {
+			if completion < 0 {
+				completions = e.complete(string(line))
+			}
+			if len(completions) == 0 {
+				io.WriteString(e.output, "\a")
+				continue
+			}
+			completion = (completion + 1) % len(completions)
+			e.refresh(prompt, []rune(completions[completion]), len([]rune(completions[completion])))
+			continue
+		}
+		if completion >= 0 {
+			// any other key keeps the completion shown
+			line = []rune(completions[completion])
+			position = len(line)
+			completion = -1
+		}
+		switch key {
+		case '\r', '\n':
+			e.refreshWithoutHint(prompt, line, len(line))
+			io.WriteString(e.output, "\r\n")
+			return string(line), nil
+		case 3: // ctrl-C
+			io.WriteString(e.output, "^C\r\n")
+			return "", errInterrupted
+		case 4: // ctrl-D
+			if len(line) == 0 {
+				io.WriteString(e.output, "\r\n")
+				return "", io.EOF
+			}
+			if position < len(line) {
+				line = append(line[:position], line[position+1:]...)
+			}
+		case 127, 8: // backspace
+			if position > 0 {
+				line = append(line[:position-1], line[position:]...)
+				position--
+			}
+		case 1: // ctrl-A
+			position = 0
+		case 5: // ctrl-E
+			position = len(line)
+		case 2: // ctrl-B
+			if position > 0 {
+				position--
+			}
+		case 6: // ctrl-F
+			if position < len(line) {
+				position++
+			}
+		case 11: // ctrl-K
+			line = line[:position]
+		case 21: // ctrl-U
+			line = line[position:]
+			position = 0
+		case 23: // ctrl-W
+			start := position
+			for start > 0 && line[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && line[start-1] != ' ' {
+				start--
+			}
+			line = append(line[:start], line[position:]...)
+			position = start
+		case 12: // ctrl-L
+			io.WriteString(e.output, "\x1b[H\x1b[2J")
+		case 16, 14: // ctrl-P and ctrl-N
+			index, line = e.browse(entries, index, line, key == 16)
+			position = len(line)
+		case 27: // escape sequences of arrows, home, end and delete
+			sequence := e.readEscapeSequence()
+			switch sequence {
+			case "[A", "[B":
+				index, line = e.browse(entries, index, line, sequence == "[A")
+				position = len(line)
+			case "[C":
+				if position < len(line) {
+					position++
+				}
+			case "[D":
+				if position > 0 {
+					position--
+				}
+			case "[H", "OH", "[1~":
+				position = 0
+			case "[F", "OF", "[4~":
+				position = len(line)
+			case "[3~":
+				if position < len(line) {
+					line = append(line[:position], line[position+1:]...)
+				}
+			}
+		default:
+			if key < ' ' {
+				continue
+			}
+			line = append(line[:position], append([]rune{key}, line[position:]...)...)
+			position++
+		}
+		e.refresh(prompt, line, position)
+	}
+}
+
+// Moves to the previous or next history entry, keeping the edits made to
+// the one left
+func (e *lineEditor) browse(entries []string, index int, line []rune, previous bool) (int, []rune) {
+	next := index + 1
+	if previous {
+		next = index - 1
+	}
+	if next < 0 || next >= len(entries) {
+		return index, line
+	}
+	entries[index] = string(line)
+	return next, []rune(entries[next])
+}
+
+// Reads what follows ESC: [ or O and a letter, or [ digits and ~
+func (e *lineEditor) readEscapeSequence() string {
+	first, _, err := e.input.ReadRune()
+	if err != nil || (first != '[' && first != 'O') {
+		return ""
+	}
+	sequence := string(first)
+	for {
+		next, _, err := e.input.ReadRune()
+		if err != nil {
+			return ""
+		}
+		sequence += string(next)
+		if next < '0' || next > '9' {
+			return sequence
+		}
+	}
+}
+
+// Redraws the line with the cursor at position, followed by the hint of the
+// line in grey
+func (e *lineEditor) refresh(prompt string, line []rune, position int) {
+	hint := ""
+	if e.hint != nil {
+		hint = e.hint(string(line))
+	}
+	e.draw(prompt, line, position, hint)
+}
+
+func (e *lineEditor) refreshWithoutHint(prompt string, line []rune, position int) {
+	e.draw(prompt, line, position, "")
+}
+
+func (e *lineEditor) draw(prompt string, line []rune, position int, hint string) {
+	var builder strings.Builder
+	builder.WriteString("\r")
+	builder.WriteString(prompt)
+	builder.WriteString(string(line))
+	if hint != "" {
+		builder.WriteString("\x1b[90m" + hint + "\x1b[0m")
+	}
+	// erase what is left of a longer line, then move the cursor back
+	builder.WriteString("\x1b[0K\r")
+	if column := len([]rune(prompt)) + position; column > 0 {
+		builder.WriteString("\x1b[" + strconv.Itoa(column) + "C")
+	}
+	io.WriteString(e.output, builder.String())
+}
+
+// Adds a line to the history unless it repeats the last one
+func (e *lineEditor) addHistory(line string) {
+	if strings.TrimSpace(line) == "" || (len(e.history) > 0 && e.history[len(e.history)-1] == line) {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > MAX_HISTORY_LENGTH {
+		e.history = e.history[len(e.history)-MAX_HISTORY_LENGTH:]
+	}
+}
+
+// Reads the history saved by saveHistory, one line per entry. A missing
+// file is an empty history.
+func (e *lineEditor) loadHistory(filename string) error {
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		e.addHistory(scanner.Text())
+	}
+	return scanner.Err()
+}
+
+// Writes the history, lines being commands as typed they can't contain
+// line breaks
+func (e *lineEditor) saveHistory(filename string) error {
+	content := strings.Join(e.history, "\n")
+	if content != "" {
+		content += "\n"
+	}
+	return ioutil.WriteFile(filename, []byte(content), 0600)
+}
+
+// Whether file is a terminal rather than a pipe or a regular file
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Puts the terminal of stdin in raw mode, so keys are read as they are
+// typed without being echoed, using stty. restore puts back the mode it had.
+func makeRaw() (restore func(), err error) {
+	state, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, err
+	}
+	return func() { stty(strings.TrimSpace(state)) }, nil
+}
+
+func stty(args ...string) (string, error) {
+	command := exec.Command("stty", args...)
+	command.Stdin = os.Stdin
+	output, err := command.Output()
+	return string(output), err
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/thedeveloperr/redis-clone/respClient"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Commands sent together by --pipe
+const PIPE_BATCH_SIZE = 1000
+
+// Sends the commands read from input, in RESP like redis-cli --pipe expects
+// or as inline command lines, in batches whose replies are read before the
+// next is sent. Error replies are printed, and the exit code is 1 if there
+// were any.
+func (c *cli) runPipe(ctx context.Context, input io.Reader) int {
+	reader := bufio.NewReader(input)
+	errorCount, replyCount := 0, 0
+	var batch [][]string
+	send := func() error {
+		messages, err := c.sendBatch(ctx, batch)
+		replyCount += len(batch)
+		batch = nil
+		for _, message := range messages {
+			fmt.Fprintln(c.output, message)
+		}
+		errorCount += len(messages)
+		return err
+	}
+	for {
+		args, err := readCommand(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Fprintln(c.output, "Invalid input:", err)
+			return 1
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if batch = append(batch, args); len(batch) == PIPE_BATCH_SIZE {
+			if err := send(); err != nil {
+				fmt.Fprintln(c.output, "Error:", err)
+				return 1
+			}
+		}
+	}
+	if len(batch) > 0 {
+		if err := send(); err != nil {
+			fmt.Fprintln(c.output, "Error:", err)
+			return 1
+		}
+	}
+	fmt.Fprintln(c.output, "All data transferred.")
+	fmt.Fprintf(c.output, "errors: %d, replies: %d\n", errorCount, replyCount)
+	if errorCount > 0 {
+		return 1
+	}
+	return 0
+}
+
+// Runs commands in one pipeline or one batch request and returns the
+// messages of their error replies
+func (c *cli) sendBatch(ctx context.Context, commands [][]string) (errorMessages []string, err error) {
+	if c.http != nil {
+		replies, err := c.http.Batch(ctx, commands...)
+		if err != nil {
+			return nil, err
+		}
+		for _, reply := range replies {
+			if isErrorReply(reply) {
+				errorMessages = append(errorMessages, reply)
+			}
+		}
+		return errorMessages, nil
+	}
+	pipeline := c.resp.Pipeline()
+	for _, args := range commands {
+		pipeline.Do(args...)
+	}
+	replies, err := pipeline.Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, reply := range replies {
+		if replyError, ok := reply.(*respClient.Error); ok {
+			errorMessages = append(errorMessages, replyError.Message)
+		}
+	}
+	return errorMessages, nil
+}
+
+// Goes through the keys matching pattern, of type typeName if it isn't
+// empty, with SCAN. visit is called with each page of keys.
+func (c *cli) scanKeys(ctx context.Context, pattern string, typeName string, visit func(keys []string) error) error {
+	cursor := "0"
+	for {
+		args := []string{"SCAN", cursor, "COUNT", strconv.Itoa(c.options.count)}
+		if pattern != "" {
+			args = append(args, "MATCH", pattern)
+		}
+		if typeName != "" {
+			args = append(args, "TYPE", typeName)
+		}
+		reply, err := c.do(ctx, args...)
+		if err != nil {
+			return err
+		}
+		items, ok := reply.([]interface{})
+		if !ok || len(items) != 2 {
+			return fmt.Errorf("unexpected SCAN reply %v", reply)
+		}
+		cursor, _ = items[0].(string)
+		page, _ := items[1].([]interface{})
+		keys := make([]string, len(page))
+		for i, key := range page {
+			keys[i], _ = key.(string)
+		}
+		if err := visit(keys); err != nil {
+			return err
+		}
+		if cursor == "0" {
+			return nil
+		}
+	}
+}
+
+// Prints the keys matching --pattern one per line, like redis-cli --scan
+func (c *cli) runScan(ctx context.Context) int {
+	err := c.scanKeys(ctx, c.options.pattern, "", func(keys []string) error {
+		for _, key := range keys {
+			fmt.Fprintln(c.output, key)
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintln(c.output, "Error:", err)
+		return 1
+	}
+	return 0
+}
+
+// A type --bigkeys looks at, and how the size of its keys is measured
+type bigKeysType struct {
+	name    string
+	command string
+	unit    string
+}
+
+var bigKeysTypes = []bigKeysType{
+	{"string", "STRLEN", "bytes"},
+	{"list", "LLEN", "items"},
+	{"set", "SCARD", "members"},
+	{"zset", "ZCARD", "members"},
+	{"hash", "HLEN", "fields"},
+	{"stream", "XLEN", "entries"},
+}
+
+// Finds the biggest key of each type like redis-cli --bigkeys. Keys of each
+// type are scanned separately since a name can be used by several types.
+func (c *cli) runBigKeys(ctx context.Context) int {
+	fmt.Fprintln(c.output, "# Scanning the entire keyspace to find biggest keys as well as")
+	fmt.Fprintln(c.output, "# average sizes per key type.")
+	fmt.Fprintln(c.output)
+	type summary struct {
+		keys, total, biggestSize int64
+		biggest                  string
+	}
+	summaries := make([]summary, len(bigKeysTypes))
+	sampled := int64(0)
+	for i, kind := range bigKeysTypes {
+		s := &summaries[i]
+		err := c.scanKeys(ctx, "", kind.name, func(keys []string) error {
+			for _, key := range keys {
+				reply, err := c.do(ctx, kind.command, key)
+				if err != nil {
+					return err
+				}
+				size, _ := reply.(int64)
+				s.keys++
+				s.total += size
+				if s.biggest == "" || size > s.biggestSize {
+					s.biggest, s.biggestSize = key, size
+					fmt.Fprintf(c.output, "Biggest %6s found so far %s with %d %s\n", kind.name, quoteCLIString(key), size, kind.unit)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintln(c.output, "Error:", err)
+			return 1
+		}
+		sampled += s.keys
+	}
+
+	fmt.Fprintln(c.output)
+	fmt.Fprintln(c.output, "-------- summary -------")
+	fmt.Fprintln(c.output)
+	fmt.Fprintf(c.output, "Sampled %d keys in the keyspace!\n", sampled)
+	fmt.Fprintln(c.output)
+	for i, kind := range bigKeysTypes {
+		if s := summaries[i]; s.keys > 0 {
+			fmt.Fprintf(c.output, "Biggest %6s found %s has %d %s\n", kind.name, quoteCLIString(s.biggest), s.biggestSize, kind.unit)
+		}
+	}
+	fmt.Fprintln(c.output)
+	for i, kind := range bigKeysTypes {
+		s := summaries[i]
+		share, average := 0.0, 0.0
+		if sampled > 0 {
+			share = float64(s.keys) * 100 / float64(sampled)
+		}
+		if s.keys > 0 {
+			average = float64(s.total) / float64(s.keys)
+		}
+		fmt.Fprintf(c.output, "%d %ss with %d %s (%.2f%% of keys, avg size %.2f)\n", s.keys, kind.name, s.total, kind.unit, share, average)
+	}
+	return 0
+}
+
+// Sends PINGs until ctx is cancelled or --samples were sent, printing the
+// minimum, maximum and average time they took in milliseconds. In a
+// terminal the line is updated after each PING.
+func (c *cli) runLatency(ctx context.Context, terminal bool) int {
+	var minimum, maximum, total time.Duration
+	samples := 0
+	line := func() string {
+		average := 0.0
+		if samples > 0 {
+			average = float64(total) / float64(samples) / float64(time.Millisecond)
+		}
+		return fmt.Sprintf("min: %d, max: %d, avg: %.2f (%d samples)",
+			minimum.Milliseconds(), maximum.Milliseconds(), average, samples)
+	}
+	for c.options.samples == 0 || samples < c.options.samples {
+		start := time.Now()
+		if _, err := c.do(ctx, "PING"); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			fmt.Fprintln(c.output, "Error:", err)
+			return 1
+		}
+		elapsed := time.Since(start)
+		if samples == 0 || elapsed < minimum {
+			minimum = elapsed
+		}
+		if elapsed > maximum {
+			maximum = elapsed
+		}
+		total += elapsed
+		samples++
+		if terminal {
+			fmt.Fprint(c.output, "\r\x1b[2K"+line())
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(10 * time.Millisecond):
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if terminal {
+		fmt.Fprint(c.output, "\r\x1b[2K")
+	}
+	fmt.Fprintln(c.output, line())
+	return 0
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"github.com/thedeveloperr/redis-clone/respClient"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFormatCLIReply(t *testing.T) {
+	items := make([]interface{}, 10)
+	for i := range items {
+		items[i] = int64(i)
+	}
+	replies := []struct {
+		reply     interface{}
+		formatted string
+		raw       string
+	}{
+		{nil, "(nil)\n", "\n"},
+		{"OK", "OK\n", "OK\n"},
+		{"a \"b\"\n\x00é", "\"a \\\"b\\\"\\n\\x00\\xc3\\xa9\"\n", "a \"b\"\n\x00é\n"},
+		{int64(-3), "(integer) -3\n", "-3\n"},
+		{&respClient.Error{Message: "ERR nope"}, "(error) ERR nope\n", "ERR nope\n"},
+		{[]interface{}{}, "(empty list or set)\n", ""},
+		{
+			[]interface{}{"a", []interface{}{int64(1), nil, []interface{}{"x", "y"}}, "b"},
+			"1) \"a\"\n2) 1) (integer) 1\n   2) (nil)\n   3) 1) \"x\"\n      2) \"y\"\n3) \"b\"\n",
+			"a\n1\n\nx\ny\nb\n",
+		},
+		{
+			[]interface{}{[]interface{}{"k"}, items},
+			"1) 1) \"k\"\n2)  1) (integer) 0\n    2) (integer) 1\n    3) (integer) 2\n    4) (integer) 3\n" +
+				"    5) (integer) 4\n    6) (integer) 5\n    7) (integer) 6\n    8) (integer) 7\n" +
+				"    9) (integer) 8\n   10) (integer) 9\n",
+			"k\n0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+		},
+	}
+	for _, r := range replies {
+		if formatted := formatCLIReply(r.reply, 0); formatted != r.formatted {
+			t.Errorf("Expected %q for %#v but got %q", r.formatted, r.reply, formatted)
+		}
+		if raw := formatRawReply(r.reply); raw != r.raw {
+			t.Errorf("Expected raw %q for %#v but got %q", r.raw, r.reply, raw)
+		}
+	}
+}
+
+func TestCompletionAndHints(t *testing.T) {
+	if completions := completeCommand("zr"); !reflect.DeepEqual(completions, []string{"zrange", "zrank"}) {
+		t.Errorf("Unexpected completions %v", completions)
+	}
+	if completions := completeCommand("CONFIG "); !reflect.DeepEqual(completions, []string{"CONFIG GET", "CONFIG RESETSTAT", "CONFIG REWRITE", "CONFIG SET"}) {
+		t.Errorf("Unexpected completions %v", completions)
+	}
+	if completions := completeCommand("SET key"); len(completions) != 0 {
+		t.Errorf("Arguments shouldn't be completed but got %v", completions)
+	}
+	hints := map[string]string{"set": " key value", "SET ": "key value", "SET k": "", "MULTI": "", "nope": ""}
+	for line, expected := range hints {
+		if hint := commandHint(line); hint != expected {
+			t.Errorf("Expected hint %q for %q but got %q", expected, line, hint)
+		}
+	}
+	if help := cliHelp("zcard"); !strings.Contains(help, "ZCARD key") || !strings.Contains(help, "sorted_set") {
+		t.Errorf("Unexpected help %q", help)
+	}
+	if help := cliHelp("@transactions"); strings.Count(help, "group: transactions") != 5 {
+		t.Errorf("Unexpected help %q", help)
+	}
+}
+
+// Types keys into a line editor and returns the lines it read
+func editLines(editor *lineEditor, keys string) (lines []string, err error) {
+	editor.input = bufio.NewReader(strings.NewReader(keys))
+	editor.output = ioutil.Discard
+	for {
+		line, err := editor.readLine("> ")
+		if err != nil {
+			return lines, err
+		}
+		lines = append(lines, line)
+		editor.addHistory(line)
+	}
+}
+
+func TestLineEditor(t *testing.T) {
+	editor := &lineEditor{complete: completeCommand, hint: commandHint}
+	keys := "SET a 1\r" +
+		// cursor moved left to insert, then a word deleted
+		"GET x\x1b[D\x1b[Dy\r" +
+		"GET a b\x17\r" +
+		// history recalled and edited, then moved past the end
+		"\x1b[A\x1b[A\x7f2\r" +
+		"\x1b[A\x1b[B\x1b[Bnew\r" +
+		// tab cycling through zrange and zrank then typing arguments
+		"zr\t\t z\r" +
+		"\x15\x01FOO\x05 bar\r" +
+		"\x04"
+	lines, err := editLines(editor, keys)
+	if err != io.EOF {
+		t.Errorf("Expected io.EOF after ctrl-D but got %v", err)
+	}
+	expected := []string{"SET a 1", "GETy x", "GET a ", "GETy 2", "new", "zrank z", "FOO bar"}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected lines %q but got %q", expected, lines)
+	}
+
+	if _, err := editLines(&lineEditor{complete: completeCommand}, "abc\x03"); err != errInterrupted {
+		t.Errorf("Expected ctrl-C to interrupt but got %v", err)
+	}
+}
+
+func TestLineEditorHistoryFile(t *testing.T) {
+	directory, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+	filename := filepath.Join(directory, "history")
+	editor := &lineEditor{}
+	if err := editor.loadHistory(filename); err != nil || len(editor.history) != 0 {
+		t.Errorf("A missing history file should give an empty history but got %v %v", editor.history, err)
+	}
+	for i := 0; i < MAX_HISTORY_LENGTH+5; i++ {
+		editor.addHistory("INCR " + strconv.Itoa(i))
+	}
+	editor.addHistory("INCR " + strconv.Itoa(MAX_HISTORY_LENGTH+4))
+	editor.addHistory("  ")
+	if err := editor.saveHistory(filename); err != nil {
+		t.Fatal(err)
+	}
+	loaded := &lineEditor{}
+	if err := loaded.loadHistory(filename); err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.history) != MAX_HISTORY_LENGTH || loaded.history[0] != "INCR 5" {
+		t.Errorf("Expected the last %d lines but got %d starting with %q", MAX_HISTORY_LENGTH, len(loaded.history), loaded.history[0])
+	}
+}
+
+// cli talking to a test server over RESP, or over HTTP when overHTTP is set
+func createTestCLI(t *testing.T, overHTTP bool) (c *cli, output *bytes.Buffer, stop func()) {
+	store := CreateTestDbSetup()
+	output = &bytes.Buffer{}
+	options := cliOptions{count: 3}
+	if overHTTP {
+		inMemoryDb = store
+		server := httptest.NewServer(httpHandler())
+		options.httpURL = server.URL
+		c = createCLI(options, output)
+		return c, output, func() {
+			c.close()
+			server.Close()
+		}
+	}
+	listener := serveTestRESP(t, store)
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	options.host = host
+	options.port, _ = strconv.Atoi(port)
+	c = createCLI(options, output)
+	return c, output, func() {
+		c.close()
+		listener.Close()
+	}
+}
+
+func TestCLI(t *testing.T) {
+	for _, overHTTP := range []bool{false, true} {
+		c, output, stop := createTestCLI(t, overHTTP)
+		ctx := context.Background()
+		transport := map[bool]string{false: "RESP", true: "HTTP"}[overHTTP]
+		expectOutput := func(what string, expected string) {
+			t.Helper()
+			if output.String() != expected {
+				t.Errorf("%s over %s: expected %q but got %q", what, transport, expected, output.String())
+			}
+			output.Reset()
+		}
+
+		pipe := "*3\r\n$3\r\nSET\r\n$4\r\nuser\r\n$5\r\nalice\r\n"
+		for i := 0; i < PIPE_BATCH_SIZE+10; i++ {
+			pipe += "RPUSH queue " + strconv.Itoa(i) + "\n"
+		}
+		pipe += "\nNOPE\nSADD tags a b c\nHSET h f v\nZADD board 1 alice\nXADD events 1-1 k v\n"
+		if code := c.runPipe(ctx, strings.NewReader(pipe)); code != 1 {
+			t.Errorf("Pipe with an error reply should exit with 1 but got %v", code)
+		}
+		expectOutput("pipe", "COMMAND NOT VALID\nAll data transferred.\n"+
+			"errors: 1, replies: "+strconv.Itoa(PIPE_BATCH_SIZE+16)+"\n")
+
+		if code := c.runCommand(ctx, []string{"LRANGE", "queue", "0", "1"}); code != 0 {
+			t.Errorf("Expected exit code 0 but got %v", code)
+		}
+		expectOutput("LRANGE", "1) \"0\"\n2) \"1\"\n")
+		c.runCommand(ctx, []string{"lrange", "queue", "0", "0"})
+		expectOutput("lowercase LRANGE", "1) \"0\"\n")
+		if code := c.runCommand(ctx, []string{"SET", "user"}); code != 1 {
+			t.Errorf("Error replies should exit with 1 but got %v", code)
+		}
+		expectOutput("error reply", "(error) COMMAND NOT VALID\n")
+
+		c.raw = true
+		c.runLines(ctx, strings.NewReader("GET user\nGET \"unbalanced\n\nTYPE events\n"))
+		expectOutput("lines", "alice\nInvalid argument(s)\nstream\n")
+		c.raw = false
+
+		if c.runScan(ctx) != 0 {
+			t.Errorf("Scan failed: %v", output.String())
+		}
+		keys := strings.Split(strings.TrimSpace(output.String()), "\n")
+		found := map[string]bool{}
+		for _, key := range keys {
+			found[key] = true
+		}
+		if len(keys) != 6 || len(found) != 6 || !found["board"] || !found["events"] {
+			t.Errorf("Expected the 6 keys over %s but got %q", transport, keys)
+		}
+		output.Reset()
+		c.options.pattern = "u*"
+		c.runScan(ctx)
+		expectOutput("scan with pattern", "user\n")
+
+		if c.runBigKeys(ctx) != 0 {
+			t.Errorf("Bigkeys failed: %v", output.String())
+		}
+		for _, expected := range []string{
+			"Biggest   list found \"queue\" has " + strconv.Itoa(PIPE_BATCH_SIZE+10) + " items",
+			"Biggest string found \"user\" has 5 bytes",
+			"1 sets with 3 members (16.67% of keys, avg size 3.00)",
+			"1 streams with 1 entries",
+			"Sampled 6 keys",
+		} {
+			if !strings.Contains(output.String(), expected) {
+				t.Errorf("Expected %q in the bigkeys output over %s:\n%s", expected, transport, output.String())
+			}
+		}
+		output.Reset()
+
+		c.options.samples = 3
+		if c.runLatency(ctx, false) != 0 || !strings.HasSuffix(output.String(), "(3 samples)\n") {
+			t.Errorf("Unexpected latency output over %s %q", transport, output.String())
+		}
+		output.Reset()
+		stop()
+	}
+}
+
+func TestCLIReportsUnreachableServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	output := &bytes.Buffer{}
+	c := createCLI(cliOptions{host: "127.0.0.1", port: port}, output)
+	defer c.close()
+	if code := c.runCommand(context.Background(), []string{"PING"}); code != 1 {
+		t.Errorf("Expected exit code 1 but got %v", code)
+	}
+	if !strings.HasPrefix(output.String(), "Could not connect to 127.0.0.1:"+strconv.Itoa(port)) {
+		t.Errorf("Unexpected output %q", output.String())
+	}
+	if _, isReply := replyErrorMessage(errors.New("connection refused")); isReply {
+		t.Errorf("Network errors aren't error replies")
+	}
+}
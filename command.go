@@ -9,8 +9,163 @@ type Command struct {
 	fullText string
 }
 
+// Splits a command line into arguments the way redis-cli does. Arguments can be
+// wrapped in double quotes, supporting escapes like \n and \xHH for binary
+// data, or in single quotes where only \' is an escape.
+// ok is false for unbalanced quotes.
+func splitArgs(line string) (args []string, ok bool) {
+	i := 0
+	for {
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			return args, true
+		}
+		var current []byte
+		switch line[i] {
+		case '"':
+			i++
+			for {
+				if i == len(line) {
+					return nil, false
+				}
+				if line[i] == '"' {
+					i++
+					break
+				}
+				if line[i] == '\\' && i+1 < len(line) {
+					if line[i+1] == 'x' && i+3 < len(line) && isHexDigit(line[i+2]) && isHexDigit(line[i+3]) {
+						value, _ := strconv.ParseUint(line[i+2:i+4], 16, 8)
+						current = append(current, byte(value))
+						i += 4
+						continue
+					}
+					switch line[i+1] {
+					case 'n':
+						current = append(current, '\n')
+					case 'r':
+						current = append(current, '\r')
+					case 't':
+						current = append(current, '\t')
+					case 'b':
+						current = append(current, '\b')
+					case 'a':
+						current = append(current, '\a')
+					default:
+						current = append(current, line[i+1])
+					}
+					i += 2
+					continue
+				}
+				current = append(current, line[i])
+				i++
+			}
+		case '\'':
+			i++
+			for {
+				if i == len(line) {
+					return nil, false
+				}
+				if line[i] == '\'' {
+					i++
+					break
+				}
+				if line[i] == '\\' && i+1 < len(line) && line[i+1] == '\'' {
+					current = append(current, '\'')
+					i += 2
+					continue
+				}
+				current = append(current, line[i])
+				i++
+			}
+		default:
+			for i < len(line) && !isSpace(line[i]) {
+				current = append(current, line[i])
+				i++
+			}
+		}
+		// closing quote must be followed by a space or the end of line
+		if i < len(line) && !isSpace(line[i]) {
+			return nil, false
+		}
+		args = append(args, string(current))
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// Joins arguments into a command line that splitArgs turns back into the
+// same arguments, quoting the ones which need it. Used to build AOF entries.
+func formatCommand(args ...string) string {
+	var builder strings.Builder
+	for i, arg := range args {
+		if i > 0 {
+			builder.WriteByte(' ')
+		}
+		needsQuotes := arg == ""
+		for j := 0; j < len(arg) && !needsQuotes; j++ {
+			c := arg[j]
+			needsQuotes = c <= ' ' || c == 0x7f || c == '"' || c == '\'' || c == '\\'
+		}
+		if !needsQuotes {
+			builder.WriteString(arg)
+			continue
+		}
+		builder.WriteByte('"')
+		for j := 0; j < len(arg); j++ {
+			c := arg[j]
+			switch {
+			case c == '\\' || c == '"':
+				builder.WriteByte('\\')
+				builder.WriteByte(c)
+			case c == '\n':
+				builder.WriteString("\\n")
+			case c == '\r':
+				builder.WriteString("\\r")
+			case c == '\t':
+				builder.WriteString("\\t")
+			case c < ' ' || c == 0x7f:
+				builder.WriteString("\\x")
+				builder.WriteString(strconv.FormatUint(uint64(c)>>4, 16))
+				builder.WriteString(strconv.FormatUint(uint64(c)&0xf, 16))
+			default:
+				builder.WriteByte(c)
+			}
+		}
+		builder.WriteByte('"')
+	}
+	return builder.String()
+}
+
 func (c Command) parse() (commandType string, key string, parsedArguments [][2]string) {
-	commandComponents := strings.Fields(c.fullText)
+	commandComponents, ok := splitArgs(c.fullText)
+	if !ok {
+		return
+	}
+	return parseComponents(commandComponents)
+}
+
+// Parses a command line like parse, also returning it with its arguments
+// quoted by formatCommand. That's what is logged to the AOF rather than the
+// line as sent, where a quoted value may hold a newline and would be replayed
+// as a command of its own.
+func parseCommandLine(line string) (commandType string, key string, parsedArguments [][2]string, command string) {
+	commandComponents, ok := splitArgs(line)
+	if !ok {
+		return "", "", nil, line
+	}
+	commandType, key, parsedArguments = parseComponents(commandComponents)
+	return commandType, key, parsedArguments, formatCommand(commandComponents...)
+}
+
+func parseComponents(commandComponents []string) (commandType string, key string, parsedArguments [][2]string) {
 	if len(commandComponents) == 0 {
 		return
 	}
@@ -19,12 +174,26 @@ func (c Command) parse() (commandType string, key string, parsedArguments [][2]s
 		key = commandComponents[1]
 		return
 	}
-	if commandComponents[0] == "SET" && len(commandComponents) == 3 {
+	if commandComponents[0] == "PING" && len(commandComponents) <= 2 {
+		commandType = "PING"
+		if len(commandComponents) == 2 {
+			parsedArguments = [][2]string{
+				{commandComponents[1], ""},
+			}
+		}
+		return
+	}
+	// KEEPTTL keeps the deadline of the key replaced
+	if commandComponents[0] == "SET" && (len(commandComponents) == 3 ||
+		len(commandComponents) == 4 && commandComponents[3] == "KEEPTTL") {
 		commandType = "SET"
 		key = commandComponents[1]
 		parsedArguments = [][2]string{
 			{commandComponents[2], ""},
 		}
+		if len(commandComponents) == 4 {
+			parsedArguments[0][1] = "KEEPTTL"
+		}
 		return
 	}
 
@@ -39,6 +208,22 @@ func (c Command) parse() (commandType string, key string, parsedArguments [][2]s
 		}
 		return
 	}
+	if commandComponents[0] == "PEXPIREAT" && len(commandComponents) == 3 {
+		if _, err := strconv.ParseInt(commandComponents[2], 10, 64); err != nil {
+			return
+		}
+		commandType = "PEXPIREAT"
+		key = commandComponents[1]
+		parsedArguments = [][2]string{
+			{commandComponents[2], ""},
+		}
+		return
+	}
+	if commandComponents[0] == "PERSIST" && len(commandComponents) == 2 {
+		commandType = "PERSIST"
+		key = commandComponents[1]
+		return
+	}
 	if commandComponents[0] == "ZRANK" && len(commandComponents) == 3 {
 		commandType = "ZRANK"
 		key = commandComponents[1]
@@ -47,6 +232,11 @@ func (c Command) parse() (commandType string, key string, parsedArguments [][2]s
 		}
 		return
 	}
+	if commandComponents[0] == "ZCARD" && len(commandComponents) == 2 {
+		commandType = "ZCARD"
+		key = commandComponents[1]
+		return
+	}
 	if commandComponents[0] == "ZADD" && len(commandComponents) >= 4 &&
 		len(commandComponents)%2 == 0 {
 		commandType = "ZADD"
@@ -75,6 +265,136 @@ func (c Command) parse() (commandType string, key string, parsedArguments [][2]s
 		}
 		return
 	}
+	for _, parser := range commandParsers {
+		if commandType, key, parsedArguments = parser(commandComponents); commandType != "" {
+			return
+		}
+	}
 	return
+}
+
+// Commands which modify data, which read only functions can't call. PFCOUNT
+// is one like in redis, as it logs the estimate it caches in the value.
+var writeCommands = map[string]bool{
+	"SET": true, "DEL": true, "EXPIRE": true, "PEXPIREAT": true, "PERSIST": true, "ZADD": true,
+	"APPEND": true, "DECR": true, "DECRBY": true, "GETDEL": true, "GETEX": true, "GETSET": true,
+	"INCR": true, "INCRBY": true, "INCRBYFLOAT": true, "MSET": true, "MSETNX": true, "SETRANGE": true,
+	"SETBIT": true, "BITFIELD": true, "BITOP": true, "PFADD": true, "PFCOUNT": true, "PFMERGE": true,
+	"HDEL": true, "HEXPIRE": true, "HEXPIREAT": true, "HINCRBY": true, "HINCRBYFLOAT": true,
+	"HPERSIST": true, "HPEXPIRE": true, "HPEXPIREAT": true, "HSET": true, "HSETNX": true,
+	"LINSERT": true, "LMOVE": true, "BLMOVE": true, "LPOP": true, "RPOP": true, "BLPOP": true, "BRPOP": true,
+	"LPUSH": true, "RPUSH": true, "LREM": true, "LSET": true, "LTRIM": true,
+	"SADD": true, "SREM": true, "SPOP": true, "SMOVE": true, "SDIFFSTORE": true, "SINTERSTORE": true, "SUNIONSTORE": true,
+	"XADD": true, "XTRIM": true, "XDEL": true, "XGROUP": true, "XREADGROUP": true, "XACK": true, "XCLAIM": true, "XAUTOCLAIM": true,
+	"GEOADD": true, "GEOSEARCHSTORE": true, "FUNCTION": true,
+}
+
+// Keys a command on a data type reads or writes, from its components. Most
+// commands touch only the key following their name.
+func commandKeys(args []string) []string {
+	if len(args) < 2 {
+		return nil
+	}
+	switch args[0] {
+	case "MSET", "MSETNX":
+		var keys []string
+		for i := 1; i < len(args); i += 2 {
+			keys = append(keys, args[i])
+		}
+		return keys
+	case "LMOVE", "BLMOVE", "SMOVE", "GEOSEARCHSTORE":
+		if len(args) > 2 {
+			return args[1:3]
+		}
+	case "BLPOP", "BRPOP":
+		return args[1 : len(args)-1]
+	case "BITOP":
+		return args[2:]
+	case "DEL", "MGET", "PFCOUNT", "PFMERGE", "SDIFF", "SINTER", "SUNION", "SDIFFSTORE", "SINTERSTORE", "SUNIONSTORE":
+		return args[1:]
+	case "SINTERCARD":
+		if numKeys, err := strconv.Atoi(args[1]); err == nil && numKeys >= 0 && numKeys <= len(args)-2 {
+			return args[2 : 2+numKeys]
+		}
+		return nil
+	case "XGROUP":
+		return args[2:3]
+	case "XREAD", "XREADGROUP":
+		// after the options, or GROUP group consumer where a name could be STREAMS
+		start := 1
+		if args[0] == "XREADGROUP" {
+			start = 4
+		}
+		for i := start; i < len(args); i++ {
+			if args[i] == "STREAMS" {
+				streams := args[i+1:]
+				return streams[:len(streams)/2]
+			}
+		}
+		return nil
+	case "FUNCTION":
+		return nil
+	}
+	return args[1:2]
+}
+
+// Data type the keys of each command must hold, named like the replies of
+// TYPE. MGET reads keys of other types as missing like in redis, so it isn't
+// listed.
+var commandDataTypes = map[string]string{
+	"GET": "string", "SET": "string", "APPEND": "string", "DECR": "string", "DECRBY": "string",
+	"GETDEL": "string", "GETEX": "string", "GETRANGE": "string", "GETSET": "string", "INCR": "string",
+	"INCRBY": "string", "INCRBYFLOAT": "string", "MSET": "string", "MSETNX": "string", "SETRANGE": "string",
+	"STRLEN": "string", "SETBIT": "string", "GETBIT": "string", "BITCOUNT": "string", "BITPOS": "string",
+	"BITOP": "string", "BITFIELD": "string", "PFADD": "string", "PFCOUNT": "string", "PFMERGE": "string",
+	"HSET": "hash", "HSETNX": "hash", "HGET": "hash", "HMGET": "hash", "HGETALL": "hash", "HDEL": "hash",
+	"HEXISTS": "hash", "HLEN": "hash", "HKEYS": "hash", "HVALS": "hash", "HSTRLEN": "hash", "HINCRBY": "hash",
+	"HINCRBYFLOAT": "hash", "HRANDFIELD": "hash", "HEXPIRE": "hash", "HPEXPIRE": "hash", "HEXPIREAT": "hash",
+	"HPEXPIREAT": "hash", "HTTL": "hash", "HPTTL": "hash", "HPERSIST": "hash", "HSCAN": "hash",
+	"LPUSH": "list", "RPUSH": "list", "LPOP": "list", "RPOP": "list", "LRANGE": "list", "LINDEX": "list",
+	"LSET": "list", "LLEN": "list", "LREM": "list", "LTRIM": "list", "LINSERT": "list", "LPOS": "list",
+	"LMOVE": "list", "BLPOP": "list", "BRPOP": "list", "BLMOVE": "list",
+	"SADD": "set", "SREM": "set", "SISMEMBER": "set", "SMISMEMBER": "set", "SMEMBERS": "set", "SCARD": "set",
+	"SPOP": "set", "SRANDMEMBER": "set", "SMOVE": "set", "SINTER": "set", "SUNION": "set", "SDIFF": "set",
+	"SINTERSTORE": "set", "SUNIONSTORE": "set", "SDIFFSTORE": "set", "SINTERCARD": "set", "SSCAN": "set",
+	"ZADD": "zset", "ZRANGE": "zset", "ZRANK": "zset", "ZCARD": "zset", "GEOADD": "zset", "GEOPOS": "zset",
+	"GEODIST": "zset", "GEOHASH": "zset", "GEOSEARCH": "zset", "GEOSEARCHSTORE": "zset",
+	"XADD": "stream", "XTRIM": "stream", "XRANGE": "stream", "XREVRANGE": "stream", "XLEN": "stream",
+	"XDEL": "stream", "XREAD": "stream", "XREADGROUP": "stream", "XGROUP": "stream", "XACK": "stream",
+	"XPENDING": "stream", "XCLAIM": "stream", "XAUTOCLAIM": "stream",
+}
+
+// Keys of a command whose type is checked against commandDataTypes. The
+// destinations a command replaces whatever they hold are left out.
+func typeCheckedKeys(args []string) []string {
+	keys := commandKeys(args)
+	if len(keys) == 0 {
+		return nil
+	}
+	switch args[0] {
+	case "SET", "MSET", "MSETNX":
+		return nil
+	case "BITOP", "GEOSEARCHSTORE", "SDIFFSTORE", "SINTERSTORE", "SUNIONSTORE":
+		return keys[1:]
+	}
+	return keys
+}
 
+// Parsers of each data type's commands, tried in order until one recognises the command
+var commandParsers = []func(commandComponents []string) (commandType string, key string, parsedArguments [][2]string){
+	parseStringCommand,
+	parseBitmapCommand,
+	parseHyperLogLogCommand,
+	parseHashCommand,
+	parseListCommand,
+	parseSetCommand,
+	parseStreamCommand,
+	parseGeoCommand,
+	parsePubSubCommand,
+	parseTransactionCommand,
+	parseScriptCommand,
+	parseFunctionCommand,
+	parseConfigCommand,
+	parseKeyspaceCommand,
+	parseInfoCommand,
 }
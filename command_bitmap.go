@@ -0,0 +1,178 @@
+package main
+
+import (
+	"github.com/thedeveloperr/redis-clone/hashmap"
+	"strconv"
+	"strings"
+)
+
+func isBitOffset(text string) bool {
+	offset, err := strconv.ParseUint(text, 10, 64)
+	return err == nil && offset <= hashmap.MAX_BIT_OFFSET
+}
+
+// Parses BITFIELD sub commands: GET type offset, SET type offset value,
+// INCRBY type offset increment and OVERFLOW WRAP|SAT|FAIL.
+// Type is i1 to i64 or u1 to u63, offset prefixed with # is multiplied by the type width.
+func parseBitfieldOps(tokens []string) (ops []hashmap.BitfieldOp, ok bool) {
+	overflow := "WRAP"
+	for i := 0; i < len(tokens); {
+		name := tokens[i]
+		if name == "OVERFLOW" {
+			if i+1 >= len(tokens) {
+				return nil, false
+			}
+			overflow = tokens[i+1]
+			if overflow != "WRAP" && overflow != "SAT" && overflow != "FAIL" {
+				return nil, false
+			}
+			i += 2
+			continue
+		}
+		argCount := 3
+		if name == "GET" {
+			argCount = 2
+		} else if name != "SET" && name != "INCRBY" {
+			return nil, false
+		}
+		if i+argCount >= len(tokens) {
+			return nil, false
+		}
+		op := hashmap.BitfieldOp{Kind: name, Overflow: overflow}
+		encoding := tokens[i+1]
+		if len(encoding) < 2 || (encoding[0] != 'i' && encoding[0] != 'u') {
+			return nil, false
+		}
+		width, err := strconv.ParseUint(encoding[1:], 10, 8)
+		op.Signed = encoding[0] == 'i'
+		if err != nil || width < 1 || (op.Signed && width > 64) || (!op.Signed && width > 63) {
+			return nil, false
+		}
+		op.Bits = uint(width)
+		offsetText := tokens[i+2]
+		multiplier := uint64(1)
+		if strings.HasPrefix(offsetText, "#") {
+			offsetText = offsetText[1:]
+			multiplier = uint64(width)
+		}
+		offset, err := strconv.ParseUint(offsetText, 10, 64)
+		if err != nil || offset > hashmap.MAX_BIT_OFFSET {
+			return nil, false
+		}
+		op.Offset = offset * multiplier
+		if name != "GET" {
+			value, err := strconv.ParseInt(tokens[i+3], 10, 64)
+			if err != nil {
+				return nil, false
+			}
+			op.Value = value
+		}
+		ops = append(ops, op)
+		i += argCount + 1
+	}
+	return ops, true
+}
+
+// Parses bit level commands on string values
+func parseBitmapCommand(commandComponents []string) (commandType string, key string, parsedArguments [][2]string) {
+	name := commandComponents[0]
+	if name == "SETBIT" && len(commandComponents) == 4 {
+		if !isBitOffset(commandComponents[2]) ||
+			(commandComponents[3] != "0" && commandComponents[3] != "1") {
+			return
+		}
+		commandType = name
+		key = commandComponents[1]
+		parsedArguments = [][2]string{
+			{commandComponents[2], commandComponents[3]},
+		}
+		return
+	}
+	if name == "GETBIT" && len(commandComponents) == 3 {
+		if !isBitOffset(commandComponents[2]) {
+			return
+		}
+		commandType = name
+		key = commandComponents[1]
+		parsedArguments = [][2]string{
+			{commandComponents[2], ""},
+		}
+		return
+	}
+	if name == "BITCOUNT" && (len(commandComponents) == 2 || len(commandComponents) == 4 ||
+		len(commandComponents) == 5) {
+		if len(commandComponents) >= 4 {
+			if !isInteger(commandComponents[2]) || !isInteger(commandComponents[3]) {
+				return
+			}
+			parsedArguments = append(parsedArguments, [2]string{commandComponents[2], commandComponents[3]})
+		}
+		if len(commandComponents) == 5 {
+			if commandComponents[4] != "BYTE" && commandComponents[4] != "BIT" {
+				return
+			}
+			parsedArguments = append(parsedArguments, [2]string{commandComponents[4], ""})
+		}
+		commandType = name
+		key = commandComponents[1]
+		return
+	}
+	if name == "BITPOS" && len(commandComponents) >= 3 && len(commandComponents) <= 6 {
+		if commandComponents[2] != "0" && commandComponents[2] != "1" {
+			return
+		}
+		parsedArguments = [][2]string{
+			{commandComponents[2], ""},
+		}
+		if len(commandComponents) >= 4 {
+			// end is left empty when not given
+			bitRange := [2]string{commandComponents[3], ""}
+			if len(commandComponents) >= 5 {
+				bitRange[1] = commandComponents[4]
+			}
+			if !isInteger(bitRange[0]) || (bitRange[1] != "" && !isInteger(bitRange[1])) {
+				return
+			}
+			parsedArguments = append(parsedArguments, bitRange)
+		}
+		if len(commandComponents) == 6 {
+			if commandComponents[5] != "BYTE" && commandComponents[5] != "BIT" {
+				return
+			}
+			parsedArguments = append(parsedArguments, [2]string{commandComponents[5], ""})
+		}
+		commandType = name
+		key = commandComponents[1]
+		return
+	}
+	if name == "BITOP" && len(commandComponents) >= 4 {
+		operation := commandComponents[1]
+		if operation != "AND" && operation != "OR" && operation != "XOR" && operation != "NOT" {
+			return
+		}
+		if operation == "NOT" && len(commandComponents) != 4 {
+			return
+		}
+		commandType = name
+		key = commandComponents[2]
+		parsedArguments = [][2]string{
+			{operation, ""},
+		}
+		for _, source := range commandComponents[3:] {
+			parsedArguments = append(parsedArguments, [2]string{source, ""})
+		}
+		return
+	}
+	if name == "BITFIELD" && len(commandComponents) >= 2 {
+		if _, ok := parseBitfieldOps(commandComponents[2:]); !ok {
+			return
+		}
+		commandType = name
+		key = commandComponents[1]
+		for _, token := range commandComponents[2:] {
+			parsedArguments = append(parsedArguments, [2]string{token, ""})
+		}
+		return
+	}
+	return
+}
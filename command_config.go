@@ -0,0 +1,25 @@
+package main
+
+// Parses CONFIG GET parameter [parameter ...], CONFIG SET parameter value
+// [parameter value ...], CONFIG REWRITE and CONFIG RESETSTAT. The key is the
+// subcommand.
+func parseConfigCommand(commandComponents []string) (commandType string, key string, parsedArguments [][2]string) {
+	if commandComponents[0] != "CONFIG" || len(commandComponents) < 2 {
+		return
+	}
+	subcommand := commandComponents[1]
+	switch {
+	case subcommand == "GET" && len(commandComponents) >= 3:
+		for _, pattern := range commandComponents[2:] {
+			parsedArguments = append(parsedArguments, [2]string{pattern, ""})
+		}
+	case subcommand == "SET" && len(commandComponents) >= 4 && len(commandComponents)%2 == 0:
+		for i := 2; i < len(commandComponents); i += 2 {
+			parsedArguments = append(parsedArguments, [2]string{commandComponents[i], commandComponents[i+1]})
+		}
+	case (subcommand == "REWRITE" || subcommand == "RESETSTAT") && len(commandComponents) == 2:
+	default:
+		return
+	}
+	return "CONFIG", subcommand, parsedArguments
+}
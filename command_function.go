@@ -0,0 +1,56 @@
+package main
+
+// Parses FCALL function numkeys [key ...] [arg ...], FCALL_RO with the same
+// arguments, and the FUNCTION subcommands LOAD [REPLACE] code,
+// LIST [LIBRARYNAME pattern] [WITHCODE], DELETE library, DUMP,
+// RESTORE payload [FLUSH|APPEND|REPLACE] and FLUSH [ASYNC|SYNC].
+// For FCALL the key is the function and for FUNCTION the subcommand.
+func parseFunctionCommand(commandComponents []string) (commandType string, key string, parsedArguments [][2]string) {
+	name := commandComponents[0]
+	switch {
+	case (name == "FCALL" || name == "FCALL_RO") && len(commandComponents) >= 3:
+	case name == "FUNCTION" && len(commandComponents) >= 2:
+		arguments := commandComponents[2:]
+		switch commandComponents[1] {
+		case "LOAD":
+			if len(arguments) != 1 && !(len(arguments) == 2 && arguments[0] == "REPLACE") {
+				return
+			}
+		case "LIST":
+			for i := 0; i < len(arguments); i++ {
+				switch {
+				case arguments[i] == "WITHCODE":
+				case arguments[i] == "LIBRARYNAME" && i+1 < len(arguments):
+					i++
+				default:
+					return
+				}
+			}
+		case "DELETE":
+			if len(arguments) != 1 {
+				return
+			}
+		case "DUMP":
+			if len(arguments) != 0 {
+				return
+			}
+		case "RESTORE":
+			if len(arguments) != 1 && !(len(arguments) == 2 &&
+				(arguments[1] == "FLUSH" || arguments[1] == "APPEND" || arguments[1] == "REPLACE")) {
+				return
+			}
+		case "FLUSH":
+			if len(arguments) > 1 || (len(arguments) == 1 && arguments[0] != "ASYNC" && arguments[0] != "SYNC") {
+				return
+			}
+		default:
+			return
+		}
+	default:
+		return
+	}
+	for _, argument := range commandComponents[2:] {
+		parsedArguments = append(parsedArguments, [2]string{argument, ""})
+	}
+	return name, commandComponents[1], parsedArguments
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Meters per distance unit accepted by geo commands
+var geoUnits = map[string]float64{
+	"m":  1,
+	"km": 1000,
+	"ft": 0.3048,
+	"mi": 1609.34,
+}
+
+func isGeoUnit(text string) bool {
+	_, exists := geoUnits[strings.ToLower(text)]
+	return exists
+}
+
+func isNonNegativeFloat(text string) bool {
+	value, err := strconv.ParseFloat(text, 64)
+	return err == nil && value >= 0
+}
+
+// Parses the options of GEOSEARCH and GEOSEARCHSTORE in any order into
+//
+//	{FROMMEMBER|FROMLONLAT, member}, {longitude, latitude},
+//	{BYRADIUS|BYBOX, unit}, {radius|width, height},
+//	{ASC|DESC|"", count}, {ANY|"", ""}
+//
+// followed by one {flag, ""} per WITHCOORD, WITHDIST, WITHHASH or STOREDIST.
+// flags lists the flags allowed.
+func parseGeoSearch(components []string, flags map[string]bool) (parsedArguments [][2]string, ok bool) {
+	from, by := [2]string{}, [2]string{}
+	position, size := [2]string{}, [2]string{}
+	order, any := [2]string{}, [2]string{}
+	var flagArguments [][2]string
+	for i := 0; i < len(components); i++ {
+		remaining := len(components) - i - 1
+		switch option := components[i]; {
+		case option == "FROMMEMBER" && remaining >= 1 && from[0] == "":
+			from = [2]string{option, components[i+1]}
+			i++
+		case option == "FROMLONLAT" && remaining >= 2 && from[0] == "":
+			if !isFloat(components[i+1]) || !isFloat(components[i+2]) {
+				return nil, false
+			}
+			from = [2]string{option, ""}
+			position = [2]string{components[i+1], components[i+2]}
+			i += 2
+		case option == "BYRADIUS" && remaining >= 2 && by[0] == "":
+			if !isNonNegativeFloat(components[i+1]) || !isGeoUnit(components[i+2]) {
+				return nil, false
+			}
+			by = [2]string{option, strings.ToLower(components[i+2])}
+			size = [2]string{components[i+1], ""}
+			i += 2
+		case option == "BYBOX" && remaining >= 3 && by[0] == "":
+			if !isNonNegativeFloat(components[i+1]) || !isNonNegativeFloat(components[i+2]) || !isGeoUnit(components[i+3]) {
+				return nil, false
+			}
+			by = [2]string{option, strings.ToLower(components[i+3])}
+			size = [2]string{components[i+1], components[i+2]}
+			i += 3
+		case option == "ASC" || option == "DESC":
+			order[0] = option
+		case option == "COUNT" && remaining >= 1:
+			if count, err := strconv.ParseInt(components[i+1], 10, 64); err != nil || count <= 0 {
+				return nil, false
+			}
+			order[1] = components[i+1]
+			i++
+		case option == "ANY":
+			any[0] = option
+		case flags[option]:
+			flagArguments = append(flagArguments, [2]string{option, ""})
+		default:
+			return nil, false
+		}
+	}
+	if from[0] == "" || by[0] == "" || (any[0] == "ANY" && order[1] == "") {
+		return nil, false
+	}
+	parsedArguments = append([][2]string{from, position, by, size, order, any}, flagArguments...)
+	return parsedArguments, true
+}
+
+// Parses commands working on geo indexes, which are sorted sets
+func parseGeoCommand(commandComponents []string) (commandType string, key string, parsedArguments [][2]string) {
+	name := commandComponents[0]
+	if name == "GEOADD" && len(commandComponents) >= 5 {
+		i := 2
+		condition, changed := "", ""
+		for ; i < len(commandComponents); i++ {
+			option := commandComponents[i]
+			if (option == "NX" || option == "XX") && condition == "" {
+				condition = option
+			} else if option == "CH" {
+				changed = option
+			} else {
+				break
+			}
+		}
+		points := commandComponents[i:]
+		if len(points) == 0 || len(points)%3 != 0 {
+			return
+		}
+		parsedArguments = [][2]string{
+			{condition, changed},
+		}
+		for j := 0; j < len(points); j += 3 {
+			if !isFloat(points[j]) || !isFloat(points[j+1]) {
+				return "", "", nil
+			}
+			parsedArguments = append(parsedArguments, [2]string{points[j], points[j+1]}, [2]string{points[j+2], ""})
+		}
+		commandType = name
+		key = commandComponents[1]
+		return
+	}
+	if (name == "GEOPOS" || name == "GEOHASH") && len(commandComponents) >= 2 {
+		for _, member := range commandComponents[2:] {
+			parsedArguments = append(parsedArguments, [2]string{member, ""})
+		}
+		commandType = name
+		key = commandComponents[1]
+		return
+	}
+	if name == "GEODIST" && (len(commandComponents) == 4 || len(commandComponents) == 5) {
+		unit := "m"
+		if len(commandComponents) == 5 {
+			if !isGeoUnit(commandComponents[4]) {
+				return
+			}
+			unit = strings.ToLower(commandComponents[4])
+		}
+		commandType = name
+		key = commandComponents[1]
+		parsedArguments = [][2]string{
+			{commandComponents[2], commandComponents[3]},
+			{unit, ""},
+		}
+		return
+	}
+	if name == "GEOSEARCH" && len(commandComponents) >= 6 {
+		searchArguments, ok := parseGeoSearch(commandComponents[2:], map[string]bool{
+			"WITHCOORD": true, "WITHDIST": true, "WITHHASH": true,
+		})
+		if !ok {
+			return
+		}
+		commandType = name
+		key = commandComponents[1]
+		parsedArguments = searchArguments
+		return
+	}
+	if name == "GEOSEARCHSTORE" && len(commandComponents) >= 7 {
+		searchArguments, ok := parseGeoSearch(commandComponents[3:], map[string]bool{
+			"STOREDIST": true,
+		})
+		if !ok {
+			return
+		}
+		commandType = name
+		key = commandComponents[1]
+		parsedArguments = append([][2]string{{commandComponents[2], ""}}, searchArguments...)
+		return
+	}
+	return
+}
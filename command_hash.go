@@ -0,0 +1,182 @@
+package main
+
+import (
+	"strconv"
+)
+
+// Parses commands working on hashes of field value pairs
+func parseHashCommand(commandComponents []string) (commandType string, key string, parsedArguments [][2]string) {
+	name := commandComponents[0]
+	if name == "HSET" && len(commandComponents) >= 4 && len(commandComponents)%2 == 0 {
+		commandType = name
+		key = commandComponents[1]
+		for i := 2; i < len(commandComponents); i = i + 2 {
+			parsedArguments = append(parsedArguments, [2]string{
+				commandComponents[i],
+				commandComponents[i+1],
+			})
+		}
+		return
+	}
+	if name == "HSETNX" && len(commandComponents) == 4 {
+		commandType = name
+		key = commandComponents[1]
+		parsedArguments = [][2]string{
+			{commandComponents[2], commandComponents[3]},
+		}
+		return
+	}
+	if (name == "HGET" || name == "HEXISTS" || name == "HSTRLEN") && len(commandComponents) == 3 {
+		commandType = name
+		key = commandComponents[1]
+		parsedArguments = [][2]string{
+			{commandComponents[2], ""},
+		}
+		return
+	}
+	if (name == "HMGET" || name == "HDEL") && len(commandComponents) >= 3 {
+		commandType = name
+		key = commandComponents[1]
+		for _, field := range commandComponents[2:] {
+			parsedArguments = append(parsedArguments, [2]string{field, ""})
+		}
+		return
+	}
+	if (name == "HGETALL" || name == "HLEN" || name == "HKEYS" || name == "HVALS") &&
+		len(commandComponents) == 2 {
+		commandType = name
+		key = commandComponents[1]
+		return
+	}
+	if (name == "HINCRBY" || name == "HINCRBYFLOAT") && len(commandComponents) == 4 {
+		if name == "HINCRBY" && !isInteger(commandComponents[3]) {
+			return
+		}
+		if name == "HINCRBYFLOAT" && !isFloat(commandComponents[3]) {
+			return
+		}
+		commandType = name
+		key = commandComponents[1]
+		parsedArguments = [][2]string{
+			{commandComponents[2], commandComponents[3]},
+		}
+		return
+	}
+	if name == "HRANDFIELD" && len(commandComponents) >= 2 && len(commandComponents) <= 4 {
+		if len(commandComponents) >= 3 {
+			if !isInteger(commandComponents[2]) {
+				return
+			}
+			withValues := ""
+			if len(commandComponents) == 4 {
+				if commandComponents[3] != "WITHVALUES" {
+					return
+				}
+				withValues = "WITHVALUES"
+			}
+			parsedArguments = [][2]string{
+				{commandComponents[2], withValues},
+			}
+		}
+		commandType = name
+		key = commandComponents[1]
+		return
+	}
+	if (name == "HEXPIRE" || name == "HPEXPIRE" || name == "HEXPIREAT" || name == "HPEXPIREAT") &&
+		len(commandComponents) >= 6 {
+		if at, err := strconv.ParseInt(commandComponents[2], 10, 64); err != nil || at < 0 {
+			return
+		}
+		condition := ""
+		fieldsAt := 3
+		switch commandComponents[3] {
+		case "NX", "XX", "GT", "LT":
+			condition = commandComponents[3]
+			fieldsAt = 4
+		}
+		fields, ok := parseFieldsArgument(commandComponents[fieldsAt:])
+		if !ok {
+			return
+		}
+		commandType = name
+		key = commandComponents[1]
+		parsedArguments = append([][2]string{{commandComponents[2], condition}}, fields...)
+		return
+	}
+	if (name == "HTTL" || name == "HPTTL" || name == "HPERSIST") && len(commandComponents) >= 5 {
+		fields, ok := parseFieldsArgument(commandComponents[2:])
+		if !ok {
+			return
+		}
+		commandType = name
+		key = commandComponents[1]
+		parsedArguments = fields
+		return
+	}
+	if name == "HSCAN" && len(commandComponents) >= 3 {
+		scanArguments, ok := parseScanOptions(commandComponents[2:], true)
+		if !ok {
+			return
+		}
+		commandType = name
+		key = commandComponents[1]
+		parsedArguments = scanArguments
+		return
+	}
+	return
+}
+
+// Parses "cursor [MATCH pattern] [COUNT count] [NOVALUES]" into
+// {cursor, ""} followed by {option, value} pairs
+func parseScanOptions(components []string, allowNoValues bool) (parsedArguments [][2]string, ok bool) {
+	if _, err := strconv.ParseUint(components[0], 10, 64); err != nil {
+		return nil, false
+	}
+	parsedArguments = [][2]string{
+		{components[0], ""},
+	}
+	for i := 1; i < len(components); {
+		switch components[i] {
+		case "MATCH":
+			if i+1 >= len(components) {
+				return nil, false
+			}
+			parsedArguments = append(parsedArguments, [2]string{"MATCH", components[i+1]})
+			i += 2
+		case "COUNT":
+			if i+1 >= len(components) {
+				return nil, false
+			}
+			if count, err := strconv.Atoi(components[i+1]); err != nil || count < 1 {
+				return nil, false
+			}
+			parsedArguments = append(parsedArguments, [2]string{"COUNT", components[i+1]})
+			i += 2
+		case "NOVALUES":
+			if !allowNoValues {
+				return nil, false
+			}
+			parsedArguments = append(parsedArguments, [2]string{"NOVALUES", ""})
+			i++
+		default:
+			return nil, false
+		}
+	}
+	return parsedArguments, true
+}
+
+// Parses "FIELDS numfields field [field ...]" where numfields must match
+// the number of fields given
+func parseFieldsArgument(components []string) (parsedArguments [][2]string, ok bool) {
+	if len(components) < 3 || components[0] != "FIELDS" {
+		return nil, false
+	}
+	numFields, err := strconv.Atoi(components[1])
+	if err != nil || numFields != len(components)-2 {
+		return nil, false
+	}
+	for _, field := range components[2:] {
+		parsedArguments = append(parsedArguments, [2]string{field, ""})
+	}
+	return parsedArguments, true
+}
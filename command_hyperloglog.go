@@ -0,0 +1,15 @@
+package main
+
+// Parses PFADD key [element ...], PFCOUNT key [key ...] and PFMERGE destkey [sourcekey ...].
+// Elements and the other keys become one argument each.
+func parseHyperLogLogCommand(commandComponents []string) (commandType string, key string, parsedArguments [][2]string) {
+	name := commandComponents[0]
+	if (name == "PFADD" || name == "PFCOUNT" || name == "PFMERGE") && len(commandComponents) >= 2 {
+		for _, argument := range commandComponents[2:] {
+			parsedArguments = append(parsedArguments, [2]string{argument, ""})
+		}
+		commandType = name
+		key = commandComponents[1]
+	}
+	return
+}
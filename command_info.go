@@ -0,0 +1,12 @@
+package main
+
+// Parses INFO [section ...]
+func parseInfoCommand(commandComponents []string) (commandType string, key string, parsedArguments [][2]string) {
+	if commandComponents[0] != "INFO" {
+		return
+	}
+	for _, section := range commandComponents[1:] {
+		parsedArguments = append(parsedArguments, [2]string{section, ""})
+	}
+	return "INFO", "", parsedArguments
+}
@@ -0,0 +1,43 @@
+package main
+
+// Parses the commands about keys of any type: SCAN cursor [MATCH pattern]
+// [COUNT count] [TYPE type], TYPE key, DEL key [key ...] and DBSIZE. The
+// TYPE option of SCAN comes last in the arguments.
+func parseKeyspaceCommand(commandComponents []string) (commandType string, key string, parsedArguments [][2]string) {
+	name := commandComponents[0]
+	switch {
+	case name == "SCAN" && len(commandComponents) >= 2:
+		// options take a value each, the TYPE one is taken out before the others are parsed
+		var options []string
+		typeName := ""
+		for i := 2; i < len(commandComponents); i += 2 {
+			if commandComponents[i] == "TYPE" && i+1 < len(commandComponents) {
+				typeName = commandComponents[i+1]
+				continue
+			}
+			end := i + 2
+			if end > len(commandComponents) {
+				end = len(commandComponents)
+			}
+			options = append(options, commandComponents[i:end]...)
+		}
+		scanArguments, ok := parseScanOptions(append([]string{commandComponents[1]}, options...), false)
+		if !ok {
+			return
+		}
+		if typeName != "" {
+			scanArguments = append(scanArguments, [2]string{"TYPE", typeName})
+		}
+		return name, "", scanArguments
+	case name == "TYPE" && len(commandComponents) == 2:
+		return name, commandComponents[1], nil
+	case name == "DEL" && len(commandComponents) >= 2:
+		for _, k := range commandComponents[1:] {
+			parsedArguments = append(parsedArguments, [2]string{k, ""})
+		}
+		return name, commandComponents[1], parsedArguments
+	case name == "DBSIZE" && len(commandComponents) == 1:
+		return name, "", nil
+	}
+	return
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"strconv"
+)
+
+func isListEnd(text string) bool {
+	return text == "LEFT" || text == "RIGHT"
+}
+
+// Blocking timeouts are seconds which can have a fraction, like redis
+func isTimeout(text string) bool {
+	timeout, err := strconv.ParseFloat(text, 64)
+	return err == nil && timeout >= 0
+}
+
+// Parses commands working on lists
+func parseListCommand(commandComponents []string) (commandType string, key string, parsedArguments [][2]string) {
+	name := commandComponents[0]
+	if (name == "LPUSH" || name == "RPUSH") && len(commandComponents) >= 3 {
+		commandType = name
+		key = commandComponents[1]
+		for _, value := range commandComponents[2:] {
+			parsedArguments = append(parsedArguments, [2]string{value, ""})
+		}
+		return
+	}
+	if (name == "LPOP" || name == "RPOP") && (len(commandComponents) == 2 || len(commandComponents) == 3) {
+		if len(commandComponents) == 3 {
+			if count, err := strconv.ParseInt(commandComponents[2], 10, 64); err != nil || count < 0 {
+				return
+			}
+			parsedArguments = [][2]string{
+				{commandComponents[2], ""},
+			}
+		}
+		commandType = name
+		key = commandComponents[1]
+		return
+	}
+	if (name == "LRANGE" || name == "LTRIM") && len(commandComponents) == 4 {
+		if !isInteger(commandComponents[2]) || !isInteger(commandComponents[3]) {
+			return
+		}
+		commandType = name
+		key = commandComponents[1]
+		parsedArguments = [][2]string{
+			{commandComponents[2], commandComponents[3]},
+		}
+		return
+	}
+	if name == "LINDEX" && len(commandComponents) == 3 && isInteger(commandComponents[2]) {
+		commandType = name
+		key = commandComponents[1]
+		parsedArguments = [][2]string{
+			{commandComponents[2], ""},
+		}
+		return
+	}
+	if (name == "LSET" || name == "LREM") && len(commandComponents) == 4 && isInteger(commandComponents[2]) {
+		commandType = name
+		key = commandComponents[1]
+		parsedArguments = [][2]string{
+			{commandComponents[2], commandComponents[3]},
+		}
+		return
+	}
+	if name == "LLEN" && len(commandComponents) == 2 {
+		commandType = name
+		key = commandComponents[1]
+		return
+	}
+	if name == "LINSERT" && len(commandComponents) == 5 &&
+		(commandComponents[2] == "BEFORE" || commandComponents[2] == "AFTER") {
+		commandType = name
+		key = commandComponents[1]
+		parsedArguments = [][2]string{
+			{commandComponents[2], ""},
+			{commandComponents[3], commandComponents[4]},
+		}
+		return
+	}
+	if name == "LPOS" && len(commandComponents) >= 3 {
+		parsedArguments = [][2]string{
+			{commandComponents[2], ""},
+		}
+		for i := 3; i < len(commandComponents); i = i + 2 {
+			option := commandComponents[i]
+			if (option != "RANK" && option != "COUNT" && option != "MAXLEN") || i+1 >= len(commandComponents) {
+				return "", "", nil
+			}
+			value, err := strconv.ParseInt(commandComponents[i+1], 10, 64)
+			if err != nil || (option == "RANK" && value == 0) || (option != "RANK" && value < 0) {
+				return "", "", nil
+			}
+			parsedArguments = append(parsedArguments, [2]string{option, commandComponents[i+1]})
+		}
+		commandType = name
+		key = commandComponents[1]
+		return
+	}
+	if name == "LMOVE" && len(commandComponents) == 5 &&
+		isListEnd(commandComponents[3]) && isListEnd(commandComponents[4]) {
+		commandType = name
+		key = commandComponents[1]
+		parsedArguments = [][2]string{
+			{commandComponents[2], ""},
+			{commandComponents[3], commandComponents[4]},
+		}
+		return
+	}
+	if (name == "BLPOP" || name == "BRPOP") && len(commandComponents) >= 3 &&
+		isTimeout(commandComponents[len(commandComponents)-1]) {
+		commandType = name
+		key = commandComponents[1]
+		parsedArguments = [][2]string{
+			{commandComponents[len(commandComponents)-1], ""},
+		}
+		for _, listKey := range commandComponents[1 : len(commandComponents)-1] {
+			parsedArguments = append(parsedArguments, [2]string{listKey, ""})
+		}
+		return
+	}
+	if name == "BLMOVE" && len(commandComponents) == 6 &&
+		isListEnd(commandComponents[3]) && isListEnd(commandComponents[4]) && isTimeout(commandComponents[5]) {
+		commandType = name
+		key = commandComponents[1]
+		parsedArguments = [][2]string{
+			{commandComponents[2], commandComponents[5]},
+			{commandComponents[3], commandComponents[4]},
+		}
+		return
+	}
+	return
+}
@@ -0,0 +1,37 @@
+package main
+
+// Parses PUBLISH channel message, SPUBLISH shardchannel message, PUBSUB subcommand [argument ...]
+// and the (un)subscribe commands. For PUBSUB the key is the subcommand.
+func parsePubSubCommand(commandComponents []string) (commandType string, key string, parsedArguments [][2]string) {
+	name := commandComponents[0]
+	switch {
+	case (name == "PUBLISH" || name == "SPUBLISH") && len(commandComponents) == 3:
+		return name, commandComponents[1], [][2]string{{commandComponents[2], ""}}
+	case name == "PUBSUB" && len(commandComponents) >= 2:
+		subcommand := commandComponents[1]
+		switch subcommand {
+		case "CHANNELS", "SHARDCHANNELS":
+			if len(commandComponents) > 3 {
+				return
+			}
+		case "NUMPAT":
+			if len(commandComponents) > 2 {
+				return
+			}
+		case "NUMSUB", "SHARDNUMSUB":
+		default:
+			return
+		}
+		for _, argument := range commandComponents[2:] {
+			parsedArguments = append(parsedArguments, [2]string{argument, ""})
+		}
+		return name, subcommand, parsedArguments
+	case (name == "SUBSCRIBE" || name == "PSUBSCRIBE" || name == "SSUBSCRIBE") && len(commandComponents) >= 2,
+		name == "UNSUBSCRIBE" || name == "PUNSUBSCRIBE" || name == "SUNSUBSCRIBE":
+		for _, argument := range commandComponents[1:] {
+			parsedArguments = append(parsedArguments, [2]string{argument, ""})
+		}
+		return name, "", parsedArguments
+	}
+	return
+}
@@ -0,0 +1,27 @@
+package main
+
+// Parses EVAL script numkeys [key ...] [arg ...], EVALSHA sha1 numkeys [key ...] [arg ...],
+// SCRIPT LOAD script, SCRIPT EXISTS sha1 [sha1 ...] and SCRIPT FLUSH [ASYNC|SYNC].
+// For EVAL the key is the script, for EVALSHA its SHA1 and for SCRIPT the subcommand.
+func parseScriptCommand(commandComponents []string) (commandType string, key string, parsedArguments [][2]string) {
+	name := commandComponents[0]
+	switch {
+	case (name == "EVAL" || name == "EVALSHA") && len(commandComponents) >= 3:
+	case name == "SCRIPT" && len(commandComponents) >= 2:
+		switch subcommand := commandComponents[1]; {
+		case subcommand == "LOAD" && len(commandComponents) == 3:
+		case subcommand == "EXISTS" && len(commandComponents) >= 3:
+		case subcommand == "FLUSH" && len(commandComponents) == 2:
+		case subcommand == "FLUSH" && len(commandComponents) == 3 &&
+			(commandComponents[2] == "ASYNC" || commandComponents[2] == "SYNC"):
+		default:
+			return
+		}
+	default:
+		return
+	}
+	for _, argument := range commandComponents[2:] {
+		parsedArguments = append(parsedArguments, [2]string{argument, ""})
+	}
+	return name, commandComponents[1], parsedArguments
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Parses commands working on unordered sets
+func parseSetCommand(commandComponents []string) (commandType string, key string, parsedArguments [][2]string) {
+	name := commandComponents[0]
+	if (name == "SADD" || name == "SREM" || name == "SMISMEMBER") && len(commandComponents) >= 3 {
+		commandType = name
+		key = commandComponents[1]
+		for _, member := range commandComponents[2:] {
+			parsedArguments = append(parsedArguments, [2]string{member, ""})
+		}
+		return
+	}
+	if name == "SISMEMBER" && len(commandComponents) == 3 {
+		commandType = name
+		key = commandComponents[1]
+		parsedArguments = [][2]string{
+			{commandComponents[2], ""},
+		}
+		return
+	}
+	if (name == "SMEMBERS" || name == "SCARD") && len(commandComponents) == 2 {
+		commandType = name
+		key = commandComponents[1]
+		return
+	}
+	if (name == "SPOP" || name == "SRANDMEMBER") && (len(commandComponents) == 2 || len(commandComponents) == 3) {
+		if len(commandComponents) == 3 {
+			count, err := strconv.ParseInt(commandComponents[2], 10, 64)
+			if err != nil || (name == "SPOP" && count < 0) {
+				return
+			}
+			parsedArguments = [][2]string{
+				{commandComponents[2], ""},
+			}
+		}
+		commandType = name
+		key = commandComponents[1]
+		return
+	}
+	if name == "SMOVE" && len(commandComponents) == 4 {
+		commandType = name
+		key = commandComponents[1]
+		parsedArguments = [][2]string{
+			{commandComponents[2], commandComponents[3]},
+		}
+		return
+	}
+	// key is the first set, or the destination of the STORE variants. args are the sets combined.
+	if ((name == "SINTER" || name == "SUNION" || name == "SDIFF") && len(commandComponents) >= 2) ||
+		((name == "SINTERSTORE" || name == "SUNIONSTORE" || name == "SDIFFSTORE") && len(commandComponents) >= 3) {
+		commandType = name
+		key = commandComponents[1]
+		sets := commandComponents[1:]
+		if strings.HasSuffix(name, "STORE") {
+			sets = commandComponents[2:]
+		}
+		for _, set := range sets {
+			parsedArguments = append(parsedArguments, [2]string{set, ""})
+		}
+		return
+	}
+	if name == "SINTERCARD" && len(commandComponents) >= 3 {
+		numKeys, err := strconv.Atoi(commandComponents[1])
+		if err != nil || numKeys < 1 || numKeys > len(commandComponents)-2 {
+			return
+		}
+		limit := "0"
+		rest := commandComponents[2+numKeys:]
+		if len(rest) != 0 {
+			if len(rest) != 2 || rest[0] != "LIMIT" {
+				return
+			}
+			if value, err := strconv.Atoi(rest[1]); err != nil || value < 0 {
+				return
+			}
+			limit = rest[1]
+		}
+		commandType = name
+		key = commandComponents[2]
+		parsedArguments = [][2]string{
+			{limit, ""},
+		}
+		for _, set := range commandComponents[2 : 2+numKeys] {
+			parsedArguments = append(parsedArguments, [2]string{set, ""})
+		}
+		return
+	}
+	if name == "SSCAN" && len(commandComponents) >= 3 {
+		scanArguments, ok := parseScanOptions(commandComponents[2:], false)
+		if !ok {
+			return
+		}
+		commandType = name
+		key = commandComponents[1]
+		parsedArguments = scanArguments
+		return
+	}
+	return
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"github.com/thedeveloperr/redis-clone/streamMap"
+	"strconv"
+)
+
+func isStreamID(text string) bool {
+	_, ok := streamMap.ParseID(text, 0)
+	return ok
+}
+
+func isNonNegativeInteger(text string) bool {
+	value, err := strconv.ParseInt(text, 10, 64)
+	return err == nil && value >= 0
+}
+
+// Parses "MAXLEN|MINID [=|~] threshold [LIMIT count]" starting at components[i]
+// into {strategy, threshold} and {LIMIT, count}. next is the index after it.
+func parseStreamTrim(components []string, i int) (parsedArguments [][2]string, next int, ok bool) {
+	strategy := components[i]
+	i++
+	if i < len(components) && (components[i] == "=" || components[i] == "~") {
+		i++
+	}
+	if i >= len(components) {
+		return nil, i, false
+	}
+	if (strategy == "MAXLEN" && !isNonNegativeInteger(components[i])) ||
+		(strategy == "MINID" && !isStreamID(components[i])) {
+		return nil, i, false
+	}
+	parsedArguments = [][2]string{
+		{strategy, components[i]},
+	}
+	i++
+	if i+1 < len(components) && components[i] == "LIMIT" {
+		if !isNonNegativeInteger(components[i+1]) {
+			return nil, i, false
+		}
+		parsedArguments = append(parsedArguments, [2]string{"LIMIT", components[i+1]})
+		i += 2
+	}
+	return parsedArguments, i, true
+}
+
+// Parses "[COUNT count] [BLOCK milliseconds] [NOACK] STREAMS key [key ...] id [id ...]"
+// into {count, block}, {NOACK or "", ""} and a {key, id} pair per stream. isValidID
+// checks the IDs, which differ between XREAD and XREADGROUP.
+func parseStreamRead(components []string, allowNoAck bool, isValidID func(string) bool) (parsedArguments [][2]string, ok bool) {
+	count, block, noAck := "", "", ""
+	i := 0
+	for ; i < len(components) && components[i] != "STREAMS"; i++ {
+		switch {
+		case components[i] == "COUNT" && i+1 < len(components) && isNonNegativeInteger(components[i+1]):
+			count = components[i+1]
+			i++
+		case components[i] == "BLOCK" && i+1 < len(components) && isNonNegativeInteger(components[i+1]):
+			block = components[i+1]
+			i++
+		case components[i] == "NOACK" && allowNoAck:
+			noAck = "NOACK"
+		default:
+			return nil, false
+		}
+	}
+	streams := components[i:]
+	if len(streams) < 3 || len(streams)%2 == 0 {
+		return nil, false
+	}
+	streams = streams[1:]
+	parsedArguments = [][2]string{
+		{count, block},
+		{noAck, ""},
+	}
+	half := len(streams) / 2
+	for j := 0; j < half; j++ {
+		if !isValidID(streams[half+j]) {
+			return nil, false
+		}
+		parsedArguments = append(parsedArguments, [2]string{streams[j], streams[half+j]})
+	}
+	return parsedArguments, true
+}
+
+// Parses commands working on streams
+func parseStreamCommand(commandComponents []string) (commandType string, key string, parsedArguments [][2]string) {
+	name := commandComponents[0]
+	if name == "XADD" && len(commandComponents) >= 5 {
+		i := 2
+		for i < len(commandComponents) {
+			if commandComponents[i] == "NOMKSTREAM" {
+				parsedArguments = append(parsedArguments, [2]string{"NOMKSTREAM", ""})
+				i++
+				continue
+			}
+			if commandComponents[i] == "MAXLEN" || commandComponents[i] == "MINID" {
+				trimArguments, next, ok := parseStreamTrim(commandComponents, i)
+				if !ok {
+					return "", "", nil
+				}
+				parsedArguments = append(parsedArguments, trimArguments...)
+				i = next
+				continue
+			}
+			break
+		}
+		fields := len(commandComponents) - i - 1
+		if fields < 2 || fields%2 != 0 {
+			return "", "", nil
+		}
+		if _, ok := streamMap.ParseAddID(commandComponents[i]); !ok {
+			return "", "", nil
+		}
+		parsedArguments = append(parsedArguments, [2]string{"ID", commandComponents[i]})
+		for j := i + 1; j < len(commandComponents); j = j + 2 {
+			parsedArguments = append(parsedArguments, [2]string{commandComponents[j], commandComponents[j+1]})
+		}
+		commandType = name
+		key = commandComponents[1]
+		return
+	}
+	if name == "XTRIM" && len(commandComponents) >= 4 &&
+		(commandComponents[2] == "MAXLEN" || commandComponents[2] == "MINID") {
+		trimArguments, next, ok := parseStreamTrim(commandComponents, 2)
+		if !ok || next != len(commandComponents) {
+			return
+		}
+		commandType = name
+		key = commandComponents[1]
+		parsedArguments = trimArguments
+		return
+	}
+	if (name == "XRANGE" || name == "XREVRANGE") && (len(commandComponents) == 4 || len(commandComponents) == 6) {
+		isFirstStart := name == "XRANGE"
+		if _, ok := streamMap.ParseRangeBound(commandComponents[2], isFirstStart); !ok {
+			return
+		}
+		if _, ok := streamMap.ParseRangeBound(commandComponents[3], !isFirstStart); !ok {
+			return
+		}
+		parsedArguments = [][2]string{
+			{commandComponents[2], commandComponents[3]},
+		}
+		if len(commandComponents) == 6 {
+			if commandComponents[4] != "COUNT" || !isNonNegativeInteger(commandComponents[5]) {
+				return "", "", nil
+			}
+			parsedArguments = append(parsedArguments, [2]string{"COUNT", commandComponents[5]})
+		}
+		commandType = name
+		key = commandComponents[1]
+		return
+	}
+	if name == "XLEN" && len(commandComponents) == 2 {
+		commandType = name
+		key = commandComponents[1]
+		return
+	}
+	if name == "XDEL" && len(commandComponents) >= 3 {
+		for _, id := range commandComponents[2:] {
+			if !isStreamID(id) {
+				return "", "", nil
+			}
+			parsedArguments = append(parsedArguments, [2]string{id, ""})
+		}
+		commandType = name
+		key = commandComponents[1]
+		return
+	}
+	if name == "XREAD" && len(commandComponents) >= 4 {
+		readArguments, ok := parseStreamRead(commandComponents[1:], false, func(id string) bool {
+			return id == "$" || isStreamID(id)
+		})
+		if !ok {
+			return
+		}
+		commandType = name
+		key = readArguments[2][0]
+		parsedArguments = readArguments
+		return
+	}
+	if name == "XREADGROUP" && len(commandComponents) >= 7 && commandComponents[1] == "GROUP" {
+		readArguments, ok := parseStreamRead(commandComponents[4:], true, func(id string) bool {
+			return id == ">" || isStreamID(id)
+		})
+		if !ok {
+			return
+		}
+		commandType = name
+		key = readArguments[2][0]
+		parsedArguments = append([][2]string{{commandComponents[2], commandComponents[3]}}, readArguments...)
+		return
+	}
+	if name == "XGROUP" && len(commandComponents) >= 4 {
+		subcommand := commandComponents[1]
+		switch {
+		case subcommand == "CREATE" && (len(commandComponents) == 5 ||
+			(len(commandComponents) == 6 && commandComponents[5] == "MKSTREAM")):
+			mkStream := ""
+			if len(commandComponents) == 6 {
+				mkStream = "MKSTREAM"
+			}
+			parsedArguments = [][2]string{{subcommand, commandComponents[3]}, {commandComponents[4], mkStream}}
+		case subcommand == "SETID" && len(commandComponents) == 5:
+			parsedArguments = [][2]string{{subcommand, commandComponents[3]}, {commandComponents[4], ""}}
+		case subcommand == "DESTROY" && len(commandComponents) == 4:
+			parsedArguments = [][2]string{{subcommand, commandComponents[3]}}
+		case (subcommand == "CREATECONSUMER" || subcommand == "DELCONSUMER") && len(commandComponents) == 5:
+			parsedArguments = [][2]string{{subcommand, commandComponents[3]}, {commandComponents[4], ""}}
+		default:
+			return
+		}
+		if (subcommand == "CREATE" || subcommand == "SETID") &&
+			commandComponents[4] != "$" && !isStreamID(commandComponents[4]) {
+			return "", "", nil
+		}
+		commandType = name
+		key = commandComponents[2]
+		return
+	}
+	if name == "XACK" && len(commandComponents) >= 4 {
+		parsedArguments = [][2]string{
+			{commandComponents[2], ""},
+		}
+		for _, id := range commandComponents[3:] {
+			if !isStreamID(id) {
+				return "", "", nil
+			}
+			parsedArguments = append(parsedArguments, [2]string{id, ""})
+		}
+		commandType = name
+		key = commandComponents[1]
+		return
+	}
+	if name == "XPENDING" && len(commandComponents) >= 3 {
+		parsedArguments = [][2]string{
+			{commandComponents[2], ""},
+		}
+		rest := commandComponents[3:]
+		if len(rest) > 0 {
+			idle := "0"
+			if rest[0] == "IDLE" {
+				if len(rest) < 2 || !isNonNegativeInteger(rest[1]) {
+					return "", "", nil
+				}
+				idle = rest[1]
+				rest = rest[2:]
+			}
+			if len(rest) != 3 && len(rest) != 4 {
+				return "", "", nil
+			}
+			_, startOk := streamMap.ParseRangeBound(rest[0], true)
+			_, endOk := streamMap.ParseRangeBound(rest[1], false)
+			if !startOk || !endOk || !isNonNegativeInteger(rest[2]) {
+				return "", "", nil
+			}
+			consumer := ""
+			if len(rest) == 4 {
+				consumer = rest[3]
+			}
+			parsedArguments = append(parsedArguments,
+				[2]string{rest[0], rest[1]},
+				[2]string{rest[2], consumer},
+				[2]string{idle, ""},
+			)
+		}
+		commandType = name
+		key = commandComponents[1]
+		return
+	}
+	if name == "XCLAIM" && len(commandComponents) >= 6 && isNonNegativeInteger(commandComponents[4]) {
+		i := 5
+		var ids [][2]string
+		for ; i < len(commandComponents) && isStreamID(commandComponents[i]); i++ {
+			ids = append(ids, [2]string{commandComponents[i], ""})
+		}
+		if len(ids) == 0 {
+			return
+		}
+		parsedArguments = append([][2]string{
+			{commandComponents[2], commandComponents[3]},
+			{commandComponents[4], strconv.Itoa(len(ids))},
+		}, ids...)
+		for ; i < len(commandComponents); i++ {
+			option := commandComponents[i]
+			switch option {
+			case "IDLE", "TIME", "RETRYCOUNT", "LASTID":
+				if i+1 >= len(commandComponents) {
+					return "", "", nil
+				}
+				value := commandComponents[i+1]
+				if (option == "LASTID" && !isStreamID(value)) || (option != "LASTID" && !isNonNegativeInteger(value)) {
+					return "", "", nil
+				}
+				parsedArguments = append(parsedArguments, [2]string{option, value})
+				i++
+			case "FORCE", "JUSTID":
+				parsedArguments = append(parsedArguments, [2]string{option, ""})
+			default:
+				return "", "", nil
+			}
+		}
+		commandType = name
+		key = commandComponents[1]
+		return
+	}
+	if name == "XAUTOCLAIM" && len(commandComponents) >= 6 && isNonNegativeInteger(commandComponents[4]) {
+		if _, ok := streamMap.ParseRangeBound(commandComponents[5], true); !ok {
+			return
+		}
+		count, justID := "100", ""
+		for i := 6; i < len(commandComponents); i++ {
+			switch {
+			case commandComponents[i] == "COUNT" && i+1 < len(commandComponents):
+				if value, err := strconv.Atoi(commandComponents[i+1]); err != nil || value < 1 {
+					return "", "", nil
+				}
+				count = commandComponents[i+1]
+				i++
+			case commandComponents[i] == "JUSTID":
+				justID = "JUSTID"
+			default:
+				return "", "", nil
+			}
+		}
+		commandType = name
+		key = commandComponents[1]
+		parsedArguments = [][2]string{
+			{commandComponents[2], commandComponents[3]},
+			{commandComponents[4], commandComponents[5]},
+			{count, justID},
+		}
+		return
+	}
+	return
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"math"
+	"strconv"
+)
+
+func isInteger(text string) bool {
+	_, err := strconv.ParseInt(text, 10, 64)
+	return err == nil
+}
+
+func isFloat(text string) bool {
+	f, err := strconv.ParseFloat(text, 64)
+	return err == nil && !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+// Parses commands working on string values stored in the hashmap
+func parseStringCommand(commandComponents []string) (commandType string, key string, parsedArguments [][2]string) {
+	name := commandComponents[0]
+	if (name == "INCR" || name == "DECR" || name == "STRLEN" || name == "GETDEL") &&
+		len(commandComponents) == 2 {
+		commandType = name
+		key = commandComponents[1]
+		return
+	}
+	if (name == "INCRBY" || name == "DECRBY") && len(commandComponents) == 3 {
+		if !isInteger(commandComponents[2]) {
+			return
+		}
+		commandType = name
+		key = commandComponents[1]
+		parsedArguments = [][2]string{
+			{commandComponents[2], ""},
+		}
+		return
+	}
+	if name == "INCRBYFLOAT" && len(commandComponents) == 3 {
+		if !isFloat(commandComponents[2]) {
+			return
+		}
+		commandType = name
+		key = commandComponents[1]
+		parsedArguments = [][2]string{
+			{commandComponents[2], ""},
+		}
+		return
+	}
+	if (name == "APPEND" || name == "GETSET") && len(commandComponents) == 3 {
+		commandType = name
+		key = commandComponents[1]
+		parsedArguments = [][2]string{
+			{commandComponents[2], ""},
+		}
+		return
+	}
+	if name == "GETRANGE" && len(commandComponents) == 4 {
+		if !isInteger(commandComponents[2]) || !isInteger(commandComponents[3]) {
+			return
+		}
+		commandType = name
+		key = commandComponents[1]
+		parsedArguments = [][2]string{
+			{commandComponents[2], commandComponents[3]},
+		}
+		return
+	}
+	if name == "SETRANGE" && len(commandComponents) == 4 {
+		if offset, err := strconv.ParseInt(commandComponents[2], 10, 64); err != nil || offset < 0 {
+			return
+		}
+		commandType = name
+		key = commandComponents[1]
+		parsedArguments = [][2]string{
+			{commandComponents[2], commandComponents[3]},
+		}
+		return
+	}
+	if name == "MGET" && len(commandComponents) >= 2 {
+		commandType = name
+		key = commandComponents[1]
+		for _, k := range commandComponents[1:] {
+			parsedArguments = append(parsedArguments, [2]string{k, ""})
+		}
+		return
+	}
+	if (name == "MSET" || name == "MSETNX") && len(commandComponents) >= 3 &&
+		len(commandComponents)%2 == 1 {
+		commandType = name
+		key = commandComponents[1]
+		for i := 1; i < len(commandComponents); i = i + 2 {
+			parsedArguments = append(parsedArguments, [2]string{
+				commandComponents[i],
+				commandComponents[i+1],
+			})
+		}
+		return
+	}
+	if name == "GETEX" && len(commandComponents) >= 2 && len(commandComponents) <= 4 {
+		if len(commandComponents) == 3 && commandComponents[2] != "PERSIST" {
+			return
+		}
+		if len(commandComponents) == 4 {
+			option := commandComponents[2]
+			if option != "EX" && option != "PX" && option != "EXAT" && option != "PXAT" {
+				return
+			}
+			if !isInteger(commandComponents[3]) {
+				return
+			}
+			parsedArguments = [][2]string{
+				{option, commandComponents[3]},
+			}
+		}
+		if len(commandComponents) == 3 {
+			parsedArguments = [][2]string{
+				{"PERSIST", ""},
+			}
+		}
+		commandType = name
+		key = commandComponents[1]
+		return
+	}
+	return
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	cases := []struct {
+		line     string
+		expected []string
+	}{
+		{"SET k1 v1", []string{"SET", "k1", "v1"}},
+		{"  SET   k1\tv1  ", []string{"SET", "k1", "v1"}},
+		{`SET k1 "hello world"`, []string{"SET", "k1", "hello world"}},
+		{`SET k1 "\x00\xff\n\"q\""`, []string{"SET", "k1", "\x00\xff\n\"q\""}},
+		{`SET k1 'it\'s'`, []string{"SET", "k1", "it's"}},
+		{`SET k1 ""`, []string{"SET", "k1", ""}},
+	}
+	for _, c := range cases {
+		args, ok := splitArgs(c.line)
+		if !ok || !reflect.DeepEqual(args, c.expected) {
+			t.Errorf("splitArgs(%q) expected %q but got %q (ok %v)", c.line, c.expected, args, ok)
+		}
+	}
+	for _, line := range []string{`SET k1 "unbalanced`, `SET k1 'unbalanced`, `SET k1 "a"b`} {
+		if _, ok := splitArgs(line); ok {
+			t.Errorf("splitArgs(%q) should fail", line)
+		}
+	}
+}
+
+func TestFormatCommandRoundTrip(t *testing.T) {
+	args := []string{"SET", "key with space", "\x00\x01binary\xff", "", `"quoted"`, "back\\slash", "plain"}
+	line := formatCommand(args...)
+	parsed, ok := splitArgs(line)
+	if !ok || !reflect.DeepEqual(parsed, args) {
+		t.Errorf("Expected %q after round trip of %q but got %q", args, line, parsed)
+	}
+	if line := formatCommand("PERSIST", "k1"); line != "PERSIST k1" {
+		t.Errorf("Expected: PERSIST k1 Got result:" + line)
+	}
+}
+
+func TestCommandKeys(t *testing.T) {
+	cases := []struct {
+		line     string
+		expected []string
+	}{
+		{"SET k v", []string{"k"}},
+		{"MSET a 1 b 2", []string{"a", "b"}},
+		{"LMOVE src dst LEFT RIGHT", []string{"src", "dst"}},
+		{"BLPOP a b 0", []string{"a", "b"}},
+		{"BITOP AND dst a b", []string{"dst", "a", "b"}},
+		{"SINTERSTORE dst a b", []string{"dst", "a", "b"}},
+		{"XGROUP CREATE s g $", []string{"s"}},
+		{"XREADGROUP GROUP STREAMS c COUNT 1 STREAMS s1 s2 > >", []string{"s1", "s2"}},
+		{"SINTERCARD 2 a b LIMIT 1", []string{"a", "b"}},
+		{"XREAD COUNT 1 STREAMS s1 s2 0 0", []string{"s1", "s2"}},
+		{"FUNCTION FLUSH", nil},
+	}
+	for _, c := range cases {
+		args, _ := splitArgs(c.line)
+		if keys := commandKeys(args); !reflect.DeepEqual(keys, c.expected) {
+			t.Errorf("commandKeys(%q) expected %q but got %q", c.line, c.expected, keys)
+		}
+	}
+}
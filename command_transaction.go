@@ -0,0 +1,16 @@
+package main
+
+// Parses MULTI, EXEC, DISCARD, WATCH key [key ...] and UNWATCH
+func parseTransactionCommand(commandComponents []string) (commandType string, key string, parsedArguments [][2]string) {
+	name := commandComponents[0]
+	switch {
+	case (name == "MULTI" || name == "EXEC" || name == "DISCARD" || name == "UNWATCH") && len(commandComponents) == 1:
+		return name, "", nil
+	case name == "WATCH" && len(commandComponents) >= 2:
+		for _, argument := range commandComponents[2:] {
+			parsedArguments = append(parsedArguments, [2]string{argument, ""})
+		}
+		return name, commandComponents[1], parsedArguments
+	}
+	return
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Settings of the server, read from a redis.conf style file and the command
+// line when it starts. They are described in configParameters.
+type Config struct {
+	Bind                 string
+	Port                 int
+	HTTPPort             int
+	Dir                  string
+	AppendOnly           bool
+	AppendFilename       string
+	AppendFsync          string
+	MaxMemory            int64
+	MaxMemoryPolicy      string
+	LogLevel             string
+	LogFile              string
+	NotifyKeyspaceEvents string
+	LuaTimeLimit         int64
+	// encoding limits of sorted sets and HyperLogLogs
+	ZsetMaxListpackEntries int64
+	ZsetMaxListpackValue   int64
+	HLLSparseMaxBytes      int64
+	// absolute path of the file the settings were read from, which CONFIG
+	// REWRITE writes back to. Empty without a config file.
+	filename string
+}
+
+// First line of the settings CONFIG REWRITE adds at the end of the file
+const CONFIG_REWRITE_SIGNATURE = "# Generated by CONFIG REWRITE"
+
+// Settings used for what the file and the command line leave out
+func DefaultConfig() Config {
+	return Config{
+		Bind:            "*",
+		Port:            6379,
+		HTTPPort:        8080,
+		Dir:             ".",
+		AppendOnly:      true,
+		AppendFilename:  "AOF.log",
+		AppendFsync:     "everysec",
+		MaxMemoryPolicy: "noeviction",
+		LogLevel:        "notice",
+		LuaTimeLimit:    5000,
+		// same defaults as redis
+		ZsetMaxListpackEntries: 128,
+		ZsetMaxListpackValue:   64,
+		HLLSparseMaxBytes:      3000,
+	}
+}
+
+// Reads the settings from the command line of the server, which like for
+// redis-server is an optional config file followed by settings given as
+// --name value, eg. "redis.conf --port 6380 --appendonly no". Settings of
+// the command line override those of the file.
+func LoadConfig(arguments []string) (Config, error) {
+	config := DefaultConfig()
+	if len(arguments) > 0 && !strings.HasPrefix(arguments[0], "--") {
+		filename, err := filepath.Abs(arguments[0])
+		if err != nil {
+			return config, err
+		}
+		config.filename = filename
+		if err := readConfigFile(&config, filename); err != nil {
+			return config, err
+		}
+		arguments = arguments[1:]
+	}
+	// the values of a setting are the arguments up to the next --name
+	var directive []string
+	for _, argument := range append(arguments, "--") {
+		if !strings.HasPrefix(argument, "--") {
+			if directive == nil {
+				return config, fmt.Errorf("'%s' should follow a --name of setting", argument)
+			}
+			directive = append(directive, argument)
+			continue
+		}
+		if directive != nil {
+			if err := setConfigDirective(&config, directive); err != nil {
+				return config, fmt.Errorf("--%s: %v", directive[0], err)
+			}
+		}
+		directive = []string{strings.TrimPrefix(argument, "--")}
+	}
+	return config, nil
+}
+
+// Reads a config file made of lines of a setting's name and its value.
+// Blank lines and lines starting with # are ignored.
+func readConfigFile(config *Config, filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		args, ok := splitArgs(line)
+		if !ok {
+			return fmt.Errorf("%s, line %d: unbalanced quotes in '%s'", filename, number, line)
+		}
+		if err := setConfigDirective(config, args); err != nil {
+			return fmt.Errorf("%s, line %d: %v in '%s'", filename, number, err, line)
+		}
+	}
+	return scanner.Err()
+}
+
+// Sets the setting named by the first argument to the others, joined by
+// spaces for settings like bind which take several
+func setConfigDirective(config *Config, args []string) error {
+	name := strings.ToLower(args[0])
+	parameter, exists := configParameters[name]
+	if !exists || len(args) < 2 {
+		return errors.New("Bad directive or wrong number of arguments")
+	}
+	value := strings.Join(args[1:], " ")
+	if !parameter.set(config, value) {
+		return fmt.Errorf("Invalid argument '%s' for '%s'", value, name)
+	}
+	return nil
+}
+
+// Names of the settings in alphabetical order
+func configParameterNames() []string {
+	names := make([]string, 0, len(configParameters))
+	for name := range configParameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Line of a config file setting name to its value in config
+func formatConfigLine(config *Config, name string) string {
+	parameter := configParameters[name]
+	value := parameter.get(config)
+	if parameter.list && value != "" {
+		return formatCommand(append([]string{name}, strings.Fields(value)...)...)
+	}
+	return formatCommand(name, value)
+}
+
+// Updates the file config was read from with its settings like redis' CONFIG
+// REWRITE. The first line of each setting in the file gets its value and
+// the following ones are removed. Settings which aren't in the file but
+// differ from their default are added at the end, after
+// CONFIG_REWRITE_SIGNATURE unless it's already there. Comments and blank
+// lines are kept.
+func rewriteConfigFile(config *Config) error {
+	content, err := ioutil.ReadFile(config.filename)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var lines []string
+	written := map[string]bool{}
+	signed := false
+	for _, line := range strings.Split(strings.TrimRight(string(content), "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		signed = signed || trimmed == CONFIG_REWRITE_SIGNATURE
+		args, ok := splitArgs(trimmed)
+		if trimmed == "" || trimmed[0] == '#' || !ok || len(args) == 0 {
+			lines = append(lines, line)
+			continue
+		}
+		name := strings.ToLower(args[0])
+		if _, exists := configParameters[name]; !exists {
+			lines = append(lines, line)
+			continue
+		}
+		if !written[name] {
+			lines = append(lines, formatConfigLine(config, name))
+			written[name] = true
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	defaults := DefaultConfig()
+	var generated []string
+	for _, name := range configParameterNames() {
+		parameter := configParameters[name]
+		if !written[name] && parameter.get(config) != parameter.get(&defaults) {
+			generated = append(generated, formatConfigLine(config, name))
+		}
+	}
+	if len(generated) > 0 && !signed {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, CONFIG_REWRITE_SIGNATURE)
+	}
+	lines = append(lines, generated...)
+	return replaceFile(config.filename, []byte(strings.Join(lines, "\n")+"\n"))
+}
+
+// Writes content to a temporary file renamed to filename, so filename is
+// never left half written
+func replaceFile(filename string, content []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(filename); err == nil {
+		mode = info.Mode()
+	}
+	file, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(content)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), mode)
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
+
+// Parses a number of bytes with an optional unit like in redis.conf, where
+// 1k is 1000 bytes, 1kb 1024 bytes and likewise for m, mb, g and gb
+func parseMemory(text string) (bytes int64, ok bool) {
+	text = strings.ToLower(text)
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30},
+		{"k", 1000}, {"m", 1000 * 1000}, {"g", 1000 * 1000 * 1000}, {"b", 1},
+	} {
+		if strings.HasSuffix(text, unit.suffix) {
+			text, multiplier = strings.TrimSuffix(text, unit.suffix), unit.multiplier
+			break
+		}
+	}
+	number, err := strconv.ParseInt(text, 10, 64)
+	if err != nil || number < 0 || number > (1<<63-1)/multiplier {
+		return 0, false
+	}
+	return number * multiplier, true
+}
+
+// Parses yes or no
+func parseYesNo(text string) (value bool, ok bool) {
+	switch strings.ToLower(text) {
+	case "yes":
+		return true, true
+	case "no":
+		return false, true
+	}
+	return false, false
+}
+
+func formatYesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Writes a config file in a temporary directory, removed by the returned func
+func writeTestConfig(t *testing.T, content string) (filename string, remove func()) {
+	directory, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename = filepath.Join(directory, "redis.conf")
+	if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename, func() { os.RemoveAll(directory) }
+}
+
+func TestLoadConfig(t *testing.T) {
+	filename, remove := writeTestConfig(t, "# settings\n\nport 7000\nbind 127.0.0.1   ::1\n"+
+		"  APPENDONLY no\nmaxmemory 2mb\nnotify-keyspace-events \"KEA\"\nlogfile \"\"\nhll-sparse-max-bytes 1kb\n")
+	defer remove()
+	config, err := LoadConfig([]string{filename, "--port", "7001", "--loglevel", "warning", "--dir", "/tmp", "--zset-max-listpack-value", "32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := DefaultConfig()
+	expected.Port = 7001
+	expected.Bind = "127.0.0.1 ::1"
+	expected.AppendOnly = false
+	expected.MaxMemory = 2 << 20
+	expected.NotifyKeyspaceEvents = "AKE"
+	expected.LogLevel = "warning"
+	expected.Dir = "/tmp"
+	expected.HLLSparseMaxBytes = 1024
+	expected.ZsetMaxListpackValue = 32
+	expected.filename = filename
+	if config != expected {
+		t.Errorf("Expected %+v but got %+v", expected, config)
+	}
+
+	if config, err := LoadConfig(nil); err != nil || config != DefaultConfig() {
+		t.Errorf("Expected the default settings but got %+v %v", config, err)
+	}
+
+	errors := map[string][]string{
+		"line 2: Bad directive":              {"port 7000\nnope 1\n"},
+		"line 1: Bad directive":              {"port\n"},
+		"line 1: Invalid argument 'x' for":   {"lua-time-limit x\n"},
+		"line 3: unbalanced quotes":          {"\n\ndir \"/tmp\n"},
+		"--port: Invalid argument '70000'":   {"", "--port", "70000"},
+		"--port: Bad directive":              {"", "--port"},
+		"'6380' should follow a --name":      {"", "6380"},
+		"--maxmemory: Invalid argument '1x'": {"", "--maxmemory", "1x"},
+	}
+	for message, arguments := range errors {
+		filename, remove := writeTestConfig(t, arguments[0])
+		_, err := LoadConfig(append([]string{filename}, arguments[1:]...))
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected an error with %q but got %v", message, err)
+		}
+		remove()
+	}
+}
+
+func TestParseMemory(t *testing.T) {
+	sizes := map[string]int64{"0": 0, "100": 100, "1k": 1000, "1KB": 1024, "3m": 3000000, "3mb": 3 << 20, "2gb": 2 << 30, "5b": 5}
+	for text, expected := range sizes {
+		if bytes, ok := parseMemory(text); !ok || bytes != expected {
+			t.Errorf("Expected %d for %q but got %d %v", expected, text, bytes, ok)
+		}
+	}
+	for _, text := range []string{"", "-1", "1tb", "mb", "99999999999gb"} {
+		if _, ok := parseMemory(text); ok {
+			t.Errorf("Expected %q to be rejected", text)
+		}
+	}
+}
+
+func TestConfigRewrite(t *testing.T) {
+	filename, remove := writeTestConfig(t, "# the port\nport 7000\nport 7002\n\nappendonly no\n\n")
+	defer remove()
+	config, err := LoadConfig([]string{filename, "--port", "7001", "--bind", "127.0.0.1", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := CreateInMemStoreWithConfig(config)
+	defer db.ProcessCommand("CONFIG SET zset-max-listpack-entries 128")
+	if result := db.ProcessCommand("CONFIG SET lua-time-limit 100 notify-keyspace-events \"\" zset-max-listpack-entries 16"); result != "OK" {
+		t.Fatalf("Expected OK but got %v", result)
+	}
+	expected := "# the port\nport 7001\n\nappendonly no\n\n" + CONFIG_REWRITE_SIGNATURE + "\n" +
+		"bind 127.0.0.1 ::1\nlua-time-limit 100\nzset-max-listpack-entries 16\n"
+	for i := 0; i < 2; i++ {
+		if result := db.ProcessCommand("CONFIG REWRITE"); result != "OK" {
+			t.Fatalf("Expected OK but got %v", result)
+		}
+		content, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != expected {
+			t.Errorf("Expected the config file %q but got %q", expected, content)
+		}
+	}
+	reloaded, err := LoadConfig([]string{filename})
+	if err != nil || reloaded != db.config {
+		t.Errorf("Expected the rewritten file to give %+v but got %+v %v", db.config, reloaded, err)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package main
+
+import (
+	"time"
+)
+
+// CPU time isn't measured without getrusage, INFO cpu shows 0
+func cpuTime() (system time.Duration, user time.Duration) {
+	return 0, 0
+}
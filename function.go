@@ -0,0 +1,281 @@
+package main
+
+import (
+	"github.com/thedeveloperr/redis-clone/lua"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Name of libraries in the positions of their error messages
+const FUNCTION_CHUNK_NAME = "user_function"
+
+// How long the code of a library can run while it registers its functions
+const FUNCTION_LOAD_TIME_LIMIT = 500 * time.Millisecond
+
+// Flags a function can be registered with. no-writes makes it read only,
+// the others only matter to redis clusters and replicas and are kept for
+// FUNCTION LIST.
+var functionFlags = map[string]bool{
+	"no-writes": true, "allow-oom": true, "allow-stale": true, "no-cluster": true, "allow-cross-slot-keys": true,
+}
+
+// Library loaded by FUNCTION LOAD: its code, kept to be listed, dumped and
+// logged, and the functions it registered
+type functionLibrary struct {
+	name      string
+	code      string
+	functions map[string]*libraryFunction
+}
+
+type libraryFunction struct {
+	name        string
+	description string
+	callback    lua.Value
+	flags       []string
+}
+
+func (function *libraryFunction) readOnly() bool {
+	for _, flag := range function.flags {
+		if flag == "no-writes" {
+			return true
+		}
+	}
+	return false
+}
+
+// Libraries by name and their functions by name, function names being unique
+// across libraries
+type functionRegistry struct {
+	mutex     sync.RWMutex
+	libraries map[string]*functionLibrary
+	functions map[string]*libraryFunction
+}
+
+func createFunctionRegistry() *functionRegistry {
+	return &functionRegistry{
+		libraries: make(map[string]*functionLibrary),
+		functions: make(map[string]*libraryFunction),
+	}
+}
+
+func (r *functionRegistry) function(name string) *libraryFunction {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.functions[name]
+}
+
+// Adds libraries, all of them or none. Libraries with the name of an
+// existing one replace it if replace is set, clear removes the existing
+// libraries first. Returns an error reply for conflicting names.
+func (r *functionRegistry) add(libraries []*functionLibrary, replace bool, clear bool) string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	merged := make(map[string]*functionLibrary)
+	if !clear {
+		for name, library := range r.libraries {
+			merged[name] = library
+		}
+	}
+	for _, library := range libraries {
+		if _, exists := merged[library.name]; exists && !replace {
+			return "ERR Library '" + library.name + "' already exists"
+		}
+		merged[library.name] = library
+	}
+	functions := make(map[string]*libraryFunction)
+	for _, library := range merged {
+		for name, function := range library.functions {
+			if _, exists := functions[name]; exists {
+				return "ERR Function " + name + " already exists"
+			}
+			functions[name] = function
+		}
+	}
+	r.libraries, r.functions = merged, functions
+	return ""
+}
+
+// Removes a library and its functions, false if there is none
+func (r *functionRegistry) remove(name string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	library, exists := r.libraries[name]
+	if !exists {
+		return false
+	}
+	delete(r.libraries, name)
+	for functionName := range library.functions {
+		delete(r.functions, functionName)
+	}
+	return true
+}
+
+// Libraries ordered by name
+func (r *functionRegistry) list() []*functionLibrary {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	libraries := make([]*functionLibrary, 0, len(r.libraries))
+	for _, library := range r.libraries {
+		libraries = append(libraries, library)
+	}
+	sort.Slice(libraries, func(i, j int) bool { return libraries[i].name < libraries[j].name })
+	return libraries
+}
+
+func sortedFunctionNames(library *functionLibrary) []string {
+	names := make([]string, 0, len(library.functions))
+	for name := range library.functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Whether name is made of letters, digits and underscores only
+func isFunctionName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// Reads the name of a library from the first line of its code, like
+// #!lua name=mylib
+func libraryName(code string) (name string, errorReply string) {
+	if !strings.HasPrefix(code, "#!") {
+		return "", "ERR Missing library metadata"
+	}
+	shebang := code[2:]
+	if end := strings.IndexByte(shebang, '\n'); end >= 0 {
+		shebang = shebang[:end]
+	}
+	fields := strings.Fields(shebang)
+	if len(fields) == 0 || fields[0] != "lua" {
+		engine := ""
+		if len(fields) > 0 {
+			engine = fields[0]
+		}
+		return "", "ERR Engine '" + engine + "' not found"
+	}
+	for _, field := range fields[1:] {
+		if !strings.HasPrefix(field, "name=") {
+			return "", "ERR Invalid metadata value given: " + field
+		}
+		name = field[len("name="):]
+	}
+	if name == "" {
+		return "", "ERR Library name was not given"
+	}
+	if !isFunctionName(name) {
+		return "", "ERR Library names can only contain letters, numbers, or underscores(_) and must be at least one character long"
+	}
+	return name, ""
+}
+
+// Runs the code of a library, which calls redis.register_function for each
+// of its functions. Only redis.register_function and redis.log are there
+// while it runs: functions can call commands, loading a library can't.
+func loadLibrary(code string) (*functionLibrary, string) {
+	name, errorReply := libraryName(code)
+	if errorReply != "" {
+		return nil, errorReply
+	}
+	// the metadata line isn't Lua, it's blanked so line numbers don't change
+	source := code[strings.IndexByte(code+"\n", '\n'):]
+	chunk, err := lua.Compile(FUNCTION_CHUNK_NAME, source)
+	if err != nil {
+		return nil, "ERR Error compiling function: " + err.Error()
+	}
+	library := &functionLibrary{name: name, code: code, functions: make(map[string]*libraryFunction)}
+	redis := lua.NewTable()
+	redis.Set("register_function", &lua.GoFunction{Name: "register_function", Fn: func(state *lua.State, args []lua.Value) ([]lua.Value, error) {
+		function, err := registeredFunction(state, args)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := library.functions[function.name]; exists {
+			return nil, state.Errorf("Function already exists in the library")
+		}
+		library.functions[function.name] = function
+		return nil, nil
+	}})
+	addScriptLog(redis)
+	redis.SetReadonly()
+	state := lua.NewState()
+	state.SetGlobal("redis", redis)
+	state.StrictGlobals = true
+	state.Deadline = time.Now().Add(FUNCTION_LOAD_TIME_LIMIT)
+	if _, err := runRecovered(func() ([]lua.Value, error) { return state.Run(chunk) }); err != nil {
+		return nil, "ERR Error registering functions: " + err.Error()
+	}
+	if len(library.functions) == 0 {
+		return nil, "ERR No functions registered"
+	}
+	return library, ""
+}
+
+// Reads the arguments of redis.register_function(name, callback) or
+// redis.register_function{function_name = name, callback = callback, flags = {...}, description = text}
+func registeredFunction(state *lua.State, args []lua.Value) (*libraryFunction, error) {
+	function := &libraryFunction{}
+	var name lua.Value
+	switch {
+	case len(args) == 2:
+		name, function.callback = args[0], args[1]
+	case len(args) == 1:
+		table, ok := args[0].(*lua.Table)
+		if !ok {
+			return nil, state.Errorf("calling redis.register_function with a single argument is only applicable to Lua table (representing named arguments).")
+		}
+		for key, value, ok, _ := table.Next(nil); ok; key, value, ok, _ = table.Next(key) {
+			switch key {
+			case "function_name":
+				name = value
+			case "callback":
+				function.callback = value
+			case "description":
+				description, ok := value.(string)
+				if !ok {
+					return nil, state.Errorf("description argument given to redis.register_function must be a string")
+				}
+				function.description = description
+			case "flags":
+				flags, ok := value.(*lua.Table)
+				if !ok {
+					return nil, state.Errorf("flags argument to redis.register_function must be a table representing function flags")
+				}
+				for i := 1; flags.Get(float64(i)) != nil; i++ {
+					flag, ok := flags.Get(float64(i)).(string)
+					if !ok || !functionFlags[flag] {
+						return nil, state.Errorf("unknown flag given")
+					}
+					function.flags = append(function.flags, flag)
+				}
+			default:
+				return nil, state.Errorf("unknown argument given to redis.register_function")
+			}
+		}
+	default:
+		return nil, state.Errorf("wrong number of arguments to redis.register_function")
+	}
+	text, ok := name.(string)
+	if !ok {
+		return nil, state.Errorf("function_name argument given to redis.register_function must be a string")
+	}
+	if !isFunctionName(text) {
+		return nil, state.Errorf("Function names can only contain letters, numbers, or underscores(_) and must be at least one character long")
+	}
+	function.name = text
+	if lua.TypeName(function.callback) != "function" {
+		return nil, state.Errorf("callback argument given to redis.register_function must be a function")
+	}
+	return function, nil
+}
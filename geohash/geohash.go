@@ -0,0 +1,233 @@
+package geohash
+
+import (
+	"math"
+)
+
+// Coordinates are interleaved into 52 bit geohashes stored as sorted set
+// scores, like redis. Latitudes are limited to what the web mercator
+// projection can represent so areas near the poles aren't huge.
+const (
+	STEP_MAX = 26
+	LAT_MIN  = -85.05112878
+	LAT_MAX  = 85.05112878
+	LONG_MIN = -180.0
+	LONG_MAX = 180.0
+
+	EARTH_RADIUS_IN_METERS = 6372797.560856
+	MERCATOR_MAX           = 20037726.37
+)
+
+// Interleaved bits of a cell at precision step, each step halving the cell
+// along both axes. Longitude uses the odd bits and latitude the even ones.
+type HashBits struct {
+	Bits uint64
+	Step uint
+}
+
+func (hash HashBits) isZero() bool {
+	return hash.Bits == 0 && hash.Step == 0
+}
+
+// Bounds of the cell of a geohash
+type Area struct {
+	LongMin, LongMax float64
+	LatMin, LatMax   float64
+}
+
+// Spreads the 32 bits of v over the even bits of the result
+func spread(v uint32) uint64 {
+	x := uint64(v)
+	x = (x | x<<16) & 0x0000ffff0000ffff
+	x = (x | x<<8) & 0x00ff00ff00ff00ff
+	x = (x | x<<4) & 0x0f0f0f0f0f0f0f0f
+	x = (x | x<<2) & 0x3333333333333333
+	x = (x | x<<1) & 0x5555555555555555
+	return x
+}
+
+// Gathers the even bits of x, the reverse of spread
+func squash(x uint64) uint32 {
+	x &= 0x5555555555555555
+	x = (x | x>>1) & 0x3333333333333333
+	x = (x | x>>2) & 0x0f0f0f0f0f0f0f0f
+	x = (x | x>>4) & 0x00ff00ff00ff00ff
+	x = (x | x>>8) & 0x0000ffff0000ffff
+	x = (x | x>>16) & 0x00000000ffffffff
+	return uint32(x)
+}
+
+func encode(longMin, longMax, latMin, latMax, longitude, latitude float64, step uint) HashBits {
+	latOffset := (latitude - latMin) / (latMax - latMin) * float64(uint64(1)<<step)
+	longOffset := (longitude - longMin) / (longMax - longMin) * float64(uint64(1)<<step)
+	return HashBits{
+		Bits: spread(uint32(latOffset)) | spread(uint32(longOffset))<<1,
+		Step: step,
+	}
+}
+
+func decode(longMin, longMax, latMin, latMax float64, hash HashBits) Area {
+	latCell, longCell := float64(squash(hash.Bits)), float64(squash(hash.Bits>>1))
+	cells := float64(uint64(1) << hash.Step)
+	return Area{
+		LatMin:  latMin + latCell/cells*(latMax-latMin),
+		LatMax:  latMin + (latCell+1)/cells*(latMax-latMin),
+		LongMin: longMin + longCell/cells*(longMax-longMin),
+		LongMax: longMin + (longCell+1)/cells*(longMax-longMin),
+	}
+}
+
+// Checks longitude and latitude are within the indexable range
+func IsValid(longitude float64, latitude float64) bool {
+	return longitude >= LONG_MIN && longitude <= LONG_MAX && latitude >= LAT_MIN && latitude <= LAT_MAX
+}
+
+// Geohash of a point at precision step
+func Encode(longitude float64, latitude float64, step uint) HashBits {
+	return encode(LONG_MIN, LONG_MAX, LAT_MIN, LAT_MAX, longitude, latitude, step)
+}
+
+func Decode(hash HashBits) Area {
+	return decode(LONG_MIN, LONG_MAX, LAT_MIN, LAT_MAX, hash)
+}
+
+// Sorted set score of a point, its geohash at full precision
+func Score(longitude float64, latitude float64) float64 {
+	return float64(Encode(longitude, latitude, STEP_MAX).Bits)
+}
+
+// Center of the cell a score stands for, which is within about 0.6 meters of
+// the point it was made from
+func DecodeScore(score float64) (longitude float64, latitude float64) {
+	area := Decode(HashBits{Bits: uint64(score), Step: STEP_MAX})
+	longitude = math.Min(math.Max((area.LongMin+area.LongMax)/2, LONG_MIN), LONG_MAX)
+	latitude = math.Min(math.Max((area.LatMin+area.LatMax)/2, LAT_MIN), LAT_MAX)
+	return longitude, latitude
+}
+
+const alphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// Standard 11 character geohash of a score, like GEOHASH. The score is
+// re-encoded with the standard latitude range of -90 to 90.
+func String(score float64) string {
+	longitude, latitude := DecodeScore(score)
+	hash := encode(-180, 180, -90, 90, longitude, latitude, STEP_MAX)
+	result := make([]byte, 11)
+	for i := range result {
+		// 52 bits only fill 10 characters, the last one is always 0
+		index := 0
+		if i < 10 {
+			index = int(hash.Bits>>(52-uint(i+1)*5)) & 0x1f
+		}
+		result[i] = alphabet[index]
+	}
+	return string(result)
+}
+
+func degreesToRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+func radiansToDegrees(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
+
+func latitudeDistance(lat1 float64, lat2 float64) float64 {
+	return EARTH_RADIUS_IN_METERS * math.Abs(degreesToRadians(lat2)-degreesToRadians(lat1))
+}
+
+// Distance in meters between two points using the haversine formula
+func Distance(long1 float64, lat1 float64, long2 float64, lat2 float64) float64 {
+	lat1r, long1r := degreesToRadians(lat1), degreesToRadians(long1)
+	lat2r, long2r := degreesToRadians(lat2), degreesToRadians(long2)
+	v := math.Sin((long2r - long1r) / 2)
+	// on the same meridian the latitude distance is exact and cheaper
+	if v == 0 {
+		return latitudeDistance(lat1, lat2)
+	}
+	u := math.Sin((lat2r - lat1r) / 2)
+	a := u*u + math.Cos(lat1r)*math.Cos(lat2r)*v*v
+	return 2 * EARTH_RADIUS_IN_METERS * math.Asin(math.Sqrt(a))
+}
+
+// Moves a cell east (d > 0) or west (d < 0) by changing its longitude bits
+func moveX(hash HashBits, d int) HashBits {
+	if d == 0 {
+		return hash
+	}
+	x := hash.Bits & 0xaaaaaaaaaaaaaaaa
+	y := hash.Bits & 0x5555555555555555
+	zz := uint64(0x5555555555555555) >> (64 - hash.Step*2)
+	if d > 0 {
+		x = x + (zz + 1)
+	} else {
+		x = x | zz
+		x = x - (zz + 1)
+	}
+	x &= 0xaaaaaaaaaaaaaaaa >> (64 - hash.Step*2)
+	hash.Bits = x | y
+	return hash
+}
+
+// Moves a cell north (d > 0) or south (d < 0) by changing its latitude bits
+func moveY(hash HashBits, d int) HashBits {
+	if d == 0 {
+		return hash
+	}
+	x := hash.Bits & 0xaaaaaaaaaaaaaaaa
+	y := hash.Bits & 0x5555555555555555
+	zz := uint64(0xaaaaaaaaaaaaaaaa) >> (64 - hash.Step*2)
+	if d > 0 {
+		y = y + (zz + 1)
+	} else {
+		y = y | zz
+		y = y - (zz + 1)
+	}
+	y &= 0x5555555555555555 >> (64 - hash.Step*2)
+	hash.Bits = x | y
+	return hash
+}
+
+// Cell of hash followed by its 8 neighbours in the order redis searches them:
+// north, south, east, west, north east, north west, south east, south west
+func neighbours(hash HashBits) [9]HashBits {
+	return [9]HashBits{
+		hash,
+		moveY(hash, 1),
+		moveY(hash, -1),
+		moveX(hash, 1),
+		moveX(hash, -1),
+		moveY(moveX(hash, 1), 1),
+		moveY(moveX(hash, -1), 1),
+		moveY(moveX(hash, 1), -1),
+		moveY(moveX(hash, -1), -1),
+	}
+}
+
+// Coarsest precision whose cells are still larger than rangeMeters, so the
+// search area is covered by a cell and its neighbours
+func estimateStepsByRadius(rangeMeters float64, latitude float64) uint {
+	if rangeMeters == 0 {
+		return STEP_MAX
+	}
+	step := 1
+	for rangeMeters < MERCATOR_MAX {
+		rangeMeters *= 2
+		step++
+	}
+	step -= 2
+	// cells get narrower towards the poles
+	if latitude > 66 || latitude < -66 {
+		step--
+		if latitude > 80 || latitude < -80 {
+			step--
+		}
+	}
+	if step < 1 {
+		step = 1
+	}
+	if step > STEP_MAX {
+		step = STEP_MAX
+	}
+	return uint(step)
+}
@@ -0,0 +1,97 @@
+package geohash
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// Palermo and Catania from the redis GEOADD documentation
+const palermoLong, palermoLat = 13.361389, 38.115556
+const cataniaLong, cataniaLat = 15.087269, 37.502669
+
+func TestScoreRoundTrip(t *testing.T) {
+	score := Score(palermoLong, palermoLat)
+	if strconv.FormatFloat(score, 'f', -1, 64) != "3479099956230698" {
+		t.Errorf("Expected redis's score for Palermo but got %v", strconv.FormatFloat(score, 'f', -1, 64))
+	}
+	longitude, latitude := DecodeScore(score)
+	if math.Abs(longitude-13.36138933897018433) > 1e-12 || math.Abs(latitude-38.11555639549629859) > 1e-12 {
+		t.Errorf("Unexpected position %v %v", longitude, latitude)
+	}
+	if Distance(palermoLong, palermoLat, longitude, latitude) > 1 {
+		t.Errorf("Decoded point should be within a meter")
+	}
+}
+
+func TestString(t *testing.T) {
+	if hash := String(Score(palermoLong, palermoLat)); hash != "sqc8b49rny0" {
+		t.Errorf("Expected sqc8b49rny0 but got %v", hash)
+	}
+	if hash := String(Score(cataniaLong, cataniaLat)); hash != "sqdtr74hyu0" {
+		t.Errorf("Expected sqdtr74hyu0 but got %v", hash)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	palermoLong, palermoLat := DecodeScore(Score(palermoLong, palermoLat))
+	cataniaLong, cataniaLat := DecodeScore(Score(cataniaLong, cataniaLat))
+	distance := Distance(palermoLong, palermoLat, cataniaLong, cataniaLat)
+	if strconv.FormatFloat(distance, 'f', 4, 64) != "166274.1516" {
+		t.Errorf("Expected 166274.1516 but got %v", distance)
+	}
+	if Distance(10, 20, 10, 21) != latitudeDistance(20, 21) {
+		t.Errorf("Same meridian should use the latitude distance")
+	}
+}
+
+func TestNeighbours(t *testing.T) {
+	hash := Encode(palermoLong, palermoLat, 10)
+	area := Decode(hash)
+	cells := neighbours(hash)
+	north, east := Decode(cells[1]), Decode(cells[3])
+	if north.LatMin != area.LatMax || north.LongMin != area.LongMin {
+		t.Errorf("North cell %v should sit on top of %v", north, area)
+	}
+	if east.LongMin != area.LongMax || east.LatMin != area.LatMin {
+		t.Errorf("East cell %v should sit right of %v", east, area)
+	}
+}
+
+// Every point within the shape must fall in one of its score ranges
+func TestScoreRangesCoverShape(t *testing.T) {
+	random := rand.New(rand.NewSource(3))
+	for i := 0; i < 200; i++ {
+		shape := Shape{
+			Longitude: random.Float64()*360 - 180,
+			Latitude:  random.Float64()*160 - 80,
+			IsBox:     i%2 == 0,
+			Radius:    random.Float64() * 500000,
+			Width:     random.Float64() * 500000,
+			Height:    random.Float64() * 500000,
+		}
+		ranges := shape.ScoreRanges()
+		sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+		for j := 0; j < 100; j++ {
+			longitude := shape.Longitude + (random.Float64()-0.5)*10
+			latitude := shape.Latitude + (random.Float64()-0.5)*10
+			if !IsValid(longitude, latitude) {
+				continue
+			}
+			score := Score(longitude, latitude)
+			longitude, latitude = DecodeScore(score)
+			if _, ok := shape.Contains(longitude, latitude); !ok {
+				continue
+			}
+			covered := false
+			for _, r := range ranges {
+				covered = covered || (score >= r[0] && score < r[1])
+			}
+			if !covered {
+				t.Fatalf("Point %v,%v inside %+v is not covered by %v", longitude, latitude, shape, ranges)
+			}
+		}
+	}
+}
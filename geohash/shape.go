@@ -0,0 +1,110 @@
+package geohash
+
+import (
+	"math"
+)
+
+// Area searched by GEOSEARCH: a circle of Radius meters, or a box of Width by
+// Height meters when IsBox, centered on Longitude and Latitude
+type Shape struct {
+	Longitude, Latitude float64
+	IsBox               bool
+	Radius              float64
+	Width, Height       float64
+}
+
+// Distance in meters of a point from the center of the shape. ok is false if
+// the point is outside the shape.
+func (shape Shape) Contains(longitude float64, latitude float64) (distance float64, ok bool) {
+	if !shape.IsBox {
+		distance = Distance(shape.Longitude, shape.Latitude, longitude, latitude)
+		return distance, distance <= shape.Radius
+	}
+	// latitude distance is cheaper to compute so it is checked first
+	if latitudeDistance(latitude, shape.Latitude) > shape.Height/2 {
+		return 0, false
+	}
+	if Distance(longitude, latitude, shape.Longitude, latitude) > shape.Width/2 {
+		return 0, false
+	}
+	return Distance(shape.Longitude, shape.Latitude, longitude, latitude), true
+}
+
+// Bounding box of the shape as longitude min, latitude min, longitude max, latitude max
+func (shape Shape) boundingBox() [4]float64 {
+	width, height := shape.Radius, shape.Radius
+	if shape.IsBox {
+		width, height = shape.Width/2, shape.Height/2
+	}
+	latDelta := radiansToDegrees(height / EARTH_RADIUS_IN_METERS)
+	// the box is widest on the side nearer the equator
+	longDelta := radiansToDegrees(width / EARTH_RADIUS_IN_METERS / math.Cos(degreesToRadians(shape.Latitude+latDelta)))
+	if shape.Latitude < 0 {
+		longDelta = radiansToDegrees(width / EARTH_RADIUS_IN_METERS / math.Cos(degreesToRadians(shape.Latitude-latDelta)))
+	}
+	return [4]float64{shape.Longitude - longDelta, shape.Latitude - latDelta, shape.Longitude + longDelta, shape.Latitude + latDelta}
+}
+
+// Cells covering the shape: the cell of its center and the neighbours
+// reaching into the bounding box, like redis's geohashCalculateAreasByShapeWGS84
+func (shape Shape) cells() []HashBits {
+	bounds := shape.boundingBox()
+	radius := shape.Radius
+	if shape.IsBox {
+		radius = math.Sqrt((shape.Width/2)*(shape.Width/2) + (shape.Height/2)*(shape.Height/2))
+	}
+	step := estimateStepsByRadius(radius, shape.Latitude)
+	hash := Encode(shape.Longitude, shape.Latitude, step)
+	cells := neighbours(hash)
+	area := Decode(hash)
+
+	// near the edge of the center cell a neighbour may not reach far enough,
+	// use cells twice as large then
+	north, south := Decode(cells[1]), Decode(cells[2])
+	east, west := Decode(cells[3]), Decode(cells[4])
+	if step > 1 && (north.LatMax < bounds[3] || south.LatMin > bounds[1] ||
+		east.LongMax < bounds[2] || west.LongMin > bounds[0]) {
+		step--
+		hash = Encode(shape.Longitude, shape.Latitude, step)
+		cells = neighbours(hash)
+		area = Decode(hash)
+	}
+
+	// drop neighbours the bounding box doesn't reach
+	if step >= 2 {
+		zero := HashBits{}
+		if area.LatMin < bounds[1] {
+			cells[2], cells[8], cells[7] = zero, zero, zero
+		}
+		if area.LatMax > bounds[3] {
+			cells[1], cells[5], cells[6] = zero, zero, zero
+		}
+		if area.LongMin < bounds[0] {
+			cells[4], cells[8], cells[6] = zero, zero, zero
+		}
+		if area.LongMax > bounds[2] {
+			cells[3], cells[7], cells[5] = zero, zero, zero
+		}
+	}
+	var result []HashBits
+	seen := make(map[HashBits]bool)
+	for _, cell := range cells {
+		if cell.isZero() || seen[cell] {
+			continue
+		}
+		seen[cell] = true
+		result = append(result, cell)
+	}
+	return result
+}
+
+// Score ranges [min, max) of the sorted set holding every point which may
+// be in the shape. Points found still need to be checked with Contains.
+func (shape Shape) ScoreRanges() [][2]float64 {
+	var ranges [][2]float64
+	for _, cell := range shape.cells() {
+		shift := 2 * (STEP_MAX - cell.Step)
+		ranges = append(ranges, [2]float64{float64(cell.Bits << shift), float64((cell.Bits + 1) << shift)})
+	}
+	return ranges
+}
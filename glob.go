@@ -0,0 +1,82 @@
+package main
+
+// Reports whether text matches a redis style glob pattern: * matches any
+// sequence, ? any single byte, [abc], [^abc] and [a-z] match classes of
+// bytes and \ escapes the next character. Used by MATCH options.
+func globMatch(pattern string, text string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(text); i++ {
+				if globMatch(pattern[1:], text[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(text) == 0 {
+				return false
+			}
+			text = text[1:]
+			pattern = pattern[1:]
+		case '[':
+			if len(text) == 0 {
+				return false
+			}
+			pattern = pattern[1:]
+			negate := len(pattern) > 0 && pattern[0] == '^'
+			if negate {
+				pattern = pattern[1:]
+			}
+			matched := false
+			for len(pattern) > 0 && pattern[0] != ']' {
+				if pattern[0] == '\\' && len(pattern) > 1 {
+					if pattern[1] == text[0] {
+						matched = true
+					}
+					pattern = pattern[2:]
+				} else if len(pattern) > 2 && pattern[1] == '-' && pattern[2] != ']' {
+					low, high := pattern[0], pattern[2]
+					if low > high {
+						low, high = high, low
+					}
+					if text[0] >= low && text[0] <= high {
+						matched = true
+					}
+					pattern = pattern[3:]
+				} else {
+					if pattern[0] == text[0] {
+						matched = true
+					}
+					pattern = pattern[1:]
+				}
+			}
+			if len(pattern) > 0 {
+				// skip closing ]
+				pattern = pattern[1:]
+			}
+			if matched == negate {
+				return false
+			}
+			text = text[1:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(text) == 0 || pattern[0] != text[0] {
+				return false
+			}
+			text = text[1:]
+			pattern = pattern[1:]
+		}
+	}
+	return len(text) == 0
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestGlobMatch(t *testing.T) {
+	cases := []struct {
+		pattern, text string
+		expected      bool
+	}{
+		{"*", "anything", true},
+		{"*", "", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h*llo", "heeeello", true},
+		{"h*llo", "hello world", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[a-b]llo", "hcllo", false},
+		{"news.*", "news.tech", true},
+		{"news.*", "sport.news", false},
+		{`\*`, "*", true},
+		{`\*`, "a", false},
+		{"user:*:name", "user:42:name", true},
+	}
+	for _, c := range cases {
+		if result := globMatch(c.pattern, c.text); result != c.expected {
+			t.Errorf("globMatch(%q, %q) expected %v but got %v", c.pattern, c.text, c.expected, result)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package hashObjectMap
+
+import (
+	"container/heap"
+	"time"
+)
+
+type fieldDeadline struct {
+	field    string
+	deadline time.Time
+	index    int // position in the heap
+}
+
+// Deadlines of the fields with a TTL, in a min-heap so the expired ones are
+// found without walking the others, and by field.
+type fieldDeadlines struct {
+	heap    deadlineHeap
+	byField map[string]*fieldDeadline
+}
+
+func (d *fieldDeadlines) get(field string) (time.Time, bool) {
+	entry, exists := d.byField[field]
+	if !exists {
+		return time.Time{}, false
+	}
+	return entry.deadline, true
+}
+
+func (d *fieldDeadlines) set(field string, deadline time.Time) {
+	if entry, exists := d.byField[field]; exists {
+		entry.deadline = deadline
+		heap.Fix(&d.heap, entry.index)
+		return
+	}
+	if d.byField == nil {
+		d.byField = make(map[string]*fieldDeadline)
+	}
+	entry := &fieldDeadline{field: field, deadline: deadline}
+	d.byField[field] = entry
+	heap.Push(&d.heap, entry)
+}
+
+// Removes the deadline of field. Returns false if it had none.
+func (d *fieldDeadlines) remove(field string) bool {
+	entry, exists := d.byField[field]
+	if !exists {
+		return false
+	}
+	heap.Remove(&d.heap, entry.index)
+	delete(d.byField, field)
+	return true
+}
+
+// Number of deadlines which aren't after now. Only visits those and the
+// heap nodes right below them, as children are never earlier than parents.
+func (d *fieldDeadlines) countPassed(now time.Time) int {
+	return d.countPassedFrom(0, now)
+}
+
+func (d *fieldDeadlines) countPassedFrom(index int, now time.Time) int {
+	if index >= len(d.heap) || now.Before(d.heap[index].deadline) {
+		return 0
+	}
+	return 1 + d.countPassedFrom(2*index+1, now) + d.countPassedFrom(2*index+2, now)
+}
+
+// Implements heap.Interface, earliest deadline first
+type deadlineHeap []*fieldDeadline
+
+func (h deadlineHeap) Len() int {
+	return len(h)
+}
+
+func (h deadlineHeap) Less(i, j int) bool {
+	return h[i].deadline.Before(h[j].deadline)
+}
+
+func (h deadlineHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *deadlineHeap) Push(x interface{}) {
+	entry := x.(*fieldDeadline)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *deadlineHeap) Pop() interface{} {
+	old := *h
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return entry
+}
@@ -0,0 +1,552 @@
+package hashObjectMap
+
+import (
+	"errors"
+	"github.com/thedeveloperr/redis-clone/keyspace"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var ErrNotInteger = errors.New("ERR hash value is not an integer")
+var ErrNotFloat = errors.New("ERR hash value is not a float")
+var ErrOverflow = errors.New("ERR increment or decrement would overflow")
+var ErrNaNOrInfinity = errors.New("ERR increment would produce NaN or Infinity")
+
+// Field value pairs stored under a single key
+type HashObject struct {
+	fields    map[string]string
+	deadlines fieldDeadlines     // of fields with a TTL
+	order     keyspace.ScanOrder // fields in the order Scan visits them
+	// keyspace holding the hash, whose clock tells which fields expired
+	keyspace *keyspace.Keyspace
+}
+
+// Reports whether field has passed its deadline and should be treated as missing
+func (h *HashObject) isFieldExpired(field string, now time.Time) bool {
+	deadline, hasTTL := h.deadlines.get(field)
+	return hasTTL && !now.Before(deadline)
+}
+
+func (h *HashObject) get(field string, now time.Time) (string, bool) {
+	value, exists := h.fields[field]
+	if !exists || h.isFieldExpired(field, now) {
+		return "", false
+	}
+	return value, true
+}
+
+// Number of fields which haven't expired. Only the expired fields their
+// timers didn't remove yet are visited.
+func (h *HashObject) length(now time.Time) int {
+	return len(h.fields) - h.deadlines.countPassed(now)
+}
+
+// Sets field to value, keeping its TTL. Caller must hold the write lock.
+func (h *HashObject) setField(field string, value string) {
+	if _, exists := h.fields[field]; !exists {
+		h.order.Add(field)
+	}
+	h.fields[field] = value
+}
+
+// Removes field and its TTL. Caller must hold the write lock.
+func (h *HashObject) deleteField(field string) {
+	if _, exists := h.fields[field]; exists {
+		h.order.Remove(field)
+	}
+	delete(h.fields, field)
+	h.deadlines.remove(field)
+}
+
+// Removes field if it expired so writes see it as missing. Caller must hold the write lock.
+func (h *HashObject) purgeIfExpired(field string, now time.Time) {
+	if h.isFieldExpired(field, now) {
+		h.deleteField(field)
+	}
+}
+
+// Whether every field is gone, when the hash stops existing
+func (h *HashObject) IsEmpty() bool {
+	return h.length(h.keyspace.Now()) == 0
+}
+
+type ConcurrentHashObjectMap struct {
+	keyspace *keyspace.Keyspace
+	// called when timers remove fields, see OnFieldsExpired
+	onFieldsExpired func(key string, keyRemoved bool)
+}
+
+// Hashes stored in a keyspace of their own
+func Create() *ConcurrentHashObjectMap {
+	return CreateInKeyspace(keyspace.New())
+}
+
+// Hashes stored in keyspace, along with the values of other types
+func CreateInKeyspace(keyspace *keyspace.Keyspace) *ConcurrentHashObjectMap {
+	return &ConcurrentHashObjectMap{keyspace: keyspace}
+}
+
+// Sets a function called, without any lock held, each time the timer of a
+// field removes it once its TTL passed. keyRemoved tells if it was the last
+// field of the hash. It must be set before fields expire.
+func (c *ConcurrentHashObjectMap) OnFieldsExpired(callback func(key string, keyRemoved bool)) {
+	c.onFieldsExpired = callback
+}
+
+// Hash at key. Caller must hold the lock of the shard.
+func getUnsafe(s *keyspace.Shard, key string) (*HashObject, bool) {
+	entry, exists := s.Get(key)
+	if !exists {
+		return nil, false
+	}
+	hash, isHash := entry.Value.(*HashObject)
+	return hash, isHash
+}
+
+// Keys which exist, in no particular order
+func (c *ConcurrentHashObjectMap) Keys() []string {
+	return c.keyspace.Keys(IsHash)
+}
+
+// Whether value, read from a keyspace, is a hash
+func IsHash(value interface{}) bool {
+	_, isHash := value.(*HashObject)
+	return isHash
+}
+
+// Returns hash at key, creating an empty one if missing. Caller must hold the write lock.
+func (c *ConcurrentHashObjectMap) getOrCreateUnsafe(s *keyspace.Shard, key string) *HashObject {
+	hash, exists := getUnsafe(s, key)
+	if !exists {
+		hash = &HashObject{fields: make(map[string]string), keyspace: c.keyspace}
+		s.Set(key, hash)
+	}
+	return hash
+}
+
+// Sets fields and returns how many of them are new
+func (c *ConcurrentHashObjectMap) Set(key string, pairs [][2]string) int {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	hash := c.getOrCreateUnsafe(s, key)
+	now := c.keyspace.Now()
+	added := 0
+	for _, pair := range pairs {
+		hash.purgeIfExpired(pair[0], now)
+		if _, exists := hash.fields[pair[0]]; !exists {
+			added++
+		}
+		// overwriting a field clears its TTL
+		hash.deadlines.remove(pair[0])
+		hash.setField(pair[0], pair[1])
+	}
+	return added
+}
+
+// Sets field only if it doesn't exist. Returns 1 if set.
+func (c *ConcurrentHashObjectMap) SetNX(key string, field string, value string) int {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	hash := c.getOrCreateUnsafe(s, key)
+	hash.purgeIfExpired(field, c.keyspace.Now())
+	if _, exists := hash.fields[field]; exists {
+		return 0
+	}
+	hash.setField(field, value)
+	return 1
+}
+
+func (c *ConcurrentHashObjectMap) Get(key string, field string) (string, bool) {
+	s := c.keyspace.Shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	valueItem, exists := getUnsafe(s, key)
+	if !exists {
+		return "", false
+	}
+	return valueItem.get(field, c.keyspace.Now())
+}
+
+func (c *ConcurrentHashObjectMap) MGet(key string, fields []string) (values []string, exists []bool) {
+	s := c.keyspace.Shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	values = make([]string, len(fields))
+	exists = make([]bool, len(fields))
+	valueItem, ok := getUnsafe(s, key)
+	if !ok {
+		return values, exists
+	}
+	now := c.keyspace.Now()
+	for i, field := range fields {
+		values[i], exists[i] = valueItem.get(field, now)
+	}
+	return values, exists
+}
+
+// All fields and values, ordered by field so replies are stable
+func (c *ConcurrentHashObjectMap) GetAll(key string) (fields []string, values []string) {
+	s := c.keyspace.Shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	valueItem, exists := getUnsafe(s, key)
+	if !exists {
+		return fields, values
+	}
+	now := c.keyspace.Now()
+	for field := range valueItem.fields {
+		if !valueItem.isFieldExpired(field, now) {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	values = make([]string, len(fields))
+	for i, field := range fields {
+		values[i] = valueItem.fields[field]
+	}
+	return fields, values
+}
+
+// Removes fields and returns how many existed. The key is removed with its last field.
+func (c *ConcurrentHashObjectMap) Del(key string, fields []string) int {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	valueItem, exists := getUnsafe(s, key)
+	if !exists {
+		return 0
+	}
+	now := c.keyspace.Now()
+	removed := 0
+	for _, field := range fields {
+		if _, exists := valueItem.get(field, now); exists {
+			removed++
+		}
+		valueItem.deleteField(field)
+	}
+	if valueItem.length(now) == 0 {
+		s.Delete(key)
+	}
+	return removed
+}
+
+func (c *ConcurrentHashObjectMap) Exists(key string, field string) bool {
+	_, exists := c.Get(key, field)
+	return exists
+}
+
+// Number of fields
+func (c *ConcurrentHashObjectMap) Len(key string) int {
+	s := c.keyspace.Shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	valueItem, exists := getUnsafe(s, key)
+	if !exists {
+		return 0
+	}
+	return valueItem.length(c.keyspace.Now())
+}
+
+// Length of the value of field, 0 if missing
+func (c *ConcurrentHashObjectMap) StrLen(key string, field string) int {
+	value, _ := c.Get(key, field)
+	return len(value)
+}
+
+// Adds delta to integer in field, missing field counts as 0
+func (c *ConcurrentHashObjectMap) IncrBy(key string, field string, delta int64) (int64, error) {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	valueItem, exists := getUnsafe(s, key)
+	var current int64 = 0
+	if exists {
+		valueItem.purgeIfExpired(field, c.keyspace.Now())
+		if value, ok := valueItem.fields[field]; ok {
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return 0, ErrNotInteger
+			}
+			current = parsed
+		}
+	}
+	if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+		return 0, ErrOverflow
+	}
+	result := current + delta
+	c.getOrCreateUnsafe(s, key).setField(field, strconv.FormatInt(result, 10))
+	return result, nil
+}
+
+// Adds delta to float in field, missing field counts as 0
+func (c *ConcurrentHashObjectMap) IncrByFloat(key string, field string, delta float64) (float64, error) {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	valueItem, exists := getUnsafe(s, key)
+	current := 0.0
+	if exists {
+		valueItem.purgeIfExpired(field, c.keyspace.Now())
+		if value, ok := valueItem.fields[field]; ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+				return 0, ErrNotFloat
+			}
+			current = parsed
+		}
+	}
+	result := current + delta
+	if math.IsNaN(result) || math.IsInf(result, 0) {
+		return 0, ErrNaNOrInfinity
+	}
+	c.getOrCreateUnsafe(s, key).setField(field, strconv.FormatFloat(result, 'f', -1, 64))
+	return result, nil
+}
+
+// Random fields like HRANDFIELD. Positive count returns distinct fields, up to
+// all of them. Negative count returns -count fields which may repeat.
+func (c *ConcurrentHashObjectMap) RandomFields(key string, count int64) (fields []string, values []string) {
+	allFields, allValues := c.GetAll(key)
+	if len(allFields) == 0 || count == 0 {
+		return fields, values
+	}
+	if count < 0 {
+		for i := int64(0); i < -count; i++ {
+			j := rand.Intn(len(allFields))
+			fields = append(fields, allFields[j])
+			values = append(values, allValues[j])
+		}
+		return fields, values
+	}
+	order := rand.Perm(len(allFields))
+	for i := 0; i < len(order) && int64(i) < count; i++ {
+		fields = append(fields, allFields[order[i]])
+		values = append(values, allValues[order[i]])
+	}
+	return fields, values
+}
+
+// Returns about count fields starting at cursor, and the cursor to continue
+// from, 0 once iteration is complete. A field present for the whole iteration
+// is always returned, even if others are added or removed between calls, see
+// keyspace.ScanOrder.
+func (c *ConcurrentHashObjectMap) Scan(key string, cursor uint64, count int) (nextCursor uint64, fields []string, values []string) {
+	s := c.keyspace.Shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	hash, exists := getUnsafe(s, key)
+	if !exists {
+		return 0, fields, values
+	}
+	now := c.keyspace.Now()
+	nextCursor = hash.order.Scan(cursor, count, func(field string) {
+		if !hash.isFieldExpired(field, now) {
+			fields = append(fields, field)
+			values = append(values, hash.fields[field])
+		}
+	})
+	return nextCursor, fields, values
+}
+
+func (c *ConcurrentHashObjectMap) Expire(key string, timeoutSeconds int) int {
+	return c.ExpireAt(key, time.Now().Add(time.Duration(timeoutSeconds)*time.Second))
+}
+
+// Expire key at an absolute deadline. A deadline in the past removes the key right away.
+func (c *ConcurrentHashObjectMap) ExpireAt(key string, deadline time.Time) int {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	if _, exists := getUnsafe(s, key); !exists || !s.ExpireAt(key, deadline) {
+		return 0
+	}
+	return 1
+}
+
+// Remove timeout of key. Returns 1 if a timeout was removed
+func (c *ConcurrentHashObjectMap) Persist(key string) int {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	if _, exists := getUnsafe(s, key); !exists || !s.Persist(key) {
+		return 0
+	}
+	return 1
+}
+
+// Replies of ExpireFields and PersistFields for each field, same as redis,
+// and the status of each TTL returned by FieldTTL
+const (
+	FIELD_MISSING = -2 // no such field or key
+	FIELD_NO_TTL  = -1 // PersistFields on a field without TTL
+	FIELD_NOT_SET = 0  // NX, XX, GT or LT condition not met
+	FIELD_TTL_SET = 1  // deadline set, or TTL removed by PersistFields
+	FIELD_DELETED = 2  // deadline already passed so the field was removed
+)
+
+// Sets deadline on each field. condition is "" or one of NX (field has no TTL),
+// XX (field has a TTL), GT (later than current TTL) and LT (earlier than
+// current TTL), where no TTL counts as infinite. Returns a FIELD_* code per field.
+func (c *ConcurrentHashObjectMap) ExpireFields(key string, deadline time.Time, condition string, fields []string) []int {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	results := make([]int, len(fields))
+	hash, exists := getUnsafe(s, key)
+	if !exists {
+		for i := range results {
+			results[i] = FIELD_MISSING
+		}
+		return results
+	}
+	now := c.keyspace.Now()
+	for i, field := range fields {
+		if _, exists := hash.get(field, now); !exists {
+			results[i] = FIELD_MISSING
+			continue
+		}
+		current, hasTTL := hash.deadlines.get(field)
+		if (condition == "NX" && hasTTL) || (condition == "XX" && !hasTTL) ||
+			(condition == "GT" && (!hasTTL || !deadline.After(current))) ||
+			(condition == "LT" && hasTTL && !deadline.Before(current)) {
+			results[i] = FIELD_NOT_SET
+			continue
+		}
+		// while the AOF loads, deadlines are only stored so the writes logged
+		// after them see the field, see FinishLoading
+		if !now.Before(deadline) && !c.keyspace.IsLoading() {
+			hash.deleteField(field)
+			results[i] = FIELD_DELETED
+			continue
+		}
+		hash.deadlines.set(field, deadline)
+		if !c.keyspace.IsLoading() {
+			c.scheduleFieldExpiry(s, key, field, deadline)
+		}
+		results[i] = FIELD_TTL_SET
+	}
+	if hash.length(now) == 0 {
+		s.Delete(key)
+	}
+	return results
+}
+
+// Starts the timers of the field deadlines stored while the AOF loaded,
+// removing the fields whose deadline passed. Called once the keyspace
+// finished loading.
+func (c *ConcurrentHashObjectMap) FinishLoading() {
+	for _, key := range c.Keys() {
+		s := c.keyspace.Shard(key)
+		s.Lock()
+		if hash, exists := getUnsafe(s, key); exists {
+			now := time.Now()
+			for field, deadline := range hash.deadlines.byField {
+				if now.Before(deadline.deadline) {
+					c.scheduleFieldExpiry(s, key, field, deadline.deadline)
+				} else {
+					hash.deleteField(field)
+				}
+			}
+			if hash.length(now) == 0 {
+				s.Delete(key)
+			}
+		}
+		s.Unlock()
+	}
+}
+
+// Removes field once deadline passes unless its TTL changed meanwhile.
+// Lazy checks on reads hide it if the timer runs late.
+func (c *ConcurrentHashObjectMap) scheduleFieldExpiry(s *keyspace.Shard, key string, field string, deadline time.Time) {
+	time.AfterFunc(time.Until(deadline), func() {
+		removed, keyRemoved := c.removeExpiredField(s, key, field)
+		if removed && c.onFieldsExpired != nil {
+			c.onFieldsExpired(key, keyRemoved)
+		}
+	})
+}
+
+// Removes field if its TTL passed, along with the hash if it was its last field
+func (c *ConcurrentHashObjectMap) removeExpiredField(s *keyspace.Shard, key string, field string) (removed bool, keyRemoved bool) {
+	s.Lock()
+	defer s.Unlock()
+	// the hash looks missing once its last field expired
+	entry, exists := s.GetIncludingEmpty(key)
+	if !exists {
+		return false, false
+	}
+	// the key may hold another type by now
+	hash, isHash := entry.Value.(*HashObject)
+	if !isHash || !hash.isFieldExpired(field, c.keyspace.Now()) {
+		return false, false
+	}
+	hash.deleteField(field)
+	if hash.IsEmpty() {
+		s.Delete(key)
+		return true, true
+	}
+	return true, false
+}
+
+// Remaining TTL of each field. codes[i] is FIELD_TTL_SET when ttls[i] holds
+// it, FIELD_MISSING for missing fields and FIELD_NO_TTL for fields which
+// don't expire.
+func (c *ConcurrentHashObjectMap) FieldTTL(key string, fields []string) (ttls []time.Duration, codes []int) {
+	s := c.keyspace.Shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	ttls = make([]time.Duration, len(fields))
+	codes = make([]int, len(fields))
+	valueItem, exists := getUnsafe(s, key)
+	now := c.keyspace.Now()
+	for i, field := range fields {
+		if !exists {
+			codes[i] = FIELD_MISSING
+			continue
+		}
+		if _, ok := valueItem.get(field, now); !ok {
+			codes[i] = FIELD_MISSING
+			continue
+		}
+		deadline, hasTTL := valueItem.deadlines.get(field)
+		if !hasTTL {
+			codes[i] = FIELD_NO_TTL
+			continue
+		}
+		ttls[i] = deadline.Sub(now)
+		codes[i] = FIELD_TTL_SET
+	}
+	return ttls, codes
+}
+
+// Removes the TTL of each field. Returns FIELD_TTL_SET if one was removed,
+// FIELD_NO_TTL if the field had none or FIELD_MISSING.
+func (c *ConcurrentHashObjectMap) PersistFields(key string, fields []string) []int {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	results := make([]int, len(fields))
+	valueItem, exists := getUnsafe(s, key)
+	now := c.keyspace.Now()
+	for i, field := range fields {
+		if !exists {
+			results[i] = FIELD_MISSING
+			continue
+		}
+		if _, ok := valueItem.get(field, now); !ok {
+			results[i] = FIELD_MISSING
+			continue
+		}
+		if !valueItem.deadlines.remove(field) {
+			results[i] = FIELD_NO_TTL
+			continue
+		}
+		results[i] = FIELD_TTL_SET
+	}
+	return results
+}
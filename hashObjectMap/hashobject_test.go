@@ -0,0 +1,212 @@
+package hashObjectMap
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSetGetDel(t *testing.T) {
+	hashes := Create()
+	if added := hashes.Set("user", [][2]string{{"name", "alice"}, {"age", "30"}}); added != 2 {
+		t.Errorf("Expected 2 new fields but got %v", added)
+	}
+	if added := hashes.Set("user", [][2]string{{"name", "bob"}, {"city", "delhi"}}); added != 1 {
+		t.Errorf("Expected 1 new field but got %v", added)
+	}
+	if value, exists := hashes.Get("user", "name"); !exists || value != "bob" {
+		t.Errorf("Expected bob but got %v", value)
+	}
+	if hashes.Len("user") != 3 {
+		t.Errorf("Expected 3 fields but got %v", hashes.Len("user"))
+	}
+	fields, values := hashes.GetAll("user")
+	if !reflect.DeepEqual(fields, []string{"age", "city", "name"}) ||
+		!reflect.DeepEqual(values, []string{"30", "delhi", "bob"}) {
+		t.Errorf("Unexpected GetAll result %v %v", fields, values)
+	}
+	if removed := hashes.Del("user", []string{"age", "missing"}); removed != 1 {
+		t.Errorf("Expected 1 removed field but got %v", removed)
+	}
+	hashes.Del("user", []string{"city", "name"})
+	if hashes.Len("user") != 0 {
+		t.Errorf("Expected hash to be removed with its last field")
+	}
+	if hashes.Persist("user") != 0 {
+		t.Errorf("Removed hash should not exist")
+	}
+}
+
+func TestSetNXAndIncr(t *testing.T) {
+	hashes := Create()
+	if hashes.SetNX("h", "f", "1") != 1 || hashes.SetNX("h", "f", "2") != 0 {
+		t.Errorf("SetNX should only set missing fields")
+	}
+	if result, err := hashes.IncrBy("h", "f", 10); err != nil || result != 11 {
+		t.Errorf("Expected 11 but got %v, err: %v", result, err)
+	}
+	if result, err := hashes.IncrByFloat("h", "f", 0.5); err != nil || result != 11.5 {
+		t.Errorf("Expected 11.5 but got %v, err: %v", result, err)
+	}
+	if _, err := hashes.IncrBy("h", "f", 1); err != ErrNotInteger {
+		t.Errorf("Expected not integer error but got %v", err)
+	}
+	hashes.Set("h", [][2]string{{"text", "abc"}})
+	if _, err := hashes.IncrByFloat("h", "text", 1); err != ErrNotFloat {
+		t.Errorf("Expected not float error but got %v", err)
+	}
+	if hashes.StrLen("h", "text") != 3 {
+		t.Errorf("Expected length 3 but got %v", hashes.StrLen("h", "text"))
+	}
+}
+
+func TestRandomFields(t *testing.T) {
+	hashes := Create()
+	hashes.Set("h", [][2]string{{"a", "1"}, {"b", "2"}, {"c", "3"}})
+	fields, _ := hashes.RandomFields("h", 10)
+	if len(fields) != 3 {
+		t.Errorf("Positive count should return at most all distinct fields, got %v", fields)
+	}
+	seen := make(map[string]bool)
+	for _, field := range fields {
+		if seen[field] {
+			t.Errorf("Positive count returned %v twice", field)
+		}
+		seen[field] = true
+	}
+	fields, values := hashes.RandomFields("h", -7)
+	if len(fields) != 7 || len(values) != 7 {
+		t.Errorf("Negative count should return exactly 7 fields, got %v", fields)
+	}
+}
+
+func TestScanVisitsEveryField(t *testing.T) {
+	hashes := Create()
+	for i := 0; i < 100; i++ {
+		hashes.Set("h", [][2]string{{"field" + strconv.Itoa(i), strconv.Itoa(i)}})
+	}
+	seen := make(map[string]bool)
+	var cursor uint64 = 0
+	calls := 0
+	for {
+		nextCursor, fields, _ := hashes.Scan("h", cursor, 7)
+		for _, field := range fields {
+			seen[field] = true
+		}
+		// fields removed or added during iteration must not hide the others
+		if calls == 2 {
+			hashes.Del("h", []string{"field0", "field1", "field2"})
+			hashes.Set("h", [][2]string{{"new", "value"}})
+		}
+		calls++
+		cursor = nextCursor
+		if cursor == 0 {
+			break
+		}
+	}
+	for i := 3; i < 100; i++ {
+		if !seen["field"+strconv.Itoa(i)] {
+			t.Errorf("Scan missed field%v", i)
+		}
+	}
+	if calls < 10 {
+		t.Errorf("Expected scan to take several calls but took %v", calls)
+	}
+}
+
+func TestHashExpire(t *testing.T) {
+	hashes := Create()
+	hashes.Set("h", [][2]string{{"f", "v"}})
+	hashes.ExpireAt("h", time.Now().Add(20*time.Millisecond))
+	if !hashes.Exists("h", "f") {
+		t.Errorf("Field should exist before deadline")
+	}
+	time.Sleep(40 * time.Millisecond)
+	if hashes.Exists("h", "f") {
+		t.Errorf("Hash should be removed after deadline")
+	}
+}
+
+func TestFieldExpiry(t *testing.T) {
+	hashes := Create()
+	hashes.Set("session", [][2]string{{"token", "abc"}, {"user", "alice"}})
+	results := hashes.ExpireFields("session", time.Now().Add(20*time.Millisecond), "", []string{"token", "missing"})
+	if !reflect.DeepEqual(results, []int{FIELD_TTL_SET, FIELD_MISSING}) {
+		t.Errorf("Unexpected ExpireFields result %v", results)
+	}
+	if ttls, codes := hashes.FieldTTL("session", []string{"token", "user"}); ttls[0] <= 0 || !reflect.DeepEqual(codes, []int{FIELD_TTL_SET, FIELD_NO_TTL}) {
+		t.Errorf("Unexpected FieldTTL result %v %v", ttls, codes)
+	}
+	time.Sleep(40 * time.Millisecond)
+	if _, exists := hashes.Get("session", "token"); exists {
+		t.Errorf("Field should be expired after its deadline")
+	}
+	if hashes.Len("session") != 1 {
+		t.Errorf("Expected 1 field but got %v", hashes.Len("session"))
+	}
+
+	hashes.ExpireFields("session", time.Now().Add(10*time.Millisecond), "", []string{"user"})
+	time.Sleep(30 * time.Millisecond)
+	if hashes.Persist("session") != 0 {
+		t.Errorf("Hash should be removed once its last field expires")
+	}
+}
+
+func TestOnFieldsExpired(t *testing.T) {
+	hashes := Create()
+	expired := make(chan bool, 2)
+	hashes.OnFieldsExpired(func(key string, keyRemoved bool) {
+		if key != "session" {
+			t.Errorf("Unexpected key " + key)
+		}
+		expired <- keyRemoved
+	})
+	hashes.Set("session", [][2]string{{"token", "abc"}, {"user", "alice"}})
+	hashes.ExpireFields("session", time.Now().Add(10*time.Millisecond), "", []string{"token"})
+	if keyRemoved := <-expired; keyRemoved {
+		t.Errorf("The hash should be kept while it has fields")
+	}
+	hashes.ExpireFields("session", time.Now().Add(10*time.Millisecond), "", []string{"user"})
+	if keyRemoved := <-expired; !keyRemoved {
+		t.Errorf("The hash should be removed with its last field")
+	}
+}
+
+func TestPersistFields(t *testing.T) {
+	hashes := Create()
+	hashes.Set("h", [][2]string{{"a", "1"}, {"b", "2"}})
+	hashes.ExpireFields("h", time.Now().Add(20*time.Millisecond), "", []string{"a"})
+	results := hashes.PersistFields("h", []string{"a", "b", "c"})
+	if !reflect.DeepEqual(results, []int{FIELD_TTL_SET, FIELD_NO_TTL, FIELD_MISSING}) {
+		t.Errorf("Unexpected PersistFields result %v", results)
+	}
+	time.Sleep(40 * time.Millisecond)
+	if value, exists := hashes.Get("h", "a"); !exists || value != "1" {
+		t.Errorf("Persisted field should not expire")
+	}
+}
+
+func TestFieldDeadlinesCountPassed(t *testing.T) {
+	var deadlines fieldDeadlines
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		deadlines.set(strconv.Itoa(i), now.Add(time.Duration(i-5)*time.Second))
+	}
+	// 0 to 5 passed
+	if passed := deadlines.countPassed(now); passed != 6 {
+		t.Errorf("Expected 6 passed deadlines but got %v", passed)
+	}
+	deadlines.set("0", now.Add(time.Minute))
+	deadlines.set("9", now.Add(-time.Minute))
+	deadlines.remove("1")
+	if passed := deadlines.countPassed(now); passed != 5 {
+		t.Errorf("Expected 5 passed deadlines but got %v", passed)
+	}
+	if _, hasTTL := deadlines.get("1"); hasTTL {
+		t.Errorf("Removed deadline should be gone")
+	}
+	if deadline, _ := deadlines.get("9"); !deadline.Equal(now.Add(-time.Minute)) {
+		t.Errorf("Expected the updated deadline but got %v", deadline)
+	}
+}
@@ -0,0 +1,431 @@
+package hashmap
+
+import (
+	"errors"
+	"github.com/thedeveloperr/redis-clone/keyspace"
+	"math"
+	"math/bits"
+)
+
+// Largest bit offset allowed, the last bit of a MAX_STRING_LENGTH string
+const MAX_BIT_OFFSET = MAX_STRING_LENGTH*8 - 1
+
+var ErrBitOffset = errors.New("ERR bit offset is not an integer or out of range")
+
+// Bytes of the string at key, without copying them if a bit command already
+// stored it as []byte. They mustn't be modified.
+// Caller must hold the lock of the shard.
+func getBitsUnsafe(s *keyspace.Shard, key string) ([]byte, bool) {
+	entry, exists := s.Get(key)
+	if !exists {
+		return nil, false
+	}
+	switch value := entry.Value.(type) {
+	case []byte:
+		return value, true
+	case string:
+		return []byte(value), true
+	}
+	return nil, false
+}
+
+// Entry of the string at key, its value stored as []byte the first time so
+// bit commands can change it in place.
+// Caller must hold the write lock of the shard.
+func getBitsForWriteUnsafe(s *keyspace.Shard, key string) (*keyspace.Entry, []byte, bool) {
+	entry, exists := s.Get(key)
+	if !exists {
+		return nil, nil, false
+	}
+	switch value := entry.Value.(type) {
+	case []byte:
+		return entry, value, true
+	case string:
+		entry.Value = []byte(value)
+		return entry, entry.Value.([]byte), true
+	}
+	return nil, nil, false
+}
+
+func (c *ConcurrentMap) getBits(key string) ([]byte, bool) {
+	s := c.keyspace.Shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	return getBitsUnsafe(s, key)
+}
+
+// Reads bit at offset of value. Bit 0 is the most significant bit of the first byte.
+func getBit(value []byte, offset uint64) int {
+	byteIndex := offset / 8
+	if byteIndex >= uint64(len(value)) {
+		return 0
+	}
+	return int(value[byteIndex]>>(7-offset%8)) & 1
+}
+
+// Returns value grown with zero bytes to at least size bytes. Like append the
+// capacity grows geometrically, so setting bits one after another past the
+// end doesn't copy the value each time.
+func growTo(value []byte, size uint64) []byte {
+	if uint64(len(value)) >= size {
+		return value
+	}
+	return append(value, make([]byte, size-uint64(len(value)))...)
+}
+
+// Sets or clears the bit at offset, growing the string if needed. Returns the old bit.
+func (c *ConcurrentMap) SetBit(key string, offset uint64, bit int) (int, error) {
+	if offset > MAX_BIT_OFFSET {
+		return 0, ErrBitOffset
+	}
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	entry, buf, exists := getBitsForWriteUnsafe(s, key)
+	if !exists {
+		entry = s.Set(key, []byte(nil))
+	}
+	buf = growTo(buf, offset/8+1)
+	old := getBit(buf, offset)
+	mask := byte(1) << (7 - offset%8)
+	if bit == 1 {
+		buf[offset/8] |= mask
+	} else {
+		buf[offset/8] &^= mask
+	}
+	entry.Value = buf
+	return old, nil
+}
+
+func (c *ConcurrentMap) GetBit(key string, offset uint64) int {
+	value, _ := c.getBits(key)
+	return getBit(value, offset)
+}
+
+// Resolves a BITCOUNT/BITPOS range over length units (bytes or bits).
+// ok is false if the range is empty.
+func bitRange(start int64, end int64, length int64) (int64, int64, bool) {
+	if start < 0 {
+		start += length
+	}
+	if end < 0 {
+		end += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end < 0 {
+		end = 0
+	}
+	if end >= length {
+		end = length - 1
+	}
+	if start > end || length == 0 {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// Number of set bits of value between bit offsets start and end inclusive
+func countBits(value []byte, start int64, end int64) int64 {
+	var count int64 = 0
+	for i := start / 8; i <= end/8; i++ {
+		count += int64(bits.OnesCount8(value[i]))
+	}
+	// remove bits of the first and last byte outside the range
+	if skip := start % 8; skip > 0 {
+		count -= int64(bits.OnesCount8(value[start/8] >> (8 - skip)))
+	}
+	if skip := 7 - end%8; skip > 0 {
+		count -= int64(bits.OnesCount8(value[end/8] & (1<<skip - 1)))
+	}
+	return count
+}
+
+// Counts set bits. If hasRange is false the whole string is counted, otherwise
+// start and end are byte offsets, or bit offsets when useBit is true.
+func (c *ConcurrentMap) BitCount(key string, start int64, end int64, hasRange bool, useBit bool) int64 {
+	value, _ := c.getBits(key)
+	length := int64(len(value))
+	if !hasRange {
+		start, end = 0, -1
+	}
+	if useBit {
+		bitStart, bitEnd, ok := bitRange(start, end, length*8)
+		if !ok {
+			return 0
+		}
+		return countBits(value, bitStart, bitEnd)
+	}
+	byteStart, byteEnd, ok := bitRange(start, end, length)
+	if !ok {
+		return 0
+	}
+	return countBits(value, byteStart*8, byteEnd*8+7)
+}
+
+// Position of the first bit set to bit. start and end work like BitCount.
+// When looking for a clear bit without an explicit end the string is
+// considered padded with zeros on the right, like redis does.
+func (c *ConcurrentMap) BitPos(key string, bit int, start int64, end int64, hasStart bool, hasEnd bool, useBit bool) int64 {
+	value, exists := c.getBits(key)
+	if !exists {
+		if bit == 1 {
+			return -1
+		}
+		return 0
+	}
+	length := int64(len(value))
+	if !hasStart {
+		start = 0
+	}
+	if !hasEnd {
+		end = -1
+	}
+	var bitStart, bitEnd int64
+	if useBit {
+		var ok bool
+		if bitStart, bitEnd, ok = bitRange(start, end, length*8); !ok {
+			return -1
+		}
+	} else {
+		byteStart, byteEnd, ok := bitRange(start, end, length)
+		if !ok {
+			return -1
+		}
+		bitStart, bitEnd = byteStart*8, byteEnd*8+7
+	}
+	for i := bitStart; i <= bitEnd; i++ {
+		// skip whole bytes which can't contain the bit
+		if i%8 == 0 && i+7 <= bitEnd {
+			b := value[i/8]
+			if (bit == 1 && b == 0) || (bit == 0 && b == 0xff) {
+				i += 7
+				continue
+			}
+		}
+		if getBit(value, uint64(i)) == bit {
+			return i
+		}
+	}
+	if bit == 0 && !hasEnd {
+		return bitEnd + 1
+	}
+	return -1
+}
+
+// Stores the result of AND, OR, XOR or NOT of the source keys in dest and
+// returns its length. Missing keys count as strings of zero bytes, shorter
+// strings are zero padded. An empty result removes dest.
+func (c *ConcurrentMap) BitOp(operation string, dest string, keys []string) int {
+	unlock := c.keyspace.LockShards(append([]string{dest}, keys...), true)
+	defer unlock()
+	var values [][]byte
+	maxLength := 0
+	for _, key := range keys {
+		value, _ := getBitsUnsafe(c.keyspace.Shard(key), key)
+		values = append(values, value)
+		if len(value) > maxLength {
+			maxLength = len(value)
+		}
+	}
+	result := make([]byte, maxLength)
+	for i := 0; i < maxLength; i++ {
+		var b byte
+		for j, value := range values {
+			var current byte
+			if i < len(value) {
+				current = value[i]
+			}
+			if j == 0 {
+				b = current
+				continue
+			}
+			switch operation {
+			case "AND":
+				b &= current
+			case "OR":
+				b |= current
+			case "XOR":
+				b ^= current
+			}
+		}
+		if operation == "NOT" {
+			b = ^b
+		}
+		result[i] = b
+	}
+	destShard := c.keyspace.Shard(dest)
+	if maxLength == 0 {
+		destShard.Delete(dest)
+		return 0
+	}
+	destShard.Set(dest, result)
+	return maxLength
+}
+
+// One GET, SET or INCRBY sub command of BITFIELD
+type BitfieldOp struct {
+	Kind     string // GET, SET or INCRBY
+	Signed   bool
+	Bits     uint   // 1 to 64 for signed, 1 to 63 for unsigned integers
+	Offset   uint64 // bit offset
+	Value    int64  // new value for SET, increment for INCRBY
+	Overflow string // WRAP, SAT or FAIL, used by SET and INCRBY
+}
+
+// Reads an integer of width bits starting at bit offset
+func readBitfield(buf []byte, offset uint64, width uint, signed bool) int64 {
+	var value uint64 = 0
+	for i := uint64(0); i < uint64(width); i++ {
+		byteIndex := (offset + i) / 8
+		var bit uint64 = 0
+		if byteIndex < uint64(len(buf)) {
+			bit = uint64(buf[byteIndex]>>(7-(offset+i)%8)) & 1
+		}
+		value = value<<1 | bit
+	}
+	if signed && width < 64 && value&(1<<(width-1)) != 0 {
+		// sign extend
+		value |= math.MaxUint64 << width
+	}
+	return int64(value)
+}
+
+// Writes the low width bits of value at bit offset, buf must be large enough
+func writeBitfield(buf []byte, offset uint64, width uint, value uint64) {
+	for i := uint64(0); i < uint64(width); i++ {
+		bit := (value >> (uint64(width) - 1 - i)) & 1
+		position := offset + i
+		mask := byte(1) << (7 - position%8)
+		if bit == 1 {
+			buf[position/8] |= mask
+		} else {
+			buf[position/8] &^= mask
+		}
+	}
+}
+
+// Applies value + incr to an integer of given width following the overflow
+// policy. ok is false if policy is FAIL and the result doesn't fit.
+func applyOverflow(value int64, incr int64, width uint, signed bool, policy string) (result int64, ok bool) {
+	if signed {
+		var max int64 = math.MaxInt64
+		if width < 64 {
+			max = 1<<(width-1) - 1
+		}
+		min := -max - 1
+		overflow := (incr > 0 && value > max-incr) || value > max
+		underflow := (incr < 0 && value < min-incr) || value < min
+		if !overflow && !underflow {
+			return value + incr, true
+		}
+		switch policy {
+		case "SAT":
+			if overflow {
+				return max, true
+			}
+			return min, true
+		case "FAIL":
+			return 0, false
+		}
+		// WRAP, two's complement of the low width bits
+		wrapped := uint64(value) + uint64(incr)
+		if width < 64 {
+			if wrapped&(1<<(width-1)) != 0 {
+				wrapped |= math.MaxUint64 << width
+			} else {
+				wrapped &^= math.MaxUint64 << width
+			}
+		}
+		return int64(wrapped), true
+	}
+
+	max := int64(1)<<width - 1
+	// negative values given to SET are out of range too
+	overflow := value < 0 || value > max || (incr > 0 && incr > max-value)
+	underflow := incr < 0 && incr < -value
+	if !overflow && !underflow {
+		return value + incr, true
+	}
+	switch policy {
+	case "SAT":
+		if overflow {
+			return max, true
+		}
+		return 0, true
+	case "FAIL":
+		return 0, false
+	}
+	return int64((uint64(value) + uint64(incr)) &^ (math.MaxUint64 << width)), true
+}
+
+// Runs BITFIELD sub commands in order on key. For SET the old value is
+// returned, for GET and INCRBY the current one. ok[i] is false when an
+// operation was skipped because of OVERFLOW FAIL.
+func (c *ConcurrentMap) BitField(key string, ops []BitfieldOp) (results []int64, ok []bool, err error) {
+	writes := false
+	for _, op := range ops {
+		if op.Offset+uint64(op.Bits)-1 > MAX_BIT_OFFSET {
+			return nil, nil, ErrBitOffset
+		}
+		if op.Kind != "GET" {
+			writes = true
+		}
+	}
+	s := c.keyspace.Shard(key)
+	if writes {
+		s.Lock()
+		defer s.Unlock()
+	} else {
+		s.RLock()
+		defer s.RUnlock()
+	}
+	var entry *keyspace.Entry
+	var buf []byte
+	var exists bool
+	if writes {
+		entry, buf, exists = getBitsForWriteUnsafe(s, key)
+	} else {
+		buf, exists = getBitsUnsafe(s, key)
+	}
+	changed := false
+	for _, op := range ops {
+		current := readBitfield(buf, op.Offset, op.Bits, op.Signed)
+		switch op.Kind {
+		case "GET":
+			results = append(results, current)
+			ok = append(ok, true)
+		case "SET", "INCRBY":
+			var newValue int64
+			var fits bool
+			if op.Kind == "SET" {
+				newValue, fits = applyOverflow(op.Value, 0, op.Bits, op.Signed, op.Overflow)
+			} else {
+				newValue, fits = applyOverflow(current, op.Value, op.Bits, op.Signed, op.Overflow)
+			}
+			if !fits {
+				results = append(results, 0)
+				ok = append(ok, false)
+				continue
+			}
+			buf = growTo(buf, (op.Offset+uint64(op.Bits)+7)/8)
+			writeBitfield(buf, op.Offset, op.Bits, uint64(newValue))
+			changed = true
+			if op.Kind == "SET" {
+				results = append(results, current)
+			} else {
+				results = append(results, newValue)
+			}
+			ok = append(ok, true)
+		}
+	}
+	if changed {
+		if exists {
+			entry.Value = buf
+		} else {
+			s.Set(key, buf)
+		}
+	}
+	return results, ok, nil
+}
@@ -0,0 +1,170 @@
+package hashmap
+
+import (
+	"testing"
+)
+
+func TestSetBitGetBit(t *testing.T) {
+	hashMap := Create()
+	if old, _ := hashMap.SetBit("bitmap", 7, 1); old != 0 {
+		t.Errorf("Expected old bit 0 but got %v", old)
+	}
+	if old, _ := hashMap.SetBit("bitmap", 7, 0); old != 1 {
+		t.Errorf("Expected old bit 1 but got %v", old)
+	}
+	hashMap.SetBit("bitmap", 1, 1)
+	hashMap.SetBit("bitmap", 20, 1)
+	if value, _ := hashMap.Get("bitmap"); value != "\x40\x00\x08" {
+		t.Errorf("Expected \\x40\\x00\\x08 but got %q", value)
+	}
+	if bit := hashMap.GetBit("bitmap", 1); bit != 1 {
+		t.Errorf("Expected bit 1 at offset 1 but got %v", bit)
+	}
+	if bit := hashMap.GetBit("bitmap", 1000); bit != 0 {
+		t.Errorf("Expected bit 0 past end but got %v", bit)
+	}
+	if _, err := hashMap.SetBit("bitmap", MAX_BIT_OFFSET+1, 1); err != ErrBitOffset {
+		t.Errorf("Expected offset error but got %v", err)
+	}
+}
+
+func TestBitCount(t *testing.T) {
+	hashMap := Create()
+	hashMap.Set("key", "foobar")
+	cases := []struct {
+		start, end       int64
+		hasRange, useBit bool
+		expected         int64
+	}{
+		{0, 0, false, false, 26},
+		{0, 0, true, false, 4},
+		{1, 1, true, false, 6},
+		{-2, -1, true, false, 7},
+		{5, 30, true, true, 17},
+		{10, 5, true, false, 0},
+	}
+	for _, c := range cases {
+		if count := hashMap.BitCount("key", c.start, c.end, c.hasRange, c.useBit); count != c.expected {
+			t.Errorf("BitCount %v %v bit:%v expected %v but got %v", c.start, c.end, c.useBit, c.expected, count)
+		}
+	}
+	if count := hashMap.BitCount("missing", 0, -1, false, false); count != 0 {
+		t.Errorf("Expected 0 for missing key but got %v", count)
+	}
+}
+
+func TestBitPos(t *testing.T) {
+	hashMap := Create()
+	hashMap.Set("key", "\xff\xf0\x00")
+	cases := []struct {
+		bit                      int
+		start, end               int64
+		hasStart, hasEnd, useBit bool
+		expected                 int64
+	}{
+		{0, 0, 0, false, false, false, 12},
+		{1, 2, 0, true, false, false, -1},
+		{1, 0, 0, false, false, false, 0},
+		{0, 2, -1, true, true, false, 16},
+		{1, 7, 15, true, true, true, 7},
+		{0, 7, 15, true, true, true, 12},
+	}
+	for _, c := range cases {
+		pos := hashMap.BitPos("key", c.bit, c.start, c.end, c.hasStart, c.hasEnd, c.useBit)
+		if pos != c.expected {
+			t.Errorf("BitPos %v %v %v expected %v but got %v", c.bit, c.start, c.end, c.expected, pos)
+		}
+	}
+
+	hashMap.Set("ones", "\xff\xff")
+	if pos := hashMap.BitPos("ones", 0, 0, 0, false, false, false); pos != 16 {
+		t.Errorf("Expected first clear bit right after the string but got %v", pos)
+	}
+	if pos := hashMap.BitPos("ones", 0, 0, -1, true, true, false); pos != -1 {
+		t.Errorf("Expected -1 with explicit end but got %v", pos)
+	}
+	if pos := hashMap.BitPos("missing", 0, 0, 0, false, false, false); pos != 0 {
+		t.Errorf("Expected 0 for missing key but got %v", pos)
+	}
+}
+
+func TestBitOp(t *testing.T) {
+	hashMap := Create()
+	hashMap.Set("a", "\x0f\xff")
+	hashMap.Set("b", "\xf0")
+	cases := []struct {
+		operation string
+		keys      []string
+		expected  string
+	}{
+		{"AND", []string{"a", "b"}, "\x00\x00"},
+		{"OR", []string{"a", "b"}, "\xff\xff"},
+		{"XOR", []string{"a", "b", "b"}, "\x0f\xff"},
+		{"NOT", []string{"b"}, "\x0f"},
+	}
+	for _, c := range cases {
+		length := hashMap.BitOp(c.operation, "dest", c.keys)
+		value, _ := hashMap.Get("dest")
+		if value != c.expected || length != len(c.expected) {
+			t.Errorf("BitOp %v expected %q but got %q with length %v", c.operation, c.expected, value, length)
+		}
+	}
+	if length := hashMap.BitOp("OR", "dest", []string{"missing"}); length != 0 {
+		t.Errorf("Expected 0 length but got %v", length)
+	}
+	if _, exists := hashMap.Get("dest"); exists {
+		t.Errorf("Empty BitOp result should remove dest")
+	}
+}
+
+func TestBitField(t *testing.T) {
+	hashMap := Create()
+	results, ok, _ := hashMap.BitField("key", []BitfieldOp{
+		{Kind: "SET", Bits: 8, Offset: 0, Value: 255, Overflow: "WRAP"},
+		{Kind: "GET", Bits: 8, Offset: 0},
+		{Kind: "GET", Signed: true, Bits: 8, Offset: 0},
+		{Kind: "INCRBY", Bits: 8, Offset: 0, Value: 10, Overflow: "WRAP"},
+		{Kind: "INCRBY", Bits: 8, Offset: 0, Value: 300, Overflow: "SAT"},
+		{Kind: "INCRBY", Bits: 8, Offset: 0, Value: 1, Overflow: "FAIL"},
+		{Kind: "INCRBY", Signed: true, Bits: 5, Offset: 100, Value: 20, Overflow: "WRAP"},
+		{Kind: "INCRBY", Signed: true, Bits: 5, Offset: 100, Value: -100, Overflow: "SAT"},
+		{Kind: "SET", Bits: 4, Offset: 8, Value: -1, Overflow: "SAT"},
+	})
+	expected := []int64{0, 255, -1, 9, 255, 0, -12, -16, 0}
+	expectedOk := []bool{true, true, true, true, true, false, true, true, true}
+	for i := range expected {
+		if results[i] != expected[i] || ok[i] != expectedOk[i] {
+			t.Errorf("Op %v expected %v (ok %v) but got %v (ok %v)", i, expected[i], expectedOk[i], results[i], ok[i])
+		}
+	}
+	if value, _ := hashMap.Get("key"); value[:2] != "\xff\xf0" {
+		t.Errorf("Expected value to start with \\xff\\xf0 but got %q", value)
+	}
+
+	if _, exists := hashMap.Get("readonly"); exists {
+		t.Errorf("Key should not exist")
+	}
+	hashMap.BitField("readonly", []BitfieldOp{{Kind: "GET", Bits: 8, Offset: 0}})
+	if _, exists := hashMap.Get("readonly"); exists {
+		t.Errorf("BITFIELD with only GET should not create the key")
+	}
+}
+
+func TestSetBitChangesValueInPlace(t *testing.T) {
+	hashMap := Create()
+	hashMap.Set("bitmap", "\x00\x00")
+	hashMap.SetBit("bitmap", 0, 1)
+	before, _ := hashMap.getBits("bitmap")
+	hashMap.SetBit("bitmap", 15, 1)
+	hashMap.BitField("bitmap", []BitfieldOp{{Kind: "SET", Bits: 4, Offset: 4, Value: 15, Overflow: "WRAP"}})
+	after, _ := hashMap.getBits("bitmap")
+	if &before[0] != &after[0] {
+		t.Errorf("Expected SETBIT and BITFIELD to change the stored bytes in place")
+	}
+	if value, _ := hashMap.Get("bitmap"); value != "\x8f\x01" {
+		t.Errorf("Expected \\x8f\\x01 but got %q", value)
+	}
+	if keys := hashMap.Keys(); len(keys) != 1 {
+		t.Errorf("Expected the bitmap to still be a string key but got %v", keys)
+	}
+}
@@ -1,80 +1,345 @@
 package hashmap
 
 import (
-	"sync"
+	"errors"
+	"github.com/thedeveloperr/redis-clone/keyspace"
+	"math"
+	"strconv"
 	"time"
 )
 
+// Largest string value allowed, same as redis's proto-max-bulk-len default
+const MAX_STRING_LENGTH = 512 * 1024 * 1024
+
+var ErrNotInteger = errors.New("ERR value is not an integer or out of range")
+var ErrNotFloat = errors.New("ERR value is not a valid float")
+var ErrOverflow = errors.New("ERR increment or decrement would overflow")
+var ErrNaNOrInfinity = errors.New("ERR increment would produce NaN or Infinity")
+var ErrMaxLength = errors.New("ERR string exceeds maximum allowed size (proto-max-bulk-len)")
+
 type HashMap interface {
 	Set(key string, value string)
 	Get(key string) (string, bool)
 	Expire(key string, timeoutSeconds int) int
 }
 
-type Value struct {
-	value        string
-	setAt        time.Time
-	expireAfter  time.Duration
-	shouldExpire bool
-}
-
 type ConcurrentMap struct {
-	mutex sync.RWMutex
-	data  map[string]*Value
+	keyspace *keyspace.Keyspace
 }
 
+// String values stored in a keyspace of their own
 func Create() *ConcurrentMap {
-	hashmap := ConcurrentMap{
-		data: make(map[string]*Value),
+	return CreateInKeyspace(keyspace.New())
+}
+
+// String values stored in keyspace, along with the values of other types
+func CreateInKeyspace(keyspace *keyspace.Keyspace) *ConcurrentMap {
+	return &ConcurrentMap{keyspace: keyspace}
+}
+
+// Entry of the string at key and its value. Strings written by bit commands
+// are stored as []byte so they can be changed in place, and are copied here.
+// Caller must hold the lock of the shard.
+func getUnsafe(s *keyspace.Shard, key string) (*keyspace.Entry, string, bool) {
+	entry, exists := s.Get(key)
+	if !exists {
+		return nil, "", false
 	}
-	return &hashmap
+	switch value := entry.Value.(type) {
+	case string:
+		return entry, value, true
+	case []byte:
+		return entry, string(value), true
+	}
+	return entry, "", false
+}
+
+// Whether value, read from a keyspace, is a string
+func IsString(value interface{}) bool {
+	switch value.(type) {
+	case string, []byte:
+		return true
+	}
+	return false
+}
+
+// Keys which exist, in no particular order
+func (c *ConcurrentMap) Keys() []string {
+	return c.keyspace.Keys(IsString)
 }
 
 func (c *ConcurrentMap) Set(key string, value string) {
-	c.mutex.Lock()
-	c.data[key] = &Value{
-		value:        value,
-		setAt:        time.Now(),
-		expireAfter:  0,
-		shouldExpire: false,
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	s.Set(key, value)
+	s.Unlock()
+}
+
+// Sets key to value like Set, keeping the deadline of the value it replaces
+func (c *ConcurrentMap) SetKeepTTL(key string, value string) {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	if entry, exists := s.Get(key); exists {
+		entry.Value = value
+		return
 	}
-	c.mutex.Unlock()
+	s.Set(key, value)
 }
 
 func (c *ConcurrentMap) Get(key string) (string, bool) {
-	c.mutex.RLock()
-	valueItem, exists := c.data[key]
+	s := c.keyspace.Shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	_, value, exists := getUnsafe(s, key)
+	return value, exists
+}
+
+func (c *ConcurrentMap) Expire(key string, timeoutSeconds int) int {
+	return c.ExpireAt(key, time.Now().Add(time.Duration(timeoutSeconds)*time.Second))
+}
+
+// Expire key at an absolute deadline. A deadline in the past removes the key right away.
+func (c *ConcurrentMap) ExpireAt(key string, deadline time.Time) int {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	if _, _, exists := getUnsafe(s, key); !exists || !s.ExpireAt(key, deadline) {
+		return 0
+	}
+	return 1
+}
+
+// Remove timeout of key. Returns 1 if a timeout was removed
+func (c *ConcurrentMap) Persist(key string) int {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	if _, _, exists := getUnsafe(s, key); !exists || !s.Persist(key) {
+		return 0
+	}
+	return 1
+}
+
+// Adds delta to integer stored at key, missing key counts as 0. TTL is kept.
+func (c *ConcurrentMap) IncrBy(key string, delta int64) (int64, error) {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	entry, value, exists := getUnsafe(s, key)
+	var current int64 = 0
+	if exists {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, ErrNotInteger
+		}
+		current = parsed
+	}
+	if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+		return 0, ErrOverflow
+	}
+	result := current + delta
+	if exists {
+		entry.Value = strconv.FormatInt(result, 10)
+	} else {
+		s.Set(key, strconv.FormatInt(result, 10))
+	}
+	return result, nil
+}
+
+// Adds delta to float stored at key, missing key counts as 0. TTL is kept.
+func (c *ConcurrentMap) IncrByFloat(key string, delta float64) (float64, error) {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	entry, value, exists := getUnsafe(s, key)
+	current := 0.0
+	if exists {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+			return 0, ErrNotFloat
+		}
+		current = parsed
+	}
+	result := current + delta
+	if math.IsNaN(result) || math.IsInf(result, 0) {
+		return 0, ErrNaNOrInfinity
+	}
+	if exists {
+		entry.Value = FormatFloat(result)
+	} else {
+		s.Set(key, FormatFloat(result))
+	}
+	return result, nil
+}
+
+// Shortest representation that parses back to the same float, eg. 10.5 or 3
+func FormatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// Appends to the value at key, creating it if missing. Returns the new length.
+func (c *ConcurrentMap) Append(key string, value string) (int, error) {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	entry, current, exists := getUnsafe(s, key)
+	if !exists {
+		if len(value) > MAX_STRING_LENGTH {
+			return 0, ErrMaxLength
+		}
+		s.Set(key, value)
+		return len(value), nil
+	}
+	if len(current)+len(value) > MAX_STRING_LENGTH {
+		return 0, ErrMaxLength
+	}
+	entry.Value = current + value
+	return len(current) + len(value), nil
+}
+
+func (c *ConcurrentMap) Strlen(key string) int {
+	value, _ := c.Get(key)
+	return len(value)
+}
+
+// Substring between start and end offsets, both inclusive. Negative offsets
+// count from the end of the string.
+func (c *ConcurrentMap) GetRange(key string, start int64, end int64) string {
+	value, _ := c.Get(key)
+	length := int64(len(value))
+	if start < 0 {
+		start += length
+	}
+	if end < 0 {
+		end += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end < 0 {
+		end = 0
+	}
+	if end >= length {
+		end = length - 1
+	}
+	if start > end || length == 0 {
+		return ""
+	}
+	return value[start : end+1]
+}
+
+// Overwrites value at key starting from offset, padding with zero bytes if
+// the string is shorter than offset. Returns the new length.
+func (c *ConcurrentMap) SetRange(key string, offset int64, value string) (int, error) {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	entry, current, exists := getUnsafe(s, key)
+	if len(value) == 0 {
+		return len(current), nil
+	}
+	if offset+int64(len(value)) > MAX_STRING_LENGTH {
+		return 0, ErrMaxLength
+	}
+	end := int(offset) + len(value)
+	size := len(current)
+	if end > size {
+		size = end
+	}
+	buf := make([]byte, size)
+	copy(buf, current)
+	copy(buf[offset:], value)
+	if exists {
+		entry.Value = string(buf)
+	} else {
+		s.Set(key, string(buf))
+	}
+	return size, nil
+}
+
+// Sets value and returns the old one. Like Set it clears any timeout.
+func (c *ConcurrentMap) GetSet(key string, value string) (string, bool) {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	_, old, exists := getUnsafe(s, key)
+	s.Set(key, value)
+	return old, exists
+}
+
+// Removes key and returns the value it had
+func (c *ConcurrentMap) GetDel(key string) (string, bool) {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	_, value, exists := getUnsafe(s, key)
 	if !exists {
-		c.mutex.RUnlock()
 		return "", false
 	}
+	s.Delete(key)
+	return value, true
+}
 
-	// To improve accuracy of EXPIRE, in case time.AfterFunc runs later and a get call is made earlier
-	if valueItem.shouldExpire && time.Now().Sub(valueItem.setAt) > valueItem.expireAfter {
-		c.mutex.RUnlock()
+// Returns value of key and either sets a new deadline, removes the timeout if
+// persist is true, or leaves it as is when deadline is zero.
+func (c *ConcurrentMap) GetEx(key string, deadline time.Time, persist bool) (string, bool) {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	_, value, exists := getUnsafe(s, key)
+	if !exists {
 		return "", false
 	}
-	c.mutex.RUnlock()
-	return valueItem.value, exists
+	if persist {
+		s.Persist(key)
+	} else if !deadline.IsZero() {
+		s.ExpireAt(key, deadline)
+	}
+	return value, true
 }
 
-func (c *ConcurrentMap) Expire(key string, timeoutSeconds int) int {
-	if val, ok := c.Get(key); !ok {
-		_ = val
-		return 0
+// Values of all keys read at one point in time. exists[i] is false for missing keys.
+func (c *ConcurrentMap) MGet(keys []string) (values []string, exists []bool) {
+	unlock := c.keyspace.LockShards(keys, false)
+	defer unlock()
+	values = make([]string, len(keys))
+	exists = make([]bool, len(keys))
+	for i, key := range keys {
+		_, values[i], exists[i] = getUnsafe(c.keyspace.Shard(key), key)
+	}
+	return values, exists
+}
+
+// Sets every key value pair atomically, clearing any timeouts like Set
+func (c *ConcurrentMap) MSet(pairs [][2]string) {
+	keys := make([]string, len(pairs))
+	for i, pair := range pairs {
+		keys[i] = pair[0]
+	}
+	unlock := c.keyspace.LockShards(keys, true)
+	defer unlock()
+	for _, pair := range pairs {
+		c.keyspace.Shard(pair[0]).Set(pair[0], pair[1])
 	}
-	c.mutex.Lock()
-	c.data[key].shouldExpire = true
-	c.data[key].expireAfter = time.Duration(timeoutSeconds) * time.Second
-	c.mutex.Unlock()
-	time.AfterFunc(time.Duration(timeoutSeconds)*time.Second, func() {
-		c.mutex.Lock()
+}
 
-		// Check if any SET option cleared timeout
-		if c.data[key].shouldExpire {
-			delete(c.data, key)
+// Sets all pairs only if none of the keys exist. Returns true if they were set.
+func (c *ConcurrentMap) MSetNX(pairs [][2]string) bool {
+	keys := make([]string, len(pairs))
+	for i, pair := range pairs {
+		keys[i] = pair[0]
+	}
+	unlock := c.keyspace.LockShards(keys, true)
+	defer unlock()
+	// a key of any type counts
+	for _, key := range keys {
+		if _, exists := c.keyspace.Shard(key).Get(key); exists {
+			return false
 		}
-		c.mutex.Unlock()
-	})
-	return 1
+	}
+	for _, pair := range pairs {
+		c.keyspace.Shard(pair[0]).Set(pair[0], pair[1])
+	}
+	return true
 }
@@ -1,6 +1,9 @@
 package hashmap
 
 import (
+	"reflect"
+	"sort"
+	"strconv"
 	"testing"
 	"time"
 )
@@ -111,3 +114,250 @@ func TestSetGetExpire(t *testing.T) {
 	}
 	t.Log("End Running slow test as involve timeout in seconds")
 }
+
+// Run with `go test -bench Parallel -cpu 1,2,4,8 ./hashmap` to see
+// throughput scale with GOMAXPROCS now that keys are spread over shards.
+func BenchmarkParallelSetGet(b *testing.B) {
+	hashMap := Create()
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+		hashMap.Set(keys[i], "value")
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := keys[i%len(keys)]
+			if i%4 == 0 {
+				hashMap.Set(key, "value")
+			} else {
+				hashMap.Get(key)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkParallelSet(b *testing.B) {
+	hashMap := Create()
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			hashMap.Set(keys[i%len(keys)], "value")
+			i++
+		}
+	})
+}
+
+func TestIncrBy(t *testing.T) {
+	hashMap := Create()
+	result, err := hashMap.IncrBy("counter", 5)
+	if err != nil || result != 5 {
+		t.Errorf("Expected 5 but got %v, err: %v", result, err)
+	}
+	result, err = hashMap.IncrBy("counter", -7)
+	if err != nil || result != -2 {
+		t.Errorf("Expected -2 but got %v, err: %v", result, err)
+	}
+	if value, _ := hashMap.Get("counter"); value != "-2" {
+		t.Errorf("Expected stored value -2 but got %v", value)
+	}
+
+	hashMap.Set("max", "9223372036854775807")
+	if _, err := hashMap.IncrBy("max", 1); err != ErrOverflow {
+		t.Errorf("Expected overflow error but got %v", err)
+	}
+
+	hashMap.Set("text", "abc")
+	if _, err := hashMap.IncrBy("text", 1); err != ErrNotInteger {
+		t.Errorf("Expected not integer error but got %v", err)
+	}
+}
+
+func TestIncrByFloat(t *testing.T) {
+	hashMap := Create()
+	hashMap.Set("float", "10.5")
+	result, err := hashMap.IncrByFloat("float", 0.1)
+	if err != nil || result != 10.6 {
+		t.Errorf("Expected 10.6 but got %v, err: %v", result, err)
+	}
+	if value, _ := hashMap.Get("float"); value != "10.6" {
+		t.Errorf("Expected stored value 10.6 but got %v", value)
+	}
+	hashMap.Set("text", "abc")
+	if _, err := hashMap.IncrByFloat("text", 1); err != ErrNotFloat {
+		t.Errorf("Expected not float error but got %v", err)
+	}
+}
+
+func TestAppendStrlenGetRange(t *testing.T) {
+	hashMap := Create()
+	if length, _ := hashMap.Append("key", "Hello"); length != 5 {
+		t.Errorf("Expected length 5 but got %v", length)
+	}
+	if length, _ := hashMap.Append("key", " World"); length != 11 {
+		t.Errorf("Expected length 11 but got %v", length)
+	}
+	if length := hashMap.Strlen("key"); length != 11 {
+		t.Errorf("Expected length 11 but got %v", length)
+	}
+	if length := hashMap.Strlen("missing"); length != 0 {
+		t.Errorf("Expected length 0 but got %v", length)
+	}
+
+	ranges := []struct {
+		start, end int64
+		expected   string
+	}{
+		{0, 4, "Hello"},
+		{-5, -1, "World"},
+		{0, -1, "Hello World"},
+		{6, 100, "World"},
+		{5, 2, ""},
+		{-100, 1, "He"},
+	}
+	for _, r := range ranges {
+		if value := hashMap.GetRange("key", r.start, r.end); value != r.expected {
+			t.Errorf("GetRange %v %v expected '%v' but got '%v'", r.start, r.end, r.expected, value)
+		}
+	}
+}
+
+func TestSetRange(t *testing.T) {
+	hashMap := Create()
+	hashMap.Set("key", "Hello World")
+	if length, _ := hashMap.SetRange("key", 6, "Redis"); length != 11 {
+		t.Errorf("Expected length 11 but got %v", length)
+	}
+	if value, _ := hashMap.Get("key"); value != "Hello Redis" {
+		t.Errorf("Expected 'Hello Redis' but got %v", value)
+	}
+
+	if length, _ := hashMap.SetRange("padded", 3, "ab"); length != 5 {
+		t.Errorf("Expected length 5 but got %v", length)
+	}
+	if value, _ := hashMap.Get("padded"); value != "\x00\x00\x00ab" {
+		t.Errorf("Expected zero padded value but got %q", value)
+	}
+
+	if _, err := hashMap.SetRange("key", MAX_STRING_LENGTH, "a"); err != ErrMaxLength {
+		t.Errorf("Expected max length error but got %v", err)
+	}
+}
+
+func TestGetSetGetDelGetEx(t *testing.T) {
+	hashMap := Create()
+	if _, exists := hashMap.GetSet("key", "v1"); exists {
+		t.Errorf("GetSet on missing key should return not exists")
+	}
+	hashMap.Expire("key", 100)
+	if old, exists := hashMap.GetSet("key", "v2"); !exists || old != "v1" {
+		t.Errorf("Expected old value v1 but got %v", old)
+	}
+	if hashMap.Persist("key") != 0 {
+		t.Errorf("GetSet should have cleared the timeout")
+	}
+
+	if value, exists := hashMap.GetEx("key", time.Now().Add(-time.Second), false); !exists || value != "v2" {
+		t.Errorf("Expected v2 from GetEx but got %v", value)
+	}
+	if _, exists := hashMap.Get("key"); exists {
+		t.Errorf("GetEx with deadline in past should remove key")
+	}
+
+	hashMap.Set("key", "v3")
+	if value, exists := hashMap.GetDel("key"); !exists || value != "v3" {
+		t.Errorf("Expected v3 from GetDel but got %v", value)
+	}
+	if _, exists := hashMap.GetDel("key"); exists {
+		t.Errorf("GetDel should have removed key")
+	}
+}
+
+func TestExpireLongAfterSet(t *testing.T) {
+	hashMap := Create()
+	hashMap.Set("key", "value")
+	time.Sleep(20 * time.Millisecond)
+	hashMap.ExpireAt("key", time.Now().Add(10*time.Millisecond))
+	if _, exists := hashMap.Get("key"); !exists {
+		t.Errorf("Key should exist until its deadline")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, exists := hashMap.Get("key"); exists {
+		t.Errorf("Key should be expired after its deadline")
+	}
+}
+
+func TestMSetMGetMSetNX(t *testing.T) {
+	hashMap := Create()
+	hashMap.MSet([][2]string{{"k1", "v1"}, {"k2", "v2"}})
+	values, exists := hashMap.MGet([]string{"k1", "missing", "k2"})
+	if !exists[0] || values[0] != "v1" || exists[1] || !exists[2] || values[2] != "v2" {
+		t.Errorf("Unexpected MGet result %v %v", values, exists)
+	}
+
+	if hashMap.MSetNX([][2]string{{"k3", "v3"}, {"k1", "new"}}) {
+		t.Errorf("MSetNX should fail when any key exists")
+	}
+	if _, exists := hashMap.Get("k3"); exists {
+		t.Errorf("MSetNX should not set any key when it fails")
+	}
+	if !hashMap.MSetNX([][2]string{{"k3", "v3"}, {"k4", "v4"}}) {
+		t.Errorf("MSetNX should succeed when no key exists")
+	}
+	if value, _ := hashMap.Get("k4"); value != "v4" {
+		t.Errorf("Expected v4 but got %v", value)
+	}
+}
+
+// Readers using MGet must never see half of an MSet
+func TestMSetIsAtomic(t *testing.T) {
+	hashMap := Create()
+	keys := make([]string, 50)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+	}
+	done := make(chan bool)
+	go func() {
+		for round := 0; round < 200; round++ {
+			pairs := make([][2]string, len(keys))
+			for i, key := range keys {
+				pairs[i] = [2]string{key, strconv.Itoa(round)}
+			}
+			hashMap.MSet(pairs)
+		}
+		close(done)
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		values, _ := hashMap.MGet(keys)
+		for _, value := range values {
+			if value != values[0] {
+				t.Fatalf("MGet saw a partial MSet: %v", values)
+			}
+		}
+	}
+}
+
+func TestKeysSkipsExpired(t *testing.T) {
+	hashMap := Create()
+	hashMap.Set("a", "1")
+	hashMap.Set("b", "2")
+	hashMap.Set("gone", "3")
+	hashMap.ExpireAt("gone", time.Now().Add(-time.Second))
+	keys := hashMap.Keys()
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, []string{"a", "b"}) {
+		t.Errorf("Expected keys [a b] but got %v", keys)
+	}
+}
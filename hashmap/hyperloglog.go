@@ -0,0 +1,443 @@
+package hashmap
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+	"sync/atomic"
+)
+
+// HyperLogLog values use the same byte layout as redis so they can be copied
+// between both with GET and SET. A value is a 16 byte header followed by 16384
+// 6 bit registers, either packed (dense) or run length encoded (sparse).
+//
+// Header: "HYLL", encoding byte, 3 unused bytes, cached cardinality as 8 little
+// endian bytes whose most significant bit is set when the cache is stale.
+const (
+	HLL_P            = 14
+	HLL_Q            = 64 - HLL_P
+	HLL_REGISTERS    = 1 << HLL_P
+	HLL_BITS         = 6
+	HLL_REGISTER_MAX = 1<<HLL_BITS - 1
+	HLL_HDR_SIZE     = 16
+	HLL_DENSE_SIZE   = HLL_HDR_SIZE + (HLL_REGISTERS*HLL_BITS+7)/8
+	HLL_DENSE        = 0
+	HLL_SPARSE       = 1
+)
+
+// Sparse opcodes: ZERO 00xxxxxx is a run of 1 to 64 zero registers, XZERO
+// 01xxxxxx yyyyyyyy a run of 1 to 16384 zero registers and VAL 1vvvvvxx a run
+// of 1 to 4 registers set to 1 to 32.
+const (
+	hllSparseZeroMaxLen  = 64
+	hllSparseXZeroMaxLen = 16384
+	hllSparseValMaxValue = 32
+	hllSparseValMaxLen   = 4
+)
+
+var ErrNotHyperLogLog = errors.New("WRONGTYPE Key is not a valid HyperLogLog string value.")
+var ErrCorruptedHyperLogLog = errors.New("INVALIDOBJ Corrupted HLL object detected")
+
+// Sparse values growing past this many bytes are converted to dense, like
+// redis's hll-sparse-max-bytes
+var hllSparseMaxBytes int64 = 3000
+
+func SetHLLSparseMaxBytes(maxBytes int) {
+	atomic.StoreInt64(&hllSparseMaxBytes, int64(maxBytes))
+}
+
+func GetHLLSparseMaxBytes() int {
+	return int(atomic.LoadInt64(&hllSparseMaxBytes))
+}
+
+// MurmurHash2 64 bit variant by Austin Appleby, the hash redis uses for HyperLogLog elements
+func murmurHash64A(data string, seed uint64) uint64 {
+	const m uint64 = 0xc6a4a7935bd1e995
+	const r = 47
+	h := seed ^ (uint64(len(data)) * m)
+	i := 0
+	for ; i+8 <= len(data); i += 8 {
+		k := uint64(data[i]) | uint64(data[i+1])<<8 | uint64(data[i+2])<<16 | uint64(data[i+3])<<24 |
+			uint64(data[i+4])<<32 | uint64(data[i+5])<<40 | uint64(data[i+6])<<48 | uint64(data[i+7])<<56
+		k *= m
+		k ^= k >> r
+		k *= m
+		h ^= k
+		h *= m
+	}
+	if tail := data[i:]; len(tail) > 0 {
+		for j := len(tail) - 1; j >= 0; j-- {
+			h ^= uint64(tail[j]) << (8 * uint(j))
+		}
+		h *= m
+	}
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
+
+// Register an element maps to and the length of the run of zero bits after
+// the register index plus one, which is the value the register should hold
+func hllPatternLength(element string) (index int, count uint8) {
+	hash := murmurHash64A(element, 0xadc83b19)
+	index = int(hash & (HLL_REGISTERS - 1))
+	hash >>= HLL_P
+	// make sure a run ends within the remaining bits
+	hash |= 1 << HLL_Q
+	return index, uint8(bits.TrailingZeros64(hash) + 1)
+}
+
+func getDenseRegister(registers []byte, index int) uint8 {
+	bit := index * HLL_BITS
+	b0, shift := bit/8, uint(bit%8)
+	value := uint(registers[b0]) >> shift
+	if b0+1 < len(registers) {
+		value |= uint(registers[b0+1]) << (8 - shift)
+	}
+	return uint8(value & HLL_REGISTER_MAX)
+}
+
+func setDenseRegister(registers []byte, index int, value uint8) {
+	bit := index * HLL_BITS
+	b0, shift := bit/8, uint(bit%8)
+	registers[b0] &^= byte(HLL_REGISTER_MAX << shift)
+	registers[b0] |= byte(uint(value) << shift)
+	if b0+1 < len(registers) {
+		registers[b0+1] &^= byte(HLL_REGISTER_MAX >> (8 - shift))
+		registers[b0+1] |= byte(uint(value) >> (8 - shift))
+	}
+}
+
+// Checks the header of a HyperLogLog value
+func validateHLL(value string) error {
+	if len(value) < HLL_HDR_SIZE || value[:4] != "HYLL" || value[4] > HLL_SPARSE {
+		return ErrNotHyperLogLog
+	}
+	if value[4] == HLL_DENSE && len(value) != HLL_DENSE_SIZE {
+		return ErrNotHyperLogLog
+	}
+	return nil
+}
+
+// Decodes all registers of a HyperLogLog value, validated by validateHLL
+func decodeRegisters(value string) ([]uint8, error) {
+	registers := make([]uint8, HLL_REGISTERS)
+	if value[4] == HLL_DENSE {
+		dense := []byte(value[HLL_HDR_SIZE:])
+		for i := range registers {
+			registers[i] = getDenseRegister(dense, i)
+		}
+		return registers, nil
+	}
+	index := 0
+	for i := HLL_HDR_SIZE; i < len(value); i++ {
+		opcode := value[i]
+		var runLength int
+		var registerValue uint8
+		switch {
+		case opcode&0xc0 == 0x00:
+			runLength = int(opcode&0x3f) + 1
+		case opcode&0xc0 == 0x40:
+			if i+1 >= len(value) {
+				return nil, ErrCorruptedHyperLogLog
+			}
+			runLength = (int(opcode&0x3f)<<8 | int(value[i+1])) + 1
+			i++
+		default:
+			registerValue = (opcode>>2)&0x1f + 1
+			runLength = int(opcode&0x03) + 1
+		}
+		if index+runLength > HLL_REGISTERS {
+			return nil, ErrCorruptedHyperLogLog
+		}
+		for j := 0; j < runLength; j++ {
+			registers[index+j] = registerValue
+		}
+		index += runLength
+	}
+	if index != HLL_REGISTERS {
+		return nil, ErrCorruptedHyperLogLog
+	}
+	return registers, nil
+}
+
+func hllHeader(encoding byte) []byte {
+	header := make([]byte, HLL_HDR_SIZE)
+	copy(header, "HYLL")
+	header[4] = encoding
+	return header
+}
+
+// Run length encodes registers. ok is false if a register is too large for
+// the sparse encoding or the result would pass hllSparseMaxBytes.
+func encodeSparse(registers []uint8) (encoded []byte, ok bool) {
+	encoded = hllHeader(HLL_SPARSE)
+	for i := 0; i < len(registers); {
+		value := registers[i]
+		if value > hllSparseValMaxValue {
+			return nil, false
+		}
+		runLength := 1
+		for i+runLength < len(registers) && registers[i+runLength] == value {
+			runLength++
+		}
+		i += runLength
+		for runLength > 0 {
+			var chunk int
+			switch {
+			case value != 0:
+				chunk = runLength
+				if chunk > hllSparseValMaxLen {
+					chunk = hllSparseValMaxLen
+				}
+				encoded = append(encoded, 0x80|(value-1)<<2|byte(chunk-1))
+			case runLength > hllSparseZeroMaxLen:
+				chunk = runLength
+				if chunk > hllSparseXZeroMaxLen {
+					chunk = hllSparseXZeroMaxLen
+				}
+				encoded = append(encoded, 0x40|byte((chunk-1)>>8), byte(chunk-1))
+			default:
+				chunk = runLength
+				encoded = append(encoded, byte(chunk-1))
+			}
+			runLength -= chunk
+		}
+		if len(encoded) > GetHLLSparseMaxBytes() {
+			return nil, false
+		}
+	}
+	return encoded, true
+}
+
+func encodeDense(registers []uint8) []byte {
+	encoded := make([]byte, HLL_DENSE_SIZE)
+	copy(encoded, hllHeader(HLL_DENSE))
+	for i, value := range registers {
+		setDenseRegister(encoded[HLL_HDR_SIZE:], i, value)
+	}
+	return encoded
+}
+
+// Encodes registers as sparse when asked and they fit, dense otherwise,
+// with a stale cardinality cache
+func encodeRegisters(registers []uint8, sparse bool) string {
+	encoded, ok := encodeSparse(registers)
+	if !sparse || !ok {
+		encoded = encodeDense(registers)
+	}
+	encoded[15] |= 0x80
+	return string(encoded)
+}
+
+// Cardinality cached in the header, ok is false when it is stale
+func cachedCardinality(value string) (cardinality int64, ok bool) {
+	if value[15]&0x80 != 0 {
+		return 0, false
+	}
+	for i := 15; i >= 8; i-- {
+		cardinality = cardinality<<8 | int64(value[i])
+	}
+	return cardinality, true
+}
+
+func withCachedCardinality(value string, cardinality int64) string {
+	buf := []byte(value)
+	for i := 8; i < 16; i++ {
+		buf[i] = byte(cardinality)
+		cardinality >>= 8
+	}
+	return string(buf)
+}
+
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y, z := 1.0, x
+	for {
+		x *= x
+		previous := z
+		z += x * y
+		y += y
+		if previous == z {
+			return z
+		}
+	}
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		previous := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+		if previous == z {
+			return z / 3
+		}
+	}
+}
+
+// Estimates the cardinality with the improved estimator from Otmar Ertl's
+// "New cardinality estimation algorithms for HyperLogLog sketches", like redis
+func hllCount(registers []uint8) int64 {
+	var histogram [HLL_Q + 2]int
+	for _, value := range registers {
+		histogram[value]++
+	}
+	m := float64(HLL_REGISTERS)
+	z := m * hllTau((m-float64(histogram[HLL_Q+1]))/m)
+	for j := HLL_Q; j >= 1; j-- {
+		z += float64(histogram[j])
+		z *= 0.5
+	}
+	z += m * hllSigma(float64(histogram[0])/m)
+	const alphaInf = 0.721347520444481703680
+	return int64(math.Round(alphaInf * m * m / z))
+}
+
+// Sparse value of a HyperLogLog with all registers zero and a cached count of 0
+func emptyHLL() string {
+	encoded, _ := encodeSparse(make([]uint8, HLL_REGISTERS))
+	return string(encoded)
+}
+
+// Adds elements to the HyperLogLog at key, creating it if missing. Returns
+// true if the key was created or a register changed, like redis's PFADD.
+func (c *ConcurrentMap) PFAdd(key string, elements []string) (bool, error) {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	entry, value, exists := getUnsafe(s, key)
+	if !exists {
+		value = emptyHLL()
+	} else if err := validateHLL(value); err != nil {
+		return false, err
+	}
+	updated := !exists
+	if value[4] == HLL_DENSE {
+		// registers are updated in place, without decoding all of them
+		buf := []byte(value)
+		for _, element := range elements {
+			index, count := hllPatternLength(element)
+			if getDenseRegister(buf[HLL_HDR_SIZE:], index) < count {
+				setDenseRegister(buf[HLL_HDR_SIZE:], index, count)
+				updated = true
+			}
+		}
+		if updated {
+			buf[15] |= 0x80
+			value = string(buf)
+		}
+	} else {
+		registers, err := decodeRegisters(value)
+		if err != nil {
+			return false, err
+		}
+		changed := false
+		for _, element := range elements {
+			index, count := hllPatternLength(element)
+			if registers[index] < count {
+				registers[index] = count
+				changed = true
+			}
+		}
+		if changed {
+			value = encodeRegisters(registers, true)
+			updated = true
+		}
+	}
+	if exists {
+		entry.Value = value
+	} else {
+		s.Set(key, value)
+	}
+	return updated, nil
+}
+
+// Registers of each existing key merged by taking the largest value of every
+// register. Caller must hold the locks of the keys' shards.
+func (c *ConcurrentMap) mergeRegistersUnsafe(keys []string) (merged []uint8, allSparse bool, err error) {
+	merged = make([]uint8, HLL_REGISTERS)
+	allSparse = true
+	for _, key := range keys {
+		_, value, exists := getUnsafe(c.keyspace.Shard(key), key)
+		if !exists {
+			continue
+		}
+		if err := validateHLL(value); err != nil {
+			return nil, false, err
+		}
+		registers, err := decodeRegisters(value)
+		if err != nil {
+			return nil, false, err
+		}
+		allSparse = allSparse && value[4] == HLL_SPARSE
+		for i, value := range registers {
+			if value > merged[i] {
+				merged[i] = value
+			}
+		}
+	}
+	return merged, allSparse, nil
+}
+
+// Estimated number of unique elements added to the union of keys, missing
+// keys counting as empty. For a single key the estimate is cached in its
+// header like redis; cacheUpdated reports when that changed the value.
+func (c *ConcurrentMap) PFCount(keys []string) (count int64, cacheUpdated bool, err error) {
+	if len(keys) > 1 {
+		unlock := c.keyspace.LockShards(keys, false)
+		defer unlock()
+		registers, _, err := c.mergeRegistersUnsafe(keys)
+		if err != nil {
+			return 0, false, err
+		}
+		return hllCount(registers), false, nil
+	}
+	key := keys[0]
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	entry, value, exists := getUnsafe(s, key)
+	if !exists {
+		return 0, false, nil
+	}
+	if err := validateHLL(value); err != nil {
+		return 0, false, err
+	}
+	if cardinality, ok := cachedCardinality(value); ok {
+		return cardinality, false, nil
+	}
+	registers, err := decodeRegisters(value)
+	if err != nil {
+		return 0, false, err
+	}
+	count = hllCount(registers)
+	entry.Value = withCachedCardinality(value, count)
+	return count, true, nil
+}
+
+// Stores the union of dest and keys in dest, keeping dest's timeout. The
+// result stays sparse only if every existing input was sparse, like redis.
+func (c *ConcurrentMap) PFMerge(dest string, keys []string) error {
+	allKeys := append([]string{dest}, keys...)
+	unlock := c.keyspace.LockShards(allKeys, true)
+	defer unlock()
+	registers, allSparse, err := c.mergeRegistersUnsafe(allKeys)
+	if err != nil {
+		return err
+	}
+	destShard := c.keyspace.Shard(dest)
+	if entry, _, exists := getUnsafe(destShard, dest); exists {
+		entry.Value = encodeRegisters(registers, allSparse)
+	} else {
+		destShard.Set(dest, encodeRegisters(registers, allSparse))
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package hashmap
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func TestPFAddCreatesSparseValue(t *testing.T) {
+	hashMap := Create()
+	if updated, _ := hashMap.PFAdd("hll", nil); !updated {
+		t.Errorf("Creating a HyperLogLog should count as an update")
+	}
+	// same bytes redis stores for an empty HyperLogLog
+	if value, _ := hashMap.Get("hll"); value != "HYLL\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x7f\xff" {
+		t.Errorf("Unexpected empty HyperLogLog %q", value)
+	}
+	if updated, _ := hashMap.PFAdd("hll", []string{"a", "b", "c", "d", "e", "f", "g"}); !updated {
+		t.Errorf("Expected registers to change")
+	}
+	if updated, _ := hashMap.PFAdd("hll", []string{"a"}); updated {
+		t.Errorf("Adding a counted element again must not change registers")
+	}
+	if count, cacheUpdated, _ := hashMap.PFCount([]string{"hll"}); count != 7 || !cacheUpdated {
+		t.Errorf("Expected 7 with cache updated but got %v %v", count, cacheUpdated)
+	}
+	if count, cacheUpdated, _ := hashMap.PFCount([]string{"hll"}); count != 7 || cacheUpdated {
+		t.Errorf("Expected cached 7 but got %v %v", count, cacheUpdated)
+	}
+	if value, _ := hashMap.Get("hll"); value[4] != HLL_SPARSE || value[8] != 7 {
+		t.Errorf("Expected sparse value caching 7 but got %q", value)
+	}
+}
+
+func TestPFAddConvertsToDense(t *testing.T) {
+	defer SetHLLSparseMaxBytes(GetHLLSparseMaxBytes())
+	SetHLLSparseMaxBytes(100)
+	hashMap := Create()
+	for i := 0; i < 200; i++ {
+		hashMap.PFAdd("hll", []string{strconv.Itoa(i)})
+	}
+	value, _ := hashMap.Get("hll")
+	if value[4] != HLL_DENSE || len(value) != HLL_DENSE_SIZE {
+		t.Errorf("Expected dense value of %v bytes but got encoding %v of %v bytes", HLL_DENSE_SIZE, value[4], len(value))
+	}
+	if count, _, _ := hashMap.PFCount([]string{"hll"}); count < 196 || count > 204 {
+		t.Errorf("Expected about 200 but got %v", count)
+	}
+}
+
+func TestPFCountAccuracy(t *testing.T) {
+	hashMap := Create()
+	random := rand.New(rand.NewSource(1))
+	for _, n := range []int{1000, 100000} {
+		key := "hll" + strconv.Itoa(n)
+		batch := []string{}
+		for i := 0; i < n; i++ {
+			batch = append(batch, strconv.FormatInt(random.Int63(), 10))
+			if len(batch) == 1000 {
+				hashMap.PFAdd(key, batch)
+				batch = batch[:0]
+			}
+		}
+		count, _, _ := hashMap.PFCount([]string{key})
+		// standard error is 0.81%, allow 3 times that
+		if math.Abs(float64(count)-float64(n))/float64(n) > 0.0243 {
+			t.Errorf("Estimate %v too far from %v", count, n)
+		}
+	}
+}
+
+func TestSparseDenseRoundTrip(t *testing.T) {
+	registers := make([]uint8, HLL_REGISTERS)
+	random := rand.New(rand.NewSource(2))
+	for i := 0; i < 300; i++ {
+		registers[random.Intn(HLL_REGISTERS)] = uint8(random.Intn(hllSparseValMaxValue) + 1)
+	}
+	registers[HLL_REGISTERS-1] = 5
+	for _, sparse := range []bool{true, false} {
+		decoded, err := decodeRegisters(encodeRegisters(registers, sparse))
+		if err != nil {
+			t.Fatalf("Decoding failed %v", err)
+		}
+		for i := range registers {
+			if decoded[i] != registers[i] {
+				t.Fatalf("Register %v expected %v but got %v (sparse %v)", i, registers[i], decoded[i], sparse)
+			}
+		}
+	}
+	registers[0] = hllSparseValMaxValue + 1
+	if _, ok := encodeSparse(registers); ok {
+		t.Errorf("Registers above 32 can't be sparse")
+	}
+}
+
+func TestPFMerge(t *testing.T) {
+	hashMap := Create()
+	hashMap.PFAdd("a", []string{"1", "2", "3"})
+	hashMap.PFAdd("b", []string{"3", "4"})
+	if err := hashMap.PFMerge("union", []string{"a", "b", "missing"}); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if value, _ := hashMap.Get("union"); value[4] != HLL_SPARSE {
+		t.Errorf("Merging sparse values should stay sparse")
+	}
+	if count, _, _ := hashMap.PFCount([]string{"union"}); count != 4 {
+		t.Errorf("Expected 4 but got %v", count)
+	}
+	if count, _, _ := hashMap.PFCount([]string{"a", "b"}); count != 4 {
+		t.Errorf("Expected 4 for the union of a and b but got %v", count)
+	}
+
+	hashMap.Set("text", "not a hll")
+	if _, err := hashMap.PFAdd("text", []string{"x"}); err != ErrNotHyperLogLog {
+		t.Errorf("Expected WRONGTYPE but got %v", err)
+	}
+	if err := hashMap.PFMerge("union", []string{"text"}); err != ErrNotHyperLogLog {
+		t.Errorf("Expected WRONGTYPE but got %v", err)
+	}
+	// stale cache so the registers are read, they cover one register too few
+	hashMap.Set("corrupt", "HYLL\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x80\x7f\xfe")
+	if _, _, err := hashMap.PFCount([]string{"corrupt"}); err != ErrCorruptedHyperLogLog {
+		t.Errorf("Expected corrupted error but got %v", err)
+	}
+}
@@ -0,0 +1,409 @@
+// Package httpClient is a typed client for the HTTP interface of the server,
+// described in openapi.yaml at the root of the repository. Connections are
+// pooled and reused, every call takes a context which cancels it, and
+// requests failing because of the network or an unavailable server are
+// retried with an exponential backoff.
+package httpClient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Settings of a Client, zero fields take the defaults below
+type Options struct {
+	// Used as is when set, the pool settings are then ignored
+	HTTPClient *http.Client
+	// Idle connections kept open to the server, 16 by default
+	MaxIdleConns int
+	// How long an idle connection is kept, 90 seconds by default
+	IdleConnTimeout time.Duration
+	// Times a failed request is sent again, 3 by default and -1 for none
+	MaxRetries int
+	// Wait before the first retry, doubled for each of the next ones up to
+	// MaxRetryBackoff. 50 milliseconds and 2 seconds by default.
+	MinRetryBackoff time.Duration
+	MaxRetryBackoff time.Duration
+	// Commands are POSTed and may not be idempotent, like INCR, so they are
+	// only retried when the connection failed before sending them, unless
+	// this is set
+	RetryCommands bool
+}
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	options    Options
+}
+
+// Error reply of the server or error status of a request
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (err *Error) Error() string {
+	return err.Message
+}
+
+// Whether err is an Error with the status code status
+func IsStatus(err error, status int) bool {
+	var replyError *Error
+	return errors.As(err, &replyError) && replyError.StatusCode == status
+}
+
+// A member of a sorted set with its score
+type ScoredMember struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+// A message received by Subscribe. Kind is message, pmessage, smessage or a
+// subscription confirmation like subscribe, in which case Count is the
+// number of subscriptions.
+type Message struct {
+	Kind    string `json:"-"`
+	Pattern string `json:"pattern,omitempty"`
+	Channel string `json:"channel"`
+	Payload string `json:"data,omitempty"`
+	Count   int    `json:"count,omitempty"`
+}
+
+// Creates a client of the server at baseURL, eg. http://localhost:8080.
+// options can be nil.
+func Create(baseURL string, options *Options) *Client {
+	client := &Client{baseURL: strings.TrimSuffix(baseURL, "/")}
+	if options != nil {
+		client.options = *options
+	}
+	o := &client.options
+	if o.MaxIdleConns == 0 {
+		o.MaxIdleConns = 16
+	}
+	if o.IdleConnTimeout == 0 {
+		o.IdleConnTimeout = 90 * time.Second
+	}
+	if o.MaxRetries == 0 {
+		o.MaxRetries = 3
+	}
+	if o.MinRetryBackoff == 0 {
+		o.MinRetryBackoff = 50 * time.Millisecond
+	}
+	if o.MaxRetryBackoff == 0 {
+		o.MaxRetryBackoff = 2 * time.Second
+	}
+	client.httpClient = o.HTTPClient
+	if client.httpClient == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.MaxIdleConns = o.MaxIdleConns
+		transport.MaxIdleConnsPerHost = o.MaxIdleConns
+		transport.IdleConnTimeout = o.IdleConnTimeout
+		client.httpClient = &http.Client{Transport: transport}
+	}
+	return client
+}
+
+// Closes the idle connections of the pool
+func (c *Client) Close() {
+	c.httpClient.CloseIdleConnections()
+}
+
+// A request which can be sent again, its body being kept
+type request struct {
+	method      string
+	path        string
+	contentType string
+	body        []byte
+	// whether sending it twice does the same as once
+	idempotent bool
+}
+
+// Sends a request, retrying it if the server couldn't be reached or was
+// unavailable. The response is closed by the caller.
+func (c *Client) send(ctx context.Context, r request) (*http.Response, error) {
+	backoff := c.options.MinRetryBackoff
+	for attempt := 0; ; attempt++ {
+		response, retry, err := c.sendOnce(ctx, r)
+		if err != nil && response != nil {
+			response.Body.Close()
+			response = nil
+		}
+		if !retry || attempt >= c.options.MaxRetries {
+			return response, err
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > c.options.MaxRetryBackoff {
+			backoff = c.options.MaxRetryBackoff
+		}
+	}
+}
+
+func (c *Client) sendOnce(ctx context.Context, r request) (response *http.Response, retry bool, err error) {
+	httpRequest, err := http.NewRequest(r.method, c.baseURL+r.path, bytes.NewReader(r.body))
+	if err != nil {
+		return nil, false, err
+	}
+	httpRequest = httpRequest.WithContext(ctx)
+	httpRequest.Header.Set("Accept", "application/json")
+	if r.contentType != "" {
+		httpRequest.Header.Set("Content-Type", r.contentType)
+	}
+	retryable := r.idempotent || c.options.RetryCommands
+	response, err = c.httpClient.Do(httpRequest)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, false, ctx.Err()
+		}
+		return nil, retryable || isDialError(err), err
+	}
+	switch response.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return response, retryable, responseError(response)
+	}
+	return response, false, nil
+}
+
+// Whether err happened while connecting, before anything was sent
+func isDialError(err error) bool {
+	var opError *net.OpError
+	return errors.As(err, &opError) && opError.Op == "dial"
+}
+
+// Error of a response with an error status, read from its {"error": ...}
+// body or the text of the body
+func responseError(response *http.Response) error {
+	body, _ := ioutil.ReadAll(response.Body)
+	var reply struct {
+		Error string `json:"error"`
+	}
+	message := strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &reply) == nil && reply.Error != "" {
+		message = reply.Error
+	}
+	if message == "" {
+		message = response.Status
+	}
+	return &Error{StatusCode: response.StatusCode, Message: message}
+}
+
+// Sends a request and decodes its JSON response into result, which can be
+// nil. Error statuses are returned as an *Error.
+func (c *Client) call(ctx context.Context, r request, result interface{}) error {
+	response, err := c.send(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 400 {
+		return responseError(response)
+	}
+	if result == nil || response.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	decoder := json.NewDecoder(response.Body)
+	decoder.UseNumber()
+	return decoder.Decode(result)
+}
+
+// Converts numbers decoded with UseNumber to int64, or float64 if they aren't integers
+func integers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if integer, err := v.Int64(); err == nil {
+			return integer
+		}
+		number, _ := v.Float64()
+		return number
+	case []interface{}:
+		for i := range v {
+			v[i] = integers(v[i])
+		}
+	}
+	return value
+}
+
+// Runs a command given as its arguments, eg. Do(ctx, "ZADD", "board", "1.5", "alice").
+// The reply is nil, an int64, a string or a []interface{} of them. Error
+// replies are returned as an *Error with the status the server gave them.
+func (c *Client) Do(ctx context.Context, args ...string) (interface{}, error) {
+	return c.Command(ctx, FormatCommand(args...))
+}
+
+// Runs a command line like the ones typed in redis-cli, see Do
+func (c *Client) Command(ctx context.Context, line string) (interface{}, error) {
+	form := url.Values{"command": {line}}
+	var reply struct {
+		Result interface{} `json:"result"`
+	}
+	err := c.call(ctx, request{
+		method:      "POST",
+		path:        "/",
+		contentType: "application/x-www-form-urlencoded",
+		body:        []byte(form.Encode()),
+	}, &reply)
+	if err != nil {
+		return nil, err
+	}
+	return integers(reply.Result), nil
+}
+
+// Runs commands given as their arguments in one request, in order, and
+// returns their replies as the text the command endpoint shows. Error
+// replies don't stop the next commands and are returned as replies.
+func (c *Client) Batch(ctx context.Context, commands ...[]string) ([]string, error) {
+	body, err := json.Marshal(commands)
+	if err != nil {
+		return nil, err
+	}
+	var replies []string
+	err = c.call(ctx, request{method: "POST", path: "/batch", contentType: "application/json", body: body}, &replies)
+	return replies, err
+}
+
+func keyPath(prefix string, key string) string {
+	return prefix + url.PathEscape(key)
+}
+
+// Value of a string key, found is false if there is none
+func (c *Client) Get(ctx context.Context, key string) (value string, found bool, err error) {
+	var reply struct {
+		Value string `json:"value"`
+	}
+	err = c.call(ctx, request{method: "GET", path: keyPath("/keys/", key), idempotent: true}, &reply)
+	if IsStatus(err, http.StatusNotFound) {
+		return "", false, nil
+	}
+	return reply.Value, err == nil, err
+}
+
+// Sets a string key
+func (c *Client) Set(ctx context.Context, key string, value string) error {
+	body, _ := json.Marshal(map[string]string{"value": value})
+	return c.call(ctx, request{
+		method:      "PUT",
+		path:        keyPath("/keys/", key),
+		contentType: "application/json",
+		body:        body,
+		idempotent:  true,
+	}, nil)
+}
+
+// Removes a string key, false if there was none
+func (c *Client) Delete(ctx context.Context, key string) (bool, error) {
+	err := c.call(ctx, request{method: "DELETE", path: keyPath("/keys/", key), idempotent: true}, nil)
+	if IsStatus(err, http.StatusNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Adds members to a sorted set and returns how many weren't there already
+func (c *Client) ZAdd(ctx context.Context, key string, members ...ScoredMember) (int64, error) {
+	body, _ := json.Marshal(members)
+	var reply struct {
+		Added int64 `json:"added"`
+	}
+	err := c.call(ctx, request{
+		method:      "POST",
+		path:        keyPath("/zsets/", key) + "/members",
+		contentType: "application/json",
+		body:        body,
+		idempotent:  true,
+	}, &reply)
+	return reply.Added, err
+}
+
+func rangePath(key string, start int64, stop int64, withScores bool) string {
+	query := url.Values{
+		"start":      {strconv.FormatInt(start, 10)},
+		"stop":       {strconv.FormatInt(stop, 10)},
+		"withscores": {strconv.FormatBool(withScores)},
+	}
+	return keyPath("/zsets/", key) + "/range?" + query.Encode()
+}
+
+// Members of a sorted set ranked from start to stop, negative ranks
+// counting from the end like in ZRANGE
+func (c *Client) ZRange(ctx context.Context, key string, start int64, stop int64) ([]string, error) {
+	var reply struct {
+		Members []string `json:"members"`
+	}
+	err := c.call(ctx, request{method: "GET", path: rangePath(key, start, stop, false), idempotent: true}, &reply)
+	return reply.Members, err
+}
+
+// Same as ZRange with the scores of the members
+func (c *Client) ZRangeWithScores(ctx context.Context, key string, start int64, stop int64) ([]ScoredMember, error) {
+	var reply struct {
+		Members []ScoredMember `json:"members"`
+	}
+	err := c.call(ctx, request{method: "GET", path: rangePath(key, start, stop, true), idempotent: true}, &reply)
+	return reply.Members, err
+}
+
+// Receives the messages of channels, patterns and shard channels, starting
+// with their subscription confirmations, calling handle for each until ctx
+// is cancelled or the server closes the stream. The stream isn't resumed,
+// returning ctx.Err() or io.ErrUnexpectedEOF.
+func (c *Client) Subscribe(ctx context.Context, channels []string, patterns []string, shardChannels []string, handle func(Message)) error {
+	query := url.Values{"channel": channels, "pattern": patterns, "shardchannel": shardChannels}
+	httpRequest, err := http.NewRequest("GET", c.baseURL+"/subscribe?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	httpRequest = httpRequest.WithContext(ctx)
+	httpRequest.Header.Set("Accept", "text/event-stream")
+	response, err := c.httpClient.Do(httpRequest)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 400 {
+		return responseError(response)
+	}
+	reader := bufio.NewReader(response.Body)
+	var message Message
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err == io.EOF {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			if message.Kind != "" {
+				handle(message)
+			}
+			message = Message{}
+		case strings.HasPrefix(line, "event: "):
+			message.Kind = line[len("event: "):]
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(line[len("data: "):]), &message); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package httpClient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFormatCommand(t *testing.T) {
+	cases := map[string][]string{
+		`SET k v`:                       {"SET", "k", "v"},
+		`SET greeting "hello world"`:    {"SET", "greeting", "hello world"},
+		`SET k ""`:                      {"SET", "k", ""},
+		`SET k "say \"hi\"\n\x00 it's"`: {"SET", "k", "say \"hi\"\n\x00 it's"},
+	}
+	for expected, args := range cases {
+		if result := FormatCommand(args...); result != expected {
+			t.Errorf("Expected %v but got %v", expected, result)
+		}
+	}
+}
+
+// Server replying 503 to the first failures requests and JSON afterwards,
+// counting the requests it got
+func flakyServer(failures int32, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(requests, 1) <= failures {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"key":"k","value":"v","result":["a",2,null]}`))
+	}))
+}
+
+func TestRetries(t *testing.T) {
+	var requests int32
+	server := flakyServer(2, &requests)
+	defer server.Close()
+	client := Create(server.URL, &Options{MinRetryBackoff: time.Millisecond})
+	value, found, err := client.Get(context.Background(), "k")
+	if err != nil || !found || value != "v" || requests != 3 {
+		t.Errorf("Expected v after 3 requests but got %v %v %v after %v", value, found, err, requests)
+	}
+
+	requests = 0
+	client = Create(server.URL, &Options{MinRetryBackoff: time.Millisecond, MaxRetries: 1})
+	if _, _, err := client.Get(context.Background(), "k"); !IsStatus(err, http.StatusServiceUnavailable) || requests != 2 {
+		t.Errorf("Expected 503 after 2 requests but got %v after %v", err, requests)
+	}
+}
+
+func TestCommandsAreOnlyRetriedWhenAllowed(t *testing.T) {
+	var requests int32
+	server := flakyServer(1, &requests)
+	defer server.Close()
+	client := Create(server.URL, &Options{MinRetryBackoff: time.Millisecond})
+	if _, err := client.Do(context.Background(), "INCR", "k"); !IsStatus(err, http.StatusServiceUnavailable) || requests != 1 {
+		t.Errorf("Expected 503 after 1 request but got %v after %v", err, requests)
+	}
+
+	requests = 0
+	client = Create(server.URL, &Options{MinRetryBackoff: time.Millisecond, RetryCommands: true})
+	reply, err := client.Do(context.Background(), "LRANGE", "l", "0", "-1")
+	items, _ := reply.([]interface{})
+	if err != nil || len(items) != 3 || items[0] != "a" || items[1] != int64(2) || items[2] != nil {
+		t.Errorf("Expected [a 2 <nil>] but got %#v %v", reply, err)
+	}
+}
+
+func TestContextCancelsRetries(t *testing.T) {
+	var requests int32
+	server := flakyServer(100, &requests)
+	defer server.Close()
+	client := Create(server.URL, &Options{MinRetryBackoff: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, _, err := client.Get(ctx, "k"); err != context.DeadlineExceeded || time.Since(start) > time.Second {
+		t.Errorf("Expected the deadline to stop the backoff but got %v after %v", err, time.Since(start))
+	}
+}
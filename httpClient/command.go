@@ -0,0 +1,51 @@
+package httpClient
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Joins arguments into a command line for the command endpoint, quoting the
+// ones containing spaces, quotes or control characters the way the server
+// splits them back, eg. FormatCommand("SET", "greeting", "hello world")
+// gives SET greeting "hello world".
+func FormatCommand(args ...string) string {
+	var builder strings.Builder
+	for i, arg := range args {
+		if i > 0 {
+			builder.WriteByte(' ')
+		}
+		needsQuotes := arg == ""
+		for j := 0; j < len(arg) && !needsQuotes; j++ {
+			c := arg[j]
+			needsQuotes = c <= ' ' || c == 0x7f || c == '"' || c == '\'' || c == '\\'
+		}
+		if !needsQuotes {
+			builder.WriteString(arg)
+			continue
+		}
+		builder.WriteByte('"')
+		for j := 0; j < len(arg); j++ {
+			c := arg[j]
+			switch {
+			case c == '\\' || c == '"':
+				builder.WriteByte('\\')
+				builder.WriteByte(c)
+			case c == '\n':
+				builder.WriteString("\\n")
+			case c == '\r':
+				builder.WriteString("\\r")
+			case c == '\t':
+				builder.WriteString("\\t")
+			case c < ' ' || c == 0x7f:
+				builder.WriteString("\\x")
+				builder.WriteString(strconv.FormatUint(uint64(c)>>4, 16))
+				builder.WriteString(strconv.FormatUint(uint64(c)&0xf, 16))
+			default:
+				builder.WriteByte(c)
+			}
+		}
+		builder.WriteByte('"')
+	}
+	return builder.String()
+}
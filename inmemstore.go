@@ -3,189 +3,544 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"github.com/thedeveloperr/redis-clone/hashObjectMap"
 	"github.com/thedeveloperr/redis-clone/hashmap"
+	"github.com/thedeveloperr/redis-clone/keyspace"
+	"github.com/thedeveloperr/redis-clone/listMap"
+	"github.com/thedeveloperr/redis-clone/setMap"
 	"github.com/thedeveloperr/redis-clone/sortedSetMap"
+	"github.com/thedeveloperr/redis-clone/streamMap"
 	"log"
+	"math"
 	"os"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// When the AOF is fsynced to make sure commands reach the hard disk, see
+// appendfsync
+const (
+	APPENDFSYNC_ALWAYS = iota
+	APPENDFSYNC_EVERYSEC
+	APPENDFSYNC_NO
+)
+
+// Policy named like in appendfsync, ok is false if there is none
+func parseFsyncPolicy(name string) (policy int32, ok bool) {
+	switch name {
+	case "always":
+		return APPENDFSYNC_ALWAYS, true
+	case "everysec":
+		return APPENDFSYNC_EVERYSEC, true
+	case "no":
+		return APPENDFSYNC_NO, true
+	}
+	return 0, false
+}
+
 // Struct for handling of appending commands to AOF file
 type AOFPersistor struct {
-	queue  chan string
-	ticker *time.Ticker
+	// bytes in the file, first for the alignment atomic needs
+	size int64
+	// bytes in the file when the server started
+	baseSize int64
+	// 1 if the last write failed, 0 if it succeeded
+	writeFailed int32
+	queue       chan string
+	ticker      *time.Ticker
+	filename    string
+	// opened when the first command is written
+	file *os.File
+	// one of the APPENDFSYNC_ policies
+	fsyncPolicy int32
+	// whether commands were written since the last fsync
+	unsynced bool
 }
 
-// Append string commands to the file with given filename
-// runs fsync to make sure data reaches hard disk and reduce
-// chance of data loss.
-func FlushCommands(command string, filename string) error {
-	if filename == "" {
-		return nil
+// Writes the queued commands to the file as they come, running fsync after
+// each of them or on each tick of the ticker depending on the policy
+func (persistor *AOFPersistor) run() {
+	for {
+		select {
+		case command := <-persistor.queue:
+			if err := persistor.write(command); err != nil {
+				serverLog(LOG_WARNING, "Writing the AOF failed:", err)
+			}
+		case <-persistor.ticker.C:
+			if atomic.LoadInt32(&persistor.fsyncPolicy) == APPENDFSYNC_EVERYSEC {
+				if err := persistor.sync(); err != nil {
+					serverLog(LOG_WARNING, "Fsync of the AOF failed:", err)
+				}
+			}
+		}
 	}
-	f, err := os.OpenFile(filename,
-		os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		log.Println(err)
+}
+
+// Append string command to the file
+func (persistor *AOFPersistor) write(command string) error {
+	if persistor.file == nil {
+		file, err := os.OpenFile(persistor.filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			atomic.StoreInt32(&persistor.writeFailed, 1)
+			return err
+		}
+		persistor.file = file
 	}
+	written, err := persistor.file.WriteString(command + "\n")
+	atomic.AddInt64(&persistor.size, int64(written))
 	if err != nil {
+		atomic.StoreInt32(&persistor.writeFailed, 1)
 		return err
 	}
-	defer f.Close()
+	atomic.StoreInt32(&persistor.writeFailed, 0)
+	persistor.unsynced = true
+	if atomic.LoadInt32(&persistor.fsyncPolicy) == APPENDFSYNC_ALWAYS {
+		return persistor.sync()
+	}
+	return nil
+}
 
-	if _, err := f.WriteString(command + "\n"); err != nil {
-		return err
+func (persistor *AOFPersistor) sync() error {
+	if !persistor.unsynced {
+		return nil
 	}
-	return f.Sync()
+	persistor.unsynced = false
+	return persistor.file.Sync()
 }
 
 // Struct for the main In Memory db
 type InMemoryStore struct {
+	// keys of every data type, each holding the value of one of the maps below
+	keyspace      *keyspace.Keyspace
 	sortedSet     *sortedSetMap.ConcurrentSortedsetMap
 	hashmap       *hashmap.ConcurrentMap
+	hashObject    *hashObjectMap.ConcurrentHashObjectMap
+	list          *listMap.ConcurrentListMap
+	set           *setMap.ConcurrentSetMap
+	stream        *streamMap.ConcurrentStreamMap
+	pubsub        *PubSub
+	watches       *watchRegistry
 	dataPersistor *AOFPersistor
+	// classes of keyspace events published, see notify-keyspace-events
+	notifyFlags int32
+	// held for reading by every command and exclusively by EXEC, so a
+	// transaction runs without commands of other clients in between
+	commandLock sync.RWMutex
+	// locked by write commands for the keys they touch, from running them
+	// until their AOF entry is queued, so the entries of writes to a key are
+	// queued in the order they were applied. Keys use the stripe of their
+	// shard in the keyspace.
+	keyLocks [keyspace.SHARD_COUNT]sync.Mutex
+	// set by EXEC while it holds commandLock, commands logged to the AOF
+	// are then collected in transactionLog to be written as one unit
+	inTransaction  bool
+	transactionLog []string
+	scripts        *scriptCache
+	functions      *functionRegistry
+	// milliseconds a script can run for before it's stopped, 0 for no limit
+	scriptTimeLimit int64
+	// bytes of memory after which writes are refused, 0 for no limit
+	maxMemory int64
+	// settings of CONFIG GET and CONFIG SET, guarded by configLock
+	config     Config
+	configLock sync.Mutex
+	// counters shown by INFO
+	stats *serverStats
 }
 
 // First load all the data in AOF file if exists in memory
 // Attach the AOF persistor to the in memory db so as to append future write
-// commands
+// commands. The AOF is fsynced every persistAfter seconds, no AOF is used if
+// AOFfilename is empty.
 func CreateInMemStore(persistAfter int, AOFfilename string) *InMemoryStore {
+	config := DefaultConfig()
+	config.AppendOnly = AOFfilename != ""
+	config.AppendFilename = AOFfilename
+	return createInMemStore(config, time.Duration(persistAfter)*time.Second)
+}
+
+// Store using the AOF and the settings of config, whose AOF is fsynced
+// every second with appendfsync everysec
+func CreateInMemStoreWithConfig(config Config) *InMemoryStore {
+	return createInMemStore(config, time.Second)
+}
 
+func createInMemStore(config Config, fsyncInterval time.Duration) *InMemoryStore {
+	keys := keyspace.New()
 	db := &InMemoryStore{
-		sortedSet:     sortedSetMap.Create(),
-		hashmap:       hashmap.Create(),
+		keyspace:      keys,
+		sortedSet:     sortedSetMap.CreateInKeyspace(keys),
+		hashmap:       hashmap.CreateInKeyspace(keys),
+		hashObject:    hashObjectMap.CreateInKeyspace(keys),
+		list:          listMap.CreateInKeyspace(keys),
+		set:           setMap.CreateInKeyspace(keys),
+		stream:        streamMap.CreateInKeyspace(keys),
+		pubsub:        CreatePubSub(),
+		watches:       createWatchRegistry(),
+		scripts:       createScriptCache(),
+		functions:     createFunctionRegistry(),
 		dataPersistor: nil,
+		config:        config,
+		stats:         createServerStats(),
 	}
+	db.notifyExpiredKeys()
 
-	if AOFfilename != "" {
+	AOFfilename := config.AppendFilename
+	if config.AppendOnly {
 		file, err := os.Open(AOFfilename)
 		if err == nil {
 
 			defer file.Close()
 
+			// nothing expires until every command is replayed, so writes
+			// logged after a deadline passed apply to the key or hash field
+			// they changed
+			keys.StartLoading()
 			scanner := bufio.NewScanner(file)
+			// a line can hold a whole value, quoted values take up to 4 bytes per
+			// byte, which is more than an int holds on 32 bit platforms
+			maxLine := int64(4*hashmap.MAX_STRING_LENGTH + 1024)
+			if maxLine > math.MaxInt32 && strconv.IntSize == 32 {
+				maxLine = math.MaxInt32
+			}
+			scanner.Buffer(make([]byte, 64*1024), int(maxLine))
+			// commands of a transaction are only applied once its EXEC is read
+			var transaction []string
+			inTransaction := false
 			for scanner.Scan() {
-				db.ProcessCommand(scanner.Text())
+				line := scanner.Text()
+				switch {
+				case line == "MULTI":
+					transaction, inTransaction = nil, true
+				case line == "EXEC" && inTransaction:
+					for _, command := range transaction {
+						db.ProcessCommand(command)
+					}
+					inTransaction = false
+				case inTransaction:
+					transaction = append(transaction, line)
+				default:
+					db.ProcessCommand(line)
+				}
 			}
 
 			if err := scanner.Err(); err != nil {
 				log.Fatal(err)
 			}
+			if inTransaction {
+				serverLog(LOG_WARNING, "Ignoring a transaction cut short at the end of", AOFfilename)
+			}
+			keys.FinishLoading()
+			db.hashObject.FinishLoading()
 		}
-	}
 
-	dataPersistor := &AOFPersistor{
-		ticker: time.NewTicker(time.Duration(persistAfter) * time.Second),
-		queue:  make(chan string, 1000),
+		db.dataPersistor = &AOFPersistor{
+			ticker:   time.NewTicker(fsyncInterval),
+			queue:    make(chan string, 1000),
+			filename: AOFfilename,
+		}
+		if info, err := os.Stat(AOFfilename); err == nil {
+			db.dataPersistor.size, db.dataPersistor.baseSize = info.Size(), info.Size()
+		}
+		go db.dataPersistor.run()
 	}
-	db.dataPersistor = dataPersistor
 
-	// Runs every given amount of seconds
-	// If the commands are there in buffered channel
-	// consume them and write them.
-	go func() {
-		for {
-			<-db.dataPersistor.ticker.C
-			for elem := range db.dataPersistor.queue {
-				err := FlushCommands(elem, AOFfilename)
-				if err != nil {
-					log.Println(err)
-				}
-			}
+	// the commands replayed aren't counted like those of clients
+	db.resetStats()
+	// settings are applied once the AOF is replayed, so a maxmemory lower
+	// than the data doesn't refuse the commands replayed
+	for _, parameter := range configParameters {
+		if parameter.apply != nil {
+			parameter.apply(db)
 		}
-	}()
-
+	}
 	return db
 }
 
 // Client's command is sent here, parsed and appropriate methods
-// on hashmap and Ordered Set Map are called.
+// on hashmap and Ordered Set Map are called. Returns the text of the reply,
+// as the HTTP handler shows it.
 func (store *InMemoryStore) ProcessCommand(command string) string {
-	comm := Command{
-		fullText: command,
+	return store.ProcessCommandReply(command).String()
+}
+
+// Runs a command like ProcessCommand and returns its reply
+func (store *InMemoryStore) ProcessCommandReply(command string) Reply {
+	commType, key, args, command := parseCommandLine(command)
+	return store.processParsedCommand(commType, key, args, command)
+}
+
+// Runs a command parsed by ProcessCommand, blocking commands without holding
+// the command lock while they wait and scripts holding it exclusively
+func (store *InMemoryStore) processParsedCommand(commType string, key string, args [][2]string, command string) Reply {
+	return store.countCommand(commType, func() Reply {
+		if keys, timeout, wait, blocks := store.blockingCommand(commType, key, args); blocks {
+			if timeout == timeoutOutOfRange {
+				return errorReply("ERR timeout is out of range")
+			}
+			return store.runBlockingCommand(commType, key, args, command, keys, timeout, wait)
+		}
+		if commType == "EVAL" || commType == "EVALSHA" || commType == "FCALL" || commType == "FCALL_RO" {
+			store.commandLock.Lock()
+			defer store.commandLock.Unlock()
+			return store.logAtomically(func() Reply {
+				return store.runCommand(commType, key, args, command)
+			})
+		}
+		store.commandLock.RLock()
+		defer store.commandLock.RUnlock()
+		defer store.lockKeys(commType, command)()
+		return store.runCommand(commType, key, args, command)
+	})
+}
+
+// Locks the stripes of keyLocks of the keys a write command touches, in
+// index order so commands locking several can't deadlock, and returns the
+// function unlocking them. Other commands lock nothing. Scripts and EXEC
+// need none, they hold the command lock exclusively.
+func (store *InMemoryStore) lockKeys(commType string, command string) func() {
+	if !writeCommands[commType] {
+		return func() {}
+	}
+	var needed [keyspace.SHARD_COUNT]bool
+	if args, ok := splitArgs(command); ok {
+		for _, key := range commandKeys(args) {
+			needed[keyspace.ShardIndex(key)] = true
+		}
+	}
+	// libraries aren't keys, FUNCTION takes the first stripe so their
+	// changes are logged in order too
+	if commType == "FUNCTION" {
+		needed[0] = true
+	}
+	var locked []*sync.Mutex
+	for i := range needed {
+		if needed[i] {
+			store.keyLocks[i].Lock()
+			locked = append(locked, &store.keyLocks[i])
+		}
+	}
+	return func() {
+		for _, lock := range locked {
+			lock.Unlock()
+		}
+	}
+}
+
+// Keys a blocking command waits on, its timeout, timeoutOutOfRange if too
+// large, and the wait of their key space. blocks is false for commands which don't wait, like XREADGROUP
+// reading pending entries.
+func (store *InMemoryStore) blockingCommand(commType string, key string, args [][2]string) (keys []string, timeout time.Duration, wait func(keys []string, timeout time.Duration, try func() bool) bool, blocks bool) {
+	switch commType {
+	case "BLPOP", "BRPOP":
+		return firstOfPairs(args[1:]), parseTimeout(args[0][0]), store.list.Block, true
+	case "BLMOVE":
+		return []string{key}, parseTimeout(args[0][1]), store.list.Block, true
+	case "XREAD", "XREADGROUP":
+		options, streams := args[0], args[2:]
+		if commType == "XREADGROUP" {
+			options, streams = args[1], args[3:]
+		}
+		if options[1] == "" {
+			return nil, 0, nil, false
+		}
+		for _, stream := range streams {
+			if commType == "XREADGROUP" && stream[1] != ">" {
+				return nil, 0, nil, false
+			}
+			keys = append(keys, stream[0])
+		}
+		_, timeout = streamReadOptions(options)
+		return keys, timeout, store.stream.Block, true
+	}
+	return nil, 0, nil, false
+}
+
+// Runs a blocking command until it replies something else than nil or its
+// timeout is reached. Each attempt runs the command once, holding the command
+// lock and key locks like any command, which are released while waiting for a
+// push or an added entry so transactions aren't held up.
+func (store *InMemoryStore) runBlockingCommand(commType string, key string, args [][2]string, command string, keys []string, timeout time.Duration, wait func(keys []string, timeout time.Duration, try func() bool) bool) Reply {
+	if commType == "XREAD" {
+		// "$" is the last entry when the command was sent, not when it is retried
+		resolved := append([][2]string{}, args...)
+		for i := 2; i < len(resolved); i++ {
+			if resolved[i][1] == "$" {
+				resolved[i][1] = store.stream.LastID(resolved[i][0]).String()
+			}
+		}
+		args = resolved
+	}
+	result := nilReply
+	// the client counts as blocked once the first attempt found nothing
+	blocked := false
+	wait(keys, timeout, func() bool {
+		store.commandLock.RLock()
+		defer store.commandLock.RUnlock()
+		defer store.lockKeys(commType, command)()
+		result = store.runCommand(commType, key, args, command)
+		if result.Kind == REPLY_NIL && !blocked {
+			blocked = true
+			atomic.AddInt64(&store.stats.blockedClients, 1)
+		}
+		return result.Kind != REPLY_NIL
+	})
+	if blocked {
+		atomic.AddInt64(&store.stats.blockedClients, -1)
+	}
+	return result
+}
+
+// Runs a parsed command. The caller holds the command lock.
+func (store *InMemoryStore) runCommand(commType string, key string, args [][2]string, command string) Reply {
+	if store.deniedByMaxMemory(commType) {
+		return errorReply("OOM command not allowed when used memory > 'maxmemory'.")
+	}
+	if store.holdsWrongType(commType, command) {
+		return errorReply("WRONGTYPE Operation against a key holding the wrong kind of value")
 	}
-	commType, key, args := comm.parse()
 	switch commType {
 	case "EXPIRE":
 		ttl, _ := strconv.ParseInt(args[0][0], 10, 32)
-		result := store.EXPIRE(key, int(ttl))
-		if result != "0" && store.dataPersistor != nil {
-			store.dataPersistor.queue <- command
+		deadline := time.Now().Add(time.Duration(ttl) * time.Second)
+		result := store.PEXPIREAT(key, deadline)
+		// Logged with the absolute deadline so replaying the AOF later doesn't extend the ttl
+		if result.Integer != 0 {
+			store.appendToAOF(formatCommand("PEXPIREAT", key, strconv.FormatInt(unixMilli(deadline), 10)))
+			store.notifyExpire(key, deadline)
+		}
+		return result
+	case "PEXPIREAT":
+		milliseconds, _ := strconv.ParseInt(args[0][0], 10, 64)
+		result := store.PEXPIREAT(key, fromUnixMilli(milliseconds))
+		if result.Integer != 0 {
+			store.appendToAOF(command)
+			store.notifyExpire(key, fromUnixMilli(milliseconds))
+		}
+		return result
+	case "PERSIST":
+		result := store.PERSIST(key)
+		if result.Integer != 0 {
+			store.appendToAOF(command)
+			store.notifyKeyspaceEvent(NOTIFY_GENERIC, "persist", key)
 		}
 		return result
 	case "GET":
 		return store.GET(key)
-	case "SET":
-		result := store.SET(key, args[0][0])
-		if result == "OK" && store.dataPersistor != nil {
-			store.dataPersistor.queue <- command
+	case "PING":
+		if len(args) == 1 {
+			return bulkReply(args[0][0])
 		}
+		return statusReply("PONG")
+	case "SET":
+		result := store.SET(key, args[0][0], args[0][1] == "KEEPTTL")
+		store.appendToAOF(command)
+		store.notifyKeyspaceEvent(NOTIFY_STRING, "set", key)
 		return result
 	case "ZRANGE":
 		if len(args) == 2 {
 			start, _ := strconv.ParseInt(args[0][0], 10, 64)
 			end, _ := strconv.ParseInt(args[0][1], 10, 64)
 			members, scores := store.ZRANGE_WITHSCR(key, start, end)
-			result := ""
-			num := 1
+			var items []Reply
 			for i := 0; i < len(members); i++ {
-				result += strconv.Itoa(num) + ") " + "'" + members[i] + "'" + "\n"
-				num++
-
-				result += strconv.Itoa(num) + ") " + fmt.Sprintf("%g", scores[i]) + "\n"
-				num++
-			}
-			if result == "" {
-				return "(empty list or set)"
+				items = append(items, bulkReply(members[i]), doubleReply(fmt.Sprintf("%g", scores[i])))
 			}
-			return result
-
+			return arrayReply(items)
 		}
 		if len(args) == 1 {
 			start, _ := strconv.ParseInt(args[0][0], 10, 64)
 			end, _ := strconv.ParseInt(args[0][1], 10, 64)
-			members := store.ZRANGE(key, start, end)
-			result := ""
-			num := 1
-			for i := 0; i < len(members); i++ {
-				result += strconv.Itoa(num) + ") " + "'" + members[i] + "'" + "\n"
-				num++
-			}
-			if result == "" {
-				return "(empty list or set)"
-			}
-			return result
+			return bulkArrayReply(store.ZRANGE(key, start, end))
 		}
 	case "ZRANK":
 		return store.ZRANK(key, args[0][0])
+	case "ZCARD":
+		return store.ZCARD(key)
 	case "ZADD":
 		added := 0
 		for i := 0; i < len(args); i++ {
 			score, _ := strconv.ParseFloat(args[i][0], 64)
 			added += store.ZADD(key, score, args[i][1])
 		}
-		if added > 0 && store.dataPersistor != nil {
-			store.dataPersistor.queue <- command
+		if added > 0 {
+			store.appendToAOF(command)
+			store.notifyKeyspaceEvent(NOTIFY_ZSET, "zadd", key)
 		}
-		result := fmt.Sprintf("%d", added)
-		return result
+		return integerReply(int64(added))
+	}
+	for _, process := range commandProcessors {
+		if result, handled := process(store, commType, key, args, command); handled {
+			return result
+		}
+	}
+	return errorReply("COMMAND NOT VALID")
+}
+
+// Runs the commands of each data type, handled is false for commands of other types
+var commandProcessors []func(store *InMemoryStore, commType string, key string, args [][2]string, command string) (result Reply, handled bool)
+
+// Filled in here as scripts run commands, which makes the processors refer to themselves
+func init() {
+	commandProcessors = []func(store *InMemoryStore, commType string, key string, args [][2]string, command string) (result Reply, handled bool){
+		(*InMemoryStore).processStringCommand,
+		(*InMemoryStore).processBitmapCommand,
+		(*InMemoryStore).processHyperLogLogCommand,
+		(*InMemoryStore).processHashCommand,
+		(*InMemoryStore).processListCommand,
+		(*InMemoryStore).processSetCommand,
+		(*InMemoryStore).processStreamCommand,
+		(*InMemoryStore).processGeoCommand,
+		(*InMemoryStore).processPubSubCommand,
+		(*InMemoryStore).processTransactionCommand,
+		(*InMemoryStore).processScriptCommand,
+		(*InMemoryStore).processFunctionCommand,
+		(*InMemoryStore).processConfigCommand,
+		(*InMemoryStore).processKeyspaceCommand,
+		(*InMemoryStore).processInfoCommand,
+	}
+}
+
+// Queue a write command to be flushed to the AOF file. Transactions watching
+// the keys it modifies are aborted, and during EXEC it's kept to be logged
+// with the rest of the transaction.
+func (store *InMemoryStore) appendToAOF(command string) {
+	store.watches.touchCommand(command)
+	if store.inTransaction {
+		store.transactionLog = append(store.transactionLog, command)
+		return
 	}
-	return "COMMAND NOT VALID"
+	if store.dataPersistor != nil {
+		store.dataPersistor.queue <- command
+	}
+}
+
+func unixMilli(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
 }
 
-// Gets value of key if set otherwise (nil)
-func (store *InMemoryStore) GET(key string) string {
+func fromUnixMilli(milliseconds int64) time.Time {
+	return time.Unix(milliseconds/1000, (milliseconds%1000)*int64(time.Millisecond))
+}
+
+// Gets value of key if set otherwise nil
+func (store *InMemoryStore) GET(key string) Reply {
 	if val, exists := store.hashmap.Get(key); exists {
-		return val
+		return bulkReply(val)
 	}
-	return "(nil)"
+	return nilReply
 }
 
-// Sets value of key returns "OK" if successful
-func (store *InMemoryStore) SET(key string, value string) string {
-	store.hashmap.Set(key, value)
-	return "OK"
+// Sets value of key returns OK if successful
+func (store *InMemoryStore) SET(key string, value string, keepTTL bool) Reply {
+	if keepTTL {
+		store.hashmap.SetKeepTTL(key, value)
+	} else {
+		store.hashmap.Set(key, value)
+	}
+	return okReply
 }
 
 // Perform ZADD and Inserts a member element with a given score in sorted set backed by Skiplist and Hasmap
@@ -206,23 +561,29 @@ func (store *InMemoryStore) ZRANGE(key string, start int64, end int64) (members
 }
 
 // Gets position of member inside sorted set. Perform ZRANK. It's 0 index based
-func (store *InMemoryStore) ZRANK(key string, member string) string {
+func (store *InMemoryStore) ZRANK(key string, member string) Reply {
 	if rank, exists := store.sortedSet.GetRank(key, member); exists {
-		return fmt.Sprintf("%d", rank)
+		return integerReply(int64(rank))
 	}
-	return "(nil)"
+	return nilReply
+}
+
+// Number of members of a sorted set, 0 for missing keys. Perform ZCARD key command
+func (store *InMemoryStore) ZCARD(key string) Reply {
+	return integerReply(int64(store.sortedSet.Card(key)))
 }
 
 // Expire and remove key after some given ttl seconds. Perform EXPIRE key ttl command
-func (store *InMemoryStore) EXPIRE(key string, ttl int) string {
-	canExpire := store.hashmap.Expire(key, ttl)
-	if canExpire == 1 {
-		return "1"
-	}
+func (store *InMemoryStore) EXPIRE(key string, ttl int) Reply {
+	return store.PEXPIREAT(key, time.Now().Add(time.Duration(ttl)*time.Second))
+}
 
-	canExpire = store.sortedSet.Expire(key, ttl)
-	if canExpire == 1 {
-		return "1"
-	}
-	return "0"
+// Expire key at given absolute time. Perform PEXPIREAT key milliseconds-timestamp command
+func (store *InMemoryStore) PEXPIREAT(key string, deadline time.Time) Reply {
+	return booleanReply(store.keyspace.ExpireAt(key, deadline))
+}
+
+// Remove the timeout of key. Perform PERSIST key command
+func (store *InMemoryStore) PERSIST(key string) Reply {
+	return booleanReply(store.keyspace.Persist(key))
 }
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"github.com/thedeveloperr/redis-clone/hashmap"
+	"strconv"
+)
+
+// Runs bit level commands on string values. handled is false if commType isn't one of them.
+func (store *InMemoryStore) processBitmapCommand(commType string, key string, args [][2]string, command string) (result Reply, handled bool) {
+	switch commType {
+	case "SETBIT":
+		offset, _ := strconv.ParseUint(args[0][0], 10, 64)
+		bit, _ := strconv.Atoi(args[0][1])
+		result := store.SETBIT(key, offset, bit)
+		if !result.isError() {
+			store.appendToAOF(command)
+			store.notifyKeyspaceEvent(NOTIFY_STRING, "setbit", key)
+		}
+		return result, true
+	case "GETBIT":
+		offset, _ := strconv.ParseUint(args[0][0], 10, 64)
+		return store.GETBIT(key, offset), true
+	case "BITCOUNT":
+		if len(args) == 0 {
+			return store.BITCOUNT(key, 0, -1, false, false), true
+		}
+		start, _ := strconv.ParseInt(args[0][0], 10, 64)
+		end, _ := strconv.ParseInt(args[0][1], 10, 64)
+		useBit := len(args) == 2 && args[1][0] == "BIT"
+		return store.BITCOUNT(key, start, end, true, useBit), true
+	case "BITPOS":
+		bit, _ := strconv.Atoi(args[0][0])
+		var start, end int64
+		hasStart, hasEnd := len(args) >= 2, len(args) >= 2 && args[1][1] != ""
+		if hasStart {
+			start, _ = strconv.ParseInt(args[1][0], 10, 64)
+		}
+		if hasEnd {
+			end, _ = strconv.ParseInt(args[1][1], 10, 64)
+		}
+		useBit := len(args) == 3 && args[2][0] == "BIT"
+		return store.BITPOS(key, bit, start, end, hasStart, hasEnd, useBit), true
+	case "BITOP":
+		sources := make([]string, len(args)-1)
+		for i := range sources {
+			sources[i] = args[i+1][0]
+		}
+		existed := store.keyExists(key)
+		result := store.BITOP(args[0][0], key, sources)
+		store.appendToAOF(command)
+		store.notifyStore(NOTIFY_STRING, "set", key, existed)
+		return result, true
+	case "BITFIELD":
+		tokens := make([]string, len(args))
+		for i := range args {
+			tokens[i] = args[i][0]
+		}
+		ops, _ := parseBitfieldOps(tokens)
+		result, err := store.BITFIELD(key, ops)
+		if err != nil {
+			return errorReply(err.Error()), true
+		}
+		for _, op := range ops {
+			if op.Kind != "GET" {
+				store.appendToAOF(command)
+				store.notifyKeyspaceEvent(NOTIFY_STRING, "setbit", key)
+				break
+			}
+		}
+		return result, true
+	}
+	return Reply{}, false
+}
+
+// Sets or clears a bit and returns its old value. Perform SETBIT key offset value command
+func (store *InMemoryStore) SETBIT(key string, offset uint64, bit int) Reply {
+	old, err := store.hashmap.SetBit(key, offset, bit)
+	if err != nil {
+		return errorReply(err.Error())
+	}
+	return integerReply(int64(old))
+}
+
+// Bit at offset, 0 past the end of the string. Perform GETBIT key offset command
+func (store *InMemoryStore) GETBIT(key string, offset uint64) Reply {
+	return integerReply(int64(store.hashmap.GetBit(key, offset)))
+}
+
+// Number of set bits. Perform BITCOUNT key [start end [BYTE|BIT]] command
+func (store *InMemoryStore) BITCOUNT(key string, start int64, end int64, hasRange bool, useBit bool) Reply {
+	return integerReply(store.hashmap.BitCount(key, start, end, hasRange, useBit))
+}
+
+// First bit set to bit. Perform BITPOS key bit [start [end [BYTE|BIT]]] command
+func (store *InMemoryStore) BITPOS(key string, bit int, start int64, end int64, hasStart bool, hasEnd bool, useBit bool) Reply {
+	return integerReply(store.hashmap.BitPos(key, bit, start, end, hasStart, hasEnd, useBit))
+}
+
+// Stores bitwise operation of sources in dest. Perform BITOP AND|OR|XOR|NOT destkey key [key ...] command
+func (store *InMemoryStore) BITOP(operation string, dest string, sources []string) Reply {
+	return integerReply(int64(store.hashmap.BitOp(operation, dest, sources)))
+}
+
+// Runs GET, SET and INCRBY on integers inside a string. Perform BITFIELD key [GET|SET|INCRBY|OVERFLOW ...] command
+func (store *InMemoryStore) BITFIELD(key string, ops []hashmap.BitfieldOp) (Reply, error) {
+	results, ok, err := store.hashmap.BitField(key, ops)
+	if err != nil {
+		return Reply{}, err
+	}
+	items := make([]Reply, len(results))
+	for i := range results {
+		if ok[i] {
+			items[i] = integerReply(results[i])
+		} else {
+			items[i] = nilReply
+		}
+	}
+	return arrayReply(items), nil
+}
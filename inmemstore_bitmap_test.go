@@ -0,0 +1,116 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_SETBIT_GETBIT_BITCOUNT_BITPOS_Commands(t *testing.T) {
+	db := CreateTestDbSetup()
+	commands := []struct {
+		command  string
+		expected string
+	}{
+		{"SETBIT visits 7 1", "0"},
+		{"SETBIT visits 7 1", "1"},
+		{"SETBIT visits 9 1", "0"},
+		{"SETBIT visits 9 2", "COMMAND NOT VALID"},
+		{"SETBIT visits -1 1", "COMMAND NOT VALID"},
+		{"GETBIT visits 9", "1"},
+		{"GETBIT visits 100", "0"},
+		{"BITCOUNT visits", "2"},
+		{"BITCOUNT visits 1 1", "1"},
+		{"BITCOUNT visits 0 7 BIT", "1"},
+		{"BITCOUNT visits 0 7 BITS", "COMMAND NOT VALID"},
+		{"BITPOS visits 1", "7"},
+		{"BITPOS visits 1 1", "9"},
+		{"BITPOS visits 0 0 0", "0"},
+		{"BITPOS visits 1 8 15 BIT", "9"},
+		{`SET raw "\xff\xf0"`, "OK"},
+		{"BITPOS raw 0", "12"},
+	}
+	for _, c := range commands {
+		result := db.ProcessCommand(c.command)
+		if result != c.expected {
+			t.Errorf("Ran:" + c.command + ". Expected: " + c.expected + " but Got result:" + result)
+		}
+	}
+}
+
+func Test_BITOP_BITFIELD_Commands(t *testing.T) {
+	db := CreateTestDbSetup()
+	commands := []struct {
+		command  string
+		expected string
+	}{
+		{`SET a "\x0f"`, "OK"},
+		{`SET b "\xf1"`, "OK"},
+		{"BITOP AND dest a b", "1"},
+		{"GETBIT dest 7", "1"},
+		{"BITCOUNT dest", "1"},
+		{"BITOP OR dest a b missing", "1"},
+		{"BITCOUNT dest", "8"},
+		{"BITOP NOT dest a b", "COMMAND NOT VALID"},
+		{"BITOP NAND dest a b", "COMMAND NOT VALID"},
+		{"BITFIELD counters INCRBY u8 #1 200 GET u8 #1", "1) 200\n2) 200\n"},
+		{"BITFIELD counters OVERFLOW FAIL INCRBY u8 #1 100 GET u8 8", "1) (nil)\n2) 200\n"},
+		{"BITFIELD counters OVERFLOW SAT INCRBY u8 #1 100", "1) 255\n"},
+		{"BITFIELD counters SET i8 0 -5 GET i8 0", "1) 0\n2) -5\n"},
+		{"BITFIELD counters GET u64 0", "COMMAND NOT VALID"},
+		{"BITFIELD counters GET i8", "COMMAND NOT VALID"},
+		{"BITFIELD counters", "(empty list or set)"},
+	}
+	for _, c := range commands {
+		result := db.ProcessCommand(c.command)
+		if result != c.expected {
+			t.Errorf("Ran:" + c.command + ". Expected: " + c.expected + " but Got result:" + result)
+		}
+	}
+}
+
+func TestAOFReplaysBinaryValues(t *testing.T) {
+	AOFfilename, remove := tempAOF(t)
+	defer remove()
+	db := CreateInMemStore(1, AOFfilename)
+	db.ProcessCommand(`SET "key with space" "\x00\xff"`)
+	db.ProcessCommand(`PEXPIREAT "key with space" 99999999999999`)
+	db.ProcessCommand(`EXPIRE "key with space" 1000`)
+	db.ProcessCommand("SETBIT bitmap 100 1")
+	db.ProcessCommand("BITFIELD counters INCRBY u8 0 42")
+	time.Sleep(2 * time.Second) //give extra time to persist to make sure all data is flushed
+
+	replayed := CreateInMemStore(1, AOFfilename)
+	expected := map[string]string{
+		`STRLEN "key with space"`:    "2",
+		`GETBIT "key with space" 8`:  "1",
+		"BITCOUNT bitmap":            "1",
+		"GETBIT bitmap 100":          "1",
+		"BITFIELD counters GET u8 0": "1) 42\n",
+	}
+	for command, value := range expected {
+		if result := replayed.ProcessCommand(command); result != value {
+			t.Errorf("After replay ran:" + command + ". Expected: " + value + " but Got result:" + result)
+		}
+	}
+}
+
+func TestAOFLogsValuesHoldingNewlines(t *testing.T) {
+	AOFfilename, remove := tempAOF(t)
+	defer remove()
+	db := CreateInMemStore(1, AOFfilename)
+	db.ProcessCommand("SET k \"a\nb\"")
+	db.ProcessCommand("SET injection \"x\nSET injected y\n\"")
+	time.Sleep(200 * time.Millisecond)
+
+	replayed := CreateInMemStore(1, AOFfilename)
+	expected := map[string]string{
+		"GET k":         "a\nb",
+		"GET injection": "x\nSET injected y\n",
+		"GET injected":  "(nil)",
+	}
+	for command, value := range expected {
+		if result := replayed.ProcessCommand(command); result != value {
+			t.Errorf("After replay ran:" + command + ". Expected: " + value + " but Got result:" + result)
+		}
+	}
+}
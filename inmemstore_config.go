@@ -0,0 +1,285 @@
+package main
+
+import (
+	"github.com/thedeveloperr/redis-clone/hashmap"
+	"github.com/thedeveloperr/redis-clone/sortedSetMap"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// A setting of Config read by CONFIG GET. set returns false for values it
+// doesn't accept.
+type configParameter struct {
+	get func(config *Config) string
+	set func(config *Config, value string) bool
+	// false for settings only read at startup, which CONFIG SET refuses
+	mutable bool
+	// makes the store use the setting, when it's created and after CONFIG SET
+	apply func(store *InMemoryStore)
+	// the value is written as several arguments in the config file
+	list bool
+}
+
+// Settings by their lowercase name
+var configParameters = map[string]configParameter{
+	"bind": {
+		get: func(config *Config) string { return config.Bind },
+		set: func(config *Config, value string) bool {
+			addresses := strings.Fields(value)
+			config.Bind = strings.Join(addresses, " ")
+			return len(addresses) > 0
+		},
+		list: true,
+	},
+	"port": {
+		get: func(config *Config) string { return strconv.Itoa(config.Port) },
+		set: func(config *Config, value string) bool { return parsePort(value, &config.Port) },
+	},
+	"http-port": {
+		get: func(config *Config) string { return strconv.Itoa(config.HTTPPort) },
+		set: func(config *Config, value string) bool { return parsePort(value, &config.HTTPPort) },
+	},
+	"dir": {
+		get: func(config *Config) string { return config.Dir },
+		set: func(config *Config, value string) bool {
+			config.Dir = value
+			return value != ""
+		},
+	},
+	"appendonly": {
+		get: func(config *Config) string { return formatYesNo(config.AppendOnly) },
+		set: func(config *Config, value string) bool {
+			appendOnly, ok := parseYesNo(value)
+			config.AppendOnly = appendOnly
+			return ok
+		},
+	},
+	"appendfilename": {
+		get: func(config *Config) string { return config.AppendFilename },
+		set: func(config *Config, value string) bool {
+			config.AppendFilename = value
+			return value != ""
+		},
+	},
+	"appendfsync": {
+		get: func(config *Config) string { return config.AppendFsync },
+		set: func(config *Config, value string) bool {
+			_, ok := parseFsyncPolicy(strings.ToLower(value))
+			config.AppendFsync = strings.ToLower(value)
+			return ok
+		},
+		mutable: true,
+		apply: func(store *InMemoryStore) {
+			if store.dataPersistor != nil {
+				policy, _ := parseFsyncPolicy(store.config.AppendFsync)
+				atomic.StoreInt32(&store.dataPersistor.fsyncPolicy, policy)
+			}
+		},
+	},
+	"maxmemory": {
+		get: func(config *Config) string { return strconv.FormatInt(config.MaxMemory, 10) },
+		set: func(config *Config, value string) bool {
+			bytes, ok := parseMemory(value)
+			config.MaxMemory = bytes
+			return ok
+		},
+		mutable: true,
+		apply: func(store *InMemoryStore) {
+			atomic.StoreInt64(&store.maxMemory, store.config.MaxMemory)
+		},
+	},
+	// keys aren't evicted, writes are refused once maxmemory is reached
+	"maxmemory-policy": {
+		get: func(config *Config) string { return config.MaxMemoryPolicy },
+		set: func(config *Config, value string) bool {
+			config.MaxMemoryPolicy = strings.ToLower(value)
+			return config.MaxMemoryPolicy == "noeviction"
+		},
+		mutable: true,
+	},
+	"loglevel": {
+		get: func(config *Config) string { return config.LogLevel },
+		set: func(config *Config, value string) bool {
+			_, ok := parseLogLevel(strings.ToLower(value))
+			config.LogLevel = strings.ToLower(value)
+			return ok
+		},
+		mutable: true,
+		apply: func(store *InMemoryStore) {
+			level, _ := parseLogLevel(store.config.LogLevel)
+			atomic.StoreInt32(&logLevel, int32(level))
+		},
+	},
+	"logfile": {
+		get: func(config *Config) string { return config.LogFile },
+		set: func(config *Config, value string) bool {
+			config.LogFile = value
+			return true
+		},
+	},
+	"notify-keyspace-events": {
+		get: func(config *Config) string { return config.NotifyKeyspaceEvents },
+		set: func(config *Config, value string) bool {
+			flags, ok := parseNotifyFlags(value)
+			config.NotifyKeyspaceEvents = formatNotifyFlags(flags)
+			return ok
+		},
+		mutable: true,
+		apply: func(store *InMemoryStore) {
+			flags, _ := parseNotifyFlags(store.config.NotifyKeyspaceEvents)
+			atomic.StoreInt32(&store.notifyFlags, flags)
+		},
+	},
+	"lua-time-limit": {
+		get: func(config *Config) string { return strconv.FormatInt(config.LuaTimeLimit, 10) },
+		set: func(config *Config, value string) bool {
+			milliseconds, err := strconv.ParseInt(value, 10, 64)
+			config.LuaTimeLimit = milliseconds
+			return err == nil && milliseconds >= 0
+		},
+		mutable: true,
+		apply: func(store *InMemoryStore) {
+			atomic.StoreInt64(&store.scriptTimeLimit, store.config.LuaTimeLimit)
+		},
+	},
+	// sets which grow past either limit are converted to Skiplist + map,
+	// those already converted stay so
+	"zset-max-listpack-entries": {
+		get: func(config *Config) string { return strconv.FormatInt(config.ZsetMaxListpackEntries, 10) },
+		set: func(config *Config, value string) bool {
+			entries, err := strconv.ParseInt(value, 10, 64)
+			config.ZsetMaxListpackEntries = entries
+			return err == nil && isEncodingLimit(entries)
+		},
+		mutable: true,
+		apply:   applyListpackThresholds,
+	},
+	"zset-max-listpack-value": {
+		get: func(config *Config) string { return strconv.FormatInt(config.ZsetMaxListpackValue, 10) },
+		set: func(config *Config, value string) bool {
+			bytes, ok := parseMemory(value)
+			config.ZsetMaxListpackValue = bytes
+			return ok && isEncodingLimit(bytes)
+		},
+		mutable: true,
+		apply:   applyListpackThresholds,
+	},
+	"hll-sparse-max-bytes": {
+		get: func(config *Config) string { return strconv.FormatInt(config.HLLSparseMaxBytes, 10) },
+		set: func(config *Config, value string) bool {
+			bytes, ok := parseMemory(value)
+			config.HLLSparseMaxBytes = bytes
+			return ok && isEncodingLimit(bytes)
+		},
+		mutable: true,
+		apply: func(store *InMemoryStore) {
+			hashmap.SetHLLSparseMaxBytes(int(store.config.HLLSparseMaxBytes))
+		},
+	},
+}
+
+// Whether limit can be one of the encoding limits, which are ints even on
+// 32 bit platforms
+func isEncodingLimit(limit int64) bool {
+	return limit >= 0 && limit <= math.MaxInt32
+}
+
+func applyListpackThresholds(store *InMemoryStore) {
+	sortedSetMap.SetListpackThresholds(int(store.config.ZsetMaxListpackEntries), int(store.config.ZsetMaxListpackValue))
+}
+
+// Parses a TCP port, 0 meaning none is listened on
+func parsePort(text string, port *int) bool {
+	number, err := strconv.Atoi(text)
+	*port = number
+	return err == nil && number >= 0 && number <= 65535
+}
+
+// Runs CONFIG commands. Settings aren't data so they aren't logged to the AOF.
+// handled is false if commType isn't one of them.
+func (store *InMemoryStore) processConfigCommand(commType string, key string, args [][2]string, command string) (result Reply, handled bool) {
+	if commType != "CONFIG" {
+		return Reply{}, false
+	}
+	switch key {
+	case "GET":
+		return store.CONFIG_GET(firstOfPairs(args)...), true
+	case "SET":
+		return store.CONFIG_SET(args), true
+	case "RESETSTAT":
+		return store.CONFIG_RESETSTAT(), true
+	}
+	return store.CONFIG_REWRITE(), true
+}
+
+// Names and values of the settings matching any of the glob patterns, in
+// alphabetical order. Perform CONFIG GET parameter [parameter ...] command
+func (store *InMemoryStore) CONFIG_GET(patterns ...string) Reply {
+	store.configLock.Lock()
+	defer store.configLock.Unlock()
+	var items []string
+	for _, name := range configParameterNames() {
+		for _, pattern := range patterns {
+			if globMatch(strings.ToLower(pattern), name) {
+				items = append(items, name, configParameters[name].get(&store.config))
+				break
+			}
+		}
+	}
+	return bulkArrayReply(items)
+}
+
+// Changes settings, none of them if one can't be changed to its value.
+// Perform CONFIG SET parameter value [parameter value ...] command
+func (store *InMemoryStore) CONFIG_SET(pairs [][2]string) Reply {
+	store.configLock.Lock()
+	defer store.configLock.Unlock()
+	config := store.config
+	seen := map[string]bool{}
+	for _, pair := range pairs {
+		name, value := strings.ToLower(pair[0]), pair[1]
+		parameter, exists := configParameters[name]
+		switch {
+		case !exists:
+			return errorReply("ERR Unknown option or number of arguments for CONFIG SET - '" + pair[0] + "'")
+		case !parameter.mutable:
+			return errorReply("ERR CONFIG SET failed (possibly related to argument '" + pair[0] + "') - can't set immutable config")
+		case seen[name]:
+			return errorReply("ERR CONFIG SET failed (possibly related to argument '" + pair[0] + "') - duplicate parameter")
+		case !parameter.set(&config, value):
+			return errorReply("ERR Invalid argument '" + value + "' for CONFIG SET '" + pair[0] + "'")
+		}
+		seen[name] = true
+	}
+	store.config = config
+	for name := range seen {
+		if apply := configParameters[name].apply; apply != nil {
+			apply(store)
+		}
+	}
+	return okReply
+}
+
+// Writes the settings to the config file the server started with.
+// Perform CONFIG REWRITE command
+func (store *InMemoryStore) CONFIG_REWRITE() Reply {
+	store.configLock.Lock()
+	defer store.configLock.Unlock()
+	if store.config.filename == "" {
+		return errorReply("ERR The server is running without a config file")
+	}
+	if err := rewriteConfigFile(&store.config); err != nil {
+		serverLog(LOG_WARNING, "CONFIG REWRITE failed:", err)
+		return errorReply("ERR Rewriting config file: " + err.Error())
+	}
+	serverLog(LOG_NOTICE, "CONFIG REWRITE executed with success.")
+	return okReply
+}
+
+// Zeroes the counters INFO shows. Perform CONFIG RESETSTAT command
+func (store *InMemoryStore) CONFIG_RESETSTAT() Reply {
+	store.resetStats()
+	return okReply
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"github.com/thedeveloperr/redis-clone/hashmap"
+	"github.com/thedeveloperr/redis-clone/sortedSetMap"
+	"testing"
+)
+
+func Test_Config_Get_Set_Commands(t *testing.T) {
+	db := CreateTestDbSetup()
+	defer db.ProcessCommand("CONFIG SET loglevel notice")
+	commands := []struct {
+		command  string
+		expected string
+	}{
+		{"CONFIG GET port", "1) 'port'\n2) '6379'\n"},
+		{"CONFIG GET *FSYNC*", "1) 'appendfsync'\n2) 'everysec'\n"},
+		{"CONFIG GET port http-port", "1) 'http-port'\n2) '8080'\n3) 'port'\n4) '6379'\n"},
+		{"CONFIG GET maxmemory*", "1) 'maxmemory'\n2) '0'\n3) 'maxmemory-policy'\n4) 'noeviction'\n"},
+		{"CONFIG GET append?nly", "1) 'appendonly'\n2) 'no'\n"},
+		{"CONFIG SET port 6380", "ERR CONFIG SET failed (possibly related to argument 'port') - can't set immutable config"},
+		{"CONFIG SET maxmemory 1mb LOGLEVEL warning", "OK"},
+		{"CONFIG GET maxmemory loglevel", "1) 'loglevel'\n2) 'warning'\n3) 'maxmemory'\n4) '1048576'\n"},
+		{"CONFIG SET loglevel debug loglevel notice", "ERR CONFIG SET failed (possibly related to argument 'loglevel') - duplicate parameter"},
+		{"CONFIG SET lua-time-limit 10 appendfsync sometimes", "ERR Invalid argument 'sometimes' for CONFIG SET 'appendfsync'"},
+		{"CONFIG GET lua-time-limit", "1) 'lua-time-limit'\n2) '5000'\n"},
+		{"CONFIG SET maxmemory-policy allkeys-lru", "ERR Invalid argument 'allkeys-lru' for CONFIG SET 'maxmemory-policy'"},
+		{"CONFIG SET maxmemory 0 appendfsync ALWAYS", "OK"},
+		{"CONFIG GET appendfsync", "1) 'appendfsync'\n2) 'always'\n"},
+		{"CONFIG SET maxmemory", "COMMAND NOT VALID"},
+		{"CONFIG SET maxmemory 0 loglevel", "COMMAND NOT VALID"},
+		{"CONFIG GET", "COMMAND NOT VALID"},
+		{"CONFIG REWRITE now", "COMMAND NOT VALID"},
+	}
+	for _, c := range commands {
+		result := db.ProcessCommand(c.command)
+		if result != c.expected {
+			t.Errorf("Ran:" + c.command + ". Expected: " + c.expected + " but Got result:" + result)
+		}
+	}
+}
+
+func Test_Config_Encoding_Limits(t *testing.T) {
+	db := CreateTestDbSetup()
+	defer db.ProcessCommand("CONFIG SET zset-max-listpack-entries 128 zset-max-listpack-value 64 hll-sparse-max-bytes 3000")
+	commands := []struct {
+		command  string
+		expected string
+	}{
+		{"CONFIG GET zset-max-listpack-*", "1) 'zset-max-listpack-entries'\n2) '128'\n3) 'zset-max-listpack-value'\n4) '64'\n"},
+		{"CONFIG GET hll-sparse-max-bytes", "1) 'hll-sparse-max-bytes'\n2) '3000'\n"},
+		{"CONFIG SET zset-max-listpack-entries 4 zset-max-listpack-value 1kb hll-sparse-max-bytes 200", "OK"},
+		{"CONFIG GET zset-max-listpack-* hll*", "1) 'hll-sparse-max-bytes'\n2) '200'\n3) 'zset-max-listpack-entries'\n4) '4'\n5) 'zset-max-listpack-value'\n6) '1024'\n"},
+		{"CONFIG SET zset-max-listpack-entries -1", "ERR Invalid argument '-1' for CONFIG SET 'zset-max-listpack-entries'"},
+		{"CONFIG SET zset-max-listpack-entries 1kb", "ERR Invalid argument '1kb' for CONFIG SET 'zset-max-listpack-entries'"},
+		{"CONFIG SET hll-sparse-max-bytes 8gb", "ERR Invalid argument '8gb' for CONFIG SET 'hll-sparse-max-bytes'"},
+	}
+	for _, c := range commands {
+		result := db.ProcessCommand(c.command)
+		if result != c.expected {
+			t.Errorf("Ran:" + c.command + ". Expected: " + c.expected + " but Got result:" + result)
+		}
+	}
+	if entries, value := sortedSetMap.GetListpackThresholds(); entries != 4 || value != 1024 {
+		t.Errorf("Expected the listpack thresholds to be 4 and 1024 but got %d and %d", entries, value)
+	}
+	if maxBytes := hashmap.GetHLLSparseMaxBytes(); maxBytes != 200 {
+		t.Errorf("Expected hll-sparse-max-bytes to be 200 but got %d", maxBytes)
+	}
+}
+
+func Test_Maxmemory_Refuses_Writes(t *testing.T) {
+	db := CreateTestDbSetup()
+	commands := []struct {
+		command  string
+		expected string
+	}{
+		{"RPUSH list a b", "2"},
+		{"CONFIG SET maxmemory 1", "OK"},
+		{"SET k v", "OOM command not allowed when used memory > 'maxmemory'."},
+		{"RPUSH list c", "OOM command not allowed when used memory > 'maxmemory'."},
+		{"LPOP list", "a"},
+		{"LRANGE list 0 -1", "1) 'b'\n"},
+		{"CONFIG SET maxmemory 0", "OK"},
+		{"SET k v", "OK"},
+	}
+	for _, c := range commands {
+		result := db.ProcessCommand(c.command)
+		if result != c.expected {
+			t.Errorf("Ran:" + c.command + ". Expected: " + c.expected + " but Got result:" + result)
+		}
+	}
+}
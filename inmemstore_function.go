@@ -0,0 +1,166 @@
+package main
+
+import (
+	"github.com/thedeveloperr/redis-clone/lua"
+)
+
+// First argument of FUNCTION DUMP payloads, changed if their format changes
+const FUNCTION_DUMP_VERSION = "FUNCTIONS1"
+
+// Runs FCALL, FCALL_RO and FUNCTION commands. Functions run like scripts,
+// holding the command lock exclusively. Changes to the libraries are logged
+// to the AOF so they are loaded again on restart. handled is false if
+// commType isn't one of them.
+func (store *InMemoryStore) processFunctionCommand(commType string, key string, args [][2]string, command string) (result Reply, handled bool) {
+	switch commType {
+	case "FCALL", "FCALL_RO":
+		return store.FCALL(key, args[0][0], firstOfPairs(args[1:]), commType == "FCALL_RO"), true
+	case "FUNCTION":
+	default:
+		return Reply{}, false
+	}
+	arguments := firstOfPairs(args)
+	switch key {
+	case "LOAD":
+		result = store.FUNCTION_LOAD(arguments[len(arguments)-1], len(arguments) == 2)
+	case "LIST":
+		pattern, withCode := "*", false
+		for i := 0; i < len(arguments); i++ {
+			if arguments[i] == "WITHCODE" {
+				withCode = true
+			} else {
+				pattern = arguments[i+1]
+				i++
+			}
+		}
+		return store.FUNCTION_LIST(pattern, withCode), true
+	case "DELETE":
+		result = store.FUNCTION_DELETE(arguments[0])
+	case "DUMP":
+		return store.FUNCTION_DUMP(), true
+	case "RESTORE":
+		policy := "APPEND"
+		if len(arguments) == 2 {
+			policy = arguments[1]
+		}
+		result = store.FUNCTION_RESTORE(arguments[0], policy)
+	case "FLUSH":
+		store.functions.add(nil, false, true)
+		result = okReply
+	}
+	if !result.isError() {
+		// formatted again so code with line breaks stays on one line
+		store.appendToAOF(formatCommand(append([]string{"FUNCTION", key}, arguments...)...))
+	}
+	return result, true
+}
+
+// Loads a library and returns its name. REPLACE replaces a library with the
+// same name, whose functions are removed. Perform FUNCTION LOAD [REPLACE] code command
+func (store *InMemoryStore) FUNCTION_LOAD(code string, replace bool) Reply {
+	library, message := loadLibrary(code)
+	if message != "" {
+		return errorReply(message)
+	}
+	if message := store.functions.add([]*functionLibrary{library}, replace, false); message != "" {
+		return errorReply(message)
+	}
+	return bulkReply(library.name)
+}
+
+// Lists the libraries whose name matches the glob pattern with their
+// functions and flags. Perform FUNCTION LIST [LIBRARYNAME pattern] [WITHCODE] command
+func (store *InMemoryStore) FUNCTION_LIST(pattern string, withCode bool) Reply {
+	var libraries []Reply
+	for _, library := range store.functions.list() {
+		if !globMatch(pattern, library.name) {
+			continue
+		}
+		var functions []Reply
+		for _, name := range sortedFunctionNames(library) {
+			function := library.functions[name]
+			description := nilReply
+			if function.description != "" {
+				description = bulkReply(function.description)
+			}
+			functions = append(functions, arrayReply([]Reply{
+				bulkReply("name"), bulkReply(name),
+				bulkReply("description"), description,
+				bulkReply("flags"), bulkArrayReply(function.flags),
+			}))
+		}
+		fields := []Reply{
+			bulkReply("library_name"), bulkReply(library.name),
+			bulkReply("engine"), bulkReply("LUA"),
+			bulkReply("functions"), arrayReply(functions),
+		}
+		if withCode {
+			fields = append(fields, bulkReply("library_code"), bulkReply(library.code))
+		}
+		libraries = append(libraries, arrayReply(fields))
+	}
+	return arrayReply(libraries)
+}
+
+// Removes a library and its functions. Perform FUNCTION DELETE library command
+func (store *InMemoryStore) FUNCTION_DELETE(name string) Reply {
+	if !store.functions.remove(name) {
+		return errorReply("ERR Library not found")
+	}
+	return okReply
+}
+
+// Serializes every library for FUNCTION RESTORE: the payload is the code of
+// each library after a version, in the form of a command line.
+// Perform FUNCTION DUMP command
+func (store *InMemoryStore) FUNCTION_DUMP() Reply {
+	payload := []string{FUNCTION_DUMP_VERSION}
+	for _, library := range store.functions.list() {
+		payload = append(payload, library.code)
+	}
+	return bulkReply(formatCommand(payload...))
+}
+
+// Loads the libraries of a FUNCTION DUMP payload, all of them or none. APPEND
+// fails if one of them exists, REPLACE replaces it and FLUSH removes every
+// library first. Perform FUNCTION RESTORE payload [FLUSH|APPEND|REPLACE] command
+func (store *InMemoryStore) FUNCTION_RESTORE(payload string, policy string) Reply {
+	codes, ok := splitArgs(payload)
+	if !ok || len(codes) == 0 || codes[0] != FUNCTION_DUMP_VERSION {
+		return errorReply("ERR payload version or checksum are wrong")
+	}
+	var libraries []*functionLibrary
+	for _, code := range codes[1:] {
+		library, message := loadLibrary(code)
+		if message != "" {
+			return errorReply(message)
+		}
+		libraries = append(libraries, library)
+	}
+	if message := store.functions.add(libraries, policy == "REPLACE", policy == "FLUSH"); message != "" {
+		return errorReply(message)
+	}
+	return okReply
+}
+
+// Calls a function with its keys and arguments as its two parameters.
+// Functions registered with the no-writes flag can't call commands which
+// write, and FCALL_RO only calls those. Perform FCALL function numkeys [key ...] [arg ...] command
+func (store *InMemoryStore) FCALL(name string, numkeys string, args []string, readOnly bool) Reply {
+	keys, argv, failure := scriptKeys(numkeys, args)
+	if failure.isError() {
+		return failure
+	}
+	function := store.functions.function(name)
+	if function == nil {
+		return errorReply("ERR Function not found")
+	}
+	if readOnly && !function.readOnly() {
+		return errorReply("ERR Can not execute a script with write flag using *_ro command.")
+	}
+	state := store.scriptState(function.readOnly())
+	results, err := runRecovered(func() ([]lua.Value, error) {
+		return state.Call(function.callback, luaStrings(keys), luaStrings(argv))
+	})
+	return scriptReply(results, err, "ERR Error running function "+name+": ")
+}
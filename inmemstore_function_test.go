@@ -0,0 +1,120 @@
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+const testLibrary = `"#!lua name=lib\n` +
+	`local function fallback(value, default) if value then return value end return default end\n` +
+	`redis.register_function('get_or', function(keys, args) return fallback(redis.call('GET', keys[1]), args[1]) end)\n` +
+	`redis.register_function{function_name = 'peek', callback = function(keys) return redis.call('GET', keys[1]) end, flags = {'no-writes'}, description = 'reads a key'}\n` +
+	`redis.register_function{function_name = 'sneaky', callback = function(keys) return redis.call('SET', keys[1], 'x') end, flags = {'no-writes'}}"`
+
+func Test_Function_Commands(t *testing.T) {
+	db := CreateTestDbSetup()
+	commands := []struct {
+		command  string
+		expected string
+	}{
+		{"FUNCTION LIST", "(empty list or set)"},
+		{"FUNCTION LOAD " + testLibrary, "lib"},
+		{"FUNCTION LOAD " + testLibrary, "ERR Library 'lib' already exists"},
+		{"FUNCTION LOAD REPLACE " + testLibrary, "lib"},
+		{"FCALL get_or 1 k default", "default"},
+		{"SET k v", "OK"},
+		{"FCALL get_or 1 k default", "v"},
+		{"FCALL_RO peek 1 k", "v"},
+		{"FCALL_RO get_or 1 k default", "ERR Can not execute a script with write flag using *_ro command."},
+		{"FCALL sneaky 1 k", "ERR Write commands are not allowed from read-only scripts."},
+		{"FCALL missing 0", "ERR Function not found"},
+		{"FCALL get_or 2 k", "ERR Number of keys can't be greater than number of args"},
+		{"FUNCTION LIST LIBRARYNAME l*", "1) 1) 'library_name'\n   2) 'lib'\n   3) 'engine'\n   4) 'LUA'\n   5) 'functions'\n   6) 1) 1) 'name'\n         2) 'get_or'\n         3) 'description'\n         4) (nil)\n         5) 'flags'\n         6) (empty list or set)\n      2) 1) 'name'\n         2) 'peek'\n         3) 'description'\n         4) 'reads a key'\n         5) 'flags'\n         6) 1) 'no-writes'\n      3) 1) 'name'\n         2) 'sneaky'\n         3) 'description'\n         4) (nil)\n         5) 'flags'\n         6) 1) 'no-writes'\n"},
+		{"FUNCTION LIST LIBRARYNAME other*", "(empty list or set)"},
+		{"FUNCTION LOAD \"#!lua name=other\nredis.register_function('peek', function() return 1 end)\"", "ERR Function peek already exists"},
+		{"FUNCTION LOAD \"#!lua name=other\nredis.register_function('one', function() return 1 end)\"", "other"},
+		{"FUNCTION LOAD \"return 1\"", "ERR Missing library metadata"},
+		{"FUNCTION LOAD \"#!js name=x\"", "ERR Engine 'js' not found"},
+		{"FUNCTION LOAD \"#!lua\"", "ERR Library name was not given"},
+		{"FUNCTION LOAD \"#!lua name=bad-name\"", "ERR Library names can only contain letters, numbers, or underscores(_) and must be at least one character long"},
+		{"FUNCTION LOAD \"#!lua name=empty\nlocal x = 1\"", "ERR No functions registered"},
+		{"FUNCTION LOAD \"#!lua name=broken\nredis.register_function('f',\"", "ERR Error compiling function: user_function:2: unexpected symbol near '<eof>'"},
+		{"FUNCTION LOAD \"#!lua name=calls\nredis.call('SET', 'k', 'v')\"", "ERR Error registering functions: user_function:2: attempt to call field 'call' (a nil value)"},
+		{"FUNCTION LOAD \"#!lua name=flags\nredis.register_function{function_name = 'f', callback = function() end, flags = {'fast'}}\"", "ERR Error registering functions: user_function:2: unknown flag given"},
+		{"FUNCTION DELETE other", "OK"},
+		{"FUNCTION DELETE other", "ERR Library not found"},
+		{"FCALL one 0", "ERR Function not found"},
+		{"FUNCTION RESTORE nonsense", "ERR payload version or checksum are wrong"},
+		{"FUNCTION FLUSH", "OK"},
+		{"FCALL_RO peek 1 k", "ERR Function not found"},
+		{"FUNCTION LOAD", "COMMAND NOT VALID"},
+		{"FUNCTION RESTORE payload MERGE", "COMMAND NOT VALID"},
+		{"FCALL get_or", "COMMAND NOT VALID"},
+	}
+	for _, c := range commands {
+		result := db.ProcessCommand(c.command)
+		if result != c.expected {
+			t.Errorf("Ran:" + c.command + ". Expected: " + c.expected + " but Got result:" + result)
+		}
+	}
+}
+
+func Test_FUNCTION_DUMP_RESTORE(t *testing.T) {
+	db := CreateTestDbSetup()
+	db.ProcessCommand("FUNCTION LOAD " + testLibrary)
+	payload := db.ProcessCommand("FUNCTION DUMP")
+	restore := formatCommand("FUNCTION", "RESTORE", payload)
+
+	other := CreateTestDbSetup()
+	other.ProcessCommand("FUNCTION LOAD \"#!lua name=lib\nredis.register_function('old', function() return 1 end)\"")
+	if result := other.ProcessCommand(restore); result != "ERR Library 'lib' already exists" {
+		t.Errorf("Expected APPEND to refuse an existing library but got " + result)
+	}
+	if result := other.ProcessCommand(restore + " REPLACE"); result != "OK" {
+		t.Errorf("Expected REPLACE to restore the library but got " + result)
+	}
+	other.ProcessCommand("SET k v")
+	if result := other.ProcessCommand("FCALL_RO peek 1 k"); result != "v" {
+		t.Errorf("Expected the restored function to run but got " + result)
+	}
+	if result := other.ProcessCommand("FCALL old 0"); result != "ERR Function not found" {
+		t.Errorf("Expected the replaced library's functions to be gone but got " + result)
+	}
+	other.ProcessCommand("FUNCTION LOAD \"#!lua name=extra\nredis.register_function('extra', function() return 1 end)\"")
+	if result := other.ProcessCommand(restore + " FLUSH"); result != "OK" {
+		t.Errorf("Expected FLUSH to restore the library but got " + result)
+	}
+	if result := other.ProcessCommand("FCALL extra 0"); result != "ERR Function not found" {
+		t.Errorf("Expected FLUSH to remove other libraries but got " + result)
+	}
+}
+
+func TestAOFReplaysFunctions(t *testing.T) {
+	AOFfilename, remove := tempAOF(t)
+	defer remove()
+	db := CreateInMemStore(1, AOFfilename)
+	db.ProcessCommand("FUNCTION LOAD " + testLibrary)
+	db.ProcessCommand("FUNCTION LOAD \"#!lua name=gone\nredis.register_function('gone', function() return 1 end)\"")
+	db.ProcessCommand("FUNCTION DELETE gone")
+	db.ProcessCommand("FCALL get_or 1 k default")
+	db.ProcessCommand("FUNCTION LOAD \"not a library\"")
+	time.Sleep(2 * time.Second) //give extra time to persist to make sure all data is flushed
+
+	content, _ := ioutil.ReadFile(AOFfilename)
+	expected := "FUNCTION LOAD " + testLibrary + "\n" +
+		"FUNCTION LOAD \"#!lua name=gone\\nredis.register_function('gone', function() return 1 end)\"\n" +
+		"FUNCTION DELETE gone\n"
+	if string(content) != expected {
+		t.Errorf("Expected AOF:\n" + expected + "Got:\n" + string(content))
+	}
+	replayed := CreateInMemStore(1, AOFfilename)
+	for command, expected := range map[string]string{
+		"FCALL get_or 1 k default": "default",
+		"FCALL gone 0":             "ERR Function not found",
+	} {
+		if result := replayed.ProcessCommand(command); result != expected {
+			t.Errorf("Ran:" + command + ". Expected: " + expected + " but Got result:" + result)
+		}
+	}
+}
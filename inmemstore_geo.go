@@ -0,0 +1,243 @@
+package main
+
+import (
+	"github.com/thedeveloperr/redis-clone/geohash"
+	"github.com/thedeveloperr/redis-clone/hashmap"
+	"github.com/thedeveloperr/redis-clone/sortedSetMap"
+	"sort"
+	"strconv"
+)
+
+// Runs commands on geo indexes. handled is false if commType isn't one of them.
+func (store *InMemoryStore) processGeoCommand(commType string, key string, args [][2]string, command string) (result Reply, handled bool) {
+	switch commType {
+	case "GEOADD":
+		result, changed := store.GEOADD(key, args[0][0], args[0][1] == "CH", args[1:])
+		// moved members are only counted with CH but still have to be logged
+		if changed {
+			store.appendToAOF(command)
+			store.notifyKeyspaceEvent(NOTIFY_ZSET, "zadd", key)
+		}
+		return result, true
+	case "GEOPOS":
+		return store.GEOPOS(key, firstOfPairs(args)), true
+	case "GEODIST":
+		return store.GEODIST(key, args[0][0], args[0][1], args[1][0]), true
+	case "GEOHASH":
+		return store.GEOHASH(key, firstOfPairs(args)), true
+	case "GEOSEARCH":
+		return store.GEOSEARCH(key, args), true
+	case "GEOSEARCHSTORE":
+		existed := store.keyExists(key)
+		result := store.GEOSEARCHSTORE(key, args[0][0], args[1:])
+		if !result.isError() {
+			store.appendToAOF(command)
+			store.notifyStore(NOTIFY_ZSET, "geosearchstore", key, existed)
+		}
+		return result, true
+	}
+	return Reply{}, false
+}
+
+func invalidGeoPair(longitude float64, latitude float64) Reply {
+	return errorReply("ERR invalid longitude,latitude pair " + strconv.FormatFloat(longitude, 'f', 6, 64) + "," + strconv.FormatFloat(latitude, 'f', 6, 64))
+}
+
+func formatGeoDistance(meters float64, unit string) Reply {
+	return doubleReply(strconv.FormatFloat(meters/geoUnits[unit], 'f', 4, 64))
+}
+
+// Longitude and latitude as bulk strings, as redis replies them
+func geoPositionReply(score float64) Reply {
+	longitude, latitude := geohash.DecodeScore(score)
+	return bulkArrayReply([]string{hashmap.FormatFloat(longitude), hashmap.FormatFloat(latitude)})
+}
+
+// Adds members at their positions, or moves existing ones. Returns the number
+// added, or also moved with CH, and whether any member was added or moved. Perform GEOADD key [NX|XX] [CH] longitude latitude member [longitude latitude member ...] command
+func (store *InMemoryStore) GEOADD(key string, condition string, countChanged bool, args [][2]string) (result Reply, changed bool) {
+	var members []string
+	var scores []float64
+	for i := 0; i < len(args); i += 2 {
+		longitude, _ := strconv.ParseFloat(args[i][0], 64)
+		latitude, _ := strconv.ParseFloat(args[i][1], 64)
+		if !geohash.IsValid(longitude, latitude) {
+			return invalidGeoPair(longitude, latitude), false
+		}
+		members = append(members, args[i+1][0])
+		scores = append(scores, geohash.Score(longitude, latitude))
+	}
+	added, updated := store.sortedSet.AddOrUpdate(key, members, scores, condition)
+	if countChanged {
+		return integerReply(int64(added + updated)), added+updated > 0
+	}
+	return integerReply(int64(added)), added+updated > 0
+}
+
+// Positions of members as longitude and latitude, (nil) for missing ones. Perform GEOPOS key [member ...] command
+func (store *InMemoryStore) GEOPOS(key string, members []string) Reply {
+	scores, exists := store.sortedSet.Scores(key, members)
+	items := make([]Reply, len(members))
+	for i := range members {
+		items[i] = nilReply
+		if exists[i] {
+			items[i] = geoPositionReply(scores[i])
+		}
+	}
+	return arrayReply(items)
+}
+
+// Distance between two members in unit, (nil) if either is missing. Perform GEODIST key member1 member2 [M|KM|FT|MI] command
+func (store *InMemoryStore) GEODIST(key string, first string, second string, unit string) Reply {
+	scores, exists := store.sortedSet.Scores(key, []string{first, second})
+	if !exists[0] || !exists[1] {
+		return nilReply
+	}
+	longitude1, latitude1 := geohash.DecodeScore(scores[0])
+	longitude2, latitude2 := geohash.DecodeScore(scores[1])
+	return formatGeoDistance(geohash.Distance(longitude1, latitude1, longitude2, latitude2), unit)
+}
+
+// Standard geohash strings of members. Perform GEOHASH key [member ...] command
+func (store *InMemoryStore) GEOHASH(key string, members []string) Reply {
+	scores, exists := store.sortedSet.Scores(key, members)
+	items := make([]Reply, len(members))
+	for i := range members {
+		items[i] = nilReply
+		if exists[i] {
+			items[i] = bulkReply(geohash.String(scores[i]))
+		}
+	}
+	return arrayReply(items)
+}
+
+// Member found by a geo search with its distance in meters from the center
+type geoMatch struct {
+	member   string
+	score    float64
+	distance float64
+}
+
+// Finds the members of key within the shape described by arguments parsed
+// by parseGeoSearch, sorted and limited as asked. Returns an error reply
+// instead when the center is invalid.
+func (store *InMemoryStore) geoSearch(key string, args [][2]string) (matches []geoMatch, failure Reply) {
+	var shape geohash.Shape
+	if args[0][0] == "FROMMEMBER" {
+		scores, exists := store.sortedSet.Scores(key, []string{args[0][1]})
+		if !exists[0] {
+			return nil, errorReply("ERR could not decode requested zset member")
+		}
+		shape.Longitude, shape.Latitude = geohash.DecodeScore(scores[0])
+	} else {
+		shape.Longitude, _ = strconv.ParseFloat(args[1][0], 64)
+		shape.Latitude, _ = strconv.ParseFloat(args[1][1], 64)
+		if !geohash.IsValid(shape.Longitude, shape.Latitude) {
+			return nil, invalidGeoPair(shape.Longitude, shape.Latitude)
+		}
+	}
+	conversion := geoUnits[args[2][1]]
+	if args[2][0] == "BYBOX" {
+		shape.IsBox = true
+		shape.Width, _ = strconv.ParseFloat(args[3][0], 64)
+		shape.Height, _ = strconv.ParseFloat(args[3][1], 64)
+		shape.Width *= conversion
+		shape.Height *= conversion
+	} else {
+		shape.Radius, _ = strconv.ParseFloat(args[3][0], 64)
+		shape.Radius *= conversion
+	}
+	order := args[4][0]
+	count, _ := strconv.Atoi(args[4][1])
+	any := args[5][0] == "ANY"
+
+	var ranges []sortedSetMap.ScoreRange
+	for _, r := range shape.ScoreRanges() {
+		ranges = append(ranges, sortedSetMap.ScoreRange{Min: r[0], Max: r[1], MaxExclusive: true})
+	}
+	members, scores := store.sortedSet.GetMembersAndScoreInScoreRanges(key, ranges)
+	matches = []geoMatch{}
+	for i, member := range members {
+		longitude, latitude := geohash.DecodeScore(scores[i])
+		if distance, ok := shape.Contains(longitude, latitude); ok {
+			matches = append(matches, geoMatch{member, scores[i], distance})
+			if any && len(matches) == count {
+				break
+			}
+		}
+	}
+	// like redis a COUNT without ANY returns the nearest members
+	if order == "" && count > 0 && !any {
+		order = "ASC"
+	}
+	if order != "" {
+		sort.SliceStable(matches, func(i, j int) bool {
+			if order == "DESC" {
+				return matches[i].distance > matches[j].distance
+			}
+			return matches[i].distance < matches[j].distance
+		})
+	}
+	if count > 0 && len(matches) > count {
+		matches = matches[:count]
+	}
+	return matches, Reply{}
+}
+
+// Members within a radius or box around a member or a position.
+// Perform GEOSEARCH key FROMMEMBER member|FROMLONLAT longitude latitude BYRADIUS radius unit|BYBOX width height unit
+// [ASC|DESC] [COUNT count [ANY]] [WITHCOORD] [WITHDIST] [WITHHASH] command
+func (store *InMemoryStore) GEOSEARCH(key string, args [][2]string) Reply {
+	matches, failure := store.geoSearch(key, args)
+	if failure.isError() {
+		return failure
+	}
+	flags := make(map[string]bool)
+	for _, flag := range args[6:] {
+		flags[flag[0]] = true
+	}
+	unit := args[2][1]
+	items := make([]Reply, len(matches))
+	for i, match := range matches {
+		if len(flags) == 0 {
+			items[i] = bulkReply(match.member)
+			continue
+		}
+		// fields are always in this order whatever the order of the flags
+		fields := []Reply{bulkReply(match.member)}
+		if flags["WITHDIST"] {
+			fields = append(fields, bulkReply(formatGeoDistance(match.distance, unit).Text))
+		}
+		if flags["WITHHASH"] {
+			fields = append(fields, integerReply(int64(match.score)))
+		}
+		if flags["WITHCOORD"] {
+			fields = append(fields, geoPositionReply(match.score))
+		}
+		items[i] = arrayReply(fields)
+	}
+	return arrayReply(items)
+}
+
+// Stores the members GEOSEARCH would return in dest with their geohash, or
+// their distance in the search unit with STOREDIST. Returns how many.
+// Perform GEOSEARCHSTORE dest source FROMMEMBER member|FROMLONLAT longitude latitude BYRADIUS radius unit|BYBOX width height unit
+// [ASC|DESC] [COUNT count [ANY]] [STOREDIST] command
+func (store *InMemoryStore) GEOSEARCHSTORE(dest string, source string, args [][2]string) Reply {
+	matches, failure := store.geoSearch(source, args)
+	if failure.isError() {
+		return failure
+	}
+	storeDistance := len(args) > 6
+	members := make([]string, len(matches))
+	scores := make([]float64, len(matches))
+	for i, match := range matches {
+		members[i] = match.member
+		scores[i] = match.score
+		if storeDistance {
+			scores[i] = match.distance / geoUnits[args[2][1]]
+		}
+	}
+	store.sortedSet.Replace(dest, members, scores)
+	return integerReply(int64(len(matches)))
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Places from the redis geo command documentation
+func Test_Geo_Commands(t *testing.T) {
+	db := CreateTestDbSetup()
+	commands := []struct {
+		command  string
+		expected string
+	}{
+		{"GEOADD Sicily 13.361389 38.115556 Palermo 15.087269 37.502669 Catania", "2"},
+		{"GEOADD Sicily 13.361389 38.115556 Palermo", "0"},
+		{"GEODIST Sicily Palermo Catania", "166274.1516"},
+		{"GEODIST Sicily Palermo Catania km", "166.2742"},
+		{"GEODIST Sicily Palermo Missing", "(nil)"},
+		{"GEOPOS Sicily Palermo Missing", "1) 1) '13.361389338970184'\n   2) '38.1155563954963'\n2) (nil)\n"},
+		{"GEOHASH Sicily Palermo Catania", "1) 'sqc8b49rny0'\n2) 'sqdtr74hyu0'\n"},
+		{"GEOSEARCH Sicily FROMLONLAT 15 37 BYRADIUS 200 km ASC", "1) 'Catania'\n2) 'Palermo'\n"},
+		{"GEOSEARCH Sicily FROMLONLAT 15 37 BYRADIUS 200 km ASC WITHCOORD WITHHASH WITHDIST",
+			"1) 1) 'Catania'\n   2) '56.4413'\n   3) 3479447370796909\n   4) 1) '15.087267458438873'\n      2) '37.50266842333162'\n" +
+				"2) 1) 'Palermo'\n   2) '190.4424'\n   3) 3479099956230698\n   4) 1) '13.361389338970184'\n      2) '38.1155563954963'\n"},
+		{"GEOADD Sicily 12.758489 38.788135 edge1 17.241510 38.788135 edge2", "2"},
+		{"GEOSEARCH Sicily FROMLONLAT 15 37 BYBOX 400 400 km ASC WITHDIST",
+			"1) 1) 'Catania'\n   2) '56.4413'\n2) 1) 'Palermo'\n   2) '190.4424'\n3) 1) 'edge2'\n   2) '279.7403'\n4) 1) 'edge1'\n   2) '279.7405'\n"},
+		{"GEOSEARCH Sicily FROMLONLAT 15 37 BYRADIUS 200 km COUNT 1", "1) 'Catania'\n"},
+		{"GEOSEARCH Sicily FROMLONLAT 15 37 BYRADIUS 500 km DESC COUNT 2", "1) 'edge1'\n2) 'edge2'\n"},
+		{"GEOSEARCH Sicily FROMMEMBER Palermo BYRADIUS 100 km ASC", "1) 'Palermo'\n2) 'edge1'\n"},
+		{"GEOSEARCH Sicily FROMLONLAT 0 0 BYRADIUS 10 km", "(empty list or set)"},
+		{"GEOSEARCHSTORE near Sicily FROMLONLAT 15 37 BYRADIUS 200 km STOREDIST", "2"},
+		{"ZRANGE near 0 -1 WITHSCORES", "1) 'Catania'\n2) 56.4412578701582\n3) 'Palermo'\n4) 190.44242984775795\n"},
+		{"GEOSEARCHSTORE near Sicily FROMLONLAT 15 37 BYRADIUS 200 km COUNT 1", "1"},
+		{"GEOHASH near Catania Palermo", "1) 'sqdtr74hyu0'\n2) (nil)\n"},
+		{"GEOADD Sicily XX CH 13.5 38 Palermo 1 1 Other", "1"},
+		{"GEOADD Sicily NX 13.6 38 Palermo 1 1 Other", "1"},
+		{"GEOPOS Sicily Other", "1) 1) '0.9999999403953552'\n   2) '0.9999994591429768'\n"},
+		{"GEOADD Sicily 200 10 Bad", "ERR invalid longitude,latitude pair 200.000000,10.000000"},
+		{"GEOSEARCH Sicily FROMMEMBER Missing BYRADIUS 100 km", "ERR could not decode requested zset member"},
+		{"GEOSEARCH Sicily BYRADIUS 100 km", "COMMAND NOT VALID"},
+		{"GEOSEARCH Sicily FROMLONLAT 15 37 BYRADIUS 100 km ANY", "COMMAND NOT VALID"},
+		{"GEOSEARCH Sicily FROMLONLAT 15 37 BYRADIUS 100 parsec", "COMMAND NOT VALID"},
+		{"GEOSEARCHSTORE near Sicily FROMLONLAT 15 37 BYRADIUS 100 km WITHDIST", "COMMAND NOT VALID"},
+		{"GEOADD Sicily 13 38", "COMMAND NOT VALID"},
+	}
+	for _, c := range commands {
+		result := db.ProcessCommand(c.command)
+		if result != c.expected {
+			t.Errorf("Ran:" + c.command + ". Expected: " + c.expected + " but Got result:" + result)
+		}
+	}
+}
+
+func TestAOFReplaysGeoCommands(t *testing.T) {
+	AOFfilename, remove := tempAOF(t)
+	defer remove()
+	db := CreateInMemStore(1, AOFfilename)
+	db.ProcessCommand("GEOADD Sicily 13.361389 38.115556 Palermo 15.087269 37.502669 Catania")
+	db.ProcessCommand("GEOADD Sicily CH 13.5 38 Palermo")
+	db.ProcessCommand("GEOSEARCHSTORE near Sicily FROMLONLAT 15 37 BYRADIUS 200 km STOREDIST")
+	time.Sleep(2 * time.Second) //give extra time to persist to make sure all data is flushed
+
+	replayed := CreateInMemStore(1, AOFfilename)
+	for _, check := range []string{"GEOPOS Sicily Palermo Catania", "ZRANGE near 0 -1 WITHSCORES"} {
+		if result, expected := replayed.ProcessCommand(check), db.ProcessCommand(check); result != expected {
+			t.Errorf("Ran:" + check + ". Expected:\n" + expected + "Got result:\n" + result)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"github.com/thedeveloperr/redis-clone/hashObjectMap"
+	"github.com/thedeveloperr/redis-clone/hashmap"
+	"strconv"
+	"time"
+)
+
+// Runs commands on hashes. handled is false if commType isn't one of them.
+func (store *InMemoryStore) processHashCommand(commType string, key string, args [][2]string, command string) (result Reply, handled bool) {
+	switch commType {
+	case "HSET":
+		result := store.HSET(key, args)
+		store.appendToAOF(command)
+		store.notifyKeyspaceEvent(NOTIFY_HASH, "hset", key)
+		return result, true
+	case "HSETNX":
+		result := store.HSETNX(key, args[0][0], args[0][1])
+		if result.Integer == 1 {
+			store.appendToAOF(command)
+			store.notifyKeyspaceEvent(NOTIFY_HASH, "hset", key)
+		}
+		return result, true
+	case "HGET":
+		return store.HGET(key, args[0][0]), true
+	case "HMGET":
+		return store.HMGET(key, firstOfPairs(args)), true
+	case "HGETALL":
+		return store.HGETALL(key), true
+	case "HDEL":
+		result := store.HDEL(key, firstOfPairs(args))
+		if result.Integer != 0 {
+			store.appendToAOF(command)
+			store.notifyKeyspaceEvent(NOTIFY_HASH, "hdel", key)
+			store.notifyIfRemoved(key)
+		}
+		return result, true
+	case "HEXISTS":
+		return store.HEXISTS(key, args[0][0]), true
+	case "HLEN":
+		return store.HLEN(key), true
+	case "HKEYS":
+		return store.HKEYS(key), true
+	case "HVALS":
+		return store.HVALS(key), true
+	case "HSTRLEN":
+		return store.HSTRLEN(key, args[0][0]), true
+	case "HINCRBY":
+		delta, _ := strconv.ParseInt(args[0][1], 10, 64)
+		result, err := store.HINCRBY(key, args[0][0], delta)
+		if err != nil {
+			return errorReply(err.Error()), true
+		}
+		store.appendToAOF(command)
+		store.notifyKeyspaceEvent(NOTIFY_HASH, "hincrby", key)
+		return result, true
+	case "HINCRBYFLOAT":
+		delta, _ := strconv.ParseFloat(args[0][1], 64)
+		result, err := store.HINCRBYFLOAT(key, args[0][0], delta)
+		if err != nil {
+			return errorReply(err.Error()), true
+		}
+		// Logged as the value it set, like INCRBYFLOAT, so replaying doesn't
+		// add up floats again. HSET keeps the TTL of the field too.
+		store.appendToAOF(formatCommand("HSET", key, args[0][0], result.Text))
+		store.notifyKeyspaceEvent(NOTIFY_HASH, "hincrbyfloat", key)
+		return result, true
+	case "HRANDFIELD":
+		if len(args) == 0 {
+			return store.HRANDFIELD(key), true
+		}
+		count, _ := strconv.ParseInt(args[0][0], 10, 64)
+		return store.HRANDFIELD_COUNT(key, count, args[0][1] == "WITHVALUES"), true
+	case "HEXPIRE", "HPEXPIRE", "HEXPIREAT", "HPEXPIREAT":
+		at, _ := strconv.ParseInt(args[0][0], 10, 64)
+		var deadline time.Time
+		switch commType {
+		case "HEXPIRE":
+			deadline = time.Now().Add(time.Duration(at) * time.Second)
+		case "HPEXPIRE":
+			deadline = time.Now().Add(time.Duration(at) * time.Millisecond)
+		case "HEXPIREAT":
+			deadline = time.Unix(at, 0)
+		case "HPEXPIREAT":
+			deadline = fromUnixMilli(at)
+		}
+		fields := firstOfPairs(args[1:])
+		results := store.HPEXPIREAT(key, deadline, args[0][1], fields)
+		for _, code := range results {
+			if code == hashObjectMap.FIELD_TTL_SET || code == hashObjectMap.FIELD_DELETED {
+				// log absolute deadline so replay doesn't extend the TTL
+				store.appendToAOF(fieldsCommand("HPEXPIREAT", key, []string{strconv.FormatInt(unixMilli(deadline), 10), args[0][1]}, fields))
+				break
+			}
+		}
+		store.notifyFieldDeadlines(key, results)
+		return integerArrayReply(results), true
+	case "HTTL", "HPTTL":
+		if commType == "HPTTL" {
+			return store.HPTTL(key, firstOfPairs(args)), true
+		}
+		return store.HTTL(key, firstOfPairs(args)), true
+	case "HPERSIST":
+		results := store.HPERSIST(key, firstOfPairs(args))
+		for _, code := range results {
+			if code == hashObjectMap.FIELD_TTL_SET {
+				store.appendToAOF(command)
+				store.notifyKeyspaceEvent(NOTIFY_HASH, "hpersist", key)
+				break
+			}
+		}
+		return integerArrayReply(results), true
+	case "HSCAN":
+		cursor, match, count, noValues := scanOptions(args)
+		return store.HSCAN(key, cursor, match, count, noValues), true
+	}
+	return Reply{}, false
+}
+
+// Events of HEXPIRE and its variants like in redis: hexpire for fields
+// whose TTL was set, hdel for the ones removed as their deadline already
+// passed, and del if that removed the hash
+func (store *InMemoryStore) notifyFieldDeadlines(key string, results []int) {
+	set, deleted := false, false
+	for _, code := range results {
+		set = set || code == hashObjectMap.FIELD_TTL_SET
+		deleted = deleted || code == hashObjectMap.FIELD_DELETED
+	}
+	if set {
+		store.notifyKeyspaceEvent(NOTIFY_HASH, "hexpire", key)
+	}
+	if deleted {
+		store.notifyKeyspaceEvent(NOTIFY_HASH, "hdel", key)
+		store.notifyIfRemoved(key)
+	}
+}
+
+// First element of every argument pair, eg. the fields of HDEL
+func firstOfPairs(args [][2]string) []string {
+	values := make([]string, len(args))
+	for i := range args {
+		values[i] = args[i][0]
+	}
+	return values
+}
+
+// Reads arguments parsed by parseScanOptions. count defaults to 10 like redis.
+func scanOptions(args [][2]string) (cursor uint64, match string, count int, noValues bool) {
+	cursor, _ = strconv.ParseUint(args[0][0], 10, 64)
+	count = 10
+	for _, option := range args[1:] {
+		switch option[0] {
+		case "MATCH":
+			match = option[1]
+		case "COUNT":
+			count, _ = strconv.Atoi(option[1])
+		case "NOVALUES":
+			noValues = true
+		}
+	}
+	return cursor, match, count, noValues
+}
+
+// Builds "name key [options ...] FIELDS numfields field [field ...]", skipping empty options
+func fieldsCommand(name string, key string, options []string, fields []string) string {
+	args := []string{name, key}
+	for _, option := range options {
+		if option != "" {
+			args = append(args, option)
+		}
+	}
+	args = append(args, "FIELDS", strconv.Itoa(len(fields)))
+	return formatCommand(append(args, fields...)...)
+}
+
+// Sets fields and returns number of new ones. Perform HSET key field value [field value ...] command
+func (store *InMemoryStore) HSET(key string, pairs [][2]string) Reply {
+	return integerReply(int64(store.hashObject.Set(key, pairs)))
+}
+
+// Sets field if it doesn't exist. Perform HSETNX key field value command
+func (store *InMemoryStore) HSETNX(key string, field string, value string) Reply {
+	return integerReply(int64(store.hashObject.SetNX(key, field, value)))
+}
+
+// Value of field or nil. Perform HGET key field command
+func (store *InMemoryStore) HGET(key string, field string) Reply {
+	if value, exists := store.hashObject.Get(key, field); exists {
+		return bulkReply(value)
+	}
+	return nilReply
+}
+
+// Values of many fields. Perform HMGET key field [field ...] command
+func (store *InMemoryStore) HMGET(key string, fields []string) Reply {
+	values, exists := store.hashObject.MGet(key, fields)
+	items := make([]Reply, len(values))
+	for i := range values {
+		if exists[i] {
+			items[i] = bulkReply(values[i])
+		} else {
+			items[i] = nilReply
+		}
+	}
+	return arrayReply(items)
+}
+
+// Fields and values one after another. Perform HGETALL key command
+func (store *InMemoryStore) HGETALL(key string) Reply {
+	fields, values := store.hashObject.GetAll(key)
+	var items []string
+	for i := range fields {
+		items = append(items, fields[i], values[i])
+	}
+	return bulkArrayReply(items)
+}
+
+// Removes fields and returns how many existed. Perform HDEL key field [field ...] command
+func (store *InMemoryStore) HDEL(key string, fields []string) Reply {
+	return integerReply(int64(store.hashObject.Del(key, fields)))
+}
+
+// 1 if field exists. Perform HEXISTS key field command
+func (store *InMemoryStore) HEXISTS(key string, field string) Reply {
+	return booleanReply(store.hashObject.Exists(key, field))
+}
+
+// Number of fields. Perform HLEN key command
+func (store *InMemoryStore) HLEN(key string) Reply {
+	return integerReply(int64(store.hashObject.Len(key)))
+}
+
+// All field names. Perform HKEYS key command
+func (store *InMemoryStore) HKEYS(key string) Reply {
+	fields, _ := store.hashObject.GetAll(key)
+	return bulkArrayReply(fields)
+}
+
+// All values. Perform HVALS key command
+func (store *InMemoryStore) HVALS(key string) Reply {
+	_, values := store.hashObject.GetAll(key)
+	return bulkArrayReply(values)
+}
+
+// Length of value of field. Perform HSTRLEN key field command
+func (store *InMemoryStore) HSTRLEN(key string, field string) Reply {
+	return integerReply(int64(store.hashObject.StrLen(key, field)))
+}
+
+// Adds delta to integer in field. Perform HINCRBY key field increment command
+func (store *InMemoryStore) HINCRBY(key string, field string, delta int64) (Reply, error) {
+	result, err := store.hashObject.IncrBy(key, field, delta)
+	if err != nil {
+		return Reply{}, err
+	}
+	return integerReply(result), nil
+}
+
+// Adds delta to float in field. Perform HINCRBYFLOAT key field increment command
+func (store *InMemoryStore) HINCRBYFLOAT(key string, field string, delta float64) (Reply, error) {
+	result, err := store.hashObject.IncrByFloat(key, field, delta)
+	if err != nil {
+		return Reply{}, err
+	}
+	return doubleReply(hashmap.FormatFloat(result)), nil
+}
+
+// One random field or nil. Perform HRANDFIELD key command
+func (store *InMemoryStore) HRANDFIELD(key string) Reply {
+	fields, _ := store.hashObject.RandomFields(key, 1)
+	if len(fields) == 0 {
+		return nilReply
+	}
+	return bulkReply(fields[0])
+}
+
+// Random fields, optionally followed by values. Perform HRANDFIELD key count [WITHVALUES] command
+func (store *InMemoryStore) HRANDFIELD_COUNT(key string, count int64, withValues bool) Reply {
+	fields, values := store.hashObject.RandomFields(key, count)
+	var items []string
+	for i := range fields {
+		items = append(items, fields[i])
+		if withValues {
+			items = append(items, values[i])
+		}
+	}
+	return bulkArrayReply(items)
+}
+
+// Reply of the SCAN commands: the cursor to continue from and a page of items
+func scanReply(nextCursor uint64, items []string) Reply {
+	return arrayReply([]Reply{bulkReply(strconv.FormatUint(nextCursor, 10)), bulkArrayReply(items)})
+}
+
+// Incrementally iterates fields. Perform HSCAN key cursor [MATCH pattern] [COUNT count] [NOVALUES] command
+func (store *InMemoryStore) HSCAN(key string, cursor uint64, match string, count int, noValues bool) Reply {
+	nextCursor, fields, values := store.hashObject.Scan(key, cursor, count)
+	var items []string
+	for i := range fields {
+		// like redis MATCH filters after fetching so a page can come back empty
+		if match != "" && !globMatch(match, fields[i]) {
+			continue
+		}
+		items = append(items, fields[i])
+		if !noValues {
+			items = append(items, values[i])
+		}
+	}
+	return scanReply(nextCursor, items)
+}
+
+// Sets deadline on fields and returns a code per field: -2 missing, 0 condition
+// not met, 1 set, 2 deleted. Perform HEXPIRE/HPEXPIRE/HEXPIREAT/HPEXPIREAT key time [NX|XX|GT|LT] FIELDS numfields field [field ...] command
+func (store *InMemoryStore) HPEXPIREAT(key string, deadline time.Time, condition string, fields []string) []int {
+	return store.hashObject.ExpireFields(key, deadline, condition, fields)
+}
+
+// Remaining TTL of each field in seconds, -1 without TTL and -2 for missing fields. Perform HTTL key FIELDS numfields field [field ...] command
+func (store *InMemoryStore) HTTL(key string, fields []string) Reply {
+	ttls, codes := store.hashObject.FieldTTL(key, fields)
+	return formatFieldTTLs(ttls, codes, time.Second)
+}
+
+// Remaining TTL of each field in milliseconds. Perform HPTTL key FIELDS numfields field [field ...] command
+func (store *InMemoryStore) HPTTL(key string, fields []string) Reply {
+	ttls, codes := store.hashObject.FieldTTL(key, fields)
+	return formatFieldTTLs(ttls, codes, time.Millisecond)
+}
+
+// Removes TTL of fields, returning 1 if removed, -1 without TTL and -2 for missing fields. Perform HPERSIST key FIELDS numfields field [field ...] command
+func (store *InMemoryStore) HPERSIST(key string, fields []string) []int {
+	return store.hashObject.PersistFields(key, fields)
+}
+
+// Rounds TTLs to unit, replying the -1 and -2 codes of fields without one
+func formatFieldTTLs(ttls []time.Duration, codes []int, unit time.Duration) Reply {
+	items := make([]Reply, len(ttls))
+	for i, ttl := range ttls {
+		if codes[i] != hashObjectMap.FIELD_TTL_SET {
+			items[i] = integerReply(int64(codes[i]))
+		} else {
+			items[i] = integerReply(int64((ttl + unit/2) / unit))
+		}
+	}
+	return arrayReply(items)
+}
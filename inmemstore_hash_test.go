@@ -0,0 +1,244 @@
+package main
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_HSET_HGET_Commands(t *testing.T) {
+	db := CreateTestDbSetup()
+	commands := []struct {
+		command  string
+		expected string
+	}{
+		{"HSET user name alice age 30", "2"},
+		{"HSET user name", "COMMAND NOT VALID"},
+		{"HSET user name bob", "0"},
+		{"HGET user name", "bob"},
+		{"HGET user missing", "(nil)"},
+		{"HMGET user name missing age", "1) 'bob'\n2) (nil)\n3) '30'\n"},
+		{"HGETALL user", "1) 'age'\n2) '30'\n3) 'name'\n4) 'bob'\n"},
+		{"HKEYS user", "1) 'age'\n2) 'name'\n"},
+		{"HVALS user", "1) '30'\n2) 'bob'\n"},
+		{"HLEN user", "2"},
+		{"HEXISTS user age", "1"},
+		{"HEXISTS user city", "0"},
+		{"HSTRLEN user name", "3"},
+		{"HSETNX user name carol", "0"},
+		{"HSETNX user city delhi", "1"},
+		{"HINCRBY user age 5", "35"},
+		{"HINCRBY user age x", "COMMAND NOT VALID"},
+		{"HINCRBY user name 1", "ERR hash value is not an integer"},
+		{"HINCRBYFLOAT user age 0.5", "35.5"},
+		{"HDEL user age city missing", "2"},
+		{"HDEL user name", "1"},
+		{"HLEN user", "0"},
+		{"HGETALL user", "(empty list or set)"},
+		{"GET user", "(nil)"},
+	}
+	for _, c := range commands {
+		result := db.ProcessCommand(c.command)
+		if result != c.expected {
+			t.Errorf("Ran:" + c.command + ". Expected: " + c.expected + " but Got result:" + result)
+		}
+	}
+}
+
+func Test_HRANDFIELD_HSCAN_Commands(t *testing.T) {
+	db := CreateTestDbSetup()
+	db.ProcessCommand("HSET h a 1")
+	commands := []struct {
+		command  string
+		expected string
+	}{
+		{"HRANDFIELD h", "a"},
+		{"HRANDFIELD missing", "(nil)"},
+		{"HRANDFIELD h 5 WITHVALUES", "1) 'a'\n2) '1'\n"},
+		{"HRANDFIELD h -2", "1) 'a'\n2) 'a'\n"},
+		{"HRANDFIELD h 1 WITHSCORES", "COMMAND NOT VALID"},
+		{"HSCAN h 0", "1) '0'\n2) 1) 'a'\n   2) '1'\n"},
+		{"HSCAN h 0 NOVALUES", "1) '0'\n2) 1) 'a'\n"},
+		{"HSCAN h 0 MATCH b*", "1) '0'\n2) (empty list or set)\n"},
+		{"HSCAN h 0 COUNT 0", "COMMAND NOT VALID"},
+		{"HSCAN h -1", "COMMAND NOT VALID"},
+	}
+	for _, c := range commands {
+		result := db.ProcessCommand(c.command)
+		if result != c.expected {
+			t.Errorf("Ran:" + c.command + ". Expected: " + c.expected + " but Got result:" + result)
+		}
+	}
+
+	for _, field := range []string{"b", "c", "d", "e", "f"} {
+		db.ProcessCommand("HSET h " + field + " 1")
+	}
+	result := db.ProcessCommand("HSCAN h 0 COUNT 2 NOVALUES")
+	if strings.HasPrefix(result, "1) '0'\n") {
+		t.Errorf("Expected a non zero cursor when more fields remain but got:\n" + result)
+	}
+}
+
+func Test_Hash_EXPIRE_Command(t *testing.T) {
+	db := CreateTestDbSetup()
+	db.ProcessCommand("HSET session user alice")
+	if result := db.ProcessCommand("EXPIRE session 1"); result != "1" {
+		t.Errorf("Expected 1 but got " + result)
+	}
+	if result := db.ProcessCommand("PERSIST session"); result != "1" {
+		t.Errorf("Expected 1 but got " + result)
+	}
+	db.ProcessCommand("EXPIRE session 1")
+	time.Sleep(1100 * time.Millisecond)
+	if result := db.ProcessCommand("HGET session user"); result != "(nil)" {
+		t.Errorf("Expected session to expire but got " + result)
+	}
+}
+
+func TestAOFReplaysHashCommands(t *testing.T) {
+	AOFfilename, remove := tempAOF(t)
+	defer remove()
+	db := CreateInMemStore(1, AOFfilename)
+	db.ProcessCommand(`HSET user name "alice smith" visits 1`)
+	db.ProcessCommand("HINCRBY user visits 2")
+	db.ProcessCommand("HINCRBYFLOAT user score 1.5")
+	db.ProcessCommand("HSETNX user name bob")
+	db.ProcessCommand("HDEL user score")
+	time.Sleep(2 * time.Second) //give extra time to persist to make sure all data is flushed
+
+	replayed := CreateInMemStore(1, AOFfilename)
+	result := replayed.ProcessCommand("HGETALL user")
+	expected := "1) 'name'\n2) 'alice smith'\n3) 'visits'\n4) '3'\n"
+	if result != expected {
+		t.Errorf("Expected:\n" + expected + "Got result:\n" + result)
+	}
+}
+
+func TestAOFLogsHINCRBYFLOATAsHSET(t *testing.T) {
+	AOFfilename, remove := tempAOF(t)
+	defer remove()
+	db := CreateInMemStore(1, AOFfilename)
+	for i := 0; i < 3; i++ {
+		db.ProcessCommand("HINCRBYFLOAT h f 0.1")
+	}
+	time.Sleep(1500 * time.Millisecond) //give extra time to persist
+	content, err := ioutil.ReadFile(AOFfilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "HSET h f 0.1\nHSET h f 0.2\nHSET h f 0.30000000000000004\n"
+	if string(content) != expected {
+		t.Errorf("Expected AOF:\n" + expected + "Got:\n" + string(content))
+	}
+	replayed := CreateInMemStore(1, AOFfilename)
+	if result := replayed.ProcessCommand("HGET h f"); result != "0.30000000000000004" {
+		t.Errorf("Expected 0.30000000000000004 after replay but got " + result)
+	}
+}
+
+func Test_HEXPIRE_HTTL_HPERSIST_Commands(t *testing.T) {
+	db := CreateTestDbSetup()
+	db.ProcessCommand("HSET session token abc user alice")
+	commands := []struct {
+		command  string
+		expected string
+	}{
+		{"HEXPIRE session 100 FIELDS 2 token missing", "1) 1\n2) -2\n"},
+		{"HEXPIRE missing 100 FIELDS 1 token", "1) -2\n"},
+		{"HEXPIRE session 100 FIELDS 2 token", "COMMAND NOT VALID"},
+		{"HEXPIRE session -1 FIELDS 1 token", "COMMAND NOT VALID"},
+		{"HEXPIRE session 100 XY FIELDS 1 token", "COMMAND NOT VALID"},
+		{"HEXPIRE session 200 NX FIELDS 2 token user", "1) 0\n2) 1\n"},
+		{"HEXPIRE session 50 GT FIELDS 1 token", "1) 0\n"},
+		{"HEXPIRE session 50 LT FIELDS 1 token", "1) 1\n"},
+		{"HTTL session FIELDS 3 token user missing", "1) 50\n2) 200\n3) -2\n"},
+		{"HPERSIST session FIELDS 2 user missing", "1) 1\n2) -2\n"},
+		{"HTTL session FIELDS 1 user", "1) -1\n"},
+		{"HPERSIST session FIELDS 1 user", "1) -1\n"},
+		{"HEXPIRE session 10 XX FIELDS 1 user", "1) 0\n"},
+		{"HSET session token xyz", "0"},
+		{"HTTL session FIELDS 1 token", "1) -1\n"},
+		{"HEXPIREAT session 1 FIELDS 1 token", "1) 2\n"},
+		{"HGET session token", "(nil)"},
+		{"HLEN session", "1"},
+		{"HPEXPIRE session 0 FIELDS 1 user", "1) 2\n"},
+		{"HGETALL session", "(empty list or set)"},
+	}
+	for _, c := range commands {
+		result := db.ProcessCommand(c.command)
+		if result != c.expected {
+			t.Errorf("Ran:" + c.command + ". Expected: " + c.expected + " but Got result:" + result)
+		}
+	}
+}
+
+func Test_Hash_Field_Expiry(t *testing.T) {
+	db := CreateTestDbSetup()
+	db.ProcessCommand("HSET session token abc user alice")
+	db.ProcessCommand("HPEXPIRE session 50 FIELDS 1 token")
+	if result := db.ProcessCommand("HGET session token"); result != "abc" {
+		t.Errorf("Expected field to exist until its deadline but got " + result)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if result := db.ProcessCommand("HGETALL session"); result != "1) 'user'\n2) 'alice'\n" {
+		t.Errorf("Expected only the user field to remain but got:\n" + result)
+	}
+}
+
+func TestAOFReplaysHashFieldDeadlines(t *testing.T) {
+	AOFfilename, remove := tempAOF(t)
+	defer remove()
+	db := CreateInMemStore(1, AOFfilename)
+	db.ProcessCommand("HSET session token abc user alice tmp 1")
+	db.ProcessCommand("HEXPIRE session 100 FIELDS 1 token")
+	db.ProcessCommand("HEXPIRE session 100 FIELDS 1 user")
+	db.ProcessCommand("HPERSIST session FIELDS 1 user")
+	db.ProcessCommand("HPEXPIRE session 1000 FIELDS 1 tmp")
+	time.Sleep(2 * time.Second) //give extra time to persist to make sure all data is flushed
+
+	replayed := CreateInMemStore(1, AOFfilename)
+	result := replayed.ProcessCommand("HTTL session FIELDS 3 token user tmp")
+	// deadline is absolute so about 2 seconds of it have already passed
+	expected := "1) 98\n2) -1\n3) -2\n"
+	if result != expected {
+		t.Errorf("Expected:\n" + expected + "Got result:\n" + result)
+	}
+}
+
+// Field deadlines which passed since they were logged don't remove the
+// fields while the AOF loads, so the writes logged after them see the
+// fields they saw then. The fields are removed once loading finishes.
+func TestAOFReplayKeepsExpiredFieldsUntilLoaded(t *testing.T) {
+	AOFfilename, remove := tempAOF(t)
+	defer remove()
+	past := strconv.FormatInt(unixMilli(time.Now())-1000, 10)
+	future := strconv.FormatInt(unixMilli(time.Now())+100000, 10)
+	lines := []string{
+		"HSET h a 10 b 1 c 5",
+		"HPEXPIREAT h " + past + " FIELDS 2 a c",
+		"HINCRBY h a 1",
+		"HSETNX h c 9",
+		"HPEXPIREAT h " + future + " FIELDS 1 b",
+		"HINCRBY h b 1",
+		"HSET g x 1",
+		"HPEXPIREAT g " + past + " FIELDS 1 x",
+		"HINCRBY g x 1",
+	}
+	if err := ioutil.WriteFile(AOFfilename, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	replayed := CreateInMemStore(1, AOFfilename)
+	expected := map[string]string{
+		"HGETALL h":             "1) 'b'\n2) '2'\n",
+		"HTTL h FIELDS 3 a b c": "1) -2\n2) 100\n3) -2\n",
+		"HLEN g":                "0",
+		"TYPE g":                "none",
+	}
+	for command, value := range expected {
+		if result := replayed.ProcessCommand(command); result != value {
+			t.Errorf("After replay ran:" + command + ". Expected:\n" + value + "but Got result:\n" + result)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+// Runs HyperLogLog commands on string values. handled is false if commType isn't one of them.
+func (store *InMemoryStore) processHyperLogLogCommand(commType string, key string, args [][2]string, command string) (result Reply, handled bool) {
+	switch commType {
+	case "PFADD":
+		result := store.PFADD(key, firstOfPairs(args))
+		if result.Integer == 1 {
+			store.appendToAOF(command)
+			store.notifyKeyspaceEvent(NOTIFY_STRING, "pfadd", key)
+		}
+		return result, true
+	case "PFCOUNT":
+		result, cacheUpdated := store.PFCOUNT(append([]string{key}, firstOfPairs(args)...))
+		// the estimate cached in the header is part of the value, replay it too
+		if cacheUpdated {
+			store.appendToAOF(command)
+		}
+		return result, true
+	case "PFMERGE":
+		result := store.PFMERGE(key, firstOfPairs(args))
+		if !result.isError() {
+			store.appendToAOF(command)
+			// like in redis, merging into a HyperLogLog counts as adding to it
+			store.notifyKeyspaceEvent(NOTIFY_STRING, "pfadd", key)
+		}
+		return result, true
+	}
+	return Reply{}, false
+}
+
+// Adds elements to a HyperLogLog and returns 1 if its estimate may have changed.
+// Perform PFADD key [element ...] command
+func (store *InMemoryStore) PFADD(key string, elements []string) Reply {
+	updated, err := store.hashmap.PFAdd(key, elements)
+	if err != nil {
+		return errorReply(err.Error())
+	}
+	return booleanReply(updated)
+}
+
+// Estimated number of unique elements in the union of keys. Perform PFCOUNT key [key ...] command
+func (store *InMemoryStore) PFCOUNT(keys []string) (result Reply, cacheUpdated bool) {
+	count, cacheUpdated, err := store.hashmap.PFCount(keys)
+	if err != nil {
+		return errorReply(err.Error()), false
+	}
+	return integerReply(count), cacheUpdated
+}
+
+// Stores the union of HyperLogLogs in destkey. Perform PFMERGE destkey [sourcekey ...] command
+func (store *InMemoryStore) PFMERGE(dest string, keys []string) Reply {
+	if err := store.hashmap.PFMerge(dest, keys); err != nil {
+		return errorReply(err.Error())
+	}
+	return okReply
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_HyperLogLog_Commands(t *testing.T) {
+	db := CreateTestDbSetup()
+	commands := []struct {
+		command  string
+		expected string
+	}{
+		{"PFADD visitors a b c d e f g", "1"},
+		{"PFADD visitors a", "0"},
+		{"PFCOUNT visitors", "7"},
+		{"PFADD empty", "1"},
+		{"PFADD empty", "0"},
+		{"PFCOUNT empty missing", "0"},
+		{"PFADD other g h", "1"},
+		{"PFCOUNT visitors other", "8"},
+		{"PFMERGE all visitors other", "OK"},
+		{"PFCOUNT all", "8"},
+		{"PFMERGE fresh", "OK"},
+		{"PFCOUNT fresh", "0"},
+		{"SET text hello", "OK"},
+		{"PFADD text x", "WRONGTYPE Key is not a valid HyperLogLog string value."},
+		{"PFCOUNT visitors text", "WRONGTYPE Key is not a valid HyperLogLog string value."},
+		{"PFMERGE all text", "WRONGTYPE Key is not a valid HyperLogLog string value."},
+		{"PFCOUNT", "COMMAND NOT VALID"},
+		{`SET copied "HYLL\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x7f\xff"`, "OK"},
+		{"PFADD copied a", "1"},
+		{"PFCOUNT copied", "1"},
+	}
+	for _, c := range commands {
+		result := db.ProcessCommand(c.command)
+		if result != c.expected {
+			t.Errorf("Ran:" + c.command + ". Expected: " + c.expected + " but Got result:" + result)
+		}
+	}
+}
+
+func TestAOFReplaysHyperLogLogCommands(t *testing.T) {
+	AOFfilename, remove := tempAOF(t)
+	defer remove()
+	db := CreateInMemStore(1, AOFfilename)
+	db.ProcessCommand("PFADD a 1 2 3")
+	db.ProcessCommand(`PFADD b "with space" 4`)
+	db.ProcessCommand("PFCOUNT a")
+	db.ProcessCommand("PFMERGE union a b")
+	time.Sleep(2 * time.Second) //give extra time to persist to make sure all data is flushed
+
+	replayed := CreateInMemStore(1, AOFfilename)
+	for _, check := range []string{"GET a", "GET b", "GET union", "PFCOUNT a b"} {
+		if result, expected := replayed.ProcessCommand(check), db.ProcessCommand(check); result != expected {
+			t.Errorf("Ran:" + check + ". Expected:\n" + expected + "Got result:\n" + result)
+		}
+	}
+}
+
+// PFCOUNT logs the estimate it caches, so it waits for the writes locking
+// its keys like they do
+func TestPFCOUNTWaitsForLockedKeys(t *testing.T) {
+	db := CreateTestDbSetup()
+	db.ProcessCommand("PFADD a 1 2 3")
+	unlock := db.lockKeys("PFMERGE", "PFMERGE a b")
+	done := make(chan string)
+	go func() { done <- db.ProcessCommand("PFCOUNT a") }()
+	select {
+	case result := <-done:
+		t.Errorf("Expected PFCOUNT to wait for the lock but got " + result)
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	if result := <-done; result != "3" {
+		t.Errorf("Expected: 3 Got result:" + result)
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Version of redis whose commands and replies are followed, reported by INFO
+// for clients which check it
+const REDIS_VERSION = "7.0.0"
+
+// Counters shown by INFO, zeroed by CONFIG RESETSTAT except for those
+// counting what is there now like connectedClients
+type serverStats struct {
+	// first for the alignment atomic needs
+	commandsProcessed   uint64
+	connectionsReceived uint64
+	errorReplies        uint64
+	// keys the data stores had expired when the stats were reset
+	expiredBefore    uint64
+	connectedClients int64
+	blockedClients   int64
+	started          time.Time
+	runID            string
+	// *commandStats by command name
+	commands sync.Map
+}
+
+// Calls of a command, with the microseconds they took in total. Rejected
+// calls didn't run, like writes refused by maxmemory, failed ones ran but
+// replied an error.
+type commandStats struct {
+	calls         uint64
+	usec          uint64
+	rejectedCalls uint64
+	failedCalls   uint64
+}
+
+func createServerStats() *serverStats {
+	id := make([]byte, 20)
+	rand.Read(id)
+	return &serverStats{started: time.Now(), runID: hex.EncodeToString(id)}
+}
+
+// Runs a command, counting it and the time it took in the stats. Commands
+// which couldn't be parsed only count as error replies.
+func (store *InMemoryStore) countCommand(commType string, run func() Reply) Reply {
+	start := time.Now()
+	result := run()
+	stats := store.stats
+	failed := result.isError()
+	if failed {
+		atomic.AddUint64(&stats.errorReplies, 1)
+	}
+	if commType == "" {
+		return result
+	}
+	atomic.AddUint64(&stats.commandsProcessed, 1)
+	value, exists := stats.commands.Load(commType)
+	if !exists {
+		value, _ = stats.commands.LoadOrStore(commType, &commandStats{})
+	}
+	command := value.(*commandStats)
+	atomic.AddUint64(&command.calls, 1)
+	atomic.AddUint64(&command.usec, uint64(time.Since(start)/time.Microsecond))
+	switch {
+	case failed && strings.HasPrefix(result.Text, "OOM "):
+		atomic.AddUint64(&command.rejectedCalls, 1)
+	case failed:
+		atomic.AddUint64(&command.failedCalls, 1)
+	}
+	return result
+}
+
+// Keys removed once their TTL passed, since the stats were reset
+func (store *InMemoryStore) expiredKeys() uint64 {
+	return store.keyspace.ExpiredCount() - atomic.LoadUint64(&store.stats.expiredBefore)
+}
+
+// Zeroes the counters of the stats
+func (store *InMemoryStore) resetStats() {
+	stats := store.stats
+	atomic.StoreUint64(&stats.commandsProcessed, 0)
+	atomic.StoreUint64(&stats.connectionsReceived, 0)
+	atomic.StoreUint64(&stats.errorReplies, 0)
+	atomic.AddUint64(&stats.expiredBefore, store.expiredKeys())
+	stats.commands.Range(func(name interface{}, _ interface{}) bool {
+		stats.commands.Delete(name)
+		return true
+	})
+}
+
+// A section of INFO, listing name:value fields
+type infoSection struct {
+	name  string
+	title string
+	// whether INFO shows it when no section is asked for
+	byDefault bool
+	fields    func(store *InMemoryStore) [][2]string
+}
+
+// In the order INFO shows them
+var infoSections = []infoSection{
+	{"server", "Server", true, (*InMemoryStore).infoServer},
+	{"clients", "Clients", true, (*InMemoryStore).infoClients},
+	{"memory", "Memory", true, (*InMemoryStore).infoMemory},
+	{"persistence", "Persistence", true, (*InMemoryStore).infoPersistence},
+	{"stats", "Stats", true, (*InMemoryStore).infoStats},
+	{"replication", "Replication", true, (*InMemoryStore).infoReplication},
+	{"cpu", "CPU", true, (*InMemoryStore).infoCPU},
+	{"commandstats", "Commandstats", false, (*InMemoryStore).infoCommandStats},
+	{"keyspace", "Keyspace", true, (*InMemoryStore).infoKeyspace},
+}
+
+// Runs INFO, handled is false for other commands
+func (store *InMemoryStore) processInfoCommand(commType string, key string, args [][2]string, command string) (result Reply, handled bool) {
+	if commType != "INFO" {
+		return Reply{}, false
+	}
+	return store.INFO(firstOfPairs(args)...), true
+}
+
+// Information and statistics about the server, in sections of name:value
+// lines like redis. Without sections the default ones are shown, "all" or
+// "everything" shows every section and "default" the default ones. Unknown
+// sections are ignored. Perform INFO [section [section ...]] command
+func (store *InMemoryStore) INFO(sections ...string) Reply {
+	wanted := map[string]bool{}
+	for _, section := range sections {
+		wanted[strings.ToLower(section)] = true
+	}
+	all := wanted["all"] || wanted["everything"]
+	defaults := len(sections) == 0 || wanted["default"]
+	var builder strings.Builder
+	for _, section := range infoSections {
+		if !all && !wanted[section.name] && !(defaults && section.byDefault) {
+			continue
+		}
+		if builder.Len() > 0 {
+			builder.WriteString("\r\n")
+		}
+		builder.WriteString("# " + section.title + "\r\n")
+		for _, field := range section.fields(store) {
+			builder.WriteString(field[0] + ":" + field[1] + "\r\n")
+		}
+	}
+	return bulkReply(builder.String())
+}
+
+// Settings the server runs with
+func (store *InMemoryStore) currentConfig() Config {
+	store.configLock.Lock()
+	defer store.configLock.Unlock()
+	return store.config
+}
+
+func (store *InMemoryStore) infoServer() [][2]string {
+	config := store.currentConfig()
+	executable, _ := os.Executable()
+	uptime := time.Since(store.stats.started)
+	return [][2]string{
+		{"redis_version", REDIS_VERSION},
+		{"redis_mode", "standalone"},
+		{"os", runtime.GOOS + " " + runtime.GOARCH},
+		{"arch_bits", strconv.Itoa(strconv.IntSize)},
+		{"go_version", runtime.Version()},
+		{"process_id", strconv.Itoa(os.Getpid())},
+		{"run_id", store.stats.runID},
+		{"tcp_port", strconv.Itoa(config.Port)},
+		{"http_port", strconv.Itoa(config.HTTPPort)},
+		{"server_time_usec", strconv.FormatInt(time.Now().UnixNano()/int64(time.Microsecond), 10)},
+		{"uptime_in_seconds", strconv.FormatInt(int64(uptime/time.Second), 10)},
+		{"uptime_in_days", strconv.FormatInt(int64(uptime/(24*time.Hour)), 10)},
+		{"executable", executable},
+		{"config_file", config.filename},
+	}
+}
+
+// Clients are RESP connections, HTTP requests don't stay connected
+func (store *InMemoryStore) infoClients() [][2]string {
+	return [][2]string{
+		{"connected_clients", strconv.FormatInt(atomic.LoadInt64(&store.stats.connectedClients), 10)},
+		{"blocked_clients", strconv.FormatInt(atomic.LoadInt64(&store.stats.blockedClients), 10)},
+	}
+}
+
+func (store *InMemoryStore) infoMemory() [][2]string {
+	usage := sampleMemory()
+	config := store.currentConfig()
+	return [][2]string{
+		{"used_memory", strconv.FormatUint(usage.used, 10)},
+		{"used_memory_human", bytesToHuman(usage.used)},
+		{"used_memory_rss", strconv.FormatUint(usage.system, 10)},
+		{"used_memory_rss_human", bytesToHuman(usage.system)},
+		{"used_memory_peak", strconv.FormatUint(usage.peak, 10)},
+		{"used_memory_peak_human", bytesToHuman(usage.peak)},
+		{"maxmemory", strconv.FormatInt(config.MaxMemory, 10)},
+		{"maxmemory_human", bytesToHuman(uint64(config.MaxMemory))},
+		{"maxmemory_policy", config.MaxMemoryPolicy},
+		{"mem_allocator", "go-" + runtime.Version()},
+	}
+}
+
+// There are no snapshots, data is only persisted by the AOF
+func (store *InMemoryStore) infoPersistence() [][2]string {
+	persistor := store.dataPersistor
+	if persistor == nil {
+		return [][2]string{{"loading", "0"}, {"aof_enabled", "0"}}
+	}
+	status := "ok"
+	if atomic.LoadInt32(&persistor.writeFailed) == 1 {
+		status = "err"
+	}
+	return [][2]string{
+		{"loading", "0"},
+		{"aof_enabled", "1"},
+		{"aof_rewrite_in_progress", "0"},
+		{"aof_last_write_status", status},
+		{"aof_current_size", strconv.FormatInt(atomic.LoadInt64(&persistor.size), 10)},
+		{"aof_base_size", strconv.FormatInt(persistor.baseSize, 10)},
+		{"aof_buffer_length", strconv.Itoa(len(persistor.queue))},
+	}
+}
+
+func (store *InMemoryStore) infoStats() [][2]string {
+	stats := store.stats
+	return [][2]string{
+		{"total_connections_received", strconv.FormatUint(atomic.LoadUint64(&stats.connectionsReceived), 10)},
+		{"total_commands_processed", strconv.FormatUint(atomic.LoadUint64(&stats.commandsProcessed), 10)},
+		{"expired_keys", strconv.FormatUint(store.expiredKeys(), 10)},
+		{"evicted_keys", "0"},
+		{"pubsub_channels", strconv.Itoa(len(store.pubsub.Channels("*")))},
+		{"pubsub_patterns", strconv.Itoa(store.pubsub.NumPat())},
+		{"pubsub_shardchannels", strconv.Itoa(len(store.pubsub.ShardChannels("*")))},
+		{"total_error_replies", strconv.FormatUint(atomic.LoadUint64(&stats.errorReplies), 10)},
+	}
+}
+
+// There are no replicas, the server is always a master
+func (store *InMemoryStore) infoReplication() [][2]string {
+	return [][2]string{
+		{"role", "master"},
+		{"connected_slaves", "0"},
+		{"master_repl_offset", "0"},
+	}
+}
+
+func (store *InMemoryStore) infoCPU() [][2]string {
+	system, user := cpuTime()
+	return [][2]string{
+		{"used_cpu_sys", fmt.Sprintf("%.6f", system.Seconds())},
+		{"used_cpu_user", fmt.Sprintf("%.6f", user.Seconds())},
+	}
+}
+
+// A line per command which ran since the stats were reset, by name
+func (store *InMemoryStore) infoCommandStats() [][2]string {
+	var fields [][2]string
+	store.stats.commands.Range(func(name interface{}, value interface{}) bool {
+		command := value.(*commandStats)
+		calls, usec := atomic.LoadUint64(&command.calls), atomic.LoadUint64(&command.usec)
+		fields = append(fields, [2]string{
+			"cmdstat_" + strings.ToLower(name.(string)),
+			fmt.Sprintf("calls=%d,usec=%d,usec_per_call=%.2f,rejected_calls=%d,failed_calls=%d",
+				calls, usec, float64(usec)/float64(calls),
+				atomic.LoadUint64(&command.rejectedCalls), atomic.LoadUint64(&command.failedCalls)),
+		})
+		return true
+	})
+	sort.Slice(fields, func(i, j int) bool { return fields[i][0] < fields[j][0] })
+	return fields
+}
+
+// The only database, db0, if it has keys. avg_ttl is in milliseconds.
+func (store *InMemoryStore) infoKeyspace() [][2]string {
+	keys, volatile, ttl := store.keyspace.KeyCounts(nil)
+	if keys == 0 {
+		return nil
+	}
+	averageTTL := int64(0)
+	if volatile > 0 {
+		averageTTL = int64(ttl/time.Millisecond) / int64(volatile)
+	}
+	return [][2]string{{"db0", fmt.Sprintf("keys=%d,expires=%d,avg_ttl=%d", keys, volatile, averageTTL)}}
+}
+
+// Bytes in the units redis uses in INFO, like 1.50M
+func bytesToHuman(bytes uint64) string {
+	if bytes < 1024 {
+		return strconv.FormatUint(bytes, 10) + "B"
+	}
+	value := float64(bytes) / 1024
+	for _, unit := range []string{"K", "M", "G", "T"} {
+		if value < 1024 {
+			return fmt.Sprintf("%.2f%s", value, unit)
+		}
+		value /= 1024
+	}
+	return fmt.Sprintf("%.2fP", value)
+}
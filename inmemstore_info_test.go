@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Value of a field in the reply of INFO, "" if it isn't there
+func infoField(info string, name string) string {
+	for _, line := range strings.Split(info, "\r\n") {
+		if strings.HasPrefix(line, name+":") {
+			return line[len(name)+1:]
+		}
+	}
+	return ""
+}
+
+func Test_Info_Sections(t *testing.T) {
+	db := CreateTestDbSetup()
+	for _, command := range []string{"SET a 1", "SET b 2", "EXPIRE b 100", "GET a", "NOPE", "HSET h f v", "RPUSH l x", "HINCRBY h f 1"} {
+		db.ProcessCommand(command)
+	}
+	info := db.ProcessCommand("INFO")
+	for _, expected := range []string{"# Server\r\nredis_version:" + REDIS_VERSION + "\r\n", "\r\n\r\n# Clients\r\n", "# Memory\r\n",
+		"# Persistence\r\nloading:0\r\naof_enabled:0\r\n", "# Stats\r\n", "# Replication\r\nrole:master\r\n", "# CPU\r\n"} {
+		if !strings.Contains(info, expected) {
+			t.Errorf("Expected %q in INFO:\n%s", expected, info)
+		}
+	}
+	if !strings.HasSuffix(info, "\r\n\r\n# Keyspace\r\ndb0:keys=4,expires=1,avg_ttl="+infoField(info, "db0")[len("keys=4,expires=1,avg_ttl="):]+"\r\n") {
+		t.Errorf("Expected the keyspace at the end of INFO:\n%s", info)
+	}
+	if ttl, err := strconv.Atoi(strings.TrimPrefix(infoField(info, "db0"), "keys=4,expires=1,avg_ttl=")); err != nil || ttl <= 99000 || ttl > 100000 {
+		t.Errorf("Expected an average TTL of about 100s but got %q", infoField(info, "db0"))
+	}
+	if strings.Contains(info, "# Commandstats") {
+		t.Errorf("Commandstats shouldn't be a default section")
+	}
+	if used, err := strconv.Atoi(infoField(info, "used_memory")); err != nil || used <= 0 || infoField(info, "used_memory_human") == "" {
+		t.Errorf("Unexpected used memory %q %q", infoField(info, "used_memory"), infoField(info, "used_memory_human"))
+	}
+
+	stats := db.ProcessCommand("INFO stats")
+	if !strings.HasPrefix(stats, "# Stats\r\n") || strings.Contains(stats, "# Server") {
+		t.Errorf("Expected only the stats section but got:\n%s", stats)
+	}
+	// the first INFO counts, the commands which couldn't be parsed don't
+	if processed := infoField(stats, "total_commands_processed"); processed != "8" {
+		t.Errorf("Expected 8 commands processed but got %q", processed)
+	}
+	if errors := infoField(stats, "total_error_replies"); errors != "2" {
+		t.Errorf("Expected 2 error replies but got %q", errors)
+	}
+
+	commandStats := db.ProcessCommand("INFO COMMANDSTATS")
+	for _, expected := range []string{"cmdstat_get:calls=1,usec=", "cmdstat_set:calls=2,usec=", ",rejected_calls=0,failed_calls=1\r\n"} {
+		if !strings.Contains(commandStats, expected) {
+			t.Errorf("Expected %q in the command stats:\n%s", expected, commandStats)
+		}
+	}
+	if strings.Index(commandStats, "cmdstat_expire:") > strings.Index(commandStats, "cmdstat_get:") {
+		t.Errorf("Expected the command stats sorted by name:\n%s", commandStats)
+	}
+
+	if both := db.ProcessCommand("INFO clients server"); !strings.HasPrefix(both, "# Server\r\n") || !strings.Contains(both, "\r\n\r\n# Clients\r\nconnected_clients:0\r\nblocked_clients:0\r\n") {
+		t.Errorf("Expected the server then the clients sections but got:\n%s", both)
+	}
+	if everything := db.ProcessCommand("INFO everything"); strings.Count(everything, "# ") != len(infoSections) {
+		t.Errorf("Expected every section but got:\n%s", everything)
+	}
+	if nothing := db.ProcessCommand("INFO nope"); nothing != "" {
+		t.Errorf("Unknown sections should be empty but got %q", nothing)
+	}
+
+	if result := db.ProcessCommand("CONFIG RESETSTAT"); result != "OK" {
+		t.Errorf("Expected OK but got " + result)
+	}
+	stats = db.ProcessCommand("INFO stats")
+	if infoField(stats, "total_commands_processed") != "1" || infoField(stats, "total_error_replies") != "0" {
+		t.Errorf("Expected the stats to be reset but got:\n%s", stats)
+	}
+	if commandStats := db.ProcessCommand("INFO commandstats"); commandStats != "# Commandstats\r\ncmdstat_config:calls=1,usec="+
+		strings.TrimPrefix(infoField(commandStats, "cmdstat_config"), "calls=1,usec=")+"\r\ncmdstat_info:"+infoField(commandStats, "cmdstat_info")+"\r\n" {
+		t.Errorf("Expected only CONFIG and INFO in the command stats:\n%s", commandStats)
+	}
+}
+
+func Test_Info_Expired_Keys(t *testing.T) {
+	db := CreateTestDbSetup()
+	deadline := strconv.FormatInt(unixMilli(time.Now().Add(50*time.Millisecond)), 10)
+	db.ProcessCommand("SET k v")
+	db.ProcessCommand("PEXPIREAT k " + deadline)
+	db.ProcessCommand("SADD s a")
+	db.ProcessCommand("PEXPIREAT s " + deadline)
+	time.Sleep(300 * time.Millisecond)
+	if expired := infoField(db.ProcessCommand("INFO stats"), "expired_keys"); expired != "2" {
+		t.Errorf("Expected 2 expired keys but got %q", expired)
+	}
+	if keyspace := db.ProcessCommand("INFO keyspace"); keyspace != "# Keyspace\r\n" {
+		t.Errorf("Expected an empty keyspace but got %q", keyspace)
+	}
+	db.ProcessCommand("CONFIG RESETSTAT")
+	if expired := infoField(db.ProcessCommand("INFO stats"), "expired_keys"); expired != "0" {
+		t.Errorf("Expected expired keys to be reset but got %q", expired)
+	}
+}
+
+func Test_Info_Persistence(t *testing.T) {
+	AOFfilename, remove := tempAOF(t)
+	defer remove()
+	db := CreateInMemStore(1, AOFfilename)
+	db.ProcessCommand("SET a 1")
+	db.ProcessCommand("RPUSH l x y")
+	time.Sleep(200 * time.Millisecond)
+	persistence := db.ProcessCommand("INFO persistence")
+	expected := "# Persistence\r\nloading:0\r\naof_enabled:1\r\naof_rewrite_in_progress:0\r\naof_last_write_status:ok\r\n" +
+		"aof_current_size:20\r\naof_base_size:0\r\naof_buffer_length:0\r\n"
+	if persistence != expected {
+		t.Errorf("Expected %q but got %q", expected, persistence)
+	}
+
+	replayed := CreateInMemStore(1, AOFfilename)
+	info := replayed.ProcessCommand("INFO persistence stats")
+	if infoField(info, "aof_base_size") != "20" || infoField(info, "aof_current_size") != "20" {
+		t.Errorf("Expected the replayed AOF as the base size:\n%s", info)
+	}
+	if processed := infoField(info, "total_commands_processed"); processed != "0" {
+		t.Errorf("Replayed commands shouldn't be counted but got %q", processed)
+	}
+}
+
+func Test_Info_Clients(t *testing.T) {
+	db := CreateTestDbSetup()
+	listener := serveTestRESP(t, db)
+	defer listener.Close()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := bufio.NewReader(conn)
+	conn.Write([]byte("BLPOP queue 5\r\n"))
+
+	waitFor := func(field string, value string) {
+		t.Helper()
+		for i := 0; i < 100; i++ {
+			if infoField(db.ProcessCommand("INFO clients stats"), field) == value {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Errorf("Expected %s:%s in INFO:\n%s", field, value, db.ProcessCommand("INFO clients stats"))
+	}
+	waitFor("blocked_clients", "1")
+	waitFor("connected_clients", "1")
+	waitFor("total_connections_received", "1")
+	db.ProcessCommand("RPUSH queue job")
+	if line, err := reader.ReadString('\n'); err != nil || line != "*2\r\n" {
+		t.Errorf("Expected BLPOP to reply but got %q %v", line, err)
+	}
+	waitFor("blocked_clients", "0")
+	conn.Close()
+	waitFor("connected_clients", "0")
+}
+
+func TestBytesToHuman(t *testing.T) {
+	sizes := map[uint64]string{0: "0B", 1023: "1023B", 1024: "1.00K", 1536: "1.50K", 3 << 20: "3.00M", 5 << 30: "5.00G"}
+	for bytes, expected := range sizes {
+		if human := bytesToHuman(bytes); human != expected {
+			t.Errorf("Expected %v for %d but got %v", expected, bytes, human)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"github.com/thedeveloperr/redis-clone/hashObjectMap"
+	"github.com/thedeveloperr/redis-clone/hashmap"
+	"github.com/thedeveloperr/redis-clone/listMap"
+	"github.com/thedeveloperr/redis-clone/setMap"
+	"github.com/thedeveloperr/redis-clone/sortedSetMap"
+	"github.com/thedeveloperr/redis-clone/streamMap"
+)
+
+// Data type of values, named like the replies of TYPE. Every type is stored in
+// one keyspace so a name holds a single value.
+type dataType struct {
+	name string
+	// whether a value read from the keyspace is of this type
+	is func(value interface{}) bool
+}
+
+// Bitmaps and HyperLogLogs are strings and geo indexes are sorted sets, as in
+// redis.
+var dataTypes = []dataType{
+	{"string", hashmap.IsString},
+	{"list", listMap.IsList},
+	{"set", setMap.IsSet},
+	{"zset", sortedSetMap.IsSortedset},
+	{"hash", hashObjectMap.IsHash},
+	{"stream", streamMap.IsStream},
+}
+
+// Whether a key of a command holds another data type than the command works
+// on. Write commands hold the locks of their keys, so the type can't change
+// before they run.
+func (store *InMemoryStore) holdsWrongType(commType string, command string) bool {
+	typeName, typed := commandDataTypes[commType]
+	if !typed {
+		return false
+	}
+	args, ok := splitArgs(command)
+	if !ok {
+		return false
+	}
+	for _, key := range typeCheckedKeys(args) {
+		if value, exists := store.keyspace.Get(key); exists && typeOf(value) != typeName {
+			return true
+		}
+	}
+	return false
+}
+
+// Runs SCAN, TYPE, DEL and DBSIZE, handled is false for other commands
+func (store *InMemoryStore) processKeyspaceCommand(commType string, key string, args [][2]string, command string) (result Reply, handled bool) {
+	switch commType {
+	case "SCAN":
+		cursor, match, count, _ := scanOptions(args)
+		typeName := ""
+		if last := args[len(args)-1]; last[0] == "TYPE" {
+			typeName = last[1]
+		}
+		return store.SCAN(cursor, match, count, typeName), true
+	case "TYPE":
+		return store.TYPE(key), true
+	case "DEL":
+		deleted := store.DEL(firstOfPairs(args))
+		// only the keys which existed are logged, each of them published as del
+		if len(deleted) > 0 {
+			store.appendToAOF(formatCommand(append([]string{"DEL"}, deleted...)...))
+		}
+		for _, key := range deleted {
+			store.notifyKeyspaceEvent(NOTIFY_GENERIC, "del", key)
+		}
+		return integerReply(int64(len(deleted))), true
+	case "DBSIZE":
+		return store.DBSIZE(), true
+	}
+	return Reply{}, false
+}
+
+// Iterates keys with the cursor of the keyspace, 0 meaning both start and
+// end. Keys present for the whole iteration are returned, others may or may
+// not be. Each call only goes through about count keys, which MATCH and TYPE
+// then filter. Perform SCAN cursor [MATCH pattern] [COUNT count] [TYPE type] command
+func (store *InMemoryStore) SCAN(cursor uint64, match string, count int, typeName string) Reply {
+	var items []string
+	nextCursor := store.keyspace.Scan(cursor, count, func(key string, value interface{}) {
+		if (typeName == "" || typeOf(value) == typeName) && (match == "" || globMatch(match, key)) {
+			items = append(items, key)
+		}
+	})
+	return scanReply(nextCursor, items)
+}
+
+// Name of the data type of a value read from the keyspace
+func typeOf(value interface{}) string {
+	for _, kind := range dataTypes {
+		if kind.is(value) {
+			return kind.name
+		}
+	}
+	return "none"
+}
+
+// Type of the value of key, none if there is no such key. Perform TYPE key command
+func (store *InMemoryStore) TYPE(key string) Reply {
+	if value, exists := store.keyspace.Get(key); exists {
+		return statusReply(typeOf(value))
+	}
+	return statusReply("none")
+}
+
+// Removes keys whatever their type and returns the ones which existed. Perform DEL key [key ...] command
+func (store *InMemoryStore) DEL(keys []string) (deleted []string) {
+	for _, key := range keys {
+		if store.keyspace.Delete(key) {
+			deleted = append(deleted, key)
+		}
+	}
+	return deleted
+}
+
+// Number of keys of every type. Perform DBSIZE command
+func (store *InMemoryStore) DBSIZE() Reply {
+	keys, _, _ := store.keyspace.KeyCounts(nil)
+	return integerReply(int64(keys))
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func Test_Keyspace_Commands(t *testing.T) {
+	db := CreateTestDbSetup()
+	commands := []struct {
+		command  string
+		expected string
+	}{
+		{"PING", "PONG"},
+		{"PING hello", "hello"},
+		{"DBSIZE", "0"},
+		{"SCAN 0", "1) '0'\n2) (empty list or set)\n"},
+		{"SET name alice", "OK"},
+		{"RPUSH queue a b", "2"},
+		{"SADD tags go", "1"},
+		{"ZADD board 1 alice 2 bob", "2"},
+		{"HSET user name alice", "1"},
+		{"XADD events 1-1 kind login", "1-1"},
+		{"PFADD visitors alice", "1"},
+		{"TYPE name", "string"},
+		{"TYPE queue", "list"},
+		{"TYPE tags", "set"},
+		{"TYPE board", "zset"},
+		{"TYPE user", "hash"},
+		{"TYPE events", "stream"},
+		{"TYPE visitors", "string"},
+		{"TYPE missing", "none"},
+		{"TYPE", "COMMAND NOT VALID"},
+		{"ZCARD board", "2"},
+		{"ZCARD missing", "0"},
+		{"DBSIZE", "7"},
+		{"DBSIZE now", "COMMAND NOT VALID"},
+		{"SCAN 0 TYPE zset", "1) '0'\n2) 1) 'board'\n"},
+		{"SCAN 0 MATCH que* COUNT 100", "1) '0'\n2) 1) 'queue'\n"},
+		{"SCAN 0 TYPE list MATCH q*", "1) '0'\n2) 1) 'queue'\n"},
+		{"SCAN 0 TYPE nothing", "1) '0'\n2) (empty list or set)\n"},
+		{"SCAN 0 COUNT 0", "COMMAND NOT VALID"},
+		{"SCAN 0 TYPE", "COMMAND NOT VALID"},
+		{"SCAN cursor", "COMMAND NOT VALID"},
+		{"DEL queue tags missing queue", "2"},
+		{"TYPE queue", "none"},
+		{"SADD tags rust", "1"},
+		{"DEL board user events", "3"},
+		{"DEL board", "0"},
+		{"DBSIZE", "3"},
+		{"DEL", "COMMAND NOT VALID"},
+	}
+	for _, c := range commands {
+		result := db.ProcessCommand(c.command)
+		if result != c.expected {
+			t.Errorf("Ran:" + c.command + ". Expected: " + c.expected + " but Got result:" + result)
+		}
+	}
+}
+
+func TestWrongType(t *testing.T) {
+	db := CreateTestDbSetup()
+	wrongType := "WRONGTYPE Operation against a key holding the wrong kind of value"
+	commands := []struct {
+		command  string
+		expected string
+	}{
+		{"RPUSH queue a", "1"},
+		{"SADD tags go", "1"},
+		{"SET name alice", "OK"},
+		{"GET queue", wrongType},
+		{"INCR queue", wrongType},
+		{"SADD queue x", wrongType},
+		{"HGET queue f", wrongType},
+		{"ZADD queue 1 x", wrongType},
+		{"XLEN queue", wrongType},
+		{"LPUSH name x", wrongType},
+		{"LMOVE queue tags LEFT LEFT", wrongType},
+		{"SINTER tags queue", wrongType},
+		{"MGET name queue", "1) 'alice'\n2) (nil)\n"},
+		{"MSETNX queue x other y", "0"},
+		{"LLEN queue", "1"},
+		{"SUNIONSTORE queue tags", "1"},
+		{"TYPE queue", "set"},
+		{"SET tags text", "OK"},
+		{"TYPE tags", "string"},
+		{"DBSIZE", "3"},
+		{"EXPIRE name 100", "1"},
+		{"PERSIST name", "1"},
+	}
+	for _, c := range commands {
+		result := db.ProcessCommand(c.command)
+		if result != c.expected {
+			t.Errorf("Ran:" + c.command + ". Expected: " + c.expected + " but Got result:" + result)
+		}
+	}
+}
+
+func TestScanVisitsEveryKeyOnce(t *testing.T) {
+	db := CreateTestDbSetup()
+	expected := make([]string, 0, 50)
+	for i := 0; i < 50; i++ {
+		key := "key:" + strconv.Itoa(i)
+		expected = append(expected, key)
+		db.ProcessCommand("SET " + key + " value")
+	}
+	// a key holding a string can't hold a list too
+	db.ProcessCommand("RPUSH key:0 a")
+
+	var keys []string
+	cursor := "0"
+	for pages := 0; ; pages++ {
+		if pages > 50 {
+			t.Fatalf("SCAN didn't end")
+		}
+		reply := db.ProcessCommandReply("SCAN " + cursor + " COUNT 7")
+		if reply.Kind != REPLY_ARRAY || len(reply.Items) != 2 {
+			t.Fatalf("Unexpected SCAN reply %v", reply)
+		}
+		cursor = reply.Items[0].Text
+		for _, item := range reply.Items[1].Items {
+			keys = append(keys, item.Text)
+		}
+		if cursor == "0" {
+			break
+		}
+	}
+	sort.Strings(keys)
+	sort.Strings(expected)
+	if len(keys) != len(expected) {
+		t.Fatalf("Expected %d keys but got %d: %v", len(expected), len(keys), keys)
+	}
+	for i := range keys {
+		if keys[i] != expected[i] {
+			t.Fatalf("Expected keys %v but got %v", expected, keys)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Runs commands on lists. handled is false if commType isn't one of them.
+func (store *InMemoryStore) processListCommand(commType string, key string, args [][2]string, command string) (result Reply, handled bool) {
+	switch commType {
+	case "LPUSH", "RPUSH":
+		result := store.PUSH(key, firstOfPairs(args), commType == "LPUSH")
+		store.appendToAOF(command)
+		store.notifyKeyspaceEvent(NOTIFY_LIST, strings.ToLower(commType), key)
+		return result, true
+	case "LPOP", "RPOP":
+		left := commType == "LPOP"
+		if len(args) == 0 {
+			result := store.POP(key, left)
+			if result.Kind != REPLY_NIL {
+				store.appendToAOF(command)
+				store.notifyListPop(key, left)
+			}
+			return result, true
+		}
+		count, _ := strconv.ParseInt(args[0][0], 10, 64)
+		result := store.POP_COUNT(key, count, left)
+		if result.Kind != REPLY_NIL && count > 0 {
+			store.appendToAOF(command)
+			store.notifyListPop(key, left)
+		}
+		return result, true
+	case "LRANGE":
+		start, _ := strconv.ParseInt(args[0][0], 10, 64)
+		end, _ := strconv.ParseInt(args[0][1], 10, 64)
+		return store.LRANGE(key, start, end), true
+	case "LINDEX":
+		index, _ := strconv.ParseInt(args[0][0], 10, 64)
+		return store.LINDEX(key, index), true
+	case "LSET":
+		index, _ := strconv.ParseInt(args[0][0], 10, 64)
+		result := store.LSET(key, index, args[0][1])
+		if !result.isError() {
+			store.appendToAOF(command)
+			store.notifyKeyspaceEvent(NOTIFY_LIST, "lset", key)
+		}
+		return result, true
+	case "LLEN":
+		return store.LLEN(key), true
+	case "LREM":
+		count, _ := strconv.ParseInt(args[0][0], 10, 64)
+		result := store.LREM(key, count, args[0][1])
+		if result.Integer != 0 {
+			store.appendToAOF(command)
+			store.notifyKeyspaceEvent(NOTIFY_LIST, "lrem", key)
+			store.notifyIfRemoved(key)
+		}
+		return result, true
+	case "LTRIM":
+		start, _ := strconv.ParseInt(args[0][0], 10, 64)
+		end, _ := strconv.ParseInt(args[0][1], 10, 64)
+		result := store.LTRIM(key, start, end)
+		store.appendToAOF(command)
+		store.notifyKeyspaceEvent(NOTIFY_LIST, "ltrim", key)
+		store.notifyIfRemoved(key)
+		return result, true
+	case "LINSERT":
+		result := store.LINSERT(key, args[0][0] == "AFTER", args[1][0], args[1][1])
+		if result.Integer > 0 {
+			store.appendToAOF(command)
+			store.notifyKeyspaceEvent(NOTIFY_LIST, "linsert", key)
+		}
+		return result, true
+	case "LPOS":
+		var rank, count, maxLen int64 = 1, -1, 0
+		for _, option := range args[1:] {
+			value, _ := strconv.ParseInt(option[1], 10, 64)
+			switch option[0] {
+			case "RANK":
+				rank = value
+			case "COUNT":
+				count = value
+			case "MAXLEN":
+				maxLen = value
+			}
+		}
+		return store.LPOS(key, args[0][0], rank, count, maxLen), true
+	case "LMOVE":
+		result := store.LMOVE(key, args[0][0], args[1][0] == "LEFT", args[1][1] == "LEFT")
+		if result.Kind != REPLY_NIL {
+			store.appendToAOF(command)
+			store.notifyListMove(key, args[0][0], args[1][0] == "LEFT", args[1][1] == "LEFT")
+		}
+		return result, true
+	case "BLPOP", "BRPOP":
+		left := commType == "BLPOP"
+		poppedKey, value, ok := store.BPOP(firstOfPairs(args[1:]), left)
+		if !ok {
+			return nilReply, true
+		}
+		// logged as the pop which actually happened so replay never blocks
+		popCommand := "RPOP"
+		if left {
+			popCommand = "LPOP"
+		}
+		store.appendToAOF(formatCommand(popCommand, poppedKey))
+		store.notifyListPop(poppedKey, left)
+		return bulkArrayReply([]string{poppedKey, value}), true
+	case "BLMOVE":
+		// one attempt, runBlockingCommand retries it until the timeout
+		result := store.LMOVE(key, args[0][0], args[1][0] == "LEFT", args[1][1] == "LEFT")
+		if result.Kind != REPLY_NIL {
+			store.appendToAOF(formatCommand("LMOVE", key, args[0][0], args[1][0], args[1][1]))
+			store.notifyListMove(key, args[0][0], args[1][0] == "LEFT", args[1][1] == "LEFT")
+		}
+		return result, true
+	}
+	return Reply{}, false
+}
+
+// Events of entries popped from the list at key, del too if it was emptied
+func (store *InMemoryStore) notifyListPop(key string, left bool) {
+	if left {
+		store.notifyKeyspaceEvent(NOTIFY_LIST, "lpop", key)
+	} else {
+		store.notifyKeyspaceEvent(NOTIFY_LIST, "rpop", key)
+	}
+	store.notifyIfRemoved(key)
+}
+
+// Events of LMOVE, a pop from source then a push to destination
+func (store *InMemoryStore) notifyListMove(source string, destination string, fromLeft bool, toLeft bool) {
+	store.notifyListPop(source, fromLeft)
+	if toLeft {
+		store.notifyKeyspaceEvent(NOTIFY_LIST, "lpush", destination)
+	} else {
+		store.notifyKeyspaceEvent(NOTIFY_LIST, "rpush", destination)
+	}
+}
+
+// Timeout of a blocking command too large for a duration, like inf
+const timeoutOutOfRange time.Duration = -2
+
+// Converts seconds validated by isTimeout to a duration, timeoutOutOfRange
+// if it doesn't fit in one
+func parseTimeout(seconds string) time.Duration {
+	timeout, _ := strconv.ParseFloat(seconds, 64)
+	if timeout*float64(time.Second) >= math.MaxInt64 {
+		return timeoutOutOfRange
+	}
+	return time.Duration(timeout * float64(time.Second))
+}
+
+// Pushes values to the head if left is true, otherwise the tail, and returns the new length. Perform LPUSH/RPUSH key element [element ...] command
+func (store *InMemoryStore) PUSH(key string, values []string, left bool) Reply {
+	return integerReply(store.list.Push(key, values, left))
+}
+
+// Pops one entry from the head if left is true, otherwise the tail. Perform LPOP/RPOP key command
+func (store *InMemoryStore) POP(key string, left bool) Reply {
+	if values, exists := store.list.Pop(key, 1, left); exists {
+		return bulkReply(values[0])
+	}
+	return nilReply
+}
+
+// Pops up to count entries. Perform LPOP/RPOP key count command
+func (store *InMemoryStore) POP_COUNT(key string, count int64, left bool) Reply {
+	if values, exists := store.list.Pop(key, count, left); exists {
+		return bulkArrayReply(values)
+	}
+	return nilReply
+}
+
+// Entries from start to end inclusive. Perform LRANGE key start stop command
+func (store *InMemoryStore) LRANGE(key string, start int64, end int64) Reply {
+	return bulkArrayReply(store.list.Range(key, start, end))
+}
+
+// Entry at index or nil. Perform LINDEX key index command
+func (store *InMemoryStore) LINDEX(key string, index int64) Reply {
+	if value, exists := store.list.Index(key, index); exists {
+		return bulkReply(value)
+	}
+	return nilReply
+}
+
+// Replaces entry at index. Perform LSET key index element command
+func (store *InMemoryStore) LSET(key string, index int64, value string) Reply {
+	if err := store.list.Set(key, index, value); err != nil {
+		return errorReply(err.Error())
+	}
+	return okReply
+}
+
+// Length of list. Perform LLEN key command
+func (store *InMemoryStore) LLEN(key string) Reply {
+	return integerReply(store.list.Len(key))
+}
+
+// Removes count occurrences of element. Perform LREM key count element command
+func (store *InMemoryStore) LREM(key string, count int64, value string) Reply {
+	return integerReply(store.list.Remove(key, count, value))
+}
+
+// Keeps only entries from start to end inclusive. Perform LTRIM key start stop command
+func (store *InMemoryStore) LTRIM(key string, start int64, end int64) Reply {
+	store.list.Trim(key, start, end)
+	return okReply
+}
+
+// Inserts element next to pivot. Perform LINSERT key BEFORE|AFTER pivot element command
+func (store *InMemoryStore) LINSERT(key string, after bool, pivot string, value string) Reply {
+	return integerReply(store.list.Insert(key, after, pivot, value))
+}
+
+// Index of matching entries. count is -1 when COUNT isn't given, replying with a
+// single index or nil instead of a list. Perform LPOS key element [RANK rank] [COUNT num-matches] [MAXLEN len] command
+func (store *InMemoryStore) LPOS(key string, value string, rank int64, count int64, maxLen int64) Reply {
+	if count == -1 {
+		positions := store.list.Pos(key, value, rank, 1, maxLen)
+		if len(positions) == 0 {
+			return nilReply
+		}
+		return integerReply(positions[0])
+	}
+	positions := store.list.Pos(key, value, rank, count, maxLen)
+	items := make([]Reply, len(positions))
+	for i, position := range positions {
+		items[i] = integerReply(position)
+	}
+	return arrayReply(items)
+}
+
+// Moves an entry between lists atomically. Perform LMOVE source destination LEFT|RIGHT LEFT|RIGHT command
+func (store *InMemoryStore) LMOVE(source string, destination string, fromLeft bool, toLeft bool) Reply {
+	if value, exists := store.list.Move(source, destination, fromLeft, toLeft); exists {
+		return bulkReply(value)
+	}
+	return nilReply
+}
+
+// Pops from the first non empty list. ok is false when they are all empty,
+// runBlockingCommand then retries after a push until the timeout.
+// Perform BLPOP/BRPOP key [key ...] timeout command
+func (store *InMemoryStore) BPOP(keys []string, left bool) (key string, value string, ok bool) {
+	for _, candidate := range keys {
+		if values, exists := store.list.Pop(candidate, 1, left); exists {
+			return candidate, values[0], true
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_List_Commands(t *testing.T) {
+	db := CreateTestDbSetup()
+	commands := []struct {
+		command  string
+		expected string
+	}{
+		{"RPUSH queue a b c", "3"},
+		{"LPUSH queue z", "4"},
+		{"LPUSH queue", "COMMAND NOT VALID"},
+		{"LRANGE queue 0 -1", "1) 'z'\n2) 'a'\n3) 'b'\n4) 'c'\n"},
+		{"LRANGE queue 5 10", "(empty list or set)"},
+		{"LRANGE queue 0 x", "COMMAND NOT VALID"},
+		{"LLEN queue", "4"},
+		{"LINDEX queue -1", "c"},
+		{"LINDEX queue 10", "(nil)"},
+		{"LSET queue 1 A", "OK"},
+		{"LSET queue 10 A", "ERR index out of range"},
+		{"LSET missing 0 A", "ERR no such key"},
+		{"LINSERT queue BEFORE b x", "5"},
+		{"LINSERT queue AFTER missing x", "-1"},
+		{"LINSERT missing AFTER b x", "0"},
+		{"LINSERT queue NEAR b x", "COMMAND NOT VALID"},
+		{"LPOS queue x", "2"},
+		{"LPOS queue missing", "(nil)"},
+		{"LPOS queue x COUNT 0", "1) 2\n"},
+		{"LPOS queue x RANK 0", "COMMAND NOT VALID"},
+		{"LREM queue 0 x", "1"},
+		{"LPOP queue", "z"},
+		{"RPOP queue 2", "1) 'c'\n2) 'b'\n"},
+		{"RPOP queue -1", "COMMAND NOT VALID"},
+		{"LPOP queue 0", "(empty list or set)"},
+		{"LPOP missing", "(nil)"},
+		{"LPOP missing 2", "(nil)"},
+		{"RPUSH src 1 2 3", "3"},
+		{"LMOVE src dst RIGHT LEFT", "3"},
+		{"LMOVE src dst UP LEFT", "COMMAND NOT VALID"},
+		{"LMOVE missing dst RIGHT LEFT", "(nil)"},
+		{"LTRIM src 1 -1", "OK"},
+		{"LRANGE src 0 -1", "1) '2'\n"},
+		{"BLPOP empty src 1", "1) 'src'\n2) '2'\n"},
+		{"BRPOP empty 0.05", "(nil)"},
+		{"BRPOP empty -1", "COMMAND NOT VALID"},
+		{"BRPOP empty inf", "ERR timeout is out of range"},
+		{"BLPOP empty 1e300", "ERR timeout is out of range"},
+		{"BLMOVE missing src LEFT RIGHT 9223372037", "ERR timeout is out of range"},
+		{"BLMOVE dst src LEFT RIGHT 0", "3"},
+		{"BLMOVE missing src LEFT RIGHT 0.05", "(nil)"},
+		{"LLEN src", "1"},
+	}
+	for _, c := range commands {
+		result := db.ProcessCommand(c.command)
+		if result != c.expected {
+			t.Errorf("Ran:" + c.command + ". Expected: " + c.expected + " but Got result:" + result)
+		}
+	}
+}
+
+func Test_BRPOP_Waits_For_LPUSH(t *testing.T) {
+	db := CreateTestDbSetup()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		db.ProcessCommand("LPUSH jobs job1")
+	}()
+	if result := db.ProcessCommand("BRPOP jobs 2"); result != "1) 'jobs'\n2) 'job1'\n" {
+		t.Errorf("Expected BRPOP to receive job1 but got " + result)
+	}
+}
+
+func Test_List_EXPIRE_Command(t *testing.T) {
+	db := CreateTestDbSetup()
+	db.ProcessCommand("RPUSH queue a")
+	if result := db.ProcessCommand("EXPIRE queue 0"); result != "1" {
+		t.Errorf("Expected 1 but got " + result)
+	}
+	if result := db.ProcessCommand("LLEN queue"); result != "0" {
+		t.Errorf("Expected list to expire but got " + result)
+	}
+}
+
+func TestAOFReplaysListCommands(t *testing.T) {
+	AOFfilename, remove := tempAOF(t)
+	defer remove()
+	db := CreateInMemStore(1, AOFfilename)
+	db.ProcessCommand(`RPUSH queue a "b c" d e`)
+	db.ProcessCommand("LPOP queue")
+	db.ProcessCommand("BRPOP queue 1")
+	db.ProcessCommand("LMOVE queue done LEFT RIGHT")
+	db.ProcessCommand("BLMOVE done queue LEFT LEFT 1")
+	db.ProcessCommand("LINSERT queue AFTER d f")
+	db.ProcessCommand("LSET queue 0 B")
+	time.Sleep(2 * time.Second) //give extra time to persist to make sure all data is flushed
+
+	replayed := CreateInMemStore(1, AOFfilename)
+	result := replayed.ProcessCommand("LRANGE queue 0 -1")
+	expected := "1) 'B'\n2) 'd'\n3) 'f'\n"
+	if result != expected {
+		t.Errorf("Expected:\n" + expected + "Got result:\n" + result)
+	}
+	if result := replayed.ProcessCommand("LLEN done"); result != "0" {
+		t.Errorf("Expected done to be empty but got " + result)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+// Runs PUBLISH and PUBSUB commands. Subscribing needs a connection which
+// stays open, so it is only possible over RESP or the /subscribe endpoint.
+// handled is false if commType isn't one of them.
+func (store *InMemoryStore) processPubSubCommand(commType string, key string, args [][2]string, command string) (result Reply, handled bool) {
+	switch commType {
+	case "PUBLISH":
+		return integerReply(int64(store.pubsub.Publish(key, args[0][0]))), true
+	case "SPUBLISH":
+		return integerReply(int64(store.pubsub.SPublish(key, args[0][0]))), true
+	case "PUBSUB":
+		return store.PUBSUB(key, firstOfPairs(args)), true
+	case "SUBSCRIBE", "PSUBSCRIBE", "SSUBSCRIBE", "UNSUBSCRIBE", "PUNSUBSCRIBE", "SUNSUBSCRIBE":
+		return errorReply("ERR " + commType + " is only supported over a RESP connection or the /subscribe endpoint"), true
+	}
+	return Reply{}, false
+}
+
+// Introspects subscriptions. Perform PUBSUB CHANNELS [pattern], PUBSUB NUMSUB [channel ...], PUBSUB NUMPAT,
+// PUBSUB SHARDCHANNELS [pattern] and PUBSUB SHARDNUMSUB [shardchannel ...] commands
+func (store *InMemoryStore) PUBSUB(subcommand string, args []string) Reply {
+	switch subcommand {
+	case "CHANNELS", "SHARDCHANNELS":
+		pattern := "*"
+		if len(args) == 1 {
+			pattern = args[0]
+		}
+		if subcommand == "CHANNELS" {
+			return bulkArrayReply(store.pubsub.Channels(pattern))
+		}
+		return bulkArrayReply(store.pubsub.ShardChannels(pattern))
+	case "NUMSUB", "SHARDNUMSUB":
+		counts := store.pubsub.NumSub(args)
+		if subcommand == "SHARDNUMSUB" {
+			counts = store.pubsub.ShardNumSub(args)
+		}
+		var items []Reply
+		for i, channel := range args {
+			items = append(items, bulkReply(channel), integerReply(int64(counts[i])))
+		}
+		return arrayReply(items)
+	}
+	return integerReply(int64(store.pubsub.NumPat()))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_PubSub_Commands(t *testing.T) {
+	db := CreateTestDbSetup()
+	alice, bob := CreateSubscriber(), CreateSubscriber()
+	db.pubsub.Subscribe(alice, []string{"news", "sports"})
+	db.pubsub.PSubscribe(alice, []string{"news.*"})
+	db.pubsub.Subscribe(bob, []string{"news"})
+	db.pubsub.PSubscribe(bob, []string{"news.*", "n*"})
+	db.pubsub.SSubscribe(bob, []string{"orders"})
+	commands := []struct {
+		command  string
+		expected string
+	}{
+		{"PUBLISH news hello", "3"},
+		{"PUBLISH news.tech hi", "3"},
+		{"PUBLISH weather sunny", "0"},
+		{"SPUBLISH orders 1", "1"},
+		{"SPUBLISH news 1", "0"},
+		{"PUBSUB CHANNELS", "1) 'news'\n2) 'sports'\n"},
+		{"PUBSUB CHANNELS s*", "1) 'sports'\n"},
+		{"PUBSUB CHANNELS x*", "(empty list or set)"},
+		{"PUBSUB NUMSUB news sports weather", "1) 'news'\n2) 2\n3) 'sports'\n4) 1\n5) 'weather'\n6) 0\n"},
+		{"PUBSUB NUMSUB", "(empty list or set)"},
+		{"PUBSUB NUMPAT", "2"},
+		{"PUBSUB SHARDCHANNELS", "1) 'orders'\n"},
+		{"PUBSUB SHARDNUMSUB orders", "1) 'orders'\n2) 1\n"},
+		{"PUBSUB NUMPAT x", "COMMAND NOT VALID"},
+		{"PUBSUB HELP", "COMMAND NOT VALID"},
+		{"PUBLISH news", "COMMAND NOT VALID"},
+		{"SUBSCRIBE news", "ERR SUBSCRIBE is only supported over a RESP connection or the /subscribe endpoint"},
+	}
+	for _, c := range commands {
+		result := db.ProcessCommand(c.command)
+		if result != c.expected {
+			t.Errorf("Ran:" + c.command + ". Expected: " + c.expected + " but Got result:" + result)
+		}
+	}
+
+	db.pubsub.Unsubscribe(bob, nil)
+	db.pubsub.PUnsubscribe(bob, []string{"n*", "missing"})
+	db.pubsub.UnsubscribeAll(alice)
+	if result := db.ProcessCommand("PUBSUB CHANNELS"); result != "(empty list or set)" {
+		t.Errorf("Expected no channels left but got " + result)
+	}
+	if result := db.ProcessCommand("PUBLISH news.tech bye"); result != "1" {
+		t.Errorf("Expected bob's news.* pattern to be left but got " + result)
+	}
+}
+
+func TestSubscriberReceivesMessagesInOrder(t *testing.T) {
+	pubsub := CreatePubSub()
+	s := CreateSubscriber()
+	pubsub.Subscribe(s, []string{"a"})
+	pubsub.PSubscribe(s, []string{"a*"})
+	pubsub.Publish("a", "1")
+	pubsub.Unsubscribe(s, nil)
+	pubsub.PUnsubscribe(s, nil)
+	pubsub.PUnsubscribe(s, nil)
+	pubsub.SSubscribe(s, []string{"b"})
+	pubsub.SPublish("b", "2")
+	expected := []PubSubMessage{
+		{Kind: "subscribe", Channel: "a", Count: 1},
+		{Kind: "psubscribe", Channel: "a*", Count: 2},
+		{Kind: "message", Channel: "a", Payload: "1"},
+		{Kind: "pmessage", Pattern: "a*", Channel: "a", Payload: "1"},
+		{Kind: "unsubscribe", Channel: "a", Count: 1},
+		{Kind: "punsubscribe", Channel: "a*", Count: 0},
+		{Kind: "punsubscribe", NilChannel: true, Count: 0},
+		{Kind: "ssubscribe", Channel: "b", Count: 1},
+		{Kind: "smessage", Channel: "b", Payload: "2"},
+	}
+	for _, message := range expected {
+		if received := <-s.Messages; !reflect.DeepEqual(received, message) {
+			t.Errorf("Expected %+v but got %+v", message, received)
+		}
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	pubsub := CreatePubSub()
+	s := CreateSubscriber()
+	pubsub.Subscribe(s, []string{"a"})
+	for i := 0; i < SUBSCRIBER_BUFFER_SIZE; i++ {
+		pubsub.Publish("a", "x")
+	}
+	select {
+	case <-s.Dropped:
+	default:
+		t.Errorf("Subscriber should be dropped once its buffer is full")
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"github.com/thedeveloperr/redis-clone/lua"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Commands scripts can't call: the ones running scripts, transactions,
+// which scripts already are, subscribing, and changing settings
+var scriptForbiddenCommands = map[string]bool{
+	"EVAL": true, "EVALSHA": true, "SCRIPT": true,
+	"FUNCTION": true, "FCALL": true, "FCALL_RO": true,
+	"MULTI": true, "EXEC": true, "DISCARD": true, "WATCH": true, "UNWATCH": true,
+	"SUBSCRIBE": true, "PSUBSCRIBE": true, "SSUBSCRIBE": true,
+	"UNSUBSCRIBE": true, "PUNSUBSCRIBE": true, "SUNSUBSCRIBE": true,
+	"CONFIG": true,
+}
+
+// Runs EVAL, EVALSHA and SCRIPT commands. Scripts run holding the command
+// lock exclusively, see processParsedCommand, so their commands are applied
+// with none of another client in between. handled is false if commType isn't
+// one of them.
+func (store *InMemoryStore) processScriptCommand(commType string, key string, args [][2]string, command string) (result Reply, handled bool) {
+	switch commType {
+	case "EVAL":
+		return store.EVAL(key, args[0][0], firstOfPairs(args[1:])), true
+	case "EVALSHA":
+		return store.EVALSHA(key, args[0][0], firstOfPairs(args[1:])), true
+	case "SCRIPT":
+		switch key {
+		case "LOAD":
+			return store.SCRIPT_LOAD(args[0][0]), true
+		case "EXISTS":
+			return store.SCRIPT_EXISTS(firstOfPairs(args)), true
+		case "FLUSH":
+			store.scripts.flush()
+			return okReply, true
+		}
+	}
+	return Reply{}, false
+}
+
+// Splits the arguments of EVAL after the script into KEYS and ARGV
+func scriptKeys(numkeys string, args []string) (keys []string, argv []string, failure Reply) {
+	count, err := strconv.ParseInt(numkeys, 10, 64)
+	if err != nil {
+		return nil, nil, errorReply("ERR value is not an integer or out of range")
+	}
+	if count < 0 {
+		return nil, nil, errorReply("ERR Number of keys can't be negative")
+	}
+	if count > int64(len(args)) {
+		return nil, nil, errorReply("ERR Number of keys can't be greater than number of args")
+	}
+	return args[:count], args[count:], Reply{}
+}
+
+// Runs a Lua script with KEYS and ARGV set from args, caching it for EVALSHA.
+// Its commands are logged to the AOF rather than the script, so replay
+// doesn't depend on the script cache or on what the script read. A script
+// runs atomically: it's stopped after lua-time-limit only until it calls a
+// command which writes, then it runs to the end, as there is no rollback of
+// the writes it already made.
+// Perform EVAL script numkeys [key ...] [arg ...] command
+func (store *InMemoryStore) EVAL(source string, numkeys string, args []string) Reply {
+	keys, argv, failure := scriptKeys(numkeys, args)
+	if failure.isError() {
+		return failure
+	}
+	sha, chunk, err := store.scripts.load(source)
+	if err != nil {
+		return errorReply("ERR Error compiling script (new function): " + err.Error())
+	}
+	return store.runScript(sha, chunk, keys, argv)
+}
+
+// Runs a script cached by EVAL or SCRIPT LOAD. Perform EVALSHA sha1 numkeys [key ...] [arg ...] command
+func (store *InMemoryStore) EVALSHA(sha string, numkeys string, args []string) Reply {
+	keys, argv, failure := scriptKeys(numkeys, args)
+	if failure.isError() {
+		return failure
+	}
+	chunk := store.scripts.get(sha)
+	if chunk == nil {
+		return errorReply("NOSCRIPT No matching script. Please use EVAL.")
+	}
+	return store.runScript(strings.ToLower(sha), chunk, keys, argv)
+}
+
+// Caches a script without running it and returns its SHA1. Perform SCRIPT LOAD script command
+func (store *InMemoryStore) SCRIPT_LOAD(source string) Reply {
+	sha, _, err := store.scripts.load(source)
+	if err != nil {
+		return errorReply("ERR Error compiling script (new function): " + err.Error())
+	}
+	return bulkReply(sha)
+}
+
+// 1 for each cached script and 0 for the others. Perform SCRIPT EXISTS sha1 [sha1 ...] command
+func (store *InMemoryStore) SCRIPT_EXISTS(shas []string) Reply {
+	items := make([]Reply, len(shas))
+	for i, sha := range shas {
+		items[i] = booleanReply(store.scripts.get(sha) != nil)
+	}
+	return arrayReply(items)
+}
+
+// Runs a compiled script with KEYS and ARGV set
+func (store *InMemoryStore) runScript(sha string, chunk *lua.Chunk, keys []string, argv []string) Reply {
+	state := store.scriptState(false)
+	state.SetGlobal("KEYS", luaStrings(keys))
+	state.SetGlobal("ARGV", luaStrings(argv))
+	results, err := runRecovered(func() ([]lua.Value, error) {
+		return state.Run(chunk)
+	})
+	return scriptReply(results, err, "ERR Error running script (call to f_"+sha+"): ")
+}
+
+// Runs a script or function, turning a panic of the interpreter into an
+// error so it fails alone instead of taking the server down while it holds
+// the command lock
+func runRecovered(run func() ([]lua.Value, error)) (results []lua.Value, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			serverLog(LOG_WARNING, "Script panicked:", recovered)
+			err = fmt.Errorf("%v", recovered)
+		}
+	}()
+	return run()
+}
+
+// Creates the sandbox scripts and functions run in: only the safe libraries
+// and redis are there and no global can be created. A script still running
+// after lua-time-limit milliseconds is stopped, like redis' SCRIPT KILL, unless
+// it called a command which writes: stopping it then would keep half of its
+// effects, so it runs to the end like in redis, which has no rollback either.
+// Read only ones can't call commands which write.
+func (store *InMemoryStore) scriptState(readOnly bool) *lua.State {
+	state := lua.NewState()
+	state.SetGlobal("redis", store.redisLibrary(readOnly))
+	state.StrictGlobals = true
+	if limit := atomic.LoadInt64(&store.scriptTimeLimit); limit > 0 {
+		state.Deadline = time.Now().Add(time.Duration(limit) * time.Millisecond)
+	}
+	return state
+}
+
+// Reply of a script which returned results or failed with err, whose message
+// follows failure
+func scriptReply(results []lua.Value, err error, failure string) Reply {
+	if err != nil {
+		// errors of redis.call keep the error reply of the command
+		if scriptError, ok := err.(*lua.Error); ok {
+			if table, ok := scriptError.Value.(*lua.Table); ok && table.Get("err") != nil {
+				return luaToReply(table)
+			}
+		}
+		return errorReply(failure + err.Error())
+	}
+	if len(results) == 0 {
+		return nilReply
+	}
+	return luaToReply(results[0])
+}
+
+// The redis table of scripts
+func (store *InMemoryStore) redisLibrary(readOnly bool) *lua.Table {
+	library := lua.NewTable()
+	functions := []*lua.GoFunction{
+		{Name: "call", Fn: func(state *lua.State, args []lua.Value) ([]lua.Value, error) {
+			return store.scriptCall(state, args, false, readOnly)
+		}},
+		{Name: "pcall", Fn: func(state *lua.State, args []lua.Value) ([]lua.Value, error) {
+			return store.scriptCall(state, args, true, readOnly)
+		}},
+		{Name: "error_reply", Fn: func(state *lua.State, args []lua.Value) ([]lua.Value, error) {
+			message, err := lua.CheckString(state, args, 1, "error_reply")
+			return []lua.Value{replyTable("err", message)}, err
+		}},
+		{Name: "status_reply", Fn: func(state *lua.State, args []lua.Value) ([]lua.Value, error) {
+			message, err := lua.CheckString(state, args, 1, "status_reply")
+			return []lua.Value{replyTable("ok", message)}, err
+		}},
+		{Name: "sha1hex", Fn: func(state *lua.State, args []lua.Value) ([]lua.Value, error) {
+			source, err := lua.CheckString(state, args, 1, "sha1hex")
+			return []lua.Value{scriptSHA(source)}, err
+		}},
+	}
+	for _, function := range functions {
+		library.Set(function.Name, function)
+	}
+	addScriptLog(library)
+	library.SetReadonly()
+	return library
+}
+
+// Adds redis.log and its levels, which are also there while functions load
+func addScriptLog(library *lua.Table) {
+	library.Set("log", &lua.GoFunction{Name: "log", Fn: func(state *lua.State, args []lua.Value) ([]lua.Value, error) {
+		level, err := lua.CheckInt(state, args, 1, "log")
+		if err != nil {
+			return nil, err
+		}
+		messages := make([]string, len(args)-1)
+		for i, arg := range args[1:] {
+			messages[i] = lua.ToString(arg)
+		}
+		serverLog(level, "Script:", strings.Join(messages, " "))
+		return nil, nil
+	}})
+	for level, name := range []string{"LOG_DEBUG", "LOG_VERBOSE", "LOG_NOTICE", "LOG_WARNING"} {
+		library.Set(name, float64(level))
+	}
+}
+
+// Runs a command for redis.call and redis.pcall. Its arguments are strings or
+// numbers and the name is case insensitive. Error replies are raised by
+// redis.call and returned by redis.pcall as {err = ...} tables.
+func (store *InMemoryStore) scriptCall(state *lua.State, args []lua.Value, protected bool, readOnly bool) ([]lua.Value, error) {
+	if len(args) == 0 {
+		return nil, state.Errorf("Please specify at least one argument for this redis lib call")
+	}
+	components := make([]string, len(args))
+	for i, arg := range args {
+		switch value := arg.(type) {
+		case string:
+			components[i] = value
+		case float64:
+			components[i] = lua.FormatNumber(value)
+		default:
+			return nil, state.Errorf("Lua redis lib command arguments must be strings or integers")
+		}
+	}
+	components[0] = strings.ToUpper(components[0])
+	command := formatCommand(components...)
+	commType, key, parsed := Command{fullText: command}.parse()
+	var reply Reply
+	switch {
+	case scriptForbiddenCommands[commType]:
+		reply = errorReply("ERR This Redis command is not allowed from script")
+	case readOnly && writeCommands[commType]:
+		reply = errorReply("ERR Write commands are not allowed from read-only scripts.")
+	default:
+		if writeCommands[commType] {
+			// from now on the script isn't stopped, see scriptState
+			state.Deadline = time.Time{}
+		}
+		// the script holds the command lock, blocking commands run once
+		reply = store.countCommand(commType, func() Reply {
+			return store.runCommand(commType, key, parsed, command)
+		})
+	}
+	value := replyToLua(reply)
+	if reply.isError() && !protected {
+		return nil, &lua.Error{Value: value}
+	}
+	return []lua.Value{value}, nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"github.com/thedeveloperr/redis-clone/lua"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Script_Commands(t *testing.T) {
+	db := CreateTestDbSetup()
+	commands := []struct {
+		command  string
+		expected string
+	}{
+		{"EVAL \"return 1\" 0", "1"},
+		{"EVAL \"return {KEYS[1], ARGV[1], ARGV[2]}\" 1 k a b", "1) 'k'\n2) 'a'\n3) 'b'\n"},
+		{"EVAL \"return redis.call('set', KEYS[1], ARGV[1])\" 1 k v", "OK"},
+		{"EVAL \"return redis.call('GET', KEYS[1])\" 1 k", "v"},
+		{"EVAL \"return redis.call('GET', 'missing')\" 0", "(nil)"},
+		{"SET number 42", "OK"},
+		{"EVAL \"return type(redis.call('GET', 'number'))\" 0", "string"},
+		{"SET text \"ERR not an error\"", "OK"},
+		{"EVAL \"return type(redis.call('GET', 'text'))\" 0", "string"},
+		{"EVAL \"return redis.call('INCRBY', 'n', 5) + 1\" 0", "6"},
+		{"EVAL \"return redis.call('RPUSH', 'l', 1, 2.5, 'x')\" 0", "3"},
+		{"EVAL \"return redis.call('LRANGE', 'l', 0, -1)\" 0", "1) '1'\n2) '2.5'\n3) 'x'\n"},
+		{"EVAL \"return #redis.call('LRANGE', 'l', 0, -1)\" 0", "3"},
+		{"EVAL \"return redis.call('LRANGE', 'nothing', 0, -1)\" 0", "(empty list or set)"},
+		{"EVAL \"return {1, 'a', true, false, {2, 'nested'}, nil, 'after nil'}\" 0", "1) 1\n2) 'a'\n3) 1\n4) (nil)\n5) 1) 2\n   2) 'nested'\n"},
+		{"EVAL \"return 3.99\" 0", "3"},
+		{"EVAL \"return nil\" 0", "(nil)"},
+		{"EVAL \"return redis.status_reply('FINE')\" 0", "FINE"},
+		{"EVAL \"return redis.error_reply('custom failure')\" 0", "ERR custom failure"},
+		{"EVAL \"return {err = 'WRONGTYPE kept'}\" 0", "WRONGTYPE kept"},
+		{"EVAL \"return redis.call('INCR', 'k')\" 0", "ERR value is not an integer or out of range"},
+		{"EVAL \"local reply = redis.pcall('INCR', 'k') return reply.err\" 0", "ERR value is not an integer or out of range"},
+		{"EVAL \"return redis.pcall('NOPE')\" 0", "COMMAND NOT VALID"},
+		{"EVAL \"return redis.call('EVAL', 'return 1', 0)\" 0", "ERR This Redis command is not allowed from script"},
+		{"EVAL \"return redis.call('GET', {})\" 0", "ERR Error running script (call to f_1343dc154e60f8f7264d986cb42bf48ba973e527): user_script:1: Lua redis lib command arguments must be strings or integers"},
+		{"EVAL \"x = 1\" 0", "ERR Error running script (call to f_34bce5f775de97f557a34088509c8bfe1ea17e52): user_script:1: Script attempted to create global variable 'x'"},
+		{"EVAL \"return undefined\" 0", "ERR Error running script (call to f_58af0b132b237fe2081dedde3689f246262712fe): user_script:1: Script attempted to access nonexistent global variable 'undefined'"},
+		{"EVAL \"return (\" 0", "ERR Error compiling script (new function): user_script:1: unexpected symbol near '<eof>'"},
+		{"EVAL \"return 1\" 1", "ERR Number of keys can't be greater than number of args"},
+		{"EVAL \"return 1\" -1", "ERR Number of keys can't be negative"},
+		{"EVAL \"return 1\" x", "ERR value is not an integer or out of range"},
+		{"EVAL \"return redis.sha1hex('')\" 0", "da39a3ee5e6b4b0d3255bfef95601890afd80709"},
+		{"SCRIPT LOAD \"return ARGV[1] .. '!'\"", "440f6a5f74c741f61e25dab0574c05e064e646a8"},
+		{"EVALSHA 440f6a5f74c741f61e25dab0574c05e064e646a8 0 hi", "hi!"},
+		{"EVALSHA 440F6A5F74C741F61E25DAB0574C05E064E646A8 0 hey", "hey!"},
+		{"SCRIPT EXISTS 440f6a5f74c741f61e25dab0574c05e064e646a8 e0e1f9fabfc9d4800c877a703b823ac0578ff8db ffff", "1) 1\n2) 1\n3) 0\n"},
+		{"SCRIPT FLUSH", "OK"},
+		{"EVALSHA 440f6a5f74c741f61e25dab0574c05e064e646a8 0 hi", "NOSCRIPT No matching script. Please use EVAL."},
+		{"SCRIPT EXISTS e0e1f9fabfc9d4800c877a703b823ac0578ff8db", "1) 0\n"},
+		{"SCRIPT LOAD \"return (\"", "ERR Error compiling script (new function): user_script:1: unexpected symbol near '<eof>'"},
+		{"SCRIPT FLUSH NOW", "COMMAND NOT VALID"},
+		{"EVAL \"return 1\"", "COMMAND NOT VALID"},
+	}
+	for _, c := range commands {
+		result := db.ProcessCommand(c.command)
+		if result != c.expected {
+			t.Errorf("Ran:" + c.command + ". Expected: " + c.expected + " but Got result:" + result)
+		}
+	}
+}
+
+func Test_Script_Time_Limit(t *testing.T) {
+	db := CreateTestDbSetup()
+	db.ProcessCommand("CONFIG SET lua-time-limit 1")
+	result := db.ProcessCommand("EVAL \"redis.call('GET', 'k') while true do end\" 0")
+	if result != "ERR Error running script (call to f_6350af0faaf4dced2baffdb14595c4e1ac5090c6): script exceeded its time limit" {
+		t.Errorf("Expected the script to be stopped but got " + result)
+	}
+	// once it wrote, stopping it would leave half of its effects, so it runs
+	// to the end however long it takes
+	result = db.ProcessCommand("EVAL \"redis.call('SET', 'k', 'v') local i = 0 while i < 1000000 do i = i + 1 end return redis.call('INCR', 'n')\" 0")
+	if result != "1" {
+		t.Errorf("Expected the script to run to the end but got " + result)
+	}
+	if result := db.ProcessCommand("GET k"); result != "v" {
+		t.Errorf("Expected k to be set but got " + result)
+	}
+}
+
+// Strings can't grow past lua.MAX_STRING_SIZE, so a script doubling one
+// fails instead of exhausting the memory of the server
+func Test_Script_String_Size_Limit(t *testing.T) {
+	db := CreateTestDbSetup()
+	result := db.ProcessCommand("EVAL \"local s='x' for i=1,40 do s = s .. s end return #s\" 0")
+	if !strings.HasSuffix(result, "string length overflow") {
+		t.Errorf("Expected the script to fail but got " + result)
+	}
+	if result := db.ProcessCommand("PING"); result != "PONG" {
+		t.Errorf("Expected the server to keep running but got " + result)
+	}
+}
+
+func TestScriptPanicsAreRecovered(t *testing.T) {
+	_, err := runRecovered(func() ([]lua.Value, error) {
+		panic("interpreter bug")
+	})
+	if err == nil || err.Error() != "interpreter bug" {
+		t.Errorf("Expected the panic to be returned as an error but got %v", err)
+	}
+}
+
+func Test_Script_Runs_Without_Other_Commands_In_Between(t *testing.T) {
+	db := CreateTestDbSetup()
+	done := make(chan string)
+	go func() {
+		done <- db.ProcessCommand("EVAL \"redis.call('SET', 'k', 'first') local i = 0 while i < 200000 do i = i + 1 end return redis.call('GET', 'k')\" 0")
+	}()
+	time.Sleep(10 * time.Millisecond)
+	db.ProcessCommand("SET k second")
+	if result := <-done; result != "first" {
+		t.Errorf("Expected the script not to see other commands but got " + result)
+	}
+}
+
+func TestAOFLogsScriptEffects(t *testing.T) {
+	AOFfilename, remove := tempAOF(t)
+	defer remove()
+	db := CreateInMemStore(1, AOFfilename)
+	db.ProcessCommand("SADD s only")
+	db.ProcessCommand("EVAL \"local member = redis.call('SPOP', KEYS[1]) redis.call('SET', KEYS[2], member) return member\" 2 s popped")
+	db.ProcessCommand("EVAL \"return redis.call('GET', 'popped')\" 0")
+	time.Sleep(2 * time.Second) //give extra time to persist to make sure all data is flushed
+
+	content, _ := ioutil.ReadFile(AOFfilename)
+	expected := "SADD s only\nMULTI\nSREM s only\nSET popped only\nEXEC\n"
+	if string(content) != expected {
+		t.Errorf("Expected AOF:\n" + expected + "Got:\n" + string(content))
+	}
+	replayed := CreateInMemStore(1, AOFfilename)
+	for command, expected := range map[string]string{
+		"GET popped": "only",
+		"SCARD s":    "0",
+		"SCRIPT EXISTS 406fc672fb9e863f4affcc5cf3b12d7d78f75fa3": "1) 0\n",
+	} {
+		if result := replayed.ProcessCommand(command); result != expected {
+			t.Errorf("Ran:" + command + ". Expected: " + expected + " but Got result:" + result)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"github.com/thedeveloperr/redis-clone/setMap"
+	"strconv"
+	"strings"
+)
+
+// Runs commands on unordered sets. handled is false if commType isn't one of them.
+func (store *InMemoryStore) processSetCommand(commType string, key string, args [][2]string, command string) (result Reply, handled bool) {
+	switch commType {
+	case "SADD":
+		result := store.SADD(key, firstOfPairs(args))
+		if result.Integer != 0 {
+			store.appendToAOF(command)
+			store.notifyKeyspaceEvent(NOTIFY_SET, "sadd", key)
+		}
+		return result, true
+	case "SREM":
+		result := store.SREM(key, firstOfPairs(args))
+		if result.Integer != 0 {
+			store.appendToAOF(command)
+			store.notifyKeyspaceEvent(NOTIFY_SET, "srem", key)
+			store.notifyIfRemoved(key)
+		}
+		return result, true
+	case "SISMEMBER":
+		return store.SISMEMBER(key, args[0][0]), true
+	case "SMISMEMBER":
+		return store.SMISMEMBER(key, firstOfPairs(args)), true
+	case "SMEMBERS":
+		return store.SMEMBERS(key), true
+	case "SCARD":
+		return store.SCARD(key), true
+	case "SPOP":
+		count := int64(1)
+		if len(args) == 1 {
+			count, _ = strconv.ParseInt(args[0][0], 10, 64)
+		}
+		members, exists := store.SPOP(key, count)
+		if len(members) > 0 {
+			// popped members are random so the AOF records which ones went
+			store.appendToAOF(formatCommand(append([]string{"SREM", key}, members...)...))
+			store.notifyKeyspaceEvent(NOTIFY_SET, "spop", key)
+			store.notifyIfRemoved(key)
+		}
+		if len(args) == 1 {
+			return bulkArrayReply(members), true
+		}
+		if !exists {
+			return nilReply, true
+		}
+		return bulkReply(members[0]), true
+	case "SRANDMEMBER":
+		if len(args) == 0 {
+			return store.SRANDMEMBER(key), true
+		}
+		count, _ := strconv.ParseInt(args[0][0], 10, 64)
+		return store.SRANDMEMBER_COUNT(key, count), true
+	case "SMOVE":
+		result := store.SMOVE(key, args[0][0], args[0][1])
+		if result.Integer == 1 {
+			store.appendToAOF(command)
+			store.notifyKeyspaceEvent(NOTIFY_SET, "srem", key)
+			store.notifyIfRemoved(key)
+			store.notifyKeyspaceEvent(NOTIFY_SET, "sadd", args[0][0])
+		}
+		return result, true
+	case "SINTER":
+		return store.combineSets(setMap.INTER, firstOfPairs(args)), true
+	case "SUNION":
+		return store.combineSets(setMap.UNION, firstOfPairs(args)), true
+	case "SDIFF":
+		return store.combineSets(setMap.DIFF, firstOfPairs(args)), true
+	case "SINTERSTORE", "SUNIONSTORE", "SDIFFSTORE":
+		operation := setMap.INTER
+		if commType == "SUNIONSTORE" {
+			operation = setMap.UNION
+		} else if commType == "SDIFFSTORE" {
+			operation = setMap.DIFF
+		}
+		existed := store.keyExists(key)
+		result := store.combineSetsStore(operation, key, firstOfPairs(args))
+		store.appendToAOF(command)
+		store.notifyStore(NOTIFY_SET, strings.ToLower(commType), key, existed)
+		return result, true
+	case "SINTERCARD":
+		limit, _ := strconv.Atoi(args[0][0])
+		return store.SINTERCARD(firstOfPairs(args[1:]), limit), true
+	case "SSCAN":
+		cursor, match, count, _ := scanOptions(args)
+		return store.SSCAN(key, cursor, match, count), true
+	}
+	return Reply{}, false
+}
+
+// Adds members and returns how many are new. Perform SADD key member [member ...] command
+func (store *InMemoryStore) SADD(key string, members []string) Reply {
+	return integerReply(int64(store.set.Add(key, members)))
+}
+
+// Removes members and returns how many existed. Perform SREM key member [member ...] command
+func (store *InMemoryStore) SREM(key string, members []string) Reply {
+	return integerReply(int64(store.set.Remove(key, members)))
+}
+
+// 1 if member is in the set. Perform SISMEMBER key member command
+func (store *InMemoryStore) SISMEMBER(key string, member string) Reply {
+	return booleanReply(store.set.IsMember(key, member))
+}
+
+// 1 or 0 for each member. Perform SMISMEMBER key member [member ...] command
+func (store *InMemoryStore) SMISMEMBER(key string, members []string) Reply {
+	results := store.set.MIsMember(key, members)
+	items := make([]Reply, len(results))
+	for i, isMember := range results {
+		items[i] = booleanReply(isMember)
+	}
+	return arrayReply(items)
+}
+
+// All members. Perform SMEMBERS key command
+func (store *InMemoryStore) SMEMBERS(key string) Reply {
+	return bulkArrayReply(store.set.Members(key))
+}
+
+// Number of members. Perform SCARD key command
+func (store *InMemoryStore) SCARD(key string) Reply {
+	return integerReply(int64(store.set.Card(key)))
+}
+
+// Removes up to count random members. Perform SPOP key [count] command
+func (store *InMemoryStore) SPOP(key string, count int64) (members []string, exists bool) {
+	return store.set.Pop(key, count)
+}
+
+// One random member or nil. Perform SRANDMEMBER key command
+func (store *InMemoryStore) SRANDMEMBER(key string) Reply {
+	members := store.set.RandomMembers(key, 1)
+	if len(members) == 0 {
+		return nilReply
+	}
+	return bulkReply(members[0])
+}
+
+// Random members, which may repeat for negative count. Perform SRANDMEMBER key count command
+func (store *InMemoryStore) SRANDMEMBER_COUNT(key string, count int64) Reply {
+	return bulkArrayReply(store.set.RandomMembers(key, count))
+}
+
+// Moves member between sets atomically. Perform SMOVE source destination member command
+func (store *InMemoryStore) SMOVE(source string, destination string, member string) Reply {
+	return booleanReply(store.set.Move(source, destination, member))
+}
+
+// Members of the intersection, union or difference of sets. Perform SINTER/SUNION/SDIFF key [key ...] command
+func (store *InMemoryStore) combineSets(operation int, keys []string) Reply {
+	return bulkArrayReply(store.set.Combine(operation, keys))
+}
+
+// Stores the combined sets and returns its size. Perform SINTERSTORE/SUNIONSTORE/SDIFFSTORE destination key [key ...] command
+func (store *InMemoryStore) combineSetsStore(operation int, destination string, keys []string) Reply {
+	return integerReply(int64(store.set.CombineStore(operation, destination, keys)))
+}
+
+// Size of the intersection, stopping at limit if not 0. Perform SINTERCARD numkeys key [key ...] [LIMIT limit] command
+func (store *InMemoryStore) SINTERCARD(keys []string, limit int) Reply {
+	return integerReply(int64(store.set.InterCard(keys, limit)))
+}
+
+// Iterates members of a set. Perform SSCAN key cursor [MATCH pattern] [COUNT count] command
+func (store *InMemoryStore) SSCAN(key string, cursor uint64, match string, count int) Reply {
+	nextCursor, members := store.set.Scan(key, cursor, count)
+	var items []string
+	for _, member := range members {
+		if match == "" || globMatch(match, member) {
+			items = append(items, member)
+		}
+	}
+	return scanReply(nextCursor, items)
+}
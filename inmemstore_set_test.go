@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_Set_Commands(t *testing.T) {
+	db := CreateTestDbSetup()
+	commands := []struct {
+		command  string
+		expected string
+	}{
+		{"SADD tags go redis db", "3"},
+		{"SADD tags go", "0"},
+		{"SADD tags", "COMMAND NOT VALID"},
+		{"SMEMBERS tags", "1) 'db'\n2) 'go'\n3) 'redis'\n"},
+		{"SCARD tags", "3"},
+		{"SISMEMBER tags go", "1"},
+		{"SISMEMBER tags java", "0"},
+		{"SMISMEMBER tags go java", "1) 1\n2) 0\n"},
+		{"SREM tags db java", "1"},
+		{"SADD other redis cache", "2"},
+		{"SINTER tags other", "1) 'redis'\n"},
+		{"SUNION tags other", "1) 'cache'\n2) 'go'\n3) 'redis'\n"},
+		{"SDIFF tags other", "1) 'go'\n"},
+		{"SUNIONSTORE all tags other", "3"},
+		{"SINTERSTORE none tags missing", "0"},
+		{"SDIFFSTORE diff other tags", "1"},
+		{"SMEMBERS diff", "1) 'cache'\n"},
+		{"SINTERCARD 2 tags other", "1"},
+		{"SINTERCARD 2 all tags LIMIT 1", "1"},
+		{"SINTERCARD 3 tags other", "COMMAND NOT VALID"},
+		{"SINTERCARD 1 tags LIMIT", "COMMAND NOT VALID"},
+		{"SMOVE all dst go", "1"},
+		{"SMOVE all dst go", "0"},
+		{"SSCAN dst 0", "1) '0'\n2) 1) 'go'\n"},
+		{"SSCAN dst 0 NOVALUES", "COMMAND NOT VALID"},
+		{"SADD ids 3 1 2", "3"},
+		{"SMEMBERS ids", "1) '1'\n2) '2'\n3) '3'\n"},
+		{"SSCAN ids 0 MATCH 2", "1) '0'\n2) 1) '2'\n"},
+		{"SPOP dst", "go"},
+		{"SPOP dst", "(nil)"},
+		{"SPOP dst 2", "(empty list or set)"},
+		{"SPOP ids -1", "COMMAND NOT VALID"},
+		{"SRANDMEMBER missing", "(nil)"},
+		{"SRANDMEMBER diff", "cache"},
+		{"SRANDMEMBER diff -3", "1) 'cache'\n2) 'cache'\n3) 'cache'\n"},
+		{"SRANDMEMBER diff 3", "1) 'cache'\n"},
+	}
+	for _, c := range commands {
+		result := db.ProcessCommand(c.command)
+		if result != c.expected {
+			t.Errorf("Ran:" + c.command + ". Expected: " + c.expected + " but Got result:" + result)
+		}
+	}
+}
+
+func TestAOFReplaysSetCommands(t *testing.T) {
+	AOFfilename, remove := tempAOF(t)
+	defer remove()
+	db := CreateInMemStore(1, AOFfilename)
+	db.ProcessCommand("SADD s 1 2 3 4 5")
+	db.ProcessCommand("SPOP s 2")
+	db.ProcessCommand(`SADD t "a b" 3`)
+	db.ProcessCommand("SINTERSTORE both s t")
+	db.ProcessCommand(`SMOVE t moved "a b"`)
+	time.Sleep(2 * time.Second) //give extra time to persist to make sure all data is flushed
+
+	replayed := CreateInMemStore(1, AOFfilename)
+	for _, check := range []string{"SMEMBERS s", "SMEMBERS both", "SMEMBERS moved"} {
+		if result, expected := replayed.ProcessCommand(check), db.ProcessCommand(check); result != expected {
+			t.Errorf("Ran:" + check + ". Expected:\n" + expected + "Got result:\n" + result)
+		}
+	}
+	if result := replayed.ProcessCommand("SCARD s"); result != "3" {
+		t.Errorf("Expected 3 members left after SPOP but got " + result)
+	}
+}
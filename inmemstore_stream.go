@@ -0,0 +1,474 @@
+package main
+
+import (
+	"github.com/thedeveloperr/redis-clone/streamMap"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Runs commands on streams. handled is false if commType isn't one of them.
+func (store *InMemoryStore) processStreamCommand(commType string, key string, args [][2]string, command string) (result Reply, handled bool) {
+	switch commType {
+	case "XADD":
+		return store.XADD(key, args), true
+	case "XTRIM":
+		removed := store.XTRIM(key, trimOptions(args))
+		if removed.Integer != 0 {
+			store.appendToAOF(command)
+			store.notifyKeyspaceEvent(NOTIFY_STREAM, "xtrim", key)
+		}
+		return removed, true
+	case "XRANGE", "XREVRANGE":
+		reverse := commType == "XREVRANGE"
+		start, _ := streamMap.ParseRangeBound(args[0][0], !reverse)
+		end, _ := streamMap.ParseRangeBound(args[0][1], reverse)
+		if reverse {
+			start, end = end, start
+		}
+		count := 0
+		if len(args) == 2 {
+			count, _ = strconv.Atoi(args[1][1])
+		}
+		return store.XRANGE(key, start, end, count, reverse), true
+	case "XLEN":
+		return store.XLEN(key), true
+	case "XDEL":
+		result := store.XDEL(key, parseStreamIDs(args))
+		if result.Integer != 0 {
+			store.appendToAOF(command)
+			store.notifyKeyspaceEvent(NOTIFY_STREAM, "xdel", key)
+		}
+		return result, true
+	case "XREAD":
+		return store.XREAD(args), true
+	case "XREADGROUP":
+		return store.XREADGROUP(args), true
+	case "XGROUP":
+		return store.XGROUP(key, args, command), true
+	case "XACK":
+		result := store.XACK(key, args[0][0], parseStreamIDs(args[1:]))
+		if result.Integer != 0 {
+			store.appendToAOF(command)
+		}
+		return result, true
+	case "XPENDING":
+		return store.XPENDING(key, args), true
+	case "XCLAIM":
+		return store.XCLAIM(key, args), true
+	case "XAUTOCLAIM":
+		return store.XAUTOCLAIM(key, args), true
+	}
+	return Reply{}, false
+}
+
+// IDs from the first element of each argument, already validated by the parser
+func parseStreamIDs(args [][2]string) []streamMap.StreamID {
+	ids := make([]streamMap.StreamID, len(args))
+	for i := range args {
+		ids[i], _ = streamMap.ParseID(args[i][0], 0)
+	}
+	return ids
+}
+
+// Reads trim arguments parsed by parseStreamTrim, ignoring other options
+func trimOptions(args [][2]string) streamMap.TrimOptions {
+	var options streamMap.TrimOptions
+	for _, option := range args {
+		switch option[0] {
+		case "MAXLEN":
+			options.Strategy = "MAXLEN"
+			options.MaxLen, _ = strconv.ParseInt(option[1], 10, 64)
+		case "MINID":
+			options.Strategy = "MINID"
+			options.MinID, _ = streamMap.ParseID(option[1], 0)
+		case "LIMIT":
+			options.Limit, _ = strconv.ParseInt(option[1], 10, 64)
+		}
+	}
+	return options
+}
+
+// Entry as a list of its ID and its field value pairs, (nil) for the
+// pairs of an entry deleted while pending
+func formatStreamEntry(entry streamMap.Entry) Reply {
+	fields := nilReply
+	if entry.Fields != nil {
+		fields = bulkArrayReply(entry.Fields)
+	}
+	return arrayReply([]Reply{bulkReply(entry.ID.String()), fields})
+}
+
+func formatStreamEntries(entries []streamMap.Entry) Reply {
+	items := make([]Reply, len(entries))
+	for i, entry := range entries {
+		items[i] = formatStreamEntry(entry)
+	}
+	return arrayReply(items)
+}
+
+func formatStreamIDs(ids []streamMap.StreamID) Reply {
+	items := make([]string, len(ids))
+	for i, id := range ids {
+		items[i] = id.String()
+	}
+	return bulkArrayReply(items)
+}
+
+// Appends an entry and returns its ID, or (nil) with NOMKSTREAM on a missing
+// stream. Perform XADD key [NOMKSTREAM] [MAXLEN|MINID [=|~] threshold [LIMIT count]] *|id field value [field value ...] command
+func (store *InMemoryStore) XADD(key string, args [][2]string) Reply {
+	noMkStream := false
+	var addID streamMap.AddID
+	// the AOF gets the generated ID so replay recreates the same entry
+	logged := []string{"XADD", key}
+	i := 0
+	for ; args[i][0] != "ID"; i++ {
+		switch args[i][0] {
+		case "NOMKSTREAM":
+			noMkStream = true
+			logged = append(logged, "NOMKSTREAM")
+		case "MAXLEN", "MINID":
+			logged = append(logged, args[i][0], "=", args[i][1])
+		case "LIMIT":
+			logged = append(logged, "LIMIT", args[i][1])
+		}
+	}
+	addID, _ = streamMap.ParseAddID(args[i][1])
+	var fields []string
+	for _, pair := range args[i+1:] {
+		fields = append(fields, pair[0], pair[1])
+	}
+	id, exists, err := store.stream.Add(key, addID, fields, noMkStream, trimOptions(args[:i]))
+	if err != nil {
+		return errorReply(err.Error())
+	}
+	if !exists {
+		return nilReply
+	}
+	logged = append(logged, id.String())
+	store.appendToAOF(formatCommand(append(logged, fields...)...))
+	store.notifyKeyspaceEvent(NOTIFY_STREAM, "xadd", key)
+	return bulkReply(id.String())
+}
+
+// Evicts old entries and returns how many. Perform XTRIM key MAXLEN|MINID [=|~] threshold [LIMIT count] command
+func (store *InMemoryStore) XTRIM(key string, options streamMap.TrimOptions) Reply {
+	return integerReply(store.stream.Trim(key, options))
+}
+
+// Entries between two IDs. Perform XRANGE key start end [COUNT count] and XREVRANGE key end start [COUNT count] command
+func (store *InMemoryStore) XRANGE(key string, start streamMap.StreamID, end streamMap.StreamID, count int, reverse bool) Reply {
+	return formatStreamEntries(store.stream.Range(key, start, end, count, reverse))
+}
+
+// Number of entries. Perform XLEN key command
+func (store *InMemoryStore) XLEN(key string) Reply {
+	return integerReply(int64(store.stream.Len(key)))
+}
+
+// Removes entries and returns how many existed. Perform XDEL key id [id ...] command
+func (store *InMemoryStore) XDEL(key string, ids []streamMap.StreamID) Reply {
+	return integerReply(int64(store.stream.Delete(key, ids)))
+}
+
+// Reads count and block options parsed by parseStreamRead. block is -1
+// without BLOCK and timeoutOutOfRange if it doesn't fit in a duration.
+func streamReadOptions(option [2]string) (count int, block time.Duration) {
+	count, _ = strconv.Atoi(option[0])
+	block = -1
+	if option[1] != "" {
+		milliseconds, _ := strconv.ParseInt(option[1], 10, 64)
+		if milliseconds > math.MaxInt64/int64(time.Millisecond) {
+			// out of range, reported by processParsedCommand
+			return count, timeoutOutOfRange
+		}
+		block = time.Duration(milliseconds) * time.Millisecond
+	}
+	return count, block
+}
+
+// Lists streams with entries as their key followed by the entries
+func formatStreamReads(keys []string, results [][]streamMap.Entry, includeEmpty bool) Reply {
+	var items []Reply
+	for i, entries := range results {
+		if len(entries) > 0 || includeEmpty {
+			items = append(items, arrayReply([]Reply{bulkReply(keys[i]), formatStreamEntries(entries)}))
+		}
+	}
+	if len(items) == 0 {
+		return nilReply
+	}
+	return arrayReply(items)
+}
+
+// Entries after the given IDs, "$" meaning the last one. With BLOCK,
+// runBlockingCommand retries it until there are some or the timeout.
+// Perform XREAD [COUNT count] [BLOCK milliseconds] STREAMS key [key ...] id [id ...] command
+func (store *InMemoryStore) XREAD(args [][2]string) Reply {
+	count, _ := streamReadOptions(args[0])
+	streams := args[2:]
+	keys := make([]string, len(streams))
+	after := make([]streamMap.StreamID, len(streams))
+	for i, stream := range streams {
+		keys[i] = stream[0]
+		if stream[1] == "$" {
+			after[i] = store.stream.LastID(stream[0])
+		} else {
+			after[i], _ = streamMap.ParseID(stream[1], 0)
+		}
+	}
+	return formatStreamReads(keys, store.stream.Read(keys, after, count), false)
+}
+
+// Reads as a consumer of a group, ">" for entries never delivered to the group
+// and an ID to read the consumer's pending entries again.
+// Perform XREADGROUP GROUP group consumer [COUNT count] [BLOCK milliseconds] [NOACK] STREAMS key [key ...] id [id ...] command
+func (store *InMemoryStore) XREADGROUP(args [][2]string) Reply {
+	group, consumer := args[0][0], args[0][1]
+	count, _ := streamReadOptions(args[1])
+	noAck := args[2][0] == "NOACK"
+	streams := args[3:]
+	keys := make([]string, len(streams))
+	after := make([]*streamMap.StreamID, len(streams))
+	readsHistory := false
+	for i, stream := range streams {
+		keys[i] = stream[0]
+		if stream[1] != ">" {
+			id, _ := streamMap.ParseID(stream[1], 0)
+			after[i] = &id
+			readsHistory = true
+		}
+	}
+	results, err := store.stream.ReadGroup(keys, group, consumer, after, count, noAck)
+	if err != nil {
+		return errorReply(err.Error())
+	}
+	entries := make([][]streamMap.Entry, len(results))
+	for i, result := range results {
+		entries[i] = result.Entries
+		store.logGroupRead(keys[i], group, consumer, after[i] == nil, noAck, result)
+	}
+	return formatStreamReads(keys, entries, readsHistory)
+}
+
+// Logs the effects of a group read: the new consumer and, for new entries,
+// the delivery recorded for each so replay rebuilds the same pending entries
+func (store *InMemoryStore) logGroupRead(key string, group string, consumer string, readNew bool, noAck bool, result streamMap.GroupRead) {
+	if result.NewConsumer {
+		store.appendToAOF(formatCommand("XGROUP", "CREATECONSUMER", key, group, consumer))
+		store.notifyKeyspaceEvent(NOTIFY_STREAM, "xgroup-createconsumer", key)
+	}
+	if !readNew || len(result.Entries) == 0 {
+		return
+	}
+	if noAck {
+		lastID := result.Entries[len(result.Entries)-1].ID
+		store.appendToAOF(formatCommand("XGROUP", "SETID", key, group, lastID.String()))
+		return
+	}
+	for _, entry := range result.Entries {
+		store.logClaim(key, group, streamMap.PendingEntry{
+			ID:            entry.ID,
+			Consumer:      consumer,
+			DeliveryTime:  result.DeliveredAt,
+			DeliveryCount: 1,
+		}, entry.ID)
+	}
+}
+
+// Logs pending entry as an XCLAIM which recreates it exactly, whatever its idle time on replay
+func (store *InMemoryStore) logClaim(key string, group string, pending streamMap.PendingEntry, lastID streamMap.StreamID) {
+	store.appendToAOF(formatCommand("XCLAIM", key, group, pending.Consumer, "0", pending.ID.String(),
+		"TIME", strconv.FormatInt(unixMilli(pending.DeliveryTime), 10),
+		"RETRYCOUNT", strconv.FormatUint(pending.DeliveryCount, 10),
+		"FORCE", "JUSTID", "LASTID", lastID.String()))
+}
+
+// Manages consumer groups. Perform XGROUP CREATE key group id|$ [MKSTREAM],
+// XGROUP SETID key group id|$, XGROUP DESTROY key group and
+// XGROUP CREATECONSUMER|DELCONSUMER key group consumer commands
+func (store *InMemoryStore) XGROUP(key string, args [][2]string, command string) Reply {
+	subcommand, group := args[0][0], args[0][1]
+	switch subcommand {
+	case "CREATE", "SETID":
+		var lastDelivered *streamMap.StreamID
+		if args[1][0] != "$" {
+			id, _ := streamMap.ParseID(args[1][0], 0)
+			lastDelivered = &id
+		}
+		var id streamMap.StreamID
+		var err error
+		if subcommand == "CREATE" {
+			id, err = store.stream.CreateGroup(key, group, lastDelivered, args[1][1] == "MKSTREAM")
+		} else {
+			id, err = store.stream.SetGroupID(key, group, lastDelivered)
+		}
+		if err != nil {
+			return errorReply(err.Error())
+		}
+		// "$" is logged as the ID it stood for at the time
+		logged := []string{"XGROUP", subcommand, key, group, id.String()}
+		if args[1][1] == "MKSTREAM" {
+			logged = append(logged, "MKSTREAM")
+		}
+		store.appendToAOF(formatCommand(logged...))
+		store.notifyKeyspaceEvent(NOTIFY_STREAM, "xgroup-"+strings.ToLower(subcommand), key)
+		return okReply
+	case "DESTROY":
+		result := store.stream.DestroyGroup(key, group)
+		if result == 1 {
+			store.appendToAOF(command)
+			store.notifyKeyspaceEvent(NOTIFY_STREAM, "xgroup-destroy", key)
+		}
+		return integerReply(int64(result))
+	case "CREATECONSUMER":
+		result, err := store.stream.CreateConsumer(key, group, args[1][0])
+		if err != nil {
+			return errorReply(err.Error())
+		}
+		if result == 1 {
+			store.appendToAOF(command)
+			store.notifyKeyspaceEvent(NOTIFY_STREAM, "xgroup-createconsumer", key)
+		}
+		return integerReply(int64(result))
+	case "DELCONSUMER":
+		result, err := store.stream.DeleteConsumer(key, group, args[1][0])
+		if err != nil {
+			return errorReply(err.Error())
+		}
+		store.appendToAOF(command)
+		store.notifyKeyspaceEvent(NOTIFY_STREAM, "xgroup-delconsumer", key)
+		return integerReply(int64(result))
+	}
+	return errorReply("COMMAND NOT VALID")
+}
+
+// Acknowledges pending entries. Perform XACK key group id [id ...] command
+func (store *InMemoryStore) XACK(key string, group string, ids []streamMap.StreamID) Reply {
+	return integerReply(int64(store.stream.Ack(key, group, ids)))
+}
+
+// Summary of pending entries, or the entries themselves when a range is given.
+// Perform XPENDING key group [[IDLE min-idle-time] start end count [consumer]] command
+func (store *InMemoryStore) XPENDING(key string, args [][2]string) Reply {
+	group := args[0][0]
+	if len(args) == 1 {
+		pending, consumers, err := store.stream.PendingSummary(key, group)
+		if err != nil {
+			return errorReply(err.Error())
+		}
+		if len(pending) == 0 {
+			return arrayReply([]Reply{integerReply(0), nilReply, nilReply, nilReply})
+		}
+		counts := make([]Reply, len(consumers))
+		for i, consumer := range consumers {
+			counts[i] = bulkArrayReply([]string{consumer.Consumer, strconv.Itoa(consumer.Count)})
+		}
+		return arrayReply([]Reply{
+			integerReply(int64(len(pending))),
+			bulkReply(pending[0].ID.String()),
+			bulkReply(pending[len(pending)-1].ID.String()),
+			arrayReply(counts),
+		})
+	}
+	start, _ := streamMap.ParseRangeBound(args[1][0], true)
+	end, _ := streamMap.ParseRangeBound(args[1][1], false)
+	count, _ := strconv.Atoi(args[2][0])
+	idle, _ := strconv.ParseInt(args[3][0], 10, 64)
+	if count == 0 {
+		return arrayReply(nil)
+	}
+	pending, err := store.stream.Pending(key, group, time.Duration(idle)*time.Millisecond, start, end, count, args[2][1])
+	if err != nil {
+		return errorReply(err.Error())
+	}
+	now := time.Now()
+	items := make([]Reply, len(pending))
+	for i, entry := range pending {
+		items[i] = arrayReply([]Reply{
+			bulkReply(entry.ID.String()),
+			bulkReply(entry.Consumer),
+			integerReply(int64(now.Sub(entry.DeliveryTime) / time.Millisecond)),
+			integerReply(int64(entry.DeliveryCount)),
+		})
+	}
+	return arrayReply(items)
+}
+
+// Claimed entries, or only their IDs with JUSTID
+func formatClaimed(claimed []streamMap.Claimed, justID bool) Reply {
+	items := make([]Reply, len(claimed))
+	for i, claim := range claimed {
+		if justID {
+			items[i] = bulkReply(claim.Entry.ID.String())
+		} else {
+			items[i] = formatStreamEntry(claim.Entry)
+		}
+	}
+	return arrayReply(items)
+}
+
+// Logs claimed entries and drops of deleted ones so replay doesn't depend on idle times
+func (store *InMemoryStore) logClaimed(key string, group string, claimed []streamMap.Claimed, deleted []streamMap.StreamID, lastID streamMap.StreamID) {
+	for _, claim := range claimed {
+		store.logClaim(key, group, claim.Pending, lastID)
+	}
+	if len(deleted) > 0 {
+		logged := []string{"XACK", key, group}
+		for _, id := range deleted {
+			logged = append(logged, id.String())
+		}
+		store.appendToAOF(formatCommand(logged...))
+	}
+}
+
+// Takes over pending entries idle for at least min-idle-time.
+// Perform XCLAIM key group consumer min-idle-time id [id ...] [IDLE ms] [TIME unix-time-milliseconds] [RETRYCOUNT count] [FORCE] [JUSTID] [LASTID lastid] command
+func (store *InMemoryStore) XCLAIM(key string, args [][2]string) Reply {
+	group, consumer := args[0][0], args[0][1]
+	minIdle, _ := strconv.ParseInt(args[1][0], 10, 64)
+	numIDs, _ := strconv.Atoi(args[1][1])
+	ids := parseStreamIDs(args[2 : 2+numIDs])
+	options := streamMap.ClaimOptions{Idle: -1, RetryCount: -1}
+	for _, option := range args[2+numIDs:] {
+		value, _ := strconv.ParseInt(option[1], 10, 64)
+		switch option[0] {
+		case "IDLE":
+			options.Idle = time.Duration(value) * time.Millisecond
+		case "TIME":
+			options.Time = fromUnixMilli(value)
+		case "RETRYCOUNT":
+			options.RetryCount = value
+		case "FORCE":
+			options.Force = true
+		case "JUSTID":
+			options.JustID = true
+		case "LASTID":
+			options.LastID, _ = streamMap.ParseID(option[1], 0)
+		}
+	}
+	claimed, deleted, err := store.stream.Claim(key, group, consumer, time.Duration(minIdle)*time.Millisecond, ids, options)
+	if err != nil {
+		return errorReply(err.Error())
+	}
+	store.logClaimed(key, group, claimed, deleted, options.LastID)
+	return formatClaimed(claimed, options.JustID)
+}
+
+// Claims up to count pending entries idle for min-idle-time scanning from start.
+// Perform XAUTOCLAIM key group consumer min-idle-time start [COUNT count] [JUSTID] command
+func (store *InMemoryStore) XAUTOCLAIM(key string, args [][2]string) Reply {
+	group, consumer := args[0][0], args[0][1]
+	minIdle, _ := strconv.ParseInt(args[1][0], 10, 64)
+	start, _ := streamMap.ParseRangeBound(args[1][1], true)
+	count, _ := strconv.Atoi(args[2][0])
+	justID := args[2][1] == "JUSTID"
+	next, claimed, deleted, err := store.stream.AutoClaim(key, group, consumer, time.Duration(minIdle)*time.Millisecond, start, count, justID)
+	if err != nil {
+		return errorReply(err.Error())
+	}
+	store.logClaimed(key, group, claimed, deleted, streamMap.MinID)
+	return arrayReply([]Reply{bulkReply(next.String()), formatClaimed(claimed, justID), formatStreamIDs(deleted)})
+}
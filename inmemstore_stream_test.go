@@ -0,0 +1,121 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Stream_Commands(t *testing.T) {
+	db := CreateTestDbSetup()
+	commands := []struct {
+		command  string
+		expected string
+	}{
+		{"XADD s 1-1 name ann", "1-1"},
+		{"XADD s 1-* name bob", "1-2"},
+		{"XADD s 1-2 name cat", "ERR The ID specified in XADD is equal or smaller than the target stream top item"},
+		{"XADD s 0-0 name cat", "ERR The ID specified in XADD must be greater than 0-0"},
+		{"XADD s 2-0 name", "COMMAND NOT VALID"},
+		{"XADD s 2-0 name cat age 3", "2-0"},
+		{"XADD missing NOMKSTREAM * name cat", "(nil)"},
+		{"XADD s MAXLEN 10 3-0 name dan", "3-0"},
+		{"XLEN s", "4"},
+		{"XLEN missing", "0"},
+		{"XRANGE s - + COUNT 2", "1) 1) '1-1'\n   2) 1) 'name'\n      2) 'ann'\n2) 1) '1-2'\n   2) 1) 'name'\n      2) 'bob'\n"},
+		{"XRANGE s (1-2 2", "1) 1) '2-0'\n   2) 1) 'name'\n      2) 'cat'\n      3) 'age'\n      4) '3'\n"},
+		{"XREVRANGE s + 2-0 COUNT 1", "1) 1) '3-0'\n   2) 1) 'name'\n      2) 'dan'\n"},
+		{"XRANGE s 5 +", "(empty list or set)"},
+		{"XRANGE s x +", "COMMAND NOT VALID"},
+		{"XDEL s 2-0 9-9", "1"},
+		{"XTRIM s MAXLEN = 2", "1"},
+		{"XTRIM s MINID 3", "1"},
+		{"XTRIM s MAXLEN x", "COMMAND NOT VALID"},
+		{"XLEN s", "1"},
+		{"XREAD STREAMS s 0", "1) 1) 's'\n   2) 1) 1) '3-0'\n         2) 1) 'name'\n            2) 'dan'\n"},
+		{"XREAD COUNT 1 STREAMS s missing 3-0 0", "(nil)"},
+		{"XREAD BLOCK 10 STREAMS s $", "(nil)"},
+		{"XREAD BLOCK 9223372036854776 STREAMS s $", "ERR timeout is out of range"},
+		{"XREAD STREAMS s", "COMMAND NOT VALID"},
+		{"XGROUP CREATE q g $", "ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically."},
+		{"XGROUP CREATE q g $ MKSTREAM", "OK"},
+		{"XGROUP CREATE q g 0", "BUSYGROUP Consumer Group name already exists"},
+		{"XADD q 1-0 job a", "1-0"},
+		{"XADD q 2-0 job b", "2-0"},
+		{"XREADGROUP GROUP g alice COUNT 1 STREAMS q >", "1) 1) 'q'\n   2) 1) 1) '1-0'\n         2) 1) 'job'\n            2) 'a'\n"},
+		{"XREADGROUP GROUP g bob STREAMS q >", "1) 1) 'q'\n   2) 1) 1) '2-0'\n         2) 1) 'job'\n            2) 'b'\n"},
+		{"XREADGROUP GROUP g bob STREAMS q >", "(nil)"},
+		{"XREADGROUP GROUP g alice STREAMS q 0", "1) 1) 'q'\n   2) 1) 1) '1-0'\n         2) 1) 'job'\n            2) 'a'\n"},
+		{"XREADGROUP GROUP other alice STREAMS q >", "NOGROUP No such key 'q' or consumer group 'other'"},
+		{"XPENDING q g", "1) 2\n2) '1-0'\n3) '2-0'\n4) 1) 1) 'alice'\n      2) '1'\n   2) 1) 'bob'\n      2) '1'\n"},
+		{"XPENDING q g IDLE 100000 - + 10", "(empty list or set)"},
+		{"XACK q g 1-0 1-0", "1"},
+		{"XCLAIM q g alice 0 2-0 JUSTID", "1) '2-0'\n"},
+		{"XDEL q 2-0", "1"},
+		{"XREADGROUP GROUP g alice STREAMS q 0", "1) 1) 'q'\n   2) 1) 1) '2-0'\n         2) (nil)\n"},
+		{"XAUTOCLAIM q g bob 0 0", "1) '0-0'\n2) (empty list or set)\n3) 1) '2-0'\n"},
+		{"XPENDING q g", "1) 0\n2) (nil)\n3) (nil)\n4) (nil)\n"},
+		{"XGROUP CREATECONSUMER q g carol", "1"},
+		{"XGROUP CREATECONSUMER q g carol", "0"},
+		{"XGROUP DELCONSUMER q g carol", "0"},
+		{"XGROUP SETID q g 0", "OK"},
+		{"XGROUP DESTROY q g", "1"},
+		{"XGROUP DESTROY q g", "0"},
+	}
+	for _, c := range commands {
+		result := db.ProcessCommand(c.command)
+		if result != c.expected {
+			t.Errorf("Ran:" + c.command + ". Expected: " + c.expected + " but Got result:" + result)
+		}
+	}
+}
+
+func TestXREADBlocksUntilXADD(t *testing.T) {
+	db := CreateTestDbSetup()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		db.ProcessCommand("XADD events 5-0 kind click")
+	}()
+	result := db.ProcessCommand("XREAD BLOCK 1000 STREAMS events $")
+	if !strings.Contains(result, "'5-0'") {
+		t.Errorf("Expected blocked XREAD to get 5-0 but got " + result)
+	}
+}
+
+func TestAOFReplaysStreamCommands(t *testing.T) {
+	AOFfilename, remove := tempAOF(t)
+	defer remove()
+	db := CreateInMemStore(1, AOFfilename)
+	db.ProcessCommand("XADD s * name ann")
+	db.ProcessCommand("XADD s * name bob")
+	db.ProcessCommand("XADD s MAXLEN 5 * name cat")
+	db.ProcessCommand("XGROUP CREATE s g 0")
+	db.ProcessCommand("XREADGROUP GROUP g alice COUNT 2 STREAMS s >")
+	db.ProcessCommand("XREADGROUP GROUP g bob NOACK STREAMS s >")
+	db.ProcessCommand("XCLAIM s g bob 0 " + strings.Split(db.ProcessCommand("XRANGE s - + COUNT 1"), "'")[1])
+	db.ProcessCommand("XGROUP CREATE s later $")
+	time.Sleep(2 * time.Second) //give extra time to persist to make sure all data is flushed
+
+	replayed := CreateInMemStore(1, AOFfilename)
+	for _, check := range []string{"XRANGE s - +", "XPENDING s g", "XPENDING s g - + 10", "XPENDING s later"} {
+		result, expected := replayed.ProcessCommand(check), db.ProcessCommand(check)
+		if check == "XPENDING s g - + 10" {
+			// idle times differ, compare IDs, consumers and delivery counts
+			result, expected = dropIdleTimes(result), dropIdleTimes(expected)
+		}
+		if result != expected {
+			t.Errorf("Ran:" + check + ". Expected:\n" + expected + "Got result:\n" + result)
+		}
+	}
+}
+
+func dropIdleTimes(pending string) string {
+	lines := strings.Split(pending, "\n")
+	kept := []string{}
+	for _, line := range lines {
+		if !strings.HasPrefix(strings.TrimSpace(line), "3)") {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
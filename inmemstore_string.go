@@ -0,0 +1,233 @@
+package main
+
+import (
+	"github.com/thedeveloperr/redis-clone/hashmap"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Runs commands on string values. handled is false if commType isn't one of them.
+func (store *InMemoryStore) processStringCommand(commType string, key string, args [][2]string, command string) (result Reply, handled bool) {
+	switch commType {
+	case "INCR", "DECR", "INCRBY", "DECRBY":
+		var delta int64 = 1
+		if len(args) == 1 {
+			delta, _ = strconv.ParseInt(args[0][0], 10, 64)
+		}
+		if commType == "DECR" || commType == "DECRBY" {
+			if delta == math.MinInt64 {
+				return errorReply(hashmap.ErrOverflow.Error()), true
+			}
+			delta = -delta
+		}
+		result, err := store.INCRBY(key, delta)
+		if err != nil {
+			return errorReply(err.Error()), true
+		}
+		store.appendToAOF(command)
+		store.notifyKeyspaceEvent(NOTIFY_STRING, "incrby", key)
+		return result, true
+	case "INCRBYFLOAT":
+		delta, _ := strconv.ParseFloat(args[0][0], 64)
+		result, err := store.INCRBYFLOAT(key, delta)
+		if err != nil {
+			return errorReply(err.Error()), true
+		}
+		// Logged as the value it set, so replaying doesn't add up floats again
+		// or depend on the key surviving until then
+		store.appendToAOF(formatCommand("SET", key, result.Text, "KEEPTTL"))
+		store.notifyKeyspaceEvent(NOTIFY_STRING, "incrbyfloat", key)
+		return result, true
+	case "APPEND":
+		result, err := store.APPEND(key, args[0][0])
+		if err != nil {
+			return errorReply(err.Error()), true
+		}
+		store.appendToAOF(command)
+		store.notifyKeyspaceEvent(NOTIFY_STRING, "append", key)
+		return result, true
+	case "STRLEN":
+		return store.STRLEN(key), true
+	case "GETRANGE":
+		start, _ := strconv.ParseInt(args[0][0], 10, 64)
+		end, _ := strconv.ParseInt(args[0][1], 10, 64)
+		return store.GETRANGE(key, start, end), true
+	case "SETRANGE":
+		offset, _ := strconv.ParseInt(args[0][0], 10, 64)
+		result, err := store.SETRANGE(key, offset, args[0][1])
+		if err != nil {
+			return errorReply(err.Error()), true
+		}
+		store.appendToAOF(command)
+		// like in redis an empty value changes nothing
+		if args[0][1] != "" {
+			store.notifyKeyspaceEvent(NOTIFY_STRING, "setrange", key)
+		}
+		return result, true
+	case "GETSET":
+		result := store.GETSET(key, args[0][0])
+		store.appendToAOF(command)
+		store.notifyKeyspaceEvent(NOTIFY_STRING, "set", key)
+		return result, true
+	case "GETDEL":
+		result := store.GETDEL(key)
+		if result.Kind != REPLY_NIL {
+			store.appendToAOF(command)
+			store.notifyKeyspaceEvent(NOTIFY_GENERIC, "del", key)
+		}
+		return result, true
+	case "MGET":
+		keys := make([]string, len(args))
+		for i := range args {
+			keys[i] = args[i][0]
+		}
+		return store.MGET(keys), true
+	case "MSET":
+		result := store.MSET(args)
+		store.appendToAOF(command)
+		for _, pair := range args {
+			store.notifyKeyspaceEvent(NOTIFY_STRING, "set", pair[0])
+		}
+		return result, true
+	case "MSETNX":
+		result := store.MSETNX(args)
+		if result.Integer == 1 {
+			store.appendToAOF(command)
+			for _, pair := range args {
+				store.notifyKeyspaceEvent(NOTIFY_STRING, "set", pair[0])
+			}
+		}
+		return result, true
+	case "GETEX":
+		if len(args) == 0 {
+			return store.GET(key), true
+		}
+		if args[0][0] == "PERSIST" {
+			result := store.GETEX(key, time.Time{}, true)
+			if result.Kind != REPLY_NIL {
+				store.appendToAOF(formatCommand("PERSIST", key))
+				store.notifyKeyspaceEvent(NOTIFY_GENERIC, "persist", key)
+			}
+			return result, true
+		}
+		amount, _ := strconv.ParseInt(args[0][1], 10, 64)
+		if amount <= 0 {
+			return errorReply("ERR invalid expire time in 'getex' command"), true
+		}
+		var deadline time.Time
+		switch args[0][0] {
+		case "EX":
+			deadline = time.Now().Add(time.Duration(amount) * time.Second)
+		case "PX":
+			deadline = time.Now().Add(time.Duration(amount) * time.Millisecond)
+		case "EXAT":
+			deadline = time.Unix(amount, 0)
+		case "PXAT":
+			deadline = fromUnixMilli(amount)
+		}
+		result := store.GETEX(key, deadline, false)
+		// Relative timeouts are logged as absolute deadline to be replay safe
+		if result.Kind != REPLY_NIL {
+			store.appendToAOF(formatCommand("PEXPIREAT", key, strconv.FormatInt(unixMilli(deadline), 10)))
+			store.notifyExpire(key, deadline)
+		}
+		return result, true
+	}
+	return Reply{}, false
+}
+
+// Adds delta to the integer at key. Perform INCR, DECR, INCRBY and DECRBY commands
+func (store *InMemoryStore) INCRBY(key string, delta int64) (Reply, error) {
+	result, err := store.hashmap.IncrBy(key, delta)
+	if err != nil {
+		return Reply{}, err
+	}
+	return integerReply(result), nil
+}
+
+// Adds delta to the float at key. Perform INCRBYFLOAT key increment command
+func (store *InMemoryStore) INCRBYFLOAT(key string, delta float64) (Reply, error) {
+	result, err := store.hashmap.IncrByFloat(key, delta)
+	if err != nil {
+		return Reply{}, err
+	}
+	return doubleReply(hashmap.FormatFloat(result)), nil
+}
+
+// Appends value to the string at key and returns new length. Perform APPEND key value command
+func (store *InMemoryStore) APPEND(key string, value string) (Reply, error) {
+	length, err := store.hashmap.Append(key, value)
+	if err != nil {
+		return Reply{}, err
+	}
+	return integerReply(int64(length)), nil
+}
+
+// Length of string at key, 0 if missing. Perform STRLEN key command
+func (store *InMemoryStore) STRLEN(key string) Reply {
+	return integerReply(int64(store.hashmap.Strlen(key)))
+}
+
+// Substring of value at key. Perform GETRANGE key start end command
+func (store *InMemoryStore) GETRANGE(key string, start int64, end int64) Reply {
+	return bulkReply(store.hashmap.GetRange(key, start, end))
+}
+
+// Overwrites part of value at key. Perform SETRANGE key offset value command
+func (store *InMemoryStore) SETRANGE(key string, offset int64, value string) (Reply, error) {
+	length, err := store.hashmap.SetRange(key, offset, value)
+	if err != nil {
+		return Reply{}, err
+	}
+	return integerReply(int64(length)), nil
+}
+
+// Sets value and returns old one or nil. Perform GETSET key value command
+func (store *InMemoryStore) GETSET(key string, value string) Reply {
+	if old, exists := store.hashmap.GetSet(key, value); exists {
+		return bulkReply(old)
+	}
+	return nilReply
+}
+
+// Deletes key and returns its value or nil. Perform GETDEL key command
+func (store *InMemoryStore) GETDEL(key string) Reply {
+	if value, exists := store.hashmap.GetDel(key); exists {
+		return bulkReply(value)
+	}
+	return nilReply
+}
+
+// Returns value and changes the timeout of key. Perform GETEX key [EX|PX|EXAT|PXAT time|PERSIST] command
+func (store *InMemoryStore) GETEX(key string, deadline time.Time, persist bool) Reply {
+	if value, exists := store.hashmap.GetEx(key, deadline, persist); exists {
+		return bulkReply(value)
+	}
+	return nilReply
+}
+
+// Values of many keys in one lock acquisition. Perform MGET key [key ...] command
+func (store *InMemoryStore) MGET(keys []string) Reply {
+	values, exists := store.hashmap.MGet(keys)
+	items := make([]Reply, len(values))
+	for i := range values {
+		if exists[i] {
+			items[i] = bulkReply(values[i])
+		} else {
+			items[i] = nilReply
+		}
+	}
+	return arrayReply(items)
+}
+
+// Sets all key value pairs atomically. Perform MSET key value [key value ...] command
+func (store *InMemoryStore) MSET(pairs [][2]string) Reply {
+	store.hashmap.MSet(pairs)
+	return okReply
+}
+
+// Sets all pairs only if no key exists, returns 1 if set. Perform MSETNX key value [key value ...] command
+func (store *InMemoryStore) MSETNX(pairs [][2]string) Reply {
+	return booleanReply(store.hashmap.MSetNX(pairs))
+}
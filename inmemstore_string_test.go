@@ -0,0 +1,232 @@
+package main
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_INCR_DECR_Commands(t *testing.T) {
+	db := CreateTestDbSetup()
+	commands := []struct {
+		command  string
+		expected string
+	}{
+		{"INCR counter", "1"},
+		{"INCRBY counter 10", "11"},
+		{"DECR counter", "10"},
+		{"DECRBY counter 3", "7"},
+		{"GET counter", "7"},
+		{"INCRBY counter 1.5", "COMMAND NOT VALID"},
+		{"INCRBYFLOAT counter 0.5", "7.5"},
+		{"INCR counter", "ERR value is not an integer or out of range"},
+		{"SET text abc", "OK"},
+		{"INCRBYFLOAT text 1", "ERR value is not a valid float"},
+		{"SET big 9223372036854775807", "OK"},
+		{"INCR big", "ERR increment or decrement would overflow"},
+		{"DECRBY big -9223372036854775808", "ERR increment or decrement would overflow"},
+	}
+	for _, c := range commands {
+		result := db.ProcessCommand(c.command)
+		if result != c.expected {
+			t.Errorf("Ran:" + c.command + ". Expected: " + c.expected + " but Got result:" + result)
+		}
+	}
+}
+
+func Test_APPEND_STRLEN_GETRANGE_SETRANGE_Commands(t *testing.T) {
+	db := CreateTestDbSetup()
+	commands := []struct {
+		command  string
+		expected string
+	}{
+		{"APPEND greeting Hello", "5"},
+		{"APPEND greeting World", "10"},
+		{"STRLEN greeting", "10"},
+		{"STRLEN missing", "0"},
+		{"GETRANGE greeting 0 4", "Hello"},
+		{"GETRANGE greeting -5 -1", "World"},
+		{"GETRANGE greeting 20 30", ""},
+		{"SETRANGE greeting 5 Redis", "10"},
+		{"GET greeting", "HelloRedis"},
+		{"SETRANGE greeting -1 x", "COMMAND NOT VALID"},
+	}
+	for _, c := range commands {
+		result := db.ProcessCommand(c.command)
+		if result != c.expected {
+			t.Errorf("Ran:" + c.command + ". Expected: " + c.expected + " but Got result:" + result)
+		}
+	}
+}
+
+func Test_GETSET_GETDEL_GETEX_Commands(t *testing.T) {
+	db := CreateTestDbSetup()
+	commands := []struct {
+		command  string
+		expected string
+	}{
+		{"GETSET k1 v1", "(nil)"},
+		{"GETSET k1 v2", "v1"},
+		{"GETEX k1", "v2"},
+		{"GETEX k1 EX 0", "ERR invalid expire time in 'getex' command"},
+		{"GETEX k1 EX abc", "COMMAND NOT VALID"},
+		{"GETEX k1 PX 100", "v2"},
+		{"GETEX k1 PERSIST", "v2"},
+		{"PERSIST k1", "0"},
+		{"GETDEL k1", "v2"},
+		{"GETDEL k1", "(nil)"},
+		{"GETEX k1", "(nil)"},
+	}
+	for _, c := range commands {
+		result := db.ProcessCommand(c.command)
+		if result != c.expected {
+			t.Errorf("Ran:" + c.command + ". Expected: " + c.expected + " but Got result:" + result)
+		}
+	}
+
+	db.ProcessCommand("SET k2 v2")
+	db.ProcessCommand("GETEX k2 PX 50")
+	time.Sleep(100 * time.Millisecond)
+	if result := db.ProcessCommand("GET k2"); result != "(nil)" {
+		t.Errorf("Expected k2 to expire after GETEX PX 50 but got " + result)
+	}
+}
+
+func Test_PEXPIREAT_Command(t *testing.T) {
+	db := CreateTestDbSetup()
+	db.ProcessCommand("SET k1 v1")
+	db.ProcessCommand("ZADD z1 1 m1")
+	past := strconv.FormatInt(unixMilli(time.Now())-1000, 10)
+	if result := db.ProcessCommand("PEXPIREAT k1 " + past); result != "1" {
+		t.Errorf("Expected 1 but got " + result)
+	}
+	if result := db.ProcessCommand("GET k1"); result != "(nil)" {
+		t.Errorf("Expected k1 to be expired but got " + result)
+	}
+	if result := db.ProcessCommand("PEXPIREAT z1 " + past); result != "1" {
+		t.Errorf("Expected 1 but got " + result)
+	}
+	if result := db.ProcessCommand("ZRANK z1 m1"); result != "(nil)" {
+		t.Errorf("Expected z1 to be expired but got " + result)
+	}
+	if result := db.ProcessCommand("PEXPIREAT missing " + past); result != "0" {
+		t.Errorf("Expected 0 but got " + result)
+	}
+}
+
+func TestAOFReplaysStringCommands(t *testing.T) {
+	AOFfilename, remove := tempAOF(t)
+	defer remove()
+	db := CreateInMemStore(1, AOFfilename)
+	db.ProcessCommand("INCRBY counter 5")
+	db.ProcessCommand("INCRBYFLOAT counter 0.25")
+	db.ProcessCommand("APPEND greeting Hello")
+	db.ProcessCommand("SETRANGE greeting 5 World")
+	db.ProcessCommand("SET temp value")
+	db.ProcessCommand("GETEX temp EX 1")
+	db.ProcessCommand("SET session abc")
+	db.ProcessCommand("EXPIRE session 100")
+	time.Sleep(2 * time.Second) //give extra time to persist and for temp to expire
+
+	replayed := CreateInMemStore(1, AOFfilename)
+	expected := map[string]string{
+		"GET counter":  "5.25",
+		"GET greeting": "HelloWorld",
+		"GET temp":     "(nil)",
+		"GET session":  "abc",
+	}
+	for command, value := range expected {
+		if result := replayed.ProcessCommand(command); result != value {
+			t.Errorf("After replay ran:" + command + ". Expected: " + value + " but Got result:" + result)
+		}
+	}
+}
+
+// Nothing expires while the AOF loads, so writes logged after a deadline
+// which passed since change the key they changed then, and the key is only
+// removed once loading finishes
+func TestAOFReplayKeepsExpiredKeysUntilLoaded(t *testing.T) {
+	AOFfilename, remove := tempAOF(t)
+	defer remove()
+	past := strconv.FormatInt(unixMilli(time.Now())-1000, 10)
+	future := strconv.FormatInt(unixMilli(time.Now())+100000, 10)
+	lines := []string{
+		"SET k 10",
+		"PEXPIREAT k " + past,
+		"INCR k",
+		"SET j 10",
+		"PEXPIREAT j " + future,
+		"INCR j",
+		"SET f 1.5",
+		"PEXPIREAT f " + future,
+		"SET f 1.75 KEEPTTL",
+	}
+	if err := ioutil.WriteFile(AOFfilename, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	replayed := CreateInMemStore(1, AOFfilename)
+	expected := map[string]string{
+		"GET k": "(nil)",
+		"GET j": "11",
+		"GET f": "1.75",
+	}
+	for command, value := range expected {
+		if result := replayed.ProcessCommand(command); result != value {
+			t.Errorf("After replay ran:" + command + ". Expected: " + value + " but Got result:" + result)
+		}
+	}
+	if replayed.keyspace.ExpiredCount() != 1 {
+		t.Errorf("Expected k to be expired once loaded")
+	}
+	for _, key := range []string{"j", "f"} {
+		s := replayed.keyspace.Shard(key)
+		s.RLock()
+		entry, _ := s.Get(key)
+		if _, ok := entry.Deadline(); !ok {
+			t.Errorf("Expected " + key + " to keep its deadline")
+		}
+		s.RUnlock()
+	}
+}
+
+// INCRBYFLOAT is logged as the value it set, keeping the TTL of the key
+func TestAOFLogsINCRBYFLOATAsSET(t *testing.T) {
+	AOFfilename, remove := tempAOF(t)
+	defer remove()
+	db := CreateInMemStore(1, AOFfilename)
+	db.ProcessCommand("SET counter 0.1")
+	db.ProcessCommand("INCRBYFLOAT counter 0.2")
+	time.Sleep(1500 * time.Millisecond) //give extra time to persist
+	content, err := ioutil.ReadFile(AOFfilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "SET counter 0.1\nSET counter 0.30000000000000004 KEEPTTL\n"
+	if string(content) != expected {
+		t.Errorf("Expected: " + expected + " but Got:" + string(content))
+	}
+}
+
+func Test_MGET_MSET_MSETNX_Commands(t *testing.T) {
+	db := CreateTestDbSetup()
+	commands := []struct {
+		command  string
+		expected string
+	}{
+		{"MSET k1 v1 k2 v2", "OK"},
+		{"MSET k1 v1 k2", "COMMAND NOT VALID"},
+		{"MGET k1 missing k2", "1) 'v1'\n2) (nil)\n3) 'v2'\n"},
+		{"MSETNX k2 x k3 v3", "0"},
+		{"GET k3", "(nil)"},
+		{"MSETNX k3 v3 k4 v4", "1"},
+		{"MGET k3 k4", "1) 'v3'\n2) 'v4'\n"},
+	}
+	for _, c := range commands {
+		result := db.ProcessCommand(c.command)
+		if result != c.expected {
+			t.Errorf("Ran:" + c.command + ". Expected: " + c.expected + " but Got result:" + result)
+		}
+	}
+}
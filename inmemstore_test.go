@@ -2,8 +2,10 @@ package main
 
 import (
 	"bufio"
+	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -13,9 +15,18 @@ func CreateTestDbSetup() *InMemoryStore {
 	return CreateInMemStore(1, AOFfilename)
 }
 
+// Path of an AOF in a new temporary directory, which remove deletes
+func tempAOF(t *testing.T) (filename string, remove func()) {
+	directory, err := ioutil.TempDir("", "aof")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(directory, "appendonly.aof"), func() { os.RemoveAll(directory) }
+}
+
 func TestAOFWrite(t *testing.T) {
-	AOFfilename := "AOF_test.log"
-	os.Remove(AOFfilename) // Remove if already exists
+	AOFfilename, remove := tempAOF(t)
+	defer remove()
 	db := CreateInMemStore(1, AOFfilename)
 	db.ProcessCommand("SET k1 v1")
 	db.ProcessCommand("GET k1")
@@ -63,6 +74,28 @@ func TestAOFWrite(t *testing.T) {
 	}
 }
 
+// A write waits for the writes locking its keys, so its AOF entry can't be
+// queued before theirs
+func TestWritesWaitForLockedKeys(t *testing.T) {
+	db := CreateTestDbSetup()
+	unlock := db.lockKeys("SMOVE", "SMOVE source destination member")
+	done := make(chan string)
+	go func() { done <- db.ProcessCommand("SADD destination other") }()
+	go func() { done <- db.ProcessCommand("GET destination") }()
+	if result := <-done; result != "(nil)" {
+		t.Errorf("Expected the read not to wait but got " + result)
+	}
+	select {
+	case result := <-done:
+		t.Errorf("Expected SADD to wait for the lock but got " + result)
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	if result := <-done; result != "1" {
+		t.Errorf("Expected: 1 Got result:" + result)
+	}
+}
+
 func TestAOFRead(t *testing.T) {
 	AOFfilename := "AOF_test_read.log"
 	db := CreateInMemStore(1, AOFfilename)
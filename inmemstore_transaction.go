@@ -0,0 +1,133 @@
+package main
+
+import (
+	"strings"
+)
+
+// Runs the transaction commands reaching ProcessCommand, which keeps no state
+// between commands: UNWATCH has nothing to forget and the others need a
+// connection, so they are only possible over RESP. handled is false if
+// commType isn't one of them.
+func (store *InMemoryStore) processTransactionCommand(commType string, key string, args [][2]string, command string) (result Reply, handled bool) {
+	switch commType {
+	case "UNWATCH":
+		return okReply, true
+	case "MULTI", "EXEC", "DISCARD", "WATCH":
+		return errorReply("ERR " + commType + " is only supported over a RESP connection"), true
+	}
+	return Reply{}, false
+}
+
+// Runs a command of the client owning transaction. MULTI queues the commands
+// which follow until EXEC runs them or DISCARD drops them, and WATCH makes
+// EXEC abort if one of the given keys is modified before. Other commands run
+// right away outside of MULTI. Returns the text of the reply like
+// ProcessCommand.
+func (store *InMemoryStore) ProcessTransactionCommand(transaction *Transaction, command string) string {
+	return store.ProcessTransactionCommandReply(transaction, command).String()
+}
+
+// Runs a command like ProcessTransactionCommand and returns its reply
+func (store *InMemoryStore) ProcessTransactionCommandReply(transaction *Transaction, command string) Reply {
+	commType, key, args, command := parseCommandLine(command)
+	switch {
+	case commType == "MULTI" || commType == "EXEC" || commType == "DISCARD" || commType == "WATCH",
+		// queued inside MULTI like redis does, where it has no effect
+		commType == "UNWATCH" && !transaction.started:
+		return store.countCommand(commType, func() Reply {
+			return store.runTransactionCommand(transaction, commType, key, args)
+		})
+	}
+	if !transaction.started {
+		return store.processParsedCommand(commType, key, args, command)
+	}
+	if commType == "" {
+		transaction.failed = true
+		return errorReply("COMMAND NOT VALID")
+	}
+	transaction.queued = append(transaction.queued, command)
+	return statusReply("QUEUED")
+}
+
+// Runs MULTI, EXEC, DISCARD, WATCH or UNWATCH for the client owning transaction
+func (store *InMemoryStore) runTransactionCommand(transaction *Transaction, commType string, key string, args [][2]string) Reply {
+	switch commType {
+	case "MULTI":
+		if transaction.started {
+			return errorReply("ERR MULTI calls can not be nested")
+		}
+		transaction.started = true
+		return okReply
+	case "EXEC":
+		if !transaction.started {
+			return errorReply("ERR EXEC without MULTI")
+		}
+		return store.EXEC(transaction)
+	case "DISCARD":
+		if !transaction.started {
+			return errorReply("ERR DISCARD without MULTI")
+		}
+		store.DISCARD(transaction)
+		return okReply
+	case "WATCH":
+		if transaction.started {
+			return errorReply("ERR WATCH inside MULTI is not allowed")
+		}
+		store.watches.watch(transaction, append([]string{key}, firstOfPairs(args)...))
+		return okReply
+	}
+	store.watches.unwatch(transaction)
+	return okReply
+}
+
+// Runs the queued commands with no command of another client in between and
+// lists their replies, or returns nil if a watched key was modified. What
+// they log is written to the AOF as one unit. Perform EXEC command
+func (store *InMemoryStore) EXEC(transaction *Transaction) Reply {
+	queued, failed := transaction.queued, transaction.failed
+	transaction.started, transaction.queued, transaction.failed = false, nil, false
+	if failed {
+		store.watches.unwatch(transaction)
+		return errorReply("EXECABORT Transaction discarded because of previous errors.")
+	}
+	store.commandLock.Lock()
+	defer store.commandLock.Unlock()
+	if store.watches.unwatch(transaction) {
+		return nilReply
+	}
+	return store.logAtomically(func() Reply {
+		replies := make([]Reply, len(queued))
+		for i, command := range queued {
+			// blocking commands run once, as if their timeout was reached like in redis
+			commType, key, args := Command{fullText: command}.parse()
+			replies[i] = store.countCommand(commType, func() Reply {
+				return store.runCommand(commType, key, args, command)
+			})
+		}
+		return arrayReply(replies)
+	})
+}
+
+// Calls run, writing what the commands it runs log to the AOF as one
+// MULTI ... EXEC unit so replay applies all of it or nothing. The caller
+// holds the command lock exclusively. A script run by EXEC is logged with
+// the rest of the transaction.
+func (store *InMemoryStore) logAtomically(run func() Reply) Reply {
+	if store.inTransaction {
+		return run()
+	}
+	store.inTransaction = true
+	result := run()
+	logged := store.transactionLog
+	store.inTransaction, store.transactionLog = false, nil
+	if len(logged) > 0 && store.dataPersistor != nil {
+		store.dataPersistor.queue <- "MULTI\n" + strings.Join(logged, "\n") + "\nEXEC"
+	}
+	return result
+}
+
+// Drops the queued commands and forgets the watched keys. Perform DISCARD command
+func (store *InMemoryStore) DISCARD(transaction *Transaction) {
+	transaction.started, transaction.queued, transaction.failed = false, nil, false
+	store.watches.unwatch(transaction)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func Test_Transaction_Commands(t *testing.T) {
+	db := CreateTestDbSetup()
+	transaction := CreateTransaction()
+	commands := []struct {
+		command  string
+		expected string
+	}{
+		{"EXEC", "ERR EXEC without MULTI"},
+		{"DISCARD", "ERR DISCARD without MULTI"},
+		{"MULTI", "OK"},
+		{"MULTI", "ERR MULTI calls can not be nested"},
+		{"WATCH k", "ERR WATCH inside MULTI is not allowed"},
+		{"SET k v", "QUEUED"},
+		{"INCR k", "QUEUED"},
+		{"ZADD z 1 m", "QUEUED"},
+		{"BLPOP empty 0", "QUEUED"},
+		{"UNWATCH", "QUEUED"},
+		{"GET k", "QUEUED"},
+		{"EXEC", "1) OK\n2) ERR value is not an integer or out of range\n3) 1\n4) (nil)\n5) OK\n6) 'v'\n"},
+		{"EXEC", "ERR EXEC without MULTI"},
+		{"MULTI", "OK"},
+		{"EXEC", "(empty list or set)"},
+		{"MULTI", "OK"},
+		{"SET k discarded", "QUEUED"},
+		{"DISCARD", "OK"},
+		{"GET k", "v"},
+		{"MULTI", "OK"},
+		{"SET k aborted", "QUEUED"},
+		{"NOPE", "COMMAND NOT VALID"},
+		{"EXEC", "EXECABORT Transaction discarded because of previous errors."},
+		{"GET k", "v"},
+	}
+	for _, c := range commands {
+		result := db.ProcessTransactionCommand(transaction, c.command)
+		if result != c.expected {
+			t.Errorf("Ran:" + c.command + ". Expected: " + c.expected + " but Got result:" + result)
+		}
+	}
+	if result := db.ProcessCommand("MULTI"); result != "ERR MULTI is only supported over a RESP connection" {
+		t.Errorf("Expected MULTI to need a connection but got " + result)
+	}
+}
+
+func Test_WATCH_Aborts_EXEC(t *testing.T) {
+	db := CreateTestDbSetup()
+	transaction := CreateTransaction()
+	db.ProcessTransactionCommand(transaction, "WATCH a b")
+	db.ProcessCommand("MSET b changed c other")
+	db.ProcessTransactionCommand(transaction, "MULTI")
+	db.ProcessTransactionCommand(transaction, "SET a mine")
+	if result := db.ProcessTransactionCommand(transaction, "EXEC"); result != "(nil)" {
+		t.Errorf("Expected EXEC to abort but got " + result)
+	}
+	if result := db.ProcessCommand("GET a"); result != "(nil)" {
+		t.Errorf("Expected a not to be set but got " + result)
+	}
+
+	// keys of other commands, unwatched keys and UNWATCH don't abort
+	db.ProcessTransactionCommand(transaction, "WATCH a")
+	db.ProcessCommand("SET c again")
+	db.ProcessTransactionCommand(transaction, "WATCH b")
+	db.ProcessTransactionCommand(transaction, "UNWATCH")
+	db.ProcessCommand("SET b again")
+	db.ProcessTransactionCommand(transaction, "MULTI")
+	db.ProcessTransactionCommand(transaction, "SET a mine")
+	if result := db.ProcessTransactionCommand(transaction, "EXEC"); result != "1) OK\n" {
+		t.Errorf("Expected EXEC to run but got " + result)
+	}
+
+	// keys removed when their ttl passes abort too
+	db.ProcessCommand("SADD expiring m")
+	db.ProcessCommand("PEXPIREAT expiring " + strconv.FormatInt(unixMilli(time.Now().Add(50*time.Millisecond)), 10))
+	db.ProcessTransactionCommand(transaction, "WATCH expiring")
+	time.Sleep(100 * time.Millisecond)
+	db.ProcessTransactionCommand(transaction, "MULTI")
+	db.ProcessTransactionCommand(transaction, "SADD expiring n")
+	if result := db.ProcessTransactionCommand(transaction, "EXEC"); result != "(nil)" {
+		t.Errorf("Expected EXEC to abort after the watched key expired but got " + result)
+	}
+}
+
+func Test_EXEC_Runs_Without_Other_Commands_In_Between(t *testing.T) {
+	db := CreateTestDbSetup()
+	popped := make(chan string)
+	go func() {
+		popped <- db.ProcessCommand("BRPOP jobs 2")
+	}()
+	time.Sleep(50 * time.Millisecond)
+	transaction := CreateTransaction()
+	db.ProcessTransactionCommand(transaction, "MULTI")
+	db.ProcessTransactionCommand(transaction, "RPUSH jobs a b")
+	db.ProcessTransactionCommand(transaction, "RPOP jobs")
+	if result := db.ProcessTransactionCommand(transaction, "EXEC"); result != "1) 2\n2) 'b'\n" {
+		t.Errorf("Expected the transaction to pop b but got " + result)
+	}
+	// the blocked client only sees the list once the whole transaction ran
+	if result := <-popped; result != "1) 'jobs'\n2) 'a'\n" {
+		t.Errorf("Expected BRPOP to receive a but got " + result)
+	}
+}
+
+func TestAOFReplaysTransactions(t *testing.T) {
+	AOFfilename, remove := tempAOF(t)
+	defer remove()
+	db := CreateInMemStore(1, AOFfilename)
+	transaction := CreateTransaction()
+	db.ProcessTransactionCommand(transaction, "MULTI")
+	db.ProcessTransactionCommand(transaction, "SET k v")
+	db.ProcessTransactionCommand(transaction, "GET k")
+	db.ProcessTransactionCommand(transaction, "ZADD z 1 m")
+	db.ProcessTransactionCommand(transaction, "EXEC")
+	db.ProcessCommand("SET after v")
+	time.Sleep(2 * time.Second) //give extra time to persist to make sure all data is flushed
+
+	content, _ := ioutil.ReadFile(AOFfilename)
+	expected := "MULTI\nSET k v\nZADD z 1 m\nEXEC\nSET after v\n"
+	if string(content) != expected {
+		t.Errorf("Expected AOF:\n" + expected + "Got:\n" + string(content))
+	}
+	// a transaction cut short by a crash isn't replayed
+	file, _ := os.OpenFile(AOFfilename, os.O_APPEND|os.O_WRONLY, 0644)
+	file.WriteString("MULTI\nSET k lost\n")
+	file.Close()
+
+	replayed := CreateInMemStore(1, AOFfilename)
+	for command, expected := range map[string]string{
+		"GET k":     "v",
+		"ZRANK z m": "0",
+		"GET after": "v",
+	} {
+		if result := replayed.ProcessCommand(command); result != expected {
+			t.Errorf("Ran:" + command + ". Expected: " + expected + " but Got result:" + result)
+		}
+	}
+}
@@ -0,0 +1,431 @@
+// Package keyspace holds the keys of every data type in one table, so a name
+// holds a single value whatever its type, along with the deadlines of keys
+// and the timers removing them.
+package keyspace
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Number of independently locked buckets keys are spread across.
+// Writes only block readers and writers of keys hashed to the same bucket.
+const SHARD_COUNT = 32
+
+// FNV-1a hash of text, inlined to avoid allocating a hash.Hash32 per call
+func Hash(text string) uint32 {
+	var hash uint32 = 2166136261
+	for i := 0; i < len(text); i++ {
+		hash ^= uint32(text[i])
+		hash *= 16777619
+	}
+	return hash
+}
+
+// Bucket of key among the SHARD_COUNT ones
+func ShardIndex(key string) int {
+	return int(Hash(key) % SHARD_COUNT)
+}
+
+// Values holding elements, like lists, which don't exist anymore once they
+// hold none
+type Collection interface {
+	IsEmpty() bool
+}
+
+// Value stored under a key and its deadline
+type Entry struct {
+	Value        interface{}
+	expireAt     time.Time
+	shouldExpire bool
+}
+
+func (e *Entry) isExpired(now time.Time) bool {
+	return e.shouldExpire && !now.Before(e.expireAt)
+}
+
+func (e *Entry) exists(now time.Time) bool {
+	if e.isExpired(now) {
+		return false
+	}
+	collection, isCollection := e.Value.(Collection)
+	return !isCollection || !collection.IsEmpty()
+}
+
+// Deadline of the key, ok is false if it has none
+func (e *Entry) Deadline() (deadline time.Time, ok bool) {
+	return e.expireAt, e.shouldExpire
+}
+
+type Shard struct {
+	mutex    sync.RWMutex
+	entries  map[string]*Entry
+	order    ScanOrder // keys in the order Scan visits them
+	keyspace *Keyspace
+	// keys found expired while the shard is locked for writing, reported
+	// once it is unlocked
+	expired []string
+}
+
+func (s *Shard) Lock() {
+	s.mutex.Lock()
+}
+
+// Unlocks the shard, then reports the keys removed once their TTL passed
+func (s *Shard) Unlock() {
+	s.keyspace.reportExpired(s.unlock())
+}
+
+// Unlocks the shard and returns the keys to report as expired
+func (s *Shard) unlock() []string {
+	expired := s.expired
+	s.expired = nil
+	s.mutex.Unlock()
+	return expired
+}
+
+func (s *Shard) RLock() {
+	s.mutex.RLock()
+}
+
+func (s *Shard) RUnlock() {
+	s.mutex.RUnlock()
+}
+
+// Entry of key if it exists. An expired key is seen as missing even before
+// its timer removes it, and so is an empty collection.
+// Caller must hold the lock of the shard.
+func (s *Shard) Get(key string) (*Entry, bool) {
+	entry, exists := s.entries[key]
+	if !exists || !entry.exists(s.keyspace.Now()) {
+		return nil, false
+	}
+	return entry, true
+}
+
+// Entry of key like Get, but also when it holds an empty collection, like a
+// hash whose last field expired before the timer removing the hash ran.
+// Caller must hold the lock of the shard.
+func (s *Shard) GetIncludingEmpty(key string) (*Entry, bool) {
+	entry, exists := s.entries[key]
+	if !exists || entry.isExpired(s.keyspace.Now()) {
+		return nil, false
+	}
+	return entry, true
+}
+
+// Stores value under key without a deadline, replacing whatever it held. A
+// key whose TTL passed before its timer ran is reported as expired first.
+// Caller must hold the write lock of the shard.
+func (s *Shard) Set(key string, value interface{}) *Entry {
+	s.removeIfExpired(key)
+	if _, exists := s.entries[key]; !exists {
+		s.order.Add(key)
+	}
+	entry := &Entry{Value: value}
+	s.entries[key] = entry
+	return entry
+}
+
+// Removes key, reporting it as expired if its TTL passed before its timer ran.
+// Caller must hold the write lock of the shard.
+func (s *Shard) Delete(key string) {
+	s.removeIfExpired(key)
+	s.remove(key)
+}
+
+// Caller must hold the write lock of the shard
+func (s *Shard) remove(key string) {
+	if _, exists := s.entries[key]; exists {
+		delete(s.entries, key)
+		s.order.Remove(key)
+	}
+}
+
+// Removes key if its TTL passed and reports it as expired.
+// Caller must hold the write lock of the shard.
+func (s *Shard) removeIfExpired(key string) {
+	if entry, exists := s.entries[key]; exists && entry.isExpired(s.keyspace.Now()) {
+		s.remove(key)
+		atomic.AddUint64(&s.keyspace.expired, 1)
+		s.expired = append(s.expired, key)
+	}
+}
+
+// Expires key at an absolute deadline. A deadline in the past removes the
+// key right away, unless the AOF is loading: the deadline is then only
+// stored, and the key removed once loading finishes. Returns false if the
+// key doesn't exist.
+// Caller must hold the write lock of the shard.
+func (s *Shard) ExpireAt(key string, deadline time.Time) bool {
+	entry, exists := s.Get(key)
+	if !exists {
+		return false
+	}
+	entry.shouldExpire = true
+	entry.expireAt = deadline
+	if s.keyspace.IsLoading() {
+		return true
+	}
+	s.scheduleExpiry(key, deadline)
+	return true
+}
+
+// Removes key once deadline passes, right away if it already did.
+// Caller must hold the write lock of the shard.
+func (s *Shard) scheduleExpiry(key string, deadline time.Time) {
+	timeout := time.Until(deadline)
+	if timeout <= 0 {
+		s.remove(key)
+		return
+	}
+	time.AfterFunc(timeout, func() {
+		s.Lock()
+		// nothing to do if PERSIST or a later EXPIRE changed the deadline,
+		// or the key was replaced
+		s.removeIfExpired(key)
+		s.Unlock()
+	})
+}
+
+// Removes the deadline of key. Returns false if it had none.
+// Caller must hold the write lock of the shard.
+func (s *Shard) Persist(key string) bool {
+	entry, exists := s.Get(key)
+	if !exists || !entry.shouldExpire {
+		return false
+	}
+	entry.shouldExpire = false
+	return true
+}
+
+// Keys of every data type
+type Keyspace struct {
+	// keys removed once their TTL passed, first for the alignment atomic needs
+	expired uint64
+	// 1 while the AOF loads, when nothing expires
+	loading   int32
+	shards    [SHARD_COUNT]*Shard
+	onExpired func(key string)
+}
+
+func New() *Keyspace {
+	keyspace := &Keyspace{}
+	for i := 0; i < SHARD_COUNT; i++ {
+		keyspace.shards[i] = &Shard{
+			entries:  make(map[string]*Entry),
+			keyspace: keyspace,
+		}
+	}
+	return keyspace
+}
+
+// Sets a function called with each key removed once its TTL passed, by its
+// timer or by a write replacing it first, without any lock held. It must be
+// set before keys expire.
+func (k *Keyspace) OnExpired(callback func(key string)) {
+	k.onExpired = callback
+}
+
+func (k *Keyspace) reportExpired(keys []string) {
+	if k.onExpired != nil {
+		for _, key := range keys {
+			k.onExpired(key)
+		}
+	}
+}
+
+// Time deadlines are compared with. While the AOF loads it's the zero time,
+// so keys and hash fields whose deadline passed since they were logged
+// still exist for the writes logged after it, like in redis.
+func (k *Keyspace) Now() time.Time {
+	if k.IsLoading() {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// Whether the AOF is loading, see StartLoading
+func (k *Keyspace) IsLoading() bool {
+	return atomic.LoadInt32(&k.loading) == 1
+}
+
+// Stops keys from expiring until FinishLoading is called
+func (k *Keyspace) StartLoading() {
+	atomic.StoreInt32(&k.loading, 1)
+}
+
+// Lets keys expire again, removing those whose deadline passed while the
+// AOF loaded or before, which are reported as expired, and starting the
+// timers of the others
+func (k *Keyspace) FinishLoading() {
+	atomic.StoreInt32(&k.loading, 0)
+	now := time.Now()
+	for _, s := range k.shards {
+		s.Lock()
+		for key, entry := range s.entries {
+			if !entry.shouldExpire {
+				continue
+			}
+			if entry.isExpired(now) {
+				s.removeIfExpired(key)
+			} else {
+				s.scheduleExpiry(key, entry.expireAt)
+			}
+		}
+		s.Unlock()
+	}
+}
+
+func (k *Keyspace) Shard(key string) *Shard {
+	return k.shards[ShardIndex(key)]
+}
+
+// Locks the shards of all keys once each, in shard order so concurrent
+// multi key calls can't deadlock. Returns function to unlock them.
+func (k *Keyspace) LockShards(keys []string, write bool) func() {
+	var needed [SHARD_COUNT]bool
+	for _, key := range keys {
+		needed[ShardIndex(key)] = true
+	}
+	var locked []*Shard
+	for i := 0; i < SHARD_COUNT; i++ {
+		if !needed[i] {
+			continue
+		}
+		if write {
+			k.shards[i].Lock()
+		} else {
+			k.shards[i].RLock()
+		}
+		locked = append(locked, k.shards[i])
+	}
+	return func() {
+		var expired []string
+		for _, s := range locked {
+			if write {
+				expired = append(expired, s.unlock()...)
+			} else {
+				s.RUnlock()
+			}
+		}
+		k.reportExpired(expired)
+	}
+}
+
+// Value of key, whatever its type
+func (k *Keyspace) Get(key string) (interface{}, bool) {
+	s := k.Shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	entry, exists := s.Get(key)
+	if !exists {
+		return nil, false
+	}
+	return entry.Value, true
+}
+
+// Removes key whatever its type. Returns false if it didn't exist.
+func (k *Keyspace) Delete(key string) bool {
+	s := k.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	if _, exists := s.Get(key); !exists {
+		return false
+	}
+	s.Delete(key)
+	return true
+}
+
+// Expires key at an absolute deadline whatever its type. A deadline in the
+// past removes the key right away, unless the AOF is loading. Returns false if the key doesn't exist.
+func (k *Keyspace) ExpireAt(key string, deadline time.Time) bool {
+	s := k.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	return s.ExpireAt(key, deadline)
+}
+
+// Removes the deadline of key whatever its type. Returns false if it had none.
+func (k *Keyspace) Persist(key string) bool {
+	s := k.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	return s.Persist(key)
+}
+
+// Keys which exist and whose value is accepted by match, in no particular
+// order. A nil match accepts every value.
+func (k *Keyspace) Keys(match func(value interface{}) bool) []string {
+	var keys []string
+	now := k.Now()
+	for _, s := range k.shards {
+		s.RLock()
+		for key, entry := range s.entries {
+			if entry.exists(now) && (match == nil || match(entry.Value)) {
+				keys = append(keys, key)
+			}
+		}
+		s.RUnlock()
+	}
+	return keys
+}
+
+// Calls visit with about count keys which exist, starting at cursor, and
+// returns the cursor to continue from, 0 once every shard was scanned. The
+// cursor holds the shard index in its low bits and the position within the
+// shard above them, so a call only visits the keys it returns, and keys
+// present for the whole iteration are returned, see ScanOrder.
+func (k *Keyspace) Scan(cursor uint64, count int, visit func(key string, value interface{})) (nextCursor uint64) {
+	index, position := cursor%SHARD_COUNT, cursor/SHARD_COUNT
+	visited := 0
+	now := k.Now()
+	for ; index < SHARD_COUNT; index, position = index+1, 0 {
+		s := k.shards[index]
+		s.RLock()
+		position = s.order.Scan(position, count-visited, func(key string) {
+			if entry := s.entries[key]; entry.exists(now) {
+				visit(key, entry.Value)
+			}
+			visited++
+		})
+		s.RUnlock()
+		if position != 0 {
+			return position*SHARD_COUNT + index
+		}
+		if visited >= count {
+			if index+1 == SHARD_COUNT {
+				return 0
+			}
+			return index + 1
+		}
+	}
+	return 0
+}
+
+// Number of keys which exist and whose value is accepted by match, how many
+// of them have a deadline and the sum of the time those have left. A nil
+// match accepts every value.
+func (k *Keyspace) KeyCounts(match func(value interface{}) bool) (keys int, volatile int, ttl time.Duration) {
+	now := time.Now()
+	for _, s := range k.shards {
+		s.RLock()
+		for _, entry := range s.entries {
+			if entry.exists(now) && (match == nil || match(entry.Value)) {
+				keys++
+				if entry.shouldExpire {
+					volatile++
+					ttl += entry.expireAt.Sub(now)
+				}
+			}
+		}
+		s.RUnlock()
+	}
+	return keys, volatile, ttl
+}
+
+// Number of keys removed once their TTL passed
+func (k *Keyspace) ExpiredCount() uint64 {
+	return atomic.LoadUint64(&k.expired)
+}
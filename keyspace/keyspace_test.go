@@ -0,0 +1,146 @@
+package keyspace
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Sets key to value in keyspace like a store does
+func set(keyspace *Keyspace, key string, value interface{}) {
+	s := keyspace.Shard(key)
+	s.Lock()
+	s.Set(key, value)
+	s.Unlock()
+}
+
+type list []string
+
+func (l list) IsEmpty() bool {
+	return len(l) == 0
+}
+
+func TestOneValuePerKey(t *testing.T) {
+	keyspace := New()
+	set(keyspace, "k", "string")
+	set(keyspace, "k", list{"a"})
+	if value, exists := keyspace.Get("k"); !exists || !reflect.DeepEqual(value, list{"a"}) {
+		t.Errorf("Expected the list to replace the string but got %v", value)
+	}
+	set(keyspace, "empty", list{})
+	if _, exists := keyspace.Get("empty"); exists {
+		t.Errorf("An empty collection shouldn't exist")
+	}
+	isString := func(value interface{}) bool {
+		_, isString := value.(string)
+		return isString
+	}
+	set(keyspace, "s", "string")
+	keys := keyspace.Keys(nil)
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, []string{"k", "s"}) {
+		t.Errorf("Expected keys [k s] but got %v", keys)
+	}
+	if keys := keyspace.Keys(isString); !reflect.DeepEqual(keys, []string{"s"}) {
+		t.Errorf("Expected string keys [s] but got %v", keys)
+	}
+	keyspace.ExpireAt("s", time.Now().Add(time.Hour))
+	if keys, volatile, _ := keyspace.KeyCounts(nil); keys != 2 || volatile != 1 {
+		t.Errorf("Expected 2 keys, 1 volatile but got %v %v", keys, volatile)
+	}
+	if !keyspace.Delete("k") || keyspace.Delete("k") {
+		t.Errorf("Expected Delete to remove k once")
+	}
+}
+
+func TestOnExpiredCalledOnlyForExpiredKeys(t *testing.T) {
+	keyspace := New()
+	expired := make(chan string, 3)
+	keyspace.OnExpired(func(key string) { expired <- key })
+	set(keyspace, "expiring", "value")
+	set(keyspace, "persisted", "value")
+	set(keyspace, "now", "value")
+	keyspace.ExpireAt("expiring", time.Now().Add(10*time.Millisecond))
+	keyspace.ExpireAt("persisted", time.Now().Add(10*time.Millisecond))
+	keyspace.Persist("persisted")
+	// a deadline already passed removes the key without the timer
+	keyspace.ExpireAt("now", time.Now())
+	select {
+	case key := <-expired:
+		if key != "expiring" {
+			t.Errorf("Expected expiring but got %v", key)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("OnExpired callback was not called")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if len(expired) != 0 {
+		t.Errorf("Only the expiring key should be reported but got %v", <-expired)
+	}
+	if count := keyspace.ExpiredCount(); count != 1 {
+		t.Errorf("Expected 1 expired key but got %v", count)
+	}
+}
+
+func TestReplacingAnExpiredKeyReportsIt(t *testing.T) {
+	keyspace := New()
+	expired := make(chan string, 2)
+	keyspace.OnExpired(func(key string) { expired <- key })
+	set(keyspace, "k", "old")
+	keyspace.ExpireAt("k", time.Now().Add(5*time.Millisecond))
+	// the write locks the shard before the timer can
+	s := keyspace.Shard("k")
+	s.Lock()
+	time.Sleep(20 * time.Millisecond)
+	s.Set("k", "new")
+	s.Unlock()
+	if key := <-expired; key != "k" {
+		t.Errorf("Expected k to be reported but got %v", key)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if len(expired) != 0 || keyspace.ExpiredCount() != 1 {
+		t.Errorf("Expected k to be reported once but got %v more", len(expired))
+	}
+	if value, _ := keyspace.Get("k"); value != "new" {
+		t.Errorf("Expected the new value but got %v", value)
+	}
+}
+
+func TestScanReturnsKeysPresentThroughout(t *testing.T) {
+	keyspace := New()
+	for i := 0; i < 200; i++ {
+		set(keyspace, "kept"+strconv.Itoa(i), "value")
+	}
+	set(keyspace, "empty", list{})
+	seen := make(map[string]bool)
+	cursor, calls := uint64(0), 0
+	for {
+		visited := 0
+		cursor = keyspace.Scan(cursor, 10, func(key string, value interface{}) {
+			seen[key] = true
+			visited++
+		})
+		calls++
+		if visited > 40 {
+			t.Errorf("Expected about 10 keys per call but got %v", visited)
+		}
+		if calls == 3 {
+			for i := 0; i < 200; i++ {
+				set(keyspace, "added"+strconv.Itoa(i), "value")
+			}
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	for i := 0; i < 200; i++ {
+		if !seen["kept"+strconv.Itoa(i)] {
+			t.Fatalf("Expected kept%v to be returned", i)
+		}
+	}
+	if seen["empty"] {
+		t.Errorf("An empty collection shouldn't be returned")
+	}
+}
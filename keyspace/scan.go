@@ -0,0 +1,98 @@
+package keyspace
+
+import "math/bits"
+
+// Smallest number of buckets of a ScanOrder holding members
+const minScanBuckets = 4
+
+// Members spread in buckets by hash, giving the order of a scan with a cursor
+// like the hash tables of redis. The cursor is a bucket index whose bits are
+// incremented from the highest one down, so a member present for the whole
+// scan is returned even if the table grows or shrinks in between, at the
+// cost of some members being returned twice when it shrinks.
+// Adding and removing a member is O(1) amortized. Not safe for concurrent use.
+type ScanOrder struct {
+	buckets [][]string
+	length  int
+}
+
+func (o *ScanOrder) Len() int {
+	return o.length
+}
+
+// Adds member, which mustn't be in already
+func (o *ScanOrder) Add(member string) {
+	o.length++
+	if o.length > len(o.buckets) {
+		o.resize(2 * len(o.buckets))
+	}
+	o.insert(member)
+}
+
+// Removes member if it is in
+func (o *ScanOrder) Remove(member string) {
+	if o.length == 0 {
+		return
+	}
+	index := o.bucketOf(member)
+	bucket := o.buckets[index]
+	for i := range bucket {
+		if bucket[i] == member {
+			bucket[i] = bucket[len(bucket)-1]
+			o.buckets[index] = bucket[:len(bucket)-1]
+			o.length--
+			break
+		}
+	}
+	switch {
+	case o.length == 0:
+		o.buckets = nil
+	case o.length < len(o.buckets)/4 && len(o.buckets) > minScanBuckets:
+		o.resize(len(o.buckets) / 2)
+	}
+}
+
+// Calls visit with the members of buckets from cursor on, until at least
+// count members were visited or every bucket was. Returns the cursor to
+// continue from, 0 once the scan is complete.
+func (o *ScanOrder) Scan(cursor uint64, count int, visit func(member string)) (nextCursor uint64) {
+	if o.length == 0 {
+		return 0
+	}
+	mask := uint64(len(o.buckets) - 1)
+	visited := 0
+	for {
+		for _, member := range o.buckets[cursor&mask] {
+			visit(member)
+			visited++
+		}
+		// increment the bits of mask from the highest one down
+		cursor |= ^mask
+		cursor = bits.Reverse64(bits.Reverse64(cursor) + 1)
+		if cursor == 0 || visited >= count {
+			return cursor
+		}
+	}
+}
+
+func (o *ScanOrder) bucketOf(member string) uint64 {
+	return uint64(Hash(member)) & uint64(len(o.buckets)-1)
+}
+
+func (o *ScanOrder) insert(member string) {
+	index := o.bucketOf(member)
+	o.buckets[index] = append(o.buckets[index], member)
+}
+
+func (o *ScanOrder) resize(size int) {
+	if size < minScanBuckets {
+		size = minScanBuckets
+	}
+	old := o.buckets
+	o.buckets = make([][]string, size)
+	for _, bucket := range old {
+		for _, member := range bucket {
+			o.insert(member)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package keyspace
+
+import (
+	"strconv"
+	"testing"
+)
+
+// Members present for the whole scan are returned while the table grows and
+// shrinks between calls
+func TestScanOrderReturnsMembersPresentThroughout(t *testing.T) {
+	var order ScanOrder
+	for i := 0; i < 100; i++ {
+		order.Add("kept" + strconv.Itoa(i))
+	}
+	for i := 0; i < 300; i++ {
+		order.Add("removed" + strconv.Itoa(i))
+	}
+	seen := make(map[string]int)
+	cursor, calls := uint64(0), 0
+	for {
+		cursor = order.Scan(cursor, 10, func(member string) {
+			seen[member]++
+		})
+		calls++
+		switch calls {
+		case 5:
+			for i := 0; i < 300; i++ {
+				order.Remove("removed" + strconv.Itoa(i))
+			}
+		case 8:
+			for i := 0; i < 1000; i++ {
+				order.Add("added" + strconv.Itoa(i))
+			}
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	for i := 0; i < 100; i++ {
+		if seen["kept"+strconv.Itoa(i)] == 0 {
+			t.Errorf("Scan missed kept%v", i)
+		}
+	}
+	if order.Len() != 1100 {
+		t.Errorf("Expected 1100 members but got %v", order.Len())
+	}
+}
+
+func TestScanOrderReturnsEachMemberOnceWithoutChanges(t *testing.T) {
+	var order ScanOrder
+	if cursor := order.Scan(0, 10, func(string) { t.Errorf("Empty order visited a member") }); cursor != 0 {
+		t.Errorf("Expected cursor 0 for an empty order but got %v", cursor)
+	}
+	for i := 0; i < 1000; i++ {
+		order.Add(strconv.Itoa(i))
+	}
+	seen := make(map[string]int)
+	cursor, calls := uint64(0), 0
+	for {
+		cursor = order.Scan(cursor, 100, func(member string) {
+			seen[member]++
+		})
+		calls++
+		if cursor == 0 {
+			break
+		}
+	}
+	if len(seen) != 1000 {
+		t.Errorf("Expected 1000 members but got %v", len(seen))
+	}
+	for member, count := range seen {
+		if count != 1 {
+			t.Errorf("%v returned %v times", member, count)
+		}
+	}
+	if calls < 5 {
+		t.Errorf("Expected scan to take several calls but took %v", calls)
+	}
+}
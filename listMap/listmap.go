@@ -0,0 +1,419 @@
+package listMap
+
+import (
+	"errors"
+	"github.com/thedeveloperr/redis-clone/keyspace"
+	"sync"
+	"time"
+)
+
+var ErrNoSuchKey = errors.New("ERR no such key")
+var ErrOutOfRange = errors.New("ERR index out of range")
+
+type ConcurrentListMap struct {
+	keyspace *keyspace.Keyspace
+
+	// Clients blocked in BLPOP like calls, woken up when a key they wait on is pushed to
+	waitersMutex sync.Mutex
+	waiters      map[string][]chan bool
+}
+
+// Lists stored in a keyspace of their own
+func Create() *ConcurrentListMap {
+	return CreateInKeyspace(keyspace.New())
+}
+
+// Lists stored in keyspace, along with the values of other types
+func CreateInKeyspace(keyspace *keyspace.Keyspace) *ConcurrentListMap {
+	return &ConcurrentListMap{
+		keyspace: keyspace,
+		waiters:  make(map[string][]chan bool),
+	}
+}
+
+// List at key. Caller must hold the lock of the shard.
+func getUnsafe(s *keyspace.Shard, key string) (*Quicklist, bool) {
+	entry, exists := s.Get(key)
+	if !exists {
+		return nil, false
+	}
+	list, isList := entry.Value.(*Quicklist)
+	return list, isList
+}
+
+// List at key, created if missing. Caller must hold the write lock of the shard.
+func getOrCreateUnsafe(s *keyspace.Shard, key string) *Quicklist {
+	list, exists := getUnsafe(s, key)
+	if !exists {
+		list = CreateQuicklist()
+		s.Set(key, list)
+	}
+	return list
+}
+
+// Keys which exist, in no particular order
+func (c *ConcurrentListMap) Keys() []string {
+	return c.keyspace.Keys(IsList)
+}
+
+// Whether value, read from a keyspace, is a list
+func IsList(value interface{}) bool {
+	_, isList := value.(*Quicklist)
+	return isList
+}
+
+// Removes key once its list is empty, like redis. Caller must hold the write lock.
+func deleteIfEmptyUnsafe(s *keyspace.Shard, key string, list *Quicklist) {
+	if list.Length() == 0 {
+		s.Delete(key)
+	}
+}
+
+// Pushes values one after another to the head, or the tail if left is false.
+// Returns the new length of the list.
+func (c *ConcurrentListMap) Push(key string, values []string, left bool) int64 {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	valueItem := getOrCreateUnsafe(s, key)
+	for _, value := range values {
+		if left {
+			valueItem.PushHead(value)
+		} else {
+			valueItem.PushTail(value)
+		}
+	}
+	length := valueItem.Length()
+	s.Unlock()
+	c.wakeWaiters(key)
+	return length
+}
+
+// Pops up to count entries from the head, or the tail if left is false.
+// exists is false if there is no list at key.
+func (c *ConcurrentListMap) Pop(key string, count int64, left bool) (values []string, exists bool) {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	valueItem, exists := getUnsafe(s, key)
+	if !exists {
+		return nil, false
+	}
+	return popUnsafe(s, key, valueItem, count, left), true
+}
+
+func popUnsafe(s *keyspace.Shard, key string, valueItem *Quicklist, count int64, left bool) []string {
+	values := []string{}
+	for int64(len(values)) < count {
+		var value string
+		var ok bool
+		if left {
+			value, ok = valueItem.PopHead()
+		} else {
+			value, ok = valueItem.PopTail()
+		}
+		if !ok {
+			break
+		}
+		values = append(values, value)
+	}
+	deleteIfEmptyUnsafe(s, key, valueItem)
+	return values
+}
+
+func (c *ConcurrentListMap) Len(key string) int64 {
+	s := c.keyspace.Shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	if valueItem, exists := getUnsafe(s, key); exists {
+		return valueItem.Length()
+	}
+	return 0
+}
+
+// Entries from start to end inclusive, negative positions count from the tail
+func (c *ConcurrentListMap) Range(key string, start int64, end int64) []string {
+	s := c.keyspace.Shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	if valueItem, exists := getUnsafe(s, key); exists {
+		return valueItem.Range(start, end)
+	}
+	return nil
+}
+
+func (c *ConcurrentListMap) Index(key string, index int64) (string, bool) {
+	s := c.keyspace.Shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	if valueItem, exists := getUnsafe(s, key); exists {
+		return valueItem.Index(index)
+	}
+	return "", false
+}
+
+// Replaces entry at index. Fails with ErrNoSuchKey or ErrOutOfRange.
+func (c *ConcurrentListMap) Set(key string, index int64, value string) error {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	valueItem, exists := getUnsafe(s, key)
+	if !exists {
+		return ErrNoSuchKey
+	}
+	if !valueItem.Set(index, value) {
+		return ErrOutOfRange
+	}
+	return nil
+}
+
+// Removes count occurrences of value, see Quicklist.Remove. Returns number removed.
+func (c *ConcurrentListMap) Remove(key string, count int64, value string) int64 {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	valueItem, exists := getUnsafe(s, key)
+	if !exists {
+		return 0
+	}
+	removed := valueItem.Remove(count, value)
+	deleteIfEmptyUnsafe(s, key, valueItem)
+	return removed
+}
+
+// Keeps only entries from start to end inclusive
+func (c *ConcurrentListMap) Trim(key string, start int64, end int64) {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	valueItem, exists := getUnsafe(s, key)
+	if !exists {
+		return
+	}
+	valueItem.Trim(start, end)
+	deleteIfEmptyUnsafe(s, key, valueItem)
+}
+
+// Inserts value next to the first pivot. Returns the new length, -1 if
+// pivot wasn't found and 0 if there is no list at key.
+func (c *ConcurrentListMap) Insert(key string, after bool, pivot string, value string) int64 {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	valueItem, exists := getUnsafe(s, key)
+	if !exists {
+		return 0
+	}
+	if !valueItem.Insert(after, pivot, value) {
+		return -1
+	}
+	return valueItem.Length()
+}
+
+// Indexes of entries equal to value like LPOS. rank picks the rank-th match,
+// negative ranks search from the tail. count limits the matches returned,
+// 0 meaning all of them, and maxLen the entries compared, 0 meaning all.
+func (c *ConcurrentListMap) Pos(key string, value string, rank int64, count int64, maxLen int64) []int64 {
+	s := c.keyspace.Shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	valueItem, exists := getUnsafe(s, key)
+	if !exists {
+		return nil
+	}
+	skip := rank - 1
+	if rank < 0 {
+		skip = -rank - 1
+	}
+	positions := []int64{}
+	var compared int64
+	valueItem.forEach(rank < 0, func(index int64, entry string) bool {
+		if maxLen != 0 && compared == maxLen {
+			return false
+		}
+		compared++
+		if entry != value {
+			return true
+		}
+		if skip > 0 {
+			skip--
+			return true
+		}
+		positions = append(positions, index)
+		return count == 0 || int64(len(positions)) < count
+	})
+	return positions
+}
+
+// Atomically pops from one end of source and pushes to one end of destination.
+// exists is false if there is no list at source.
+func (c *ConcurrentListMap) Move(source string, destination string, fromLeft bool, toLeft bool) (value string, exists bool) {
+	unlock := c.keyspace.LockShards([]string{source, destination}, true)
+	value, exists = c.moveUnsafe(source, destination, fromLeft, toLeft)
+	unlock()
+	if exists {
+		c.wakeWaiters(destination)
+	}
+	return value, exists
+}
+
+// Caller must hold the write locks of both keys
+func (c *ConcurrentListMap) moveUnsafe(source string, destination string, fromLeft bool, toLeft bool) (string, bool) {
+	sourceShard := c.keyspace.Shard(source)
+	valueItem, exists := getUnsafe(sourceShard, source)
+	if !exists {
+		return "", false
+	}
+	value := popUnsafe(sourceShard, source, valueItem, 1, fromLeft)[0]
+	destinationItem := getOrCreateUnsafe(c.keyspace.Shard(destination), destination)
+	if toLeft {
+		destinationItem.PushHead(value)
+	} else {
+		destinationItem.PushTail(value)
+	}
+	return value, true
+}
+
+// Registers a channel signalled when any of keys is pushed to, behind the
+// clients already waiting on them
+func (c *ConcurrentListMap) addWaiter(keys []string) chan bool {
+	wake := make(chan bool, 1)
+	c.waitersMutex.Lock()
+	for _, key := range keys {
+		c.waiters[key] = append(c.waiters[key], wake)
+	}
+	c.waitersMutex.Unlock()
+	return wake
+}
+
+// Unregisters wake and signals the clients now first in line on keys, so
+// what is left of a push, or a push wake was signalled for but didn't get
+// to, goes to them.
+func (c *ConcurrentListMap) removeWaiter(keys []string, wake chan bool) {
+	c.waitersMutex.Lock()
+	defer c.waitersMutex.Unlock()
+	for _, key := range keys {
+		waiting := c.waiters[key]
+		for i := range waiting {
+			if waiting[i] == wake {
+				waiting = append(waiting[:i], waiting[i+1:]...)
+				break
+			}
+		}
+		if len(waiting) == 0 {
+			delete(c.waiters, key)
+		} else {
+			c.waiters[key] = waiting
+			signal(waiting[0])
+		}
+	}
+}
+
+func signal(wake chan bool) {
+	select {
+	case wake <- true:
+	default:
+	}
+}
+
+// Signals the client blocked on key for the longest. Once served it passes
+// the signal on to the next one.
+func (c *ConcurrentListMap) wakeWaiters(key string) {
+	c.waitersMutex.Lock()
+	defer c.waitersMutex.Unlock()
+	if waiting := c.waiters[key]; len(waiting) > 0 {
+		signal(waiting[0])
+	}
+}
+
+// Whether no client waits on keys before wake, a nil wake meaning one not
+// waiting yet
+func (c *ConcurrentListMap) isFirstWaiter(keys []string, wake chan bool) bool {
+	c.waitersMutex.Lock()
+	defer c.waitersMutex.Unlock()
+	for _, key := range keys {
+		if waiting := c.waiters[key]; len(waiting) == 0 || waiting[0] == wake {
+			return true
+		}
+	}
+	return false
+}
+
+// Runs try until it succeeds, waiting for pushes to keys in between. Clients
+// are served in the order they started waiting, so try only runs while no
+// client waits on one of the keys before this one.
+// A timeout of 0 waits forever. Returns false on timeout.
+func (c *ConcurrentListMap) Block(keys []string, timeout time.Duration, try func() bool) bool {
+	if c.isFirstWaiter(keys, nil) && try() {
+		return true
+	}
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	wake := c.addWaiter(keys)
+	defer c.removeWaiter(keys, wake)
+	for {
+		// retry after registering so a push in between isn't missed
+		if c.isFirstWaiter(keys, wake) && try() {
+			return true
+		}
+		select {
+		case <-wake:
+		case <-expired:
+			return false
+		}
+	}
+}
+
+// Pops one entry from the first non empty list among keys, waiting up to
+// timeout for one to be pushed. ok is false on timeout.
+func (c *ConcurrentListMap) BlockingPop(keys []string, left bool, timeout time.Duration) (key string, value string, ok bool) {
+	ok = c.Block(keys, timeout, func() bool {
+		for _, candidate := range keys {
+			if values, exists := c.Pop(candidate, 1, left); exists {
+				key, value = candidate, values[0]
+				return true
+			}
+		}
+		return false
+	})
+	return key, value, ok
+}
+
+// Like Move but waits up to timeout for source to be pushed to
+func (c *ConcurrentListMap) BlockingMove(source string, destination string, fromLeft bool, toLeft bool, timeout time.Duration) (value string, ok bool) {
+	ok = c.Block([]string{source}, timeout, func() bool {
+		var exists bool
+		value, exists = c.Move(source, destination, fromLeft, toLeft)
+		return exists
+	})
+	return value, ok
+}
+
+func (c *ConcurrentListMap) Expire(key string, timeoutSeconds int) int {
+	return c.ExpireAt(key, time.Now().Add(time.Duration(timeoutSeconds)*time.Second))
+}
+
+// Expire key at an absolute deadline. A deadline in the past removes the key right away.
+func (c *ConcurrentListMap) ExpireAt(key string, deadline time.Time) int {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	if _, exists := getUnsafe(s, key); !exists || !s.ExpireAt(key, deadline) {
+		return 0
+	}
+	return 1
+}
+
+// Remove timeout of key. Returns 1 if a timeout was removed
+func (c *ConcurrentListMap) Persist(key string) int {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	if _, exists := getUnsafe(s, key); !exists || !s.Persist(key) {
+		return 0
+	}
+	return 1
+}
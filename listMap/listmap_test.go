@@ -0,0 +1,146 @@
+package listMap
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPushPopRemovesEmptyList(t *testing.T) {
+	lists := Create()
+	if length := lists.Push("queue", []string{"a", "b", "c"}, false); length != 3 {
+		t.Errorf("Expected length 3 but got %v", length)
+	}
+	lists.Push("queue", []string{"x", "y"}, true)
+	if values := lists.Range("queue", 0, -1); !reflect.DeepEqual(values, []string{"y", "x", "a", "b", "c"}) {
+		t.Errorf("Unexpected list %v", values)
+	}
+	if values, _ := lists.Pop("queue", 2, false); !reflect.DeepEqual(values, []string{"c", "b"}) {
+		t.Errorf("Unexpected pop %v", values)
+	}
+	lists.Pop("queue", 10, true)
+	if _, exists := lists.Pop("queue", 1, true); exists {
+		t.Errorf("Emptied list should be removed")
+	}
+	if lists.Persist("queue") != 0 {
+		t.Errorf("Emptied list should be removed")
+	}
+}
+
+func TestPos(t *testing.T) {
+	lists := Create()
+	lists.Push("l", []string{"a", "b", "c", "1", "2", "3", "c", "c"}, false)
+	cases := []struct {
+		rank, count, maxLen int64
+		expected            []int64
+	}{
+		{1, 1, 0, []int64{2}},
+		{1, 0, 0, []int64{2, 6, 7}},
+		{2, 0, 0, []int64{6, 7}},
+		{-1, 2, 0, []int64{7, 6}},
+		{1, 0, 3, []int64{2}},
+		{-1, 0, 1, []int64{7}},
+		{4, 1, 0, []int64{}},
+	}
+	for _, c := range cases {
+		if positions := lists.Pos("l", "c", c.rank, c.count, c.maxLen); !reflect.DeepEqual(positions, c.expected) {
+			t.Errorf("Pos rank %v count %v maxlen %v expected %v but got %v", c.rank, c.count, c.maxLen, c.expected, positions)
+		}
+	}
+}
+
+func TestBlockingPopWaitsForPush(t *testing.T) {
+	lists := Create()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		lists.Push("second", []string{"job"}, false)
+	}()
+	key, value, ok := lists.BlockingPop([]string{"first", "second"}, true, time.Second)
+	if !ok || key != "second" || value != "job" {
+		t.Errorf("Expected job from second but got %v %v %v", key, value, ok)
+	}
+	start := time.Now()
+	if _, _, ok := lists.BlockingPop([]string{"first"}, true, 20*time.Millisecond); ok {
+		t.Errorf("Expected timeout on empty list")
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Errorf("BlockingPop returned before its timeout")
+	}
+}
+
+// Every pushed entry is handed to exactly one of the blocked clients
+func TestBlockingPopDeliversOnce(t *testing.T) {
+	lists := Create()
+	results := make(chan string, 10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			_, value, ok := lists.BlockingPop([]string{"queue"}, false, 2*time.Second)
+			if ok {
+				results <- value
+			} else {
+				results <- ""
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	for _, job := range []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"} {
+		lists.Push("queue", []string{job}, true)
+	}
+	seen := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		value := <-results
+		if value == "" || seen[value] {
+			t.Fatalf("Got missing or duplicate job %q", value)
+		}
+		seen[value] = true
+	}
+}
+
+func TestBlockingPopServesLongestWaitingFirst(t *testing.T) {
+	lists := Create()
+	results := make([]chan string, 3)
+	for i := range results {
+		results[i] = make(chan string, 1)
+		go func(result chan string) {
+			_, value, _ := lists.BlockingPop([]string{"queue"}, true, time.Second)
+			result <- value
+		}(results[i])
+		// each client starts waiting after the one before
+		time.Sleep(20 * time.Millisecond)
+	}
+	lists.Push("queue", []string{"a", "b"}, false)
+	for i, expected := range []string{"a", "b"} {
+		if value := <-results[i]; value != expected {
+			t.Errorf("Expected client %v to get %v but got %q", i, expected, value)
+		}
+	}
+	lists.Push("queue", []string{"c"}, false)
+	if value := <-results[2]; value != "c" {
+		t.Errorf("Expected the last client to get c but got %q", value)
+	}
+}
+
+func TestBlockingMove(t *testing.T) {
+	lists := Create()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		lists.Push("source", []string{"a", "b"}, false)
+	}()
+	value, ok := lists.BlockingMove("source", "destination", false, true, time.Second)
+	if !ok || value != "b" {
+		t.Errorf("Expected b to be moved but got %v", value)
+	}
+	if values := lists.Range("destination", 0, -1); !reflect.DeepEqual(values, []string{"b"}) {
+		t.Errorf("Unexpected destination %v", values)
+	}
+}
+
+func TestKeysSkipsEmptiedLists(t *testing.T) {
+	lists := Create()
+	lists.Push("queue", []string{"a"}, false)
+	lists.Push("done", []string{"b"}, false)
+	lists.Pop("done", 1, true)
+	if keys := lists.Keys(); !reflect.DeepEqual(keys, []string{"queue"}) {
+		t.Errorf("Expected keys [queue] but got %v", keys)
+	}
+}
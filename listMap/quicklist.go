@@ -0,0 +1,343 @@
+package listMap
+
+import (
+	"sync/atomic"
+)
+
+// Maximum number of entries kept in one quicklist node, like list-max-listpack-size
+var maxNodeEntries int64 = 128
+
+// Change the size of quicklist nodes. Existing nodes keep their size until they are split.
+func SetQuicklistNodeSize(maxEntries int) {
+	atomic.StoreInt64(&maxNodeEntries, int64(maxEntries))
+}
+
+func GetQuicklistNodeSize() int {
+	return int(atomic.LoadInt64(&maxNodeEntries))
+}
+
+// List encoding inspired by redis's quicklist: a doubly linked list of
+// nodes each holding a small slice of entries. Pushes and pops at either
+// end are O(1) while per entry overhead stays close to a plain slice, and
+// an insert in the middle only moves the entries of one node.
+//
+//	head                                          tail
+//	[a b c ... ] <-> [d e f ... ] <-> ... <-> [x y z]
+type Quicklist struct {
+	head      *quicklistNode
+	tail      *quicklistNode
+	length    int64
+	nodeCount int
+}
+
+type quicklistNode struct {
+	entries []string
+	prev    *quicklistNode
+	next    *quicklistNode
+}
+
+func CreateQuicklist() *Quicklist {
+	return &Quicklist{}
+}
+
+func (q *Quicklist) Length() int64 {
+	return q.length
+}
+
+// Whether it holds no entry, when the list stops existing
+func (q *Quicklist) IsEmpty() bool {
+	return q.Length() == 0
+}
+
+// Unlinks a node which became empty
+func (q *Quicklist) unlink(node *quicklistNode) {
+	if node.prev != nil {
+		node.prev.next = node.next
+	} else {
+		q.head = node.next
+	}
+	if node.next != nil {
+		node.next.prev = node.prev
+	} else {
+		q.tail = node.prev
+	}
+	q.nodeCount--
+}
+
+// Links a new empty node after prev, or as the head if prev is nil
+func (q *Quicklist) linkAfter(prev *quicklistNode) *quicklistNode {
+	node := &quicklistNode{prev: prev}
+	if prev == nil {
+		node.next = q.head
+		q.head = node
+	} else {
+		node.next = prev.next
+		prev.next = node
+	}
+	if node.next != nil {
+		node.next.prev = node
+	} else {
+		q.tail = node
+	}
+	q.nodeCount++
+	return node
+}
+
+func (q *Quicklist) PushHead(value string) {
+	if q.head == nil || len(q.head.entries) >= GetQuicklistNodeSize() {
+		q.linkAfter(nil)
+	}
+	q.head.entries = append(q.head.entries, "")
+	copy(q.head.entries[1:], q.head.entries)
+	q.head.entries[0] = value
+	q.length++
+}
+
+func (q *Quicklist) PushTail(value string) {
+	if q.tail == nil || len(q.tail.entries) >= GetQuicklistNodeSize() {
+		q.linkAfter(q.tail)
+	}
+	q.tail.entries = append(q.tail.entries, value)
+	q.length++
+}
+
+func (q *Quicklist) PopHead() (string, bool) {
+	if q.head == nil {
+		return "", false
+	}
+	value := q.head.entries[0]
+	q.deleteAt(q.head, 0)
+	return value, true
+}
+
+func (q *Quicklist) PopTail() (string, bool) {
+	if q.tail == nil {
+		return "", false
+	}
+	value := q.tail.entries[len(q.tail.entries)-1]
+	q.deleteAt(q.tail, len(q.tail.entries)-1)
+	return value, true
+}
+
+// Node and offset inside it holding index. Walks from whichever end is closer.
+// index must be within 0 and length-1.
+func (q *Quicklist) locate(index int64) (*quicklistNode, int) {
+	if index < q.length/2 {
+		node := q.head
+		for index >= int64(len(node.entries)) {
+			index -= int64(len(node.entries))
+			node = node.next
+		}
+		return node, int(index)
+	}
+	fromTail := q.length - 1 - index
+	node := q.tail
+	for fromTail >= int64(len(node.entries)) {
+		fromTail -= int64(len(node.entries))
+		node = node.prev
+	}
+	return node, len(node.entries) - 1 - int(fromTail)
+}
+
+// Turns a negative index counting from the tail into a positive one.
+// ok is false when it is out of range.
+func (q *Quicklist) normaliseIndex(index int64) (int64, bool) {
+	if index < 0 {
+		index += q.length
+	}
+	return index, index >= 0 && index < q.length
+}
+
+// Entry at index, negative indexes count from the tail
+func (q *Quicklist) Index(index int64) (string, bool) {
+	index, ok := q.normaliseIndex(index)
+	if !ok {
+		return "", false
+	}
+	node, offset := q.locate(index)
+	return node.entries[offset], true
+}
+
+// Replaces entry at index. Returns false if index is out of range
+func (q *Quicklist) Set(index int64, value string) bool {
+	index, ok := q.normaliseIndex(index)
+	if !ok {
+		return false
+	}
+	node, offset := q.locate(index)
+	node.entries[offset] = value
+	return true
+}
+
+// Entries from start to end inclusive, with negative positions counting from the tail like LRANGE
+func (q *Quicklist) Range(start int64, end int64) []string {
+	start, end, ok := normaliseRange(start, end, q.length)
+	if !ok {
+		return nil
+	}
+	values := make([]string, 0, end-start+1)
+	node, offset := q.locate(start)
+	for i := start; i <= end; i++ {
+		values = append(values, node.entries[offset])
+		offset++
+		if offset == len(node.entries) {
+			node, offset = node.next, 0
+		}
+	}
+	return values
+}
+
+// Clamps LRANGE style positions to the list. ok is false for an empty range.
+func normaliseRange(start int64, end int64, length int64) (int64, int64, bool) {
+	if start < 0 {
+		start += length
+	}
+	if end < 0 {
+		end += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end >= length {
+		end = length - 1
+	}
+	return start, end, start <= end
+}
+
+// Removes the entry at offset of node, dropping the node once it is empty
+func (q *Quicklist) deleteAt(node *quicklistNode, offset int) {
+	copy(node.entries[offset:], node.entries[offset+1:])
+	node.entries[len(node.entries)-1] = ""
+	node.entries = node.entries[:len(node.entries)-1]
+	q.length--
+	if len(node.entries) == 0 {
+		q.unlink(node)
+	}
+}
+
+// Inserts value before offset of node, splitting the node in half when full
+func (q *Quicklist) insertAt(node *quicklistNode, offset int, value string) {
+	if len(node.entries) >= GetQuicklistNodeSize() {
+		half := len(node.entries) / 2
+		next := q.linkAfter(node)
+		next.entries = append(next.entries, node.entries[half:]...)
+		for i := half; i < len(node.entries); i++ {
+			node.entries[i] = ""
+		}
+		node.entries = node.entries[:half]
+		if offset > half {
+			node, offset = next, offset-half
+		}
+	}
+	node.entries = append(node.entries, "")
+	copy(node.entries[offset+1:], node.entries[offset:])
+	node.entries[offset] = value
+	q.length++
+}
+
+// Inserts value before or after the first occurrence of pivot. Returns false if pivot isn't found
+func (q *Quicklist) Insert(after bool, pivot string, value string) bool {
+	for node := q.head; node != nil; node = node.next {
+		for offset, entry := range node.entries {
+			if entry != pivot {
+				continue
+			}
+			if after {
+				offset++
+			}
+			q.insertAt(node, offset, value)
+			return true
+		}
+	}
+	return false
+}
+
+// Removes entries equal to value like LREM: count > 0 removes the first count from
+// the head, count < 0 the first -count from the tail and 0 all of them
+func (q *Quicklist) Remove(count int64, value string) int64 {
+	var removed int64
+	if count >= 0 {
+		for node := q.head; node != nil; {
+			next := node.next
+			for offset := 0; offset < len(node.entries); {
+				if node.entries[offset] == value && (count == 0 || removed < count) {
+					q.deleteAt(node, offset)
+					removed++
+					continue
+				}
+				offset++
+			}
+			node = next
+		}
+		return removed
+	}
+	for node := q.tail; node != nil && removed < -count; {
+		prev := node.prev
+		for offset := len(node.entries) - 1; offset >= 0 && removed < -count; offset-- {
+			if node.entries[offset] == value {
+				q.deleteAt(node, offset)
+				removed++
+			}
+		}
+		node = prev
+	}
+	return removed
+}
+
+// Keeps only entries from start to end inclusive like LTRIM
+func (q *Quicklist) Trim(start int64, end int64) {
+	start, end, ok := normaliseRange(start, end, q.length)
+	if !ok {
+		q.head, q.tail, q.length, q.nodeCount = nil, nil, 0, 0
+		return
+	}
+	dropHead := start
+	dropTail := q.length - 1 - end
+	for dropHead > 0 {
+		drop := int64(len(q.head.entries))
+		if drop > dropHead {
+			drop = dropHead
+			q.head.entries = append([]string(nil), q.head.entries[drop:]...)
+		} else {
+			q.unlink(q.head)
+		}
+		q.length -= drop
+		dropHead -= drop
+	}
+	for dropTail > 0 {
+		drop := int64(len(q.tail.entries))
+		if drop > dropTail {
+			drop = dropTail
+			q.tail.entries = q.tail.entries[:int64(len(q.tail.entries))-drop]
+		} else {
+			q.unlink(q.tail)
+		}
+		q.length -= drop
+		dropTail -= drop
+	}
+}
+
+// Calls fn with each entry and its index, from the tail if reverse is true, until fn returns false
+func (q *Quicklist) forEach(reverse bool, fn func(index int64, value string) bool) {
+	if !reverse {
+		var index int64
+		for node := q.head; node != nil; node = node.next {
+			for _, entry := range node.entries {
+				if !fn(index, entry) {
+					return
+				}
+				index++
+			}
+		}
+		return
+	}
+	index := q.length - 1
+	for node := q.tail; node != nil; node = node.prev {
+		for offset := len(node.entries) - 1; offset >= 0; offset-- {
+			if !fn(index, node.entries[offset]) {
+				return
+			}
+			index--
+		}
+	}
+}
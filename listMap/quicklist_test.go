@@ -0,0 +1,160 @@
+package listMap
+
+import (
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// Checks links, node sizes and length against the entries actually stored
+func (q *Quicklist) checkInvariants() string {
+	var length int64
+	nodes := 0
+	var prev *quicklistNode
+	for node := q.head; node != nil; node = node.next {
+		if node.prev != prev {
+			return "broken prev link"
+		}
+		if len(node.entries) == 0 || len(node.entries) > GetQuicklistNodeSize() {
+			return "node with " + strconv.Itoa(len(node.entries)) + " entries"
+		}
+		length += int64(len(node.entries))
+		nodes++
+		prev = node
+	}
+	if prev != q.tail || length != q.length || nodes != q.nodeCount {
+		return "tail, length or node count out of sync"
+	}
+	return ""
+}
+
+// Runs random operations on a quicklist with tiny nodes and a plain slice
+// and expects both to always hold the same entries
+func TestQuicklistAgainstSlice(t *testing.T) {
+	defer SetQuicklistNodeSize(GetQuicklistNodeSize())
+	SetQuicklistNodeSize(4)
+	random := rand.New(rand.NewSource(1))
+	list := CreateQuicklist()
+	model := []string{}
+	for step := 0; step < 5000; step++ {
+		value := strconv.Itoa(random.Intn(10))
+		switch random.Intn(8) {
+		case 0:
+			list.PushHead(value)
+			model = append([]string{value}, model...)
+		case 1:
+			list.PushTail(value)
+			model = append(model, value)
+		case 2:
+			got, ok := list.PopHead()
+			if ok != (len(model) > 0) || (ok && got != model[0]) {
+				t.Fatalf("step %v: PopHead got %v", step, got)
+			}
+			if ok {
+				model = model[1:]
+			}
+		case 3:
+			got, ok := list.PopTail()
+			if ok != (len(model) > 0) || (ok && got != model[len(model)-1]) {
+				t.Fatalf("step %v: PopTail got %v", step, got)
+			}
+			if ok {
+				model = model[:len(model)-1]
+			}
+		case 4:
+			after := random.Intn(2) == 0
+			pivot := strconv.Itoa(random.Intn(10))
+			inserted := list.Insert(after, pivot, value)
+			for i, entry := range model {
+				if entry == pivot {
+					if after {
+						i++
+					}
+					model = append(model[:i], append([]string{value}, model[i:]...)...)
+					break
+				}
+			}
+			if !inserted && int64(len(model)) != list.Length() {
+				t.Fatalf("step %v: Insert missed pivot %v", step, pivot)
+			}
+		case 5:
+			count := int64(random.Intn(5) - 2)
+			list.Remove(count, value)
+			model = removeFromSlice(model, count, value)
+		case 6:
+			if random.Intn(4) == 0 {
+				start, end := int64(random.Intn(6)-2), int64(random.Intn(20)-4)
+				list.Trim(start, end)
+				model = sliceRange(model, start, end)
+			}
+		case 7:
+			if len(model) > 0 {
+				index := random.Intn(len(model))
+				list.Set(int64(index), value)
+				model[index] = value
+			}
+		}
+		if problem := list.checkInvariants(); problem != "" {
+			t.Fatalf("step %v: %v", step, problem)
+		}
+		if got := list.Range(0, -1); !(len(got) == 0 && len(model) == 0) && !reflect.DeepEqual(got, model) {
+			t.Fatalf("step %v: expected %v but got %v", step, model, got)
+		}
+	}
+}
+
+func removeFromSlice(model []string, count int64, value string) []string {
+	var removed int64
+	kept := []string{}
+	if count >= 0 {
+		for _, entry := range model {
+			if entry == value && (count == 0 || removed < count) {
+				removed++
+				continue
+			}
+			kept = append(kept, entry)
+		}
+		return kept
+	}
+	for i := len(model) - 1; i >= 0; i-- {
+		if model[i] == value && removed < -count {
+			removed++
+			continue
+		}
+		kept = append([]string{model[i]}, kept...)
+	}
+	return kept
+}
+
+func sliceRange(model []string, start int64, end int64) []string {
+	start, end, ok := normaliseRange(start, end, int64(len(model)))
+	if !ok {
+		return []string{}
+	}
+	return append([]string{}, model[start:end+1]...)
+}
+
+func TestQuicklistIndexAndRange(t *testing.T) {
+	defer SetQuicklistNodeSize(GetQuicklistNodeSize())
+	SetQuicklistNodeSize(3)
+	list := CreateQuicklist()
+	for i := 0; i < 10; i++ {
+		list.PushTail(strconv.Itoa(i))
+	}
+	if value, _ := list.Index(-1); value != "9" {
+		t.Errorf("Expected 9 but got %v", value)
+	}
+	if value, _ := list.Index(4); value != "4" {
+		t.Errorf("Expected 4 but got %v", value)
+	}
+	if _, ok := list.Index(10); ok {
+		t.Errorf("Index past the end should not exist")
+	}
+	if values := list.Range(-3, 100); !reflect.DeepEqual(values, []string{"7", "8", "9"}) {
+		t.Errorf("Unexpected range %v", values)
+	}
+	if values := list.Range(5, 2); values != nil {
+		t.Errorf("Expected empty range but got %v", values)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"log"
+	"sync/atomic"
+)
+
+// Levels of the server log, in the order of redis.log's LOG_ levels
+const (
+	LOG_DEBUG = iota
+	LOG_VERBOSE
+	LOG_NOTICE
+	LOG_WARNING
+)
+
+// Names of the log levels for the loglevel setting, by level
+var logLevelNames = []string{"debug", "verbose", "notice", "warning"}
+
+// Messages below this level aren't logged, see loglevel
+var logLevel int32 = LOG_NOTICE
+
+// Logs a message like log.Println if level is at least the loglevel
+func serverLog(level int, v ...interface{}) {
+	if int32(level) >= atomic.LoadInt32(&logLevel) {
+		log.Println(v...)
+	}
+}
+
+// Level named name, ok is false if there is none
+func parseLogLevel(name string) (level int, ok bool) {
+	for level, levelName := range logLevelNames {
+		if levelName == name {
+			return level, true
+		}
+	}
+	return 0, false
+}
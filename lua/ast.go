@@ -0,0 +1,175 @@
+package lua
+
+// Syntax tree of a chunk. Names are resolved while parsing: locals are slots
+// of their function's frame, captured variables are indexes in the closure's
+// upvalues, and other names are globals.
+
+type expr interface{}
+
+type constantExpr struct {
+	value Value
+}
+
+type varargExpr struct{}
+
+type localExpr struct {
+	name string
+	slot int
+}
+
+type upvalueExpr struct {
+	name  string
+	index int
+}
+
+type globalExpr struct {
+	name string
+	line int
+}
+
+type indexExpr struct {
+	object expr
+	key    expr
+	line   int
+}
+
+type callExpr struct {
+	function expr
+	args     []expr
+	line     int
+}
+
+type methodCallExpr struct {
+	object expr
+	name   string
+	args   []expr
+	line   int
+}
+
+type functionExpr struct {
+	proto *prototype
+}
+
+type binaryExpr struct {
+	op          string
+	left, right expr
+	line        int
+}
+
+// and and or, which only evaluate right when needed
+type logicalExpr struct {
+	and         bool
+	left, right expr
+}
+
+type unaryExpr struct {
+	op      string
+	operand expr
+	line    int
+}
+
+type tableField struct {
+	// nil for positional fields
+	key   expr
+	value expr
+}
+
+type tableExpr struct {
+	fields []tableField
+	line   int
+}
+
+// Parentheses keep only the first value of a call or ...
+type parenExpr struct {
+	inner expr
+}
+
+type stat interface{}
+
+type localStat struct {
+	slots  []int
+	values []expr
+}
+
+type localFunctionStat struct {
+	slot     int
+	function *functionExpr
+}
+
+type assignStat struct {
+	targets []expr
+	values  []expr
+	line    int
+}
+
+type callStat struct {
+	call expr
+}
+
+type doStat struct {
+	body *block
+}
+
+type whileStat struct {
+	condition expr
+	body      *block
+}
+
+// The condition is in the scope of the body
+type repeatStat struct {
+	body      *block
+	condition expr
+}
+
+type ifStat struct {
+	conditions []expr
+	blocks     []*block
+	elseBlock  *block
+}
+
+type numericForStat struct {
+	slot               int
+	start, limit, step expr
+	body               *block
+	line               int
+}
+
+type genericForStat struct {
+	slots  []int
+	values []expr
+	body   *block
+	line   int
+}
+
+type returnStat struct {
+	values []expr
+}
+
+type breakStat struct{}
+
+type block struct {
+	stats []stat
+}
+
+// Where a closure finds a captured variable when it is created: a slot of the
+// enclosing function's frame or one of its upvalues
+type upvalueDesc struct {
+	fromLocal bool
+	index     int
+}
+
+type prototype struct {
+	chunkName string
+	name      string
+	line      int
+	params    []int
+	isVararg  bool
+	numSlots  int
+	upvalues  []upvalueDesc
+	body      *block
+}
+
+// Compiled script, run with State.Run
+type Chunk struct {
+	proto *prototype
+}
@@ -0,0 +1,671 @@
+package lua
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Returned when a script runs past its deadline. Unlike an Error, pcall
+// doesn't catch it.
+var ErrTimeout = errors.New("script exceeded its time limit")
+
+// Deepest nesting of calls before a stack overflow error
+const MAX_CALL_DEPTH = 200
+
+// How many steps run between checks of the deadline
+const DEADLINE_CHECK_STEPS = 1024
+
+// Variable of a frame, shared with the closures capturing it
+type cell struct {
+	value Value
+}
+
+type frame struct {
+	slots    []*cell
+	upvalues []*cell
+	varargs  []Value
+}
+
+type flow int
+
+const (
+	flowNormal flow = iota
+	flowBreak
+	flowReturn
+)
+
+// Global environment scripts run in, with the safe part of the standard
+// library. A State isn't safe for concurrent use.
+type State struct {
+	globals   *Table
+	chunkName string
+	// line of the call being made, for the position error() adds
+	line  int
+	depth int
+	steps int
+	// A script still running at Deadline fails with ErrTimeout, zero means no limit
+	Deadline time.Time
+	// Scripts can't create globals or read missing ones, like in redis
+	StrictGlobals bool
+}
+
+// Creates a state with the base, string, table and math libraries. Nothing
+// reaching outside the state, like io, os or loading code, is available.
+func NewState() *State {
+	state := &State{globals: NewTable()}
+	openBaseLibrary(state)
+	return state
+}
+
+func (s *State) SetGlobal(name string, value Value) {
+	s.globals.Set(name, value)
+}
+
+func (s *State) GetGlobal(name string) Value {
+	return s.globals.Get(name)
+}
+
+// Runs a compiled chunk with args as ... and returns what it returned
+func (s *State) Run(chunk *Chunk, args ...Value) ([]Value, error) {
+	return s.Call(&Function{proto: chunk.proto}, args...)
+}
+
+// Calls a function value with args
+func (s *State) Call(function Value, args ...Value) ([]Value, error) {
+	s.steps = 0
+	return s.call(function, args, nil)
+}
+
+func (s *State) runtimeError(line int, message string) error {
+	return &Error{s.chunkNameOr() + ":" + itoa(line) + ": " + message}
+}
+
+func (s *State) chunkNameOr() string {
+	if s.chunkName == "" {
+		return "?"
+	}
+	return s.chunkName
+}
+
+func itoa(number int) string {
+	return strconv.Itoa(number)
+}
+
+// Counts a step, failing once the deadline passed
+func (s *State) step() error {
+	s.steps++
+	if s.steps%DEADLINE_CHECK_STEPS == 0 && !s.Deadline.IsZero() && time.Now().After(s.Deadline) {
+		return ErrTimeout
+	}
+	return nil
+}
+
+// Calls function, described by callee in error messages when not nil
+func (s *State) call(function Value, args []Value, callee expr) ([]Value, error) {
+	if err := s.step(); err != nil {
+		return nil, err
+	}
+	switch f := function.(type) {
+	case *GoFunction:
+		return f.Fn(s, args)
+	case *Function:
+		if s.depth >= MAX_CALL_DEPTH {
+			return nil, s.runtimeError(s.line, "stack overflow")
+		}
+		s.depth++
+		defer func() { s.depth-- }()
+		fr := &frame{slots: make([]*cell, f.proto.numSlots), upvalues: f.upvalues}
+		for i, slot := range f.proto.params {
+			var value Value
+			if i < len(args) {
+				value = args[i]
+			}
+			fr.slots[slot] = &cell{value}
+		}
+		if f.proto.isVararg && len(args) > len(f.proto.params) {
+			fr.varargs = args[len(f.proto.params):]
+		}
+		previousName := s.chunkName
+		s.chunkName = f.proto.chunkName
+		_, values, err := s.execBlock(fr, f.proto.body)
+		s.chunkName = previousName
+		return values, err
+	}
+	return nil, s.runtimeError(s.line, "attempt to call "+describe(callee, function))
+}
+
+// Names what an expression refers to in error messages, like Lua does
+func describe(e expr, value Value) string {
+	kind := "a " + TypeName(value) + " value"
+	switch v := e.(type) {
+	case *globalExpr:
+		return "global '" + v.name + "' (" + kind + ")"
+	case *localExpr:
+		return "local '" + v.name + "' (" + kind + ")"
+	case *upvalueExpr:
+		return "upvalue '" + v.name + "' (" + kind + ")"
+	case *indexExpr:
+		if key, ok := v.key.(*constantExpr); ok {
+			if name, ok := key.value.(string); ok {
+				return "field '" + name + "' (" + kind + ")"
+			}
+		}
+	case *methodCallExpr:
+		return "method '" + v.name + "' (" + kind + ")"
+	}
+	return kind
+}
+
+func (s *State) execBlock(fr *frame, b *block) (flow, []Value, error) {
+	// counted even when empty, so that while true do end times out
+	if err := s.step(); err != nil {
+		return flowNormal, nil, err
+	}
+	for _, st := range b.stats {
+		if err := s.step(); err != nil {
+			return flowNormal, nil, err
+		}
+		f, values, err := s.exec(fr, st)
+		if err != nil || f != flowNormal {
+			return f, values, err
+		}
+	}
+	return flowNormal, nil, nil
+}
+
+func (s *State) exec(fr *frame, st stat) (flow, []Value, error) {
+	switch st := st.(type) {
+	case *localStat:
+		values, err := s.evalList(fr, st.values, len(st.slots))
+		if err != nil {
+			return flowNormal, nil, err
+		}
+		for i, slot := range st.slots {
+			fr.slots[slot] = &cell{values[i]}
+		}
+	case *localFunctionStat:
+		fr.slots[st.slot] = &cell{}
+		fr.slots[st.slot].value = s.closure(fr, st.function.proto)
+	case *assignStat:
+		return flowNormal, nil, s.assign(fr, st)
+	case *callStat:
+		_, err := s.evalMulti(fr, st.call)
+		return flowNormal, nil, err
+	case *doStat:
+		return s.execBlock(fr, st.body)
+	case *whileStat:
+		for {
+			condition, err := s.eval(fr, st.condition)
+			if err != nil {
+				return flowNormal, nil, err
+			}
+			if !Truthy(condition) {
+				return flowNormal, nil, nil
+			}
+			f, values, err := s.execBlock(fr, st.body)
+			if err != nil || f == flowReturn {
+				return f, values, err
+			}
+			if f == flowBreak {
+				return flowNormal, nil, nil
+			}
+		}
+	case *repeatStat:
+		for {
+			f, values, err := s.execBlock(fr, st.body)
+			if err != nil || f == flowReturn {
+				return f, values, err
+			}
+			if f == flowBreak {
+				return flowNormal, nil, nil
+			}
+			condition, err := s.eval(fr, st.condition)
+			if err != nil {
+				return flowNormal, nil, err
+			}
+			if Truthy(condition) {
+				return flowNormal, nil, nil
+			}
+		}
+	case *ifStat:
+		for i, conditionExpr := range st.conditions {
+			condition, err := s.eval(fr, conditionExpr)
+			if err != nil {
+				return flowNormal, nil, err
+			}
+			if Truthy(condition) {
+				return s.execBlock(fr, st.blocks[i])
+			}
+		}
+		if st.elseBlock != nil {
+			return s.execBlock(fr, st.elseBlock)
+		}
+	case *numericForStat:
+		return s.numericFor(fr, st)
+	case *genericForStat:
+		return s.genericFor(fr, st)
+	case *returnStat:
+		// a call in tail position returns all its values
+		values, err := s.evalList(fr, st.values, -1)
+		return flowReturn, values, err
+	case *breakStat:
+		return flowBreak, nil, nil
+	}
+	return flowNormal, nil, nil
+}
+
+func (s *State) numericFor(fr *frame, st *numericForStat) (flow, []Value, error) {
+	bounds := []expr{st.start, st.limit, st.step}
+	names := []string{"initial", "limit", "step"}
+	numbers := []float64{0, 0, 1}
+	for i, e := range bounds {
+		if e == nil {
+			continue
+		}
+		value, err := s.eval(fr, e)
+		if err != nil {
+			return flowNormal, nil, err
+		}
+		number, ok := ToNumber(value)
+		if !ok {
+			return flowNormal, nil, s.runtimeError(st.line, "'for' "+names[i]+" value must be a number")
+		}
+		numbers[i] = number
+	}
+	start, limit, step := numbers[0], numbers[1], numbers[2]
+	for i := start; (step > 0 && i <= limit) || (step <= 0 && i >= limit); i += step {
+		fr.slots[st.slot] = &cell{i}
+		f, values, err := s.execBlock(fr, st.body)
+		if err != nil || f == flowReturn {
+			return f, values, err
+		}
+		if f == flowBreak {
+			break
+		}
+	}
+	return flowNormal, nil, nil
+}
+
+func (s *State) genericFor(fr *frame, st *genericForStat) (flow, []Value, error) {
+	values, err := s.evalList(fr, st.values, 3)
+	if err != nil {
+		return flowNormal, nil, err
+	}
+	iterator, state, control := values[0], values[1], values[2]
+	for {
+		s.line = st.line
+		results, err := s.call(iterator, []Value{state, control}, st.values[0])
+		if err != nil {
+			return flowNormal, nil, err
+		}
+		if len(results) == 0 || results[0] == nil {
+			return flowNormal, nil, nil
+		}
+		control = results[0]
+		for i, slot := range st.slots {
+			var value Value
+			if i < len(results) {
+				value = results[i]
+			}
+			fr.slots[slot] = &cell{value}
+		}
+		f, values, err := s.execBlock(fr, st.body)
+		if err != nil || f == flowReturn {
+			return f, values, err
+		}
+		if f == flowBreak {
+			return flowNormal, nil, nil
+		}
+	}
+}
+
+func (s *State) assign(fr *frame, st *assignStat) error {
+	// objects and keys are evaluated before the values, like Lua
+	objects := make([]Value, len(st.targets))
+	keys := make([]Value, len(st.targets))
+	for i, target := range st.targets {
+		if index, ok := target.(*indexExpr); ok {
+			object, err := s.eval(fr, index.object)
+			if err != nil {
+				return err
+			}
+			key, err := s.eval(fr, index.key)
+			if err != nil {
+				return err
+			}
+			objects[i], keys[i] = object, key
+		}
+	}
+	values, err := s.evalList(fr, st.values, len(st.targets))
+	if err != nil {
+		return err
+	}
+	for i, target := range st.targets {
+		switch t := target.(type) {
+		case *localExpr:
+			fr.slots[t.slot].value = values[i]
+		case *upvalueExpr:
+			fr.upvalues[t.index].value = values[i]
+		case *globalExpr:
+			if s.StrictGlobals {
+				if s.globals.Get(t.name) == nil {
+					return s.runtimeError(t.line, "Script attempted to create global variable '"+t.name+"'")
+				}
+				return s.runtimeError(t.line, "Attempt to modify a readonly table")
+			}
+			s.globals.Set(t.name, values[i])
+		case *indexExpr:
+			table, ok := objects[i].(*Table)
+			if !ok {
+				return s.runtimeError(t.line, "attempt to index "+describe(t.object, objects[i]))
+			}
+			if s.StrictGlobals && table.readonly {
+				return s.runtimeError(t.line, "Attempt to modify a readonly table")
+			}
+			if err := table.Set(keys[i], values[i]); err != nil {
+				return s.runtimeError(t.line, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+func (s *State) closure(fr *frame, proto *prototype) *Function {
+	upvalues := make([]*cell, len(proto.upvalues))
+	for i, desc := range proto.upvalues {
+		if desc.fromLocal {
+			upvalues[i] = fr.slots[desc.index]
+		} else {
+			upvalues[i] = fr.upvalues[desc.index]
+		}
+	}
+	return &Function{proto, upvalues}
+}
+
+// Evaluates exprs into exactly want values, or all of them when want is -1.
+// The last expression gives all its values when it's a call or ...
+func (s *State) evalList(fr *frame, exprs []expr, want int) ([]Value, error) {
+	values := make([]Value, 0, len(exprs))
+	for i, e := range exprs {
+		if i == len(exprs)-1 {
+			last, err := s.evalMulti(fr, e)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, last...)
+			break
+		}
+		value, err := s.eval(fr, e)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	if want < 0 {
+		return values, nil
+	}
+	for len(values) < want {
+		values = append(values, nil)
+	}
+	return values[:want], nil
+}
+
+// Evaluates an expression giving all the values of calls and ...
+func (s *State) evalMulti(fr *frame, e expr) ([]Value, error) {
+	switch e := e.(type) {
+	case *varargExpr:
+		return append([]Value(nil), fr.varargs...), nil
+	case *callExpr:
+		function, err := s.eval(fr, e.function)
+		if err != nil {
+			return nil, err
+		}
+		args, err := s.evalList(fr, e.args, -1)
+		if err != nil {
+			return nil, err
+		}
+		s.line = e.line
+		return s.call(function, args, e.function)
+	case *methodCallExpr:
+		object, err := s.eval(fr, e.object)
+		if err != nil {
+			return nil, err
+		}
+		method, err := s.index(object, e.name, e.object, e.line)
+		if err != nil {
+			return nil, err
+		}
+		args, err := s.evalList(fr, e.args, -1)
+		if err != nil {
+			return nil, err
+		}
+		s.line = e.line
+		return s.call(method, append([]Value{object}, args...), e)
+	}
+	value, err := s.eval(fr, e)
+	return []Value{value}, err
+}
+
+// Evaluates an expression to its first value
+func (s *State) eval(fr *frame, e expr) (Value, error) {
+	switch e := e.(type) {
+	case *constantExpr:
+		return e.value, nil
+	case *localExpr:
+		return fr.slots[e.slot].value, nil
+	case *upvalueExpr:
+		return fr.upvalues[e.index].value, nil
+	case *globalExpr:
+		value := s.globals.Get(e.name)
+		if value == nil && s.StrictGlobals {
+			return nil, s.runtimeError(e.line, "Script attempted to access nonexistent global variable '"+e.name+"'")
+		}
+		return value, nil
+	case *indexExpr:
+		object, err := s.eval(fr, e.object)
+		if err != nil {
+			return nil, err
+		}
+		key, err := s.eval(fr, e.key)
+		if err != nil {
+			return nil, err
+		}
+		return s.index(object, key, e.object, e.line)
+	case *varargExpr, *callExpr, *methodCallExpr:
+		values, err := s.evalMulti(fr, e)
+		if err != nil || len(values) == 0 {
+			return nil, err
+		}
+		return values[0], nil
+	case *parenExpr:
+		return s.eval(fr, e.inner)
+	case *functionExpr:
+		return s.closure(fr, e.proto), nil
+	case *logicalExpr:
+		left, err := s.eval(fr, e.left)
+		if err != nil {
+			return nil, err
+		}
+		if Truthy(left) != e.and {
+			return left, nil
+		}
+		return s.eval(fr, e.right)
+	case *unaryExpr:
+		operand, err := s.eval(fr, e.operand)
+		if err != nil {
+			return nil, err
+		}
+		return s.unary(e, operand)
+	case *binaryExpr:
+		left, err := s.eval(fr, e.left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := s.eval(fr, e.right)
+		if err != nil {
+			return nil, err
+		}
+		return s.binary(e, left, right)
+	case *tableExpr:
+		return s.table(fr, e)
+	}
+	return nil, nil
+}
+
+// Indexes tables, and strings whose fields are the string library
+func (s *State) index(object Value, key Value, objectExpr expr, line int) (Value, error) {
+	switch o := object.(type) {
+	case *Table:
+		return o.Get(key), nil
+	case string:
+		if library, ok := s.globals.Get("string").(*Table); ok {
+			return library.Get(key), nil
+		}
+	}
+	return nil, s.runtimeError(line, "attempt to index "+describe(objectExpr, object))
+}
+
+func (s *State) table(fr *frame, e *tableExpr) (Value, error) {
+	table := NewTable()
+	position := 1
+	for i, field := range e.fields {
+		if field.key != nil {
+			key, err := s.eval(fr, field.key)
+			if err != nil {
+				return nil, err
+			}
+			value, err := s.eval(fr, field.value)
+			if err != nil {
+				return nil, err
+			}
+			if err := table.Set(key, value); err != nil {
+				return nil, s.runtimeError(e.line, err.Error())
+			}
+			continue
+		}
+		values := []Value{nil}
+		var err error
+		if i == len(e.fields)-1 {
+			values, err = s.evalMulti(fr, field.value)
+		} else {
+			values[0], err = s.eval(fr, field.value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range values {
+			table.Set(float64(position), value)
+			position++
+		}
+	}
+	return table, nil
+}
+
+func (s *State) unary(e *unaryExpr, operand Value) (Value, error) {
+	switch e.op {
+	case "not":
+		return !Truthy(operand), nil
+	case "-":
+		if number, ok := ToNumber(operand); ok {
+			return -number, nil
+		}
+		return nil, s.runtimeError(e.line, "attempt to perform arithmetic on "+describe(e.operand, operand))
+	}
+	switch o := operand.(type) {
+	case string:
+		return float64(len(o)), nil
+	case *Table:
+		return float64(o.Len()), nil
+	}
+	return nil, s.runtimeError(e.line, "attempt to get length of "+describe(e.operand, operand))
+}
+
+func (s *State) binary(e *binaryExpr, left Value, right Value) (Value, error) {
+	switch e.op {
+	case "==":
+		return left == right, nil
+	case "~=":
+		return left != right, nil
+	case "<", "<=", ">", ">=":
+		// > and >= are < and <= with swapped operands
+		if e.op == ">" || e.op == ">=" {
+			left, right = right, left
+		}
+		orEqual := e.op == "<=" || e.op == ">="
+		if a, ok := left.(float64); ok {
+			if b, ok := right.(float64); ok {
+				return a < b || (orEqual && a == b), nil
+			}
+		}
+		if a, ok := left.(string); ok {
+			if b, ok := right.(string); ok {
+				return a < b || (orEqual && a == b), nil
+			}
+		}
+		leftType, rightType := TypeName(left), TypeName(right)
+		if leftType == rightType {
+			return nil, s.runtimeError(e.line, "attempt to compare two "+leftType+" values")
+		}
+		return nil, s.runtimeError(e.line, "attempt to compare "+leftType+" with "+rightType)
+	case "..":
+		a, aok := concatOperand(left)
+		b, bok := concatOperand(right)
+		if !aok {
+			return nil, s.runtimeError(e.line, "attempt to concatenate "+describe(e.left, left))
+		}
+		if !bok {
+			return nil, s.runtimeError(e.line, "attempt to concatenate "+describe(e.right, right))
+		}
+		if len(a)+len(b) > MAX_STRING_SIZE {
+			return nil, s.runtimeError(e.line, "string length overflow")
+		}
+		return a + b, nil
+	}
+	a, aok := ToNumber(left)
+	b, bok := ToNumber(right)
+	if !aok {
+		return nil, s.runtimeError(e.line, "attempt to perform arithmetic on "+describe(e.left, left))
+	}
+	if !bok {
+		return nil, s.runtimeError(e.line, "attempt to perform arithmetic on "+describe(e.right, right))
+	}
+	return arithmetic(e.op, a, b), nil
+}
+
+func arithmetic(op string, a float64, b float64) float64 {
+	switch op {
+	case "+":
+		return a + b
+	case "-":
+		return a - b
+	case "*":
+		return a * b
+	case "/":
+		return a / b
+	case "%":
+		// the sign follows the divisor, like Lua
+		return a - math.Floor(a/b)*b
+	}
+	return math.Pow(a, b)
+}
+
+func concatOperand(value Value) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64:
+		return FormatNumber(v), true
+	}
+	return "", false
+}
+
+// Error with the position of the current call, like error() adds at level 1.
+// Functions called from Go, like by pcall, have no position.
+func (s *State) Errorf(message string) error {
+	if s.line == 0 {
+		return &Error{message}
+	}
+	return s.runtimeError(s.line, message)
+}
@@ -0,0 +1,272 @@
+package lua
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenName
+	tokenNumber
+	tokenString
+	// keywords and symbols are kept in text
+	tokenKeyword
+	tokenSymbol
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	number float64
+	line   int
+}
+
+var keywords = map[string]bool{
+	"and": true, "break": true, "do": true, "else": true, "elseif": true, "end": true,
+	"false": true, "for": true, "function": true, "if": true, "in": true, "local": true,
+	"nil": true, "not": true, "or": true, "repeat": true, "return": true, "then": true,
+	"true": true, "until": true, "while": true,
+}
+
+// Symbols of three and two characters, tried before single characters
+var longSymbols = []string{"...", "..", "==", "~=", "<=", ">="}
+
+const singleSymbols = "+-*/%^#<>=(){}[];:,."
+
+// Splits a chunk into tokens
+type lexer struct {
+	chunkName string
+	source    string
+	pos       int
+	line      int
+}
+
+func (l *lexer) errorf(line int, near string, format string, args ...interface{}) error {
+	message := fmt.Sprintf("%s:%d: %s", l.chunkName, line, fmt.Sprintf(format, args...))
+	if near != "" {
+		message += " near '" + near + "'"
+	}
+	return &Error{message}
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// Skips spaces and comments
+func (l *lexer) skipSpace() error {
+	for l.pos < len(l.source) {
+		c := l.source[l.pos]
+		switch {
+		case c == '\n':
+			l.line++
+			l.pos++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\v' || c == '\f':
+			l.pos++
+		case strings.HasPrefix(l.source[l.pos:], "--"):
+			l.pos += 2
+			if level, ok := l.longBracketLevel(); ok {
+				if _, err := l.readLongString(level); err != nil {
+					return err
+				}
+				continue
+			}
+			for l.pos < len(l.source) && l.source[l.pos] != '\n' {
+				l.pos++
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// Level of a long bracket like [==[ starting at the current position
+func (l *lexer) longBracketLevel() (int, bool) {
+	if l.pos >= len(l.source) || l.source[l.pos] != '[' {
+		return 0, false
+	}
+	level := 0
+	for l.pos+1+level < len(l.source) && l.source[l.pos+1+level] == '=' {
+		level++
+	}
+	if l.pos+1+level < len(l.source) && l.source[l.pos+1+level] == '[' {
+		return level, true
+	}
+	return 0, false
+}
+
+// Reads a long string or comment whose opening bracket of level is at the
+// current position. A first newline is skipped.
+func (l *lexer) readLongString(level int) (string, error) {
+	line := l.line
+	l.pos += level + 2
+	if strings.HasPrefix(l.source[l.pos:], "\r\n") {
+		l.pos += 2
+		l.line++
+	} else if strings.HasPrefix(l.source[l.pos:], "\n") {
+		l.pos++
+		l.line++
+	}
+	closing := "]" + strings.Repeat("=", level) + "]"
+	end := strings.Index(l.source[l.pos:], closing)
+	if end < 0 {
+		l.pos = len(l.source)
+		return "", l.errorf(line, "<eof>", "unfinished long string")
+	}
+	text := l.source[l.pos : l.pos+end]
+	l.line += strings.Count(text, "\n")
+	l.pos += end + len(closing)
+	return text, nil
+}
+
+func (l *lexer) next() (token, error) {
+	if err := l.skipSpace(); err != nil {
+		return token{}, err
+	}
+	if l.pos >= len(l.source) {
+		return token{kind: tokenEOF, text: "<eof>", line: l.line}, nil
+	}
+	c := l.source[l.pos]
+	start := l.pos
+	switch {
+	case isLetter(c):
+		for l.pos < len(l.source) && (isLetter(l.source[l.pos]) || isDigit(l.source[l.pos])) {
+			l.pos++
+		}
+		text := l.source[start:l.pos]
+		if keywords[text] {
+			return token{kind: tokenKeyword, text: text, line: l.line}, nil
+		}
+		return token{kind: tokenName, text: text, line: l.line}, nil
+	case isDigit(c) || (c == '.' && l.pos+1 < len(l.source) && isDigit(l.source[l.pos+1])):
+		return l.readNumber()
+	case c == '"' || c == '\'':
+		return l.readString(c)
+	case c == '[':
+		if level, ok := l.longBracketLevel(); ok {
+			line := l.line
+			text, err := l.readLongString(level)
+			return token{kind: tokenString, text: text, line: line}, err
+		}
+	}
+	for _, symbol := range longSymbols {
+		if strings.HasPrefix(l.source[l.pos:], symbol) {
+			l.pos += len(symbol)
+			return token{kind: tokenSymbol, text: symbol, line: l.line}, nil
+		}
+	}
+	if strings.IndexByte(singleSymbols, c) >= 0 {
+		l.pos++
+		return token{kind: tokenSymbol, text: string(c), line: l.line}, nil
+	}
+	return token{}, l.errorf(l.line, string(c), "unexpected symbol")
+}
+
+func (l *lexer) readNumber() (token, error) {
+	start := l.pos
+	if strings.HasPrefix(l.source[l.pos:], "0x") || strings.HasPrefix(l.source[l.pos:], "0X") {
+		l.pos += 2
+	}
+	for l.pos < len(l.source) {
+		c := l.source[l.pos]
+		if (c == '+' || c == '-') && (l.source[l.pos-1] == 'e' || l.source[l.pos-1] == 'E') &&
+			!strings.HasPrefix(l.source[start:], "0x") && !strings.HasPrefix(l.source[start:], "0X") {
+			l.pos++
+			continue
+		}
+		if !isLetter(c) && !isDigit(c) && c != '.' {
+			break
+		}
+		l.pos++
+	}
+	text := l.source[start:l.pos]
+	number, ok := parseNumber(text)
+	if !ok {
+		return token{}, l.errorf(l.line, text, "malformed number")
+	}
+	return token{kind: tokenNumber, text: text, number: number, line: l.line}, nil
+}
+
+func (l *lexer) readString(quote byte) (token, error) {
+	line := l.line
+	l.pos++
+	var builder strings.Builder
+	for {
+		if l.pos >= len(l.source) {
+			return token{}, l.errorf(line, "<eof>", "unfinished string")
+		}
+		c := l.source[l.pos]
+		if c == quote {
+			l.pos++
+			return token{kind: tokenString, text: builder.String(), line: line}, nil
+		}
+		if c == '\n' {
+			return token{}, l.errorf(line, builder.String(), "unfinished string")
+		}
+		if c != '\\' {
+			builder.WriteByte(c)
+			l.pos++
+			continue
+		}
+		l.pos++
+		if l.pos >= len(l.source) {
+			return token{}, l.errorf(line, "<eof>", "unfinished string")
+		}
+		escape := l.source[l.pos]
+		l.pos++
+		switch escape {
+		case 'n':
+			builder.WriteByte('\n')
+		case 't':
+			builder.WriteByte('\t')
+		case 'r':
+			builder.WriteByte('\r')
+		case 'a':
+			builder.WriteByte('\a')
+		case 'b':
+			builder.WriteByte('\b')
+		case 'f':
+			builder.WriteByte('\f')
+		case 'v':
+			builder.WriteByte('\v')
+		case '\n':
+			builder.WriteByte('\n')
+			l.line++
+		case 'x':
+			if l.pos+2 > len(l.source) {
+				return token{}, l.errorf(line, "\\x", "hexadecimal digit expected")
+			}
+			value, err := strconv.ParseUint(l.source[l.pos:l.pos+2], 16, 8)
+			if err != nil {
+				return token{}, l.errorf(line, "\\x"+l.source[l.pos:l.pos+2], "hexadecimal digit expected")
+			}
+			builder.WriteByte(byte(value))
+			l.pos += 2
+		default:
+			if !isDigit(escape) {
+				builder.WriteByte(escape)
+				continue
+			}
+			// up to three decimal digits
+			end := l.pos - 1
+			for end < len(l.source) && end < l.pos+2 && isDigit(l.source[end]) {
+				end++
+			}
+			value, _ := strconv.Atoi(l.source[l.pos-1 : end])
+			if value > 255 {
+				return token{}, l.errorf(line, "\\"+l.source[l.pos-1:end], "escape sequence too large")
+			}
+			builder.WriteByte(byte(value))
+			l.pos = end
+		}
+	}
+}
@@ -0,0 +1,551 @@
+package lua
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func argError(state *State, n int, name string, message string) error {
+	return state.Errorf("bad argument #" + strconv.Itoa(n) + " to '" + name + "' (" + message + ")")
+}
+
+func typeError(state *State, args []Value, n int, name string, expected string) error {
+	got := "no value"
+	if n <= len(args) {
+		got = TypeName(args[n-1])
+	}
+	return argError(state, n, name, expected+" expected, got "+got)
+}
+
+func arg(args []Value, n int) Value {
+	if n <= len(args) {
+		return args[n-1]
+	}
+	return nil
+}
+
+func CheckTable(state *State, args []Value, n int, name string) (*Table, error) {
+	if table, ok := arg(args, n).(*Table); ok {
+		return table, nil
+	}
+	return nil, typeError(state, args, n, name, "table")
+}
+
+func CheckNumber(state *State, args []Value, n int, name string) (float64, error) {
+	if number, ok := ToNumber(arg(args, n)); ok {
+		return number, nil
+	}
+	return 0, typeError(state, args, n, name, "number")
+}
+
+func CheckInt(state *State, args []Value, n int, name string) (int, error) {
+	number, err := CheckNumber(state, args, n, name)
+	if number > math.MaxInt32 {
+		return math.MaxInt32, err
+	}
+	if number < math.MinInt32 {
+		return math.MinInt32, err
+	}
+	return int(number), err
+}
+
+func OptInt(state *State, args []Value, n int, name string, defaultValue int) (int, error) {
+	if arg(args, n) == nil {
+		return defaultValue, nil
+	}
+	return CheckInt(state, args, n, name)
+}
+
+// Strings, and numbers converted to strings
+func CheckString(state *State, args []Value, n int, name string) (string, error) {
+	switch value := arg(args, n).(type) {
+	case string:
+		return value, nil
+	case float64:
+		return FormatNumber(value), nil
+	}
+	return "", typeError(state, args, n, name, "string")
+}
+
+// Library tables can't be changed when globals are strict
+func checkWritable(state *State, table *Table) error {
+	if state.StrictGlobals && table.readonly {
+		return state.Errorf("Attempt to modify a readonly table")
+	}
+	return nil
+}
+
+func register(table *Table, functions map[string]func(state *State, args []Value) ([]Value, error)) {
+	for name, fn := range functions {
+		table.Set(name, &GoFunction{name, fn})
+	}
+}
+
+func openBaseLibrary(state *State) {
+	base := NewTable()
+	register(base, map[string]func(state *State, args []Value) ([]Value, error){
+		"assert":   luaAssert,
+		"error":    luaError,
+		"ipairs":   luaIpairs,
+		"next":     luaNext,
+		"pairs":    luaPairs,
+		"pcall":    luaPcall,
+		"rawequal": luaRawequal,
+		"rawget":   luaRawget,
+		"rawset":   luaRawset,
+		"select":   luaSelect,
+		"tonumber": luaTonumber,
+		"tostring": luaTostring,
+		"type":     luaType,
+		"unpack":   luaUnpack,
+	})
+	for key, value, ok, _ := base.Next(nil); ok; key, value, ok, _ = base.Next(key) {
+		state.globals.Set(key, value)
+	}
+	state.globals.Set("_VERSION", "Lua 5.1")
+
+	table := NewTable()
+	register(table, map[string]func(state *State, args []Value) ([]Value, error){
+		"concat": tableConcat,
+		"insert": tableInsert,
+		"remove": tableRemove,
+		"sort":   tableSort,
+		"unpack": luaUnpack,
+		"getn": func(state *State, args []Value) ([]Value, error) {
+			t, err := CheckTable(state, args, 1, "getn")
+			if err != nil {
+				return nil, err
+			}
+			return []Value{float64(t.Len())}, nil
+		},
+	})
+	state.globals.Set("table", table)
+	state.globals.Set("string", stringLibrary())
+	state.globals.Set("math", mathLibrary())
+	for _, name := range []string{"table", "string", "math"} {
+		state.globals.Get(name).(*Table).SetReadonly()
+	}
+}
+
+func luaAssert(state *State, args []Value) ([]Value, error) {
+	if Truthy(arg(args, 1)) {
+		return args, nil
+	}
+	if len(args) >= 2 {
+		return nil, &Error{args[1]}
+	}
+	return nil, state.Errorf("assertion failed!")
+}
+
+// error(message [, level]) adds the position of the call to string messages unless level is 0
+func luaError(state *State, args []Value) ([]Value, error) {
+	message := arg(args, 1)
+	level, err := OptInt(state, args, 2, "error", 1)
+	if err != nil {
+		return nil, err
+	}
+	if text, ok := message.(string); ok && level > 0 {
+		return nil, state.Errorf(text)
+	}
+	return nil, &Error{message}
+}
+
+func luaIpairs(state *State, args []Value) ([]Value, error) {
+	table, err := CheckTable(state, args, 1, "ipairs")
+	if err != nil {
+		return nil, err
+	}
+	iterator := &GoFunction{"ipairs_iterator", func(state *State, args []Value) ([]Value, error) {
+		index, _ := ToNumber(arg(args, 2))
+		value := table.Get(index + 1)
+		if value == nil {
+			return []Value{nil}, nil
+		}
+		return []Value{index + 1, value}, nil
+	}}
+	return []Value{iterator, table, float64(0)}, nil
+}
+
+func luaNext(state *State, args []Value) ([]Value, error) {
+	table, err := CheckTable(state, args, 1, "next")
+	if err != nil {
+		return nil, err
+	}
+	key, value, ok, err := table.Next(arg(args, 2))
+	if err != nil {
+		return nil, state.Errorf(err.Error())
+	}
+	if !ok {
+		return []Value{nil}, nil
+	}
+	return []Value{key, value}, nil
+}
+
+func luaPairs(state *State, args []Value) ([]Value, error) {
+	table, err := CheckTable(state, args, 1, "pairs")
+	if err != nil {
+		return nil, err
+	}
+	return []Value{state.globals.Get("next"), table, nil}, nil
+}
+
+// Errors raised by the function are returned, but not running out of time
+func luaPcall(state *State, args []Value) ([]Value, error) {
+	if len(args) == 0 {
+		return nil, argError(state, 1, "pcall", "value expected")
+	}
+	line := state.line
+	state.line = 0
+	results, err := state.call(args[0], args[1:], nil)
+	state.line = line
+	if err != nil {
+		if scriptError, ok := err.(*Error); ok {
+			return []Value{false, scriptError.Value}, nil
+		}
+		return nil, err
+	}
+	return append([]Value{true}, results...), nil
+}
+
+func luaRawequal(state *State, args []Value) ([]Value, error) {
+	return []Value{arg(args, 1) == arg(args, 2)}, nil
+}
+
+func luaRawget(state *State, args []Value) ([]Value, error) {
+	table, err := CheckTable(state, args, 1, "rawget")
+	if err != nil {
+		return nil, err
+	}
+	return []Value{table.Get(arg(args, 2))}, nil
+}
+
+func luaRawset(state *State, args []Value) ([]Value, error) {
+	table, err := CheckTable(state, args, 1, "rawset")
+	if err != nil {
+		return nil, err
+	}
+	if err := checkWritable(state, table); err != nil {
+		return nil, err
+	}
+	if err := table.Set(arg(args, 2), arg(args, 3)); err != nil {
+		return nil, state.Errorf(err.Error())
+	}
+	return []Value{table}, nil
+}
+
+func luaSelect(state *State, args []Value) ([]Value, error) {
+	if text, ok := arg(args, 1).(string); ok && text == "#" {
+		return []Value{float64(len(args) - 1)}, nil
+	}
+	n, err := CheckInt(state, args, 1, "select")
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		n = len(args) + n
+	}
+	if n < 1 {
+		return nil, argError(state, 1, "select", "index out of range")
+	}
+	if n >= len(args) {
+		return nil, nil
+	}
+	return args[n:], nil
+}
+
+func luaTonumber(state *State, args []Value) ([]Value, error) {
+	base, err := OptInt(state, args, 2, "tonumber", 10)
+	if err != nil {
+		return nil, err
+	}
+	if base == 10 {
+		if number, ok := ToNumber(arg(args, 1)); ok {
+			return []Value{number}, nil
+		}
+		return []Value{nil}, nil
+	}
+	if base < 2 || base > 36 {
+		return nil, argError(state, 2, "tonumber", "base out of range")
+	}
+	text, err := CheckString(state, args, 1, "tonumber")
+	if err != nil {
+		return nil, err
+	}
+	value, err := strconv.ParseInt(strings.TrimSpace(text), base, 64)
+	if err != nil {
+		return []Value{nil}, nil
+	}
+	return []Value{float64(value)}, nil
+}
+
+func luaTostring(state *State, args []Value) ([]Value, error) {
+	if len(args) == 0 {
+		return nil, argError(state, 1, "tostring", "value expected")
+	}
+	return []Value{ToString(args[0])}, nil
+}
+
+func luaType(state *State, args []Value) ([]Value, error) {
+	if len(args) == 0 {
+		return nil, argError(state, 1, "type", "value expected")
+	}
+	return []Value{TypeName(args[0])}, nil
+}
+
+// unpack(list [, i [, j]]) returns list[i] to list[j]
+func luaUnpack(state *State, args []Value) ([]Value, error) {
+	table, err := CheckTable(state, args, 1, "unpack")
+	if err != nil {
+		return nil, err
+	}
+	first, err := OptInt(state, args, 2, "unpack", 1)
+	if err != nil {
+		return nil, err
+	}
+	last, err := OptInt(state, args, 3, "unpack", table.Len())
+	if err != nil {
+		return nil, err
+	}
+	if last-first >= 8000 {
+		return nil, state.Errorf("too many results to unpack")
+	}
+	var values []Value
+	for i := first; i <= last; i++ {
+		values = append(values, table.Get(float64(i)))
+	}
+	return values, nil
+}
+
+func tableConcat(state *State, args []Value) ([]Value, error) {
+	table, err := CheckTable(state, args, 1, "concat")
+	if err != nil {
+		return nil, err
+	}
+	separator := ""
+	if arg(args, 2) != nil {
+		if separator, err = CheckString(state, args, 2, "concat"); err != nil {
+			return nil, err
+		}
+	}
+	first, err := OptInt(state, args, 3, "concat", 1)
+	if err != nil {
+		return nil, err
+	}
+	last, err := OptInt(state, args, 4, "concat", table.Len())
+	if err != nil {
+		return nil, err
+	}
+	var builder strings.Builder
+	for i := first; i <= last; i++ {
+		text, ok := concatOperand(table.Get(float64(i)))
+		if !ok {
+			return nil, state.Errorf("invalid value (at index " + strconv.Itoa(i) + ") in table for 'concat'")
+		}
+		if builder.Len()+len(text)+len(separator) > MAX_STRING_SIZE {
+			return nil, state.Errorf("string length overflow")
+		}
+		builder.WriteString(text)
+		if i < last {
+			builder.WriteString(separator)
+		}
+	}
+	return []Value{builder.String()}, nil
+}
+
+// table.insert(list, [pos,] value)
+func tableInsert(state *State, args []Value) ([]Value, error) {
+	table, err := CheckTable(state, args, 1, "insert")
+	if err != nil {
+		return nil, err
+	}
+	if err := checkWritable(state, table); err != nil {
+		return nil, err
+	}
+	length := table.Len()
+	switch len(args) {
+	case 2:
+		return nil, table.Set(float64(length+1), args[1])
+	case 3:
+		position, err := CheckInt(state, args, 2, "insert")
+		if err != nil {
+			return nil, err
+		}
+		for i := length; i >= position; i-- {
+			table.Set(float64(i+1), table.Get(float64(i)))
+		}
+		return nil, table.Set(float64(position), args[2])
+	}
+	return nil, state.Errorf("wrong number of arguments to 'insert'")
+}
+
+// table.remove(list [, pos]) removes and returns list[pos], the last element by default
+func tableRemove(state *State, args []Value) ([]Value, error) {
+	table, err := CheckTable(state, args, 1, "remove")
+	if err != nil {
+		return nil, err
+	}
+	if err := checkWritable(state, table); err != nil {
+		return nil, err
+	}
+	length := table.Len()
+	position, err := OptInt(state, args, 2, "remove", length)
+	if err != nil {
+		return nil, err
+	}
+	if length == 0 {
+		return []Value{nil}, nil
+	}
+	removed := table.Get(float64(position))
+	for i := position; i < length; i++ {
+		table.Set(float64(i), table.Get(float64(i+1)))
+	}
+	table.Set(float64(length), nil)
+	return []Value{removed}, nil
+}
+
+// table.sort(list [, comp]) sorts in place with < or comp
+func tableSort(state *State, args []Value) ([]Value, error) {
+	table, err := CheckTable(state, args, 1, "sort")
+	if err != nil {
+		return nil, err
+	}
+	if err := checkWritable(state, table); err != nil {
+		return nil, err
+	}
+	comparator := arg(args, 2)
+	values := make([]Value, table.Len())
+	for i := range values {
+		values[i] = table.Get(float64(i + 1))
+	}
+	var sortErr error
+	less := &binaryExpr{op: "<", line: state.line}
+	sort.SliceStable(values, func(i, j int) bool {
+		if sortErr != nil {
+			return false
+		}
+		if comparator != nil {
+			results, err := state.call(comparator, []Value{values[i], values[j]}, nil)
+			if err != nil {
+				sortErr = err
+				return false
+			}
+			return len(results) > 0 && Truthy(results[0])
+		}
+		result, err := state.binary(less, values[i], values[j])
+		if err != nil {
+			sortErr = err
+			return false
+		}
+		return result.(bool)
+	})
+	if sortErr != nil {
+		return nil, sortErr
+	}
+	for i, value := range values {
+		table.Set(float64(i+1), value)
+	}
+	return nil, nil
+}
+
+func mathLibrary() *Table {
+	library := NewTable()
+	unary := map[string]func(float64) float64{
+		"abs": math.Abs, "ceil": math.Ceil, "floor": math.Floor, "sqrt": math.Sqrt,
+		"exp": math.Exp, "log10": math.Log10, "sin": math.Sin, "cos": math.Cos, "tan": math.Tan,
+		"asin": math.Asin, "acos": math.Acos, "atan": math.Atan,
+		"deg": func(x float64) float64 { return x * 180 / math.Pi },
+		"rad": func(x float64) float64 { return x * math.Pi / 180 },
+	}
+	for name, fn := range unary {
+		name, fn := name, fn
+		library.Set(name, &GoFunction{name, func(state *State, args []Value) ([]Value, error) {
+			x, err := CheckNumber(state, args, 1, name)
+			return []Value{fn(x)}, err
+		}})
+	}
+	// a fixed seed like redis, so scripts behave the same every time
+	random := rand.New(rand.NewSource(0))
+	register(library, map[string]func(state *State, args []Value) ([]Value, error){
+		"fmod": func(state *State, args []Value) ([]Value, error) {
+			x, err := CheckNumber(state, args, 1, "fmod")
+			if err != nil {
+				return nil, err
+			}
+			y, err := CheckNumber(state, args, 2, "fmod")
+			return []Value{math.Mod(x, y)}, err
+		},
+		"log": func(state *State, args []Value) ([]Value, error) {
+			x, err := CheckNumber(state, args, 1, "log")
+			return []Value{math.Log(x)}, err
+		},
+		"max": func(state *State, args []Value) ([]Value, error) {
+			return extremum(state, args, "max", func(a, b float64) bool { return a > b })
+		},
+		"min": func(state *State, args []Value) ([]Value, error) {
+			return extremum(state, args, "min", func(a, b float64) bool { return a < b })
+		},
+		"modf": func(state *State, args []Value) ([]Value, error) {
+			x, err := CheckNumber(state, args, 1, "modf")
+			integer, fraction := math.Modf(x)
+			return []Value{integer, fraction}, err
+		},
+		"pow": func(state *State, args []Value) ([]Value, error) {
+			x, err := CheckNumber(state, args, 1, "pow")
+			if err != nil {
+				return nil, err
+			}
+			y, err := CheckNumber(state, args, 2, "pow")
+			return []Value{math.Pow(x, y)}, err
+		},
+		// random() in [0,1), random(m) in [1,m] and random(m, n) in [m,n]
+		"random": func(state *State, args []Value) ([]Value, error) {
+			if len(args) == 0 {
+				return []Value{random.Float64()}, nil
+			}
+			low, high := 1, 0
+			var err error
+			if len(args) == 1 {
+				high, err = CheckInt(state, args, 1, "random")
+			} else {
+				if low, err = CheckInt(state, args, 1, "random"); err == nil {
+					high, err = CheckInt(state, args, 2, "random")
+				}
+			}
+			if err != nil {
+				return nil, err
+			}
+			if low > high {
+				return nil, argError(state, len(args), "random", "interval is empty")
+			}
+			return []Value{float64(low + random.Intn(high-low+1))}, nil
+		},
+		"randomseed": func(state *State, args []Value) ([]Value, error) {
+			seed, err := CheckNumber(state, args, 1, "randomseed")
+			random.Seed(int64(seed))
+			return nil, err
+		},
+	})
+	library.Set("pi", math.Pi)
+	library.Set("huge", math.Inf(1))
+	return library
+}
+
+func extremum(state *State, args []Value, name string, better func(a, b float64) bool) ([]Value, error) {
+	result, err := CheckNumber(state, args, 1, name)
+	if err != nil {
+		return nil, err
+	}
+	for i := 2; i <= len(args); i++ {
+		number, err := CheckNumber(state, args, i, name)
+		if err != nil {
+			return nil, err
+		}
+		if better(number, result) {
+			result = number
+		}
+	}
+	return []Value{result}, nil
+}
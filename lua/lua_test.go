@@ -0,0 +1,198 @@
+package lua
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func run(t *testing.T, script string) (string, error) {
+	chunk, err := Compile("test", script)
+	if err != nil {
+		return "", err
+	}
+	results, err := NewState().Run(chunk)
+	texts := make([]string, len(results))
+	for i, result := range results {
+		texts[i] = ToString(result)
+	}
+	return strings.Join(texts, ","), err
+}
+
+func TestRun(t *testing.T) {
+	cases := []struct {
+		script, expected string
+	}{
+		{"return 1 + 2 * 3, 2 ^ 3 ^ 2, -2 ^ 2, 7 % 3, -7 % 3, 10 / 4", "7,512,-4,1,2,2.5"},
+		{"return 1 .. 2, 'a' .. 'b' .. 'c', #'hello', '10' + 5", "12,abc,5,15"},
+		{"return 1 < 2, 'a' < 'b', 1 == 1.0, 'x' ~= 'x', not nil", "true,true,true,false,true"},
+		{"return nil or 'default', false and 1, 1 and 2, nil and nil", "default,false,2,nil"},
+		{"local t = {1, 2, 3, x = 'y', [10] = 'z'} return #t, t.x, t[10], t[4]", "3,y,z,nil"},
+		{"local s = 0 for i = 1, 10 do s = s + i end return s", "55"},
+		{"local s = 0 for i = 10, 1, -3 do s = s + i end return s", "22"},
+		{"local t = {} for i, v in ipairs({'a', 'b'}) do t[#t + 1] = i .. v end return table.concat(t, ' ')", "1a 2b"},
+		{"local n = 0 for k, v in pairs({a = 1, b = 2, 3}) do n = n + v end return n", "6"},
+		{"local i = 0 while true do i = i + 1 if i > 5 then break end end return i", "6"},
+		{"local i = 0 repeat local j = i i = i + 1 until j >= 3 return i", "4"},
+		{"local function fact(n) if n <= 1 then return 1 end return n * fact(n - 1) end return fact(10)", "3628800"},
+		{"local function counter() local c = 0 return function() c = c + 1 return c end end local f = counter() f() return f(), counter()()", "2,1"},
+		{"local fs = {} for i = 1, 3 do fs[i] = function() return i end end return fs[1](), fs[3]()", "1,3"},
+		{"local function f(...) return select('#', ...), ... end return f(1, nil, 3)", "3,1,nil,3"},
+		{"local function f() return 1, 2 end local t = {f(), f()} return #t, (f())", "3,1"},
+		{"local a, b, c = (function() return 1, 2, 3 end)() return c, b, a", "3,2,1"},
+		{"local a, b = 1, 2 a, b = b, a return a, b", "2,1"},
+		{"local t = {name = 'n'} function t.get(self) return self.name end return t:get()", "n"},
+		{"return tostring(nil), tostring(1.5), tonumber('0x10'), tonumber('z', 36), tonumber('x')", "nil,1.5,16,35,nil"},
+		{"return type(1), type('s'), type({}), type(print), type(type)", "number,string,table,nil,function"},
+		{"return pcall(error, 'boom')", "false,boom"},
+		{"local ok, err = pcall(function() error({code = 1}) end) return ok, err.code", "false,1"},
+		{"local ok, err = pcall(function() local x = nil return x.y end) return ok, err", "false,test:1: attempt to index local 'x' (a nil value)"},
+		{"return select(2, pcall(error))", "nil"},
+		{"local t = {3, 1, 2} table.sort(t) return t[1], t[2], t[3]", "1,2,3"},
+		{"local t = {3, 1, 2} table.sort(t, function(a, b) return a > b end) return table.concat(t, ',')", "3,2,1"},
+		{"local t = {1, 2} table.insert(t, 3) table.insert(t, 1, 0) return table.concat(t), table.remove(t), table.remove(t, 1), #t", "0123,3,0,2"},
+		{"return unpack({1, 2, 3})", "1,2,3"},
+		{"return math.max(1, 5, 3), math.min(4, 2), math.floor(3.7), math.huge, -math.huge", "5,2,3,inf,-inf"},
+		{"return string.format('%d %5.2f %s %q %x %%', 3.9, 3.14159, 'x', 'a\\nb', 255)", "3  3.14 x \"a\\\nb\" ff %"},
+		{"return ('hello'):upper(), string.sub('hello', 2, -2), ('abc'):rep(2), ('abc'):reverse()", "HELLO,ell,abcabc,cba"},
+		{"return string.byte('A'), string.char(104, 105), #string.rep('ab', 3, '')", "65,hi,6"},
+		{"return string.find('hello world', 'o w'), string.find('hello', 'l+'), string.find('a.b', '.', 1, true)", "5,3,2,2"},
+		{"return string.match('key=value', '(%w+)=(%w+)')", "key,value"},
+		{"return string.match('  trim  ', '^%s*(.-)%s*$'), string.match('abc', '()b()')", "trim,2,3"},
+		{"return string.gsub('hello world', 'o', '0')", "hell0 w0rld,2"},
+		{"return string.gsub('hello world', '(%w+)', '<%1>')", "<hello> <world>,2"},
+		{"return string.gsub('abc', '%w', function(c) return c:upper() .. '.' end)", "A.B.C.,3"},
+		{"return string.gsub('$name is $age', '%$(%w+)', {name = 'bob', age = 3})", "bob is 3,2"},
+		{"return string.gsub('abc', '', '-')", "-a-b-c-,4"},
+		{"local t = {} for w in string.gmatch('one two three', '%a+') do t[#t + 1] = w end return table.concat(t, '|')", "one|two|three"},
+		{"return string.match('f(a(b)c)d', '%b()'), string.find('THE (quick) fox', '%f[%a]%a+', 5)", "(a(b)c),6,10"},
+		{"return string.match('xaax', '(a)%1'), string.match('2024-01-02', '(%d+)-(%d+)-(%d+)')", "a,2024,01,02"},
+		{"return [[long\nstring]], [==[with ]] inside]==] -- comment\n--[[ block\ncomment ]]", "long\nstring,with ]] inside"},
+		{"return '\\65\\066\\x43', 0x1F, 1e2, .5", "ABC,31,100,0.5"},
+	}
+	for _, c := range cases {
+		result, err := run(t, c.script)
+		if err != nil {
+			t.Errorf("Ran: %v. Unexpected error %v", c.script, err)
+			continue
+		}
+		if result != c.expected {
+			t.Errorf("Ran: %v. Expected: %q but Got result: %q", c.script, c.expected, result)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	cases := []struct {
+		script, expected string
+	}{
+		{"return 1 +", "test:1: unexpected symbol near '<eof>'"},
+		{"x = = 1", "test:1: unexpected symbol near '='"},
+		{"return 'unfinished", "test:1: unfinished string near '<eof>'"},
+		{"for i = 1, 2 do end break", "test:1: no loop to break near 'break'"},
+		{"local t = nil\nreturn t.x", "test:2: attempt to index local 't' (a nil value)"},
+		{"return {} + 1", "test:1: attempt to perform arithmetic on a table value"},
+		{"return 'a' < 1", "test:1: attempt to compare string with number"},
+		{"return #nil", "test:1: attempt to get length of a nil value"},
+		{"undefined()", "test:1: attempt to call global 'undefined' (a nil value)"},
+		{"error('custom')", "test:1: custom"},
+		{"error('plain', 0)", "plain"},
+		{"return ('x'):rep()", "test:1: bad argument #2 to 'rep' (number expected, got no value)"},
+		{"return string.find('a', '[a')", "test:1: malformed pattern (missing ']')"},
+		{"local function f() return f() + 1 end return f()", "test:1: stack overflow"},
+		{"local t = {} t[nil] = 1", "test:1: table index is nil"},
+		{"local s = ('x'):rep(300 * 1024 * 1024) return s .. s", "test:1: string length overflow"},
+		{"local s = ('x'):rep(300 * 1024 * 1024) return table.concat({s, s})", "test:1: string length overflow"},
+		{"local s = ('x'):rep(300 * 1024 * 1024) return string.format('%s%s', s, s)", "test:1: string length overflow"},
+		{"return string.format('%999999999d', 1)", "test:1: invalid format (width or precision too long)"},
+	}
+	for _, c := range cases {
+		_, err := run(t, c.script)
+		if err == nil || err.Error() != c.expected {
+			t.Errorf("Ran: %v. Expected error: %q but Got: %v", c.script, c.expected, err)
+		}
+	}
+}
+
+func TestSyntaxLevels(t *testing.T) {
+	nested := strings.Repeat("(", 3000000) + "1" + strings.Repeat(")", 3000000)
+	cases := []string{
+		"return " + nested,
+		"return " + strings.Repeat("not ", 3000000) + "1",
+		"return " + strings.Repeat("{", 3000000),
+		strings.Repeat("do ", 3000000),
+	}
+	for _, script := range cases {
+		if _, err := Compile("test", script); err == nil || !strings.Contains(err.Error(), "chunk has too many syntax levels") {
+			t.Errorf("Expected too many syntax levels for %.20s... but Got: %v", script, err)
+		}
+	}
+	deep := strings.Repeat("(", MAX_SYNTAX_LEVELS-10) + "1" + strings.Repeat(")", MAX_SYNTAX_LEVELS-10)
+	if result, err := run(t, "return "+deep); err != nil || result != "1" {
+		t.Errorf("Expected 1 but Got: %v %v", result, err)
+	}
+}
+
+func TestStrictGlobals(t *testing.T) {
+	state := NewState()
+	state.SetGlobal("KEYS", NewArray([]Value{"k"}))
+	state.StrictGlobals = true
+	cases := []struct {
+		script, expected string
+	}{
+		{"return KEYS[1]", ""},
+		{"return missing", "Script attempted to access nonexistent global variable 'missing'"},
+		{"created = 1", "Script attempted to create global variable 'created'"},
+		{"string.x = 1", "Attempt to modify a readonly table"},
+		{"table.insert(math, 1)", "Attempt to modify a readonly table"},
+	}
+	for _, c := range cases {
+		chunk, err := Compile("strict", c.script)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = state.Run(chunk)
+		if c.expected == "" && err != nil {
+			t.Errorf("Ran: %v. Unexpected error %v", c.script, err)
+		}
+		if c.expected != "" && (err == nil || !strings.Contains(err.Error(), c.expected)) {
+			t.Errorf("Ran: %v. Expected error: %q but Got: %v", c.script, c.expected, err)
+		}
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	chunk, err := Compile("loop", "local ok = pcall(function() while true do end end) return ok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := NewState()
+	state.Deadline = time.Now().Add(50 * time.Millisecond)
+	if _, err := state.Run(chunk); err != ErrTimeout {
+		t.Errorf("Expected pcall not to catch the timeout but got %v", err)
+	}
+}
+
+func TestGoFunctions(t *testing.T) {
+	state := NewState()
+	state.SetGlobal("add", &GoFunction{"add", func(state *State, args []Value) ([]Value, error) {
+		a, err := CheckNumber(state, args, 1, "add")
+		if err != nil {
+			return nil, err
+		}
+		b, err := CheckNumber(state, args, 2, "add")
+		return []Value{a + b}, err
+	}})
+	chunk, err := Compile("go", "local f = ... return add(f, 2), pcall(add, 'x')")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := state.Run(chunk, 40.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || results[0] != 42.0 || results[1] != false ||
+		results[2] != "bad argument #1 to 'add' (number expected, got string)" {
+		t.Errorf("Unexpected results %v", results)
+	}
+}
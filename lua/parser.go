@@ -0,0 +1,880 @@
+package lua
+
+import (
+	"strconv"
+)
+
+// Names in scope while parsing a function
+type functionState struct {
+	parent   *functionState
+	proto    *prototype
+	scopes   []map[string]int
+	upvalues map[upvalueDesc]int
+	// loops around the statement being parsed, break needs one
+	loops int
+}
+
+func (fs *functionState) openScope() {
+	fs.scopes = append(fs.scopes, make(map[string]int))
+}
+
+func (fs *functionState) closeScope() {
+	fs.scopes = fs.scopes[:len(fs.scopes)-1]
+}
+
+func (fs *functionState) declareLocal(name string) int {
+	slot := fs.proto.numSlots
+	fs.proto.numSlots++
+	fs.scopes[len(fs.scopes)-1][name] = slot
+	return slot
+}
+
+// Resolves name to a local, an upvalue captured from an enclosing function or,
+// when none declares it, nil for a global
+func (fs *functionState) resolve(name string) expr {
+	for i := len(fs.scopes) - 1; i >= 0; i-- {
+		if slot, ok := fs.scopes[i][name]; ok {
+			return &localExpr{name, slot}
+		}
+	}
+	if fs.parent == nil {
+		return nil
+	}
+	switch outer := fs.parent.resolve(name).(type) {
+	case *localExpr:
+		return &upvalueExpr{name, fs.addUpvalue(upvalueDesc{true, outer.slot})}
+	case *upvalueExpr:
+		return &upvalueExpr{name, fs.addUpvalue(upvalueDesc{false, outer.index})}
+	}
+	return nil
+}
+
+func (fs *functionState) addUpvalue(desc upvalueDesc) int {
+	if index, ok := fs.upvalues[desc]; ok {
+		return index
+	}
+	index := len(fs.proto.upvalues)
+	fs.proto.upvalues = append(fs.proto.upvalues, desc)
+	fs.upvalues[desc] = index
+	return index
+}
+
+type parser struct {
+	lexer   *lexer
+	current token
+	fs      *functionState
+	// statements and expressions being parsed inside each other
+	levels int
+}
+
+// Parses source into a chunk which runs as a function taking any arguments.
+// chunkName prefixes the positions in error messages.
+func Compile(chunkName string, source string) (*Chunk, error) {
+	p := &parser{lexer: &lexer{chunkName: chunkName, source: source, line: 1}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	proto := &prototype{chunkName: chunkName, name: "main chunk", isVararg: true}
+	p.openFunction(proto)
+	body, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+	if p.current.kind != tokenEOF {
+		return nil, p.errorNear("'<eof>' expected")
+	}
+	proto.body = body
+	p.closeFunction()
+	return &Chunk{proto}, nil
+}
+
+func (p *parser) openFunction(proto *prototype) {
+	p.fs = &functionState{parent: p.fs, proto: proto, upvalues: make(map[upvalueDesc]int)}
+	p.fs.openScope()
+}
+
+func (p *parser) closeFunction() {
+	p.fs = p.fs.parent
+}
+
+func (p *parser) advance() error {
+	next, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.current = next
+	return nil
+}
+
+// Deepest nesting of statements and expressions, like parentheses in
+// parentheses, before a compile error rather than a stack overflow
+const MAX_SYNTAX_LEVELS = 200
+
+// Counts one more level of nesting, leaveLevel undoing it
+func (p *parser) enterLevel() error {
+	p.levels++
+	if p.levels > MAX_SYNTAX_LEVELS {
+		return p.errorNear("chunk has too many syntax levels")
+	}
+	return nil
+}
+
+func (p *parser) leaveLevel() {
+	p.levels--
+}
+
+func (p *parser) errorNear(message string) error {
+	return p.lexer.errorf(p.current.line, p.current.text, "%s", message)
+}
+
+func (p *parser) check(text string) bool {
+	return (p.current.kind == tokenSymbol || p.current.kind == tokenKeyword) && p.current.text == text
+}
+
+// Consumes text if it's the current token
+func (p *parser) accept(text string) (bool, error) {
+	if !p.check(text) {
+		return false, nil
+	}
+	return true, p.advance()
+}
+
+func (p *parser) expect(text string) error {
+	if !p.check(text) {
+		return p.errorNear("'" + text + "' expected")
+	}
+	return p.advance()
+}
+
+// Expects the keyword closing what was opened at line
+func (p *parser) expectClosing(text string, opening string, line int) error {
+	if p.check(text) {
+		return p.advance()
+	}
+	if line == p.current.line {
+		return p.errorNear("'" + text + "' expected")
+	}
+	return p.errorNear("'" + text + "' expected (to close '" + opening + "' at line " + strconv.Itoa(line) + ")")
+}
+
+func (p *parser) name() (string, error) {
+	if p.current.kind != tokenName {
+		return "", p.errorNear("<name> expected")
+	}
+	name := p.current.text
+	return name, p.advance()
+}
+
+func (p *parser) blockEnds() bool {
+	if p.current.kind == tokenEOF {
+		return true
+	}
+	if p.current.kind != tokenKeyword {
+		return false
+	}
+	switch p.current.text {
+	case "end", "else", "elseif", "until":
+		return true
+	}
+	return false
+}
+
+// Parses statements in a new scope
+func (p *parser) block() (*block, error) {
+	p.fs.openScope()
+	defer p.fs.closeScope()
+	return p.statements()
+}
+
+// Parses the body of a loop, where break is allowed
+func (p *parser) loopBlock() (*block, error) {
+	p.fs.loops++
+	defer func() { p.fs.loops-- }()
+	return p.block()
+}
+
+// Parses statements in the current scope, a return being the last one
+func (p *parser) statements() (*block, error) {
+	b := &block{}
+	for !p.blockEnds() {
+		if p.check("return") {
+			stat, err := p.returnStat()
+			if err != nil {
+				return nil, err
+			}
+			b.stats = append(b.stats, stat)
+			if !p.blockEnds() {
+				return nil, p.errorNear("'end' expected")
+			}
+			break
+		}
+		stat, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		if stat != nil {
+			b.stats = append(b.stats, stat)
+		}
+	}
+	return b, nil
+}
+
+func (p *parser) returnStat() (stat, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	ret := &returnStat{}
+	if !p.blockEnds() && !p.check(";") {
+		values, err := p.exprList()
+		if err != nil {
+			return nil, err
+		}
+		ret.values = values
+	}
+	_, err := p.accept(";")
+	return ret, err
+}
+
+func (p *parser) statement() (stat, error) {
+	defer p.leaveLevel()
+	if err := p.enterLevel(); err != nil {
+		return nil, err
+	}
+	line := p.current.line
+	if p.current.kind == tokenKeyword {
+		switch p.current.text {
+		case "if":
+			return p.ifStat(line)
+		case "while":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			condition, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("do"); err != nil {
+				return nil, err
+			}
+			body, err := p.loopBlock()
+			if err != nil {
+				return nil, err
+			}
+			return &whileStat{condition, body}, p.expectClosing("end", "while", line)
+		case "do":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			body, err := p.block()
+			if err != nil {
+				return nil, err
+			}
+			return &doStat{body}, p.expectClosing("end", "do", line)
+		case "for":
+			return p.forStat(line)
+		case "repeat":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			// until sees the locals of the body
+			p.fs.openScope()
+			defer p.fs.closeScope()
+			p.fs.loops++
+			body, err := p.statements()
+			p.fs.loops--
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectClosing("until", "repeat", line); err != nil {
+				return nil, err
+			}
+			condition, err := p.expr()
+			return &repeatStat{body, condition}, err
+		case "function":
+			return p.functionStat(line)
+		case "local":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if ok, err := p.accept("function"); err != nil || ok {
+				if err != nil {
+					return nil, err
+				}
+				name, err := p.name()
+				if err != nil {
+					return nil, err
+				}
+				// declared first so the function can call itself
+				slot := p.fs.declareLocal(name)
+				function, err := p.functionBody(name, false, line)
+				return &localFunctionStat{slot, function}, err
+			}
+			return p.localStat()
+		case "break":
+			if p.fs.loops == 0 {
+				return nil, p.errorNear("no loop to break")
+			}
+			return &breakStat{}, p.advance()
+		}
+	}
+	if ok, err := p.accept(";"); ok || err != nil {
+		return nil, err
+	}
+	return p.exprStat(line)
+}
+
+func (p *parser) ifStat(line int) (stat, error) {
+	s := &ifStat{}
+	for {
+		// if or elseif
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		condition, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("then"); err != nil {
+			return nil, err
+		}
+		body, err := p.block()
+		if err != nil {
+			return nil, err
+		}
+		s.conditions = append(s.conditions, condition)
+		s.blocks = append(s.blocks, body)
+		if !p.check("elseif") {
+			break
+		}
+	}
+	if ok, err := p.accept("else"); err != nil || ok {
+		if err != nil {
+			return nil, err
+		}
+		elseBlock, err := p.block()
+		if err != nil {
+			return nil, err
+		}
+		s.elseBlock = elseBlock
+	}
+	return s, p.expectClosing("end", "if", line)
+}
+
+func (p *parser) forStat(line int) (stat, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	first, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := p.accept("="); err != nil || ok {
+		if err != nil {
+			return nil, err
+		}
+		s := &numericForStat{line: line}
+		if s.start, err = p.expr(); err != nil {
+			return nil, err
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		if s.limit, err = p.expr(); err != nil {
+			return nil, err
+		}
+		if ok, err := p.accept(","); err != nil || ok {
+			if err != nil {
+				return nil, err
+			}
+			if s.step, err = p.expr(); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.expect("do"); err != nil {
+			return nil, err
+		}
+		p.fs.openScope()
+		s.slot = p.fs.declareLocal(first)
+		s.body, err = p.loopBlock()
+		p.fs.closeScope()
+		if err != nil {
+			return nil, err
+		}
+		return s, p.expectClosing("end", "for", line)
+	}
+	names := []string{first}
+	for {
+		ok, err := p.accept(",")
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	if err := p.expect("in"); err != nil {
+		return nil, err
+	}
+	s := &genericForStat{line: line}
+	if s.values, err = p.exprList(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("do"); err != nil {
+		return nil, err
+	}
+	p.fs.openScope()
+	for _, name := range names {
+		s.slots = append(s.slots, p.fs.declareLocal(name))
+	}
+	s.body, err = p.loopBlock()
+	p.fs.closeScope()
+	if err != nil {
+		return nil, err
+	}
+	return s, p.expectClosing("end", "for", line)
+}
+
+// function a.b.c:m() is an assignment to a field
+func (p *parser) functionStat(line int) (stat, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	fullName := name
+	target := p.variable(name, line)
+	isMethod := false
+	for p.check(".") || p.check(":") {
+		isMethod = p.check(":")
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		field, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		fullName += "." + field
+		target = &indexExpr{target, &constantExpr{field}, line}
+		if isMethod {
+			break
+		}
+	}
+	function, err := p.functionBody(fullName, isMethod, line)
+	if err != nil {
+		return nil, err
+	}
+	return &assignStat{targets: []expr{target}, values: []expr{function}, line: line}, nil
+}
+
+func (p *parser) localStat() (stat, error) {
+	var names []string
+	for {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		ok, err := p.accept(",")
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+	}
+	s := &localStat{}
+	if ok, err := p.accept("="); err != nil || ok {
+		if err != nil {
+			return nil, err
+		}
+		if s.values, err = p.exprList(); err != nil {
+			return nil, err
+		}
+	}
+	// declared after the values, which still see the previous variables
+	for _, name := range names {
+		s.slots = append(s.slots, p.fs.declareLocal(name))
+	}
+	return s, nil
+}
+
+func (p *parser) exprStat(line int) (stat, error) {
+	first, err := p.suffixedExpr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.check("=") && !p.check(",") {
+		switch first.(type) {
+		case *callExpr, *methodCallExpr:
+			return &callStat{first}, nil
+		}
+		return nil, p.errorNear("syntax error")
+	}
+	targets := []expr{first}
+	for {
+		ok, err := p.accept(",")
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		target, err := p.suffixedExpr()
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	for _, target := range targets {
+		switch target.(type) {
+		case *localExpr, *upvalueExpr, *globalExpr, *indexExpr:
+		default:
+			return nil, p.errorNear("syntax error")
+		}
+	}
+	if err := p.expect("="); err != nil {
+		return nil, err
+	}
+	values, err := p.exprList()
+	if err != nil {
+		return nil, err
+	}
+	return &assignStat{targets, values, line}, nil
+}
+
+func (p *parser) variable(name string, line int) expr {
+	if resolved := p.fs.resolve(name); resolved != nil {
+		return resolved
+	}
+	return &globalExpr{name, line}
+}
+
+func (p *parser) functionBody(name string, isMethod bool, line int) (*functionExpr, error) {
+	proto := &prototype{chunkName: p.lexer.chunkName, name: name, line: line}
+	p.openFunction(proto)
+	defer p.closeFunction()
+	if isMethod {
+		proto.params = append(proto.params, p.fs.declareLocal("self"))
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	for !p.check(")") {
+		if ok, err := p.accept("..."); err != nil || ok {
+			if err != nil {
+				return nil, err
+			}
+			proto.isVararg = true
+			break
+		}
+		param, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		proto.params = append(proto.params, p.fs.declareLocal(param))
+		if ok, err := p.accept(","); err != nil || !ok {
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	body, err := p.statements()
+	if err != nil {
+		return nil, err
+	}
+	proto.body = body
+	return &functionExpr{proto}, p.expectClosing("end", "function", line)
+}
+
+func (p *parser) exprList() ([]expr, error) {
+	var list []expr
+	for {
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+		ok, err := p.accept(",")
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return list, nil
+		}
+	}
+}
+
+// Left and right priorities of binary operators, like Lua 5.1
+var binaryPriority = map[string][2]int{
+	"or": {1, 1}, "and": {2, 2},
+	"<": {3, 3}, ">": {3, 3}, "<=": {3, 3}, ">=": {3, 3}, "~=": {3, 3}, "==": {3, 3},
+	"..": {5, 4}, "+": {6, 6}, "-": {6, 6},
+	"*": {7, 7}, "/": {7, 7}, "%": {7, 7}, "^": {10, 9},
+}
+
+const unaryPriority = 8
+
+func (p *parser) expr() (expr, error) {
+	return p.subExpr(0)
+}
+
+func (p *parser) binaryOperator() (string, bool) {
+	if p.current.kind != tokenSymbol && p.current.kind != tokenKeyword {
+		return "", false
+	}
+	_, ok := binaryPriority[p.current.text]
+	return p.current.text, ok
+}
+
+func (p *parser) subExpr(limit int) (expr, error) {
+	defer p.leaveLevel()
+	if err := p.enterLevel(); err != nil {
+		return nil, err
+	}
+	var left expr
+	line := p.current.line
+	if p.check("not") || p.check("-") || p.check("#") {
+		op := p.current.text
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		operand, err := p.subExpr(unaryPriority)
+		if err != nil {
+			return nil, err
+		}
+		if constant, ok := operand.(*constantExpr); ok && op == "-" {
+			if number, ok := constant.value.(float64); ok {
+				operand, op = &constantExpr{-number}, ""
+			}
+		}
+		left = operand
+		if op != "" {
+			left = &unaryExpr{op, operand, line}
+		}
+	} else {
+		simple, err := p.simpleExpr()
+		if err != nil {
+			return nil, err
+		}
+		left = simple
+	}
+	for {
+		op, ok := p.binaryOperator()
+		if !ok || binaryPriority[op][0] <= limit {
+			return left, nil
+		}
+		line := p.current.line
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		right, err := p.subExpr(binaryPriority[op][1])
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case "and", "or":
+			left = &logicalExpr{op == "and", left, right}
+		default:
+			left = &binaryExpr{op, left, right, line}
+		}
+	}
+}
+
+func (p *parser) simpleExpr() (expr, error) {
+	current := p.current
+	switch current.kind {
+	case tokenNumber:
+		return &constantExpr{current.number}, p.advance()
+	case tokenString:
+		return &constantExpr{current.text}, p.advance()
+	case tokenKeyword:
+		switch current.text {
+		case "nil":
+			return &constantExpr{nil}, p.advance()
+		case "true":
+			return &constantExpr{true}, p.advance()
+		case "false":
+			return &constantExpr{false}, p.advance()
+		case "function":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			return p.functionBody("anonymous", false, current.line)
+		}
+	case tokenSymbol:
+		switch current.text {
+		case "...":
+			if !p.fs.proto.isVararg {
+				return nil, p.errorNear("cannot use '...' outside a vararg function")
+			}
+			return &varargExpr{}, p.advance()
+		case "{":
+			return p.tableConstructor()
+		}
+	}
+	return p.suffixedExpr()
+}
+
+func (p *parser) primaryExpr() (expr, error) {
+	line := p.current.line
+	if p.current.kind == tokenName {
+		name := p.current.text
+		return p.variable(name, line), p.advance()
+	}
+	if ok, err := p.accept("("); err != nil || ok {
+		if err != nil {
+			return nil, err
+		}
+		inner, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		return &parenExpr{inner}, p.expectClosing(")", "(", line)
+	}
+	return nil, p.errorNear("unexpected symbol")
+}
+
+func (p *parser) suffixedExpr() (expr, error) {
+	e, err := p.primaryExpr()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		line := p.current.line
+		switch {
+		case p.check("."):
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			field, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			e = &indexExpr{e, &constantExpr{field}, line}
+		case p.check("["):
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			key, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			e = &indexExpr{e, key, line}
+		case p.check(":"):
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			method, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			args, err := p.callArgs()
+			if err != nil {
+				return nil, err
+			}
+			e = &methodCallExpr{e, method, args, line}
+		case p.check("(") || p.check("{") || p.current.kind == tokenString:
+			args, err := p.callArgs()
+			if err != nil {
+				return nil, err
+			}
+			e = &callExpr{e, args, line}
+		default:
+			return e, nil
+		}
+	}
+}
+
+func (p *parser) callArgs() ([]expr, error) {
+	if p.current.kind == tokenString {
+		text := p.current.text
+		return []expr{&constantExpr{text}}, p.advance()
+	}
+	if p.check("{") {
+		table, err := p.tableConstructor()
+		return []expr{table}, err
+	}
+	line := p.current.line
+	if err := p.expect("("); err != nil {
+		return nil, p.errorNear("function arguments expected")
+	}
+	if ok, err := p.accept(")"); err != nil || ok {
+		return nil, err
+	}
+	args, err := p.exprList()
+	if err != nil {
+		return nil, err
+	}
+	return args, p.expectClosing(")", "(", line)
+}
+
+func (p *parser) tableConstructor() (expr, error) {
+	line := p.current.line
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	table := &tableExpr{line: line}
+	for !p.check("}") {
+		var field tableField
+		switch {
+		case p.check("["):
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			key, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			if err := p.expect("="); err != nil {
+				return nil, err
+			}
+			field.key = key
+		case p.current.kind == tokenName:
+			// name = value, or an expression starting with a name
+			saved := *p.lexer
+			current := p.current
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if p.check("=") {
+				field.key = &constantExpr{current.text}
+				if err := p.advance(); err != nil {
+					return nil, err
+				}
+			} else {
+				*p.lexer = saved
+				p.current = current
+			}
+		}
+		value, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		field.value = value
+		table.fields = append(table.fields, field)
+		if !p.check(",") && !p.check(";") {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	return table, p.expectClosing("}", "{", line)
+}
@@ -0,0 +1,404 @@
+package lua
+
+import (
+	"strings"
+)
+
+// Lua patterns, matched the way lstrlib.c does: single character classes
+// like %d or [a-z] with * + - ? repetitions, anchors, captures including
+// position captures (), %b balanced matches, %f frontiers and %1 back references.
+
+const MAX_CAPTURES = 32
+
+// Special value of a capture length for position captures
+const capturePosition = -2
+
+// Capture still open while matching
+const captureUnfinished = -1
+
+type matchState struct {
+	source   string
+	pattern  string
+	level    int
+	captures [MAX_CAPTURES]struct {
+		start  int
+		length int
+	}
+	// bounds the recursion of patterns like (a*)*
+	depth int
+}
+
+const MAX_MATCH_DEPTH = 200
+
+type patternError struct {
+	message string
+}
+
+func (e *patternError) Error() string {
+	return e.message
+}
+
+func classEnd(ms *matchState, p int) int {
+	c := ms.pattern[p]
+	p++
+	if c == '%' {
+		if p >= len(ms.pattern) {
+			panic(&patternError{"malformed pattern (ends with '%')"})
+		}
+		return p + 1
+	}
+	if c == '[' {
+		if p < len(ms.pattern) && ms.pattern[p] == '^' {
+			p++
+		}
+		// the first character of a set can be ]
+		for {
+			if p >= len(ms.pattern) {
+				panic(&patternError{"malformed pattern (missing ']')"})
+			}
+			c := ms.pattern[p]
+			p++
+			if c == '%' {
+				p++
+			}
+			if p < len(ms.pattern) && ms.pattern[p] == ']' {
+				return p + 1
+			}
+			if p >= len(ms.pattern) {
+				panic(&patternError{"malformed pattern (missing ']')"})
+			}
+		}
+	}
+	return p
+}
+
+func isAlpha(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isPunct(c byte) bool {
+	return c >= 33 && c <= 126 && !isAlpha(c) && !isDigit(c)
+}
+
+func matchClass(c byte, class byte) bool {
+	var result bool
+	lower := class | 0x20
+	switch lower {
+	case 'a':
+		result = isAlpha(c)
+	case 'c':
+		result = c < 32 || c == 127
+	case 'd':
+		result = isDigit(c)
+	case 'l':
+		result = c >= 'a' && c <= 'z'
+	case 'p':
+		result = isPunct(c)
+	case 's':
+		result = c == ' ' || (c >= '\t' && c <= '\r')
+	case 'u':
+		result = c >= 'A' && c <= 'Z'
+	case 'w':
+		result = isAlpha(c) || isDigit(c)
+	case 'x':
+		result = isDigit(c) || (c|0x20 >= 'a' && c|0x20 <= 'f')
+	case 'z':
+		result = c == 0
+	default:
+		return class == c
+	}
+	if class >= 'A' && class <= 'Z' {
+		return !result
+	}
+	return result
+}
+
+// Whether c is in the set [...] spanning pattern[p:end], p at the [ and end after the ]
+func matchBracketClass(ms *matchState, c byte, p int, end int) bool {
+	negate := false
+	p++
+	if ms.pattern[p] == '^' {
+		negate = true
+		p++
+	}
+	for ; p < end-1; p++ {
+		switch {
+		case ms.pattern[p] == '%' && p+1 < end-1:
+			p++
+			if matchClass(c, ms.pattern[p]) {
+				return !negate
+			}
+		case p+2 < end-1 && ms.pattern[p+1] == '-':
+			if ms.pattern[p] <= c && c <= ms.pattern[p+2] {
+				return !negate
+			}
+			p += 2
+		case ms.pattern[p] == c:
+			return !negate
+		}
+	}
+	return negate
+}
+
+func singleMatch(ms *matchState, s int, p int, end int) bool {
+	if s >= len(ms.source) {
+		return false
+	}
+	c := ms.source[s]
+	switch ms.pattern[p] {
+	case '.':
+		return true
+	case '%':
+		return matchClass(c, ms.pattern[p+1])
+	case '[':
+		return matchBracketClass(ms, c, p, end)
+	}
+	return ms.pattern[p] == c
+}
+
+// Matches pattern[p:] at source[s:], returning the end of the match or -1
+func doMatch(ms *matchState, s int, p int) int {
+	ms.depth++
+	if ms.depth > MAX_MATCH_DEPTH {
+		panic(&patternError{"pattern too complex"})
+	}
+	defer func() { ms.depth-- }()
+	for {
+		if p >= len(ms.pattern) {
+			return s
+		}
+		switch ms.pattern[p] {
+		case '(':
+			if p+1 < len(ms.pattern) && ms.pattern[p+1] == ')' {
+				return startCapture(ms, s, p+2, capturePosition)
+			}
+			return startCapture(ms, s, p+1, captureUnfinished)
+		case ')':
+			return endCapture(ms, s, p+1)
+		case '$':
+			if p+1 == len(ms.pattern) {
+				if s == len(ms.source) {
+					return s
+				}
+				return -1
+			}
+		case '%':
+			if p+1 < len(ms.pattern) {
+				switch next := ms.pattern[p+1]; {
+				case next == 'b':
+					s = matchBalance(ms, s, p+2)
+					if s == -1 {
+						return -1
+					}
+					p += 4
+					continue
+				case next == 'f':
+					p += 2
+					if p >= len(ms.pattern) || ms.pattern[p] != '[' {
+						panic(&patternError{"missing '[' after '%f' in pattern"})
+					}
+					end := classEnd(ms, p)
+					var previous, current byte
+					if s > 0 {
+						previous = ms.source[s-1]
+					}
+					if s < len(ms.source) {
+						current = ms.source[s]
+					}
+					if matchBracketClass(ms, previous, p, end) || !matchBracketClass(ms, current, p, end) {
+						return -1
+					}
+					p = end
+					continue
+				case isDigit(next):
+					s = matchCapture(ms, s, next)
+					if s == -1 {
+						return -1
+					}
+					p += 2
+					continue
+				}
+			}
+		}
+		end := classEnd(ms, p)
+		matches := singleMatch(ms, s, p, end)
+		var quantifier byte
+		if end < len(ms.pattern) {
+			quantifier = ms.pattern[end]
+		}
+		switch quantifier {
+		case '?':
+			if matches {
+				if result := doMatch(ms, s+1, end+1); result != -1 {
+					return result
+				}
+			}
+			p = end + 1
+			continue
+		case '*':
+			return maxExpand(ms, s, p, end)
+		case '+':
+			if !matches {
+				return -1
+			}
+			return maxExpand(ms, s+1, p, end)
+		case '-':
+			return minExpand(ms, s, p, end)
+		}
+		if !matches {
+			return -1
+		}
+		s++
+		p = end
+	}
+}
+
+func maxExpand(ms *matchState, s int, p int, end int) int {
+	count := 0
+	for singleMatch(ms, s+count, p, end) {
+		count++
+	}
+	for ; count >= 0; count-- {
+		if result := doMatch(ms, s+count, end+1); result != -1 {
+			return result
+		}
+	}
+	return -1
+}
+
+func minExpand(ms *matchState, s int, p int, end int) int {
+	for {
+		if result := doMatch(ms, s, end+1); result != -1 {
+			return result
+		}
+		if !singleMatch(ms, s, p, end) {
+			return -1
+		}
+		s++
+	}
+}
+
+func startCapture(ms *matchState, s int, p int, length int) int {
+	if ms.level >= MAX_CAPTURES {
+		panic(&patternError{"too many captures"})
+	}
+	ms.captures[ms.level].start = s
+	ms.captures[ms.level].length = length
+	ms.level++
+	result := doMatch(ms, s, p)
+	if result == -1 {
+		ms.level--
+	}
+	return result
+}
+
+func endCapture(ms *matchState, s int, p int) int {
+	open := -1
+	for i := ms.level - 1; i >= 0; i-- {
+		if ms.captures[i].length == captureUnfinished {
+			open = i
+			break
+		}
+	}
+	if open < 0 {
+		panic(&patternError{"invalid pattern capture"})
+	}
+	ms.captures[open].length = s - ms.captures[open].start
+	result := doMatch(ms, s, p)
+	if result == -1 {
+		ms.captures[open].length = captureUnfinished
+	}
+	return result
+}
+
+func matchBalance(ms *matchState, s int, p int) int {
+	if p+1 >= len(ms.pattern) {
+		panic(&patternError{"missing arguments to '%b'"})
+	}
+	if s >= len(ms.source) || ms.source[s] != ms.pattern[p] {
+		return -1
+	}
+	open, close := ms.pattern[p], ms.pattern[p+1]
+	depth := 1
+	for i := s + 1; i < len(ms.source); i++ {
+		switch ms.source[i] {
+		case close:
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		case open:
+			depth++
+		}
+	}
+	return -1
+}
+
+func matchCapture(ms *matchState, s int, digit byte) int {
+	index := int(digit - '1')
+	if index < 0 || index >= ms.level || ms.captures[index].length == captureUnfinished {
+		panic(&patternError{"invalid capture index"})
+	}
+	captured := ms.source[ms.captures[index].start : ms.captures[index].start+ms.captures[index].length]
+	if strings.HasPrefix(ms.source[s:], captured) {
+		return s + len(captured)
+	}
+	return -1
+}
+
+// Value of capture i, the whole match from start to end when there is none
+func (ms *matchState) capture(i int, start int, end int) Value {
+	if i >= ms.level {
+		if i == 0 {
+			return ms.source[start:end]
+		}
+		panic(&patternError{"invalid capture index"})
+	}
+	c := ms.captures[i]
+	if c.length == capturePosition {
+		return float64(c.start + 1)
+	}
+	return ms.source[c.start : c.start+c.length]
+}
+
+// Captures of a match, or the whole match when the pattern has none
+func (ms *matchState) captureValues(start int, end int, wholeIfNone bool) []Value {
+	count := ms.level
+	if count == 0 && wholeIfNone {
+		count = 1
+	}
+	values := make([]Value, count)
+	for i := range values {
+		values[i] = ms.capture(i, start, end)
+	}
+	return values
+}
+
+// Finds the first match of pattern in source from init, -1 if none. Errors
+// in the pattern are returned as patternError.
+func findPattern(source string, pattern string, init int) (ms *matchState, start int, end int, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			patternErr, ok := recovered.(*patternError)
+			if !ok {
+				panic(recovered)
+			}
+			err = patternErr
+		}
+	}()
+	anchored := strings.HasPrefix(pattern, "^")
+	if anchored {
+		pattern = pattern[1:]
+	}
+	ms = &matchState{source: source, pattern: pattern}
+	for s := init; s <= len(source); s++ {
+		ms.level = 0
+		if e := doMatch(ms, s, 0); e != -1 {
+			return ms, s, e, nil
+		}
+		if anchored {
+			break
+		}
+	}
+	return ms, -1, -1, nil
+}
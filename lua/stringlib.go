@@ -0,0 +1,489 @@
+package lua
+
+import (
+	"fmt"
+	"strings"
+)
+
+func stringLibrary() *Table {
+	library := NewTable()
+	register(library, map[string]func(state *State, args []Value) ([]Value, error){
+		"byte":    stringByte,
+		"char":    stringChar,
+		"find":    stringFind,
+		"format":  stringFormat,
+		"gmatch":  stringGmatch,
+		"gsub":    stringGsub,
+		"len":     stringLen,
+		"lower":   stringLower,
+		"match":   stringMatch,
+		"rep":     stringRep,
+		"reverse": stringReverse,
+		"sub":     stringSub,
+		"upper":   stringUpper,
+	})
+	return library
+}
+
+// Converts a 1-based position that may count from the end to a 0-based offset
+func stringOffset(position int, length int) int {
+	if position < 0 {
+		position += length + 1
+	}
+	if position < 0 {
+		position = 0
+	}
+	return position
+}
+
+// Start and end offsets of s:sub(i, j) style arguments, clamped to the string
+func stringRange(i int, j int, length int) (int, int) {
+	start, end := stringOffset(i, length), stringOffset(j, length)
+	if start < 1 {
+		start = 1
+	}
+	if end > length {
+		end = length
+	}
+	return start - 1, end
+}
+
+func stringByte(state *State, args []Value) ([]Value, error) {
+	s, err := CheckString(state, args, 1, "byte")
+	if err != nil {
+		return nil, err
+	}
+	i, err := OptInt(state, args, 2, "byte", 1)
+	if err != nil {
+		return nil, err
+	}
+	j, err := OptInt(state, args, 3, "byte", i)
+	if err != nil {
+		return nil, err
+	}
+	start, end := stringRange(i, j, len(s))
+	var values []Value
+	for k := start; k < end; k++ {
+		values = append(values, float64(s[k]))
+	}
+	return values, nil
+}
+
+func stringChar(state *State, args []Value) ([]Value, error) {
+	var builder strings.Builder
+	for n := 1; n <= len(args); n++ {
+		c, err := CheckInt(state, args, n, "char")
+		if err != nil {
+			return nil, err
+		}
+		if c < 0 || c > 255 {
+			return nil, argError(state, n, "char", "invalid value")
+		}
+		builder.WriteByte(byte(c))
+	}
+	return []Value{builder.String()}, nil
+}
+
+// Whether a find pattern has no special characters and can be searched as is
+func isPlainPattern(pattern string) bool {
+	return !strings.ContainsAny(pattern, "^$*+?.([%-")
+}
+
+func stringFind(state *State, args []Value) ([]Value, error) {
+	return find(state, args, "find", true)
+}
+
+func stringMatch(state *State, args []Value) ([]Value, error) {
+	return find(state, args, "match", false)
+}
+
+// string.find returns the positions of the match followed by its captures,
+// string.match only the captures or the whole match
+func find(state *State, args []Value, name string, positions bool) ([]Value, error) {
+	s, err := CheckString(state, args, 1, name)
+	if err != nil {
+		return nil, err
+	}
+	pattern, err := CheckString(state, args, 2, name)
+	if err != nil {
+		return nil, err
+	}
+	init, err := OptInt(state, args, 3, name, 1)
+	if err != nil {
+		return nil, err
+	}
+	init = stringOffset(init, len(s)) - 1
+	if init < 0 {
+		init = 0
+	}
+	if init > len(s) {
+		return []Value{nil}, nil
+	}
+	if positions && (Truthy(arg(args, 4)) || isPlainPattern(pattern)) {
+		index := strings.Index(s[init:], pattern)
+		if index < 0 {
+			return []Value{nil}, nil
+		}
+		return []Value{float64(init + index + 1), float64(init + index + len(pattern))}, nil
+	}
+	ms, start, end, err := findPattern(s, pattern, init)
+	if err != nil {
+		return nil, state.Errorf(err.Error())
+	}
+	if start < 0 {
+		return []Value{nil}, nil
+	}
+	if positions {
+		return append([]Value{float64(start + 1), float64(end)}, ms.captureValues(start, end, false)...), nil
+	}
+	return ms.captureValues(start, end, true), nil
+}
+
+func stringGmatch(state *State, args []Value) ([]Value, error) {
+	s, err := CheckString(state, args, 1, "gmatch")
+	if err != nil {
+		return nil, err
+	}
+	pattern, err := CheckString(state, args, 2, "gmatch")
+	if err != nil {
+		return nil, err
+	}
+	position := 0
+	iterator := func(state *State, args []Value) ([]Value, error) {
+		for position <= len(s) {
+			ms, start, end, err := findPattern(s, pattern, position)
+			if err != nil {
+				return nil, state.Errorf(err.Error())
+			}
+			if start < 0 {
+				position = len(s) + 1
+				break
+			}
+			// an empty match moves on by one so the loop ends
+			if end == start {
+				position = end + 1
+			} else {
+				position = end
+			}
+			return ms.captureValues(start, end, true), nil
+		}
+		return []Value{nil}, nil
+	}
+	return []Value{&GoFunction{"gmatch_iterator", iterator}}, nil
+}
+
+func stringGsub(state *State, args []Value) ([]Value, error) {
+	s, err := CheckString(state, args, 1, "gsub")
+	if err != nil {
+		return nil, err
+	}
+	pattern, err := CheckString(state, args, 2, "gsub")
+	if err != nil {
+		return nil, err
+	}
+	replacement := arg(args, 3)
+	switch replacement.(type) {
+	case string, float64, *Table, *Function, *GoFunction:
+	default:
+		return nil, typeError(state, args, 3, "gsub", "string/function/table")
+	}
+	limit, err := OptInt(state, args, 4, "gsub", len(s)+1)
+	if err != nil {
+		return nil, err
+	}
+	anchored := strings.HasPrefix(pattern, "^")
+	var builder strings.Builder
+	position, count := 0, 0
+	for count < limit {
+		ms, start, end, err := findPattern(s, pattern, position)
+		if err != nil {
+			return nil, state.Errorf(err.Error())
+		}
+		if start < 0 {
+			break
+		}
+		builder.WriteString(s[position:start])
+		count++
+		value, err := substitute(state, ms, start, end, replacement)
+		if err != nil {
+			return nil, err
+		}
+		builder.WriteString(value)
+		position = end
+		if end == start {
+			if position < len(s) {
+				builder.WriteByte(s[position])
+			}
+			position++
+		}
+		if position > len(s) || anchored {
+			break
+		}
+	}
+	if position < len(s) {
+		builder.WriteString(s[position:])
+	}
+	return []Value{builder.String(), float64(count)}, nil
+}
+
+// Text replacing one match in gsub, the match itself when a table or
+// function gives nil or false
+func substitute(state *State, ms *matchState, start int, end int, replacement Value) (string, error) {
+	whole := ms.source[start:end]
+	var value Value
+	switch r := replacement.(type) {
+	case float64:
+		return FormatNumber(r), nil
+	case string:
+		var builder strings.Builder
+		for i := 0; i < len(r); i++ {
+			if r[i] != '%' || i+1 == len(r) {
+				builder.WriteByte(r[i])
+				continue
+			}
+			i++
+			switch {
+			case r[i] == '0':
+				builder.WriteString(whole)
+			case isDigit(r[i]):
+				captured, err := safeCapture(ms, int(r[i]-'1'), start, end)
+				if err != nil {
+					return "", state.Errorf(err.Error())
+				}
+				builder.WriteString(ToString(captured))
+			default:
+				builder.WriteByte(r[i])
+			}
+		}
+		return builder.String(), nil
+	case *Table:
+		key, err := safeCapture(ms, 0, start, end)
+		if err != nil {
+			return "", state.Errorf(err.Error())
+		}
+		value = r.Get(key)
+	default:
+		results, err := state.call(r, ms.captureValues(start, end, true), nil)
+		if err != nil {
+			return "", err
+		}
+		if len(results) > 0 {
+			value = results[0]
+		}
+	}
+	switch v := value.(type) {
+	case nil:
+		return whole, nil
+	case bool:
+		if !v {
+			return whole, nil
+		}
+	case string:
+		return v, nil
+	case float64:
+		return FormatNumber(v), nil
+	}
+	return "", state.Errorf("invalid replacement value (a " + TypeName(value) + ")")
+}
+
+func safeCapture(ms *matchState, i int, start int, end int) (value Value, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			patternErr, ok := recovered.(*patternError)
+			if !ok {
+				panic(recovered)
+			}
+			err = patternErr
+		}
+	}()
+	return ms.capture(i, start, end), nil
+}
+
+func stringLen(state *State, args []Value) ([]Value, error) {
+	s, err := CheckString(state, args, 1, "len")
+	return []Value{float64(len(s))}, err
+}
+
+func stringLower(state *State, args []Value) ([]Value, error) {
+	s, err := CheckString(state, args, 1, "lower")
+	return []Value{strings.ToLower(s)}, err
+}
+
+func stringUpper(state *State, args []Value) ([]Value, error) {
+	s, err := CheckString(state, args, 1, "upper")
+	return []Value{strings.ToUpper(s)}, err
+}
+
+// Largest string string.rep, .., table.concat and string.format build, so
+// scripts can't use them to exhaust memory
+const MAX_STRING_SIZE = 512 * 1024 * 1024
+
+func stringRep(state *State, args []Value) ([]Value, error) {
+	s, err := CheckString(state, args, 1, "rep")
+	if err != nil {
+		return nil, err
+	}
+	n, err := CheckInt(state, args, 2, "rep")
+	if err != nil {
+		return nil, err
+	}
+	if n <= 0 {
+		return []Value{""}, nil
+	}
+	if len(s) > 0 && n > MAX_STRING_SIZE/len(s) {
+		return nil, state.Errorf("resulting string too large")
+	}
+	return []Value{strings.Repeat(s, n)}, nil
+}
+
+func stringReverse(state *State, args []Value) ([]Value, error) {
+	s, err := CheckString(state, args, 1, "reverse")
+	reversed := make([]byte, len(s))
+	for i := range reversed {
+		reversed[i] = s[len(s)-1-i]
+	}
+	return []Value{string(reversed)}, err
+}
+
+func stringSub(state *State, args []Value) ([]Value, error) {
+	s, err := CheckString(state, args, 1, "sub")
+	if err != nil {
+		return nil, err
+	}
+	i, err := CheckInt(state, args, 2, "sub")
+	if err != nil {
+		return nil, err
+	}
+	j, err := OptInt(state, args, 3, "sub", -1)
+	if err != nil {
+		return nil, err
+	}
+	start, end := stringRange(i, j, len(s))
+	if start >= end {
+		return []Value{""}, nil
+	}
+	return []Value{s[start:end]}, nil
+}
+
+func stringFormat(state *State, args []Value) ([]Value, error) {
+	format, err := CheckString(state, args, 1, "format")
+	if err != nil {
+		return nil, err
+	}
+	var builder strings.Builder
+	n := 1
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			builder.WriteByte(format[i])
+			continue
+		}
+		i++
+		if i < len(format) && format[i] == '%' {
+			builder.WriteByte('%')
+			continue
+		}
+		// flags, width and precision are passed on to strconv style formatting
+		specStart := i
+		for i < len(format) && strings.IndexByte("-+ #0", format[i]) >= 0 {
+			i++
+		}
+		for i < len(format) && (isDigit(format[i]) || format[i] == '.') {
+			i++
+		}
+		// like Lua, widths and precisions have at most 2 digits
+		if width, precision := formatSpecDigits(format[specStart:i]); width > 2 || precision > 2 {
+			return nil, state.Errorf("invalid format (width or precision too long)")
+		}
+		if i >= len(format) {
+			return nil, state.Errorf("invalid option '%' to 'format'")
+		}
+		spec := format[specStart:i]
+		n++
+		formatted, err := formatDirective(state, args, n, format[i], spec)
+		if err != nil {
+			return nil, err
+		}
+		if builder.Len()+len(formatted) > MAX_STRING_SIZE {
+			return nil, state.Errorf("string length overflow")
+		}
+		builder.WriteString(formatted)
+	}
+	return []Value{builder.String()}, nil
+}
+
+// Number of digits of the width and of the precision of a format spec
+func formatSpecDigits(spec string) (width int, precision int) {
+	spec = strings.TrimLeft(spec, "-+ #0")
+	if dot := strings.IndexByte(spec, '.'); dot >= 0 {
+		return dot, len(spec) - dot - 1
+	}
+	return len(spec), 0
+}
+
+func formatDirective(state *State, args []Value, n int, verb byte, spec string) (string, error) {
+	switch verb {
+	case 'd', 'i':
+		number, err := CheckNumber(state, args, n, "format")
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%"+spec+"d", int64(number)), nil
+	case 'u':
+		number, err := CheckNumber(state, args, n, "format")
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%"+spec+"d", uint64(int64(number))), nil
+	case 'c':
+		number, err := CheckNumber(state, args, n, "format")
+		if err != nil {
+			return "", err
+		}
+		return string([]byte{byte(number)}), nil
+	case 'x', 'X', 'o':
+		number, err := CheckNumber(state, args, n, "format")
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%"+spec+string(verb), uint64(int64(number))), nil
+	case 'e', 'E', 'f', 'g', 'G':
+		number, err := CheckNumber(state, args, n, "format")
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%"+spec+string(verb), number), nil
+	case 'q':
+		s, err := CheckString(state, args, n, "format")
+		if err != nil {
+			return "", err
+		}
+		return quoteString(s), nil
+	case 's':
+		if n > len(args) {
+			return "", typeError(state, args, n, "format", "string")
+		}
+		return fmt.Sprintf("%"+spec+"s", ToString(args[n-1])), nil
+	}
+	return "", state.Errorf("invalid option '%" + string([]byte{verb}) + "' to 'format'")
+}
+
+// Quotes s so that Lua reads it back, as %q does
+func quoteString(s string) string {
+	var builder strings.Builder
+	builder.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\', '\n':
+			builder.WriteByte('\\')
+			builder.WriteByte(c)
+		case '\r':
+			builder.WriteString("\\r")
+		case 0:
+			builder.WriteString("\\000")
+		default:
+			builder.WriteByte(c)
+		}
+	}
+	builder.WriteByte('"')
+	return builder.String()
+}
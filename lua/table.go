@@ -0,0 +1,167 @@
+package lua
+
+import (
+	"math"
+)
+
+// Lua table. Keys 1 to n are kept in a slice and the others in a map, with
+// their insertion order so next() can walk them.
+type Table struct {
+	array []Value
+	hash  map[Value]Value
+	// keys of hash in insertion order and their position, removed keys are
+	// skipped until there are as many of them as live keys
+	keys     []Value
+	position map[Value]int
+	// library tables, which scripts can't change when globals are strict
+	readonly bool
+}
+
+func NewTable() *Table {
+	return &Table{}
+}
+
+// Creates a table holding values at keys 1 to len(values)
+func NewArray(values []Value) *Table {
+	return &Table{array: append([]Value(nil), values...)}
+}
+
+// Stops scripts from changing the table when globals are strict, like the
+// libraries
+func (t *Table) SetReadonly() {
+	t.readonly = true
+}
+
+func arrayIndex(key Value) (int, bool) {
+	number, ok := key.(float64)
+	if !ok {
+		return 0, false
+	}
+	index, ok := toInteger(number)
+	if !ok || index < 1 || index > math.MaxInt32 {
+		return 0, false
+	}
+	return int(index), true
+}
+
+func (t *Table) Get(key Value) Value {
+	if index, ok := arrayIndex(key); ok && index <= len(t.array) {
+		return t.array[index-1]
+	}
+	if t.hash == nil {
+		return nil
+	}
+	return t.hash[key]
+}
+
+// Sets key to value, removing it for a nil value. Fails for nil and NaN keys.
+func (t *Table) Set(key Value, value Value) error {
+	switch k := key.(type) {
+	case nil:
+		return &Error{"table index is nil"}
+	case float64:
+		if math.IsNaN(k) {
+			return &Error{"table index is NaN"}
+		}
+	}
+	if index, ok := arrayIndex(key); ok && index <= len(t.array)+1 {
+		if index <= len(t.array) {
+			t.array[index-1] = value
+			// keep no nil at the end so the length stays a border
+			for len(t.array) > 0 && t.array[len(t.array)-1] == nil {
+				t.array = t.array[:len(t.array)-1]
+			}
+			return nil
+		}
+		if value == nil {
+			t.setHash(key, nil)
+			return nil
+		}
+		t.array = append(t.array, value)
+		t.setHash(key, nil)
+		// following keys already in the map move to the array
+		for {
+			next := float64(len(t.array) + 1)
+			moved, exists := t.hash[next]
+			if !exists {
+				return nil
+			}
+			t.array = append(t.array, moved)
+			t.setHash(next, nil)
+		}
+	}
+	t.setHash(key, value)
+	return nil
+}
+
+func (t *Table) setHash(key Value, value Value) {
+	if value == nil {
+		if _, exists := t.hash[key]; exists {
+			delete(t.hash, key)
+			delete(t.position, key)
+		}
+		return
+	}
+	if t.hash == nil {
+		t.hash = make(map[Value]Value)
+		t.position = make(map[Value]int)
+	}
+	if _, exists := t.hash[key]; !exists {
+		if len(t.keys) > 8 && len(t.keys) > 2*len(t.hash) {
+			t.compactKeys()
+		}
+		t.position[key] = len(t.keys)
+		t.keys = append(t.keys, key)
+	}
+	t.hash[key] = value
+}
+
+func (t *Table) compactKeys() {
+	live := t.keys[:0]
+	for _, key := range t.keys {
+		if _, exists := t.hash[key]; exists {
+			t.position[key] = len(live)
+			live = append(live, key)
+		}
+	}
+	for i := len(live); i < len(t.keys); i++ {
+		t.keys[i] = nil
+	}
+	t.keys = live
+}
+
+// Length of the array part, a border like the # operator returns
+func (t *Table) Len() int {
+	return len(t.array)
+}
+
+// Key and value following key in traversal order, the first ones for a nil
+// key. ok is false at the end of the traversal or for an unknown key.
+func (t *Table) Next(key Value) (nextKey Value, value Value, ok bool, err error) {
+	start := 0
+	if key != nil {
+		if index, isIndex := arrayIndex(key); isIndex && index <= len(t.array) {
+			start = index
+		} else {
+			position, exists := t.position[key]
+			if !exists {
+				return nil, nil, false, &Error{"invalid key to 'next'"}
+			}
+			start = len(t.array) + position + 1
+		}
+	}
+	for i := start; i < len(t.array); i++ {
+		if t.array[i] != nil {
+			return float64(i + 1), t.array[i], true, nil
+		}
+	}
+	for i := start - len(t.array); i < len(t.keys); i++ {
+		if i < 0 {
+			continue
+		}
+		if value, exists := t.hash[t.keys[i]]; exists {
+			return t.keys[i], value, true, nil
+		}
+	}
+	return nil, nil, false, nil
+}
@@ -0,0 +1,150 @@
+package lua
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A Lua value: nil, bool, float64, string, *Table, *Function or *GoFunction
+type Value interface{}
+
+// Function written in Go callable from scripts
+type GoFunction struct {
+	Name string
+	Fn   func(state *State, args []Value) ([]Value, error)
+}
+
+// Function defined by a script together with the variables it captured
+type Function struct {
+	proto    *prototype
+	upvalues []*cell
+}
+
+// Error raised by a script with error() or by a failed operation, which pcall
+// catches. Value is what error() was called with.
+type Error struct {
+	Value Value
+}
+
+func (e *Error) Error() string {
+	if text, ok := e.Value.(string); ok {
+		return text
+	}
+	if number, ok := e.Value.(float64); ok {
+		return FormatNumber(number)
+	}
+	return "(error object is a " + TypeName(e.Value) + " value)"
+}
+
+// Name of the type of value as returned by type()
+func TypeName(value Value) string {
+	switch value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case *Table:
+		return "table"
+	case *Function, *GoFunction:
+		return "function"
+	}
+	return "userdata"
+}
+
+// Formats a number like Lua 5.1 does, integers without a fraction
+func FormatNumber(number float64) string {
+	if math.IsInf(number, 1) {
+		return "inf"
+	}
+	if math.IsInf(number, -1) {
+		return "-inf"
+	}
+	if math.IsNaN(number) {
+		return "nan"
+	}
+	return fmt.Sprintf("%.14g", number)
+}
+
+// Converts value to a string like tostring() does
+func ToString(value Value) string {
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return FormatNumber(v)
+	case string:
+		return v
+	case *Table:
+		return fmt.Sprintf("table: %p", v)
+	case *Function:
+		return fmt.Sprintf("function: %p", v)
+	case *GoFunction:
+		return fmt.Sprintf("function: builtin: %p", v)
+	}
+	return fmt.Sprintf("userdata: %v", value)
+}
+
+// Converts a number, or a string holding one, to a number. ok is false otherwise.
+func ToNumber(value Value) (number float64, ok bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		return parseNumber(v)
+	}
+	return 0, false
+}
+
+// Parses a decimal or hexadecimal number surrounded by optional spaces
+func parseNumber(text string) (float64, bool) {
+	text = strings.TrimSpace(text)
+	unsigned, negative := text, false
+	if strings.HasPrefix(unsigned, "-") {
+		unsigned, negative = unsigned[1:], true
+	}
+	if strings.HasPrefix(unsigned, "0x") || strings.HasPrefix(unsigned, "0X") {
+		value, err := strconv.ParseUint(unsigned[2:], 16, 64)
+		if err != nil {
+			return 0, false
+		}
+		if negative {
+			return -float64(value), true
+		}
+		return float64(value), true
+	}
+	if text == "" || strings.ContainsAny(text, "_xXpP") {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil && !strings.Contains(err.Error(), "value out of range") {
+		return 0, false
+	}
+	return value, true
+}
+
+// Only nil and false are false
+func Truthy(value Value) bool {
+	if value == nil {
+		return false
+	}
+	if b, ok := value.(bool); ok {
+		return b
+	}
+	return true
+}
+
+// Returns the integer value of number if it has no fraction
+func toInteger(number float64) (int64, bool) {
+	if number != math.Trunc(number) || math.IsInf(number, 0) || number < math.MinInt64 || number >= math.MaxInt64 {
+		return 0, false
+	}
+	return int64(number), true
+}
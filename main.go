@@ -1,13 +1,24 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var inMemoryDb *InMemoryStore
 
+// Runs the command form value of a POST. The reply is sent as text, or as
+// {"result": ...} JSON if the Accept header prefers application/json, with
+// an error status for error replies.
 func handler(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
 		http.Error(w, "404 not found.", http.StatusNotFound)
@@ -15,24 +26,252 @@ func handler(w http.ResponseWriter, r *http.Request) {
 	}
 	switch r.Method {
 	case "POST":
+		limitBody(w, r)
 		if err := r.ParseForm(); err != nil {
-			fmt.Fprintf(w, "ParseForm() err: %v", err)
+			if status, message := bodyError(err); status == http.StatusRequestEntityTooLarge {
+				http.Error(w, message, status)
+				return
+			}
+			http.Error(w, fmt.Sprintf("ParseForm() err: %v", err), http.StatusBadRequest)
 			return
 		}
 		command := r.FormValue("command")
-		result := inMemoryDb.ProcessCommand(command)
+		result := inMemoryDb.ProcessCommandReply(command)
+		status := http.StatusOK
+		if result.isError() {
+			status = replyStatus(result.Text)
+		}
+		if acceptQuality(r, "application/json") > acceptQuality(r, "text/plain") {
+			if status != http.StatusOK {
+				writeJSONError(w, status, result.Text)
+			} else {
+				writeJSON(w, status, map[string]interface{}{"result": replyToJSON(result)})
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
 		fmt.Fprintf(w, "%s\n", result)
 
 	default:
-		fmt.Fprintf(w, "Sorry, only POST method supported.")
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Sorry, only POST method supported.", http.StatusMethodNotAllowed)
+	}
+}
+
+// Runs the commands of a POST body in order and replies with the JSON array
+// of their results. The body is either a JSON array whose items are command
+// lines or arrays of arguments, eg. ["SET k v", ["SET", "greeting", "hello world"]],
+// or command lines separated by newlines, blank ones being skipped.
+func batchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Sorry, only POST method supported.", http.StatusMethodNotAllowed)
+		return
+	}
+	body, status, message := readBody(w, r)
+	if status != 0 {
+		http.Error(w, message, status)
+		return
+	}
+	commands, err := batchCommands(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	results := make([]string, len(commands))
+	for i, command := range commands {
+		results[i] = inMemoryDb.ProcessCommand(command)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// Command lines of a batch body, see batchHandler
+func batchCommands(body []byte) ([]string, error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		var commands []string
+		for _, line := range strings.Split(string(body), "\n") {
+			if line = strings.TrimSuffix(line, "\r"); strings.TrimSpace(line) != "" {
+				commands = append(commands, line)
+			}
+		}
+		return commands, nil
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(trimmed, &items); err != nil {
+		return nil, errors.New("Invalid JSON array of commands: " + err.Error())
+	}
+	commands := make([]string, len(items))
+	for i, item := range items {
+		var arguments []string
+		if err := json.Unmarshal(item, &commands[i]); err == nil {
+			continue
+		}
+		if err := json.Unmarshal(item, &arguments); err != nil || len(arguments) == 0 {
+			return nil, fmt.Errorf("Command %d should be a string or a non empty array of strings.", i)
+		}
+		commands[i] = formatCommand(arguments...)
 	}
+	return commands, nil
+}
+
+// Time after which a keep-alive comment is sent on an idle event stream, so
+// proxies don't close it
+var sseKeepAlive = 15 * time.Second
+
+// Streams messages of the channels, patterns and shard channels given as
+// query parameters as Server-Sent Events, eg. GET /subscribe?channel=news&pattern=sports.*
+// Each event is named after the message kind and carries JSON data like
+// {"channel":"news","data":"hello"}. The headers are sent right away, the
+// subscribe confirmations follow as the first events, and a ": keep-alive"
+// comment is sent every sseKeepAlive.
+func subscribeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Sorry, only GET method supported.", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	channels, patterns, shardChannels := query["channel"], query["pattern"], query["shardchannel"]
+	if len(channels)+len(patterns)+len(shardChannels) == 0 {
+		http.Error(w, "At least one channel, pattern or shardchannel parameter is needed.", http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported.", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	subscriber := CreateSubscriber()
+	pubsub := inMemoryDb.pubsub
+	defer pubsub.UnsubscribeAll(subscriber)
+	if len(channels) > 0 {
+		pubsub.Subscribe(subscriber, channels)
+	}
+	if len(patterns) > 0 {
+		pubsub.PSubscribe(subscriber, patterns)
+	}
+	if len(shardChannels) > 0 {
+		pubsub.SSubscribe(subscriber, shardChannels)
+	}
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case message := <-subscriber.Messages:
+			data, _ := json.Marshal(message)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", message.Kind, data)
+			if len(subscriber.Messages) == 0 {
+				flusher.Flush()
+			}
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case <-subscriber.Dropped:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// Routes of the HTTP interface, described in openapi.yaml
+func httpHandler() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", handler)
+	mux.HandleFunc("/subscribe", subscribeHandler)
+	mux.HandleFunc("/batch", batchHandler)
+	mux.HandleFunc("/keys/", keysHandler)
+	mux.HandleFunc("/zsets/", zsetsHandler)
+	return mux
+}
+
+// host:port addresses to listen on at port for the addresses of bind, "*"
+// being every interface. There are none if port is 0.
+func listenAddresses(bind string, port int) []string {
+	if port == 0 {
+		return nil
+	}
+	var addresses []string
+	for _, host := range strings.Fields(bind) {
+		if host == "*" {
+			host = ""
+		}
+		addresses = append(addresses, net.JoinHostPort(host, strconv.Itoa(port)))
+	}
+	return addresses
 }
 
 func main() {
-	inMemoryDb = CreateInMemStore(5, "AOF.log")
-	http.HandleFunc("/", handler)
-	fmt.Println("Server starting at at http://localhost:8080/ use ctrl+c to stop.\n" +
-		"You can send commads as x-www-form-urlencoded POST request key value eg. 'command=SET k1 v1' \n" +
-		"Eg.:\n\ncurl -d 'command=SET edtech awesome' http://localhost:8080/\n\n ")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	if len(os.Args) > 1 && os.Args[1] == "cli" {
+		os.Exit(runCLI(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && (os.Args[1] == "-h" || os.Args[1] == "--help") {
+		fmt.Println("Usage: go run ./ [/path/to/redis.conf] [--name value ...]\n" +
+			"       go run ./ cli [options] [command [arg ...]]\n\n" +
+			"Settings given as --name value override those of the config file, eg. --port 6380 --appendonly no")
+		return
+	}
+	config, err := LoadConfig(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Bad configuration:", err)
+		os.Exit(1)
+	}
+	if config.LogFile != "" {
+		file, err := os.OpenFile(config.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.SetOutput(file)
+	}
+	// the AOF is relative to dir like in redis
+	if err := os.Chdir(config.Dir); err != nil {
+		log.Fatal(err)
+	}
+	inMemoryDb = CreateInMemStoreWithConfig(config)
+
+	respAddresses := listenAddresses(config.Bind, config.Port)
+	httpAddresses := listenAddresses(config.Bind, config.HTTPPort)
+	if len(respAddresses)+len(httpAddresses) == 0 {
+		log.Fatal("port and http-port can't both be 0")
+	}
+	// the server stops when one of its listeners fails
+	failed := make(chan error)
+	for _, address := range respAddresses {
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go func(listener net.Listener) {
+			failed <- ServeRESP(listener, inMemoryDb)
+		}(listener)
+	}
+	for _, address := range httpAddresses {
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go func(listener net.Listener) {
+			failed <- http.Serve(listener, httpHandler())
+		}(listener)
+	}
+	if len(httpAddresses) > 0 {
+		url := fmt.Sprintf("http://localhost:%d/", config.HTTPPort)
+		fmt.Println("Server starting at at " + url + " use ctrl+c to stop.\n" +
+			"You can send commads as x-www-form-urlencoded POST request key value eg. 'command=SET k1 v1' \n" +
+			"Eg.:\n\ncurl -d 'command=SET edtech awesome' " + url + "\n\n" +
+			"Keys and sorted sets are also served as JSON at " + url + "keys/{key} and " + url + "zsets/{key}\n" +
+			"Several commands can be POSTed at once to " + url + "batch one per line or as a JSON array")
+	}
+	if len(respAddresses) > 0 {
+		fmt.Printf("Redis clients like redis-cli can connect at localhost:%d\n \n", config.Port)
+	}
+	log.Fatal(<-failed)
 }
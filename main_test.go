@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"github.com/thedeveloperr/redis-clone/httpClient"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSubscribeHandlerStreamsEvents(t *testing.T) {
+	inMemoryDb = CreateTestDbSetup()
+	server := httptest.NewServer(http.HandlerFunc(subscribeHandler))
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/subscribe?channel=news&pattern=sports.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if contentType := response.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("Expected text/event-stream but got " + contentType)
+	}
+	reader := bufio.NewReader(response.Body)
+	readEvent := func() string {
+		var lines []string
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if line == "\n" {
+				return strings.Join(lines, "")
+			}
+			lines = append(lines, line)
+		}
+	}
+	expected := []string{
+		"event: subscribe\ndata: {\"channel\":\"news\",\"count\":1}\n",
+		"event: psubscribe\ndata: {\"channel\":\"sports.*\",\"count\":2}\n",
+	}
+	for _, event := range expected {
+		if result := readEvent(); result != event {
+			t.Errorf("Expected:\n" + event + "Got result:\n" + result)
+		}
+	}
+	inMemoryDb.ProcessCommand("PUBLISH news hello")
+	inMemoryDb.ProcessCommand(`PUBLISH sports.tennis "match point"`)
+	expected = []string{
+		"event: message\ndata: {\"channel\":\"news\",\"data\":\"hello\"}\n",
+		"event: pmessage\ndata: {\"pattern\":\"sports.*\",\"channel\":\"sports.tennis\",\"data\":\"match point\"}\n",
+	}
+	for _, event := range expected {
+		if result := readEvent(); result != event {
+			t.Errorf("Expected:\n" + event + "Got result:\n" + result)
+		}
+	}
+}
+
+func TestSubscribeHandlerSendsKeepAlives(t *testing.T) {
+	inMemoryDb = CreateTestDbSetup()
+	defer func(interval time.Duration) { sseKeepAlive = interval }(sseKeepAlive)
+	sseKeepAlive = 20 * time.Millisecond
+	server := httptest.NewServer(http.HandlerFunc(subscribeHandler))
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/subscribe?channel=news")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	reader := bufio.NewReader(response.Body)
+	expected := []string{
+		"event: subscribe\n",
+		"data: {\"channel\":\"news\",\"count\":1}\n",
+		"\n",
+		": keep-alive\n",
+		"\n",
+	}
+	for _, line := range expected {
+		if result, err := reader.ReadString('\n'); result != line || err != nil {
+			t.Errorf("Expected %q but got %q %v", line, result, err)
+		}
+	}
+}
+
+func TestSubscribeHandlerRejectsBadRequests(t *testing.T) {
+	inMemoryDb = CreateTestDbSetup()
+	recorder := httptest.NewRecorder()
+	subscribeHandler(recorder, httptest.NewRequest("POST", "/subscribe?channel=news", nil))
+	if recorder.Code != http.StatusMethodNotAllowed || recorder.Header().Get("Allow") != "GET" {
+		t.Errorf("Expected 405 with Allow: GET but got %v %v", recorder.Code, recorder.Header())
+	}
+	recorder = httptest.NewRecorder()
+	subscribeHandler(recorder, httptest.NewRequest("GET", "/subscribe", nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without channels but got %v", recorder.Code)
+	}
+}
+
+func TestBatchHandler(t *testing.T) {
+	inMemoryDb = CreateTestDbSetup()
+	cases := []struct {
+		body     string
+		expected string
+	}{
+		{"SET k v\nGET k\r\n\nINCR k\n", `["OK","v","ERR value is not an integer or out of range"]`},
+		{`["SET k \"a b\"", ["APPEND", "k", " c"], ["GET", "k"]]`, `["OK","5","a b c"]`},
+		{"", `[]`},
+		{"[]", `[]`},
+	}
+	for _, c := range cases {
+		recorder := httptest.NewRecorder()
+		batchHandler(recorder, httptest.NewRequest("POST", "/batch", strings.NewReader(c.body)))
+		if result := strings.TrimSpace(recorder.Body.String()); recorder.Code != http.StatusOK || result != c.expected {
+			t.Errorf("Posted:%q. Expected: %v but Got result: %v %v", c.body, c.expected, recorder.Code, result)
+		}
+		if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("Expected application/json but got " + contentType)
+		}
+	}
+	for _, body := range []string{`["GET k"`, `[1]`, `[[]]`} {
+		recorder := httptest.NewRecorder()
+		batchHandler(recorder, httptest.NewRequest("POST", "/batch", strings.NewReader(body)))
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("Posted:%q. Expected 400 but got %v", body, recorder.Code)
+		}
+	}
+	recorder := httptest.NewRecorder()
+	batchHandler(recorder, httptest.NewRequest("GET", "/batch", nil))
+	if recorder.Code != http.StatusMethodNotAllowed || recorder.Header().Get("Allow") != "POST" {
+		t.Errorf("Expected 405 with Allow: POST but got %v %v", recorder.Code, recorder.Header())
+	}
+}
+
+func TestHandlerStatusAndContentNegotiation(t *testing.T) {
+	inMemoryDb = CreateTestDbSetup()
+	cases := []struct {
+		command  string
+		accept   string
+		status   int
+		expected string
+	}{
+		{"SET k v", "", 200, "OK\n"},
+		{"NOPE", "", 400, "COMMAND NOT VALID\n"},
+		{"RPUSH l a b", "application/json", 200, `{"result":2}` + "\n"},
+		{"LRANGE l 0 -1", "application/json", 200, `{"result":["a","b"]}` + "\n"},
+		{"GET missing", "application/json, text/plain;q=0.5", 200, `{"result":null}` + "\n"},
+		{"PFADD k x", "application/json", 409, `{"error":"WRONGTYPE Key is not a valid HyperLogLog string value."}` + "\n"},
+		{"GET k", "text/plain, application/json", 200, "v\n"},
+	}
+	for _, c := range cases {
+		request := httptest.NewRequest("POST", "/", strings.NewReader("command="+url.QueryEscape(c.command)))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set("Accept", c.accept)
+		recorder := httptest.NewRecorder()
+		handler(recorder, request)
+		if recorder.Code != c.status || recorder.Body.String() != c.expected {
+			t.Errorf("Ran:%v. Expected: %v %q but Got result: %v %q", c.command, c.status, c.expected, recorder.Code, recorder.Body.String())
+		}
+	}
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest("GET", "/", nil))
+	if recorder.Code != http.StatusMethodNotAllowed || recorder.Header().Get("Allow") != "POST" {
+		t.Errorf("Expected 405 with Allow: POST but got %v %v", recorder.Code, recorder.Header())
+	}
+}
+
+func TestHTTPClient(t *testing.T) {
+	inMemoryDb = CreateTestDbSetup()
+	server := httptest.NewServer(httpHandler())
+	defer server.Close()
+	client := httpClient.Create(server.URL, nil)
+	defer client.Close()
+	ctx := context.Background()
+
+	if err := client.Set(ctx, "a/b", "hello world"); err != nil {
+		t.Fatal(err)
+	}
+	if value, found, err := client.Get(ctx, "a/b"); value != "hello world" || !found || err != nil {
+		t.Errorf("Expected hello world but got %v %v %v", value, found, err)
+	}
+	if reply, err := client.Do(ctx, "APPEND", "a/b", "\n!"); reply != int64(13) || err != nil {
+		t.Errorf("Expected 13 but got %#v %v", reply, err)
+	}
+	if reply, err := client.Command(ctx, "GET a/b"); reply != "hello world\n!" || err != nil {
+		t.Errorf("Expected the appended value but got %#v %v", reply, err)
+	}
+	if _, err := client.Do(ctx, "NOPE"); !httpClient.IsStatus(err, http.StatusBadRequest) || err.Error() != "COMMAND NOT VALID" {
+		t.Errorf("Expected COMMAND NOT VALID with 400 but got %v", err)
+	}
+	if deleted, err := client.Delete(ctx, "a/b"); !deleted || err != nil {
+		t.Errorf("Expected the key to be deleted but got %v %v", deleted, err)
+	}
+	if _, found, err := client.Get(ctx, "a/b"); found || err != nil {
+		t.Errorf("Expected the key to be gone but got %v %v", found, err)
+	}
+
+	added, err := client.ZAdd(ctx, "board", httpClient.ScoredMember{Member: "alice", Score: 1.5}, httpClient.ScoredMember{Member: "bob", Score: 3})
+	if added != 2 || err != nil {
+		t.Errorf("Expected 2 added but got %v %v", added, err)
+	}
+	if members, err := client.ZRange(ctx, "board", 0, -1); !reflect.DeepEqual(members, []string{"alice", "bob"}) || err != nil {
+		t.Errorf("Expected [alice bob] but got %v %v", members, err)
+	}
+	if members, err := client.ZRangeWithScores(ctx, "board", -1, -1); !reflect.DeepEqual(members, []httpClient.ScoredMember{{Member: "bob", Score: 3}}) || err != nil {
+		t.Errorf("Expected [bob 3] but got %v %v", members, err)
+	}
+	replies, err := client.Batch(ctx, []string{"SET", "n", "1"}, []string{"INCR", "n"}, []string{"NOPE"})
+	if !reflect.DeepEqual(replies, []string{"OK", "2", "COMMAND NOT VALID"}) || err != nil {
+		t.Errorf("Expected [OK 2 COMMAND NOT VALID] but got %q %v", replies, err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	messages := make(chan httpClient.Message, 10)
+	done := make(chan error)
+	go func() {
+		done <- client.Subscribe(ctx, []string{"news"}, nil, nil, func(message httpClient.Message) { messages <- message })
+	}()
+	if message := <-messages; message.Kind != "subscribe" || message.Channel != "news" || message.Count != 1 {
+		t.Errorf("Expected the subscription confirmation but got %+v", message)
+	}
+	client.Do(ctx, "PUBLISH", "news", "hello")
+	if message := <-messages; message.Kind != "message" || message.Payload != "hello" {
+		t.Errorf("Expected the published message but got %+v", message)
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Expected Subscribe to stop when cancelled but got %v", err)
+	}
+}
+
+func TestRequestBodyLimit(t *testing.T) {
+	inMemoryDb = CreateTestDbSetup()
+	defer func(limit int64) { maxRequestBody = limit }(maxRequestBody)
+	maxRequestBody = 16
+	recorder := httptest.NewRecorder()
+	batchHandler(recorder, httptest.NewRequest("POST", "/batch", strings.NewReader("SET k 0123456789abcdef")))
+	if recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for a long batch but got %v", recorder.Code)
+	}
+	request := httptest.NewRequest("POST", "/", strings.NewReader("command="+url.QueryEscape("SET k 0123456789abcdef")))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder = httptest.NewRecorder()
+	handler(recorder, request)
+	if recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for a long command but got %v", recorder.Code)
+	}
+	expectREST(t, "PUT", "/keys/k", nil, "0123456789abcdef!", 413, `{"error":"The request body is too large."}`)
+	expectREST(t, "POST", "/zsets/z/members", jsonContent, `{"member":"alice","score":1.5}`, 413, `{"error":"The request body is too large."}`)
+	expectREST(t, "PUT", "/keys/k", nil, "0123456789abcdef", 200, `{"key":"k","value":"0123456789abcdef"}`)
+}
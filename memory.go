@@ -0,0 +1,67 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// How long a measure of the memory used is reused, as measuring it stops
+// every goroutine for a moment
+const MEMORY_SAMPLE_INTERVAL = 100 * time.Millisecond
+
+var memorySample struct {
+	sync.Mutex
+	memoryUsage
+	taken time.Time
+}
+
+// Bytes of memory the server uses, as reported by INFO memory
+type memoryUsage struct {
+	// the heap in use, which is what maxmemory limits
+	used uint64
+	// the most used was since the server started
+	peak uint64
+	// obtained from the OS by the Go runtime
+	system uint64
+}
+
+// Measures the memory used, reusing the last measure if it is recent
+func sampleMemory() memoryUsage {
+	memorySample.Lock()
+	defer memorySample.Unlock()
+	if time.Since(memorySample.taken) >= MEMORY_SAMPLE_INTERVAL {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		memorySample.used, memorySample.system, memorySample.taken = stats.HeapAlloc, stats.Sys, time.Now()
+		if stats.HeapAlloc > memorySample.peak {
+			memorySample.peak = stats.HeapAlloc
+		}
+	}
+	return memorySample.memoryUsage
+}
+
+// Bytes of the heap in use by the server
+func usedMemory() uint64 {
+	return sampleMemory().used
+}
+
+// Write commands which only remove data, which are still run once
+// maxmemory is reached so memory can be freed, and PFCOUNT, which only
+// refreshes the estimate cached in values
+var shrinkingCommands = map[string]bool{
+	"DEL": true, "EXPIRE": true, "PEXPIREAT": true, "PERSIST": true, "GETDEL": true, "HDEL": true, "PFCOUNT": true,
+	"LPOP": true, "RPOP": true, "BLPOP": true, "BRPOP": true, "LREM": true, "LTRIM": true,
+	"SREM": true, "SPOP": true, "XTRIM": true, "XDEL": true, "XACK": true,
+}
+
+// Whether a command is refused because maxmemory is reached, like with the
+// noeviction policy of redis
+func (store *InMemoryStore) deniedByMaxMemory(commType string) bool {
+	if !writeCommands[commType] || shrinkingCommands[commType] {
+		return false
+	}
+	limit := atomic.LoadInt64(&store.maxMemory)
+	return limit > 0 && usedMemory() > uint64(limit)
+}
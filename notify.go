@@ -0,0 +1,138 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Classes of keyspace events, enabled by the characters of notify-keyspace-events like in redis
+const (
+	NOTIFY_KEYSPACE = 1 << iota // K, published to __keyspace@0__:<key>
+	NOTIFY_KEYEVENT             // E, published to __keyevent@0__:<event>
+	NOTIFY_GENERIC              // g, del, expire and persist
+	NOTIFY_STRING               // $, set, incrby, append, setbit, pfadd...
+	NOTIFY_LIST                 // l, lpush, lpop, lset, ltrim...
+	NOTIFY_SET                  // s, sadd, srem, spop, sinterstore...
+	NOTIFY_HASH                 // h, hset, hdel, hincrby, hexpire, hexpired...
+	NOTIFY_ZSET                 // z, zadd and geosearchstore
+	NOTIFY_EXPIRED              // x, expired
+	NOTIFY_EVICTED              // e, evicted, never published as keys aren't evicted
+	NOTIFY_STREAM               // t, xadd, xtrim, xdel, xgroup-*
+	NOTIFY_KEY_MISS             // m
+	NOTIFY_NEW                  // n
+)
+
+// Classes enabled by A
+const NOTIFY_ALL = NOTIFY_GENERIC | NOTIFY_STRING | NOTIFY_LIST | NOTIFY_SET | NOTIFY_HASH |
+	NOTIFY_ZSET | NOTIFY_EXPIRED | NOTIFY_EVICTED | NOTIFY_STREAM
+
+// Characters of the classes in the order redis shows them
+var notifyClassCharacters = []struct {
+	character byte
+	class     int32
+}{
+	{'g', NOTIFY_GENERIC}, {'$', NOTIFY_STRING}, {'l', NOTIFY_LIST}, {'s', NOTIFY_SET},
+	{'h', NOTIFY_HASH}, {'z', NOTIFY_ZSET}, {'x', NOTIFY_EXPIRED}, {'e', NOTIFY_EVICTED},
+	{'t', NOTIFY_STREAM}, {'K', NOTIFY_KEYSPACE}, {'E', NOTIFY_KEYEVENT},
+	{'m', NOTIFY_KEY_MISS}, {'n', NOTIFY_NEW},
+}
+
+// Parses a notify-keyspace-events value like "KEA" or "Kx". ok is false for unknown characters.
+func parseNotifyFlags(text string) (flags int32, ok bool) {
+	for i := 0; i < len(text); i++ {
+		if text[i] == 'A' {
+			flags |= NOTIFY_ALL
+			continue
+		}
+		known := false
+		for _, class := range notifyClassCharacters {
+			if class.character == text[i] {
+				flags |= class.class
+				known = true
+			}
+		}
+		if !known {
+			return 0, false
+		}
+	}
+	return flags, true
+}
+
+// Reverses parseNotifyFlags, writing A for all the classes it stands for
+func formatNotifyFlags(flags int32) string {
+	var text []byte
+	if flags&NOTIFY_ALL == NOTIFY_ALL {
+		text = append(text, 'A')
+	}
+	for _, class := range notifyClassCharacters {
+		if flags&class.class == 0 || (flags&NOTIFY_ALL == NOTIFY_ALL && class.class&NOTIFY_ALL != 0) {
+			continue
+		}
+		text = append(text, class.character)
+	}
+	return string(text)
+}
+
+// Publishes event about key if its class is enabled, to the keyspace channel
+// of the key and to the keyevent channel of the event, like redis. Nothing is
+// published unless K or E is enabled too.
+func (store *InMemoryStore) notifyKeyspaceEvent(class int32, event string, key string) {
+	flags := atomic.LoadInt32(&store.notifyFlags)
+	if flags&class == 0 {
+		return
+	}
+	if flags&NOTIFY_KEYSPACE != 0 {
+		store.pubsub.Publish("__keyspace@0__:"+key, event)
+	}
+	if flags&NOTIFY_KEYEVENT != 0 {
+		store.pubsub.Publish("__keyevent@0__:"+event, key)
+	}
+}
+
+// Whether key holds a value of any type
+func (store *InMemoryStore) keyExists(key string) bool {
+	_, exists := store.keyspace.Get(key)
+	return exists
+}
+
+// Publishes del for key if the write which changed it removed its last element
+func (store *InMemoryStore) notifyIfRemoved(key string) {
+	if !store.keyExists(key) {
+		store.notifyKeyspaceEvent(NOTIFY_GENERIC, "del", key)
+	}
+}
+
+// Events of a command storing its result in dest, which existed before it
+// ran or not: event if something was stored, else del if dest was removed
+func (store *InMemoryStore) notifyStore(class int32, event string, dest string, existed bool) {
+	if store.keyExists(dest) {
+		store.notifyKeyspaceEvent(class, event, dest)
+	} else if existed {
+		store.notifyKeyspaceEvent(NOTIFY_GENERIC, "del", dest)
+	}
+}
+
+// Event of a timeout set on key: a deadline already passed deletes the key
+func (store *InMemoryStore) notifyExpire(key string, deadline time.Time) {
+	if deadline.After(time.Now()) {
+		store.notifyKeyspaceEvent(NOTIFY_GENERIC, "expire", key)
+	} else {
+		store.notifyKeyspaceEvent(NOTIFY_GENERIC, "del", key)
+	}
+}
+
+// Publishes expired for keys removed once their TTL passed, by their timers
+// or by writes replacing them first, which also aborts the transactions
+// watching them, and hexpired for hash fields removed by their timers
+func (store *InMemoryStore) notifyExpiredKeys() {
+	store.keyspace.OnExpired(func(key string) {
+		store.watches.touch(key)
+		store.notifyKeyspaceEvent(NOTIFY_EXPIRED, "expired", key)
+	})
+	store.hashObject.OnFieldsExpired(func(key string, keyRemoved bool) {
+		store.notifyKeyspaceEvent(NOTIFY_HASH, "hexpired", key)
+		if keyRemoved {
+			store.notifyKeyspaceEvent(NOTIFY_GENERIC, "del", key)
+		}
+	})
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNotifyFlags(t *testing.T) {
+	cases := map[string]string{
+		"":           "",
+		"KEA":        "AKE",
+		"AK":         "AK",
+		"Ex":         "xE",
+		"g$lshzxetK": "AK",
+		"Kz$":        "$zK",
+		"KEmn":       "KEmn",
+	}
+	for flags, expected := range cases {
+		parsed, ok := parseNotifyFlags(flags)
+		if result := formatNotifyFlags(parsed); !ok || result != expected {
+			t.Errorf("Parsed " + flags + ". Expected: " + expected + " but Got result:" + result)
+		}
+	}
+	if _, ok := parseNotifyFlags("KEQ"); ok {
+		t.Errorf("Unknown classes should be rejected")
+	}
+}
+
+func Test_Config_Commands(t *testing.T) {
+	db := CreateTestDbSetup()
+	commands := []struct {
+		command  string
+		expected string
+	}{
+		{"CONFIG GET notify-keyspace-events", "1) 'notify-keyspace-events'\n2) ''\n"},
+		{"CONFIG SET notify-keyspace-events KEA", "OK"},
+		{"CONFIG GET NOTIFY-KEYSPACE-EVENTS", "1) 'notify-keyspace-events'\n2) 'AKE'\n"},
+		{"CONFIG SET notify-keyspace-events KQ", "ERR Invalid argument 'KQ' for CONFIG SET 'notify-keyspace-events'"},
+		{"CONFIG GET notify-keyspace-events", "1) 'notify-keyspace-events'\n2) 'AKE'\n"},
+		{"CONFIG GET missing", "(empty list or set)"},
+		{"CONFIG SET missing 1", "ERR Unknown option or number of arguments for CONFIG SET - 'missing'"},
+		{"CONFIG SET notify-keyspace-events", "COMMAND NOT VALID"},
+		{"CONFIG REWRITE", "ERR The server is running without a config file"},
+	}
+	for _, c := range commands {
+		result := db.ProcessCommand(c.command)
+		if result != c.expected {
+			t.Errorf("Ran:" + c.command + ". Expected: " + c.expected + " but Got result:" + result)
+		}
+	}
+}
+
+// Collects the notifications published for the commands run
+func receiveNotifications(t *testing.T, db *InMemoryStore, commands []string, expected []PubSubMessage) {
+	s := CreateSubscriber()
+	db.pubsub.PSubscribe(s, []string{"__key*@0__:*"})
+	defer db.pubsub.UnsubscribeAll(s)
+	<-s.Messages
+	for _, command := range commands {
+		db.ProcessCommand(command)
+	}
+	for _, message := range expected {
+		select {
+		case received := <-s.Messages:
+			received.Pattern = ""
+			if received != message {
+				t.Errorf("Expected %+v but got %+v", message, received)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Expected %+v but got nothing", message)
+		}
+	}
+	if len(s.Messages) != 0 {
+		t.Errorf("Unexpected notification %+v", <-s.Messages)
+	}
+}
+
+func keyspaceMessage(key string, event string) PubSubMessage {
+	return PubSubMessage{Kind: "pmessage", Channel: "__keyspace@0__:" + key, Payload: event}
+}
+
+func keyevent(event string, key string) PubSubMessage {
+	return PubSubMessage{Kind: "pmessage", Channel: "__keyevent@0__:" + event, Payload: key}
+}
+
+func TestKeyspaceNotifications(t *testing.T) {
+	db := CreateTestDbSetup()
+	// nothing is published by default
+	receiveNotifications(t, db, []string{"SET k v", "ZADD z 1 a"}, nil)
+
+	db.ProcessCommand("CONFIG SET notify-keyspace-events KEA")
+	receiveNotifications(t, db, []string{
+		"SET k v",
+		"MSET a 1 b 2",
+		"ZADD z 2 b",
+		"ZADD z 2 b",
+		"GEOADD places 13.361389 38.115556 Palermo",
+		"EXPIRE k 100",
+		"GETDEL a",
+		"GETDEL a",
+		"PEXPIREAT b 1",
+		"DEL k z missing",
+	}, []PubSubMessage{
+		keyspaceMessage("k", "set"), keyevent("set", "k"),
+		keyspaceMessage("a", "set"), keyevent("set", "a"),
+		keyspaceMessage("b", "set"), keyevent("set", "b"),
+		keyspaceMessage("z", "zadd"), keyevent("zadd", "z"),
+		keyspaceMessage("places", "zadd"), keyevent("zadd", "places"),
+		keyspaceMessage("k", "expire"), keyevent("expire", "k"),
+		keyspaceMessage("a", "del"), keyevent("del", "a"),
+		keyspaceMessage("b", "del"), keyevent("del", "b"),
+		keyspaceMessage("k", "del"), keyevent("del", "k"),
+		keyspaceMessage("z", "del"), keyevent("del", "z"),
+	})
+
+	db.ProcessCommand("CONFIG SET notify-keyspace-events Ex")
+	receiveNotifications(t, db, []string{
+		"SET k v",
+		"SADD s m",
+		"PEXPIREAT k " + strconv.FormatInt(unixMilli(time.Now().Add(50*time.Millisecond)), 10),
+		"PEXPIREAT s " + strconv.FormatInt(unixMilli(time.Now().Add(100*time.Millisecond)), 10),
+	}, []PubSubMessage{keyevent("expired", "k"), keyevent("expired", "s")})
+}
+
+// Both messages of event on key
+func notification(key string, event string) []PubSubMessage {
+	return []PubSubMessage{keyspaceMessage(key, event), keyevent(event, key)}
+}
+
+// Messages of each key and event pair, in order
+func notifications(keysAndEvents ...string) []PubSubMessage {
+	var messages []PubSubMessage
+	for i := 0; i < len(keysAndEvents); i += 2 {
+		messages = append(messages, notification(keysAndEvents[i], keysAndEvents[i+1])...)
+	}
+	return messages
+}
+
+func TestStringNotifications(t *testing.T) {
+	db := CreateTestDbSetup()
+	db.ProcessCommand("CONFIG SET notify-keyspace-events KE$g")
+	receiveNotifications(t, db, []string{
+		"INCR n",
+		"DECRBY n 3",
+		"INCRBYFLOAT f 1.5",
+		"APPEND s abc",
+		"SETRANGE s 1 x",
+		"SETRANGE s 1 \"\"",
+		"SETBIT b 3 1",
+		"BITFIELD b GET u4 0",
+		"BITFIELD b SET u4 0 1",
+		"BITOP NOT nb b",
+		"BITOP AND nb missing",
+		"PFADD h a b",
+		"PFADD h a",
+		"PFMERGE m h",
+		"EXPIRE s 100",
+		"PERSIST s",
+	}, notifications(
+		"n", "incrby", "n", "incrby", "f", "incrbyfloat", "s", "append", "s", "setrange",
+		"b", "setbit", "b", "setbit", "nb", "set", "nb", "del", "h", "pfadd", "m", "pfadd",
+		"s", "expire", "s", "persist"))
+}
+
+func TestListNotifications(t *testing.T) {
+	db := CreateTestDbSetup()
+	db.ProcessCommand("CONFIG SET notify-keyspace-events KElg")
+	receiveNotifications(t, db, []string{
+		"RPUSH l a b c",
+		"LPUSH l z",
+		"LINSERT l BEFORE b x",
+		"LINSERT l BEFORE missing x",
+		"LSET l 0 y",
+		"LREM l 0 x",
+		"LTRIM l 0 1",
+		"LMOVE l other LEFT RIGHT",
+		"LPOP l",
+		"RPOP other 2",
+		"LPOP other",
+	}, notifications(
+		"l", "rpush", "l", "lpush", "l", "linsert", "l", "lset", "l", "lrem", "l", "ltrim",
+		"l", "lpop", "other", "rpush", "l", "lpop", "l", "del", "other", "rpop", "other", "del"))
+}
+
+func TestSetNotifications(t *testing.T) {
+	db := CreateTestDbSetup()
+	db.ProcessCommand("CONFIG SET notify-keyspace-events KEsg")
+	receiveNotifications(t, db, []string{
+		"SADD s a b c",
+		"SADD s a",
+		"SREM s a",
+		"SMOVE s t b",
+		"SINTERSTORE i s t",
+		"SUNIONSTORE u s t",
+		"SDIFFSTORE d s t",
+		"SDIFFSTORE d s s",
+		"SPOP s",
+		"SPOP s",
+	}, notifications(
+		"s", "sadd", "s", "srem", "s", "srem", "t", "sadd", "u", "sunionstore", "d", "sdiffstore",
+		"d", "del", "s", "spop", "s", "del"))
+}
+
+func TestHashNotifications(t *testing.T) {
+	db := CreateTestDbSetup()
+	db.ProcessCommand("CONFIG SET notify-keyspace-events KEhg")
+	receiveNotifications(t, db, []string{
+		"HSET h a 1 b 2",
+		"HSETNX h a 1",
+		"HSETNX h c 3",
+		"HINCRBY h a 1",
+		"HINCRBYFLOAT h b 0.5",
+		"HEXPIRE h 100 FIELDS 1 a",
+		"HPERSIST h FIELDS 1 a",
+		"HPERSIST h FIELDS 1 a",
+		"HDEL h a",
+		"HPEXPIREAT h 1 FIELDS 1 b",
+		"HDEL h c",
+		"HSET e f v",
+		"HPEXPIRE e 50 FIELDS 1 f",
+	}, notifications(
+		"h", "hset", "h", "hset", "h", "hincrby", "h", "hincrbyfloat", "h", "hexpire", "h", "hpersist",
+		"h", "hdel", "h", "hdel", "h", "hdel", "h", "del", "e", "hset", "e", "hexpire",
+		"e", "hexpired", "e", "del"))
+}
+
+func TestStreamNotifications(t *testing.T) {
+	db := CreateTestDbSetup()
+	db.ProcessCommand("CONFIG SET notify-keyspace-events KEt")
+	receiveNotifications(t, db, []string{
+		"XADD s 1-1 f v",
+		"XADD s 2-1 f v",
+		"XADD s NOMKSTREAM 3-1 f v",
+		"XGROUP CREATE s g 0",
+		"XGROUP SETID s g $",
+		"XGROUP CREATECONSUMER s g c",
+		"XGROUP DELCONSUMER s g c",
+		"XREADGROUP GROUP g reader STREAMS s >",
+		"XGROUP DESTROY s g",
+		"XDEL s 1-1",
+		"XTRIM s MAXLEN 0",
+	}, notifications(
+		"s", "xadd", "s", "xadd", "s", "xadd", "s", "xgroup-create", "s", "xgroup-setid",
+		"s", "xgroup-createconsumer", "s", "xgroup-delconsumer", "s", "xgroup-createconsumer",
+		"s", "xgroup-destroy", "s", "xdel", "s", "xtrim"))
+}
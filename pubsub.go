@@ -0,0 +1,283 @@
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+// Messages a subscriber can fall behind by before it is dropped, like redis's
+// client-output-buffer-limit for pubsub clients
+const SUBSCRIBER_BUFFER_SIZE = 1024
+
+// Pushed to subscribers: a message published to a channel (Kind message,
+// pmessage for a pattern subscription or smessage for a shard channel) or the
+// confirmation of a (un)subscription with the number of subscriptions left
+type PubSubMessage struct {
+	Kind    string `json:"-"`
+	Pattern string `json:"pattern,omitempty"`
+	Channel string `json:"channel"`
+	Payload string `json:"data,omitempty"`
+	Count   int    `json:"count,omitempty"`
+	// set when unsubscribing without any subscription, redis then sends a nil channel
+	NilChannel bool `json:"-"`
+}
+
+// A connection listening to channels. Messages are buffered in Messages and
+// Dropped is closed if the buffer fills up because the connection is too slow.
+type Subscriber struct {
+	Messages chan PubSubMessage
+	Dropped  chan struct{}
+	dropOnce sync.Once
+	// whether (un)subscription confirmations are returned by the calls
+	// making them rather than pushed to Messages, so they are sent in
+	// order with the replies of the commands pipelined around them
+	returnsConfirmations bool
+	channels             map[string]bool
+	patterns             map[string]bool
+	shardChannels        map[string]bool
+}
+
+func CreateSubscriber() *Subscriber {
+	return &Subscriber{
+		Messages:      make(chan PubSubMessage, SUBSCRIBER_BUFFER_SIZE),
+		Dropped:       make(chan struct{}),
+		channels:      make(map[string]bool),
+		patterns:      make(map[string]bool),
+		shardChannels: make(map[string]bool),
+	}
+}
+
+func (s *Subscriber) push(message PubSubMessage) {
+	select {
+	case <-s.Dropped:
+		return
+	default:
+	}
+	select {
+	case s.Messages <- message:
+	default:
+		s.dropOnce.Do(func() { close(s.Dropped) })
+	}
+}
+
+// Shard channel subscriptions are counted apart from the others, like redis
+func (s *Subscriber) count(shard bool) int {
+	if shard {
+		return len(s.shardChannels)
+	}
+	return len(s.channels) + len(s.patterns)
+}
+
+// Routes published messages to subscribers of channels, of patterns matching
+// channels and of shard channels. Shard channels are a separate namespace
+// since there is a single shard.
+type PubSub struct {
+	mutex         sync.RWMutex
+	channels      map[string]map[*Subscriber]bool
+	patterns      map[string]map[*Subscriber]bool
+	shardChannels map[string]map[*Subscriber]bool
+}
+
+func CreatePubSub() *PubSub {
+	return &PubSub{
+		channels:      make(map[string]map[*Subscriber]bool),
+		patterns:      make(map[string]map[*Subscriber]bool),
+		shardChannels: make(map[string]map[*Subscriber]bool),
+	}
+}
+
+// Pushes a confirmation to s, or adds it to those returned to the caller if
+// s returns them
+func (s *Subscriber) confirm(confirmations []PubSubMessage, message PubSubMessage) []PubSubMessage {
+	if s.returnsConfirmations {
+		return append(confirmations, message)
+	}
+	s.push(message)
+	return confirmations
+}
+
+// Confirmations are pushed while holding the lock so they always reach the
+// subscriber before messages published after the subscription. Returns them
+// instead if s returns confirmations.
+func (p *PubSub) add(s *Subscriber, names []string, registry map[string]map[*Subscriber]bool, own map[string]bool, kind string, shard bool) (confirmations []PubSubMessage) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, name := range names {
+		if !own[name] {
+			own[name] = true
+			if registry[name] == nil {
+				registry[name] = make(map[*Subscriber]bool)
+			}
+			registry[name][s] = true
+		}
+		confirmations = s.confirm(confirmations, PubSubMessage{Kind: kind, Channel: name, Count: s.count(shard)})
+	}
+	return confirmations
+}
+
+// Removes the given subscriptions, or all of them if names is empty
+func (p *PubSub) remove(s *Subscriber, names []string, registry map[string]map[*Subscriber]bool, own map[string]bool, kind string, shard bool) (confirmations []PubSubMessage) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if len(names) == 0 {
+		if len(own) == 0 {
+			return s.confirm(nil, PubSubMessage{Kind: kind, NilChannel: true, Count: s.count(shard)})
+		}
+		for name := range own {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	for _, name := range names {
+		if own[name] {
+			delete(own, name)
+			delete(registry[name], s)
+			if len(registry[name]) == 0 {
+				delete(registry, name)
+			}
+		}
+		confirmations = s.confirm(confirmations, PubSubMessage{Kind: kind, Channel: name, Count: s.count(shard)})
+	}
+	return confirmations
+}
+
+// Perform SUBSCRIBE channel [channel ...]
+func (p *PubSub) Subscribe(s *Subscriber, channels []string) []PubSubMessage {
+	return p.add(s, channels, p.channels, s.channels, "subscribe", false)
+}
+
+// Perform PSUBSCRIBE pattern [pattern ...]
+func (p *PubSub) PSubscribe(s *Subscriber, patterns []string) []PubSubMessage {
+	return p.add(s, patterns, p.patterns, s.patterns, "psubscribe", false)
+}
+
+// Perform SSUBSCRIBE shardchannel [shardchannel ...]
+func (p *PubSub) SSubscribe(s *Subscriber, channels []string) []PubSubMessage {
+	return p.add(s, channels, p.shardChannels, s.shardChannels, "ssubscribe", true)
+}
+
+// Perform UNSUBSCRIBE [channel ...]
+func (p *PubSub) Unsubscribe(s *Subscriber, channels []string) []PubSubMessage {
+	return p.remove(s, channels, p.channels, s.channels, "unsubscribe", false)
+}
+
+// Perform PUNSUBSCRIBE [pattern ...]
+func (p *PubSub) PUnsubscribe(s *Subscriber, patterns []string) []PubSubMessage {
+	return p.remove(s, patterns, p.patterns, s.patterns, "punsubscribe", false)
+}
+
+// Perform SUNSUBSCRIBE [shardchannel ...]
+func (p *PubSub) SUnsubscribe(s *Subscriber, channels []string) []PubSubMessage {
+	return p.remove(s, channels, p.shardChannels, s.shardChannels, "sunsubscribe", true)
+}
+
+// Removes every subscription of s without confirmations, when its connection closes
+func (p *PubSub) UnsubscribeAll(s *Subscriber) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, subscriptions := range []struct {
+		registry map[string]map[*Subscriber]bool
+		own      map[string]bool
+	}{{p.channels, s.channels}, {p.patterns, s.patterns}, {p.shardChannels, s.shardChannels}} {
+		for name := range subscriptions.own {
+			delete(subscriptions.own, name)
+			delete(subscriptions.registry[name], s)
+			if len(subscriptions.registry[name]) == 0 {
+				delete(subscriptions.registry, name)
+			}
+		}
+	}
+}
+
+// Number of channels, patterns and shard channels s is subscribed to
+func (p *PubSub) SubscriptionCount(s *Subscriber) int {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return s.count(false) + s.count(true)
+}
+
+// Sends payload to subscribers of channel and of patterns matching it.
+// Returns the number of subscriptions it was sent to. Perform PUBLISH channel message
+func (p *PubSub) Publish(channel string, payload string) int {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	received := 0
+	for s := range p.channels[channel] {
+		s.push(PubSubMessage{Kind: "message", Channel: channel, Payload: payload})
+		received++
+	}
+	for pattern, subscribers := range p.patterns {
+		if !globMatch(pattern, channel) {
+			continue
+		}
+		for s := range subscribers {
+			s.push(PubSubMessage{Kind: "pmessage", Pattern: pattern, Channel: channel, Payload: payload})
+			received++
+		}
+	}
+	return received
+}
+
+// Perform SPUBLISH shardchannel message
+func (p *PubSub) SPublish(channel string, payload string) int {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	for s := range p.shardChannels[channel] {
+		s.push(PubSubMessage{Kind: "smessage", Channel: channel, Payload: payload})
+	}
+	return len(p.shardChannels[channel])
+}
+
+func matchingNames(registry map[string]map[*Subscriber]bool, pattern string) []string {
+	names := []string{}
+	for name := range registry {
+		if globMatch(pattern, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Channels with at least one subscriber matching pattern. Perform PUBSUB CHANNELS [pattern]
+func (p *PubSub) Channels(pattern string) []string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return matchingNames(p.channels, pattern)
+}
+
+// Perform PUBSUB SHARDCHANNELS [pattern]
+func (p *PubSub) ShardChannels(pattern string) []string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return matchingNames(p.shardChannels, pattern)
+}
+
+// Number of subscribers of each channel, not counting pattern subscriptions. Perform PUBSUB NUMSUB [channel ...]
+func (p *PubSub) NumSub(channels []string) []int {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	counts := make([]int, len(channels))
+	for i, channel := range channels {
+		counts[i] = len(p.channels[channel])
+	}
+	return counts
+}
+
+// Perform PUBSUB SHARDNUMSUB [shardchannel ...]
+func (p *PubSub) ShardNumSub(channels []string) []int {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	counts := make([]int, len(channels))
+	for i, channel := range channels {
+		counts[i] = len(p.shardChannels[channel])
+	}
+	return counts
+}
+
+// Number of distinct patterns subscribed to. Perform PUBSUB NUMPAT
+func (p *PubSub) NumPat() int {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return len(p.patterns)
+}
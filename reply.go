@@ -0,0 +1,168 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Wraps a value in single quotes the way list items are shown, eg. 'm1'.
+// Backslashes, quotes and control characters are escaped so every item stays on one line.
+func quote(value string) string {
+	var builder strings.Builder
+	builder.WriteByte('\'')
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '\\' || c == '\'':
+			builder.WriteByte('\\')
+			builder.WriteByte(c)
+		case c == '\n':
+			builder.WriteString("\\n")
+		case c == '\r':
+			builder.WriteString("\\r")
+		case c == '\t':
+			builder.WriteString("\\t")
+		case c < 0x20 || c == 0x7f:
+			builder.WriteString("\\x")
+			builder.WriteString(strconv.FormatUint(uint64(c)>>4, 16))
+			builder.WriteString(strconv.FormatUint(uint64(c)&0xf, 16))
+		default:
+			builder.WriteByte(c)
+		}
+	}
+	builder.WriteByte('\'')
+	return builder.String()
+}
+
+// Numbers already formatted items like "1) 'a'\n2) (nil)\n". Items which are
+// lists themselves are nested with their lines indented under the number.
+func formatList(items []string) string {
+	if len(items) == 0 {
+		return "(empty list or set)"
+	}
+	var builder strings.Builder
+	for i, item := range items {
+		prefix := strconv.Itoa(i+1) + ") "
+		lines := strings.Split(strings.TrimSuffix(item, "\n"), "\n")
+		for j, line := range lines {
+			if j == 0 {
+				builder.WriteString(prefix)
+			} else {
+				builder.WriteString(strings.Repeat(" ", len(prefix)))
+			}
+			builder.WriteString(line)
+			builder.WriteByte('\n')
+		}
+	}
+	return builder.String()
+}
+
+// Kinds of replies, the types RESP tells apart
+const (
+	REPLY_STATUS  = iota // short text like OK
+	REPLY_ERROR          // error message like ERR ...
+	REPLY_INTEGER        // number in Integer
+	REPLY_BULK           // binary safe string
+	REPLY_DOUBLE         // number which may not be an integer, like a score, sent as a bulk string
+	REPLY_NIL            // missing value
+	REPLY_ARRAY          // replies in Items
+)
+
+// Reply of a command, turned into RESP, JSON, Lua values or text depending
+// on who ran it. Text holds the status, error message, string or formatted
+// double.
+type Reply struct {
+	Kind    int
+	Text    string
+	Integer int64
+	Items   []Reply
+}
+
+var nilReply = Reply{Kind: REPLY_NIL}
+var okReply = statusReply("OK")
+
+func statusReply(text string) Reply {
+	return Reply{Kind: REPLY_STATUS, Text: text}
+}
+
+func errorReply(message string) Reply {
+	return Reply{Kind: REPLY_ERROR, Text: message}
+}
+
+func integerReply(value int64) Reply {
+	return Reply{Kind: REPLY_INTEGER, Integer: value}
+}
+
+func bulkReply(value string) Reply {
+	return Reply{Kind: REPLY_BULK, Text: value}
+}
+
+// Number already formatted, eg. by hashmap.FormatFloat
+func doubleReply(text string) Reply {
+	return Reply{Kind: REPLY_DOUBLE, Text: text}
+}
+
+// 1 if true, 0 otherwise
+func booleanReply(value bool) Reply {
+	if value {
+		return integerReply(1)
+	}
+	return integerReply(0)
+}
+
+// The empty list for no items, not nil
+func arrayReply(items []Reply) Reply {
+	if items == nil {
+		items = []Reply{}
+	}
+	return Reply{Kind: REPLY_ARRAY, Items: items}
+}
+
+// Array of strings
+func bulkArrayReply(values []string) Reply {
+	items := make([]Reply, len(values))
+	for i, value := range values {
+		items[i] = bulkReply(value)
+	}
+	return arrayReply(items)
+}
+
+// Array of integers
+func integerArrayReply(values []int) Reply {
+	items := make([]Reply, len(values))
+	for i, value := range values {
+		items[i] = integerReply(int64(value))
+	}
+	return arrayReply(items)
+}
+
+func (r Reply) isError() bool {
+	return r.Kind == REPLY_ERROR
+}
+
+// Text of the reply meant to be read by people, as the HTTP handler replies
+// it: strings are shown as they are, or quoted inside lists, missing values
+// are (nil) and lists are numbered by formatList.
+func (r Reply) String() string {
+	return r.format(true)
+}
+
+func (r Reply) format(topLevel bool) string {
+	switch r.Kind {
+	case REPLY_INTEGER:
+		return strconv.FormatInt(r.Integer, 10)
+	case REPLY_NIL:
+		return "(nil)"
+	case REPLY_ARRAY:
+		items := make([]string, len(r.Items))
+		for i, item := range r.Items {
+			items[i] = item.format(false)
+		}
+		return formatList(items)
+	case REPLY_BULK:
+		if !topLevel {
+			return quote(r.Text)
+		}
+	}
+	return r.Text
+}
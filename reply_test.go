@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestQuote(t *testing.T) {
+	cases := map[string]string{
+		"m1":         "'m1'",
+		"it's":       "'it\\'s'",
+		"a\\b":       "'a\\\\b'",
+		"two\nlines": "'two\\nlines'",
+		"\x00":       "'\\x00'",
+	}
+	for value, expected := range cases {
+		if result := quote(value); result != expected {
+			t.Errorf("Expected: " + expected + " Got result:" + result)
+		}
+	}
+}
+
+func TestFormatList(t *testing.T) {
+	if result := formatList(nil); result != "(empty list or set)" {
+		t.Errorf("Expected: (empty list or set) Got result:" + result)
+	}
+	result := formatList([]string{"'a'", "(nil)", "0.5"})
+	expected := "1) 'a'\n2) (nil)\n3) 0.5\n"
+	if result != expected {
+		t.Errorf("Expected:\n" + expected + "Got result:\n" + result)
+	}
+
+	nested := formatList([]string{"'id'", formatList([]string{"'f'", "'v'"})})
+	expected = "1) 'id'\n2) 1) 'f'\n   2) 'v'\n"
+	if nested != expected {
+		t.Errorf("Expected:\n" + expected + "Got result:\n" + nested)
+	}
+}
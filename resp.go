@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"github.com/thedeveloperr/redis-clone/hashmap"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Most arguments accepted in one command, like redis
+const MAX_MULTIBULK_LENGTH = 1024 * 1024
+
+// Longest inline command line
+const MAX_INLINE_LENGTH = 64 * 1024
+
+var ErrUnbalancedQuotes = errors.New("ERR Protocol error: unbalanced quotes in request")
+var ErrInvalidMultibulkLength = errors.New("ERR Protocol error: invalid multibulk length")
+var ErrInvalidBulkLength = errors.New("ERR Protocol error: invalid bulk length")
+var ErrTooBigInlineRequest = errors.New("ERR Protocol error: too big inline request")
+
+// Errors the reader can't recover from, they are sent to the client before closing the connection
+func isProtocolError(err error) bool {
+	return strings.HasPrefix(err.Error(), "ERR Protocol error")
+}
+
+// Reads a line ending in \r\n, or just \n like redis accepts for inline commands
+func readLine(reader *bufio.Reader, limit int) (string, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := reader.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		line = append(line, chunk...)
+		if len(line) > limit {
+			return "", ErrTooBigInlineRequest
+		}
+		if !isPrefix {
+			return string(line), nil
+		}
+	}
+}
+
+// Reads the next command sent by a client: an array of bulk strings as sent by
+// redis clients, or an inline command line as typed in telnet which is split
+// like redis-cli does. Empty inline lines give no arguments.
+func readCommand(reader *bufio.Reader) (args []string, err error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if first[0] != '*' {
+		line, err := readLine(reader, MAX_INLINE_LENGTH)
+		if err != nil {
+			return nil, err
+		}
+		args, ok := splitArgs(line)
+		if !ok {
+			return nil, ErrUnbalancedQuotes
+		}
+		return args, nil
+	}
+	header, err := readLine(reader, MAX_INLINE_LENGTH)
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(header[1:])
+	if err != nil || count > MAX_MULTIBULK_LENGTH {
+		return nil, ErrInvalidMultibulkLength
+	}
+	for i := 0; i < count; i++ {
+		header, err := readLine(reader, MAX_INLINE_LENGTH)
+		if err != nil {
+			return nil, err
+		}
+		if len(header) == 0 || header[0] != '$' {
+			quoted := ""
+			if len(header) > 0 {
+				quoted = header[:1]
+			}
+			return nil, errors.New("ERR Protocol error: expected '$', got '" + quoted + "'")
+		}
+		length, err := strconv.Atoi(header[1:])
+		if err != nil || length < 0 || length > hashmap.MAX_STRING_LENGTH {
+			return nil, ErrInvalidBulkLength
+		}
+		bulk := make([]byte, length+2)
+		if _, err := io.ReadFull(reader, bulk); err != nil {
+			return nil, err
+		}
+		args = append(args, string(bulk[:length]))
+	}
+	return args, nil
+}
+
+// Replies starting with these are errors
+var errorReplyPrefixes = []string{"ERR ", "WRONGTYPE ", "INVALIDOBJ ", "NOGROUP ", "BUSYGROUP ", "EXECABORT ", "NOSCRIPT ", "OOM "}
+
+func isErrorReply(reply string) bool {
+	if reply == "COMMAND NOT VALID" {
+		return true
+	}
+	for _, prefix := range errorReplyPrefixes {
+		if strings.HasPrefix(reply, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func appendBulk(buffer []byte, value string) []byte {
+	buffer = append(buffer, '$')
+	buffer = strconv.AppendInt(buffer, int64(len(value)), 10)
+	buffer = append(buffer, "\r\n"...)
+	buffer = append(buffer, value...)
+	return append(buffer, "\r\n"...)
+}
+
+func appendInteger(buffer []byte, value int64) []byte {
+	buffer = append(buffer, ':')
+	buffer = strconv.AppendInt(buffer, value, 10)
+	return append(buffer, "\r\n"...)
+}
+
+func appendArrayHeader(buffer []byte, length int) []byte {
+	buffer = append(buffer, '*')
+	buffer = strconv.AppendInt(buffer, int64(length), 10)
+	return append(buffer, "\r\n"...)
+}
+
+// Converts a reply to RESP. Doubles are bulk strings like in RESP2, and
+// line breaks of error messages become spaces as they end the line.
+func appendReply(buffer []byte, reply Reply) []byte {
+	switch reply.Kind {
+	case REPLY_STATUS:
+		buffer = append(buffer, '+')
+		buffer = append(buffer, reply.Text...)
+		return append(buffer, "\r\n"...)
+	case REPLY_ERROR:
+		buffer = append(buffer, '-')
+		buffer = append(buffer, strings.NewReplacer("\r", " ", "\n", " ").Replace(reply.Text)...)
+		return append(buffer, "\r\n"...)
+	case REPLY_INTEGER:
+		return appendInteger(buffer, reply.Integer)
+	case REPLY_NIL:
+		return append(buffer, "$-1\r\n"...)
+	case REPLY_ARRAY:
+		buffer = appendArrayHeader(buffer, len(reply.Items))
+		for _, item := range reply.Items {
+			buffer = appendReply(buffer, item)
+		}
+		return buffer
+	}
+	return appendBulk(buffer, reply.Text)
+}
+
+// Encodes a message pushed to a subscriber the way redis does: an array of
+// its kind, the pattern for pmessage, the channel and either the payload or,
+// for (un)subscribe confirmations, the number of subscriptions
+func appendPubSubMessage(buffer []byte, message PubSubMessage) []byte {
+	switch message.Kind {
+	case "message", "smessage":
+		buffer = appendArrayHeader(buffer, 3)
+		buffer = appendBulk(buffer, message.Kind)
+		buffer = appendBulk(buffer, message.Channel)
+		return appendBulk(buffer, message.Payload)
+	case "pmessage":
+		buffer = appendArrayHeader(buffer, 4)
+		buffer = appendBulk(buffer, message.Kind)
+		buffer = appendBulk(buffer, message.Pattern)
+		buffer = appendBulk(buffer, message.Channel)
+		return appendBulk(buffer, message.Payload)
+	}
+	buffer = appendArrayHeader(buffer, 3)
+	buffer = appendBulk(buffer, message.Kind)
+	if message.NilChannel {
+		buffer = append(buffer, "$-1\r\n"...)
+	} else {
+		buffer = appendBulk(buffer, message.Channel)
+	}
+	return appendInteger(buffer, int64(message.Count))
+}
@@ -0,0 +1,231 @@
+// Package respClient is a client of the RESP listener of the server, which
+// any redis client can also use. Connections are pooled, commands can be
+// pipelined, every call takes a context whose deadline and cancellation
+// apply to it, and connections the server closed are replaced by new ones.
+// Common commands of each data type have typed helpers, in the commands
+// file of the type, and Do runs any command.
+package respClient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrClosed = errors.New("respClient: client is closed")
+
+// Settings of a Client, zero fields take the defaults below
+type Options struct {
+	// Most connections open at once, callers wait for one beyond. 10 by default.
+	PoolSize int
+	// 5 seconds by default
+	DialTimeout time.Duration
+	// Times a command is sent again on a new connection when the one it was
+	// sent on turns out to be closed, or connecting fails. 3 by default and
+	// -1 for none. Commands which may write, like INCR, are only sent again
+	// when nothing of them was sent, as the server may have run them.
+	MaxRetries int
+	// Wait before connecting again, doubled each time up to MaxRetryBackoff.
+	// 50 milliseconds and 2 seconds by default.
+	MinRetryBackoff time.Duration
+	MaxRetryBackoff time.Duration
+}
+
+type Client struct {
+	address string
+	options Options
+	// idle connections, and a token for each connection in use
+	idle   chan *conn
+	tokens chan struct{}
+
+	mutex  sync.Mutex
+	closed bool
+}
+
+// Creates a client of the server at address, eg. localhost:6379. Connections
+// are opened when needed. options can be nil.
+func Create(address string, options *Options) *Client {
+	client := &Client{address: address}
+	if options != nil {
+		client.options = *options
+	}
+	o := &client.options
+	if o.PoolSize <= 0 {
+		o.PoolSize = 10
+	}
+	if o.DialTimeout == 0 {
+		o.DialTimeout = 5 * time.Second
+	}
+	if o.MaxRetries == 0 {
+		o.MaxRetries = 3
+	}
+	if o.MinRetryBackoff == 0 {
+		o.MinRetryBackoff = 50 * time.Millisecond
+	}
+	if o.MaxRetryBackoff == 0 {
+		o.MaxRetryBackoff = 2 * time.Second
+	}
+	client.idle = make(chan *conn, o.PoolSize)
+	client.tokens = make(chan struct{}, o.PoolSize)
+	return client
+}
+
+// Closes the idle connections, the ones in use are closed when released.
+// Commands sent afterwards fail with ErrClosed.
+func (c *Client) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	for {
+		select {
+		case cn := <-c.idle:
+			cn.close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (c *Client) isClosed() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.closed
+}
+
+// Takes an idle connection or opens one, waiting while the pool is full.
+// reused tells whether the connection was idle, the server may have closed
+// it since.
+func (c *Client) getConn(ctx context.Context) (cn *conn, reused bool, err error) {
+	if c.isClosed() {
+		return nil, false, ErrClosed
+	}
+	select {
+	case c.tokens <- struct{}{}:
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+	select {
+	case cn := <-c.idle:
+		return cn, true, nil
+	default:
+	}
+	cn, err = dial(ctx, c.address, c.options.DialTimeout)
+	if err != nil {
+		<-c.tokens
+		return nil, false, err
+	}
+	return cn, false, nil
+}
+
+// Gives back a connection, closing it if it failed
+func (c *Client) putConn(cn *conn, failed bool) {
+	defer func() { <-c.tokens }()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if failed || c.closed {
+		cn.close()
+		return
+	}
+	select {
+	case c.idle <- cn:
+	default:
+		cn.close()
+	}
+}
+
+// Runs f on a pooled connection. If the connection fails, f runs again on a
+// new one when the failure is the server having closed an idle connection,
+// or when connecting fails, waiting more each time. Once f sent something,
+// it only runs again if readOnly is true, since the server may have run the
+// commands which reached it.
+func (c *Client) withConn(ctx context.Context, readOnly bool, f func(cn *conn) error) error {
+	backoff := c.options.MinRetryBackoff
+	for attempt := 0; ; attempt++ {
+		cn, reused, err := c.getConn(ctx)
+		if err == nil {
+			sent := cn.sent
+			err = cn.withContext(ctx, func() error { return f(cn) })
+			c.putConn(cn, err != nil)
+			if err == nil || !reused || !isNetworkError(err) || ctx.Err() != nil {
+				return err
+			}
+			if cn.sent != sent && !readOnly {
+				return err
+			}
+			// idle connections closed by the server are replaced right away
+			if attempt < c.options.MaxRetries {
+				continue
+			}
+			return err
+		}
+		if !isNetworkError(err) || ctx.Err() != nil || attempt >= c.options.MaxRetries {
+			return err
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > c.options.MaxRetryBackoff {
+			backoff = c.options.MaxRetryBackoff
+		}
+	}
+}
+
+// Commands which don't change anything, which are safe to send again after
+// they may have run
+var readOnlyCommands = map[string]bool{
+	"PING": true, "GET": true, "MGET": true, "STRLEN": true, "GETRANGE": true,
+	"GETBIT": true, "BITCOUNT": true, "BITPOS": true, "TYPE": true, "SCAN": true, "DBSIZE": true,
+	"HGET": true, "HMGET": true, "HGETALL": true, "HEXISTS": true, "HLEN": true, "HKEYS": true,
+	"HVALS": true, "HSTRLEN": true, "HRANDFIELD": true, "HTTL": true, "HPTTL": true, "HSCAN": true,
+	"LRANGE": true, "LINDEX": true, "LLEN": true, "LPOS": true,
+	"SISMEMBER": true, "SMISMEMBER": true, "SMEMBERS": true, "SCARD": true, "SRANDMEMBER": true,
+	"SINTER": true, "SUNION": true, "SDIFF": true, "SINTERCARD": true, "SSCAN": true,
+	"ZRANGE": true, "ZRANK": true, "ZCARD": true,
+	"GEOPOS": true, "GEODIST": true, "GEOHASH": true, "GEOSEARCH": true,
+	"XRANGE": true, "XREVRANGE": true, "XLEN": true, "XREAD": true, "XPENDING": true,
+	"FCALL_RO": true,
+}
+
+func isReadOnly(args []string) bool {
+	return len(args) > 0 && readOnlyCommands[strings.ToUpper(args[0])]
+}
+
+func isNetworkError(err error) bool {
+	var netError net.Error
+	return errors.As(err, &netError) || err == io.EOF || err == io.ErrUnexpectedEOF
+}
+
+// Runs a command given as its arguments, eg. Do(ctx, "SET", "greeting", "hello world").
+// The reply is nil, a string, an int64 or a []interface{} of replies, in
+// which error replies are *Error values. An error reply of the command is
+// returned as an *Error.
+func (c *Client) Do(ctx context.Context, args ...string) (interface{}, error) {
+	var reply interface{}
+	err := c.withConn(ctx, isReadOnly(args), func(cn *conn) error {
+		cn.writeCommand(args)
+		if err := cn.flush(); err != nil {
+			return err
+		}
+		var err error
+		reply, err = cn.readReply()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if replyError, ok := reply.(*Error); ok {
+		return nil, replyError
+	}
+	return reply, nil
+}
@@ -0,0 +1,86 @@
+package respClient
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// Server replying to PING and closing the connection on any other command,
+// as if it went away after running it. It sends the names of the commands
+// it received.
+func closingServer(t *testing.T) (net.Listener, chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan string, 10)
+	go func() {
+		for {
+			netConn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer netConn.Close()
+				cn := &conn{reader: bufio.NewReader(netConn)}
+				for {
+					command, err := cn.readReply()
+					if err != nil {
+						return
+					}
+					name := command.([]interface{})[0].(string)
+					received <- name
+					if name != "PING" {
+						return
+					}
+					netConn.Write([]byte("+PONG\r\n"))
+				}
+			}()
+		}
+	}()
+	return listener, received
+}
+
+// Times the server received the command name since the last call
+func countReceived(received chan string, name string) int {
+	count := 0
+	for len(received) > 0 {
+		if <-received == name {
+			count++
+		}
+	}
+	return count
+}
+
+func TestOnlyReadOnlyCommandsAreSentAgain(t *testing.T) {
+	listener, received := closingServer(t)
+	defer listener.Close()
+	client := Create(listener.Addr().String(), &Options{MinRetryBackoff: time.Millisecond})
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Do(ctx, "INCR", "n"); err == nil {
+		t.Errorf("Expected INCR to fail")
+	}
+	if count := countReceived(received, "INCR"); count != 1 {
+		t.Errorf("Expected INCR to be sent once but it was sent %v times", count)
+	}
+
+	if err := client.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// sent again on a new connection, which the server closes too
+	if _, err := client.Do(ctx, "GET", "k"); err == nil {
+		t.Errorf("Expected GET to fail")
+	}
+	if count := countReceived(received, "GET"); count != 2 {
+		t.Errorf("Expected GET to be sent twice but it was sent %v times", count)
+	}
+}
@@ -0,0 +1,237 @@
+package respClient
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+func unexpectedReply(reply interface{}) error {
+	return fmt.Errorf("respClient: unexpected reply %#v", reply)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func replyString(reply interface{}) (string, error) {
+	if value, ok := reply.(string); ok {
+		return value, nil
+	}
+	return "", unexpectedReply(reply)
+}
+
+func replyInt(reply interface{}) (int64, error) {
+	if value, ok := reply.(int64); ok {
+		return value, nil
+	}
+	return 0, unexpectedReply(reply)
+}
+
+func replyFloat(reply interface{}) (float64, error) {
+	switch value := reply.(type) {
+	case int64:
+		return float64(value), nil
+	case string:
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			return number, nil
+		}
+	}
+	return 0, unexpectedReply(reply)
+}
+
+func replyStrings(reply interface{}) ([]string, error) {
+	items, ok := reply.([]interface{})
+	if !ok {
+		return nil, unexpectedReply(reply)
+	}
+	values := make([]string, len(items))
+	for i, item := range items {
+		var err error
+		if values[i], err = replyString(item); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// Runs a command whose reply is a string or nil, found is false for nil
+func (c *Client) doString(ctx context.Context, args ...string) (value string, found bool, err error) {
+	reply, err := c.Do(ctx, args...)
+	if err != nil || reply == nil {
+		return "", false, err
+	}
+	value, err = replyString(reply)
+	return value, err == nil, err
+}
+
+func (c *Client) doInt(ctx context.Context, args ...string) (int64, error) {
+	reply, err := c.Do(ctx, args...)
+	if err != nil {
+		return 0, err
+	}
+	return replyInt(reply)
+}
+
+func (c *Client) doStrings(ctx context.Context, args ...string) ([]string, error) {
+	reply, err := c.Do(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	return replyStrings(reply)
+}
+
+func (c *Client) doFloat(ctx context.Context, args ...string) (float64, error) {
+	reply, err := c.Do(ctx, args...)
+	if err != nil {
+		return 0, err
+	}
+	return replyFloat(reply)
+}
+
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.Do(ctx, "PING")
+	return err
+}
+
+// Value of a string key, found is false if there is none
+func (c *Client) Get(ctx context.Context, key string) (value string, found bool, err error) {
+	return c.doString(ctx, "GET", key)
+}
+
+func (c *Client) Set(ctx context.Context, key string, value string) error {
+	_, err := c.Do(ctx, "SET", key, value)
+	return err
+}
+
+// Removes a string key and returns its value, found is false if there was none
+func (c *Client) GetDel(ctx context.Context, key string) (value string, found bool, err error) {
+	return c.doString(ctx, "GETDEL", key)
+}
+
+func (c *Client) Incr(ctx context.Context, key string) (int64, error) {
+	return c.doInt(ctx, "INCR", key)
+}
+
+func (c *Client) IncrBy(ctx context.Context, key string, increment int64) (int64, error) {
+	return c.doInt(ctx, "INCRBY", key, strconv.FormatInt(increment, 10))
+}
+
+func (c *Client) Decr(ctx context.Context, key string) (int64, error) {
+	return c.doInt(ctx, "DECR", key)
+}
+
+func (c *Client) DecrBy(ctx context.Context, key string, decrement int64) (int64, error) {
+	return c.doInt(ctx, "DECRBY", key, strconv.FormatInt(decrement, 10))
+}
+
+func (c *Client) IncrByFloat(ctx context.Context, key string, increment float64) (float64, error) {
+	return c.doFloat(ctx, "INCRBYFLOAT", key, formatFloat(increment))
+}
+
+// Appends to a string key and returns its new length
+func (c *Client) Append(ctx context.Context, key string, value string) (int64, error) {
+	return c.doInt(ctx, "APPEND", key, value)
+}
+
+func (c *Client) StrLen(ctx context.Context, key string) (int64, error) {
+	return c.doInt(ctx, "STRLEN", key)
+}
+
+// Values of string keys, found is false for the missing ones
+func (c *Client) MGet(ctx context.Context, keys ...string) (values []string, found []bool, err error) {
+	reply, err := c.Do(ctx, append([]string{"MGET"}, keys...)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	items, ok := reply.([]interface{})
+	if !ok {
+		return nil, nil, unexpectedReply(reply)
+	}
+	values = make([]string, len(items))
+	found = make([]bool, len(items))
+	for i, item := range items {
+		values[i], found[i] = item.(string)
+	}
+	return values, found, nil
+}
+
+func (c *Client) MSet(ctx context.Context, values map[string]string) error {
+	args := []string{"MSET"}
+	for key, value := range values {
+		args = append(args, key, value)
+	}
+	_, err := c.Do(ctx, args...)
+	return err
+}
+
+// Sets a key to expire after seconds, false if there is no such key
+func (c *Client) Expire(ctx context.Context, key string, seconds int64) (bool, error) {
+	set, err := c.doInt(ctx, "EXPIRE", key, strconv.FormatInt(seconds, 10))
+	return set == 1, err
+}
+
+// Sets a key to expire at deadline, false if there is no such key
+func (c *Client) PExpireAt(ctx context.Context, key string, deadline time.Time) (bool, error) {
+	milliseconds := deadline.UnixNano() / int64(time.Millisecond)
+	set, err := c.doInt(ctx, "PEXPIREAT", key, strconv.FormatInt(milliseconds, 10))
+	return set == 1, err
+}
+
+// Removes the deadline of a key, false if it had none or doesn't exist
+func (c *Client) Persist(ctx context.Context, key string) (bool, error) {
+	removed, err := c.doInt(ctx, "PERSIST", key)
+	return removed == 1, err
+}
+
+// Type of the value of a key like string or zset, none if there is no such key
+func (c *Client) Type(ctx context.Context, key string) (string, error) {
+	value, _, err := c.doString(ctx, "TYPE", key)
+	return value, err
+}
+
+// Number of keys
+func (c *Client) DBSize(ctx context.Context) (int64, error) {
+	return c.doInt(ctx, "DBSIZE")
+}
+
+// Goes through the keys from cursor, 0 to start, returning a page of about
+// count of them and the cursor of the next page, which is 0 after the last.
+// Keys are filtered by the glob pattern match unless it is empty.
+func (c *Client) Scan(ctx context.Context, cursor uint64, match string, count int64) (keys []string, next uint64, err error) {
+	args := []string{"SCAN", strconv.FormatUint(cursor, 10)}
+	if match != "" {
+		args = append(args, "MATCH", match)
+	}
+	if count > 0 {
+		args = append(args, "COUNT", strconv.FormatInt(count, 10))
+	}
+	reply, err := c.Do(ctx, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return scanReply(reply)
+}
+
+// Reads the cursor and items of a reply of SCAN, HSCAN or SSCAN
+func scanReply(reply interface{}) (items []string, next uint64, err error) {
+	page, ok := reply.([]interface{})
+	if !ok || len(page) != 2 {
+		return nil, 0, unexpectedReply(reply)
+	}
+	cursor, err := replyString(page[0])
+	if err != nil {
+		return nil, 0, err
+	}
+	if next, err = strconv.ParseUint(cursor, 10, 64); err != nil {
+		return nil, 0, unexpectedReply(reply)
+	}
+	items, err = replyStrings(page[1])
+	return items, next, err
+}
+
+// Publishes a message and returns how many subscribers received it
+func (c *Client) Publish(ctx context.Context, channel string, message string) (int64, error) {
+	return c.doInt(ctx, "PUBLISH", channel, message)
+}
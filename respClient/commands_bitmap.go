@@ -0,0 +1,27 @@
+package respClient
+
+import (
+	"context"
+	"strconv"
+)
+
+// Sets the bit at offset of a string to value and returns its previous value
+func (c *Client) SetBit(ctx context.Context, key string, offset int64, value int) (int64, error) {
+	return c.doInt(ctx, "SETBIT", key, strconv.FormatInt(offset, 10), strconv.Itoa(value))
+}
+
+// Bit at offset of a string, 0 past its end
+func (c *Client) GetBit(ctx context.Context, key string, offset int64) (int64, error) {
+	return c.doInt(ctx, "GETBIT", key, strconv.FormatInt(offset, 10))
+}
+
+// Number of bits set in a string
+func (c *Client) BitCount(ctx context.Context, key string) (int64, error) {
+	return c.doInt(ctx, "BITCOUNT", key)
+}
+
+// Stores the result of operation, AND, OR, XOR or NOT, on the strings of keys
+// in dest and returns its length
+func (c *Client) BitOp(ctx context.Context, operation string, dest string, keys ...string) (int64, error) {
+	return c.doInt(ctx, append([]string{"BITOP", operation, dest}, keys...)...)
+}
@@ -0,0 +1,65 @@
+package respClient
+
+import (
+	"context"
+)
+
+// A member of a geo index with its position
+type GeoLocation struct {
+	Member    string
+	Longitude float64
+	Latitude  float64
+}
+
+// Adds members at their positions, or moves existing ones, and returns how
+// many were added
+func (c *Client) GeoAdd(ctx context.Context, key string, locations ...GeoLocation) (int64, error) {
+	args := []string{"GEOADD", key}
+	for _, location := range locations {
+		args = append(args, formatFloat(location.Longitude), formatFloat(location.Latitude), location.Member)
+	}
+	return c.doInt(ctx, args...)
+}
+
+// Position of a member, found is false if it isn't there
+func (c *Client) GeoPos(ctx context.Context, key string, member string) (longitude float64, latitude float64, found bool, err error) {
+	reply, err := c.Do(ctx, "GEOPOS", key, member)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	items, ok := reply.([]interface{})
+	if !ok || len(items) != 1 {
+		return 0, 0, false, unexpectedReply(reply)
+	}
+	if items[0] == nil {
+		return 0, 0, false, nil
+	}
+	position, ok := items[0].([]interface{})
+	if !ok || len(position) != 2 {
+		return 0, 0, false, unexpectedReply(reply)
+	}
+	if longitude, err = replyFloat(position[0]); err != nil {
+		return 0, 0, false, err
+	}
+	if latitude, err = replyFloat(position[1]); err != nil {
+		return 0, 0, false, err
+	}
+	return longitude, latitude, true, nil
+}
+
+// Distance between two members in unit, m, km, ft or mi. found is false if
+// either isn't there.
+func (c *Client) GeoDist(ctx context.Context, key string, first string, second string, unit string) (distance float64, found bool, err error) {
+	reply, err := c.Do(ctx, "GEODIST", key, first, second, unit)
+	if err != nil || reply == nil {
+		return 0, false, err
+	}
+	distance, err = replyFloat(reply)
+	return distance, err == nil, err
+}
+
+// Members within radius in unit of a position, nearest first
+func (c *Client) GeoSearchRadius(ctx context.Context, key string, longitude float64, latitude float64, radius float64, unit string) ([]string, error) {
+	return c.doStrings(ctx, "GEOSEARCH", key, "FROMLONLAT", formatFloat(longitude), formatFloat(latitude),
+		"BYRADIUS", formatFloat(radius), unit, "ASC")
+}
@@ -0,0 +1,85 @@
+package respClient
+
+import (
+	"context"
+	"strconv"
+)
+
+// Sets fields of a hash and returns how many were added
+func (c *Client) HSet(ctx context.Context, key string, fields map[string]string) (int64, error) {
+	args := []string{"HSET", key}
+	for field, value := range fields {
+		args = append(args, field, value)
+	}
+	return c.doInt(ctx, args...)
+}
+
+// Value of a field of a hash, found is false if there is none
+func (c *Client) HGet(ctx context.Context, key string, field string) (value string, found bool, err error) {
+	return c.doString(ctx, "HGET", key, field)
+}
+
+func (c *Client) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	items, err := c.doStrings(ctx, "HGETALL", key)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]string, len(items)/2)
+	for i := 0; i+1 < len(items); i += 2 {
+		fields[items[i]] = items[i+1]
+	}
+	return fields, nil
+}
+
+// Removes fields of a hash and returns how many existed
+func (c *Client) HDel(ctx context.Context, key string, fields ...string) (int64, error) {
+	return c.doInt(ctx, append([]string{"HDEL", key}, fields...)...)
+}
+
+func (c *Client) HExists(ctx context.Context, key string, field string) (bool, error) {
+	exists, err := c.doInt(ctx, "HEXISTS", key, field)
+	return exists == 1, err
+}
+
+// Number of fields of a hash
+func (c *Client) HLen(ctx context.Context, key string) (int64, error) {
+	return c.doInt(ctx, "HLEN", key)
+}
+
+func (c *Client) HKeys(ctx context.Context, key string) ([]string, error) {
+	return c.doStrings(ctx, "HKEYS", key)
+}
+
+func (c *Client) HVals(ctx context.Context, key string) ([]string, error) {
+	return c.doStrings(ctx, "HVALS", key)
+}
+
+func (c *Client) HIncrBy(ctx context.Context, key string, field string, increment int64) (int64, error) {
+	return c.doInt(ctx, "HINCRBY", key, field, strconv.FormatInt(increment, 10))
+}
+
+func (c *Client) HIncrByFloat(ctx context.Context, key string, field string, increment float64) (float64, error) {
+	return c.doFloat(ctx, "HINCRBYFLOAT", key, field, formatFloat(increment))
+}
+
+// Sets a field of a hash unless it exists, false if it did
+func (c *Client) HSetNX(ctx context.Context, key string, field string, value string) (bool, error) {
+	set, err := c.doInt(ctx, "HSETNX", key, field, value)
+	return set == 1, err
+}
+
+// Goes through the fields of a hash like Scan, items being field value pairs
+func (c *Client) HScan(ctx context.Context, key string, cursor uint64, match string, count int64) (items []string, next uint64, err error) {
+	args := []string{"HSCAN", key, strconv.FormatUint(cursor, 10)}
+	if match != "" {
+		args = append(args, "MATCH", match)
+	}
+	if count > 0 {
+		args = append(args, "COUNT", strconv.FormatInt(count, 10))
+	}
+	reply, err := c.Do(ctx, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return scanReply(reply)
+}
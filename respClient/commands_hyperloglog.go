@@ -0,0 +1,22 @@
+package respClient
+
+import (
+	"context"
+)
+
+// Adds elements to a HyperLogLog, true if its estimate changed
+func (c *Client) PFAdd(ctx context.Context, key string, elements ...string) (bool, error) {
+	changed, err := c.doInt(ctx, append([]string{"PFADD", key}, elements...)...)
+	return changed == 1, err
+}
+
+// Estimated number of distinct elements added to any of the HyperLogLogs
+func (c *Client) PFCount(ctx context.Context, keys ...string) (int64, error) {
+	return c.doInt(ctx, append([]string{"PFCOUNT"}, keys...)...)
+}
+
+// Merges HyperLogLogs into dest
+func (c *Client) PFMerge(ctx context.Context, dest string, sources ...string) error {
+	_, err := c.Do(ctx, append([]string{"PFMERGE", dest}, sources...)...)
+	return err
+}
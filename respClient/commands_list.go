@@ -0,0 +1,82 @@
+package respClient
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// Pushes values at the head of a list and returns its length
+func (c *Client) LPush(ctx context.Context, key string, values ...string) (int64, error) {
+	return c.doInt(ctx, append([]string{"LPUSH", key}, values...)...)
+}
+
+// Pushes values at the tail of a list and returns its length
+func (c *Client) RPush(ctx context.Context, key string, values ...string) (int64, error) {
+	return c.doInt(ctx, append([]string{"RPUSH", key}, values...)...)
+}
+
+func (c *Client) LRange(ctx context.Context, key string, start int64, stop int64) ([]string, error) {
+	return c.doStrings(ctx, "LRANGE", key, strconv.FormatInt(start, 10), strconv.FormatInt(stop, 10))
+}
+
+// Removes and returns the first element of a list, found is false if it's empty
+func (c *Client) LPop(ctx context.Context, key string) (value string, found bool, err error) {
+	return c.doString(ctx, "LPOP", key)
+}
+
+// Removes and returns the last element of a list, found is false if it's empty
+func (c *Client) RPop(ctx context.Context, key string) (value string, found bool, err error) {
+	return c.doString(ctx, "RPOP", key)
+}
+
+func (c *Client) LLen(ctx context.Context, key string) (int64, error) {
+	return c.doInt(ctx, "LLEN", key)
+}
+
+// Element at index, negative ones counting from the end. found is false out of range.
+func (c *Client) LIndex(ctx context.Context, key string, index int64) (value string, found bool, err error) {
+	return c.doString(ctx, "LINDEX", key, strconv.FormatInt(index, 10))
+}
+
+func (c *Client) LSet(ctx context.Context, key string, index int64, value string) error {
+	_, err := c.Do(ctx, "LSET", key, strconv.FormatInt(index, 10), value)
+	return err
+}
+
+// Removes count occurrences of value, from the tail if count is negative and
+// all of them if it's 0. Returns how many were removed.
+func (c *Client) LRem(ctx context.Context, key string, count int64, value string) (int64, error) {
+	return c.doInt(ctx, "LREM", key, strconv.FormatInt(count, 10), value)
+}
+
+// Keeps only the elements from start to stop
+func (c *Client) LTrim(ctx context.Context, key string, start int64, stop int64) error {
+	_, err := c.Do(ctx, "LTRIM", key, strconv.FormatInt(start, 10), strconv.FormatInt(stop, 10))
+	return err
+}
+
+// Pops the first element of the first non empty list, waiting up to timeout
+// for one, or forever if it is 0. found is false if the timeout passed.
+func (c *Client) BLPop(ctx context.Context, timeout time.Duration, keys ...string) (key string, value string, found bool, err error) {
+	return c.blockingPop(ctx, "BLPOP", timeout, keys)
+}
+
+// Same as BLPop for the last element
+func (c *Client) BRPop(ctx context.Context, timeout time.Duration, keys ...string) (key string, value string, found bool, err error) {
+	return c.blockingPop(ctx, "BRPOP", timeout, keys)
+}
+
+func (c *Client) blockingPop(ctx context.Context, command string, timeout time.Duration, keys []string) (key string, value string, found bool, err error) {
+	args := append([]string{command}, keys...)
+	args = append(args, strconv.FormatFloat(timeout.Seconds(), 'f', -1, 64))
+	reply, err := c.Do(ctx, args...)
+	if err != nil || reply == nil {
+		return "", "", false, err
+	}
+	items, err := replyStrings(reply)
+	if err != nil || len(items) != 2 {
+		return "", "", false, unexpectedReply(reply)
+	}
+	return items[0], items[1], true, nil
+}
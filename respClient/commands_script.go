@@ -0,0 +1,44 @@
+package respClient
+
+import (
+	"context"
+	"strconv"
+)
+
+// Arguments of EVAL, EVALSHA and FCALL after the script or function
+func scriptArgs(command string, name string, keys []string, args []string) []string {
+	arguments := append([]string{command, name, strconv.Itoa(len(keys))}, keys...)
+	return append(arguments, args...)
+}
+
+// Runs a Lua script with KEYS and ARGV set and returns its reply like Do
+func (c *Client) Eval(ctx context.Context, script string, keys []string, args ...string) (interface{}, error) {
+	return c.Do(ctx, scriptArgs("EVAL", script, keys, args)...)
+}
+
+// Runs a script cached by Eval or ScriptLoad by its SHA1
+func (c *Client) EvalSha(ctx context.Context, sha string, keys []string, args ...string) (interface{}, error) {
+	return c.Do(ctx, scriptArgs("EVALSHA", sha, keys, args)...)
+}
+
+// Caches a script without running it and returns its SHA1
+func (c *Client) ScriptLoad(ctx context.Context, script string) (string, error) {
+	sha, _, err := c.doString(ctx, "SCRIPT", "LOAD", script)
+	return sha, err
+}
+
+// Loads a library of functions, replacing the one with the same name if
+// replace is true, and returns its name
+func (c *Client) FunctionLoad(ctx context.Context, code string, replace bool) (string, error) {
+	args := []string{"FUNCTION", "LOAD", code}
+	if replace {
+		args = []string{"FUNCTION", "LOAD", "REPLACE", code}
+	}
+	name, _, err := c.doString(ctx, args...)
+	return name, err
+}
+
+// Calls a function loaded with FunctionLoad and returns its reply like Do
+func (c *Client) FCall(ctx context.Context, function string, keys []string, args ...string) (interface{}, error) {
+	return c.Do(ctx, scriptArgs("FCALL", function, keys, args)...)
+}
@@ -0,0 +1,44 @@
+package respClient
+
+import (
+	"context"
+)
+
+// Adds members to a set and returns how many weren't there already
+func (c *Client) SAdd(ctx context.Context, key string, members ...string) (int64, error) {
+	return c.doInt(ctx, append([]string{"SADD", key}, members...)...)
+}
+
+func (c *Client) SMembers(ctx context.Context, key string) ([]string, error) {
+	return c.doStrings(ctx, "SMEMBERS", key)
+}
+
+// Removes members from a set and returns how many were there
+func (c *Client) SRem(ctx context.Context, key string, members ...string) (int64, error) {
+	return c.doInt(ctx, append([]string{"SREM", key}, members...)...)
+}
+
+func (c *Client) SIsMember(ctx context.Context, key string, member string) (bool, error) {
+	isMember, err := c.doInt(ctx, "SISMEMBER", key, member)
+	return isMember == 1, err
+}
+
+// Number of members of a set
+func (c *Client) SCard(ctx context.Context, key string) (int64, error) {
+	return c.doInt(ctx, "SCARD", key)
+}
+
+// Members of every set
+func (c *Client) SInter(ctx context.Context, keys ...string) ([]string, error) {
+	return c.doStrings(ctx, append([]string{"SINTER"}, keys...)...)
+}
+
+// Members of any of the sets
+func (c *Client) SUnion(ctx context.Context, keys ...string) ([]string, error) {
+	return c.doStrings(ctx, append([]string{"SUNION"}, keys...)...)
+}
+
+// Members of the first set which aren't in the others
+func (c *Client) SDiff(ctx context.Context, keys ...string) ([]string, error) {
+	return c.doStrings(ctx, append([]string{"SDIFF"}, keys...)...)
+}
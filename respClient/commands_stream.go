@@ -0,0 +1,156 @@
+package respClient
+
+import (
+	"context"
+	"strconv"
+)
+
+// An entry of a stream. Fields holds its field value pairs in order, and is
+// nil for an entry read again by a consumer after it was deleted.
+type StreamEntry struct {
+	ID     string
+	Fields []string
+}
+
+func replyStreamEntries(reply interface{}) ([]StreamEntry, error) {
+	items, ok := reply.([]interface{})
+	if !ok {
+		return nil, unexpectedReply(reply)
+	}
+	entries := make([]StreamEntry, len(items))
+	for i, item := range items {
+		pair, ok := item.([]interface{})
+		if !ok || len(pair) != 2 {
+			return nil, unexpectedReply(item)
+		}
+		id, err := replyString(pair[0])
+		if err != nil {
+			return nil, err
+		}
+		entries[i].ID = id
+		if pair[1] != nil {
+			if entries[i].Fields, err = replyStrings(pair[1]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return entries, nil
+}
+
+// Reads the entries of each stream of a reply of XREAD or XREADGROUP by key.
+// nil, when no stream had entries, gives an empty map.
+func replyStreamReads(reply interface{}) (map[string][]StreamEntry, error) {
+	streams := make(map[string][]StreamEntry)
+	if reply == nil {
+		return streams, nil
+	}
+	items, ok := reply.([]interface{})
+	if !ok {
+		return nil, unexpectedReply(reply)
+	}
+	for _, item := range items {
+		pair, ok := item.([]interface{})
+		if !ok || len(pair) != 2 {
+			return nil, unexpectedReply(item)
+		}
+		key, err := replyString(pair[0])
+		if err != nil {
+			return nil, err
+		}
+		if streams[key], err = replyStreamEntries(pair[1]); err != nil {
+			return nil, err
+		}
+	}
+	return streams, nil
+}
+
+// Appends an entry with fields given as field value pairs and returns its
+// ID. id is "*" for an ID made from the time.
+func (c *Client) XAdd(ctx context.Context, key string, id string, fields ...string) (string, error) {
+	value, _, err := c.doString(ctx, append([]string{"XADD", key, id}, fields...)...)
+	return value, err
+}
+
+// Number of entries of a stream
+func (c *Client) XLen(ctx context.Context, key string) (int64, error) {
+	return c.doInt(ctx, "XLEN", key)
+}
+
+// Entries with IDs from start to end, "-" and "+" meaning the first and the
+// last. All of them if count is 0.
+func (c *Client) XRange(ctx context.Context, key string, start string, end string, count int64) ([]StreamEntry, error) {
+	return c.streamRange(ctx, "XRANGE", key, start, end, count)
+}
+
+// Same as XRange from the last entry, end coming first like in XREVRANGE
+func (c *Client) XRevRange(ctx context.Context, key string, end string, start string, count int64) ([]StreamEntry, error) {
+	return c.streamRange(ctx, "XREVRANGE", key, end, start, count)
+}
+
+func (c *Client) streamRange(ctx context.Context, command string, key string, from string, to string, count int64) ([]StreamEntry, error) {
+	args := []string{command, key, from, to}
+	if count > 0 {
+		args = append(args, "COUNT", strconv.FormatInt(count, 10))
+	}
+	reply, err := c.Do(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	return replyStreamEntries(reply)
+}
+
+// Removes entries and returns how many existed
+func (c *Client) XDel(ctx context.Context, key string, ids ...string) (int64, error) {
+	return c.doInt(ctx, append([]string{"XDEL", key}, ids...)...)
+}
+
+// Removes the oldest entries beyond maxLen and returns how many
+func (c *Client) XTrimMaxLen(ctx context.Context, key string, maxLen int64) (int64, error) {
+	return c.doInt(ctx, "XTRIM", key, "MAXLEN", strconv.FormatInt(maxLen, 10))
+}
+
+// Entries after ids[i] in the stream keys[i], "$" meaning the last entry,
+// by key. Up to count of each stream, all of them if count is 0.
+func (c *Client) XRead(ctx context.Context, count int64, keys []string, ids []string) (map[string][]StreamEntry, error) {
+	args := []string{"XREAD"}
+	if count > 0 {
+		args = append(args, "COUNT", strconv.FormatInt(count, 10))
+	}
+	args = append(append(append(args, "STREAMS"), keys...), ids...)
+	reply, err := c.Do(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	return replyStreamReads(reply)
+}
+
+// Creates a consumer group whose last delivered entry is id, "$" for the
+// last entry. mkStream creates the stream if it doesn't exist.
+func (c *Client) XGroupCreate(ctx context.Context, key string, group string, id string, mkStream bool) error {
+	args := []string{"XGROUP", "CREATE", key, group, id}
+	if mkStream {
+		args = append(args, "MKSTREAM")
+	}
+	_, err := c.Do(ctx, args...)
+	return err
+}
+
+// Reads as consumer of group like XRead, ">" as ID reading entries never
+// delivered to the group and another ID the consumer's pending entries after it
+func (c *Client) XReadGroup(ctx context.Context, group string, consumer string, count int64, keys []string, ids []string) (map[string][]StreamEntry, error) {
+	args := []string{"XREADGROUP", "GROUP", group, consumer}
+	if count > 0 {
+		args = append(args, "COUNT", strconv.FormatInt(count, 10))
+	}
+	args = append(append(append(args, "STREAMS"), keys...), ids...)
+	reply, err := c.Do(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	return replyStreamReads(reply)
+}
+
+// Acknowledges pending entries of a group and returns how many were pending
+func (c *Client) XAck(ctx context.Context, key string, group string, ids ...string) (int64, error) {
+	return c.doInt(ctx, append([]string{"XACK", key, group}, ids...)...)
+}
@@ -0,0 +1,67 @@
+package respClient
+
+import (
+	"context"
+	"strconv"
+)
+
+// A member of a sorted set with its score
+type ScoredMember struct {
+	Member string
+	Score  float64
+}
+
+// Adds members to a sorted set and returns how many weren't there already
+func (c *Client) ZAdd(ctx context.Context, key string, members ...ScoredMember) (int64, error) {
+	args := []string{"ZADD", key}
+	for _, member := range members {
+		args = append(args, formatFloat(member.Score), member.Member)
+	}
+	return c.doInt(ctx, args...)
+}
+
+// Members of a sorted set ranked from start to stop, negative ranks
+// counting from the end
+func (c *Client) ZRange(ctx context.Context, key string, start int64, stop int64) ([]string, error) {
+	return c.doStrings(ctx, "ZRANGE", key, strconv.FormatInt(start, 10), strconv.FormatInt(stop, 10))
+}
+
+// Same as ZRange with the scores of the members
+func (c *Client) ZRangeWithScores(ctx context.Context, key string, start int64, stop int64) ([]ScoredMember, error) {
+	reply, err := c.Do(ctx, "ZRANGE", key, strconv.FormatInt(start, 10), strconv.FormatInt(stop, 10), "WITHSCORES")
+	if err != nil {
+		return nil, err
+	}
+	items, ok := reply.([]interface{})
+	if !ok {
+		return nil, unexpectedReply(reply)
+	}
+	members := make([]ScoredMember, 0, len(items)/2)
+	for i := 0; i+1 < len(items); i += 2 {
+		member, err := replyString(items[i])
+		if err != nil {
+			return nil, err
+		}
+		score, err := replyFloat(items[i+1])
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, ScoredMember{Member: member, Score: score})
+	}
+	return members, nil
+}
+
+// Rank of a member in a sorted set from 0, found is false if it isn't there
+func (c *Client) ZRank(ctx context.Context, key string, member string) (rank int64, found bool, err error) {
+	reply, err := c.Do(ctx, "ZRANK", key, member)
+	if err != nil || reply == nil {
+		return 0, false, err
+	}
+	rank, err = replyInt(reply)
+	return rank, err == nil, err
+}
+
+// Number of members of a sorted set
+func (c *Client) ZCard(ctx context.Context, key string) (int64, error) {
+	return c.doInt(ctx, "ZCARD", key)
+}
@@ -0,0 +1,171 @@
+package respClient
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Error reply of the server, like ERR value is not an integer or out of range
+type Error struct {
+	Message string
+}
+
+func (err *Error) Error() string {
+	return err.Message
+}
+
+var ErrProtocol = errors.New("respClient: invalid reply from the server")
+
+// A connection to the server. A single goroutine uses it at a time.
+type conn struct {
+	netConn net.Conn
+	reader  *bufio.Reader
+	writer  *bufio.Writer
+	// bytes written to netConn, telling whether a failed command reached it
+	sent int64
+}
+
+// Writer of a connection counting the bytes it sends
+type countingWriter struct {
+	writer io.Writer
+	count  *int64
+}
+
+func (w countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	*w.count += int64(n)
+	return n, err
+}
+
+func dial(ctx context.Context, address string, timeout time.Duration) (*conn, error) {
+	dialer := net.Dialer{Timeout: timeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{netConn: netConn, reader: bufio.NewReader(netConn)}
+	cn.writer = bufio.NewWriter(countingWriter{writer: netConn, count: &cn.sent})
+	return cn, nil
+}
+
+// Buffers a command as an array of bulk strings, sent by flush
+func (cn *conn) writeCommand(args []string) {
+	cn.writer.WriteByte('*')
+	cn.writer.WriteString(strconv.Itoa(len(args)))
+	cn.writer.WriteString("\r\n")
+	for _, arg := range args {
+		cn.writer.WriteByte('$')
+		cn.writer.WriteString(strconv.Itoa(len(arg)))
+		cn.writer.WriteString("\r\n")
+		cn.writer.WriteString(arg)
+		cn.writer.WriteString("\r\n")
+	}
+}
+
+func (cn *conn) flush() error {
+	return cn.writer.Flush()
+}
+
+func (cn *conn) readLine() (string, error) {
+	line, err := cn.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return "", ErrProtocol
+	}
+	return line[:len(line)-2], nil
+}
+
+// Reads a reply: nil, a string for status and bulk replies, an int64, an
+// *Error or a []interface{} of replies. err is only set if the reply
+// couldn't be read.
+func (cn *conn) readReply() (interface{}, error) {
+	line, err := cn.readLine()
+	if err != nil {
+		return nil, err
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return &Error{Message: line[1:]}, nil
+	case ':':
+		value, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, ErrProtocol
+		}
+		return value, nil
+	case '$':
+		length, err := strconv.Atoi(line[1:])
+		if err != nil || length < -1 {
+			return nil, ErrProtocol
+		}
+		if length == -1 {
+			return nil, nil
+		}
+		bulk := make([]byte, length+2)
+		if _, err := io.ReadFull(cn.reader, bulk); err != nil {
+			return nil, err
+		}
+		return string(bulk[:length]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil || count < -1 {
+			return nil, ErrProtocol
+		}
+		if count == -1 {
+			return nil, nil
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			if items[i], err = cn.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, ErrProtocol
+}
+
+// A time in the past, setting it as deadline interrupts reads and writes
+var aLongTimeAgo = time.Unix(1, 0)
+
+// Runs f with the deadline of ctx set on the connection, interrupting it if
+// ctx is cancelled. The connection can't be used again if it failed.
+func (cn *conn) withContext(ctx context.Context, f func() error) error {
+	deadline, _ := ctx.Deadline()
+	cn.netConn.SetDeadline(deadline)
+	if ctx.Done() == nil {
+		return f()
+	}
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			cn.netConn.SetDeadline(aLongTimeAgo)
+		case <-stop:
+		}
+	}()
+	err := f()
+	close(stop)
+	<-stopped
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	// the deadline of the connection can pass just before the one of ctx
+	if err != nil && !deadline.IsZero() && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+func (cn *conn) close() error {
+	return cn.netConn.Close()
+}
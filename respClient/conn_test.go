@@ -0,0 +1,68 @@
+package respClient
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteCommand(t *testing.T) {
+	var buffer bytes.Buffer
+	cn := &conn{writer: bufio.NewWriter(&buffer)}
+	cn.writeCommand([]string{"SET", "k", "a b\r\n"})
+	cn.flush()
+	if expected := "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$5\r\na b\r\n\r\n"; buffer.String() != expected {
+		t.Errorf("Expected %q but got %q", expected, buffer.String())
+	}
+}
+
+func TestReadReply(t *testing.T) {
+	input := "+OK\r\n" +
+		"-ERR wrong\r\n" +
+		":-42\r\n" +
+		"$5\r\na\r\nb!\r\n" +
+		"$-1\r\n" +
+		"*3\r\n$1\r\na\r\n*1\r\n:1\r\n$-1\r\n" +
+		"*0\r\n" +
+		"*-1\r\n"
+	expected := []interface{}{
+		"OK",
+		&Error{Message: "ERR wrong"},
+		int64(-42),
+		"a\r\nb!",
+		nil,
+		[]interface{}{"a", []interface{}{int64(1)}, nil},
+		[]interface{}{},
+		nil,
+	}
+	cn := &conn{reader: bufio.NewReader(strings.NewReader(input))}
+	for _, reply := range expected {
+		if result, err := cn.readReply(); err != nil || !reflect.DeepEqual(result, reply) {
+			t.Errorf("Expected %#v but got %#v %v", reply, result, err)
+		}
+	}
+	for _, input := range []string{"?\r\n", ":x\r\n", "$-2\r\n", "+OK\n"} {
+		cn := &conn{reader: bufio.NewReader(strings.NewReader(input))}
+		if _, err := cn.readReply(); err != ErrProtocol {
+			t.Errorf("Expected ErrProtocol for %q but got %v", input, err)
+		}
+	}
+}
+
+func TestPubSubMessage(t *testing.T) {
+	cases := map[string]struct {
+		reply    []interface{}
+		expected Message
+	}{
+		"message":   {[]interface{}{"message", "news", "hi"}, Message{Kind: "message", Channel: "news", Payload: "hi"}},
+		"pmessage":  {[]interface{}{"pmessage", "n*", "news", "1"}, Message{Kind: "pmessage", Pattern: "n*", Channel: "news", Payload: "1"}},
+		"subscribe": {[]interface{}{"subscribe", "news", int64(2)}, Message{Kind: "subscribe", Channel: "news", Count: 2}},
+	}
+	for name, c := range cases {
+		if message, ok := pubSubMessage(c.reply); !ok || message != c.expected {
+			t.Errorf("%v: expected %+v but got %+v", name, c.expected, message)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package respClient
+
+import "context"
+
+// Commands sent together on one connection, their replies being read once
+// all are sent. Pipelines aren't transactions: commands of other clients can
+// run in between.
+type Pipeline struct {
+	client   *Client
+	commands [][]string
+}
+
+func (c *Client) Pipeline() *Pipeline {
+	return &Pipeline{client: c}
+}
+
+// Queues a command, sent by Exec
+func (p *Pipeline) Do(args ...string) {
+	p.commands = append(p.commands, args)
+}
+
+// Number of commands queued
+func (p *Pipeline) Len() int {
+	return len(p.commands)
+}
+
+// Sends the queued commands and returns their replies in order, error
+// replies being *Error values. err is only set if the replies couldn't be
+// read, in which case some of the commands may have run. The queue is
+// emptied either way.
+func (p *Pipeline) Exec(ctx context.Context) ([]interface{}, error) {
+	commands := p.commands
+	p.commands = nil
+	if len(commands) == 0 {
+		return nil, nil
+	}
+	readOnly := true
+	for _, args := range commands {
+		readOnly = readOnly && isReadOnly(args)
+	}
+	replies := make([]interface{}, len(commands))
+	err := p.client.withConn(ctx, readOnly, func(cn *conn) error {
+		// replies are read while sending, the server stops reading commands
+		// of long pipelines when its replies aren't read
+		written := make(chan error, 1)
+		go func() {
+			for _, args := range commands {
+				cn.writeCommand(args)
+			}
+			written <- cn.flush()
+		}()
+		for i := range replies {
+			var err error
+			if replies[i], err = cn.readReply(); err != nil {
+				cn.close()
+				<-written
+				return err
+			}
+		}
+		return <-written
+	})
+	if err != nil {
+		return nil, err
+	}
+	return replies, nil
+}
@@ -0,0 +1,248 @@
+package respClient
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A message received by a PubSub. Kind is message, pmessage, smessage or a
+// confirmation like subscribe or unsubscribe, in which case Count is the
+// number of subscriptions left.
+type Message struct {
+	Kind    string
+	Pattern string
+	Channel string
+	Payload string
+	Count   int64
+}
+
+// Subscriptions on a connection of their own, outside of the pool. If the
+// connection is lost a new one is opened and the channels and patterns are
+// subscribed again, whose confirmations are received again too. Messages
+// published in between are lost.
+type PubSub struct {
+	client   *Client
+	messages chan Message
+	done     chan struct{}
+
+	mutex    sync.Mutex
+	cn       *conn
+	channels map[string]bool
+	patterns map[string]bool
+	closed   bool
+}
+
+// Subscribes to channels on a new connection
+func (c *Client) Subscribe(ctx context.Context, channels ...string) (*PubSub, error) {
+	p, err := c.newPubSub(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return p, p.Subscribe(ctx, channels...)
+}
+
+// Subscribes to glob-style patterns, like news.*, on a new connection
+func (c *Client) PSubscribe(ctx context.Context, patterns ...string) (*PubSub, error) {
+	p, err := c.newPubSub(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return p, p.PSubscribe(ctx, patterns...)
+}
+
+func (c *Client) newPubSub(ctx context.Context) (*PubSub, error) {
+	if c.isClosed() {
+		return nil, ErrClosed
+	}
+	cn, err := dial(ctx, c.address, c.options.DialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	p := &PubSub{
+		client:   c,
+		messages: make(chan Message, 100),
+		done:     make(chan struct{}),
+		cn:       cn,
+		channels: make(map[string]bool),
+		patterns: make(map[string]bool),
+	}
+	go p.receive()
+	return p, nil
+}
+
+// Messages and confirmations in the order they were received. It is closed
+// by Close.
+func (p *PubSub) Channel() <-chan Message {
+	return p.messages
+}
+
+// Sends a command on the connection. If that fails the subscriptions are
+// sent again once reconnected.
+func (p *PubSub) send(ctx context.Context, args []string) error {
+	if p.closed {
+		return ErrClosed
+	}
+	deadline, _ := ctx.Deadline()
+	p.cn.netConn.SetWriteDeadline(deadline)
+	defer p.cn.netConn.SetWriteDeadline(time.Time{})
+	p.cn.writeCommand(args)
+	return p.cn.flush()
+}
+
+func (p *PubSub) Subscribe(ctx context.Context, channels ...string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, channel := range channels {
+		p.channels[channel] = true
+	}
+	return p.send(ctx, append([]string{"SUBSCRIBE"}, channels...))
+}
+
+func (p *PubSub) PSubscribe(ctx context.Context, patterns ...string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, pattern := range patterns {
+		p.patterns[pattern] = true
+	}
+	return p.send(ctx, append([]string{"PSUBSCRIBE"}, patterns...))
+}
+
+// Unsubscribes from channels, or from all of them if none is given
+func (p *PubSub) Unsubscribe(ctx context.Context, channels ...string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	removeAll(p.channels, channels)
+	return p.send(ctx, append([]string{"UNSUBSCRIBE"}, channels...))
+}
+
+// Unsubscribes from patterns, or from all of them if none is given
+func (p *PubSub) PUnsubscribe(ctx context.Context, patterns ...string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	removeAll(p.patterns, patterns)
+	return p.send(ctx, append([]string{"PUNSUBSCRIBE"}, patterns...))
+}
+
+func removeAll(set map[string]bool, names []string) {
+	if len(names) == 0 {
+		for name := range set {
+			delete(set, name)
+		}
+	}
+	for _, name := range names {
+		delete(set, name)
+	}
+}
+
+// Closes the connection and the channel of messages
+func (p *PubSub) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	close(p.done)
+	return p.cn.close()
+}
+
+// Reads messages until closed, reconnecting when the connection is lost
+func (p *PubSub) receive() {
+	defer close(p.messages)
+	for {
+		p.mutex.Lock()
+		cn := p.cn
+		p.mutex.Unlock()
+		reply, err := cn.readReply()
+		if err != nil {
+			cn.close()
+			if !p.reconnect() {
+				return
+			}
+			continue
+		}
+		if message, ok := pubSubMessage(reply); ok {
+			select {
+			case p.messages <- message:
+			case <-p.done:
+				return
+			}
+		}
+	}
+}
+
+// Opens a new connection and subscribes again, waiting more after each
+// failure. false once closed.
+func (p *PubSub) reconnect() bool {
+	options := p.client.options
+	backoff := options.MinRetryBackoff
+	for {
+		p.mutex.Lock()
+		closed := p.closed
+		p.mutex.Unlock()
+		if closed {
+			return false
+		}
+		cn, err := dial(context.Background(), p.client.address, options.DialTimeout)
+		if err == nil {
+			p.mutex.Lock()
+			p.cn = cn
+			err = p.resubscribe()
+			p.mutex.Unlock()
+			if err == nil {
+				return true
+			}
+			cn.close()
+		}
+		select {
+		case <-time.After(backoff):
+		case <-p.done:
+			return false
+		}
+		if backoff *= 2; backoff > options.MaxRetryBackoff {
+			backoff = options.MaxRetryBackoff
+		}
+	}
+}
+
+func (p *PubSub) resubscribe() error {
+	for kind, names := range map[string]map[string]bool{"SUBSCRIBE": p.channels, "PSUBSCRIBE": p.patterns} {
+		if len(names) == 0 {
+			continue
+		}
+		args := []string{kind}
+		for name := range names {
+			args = append(args, name)
+		}
+		if err := p.send(context.Background(), args); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reads a message pushed to subscribers, like [message channel payload]
+func pubSubMessage(reply interface{}) (message Message, ok bool) {
+	items, ok := reply.([]interface{})
+	if !ok || len(items) < 3 {
+		return message, false
+	}
+	message.Kind, _ = items[0].(string)
+	switch message.Kind {
+	case "message", "smessage":
+		message.Channel, _ = items[1].(string)
+		message.Payload, _ = items[2].(string)
+	case "pmessage":
+		if len(items) < 4 {
+			return message, false
+		}
+		message.Pattern, _ = items[1].(string)
+		message.Channel, _ = items[2].(string)
+		message.Payload, _ = items[3].(string)
+	default:
+		message.Channel, _ = items[1].(string)
+		message.Count, _ = items[2].(int64)
+	}
+	return message, true
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Commands which can wait for data. Replies of the commands pipelined before
+// them are sent first so the client isn't kept waiting for those too.
+var blockingCommandNames = map[string]bool{
+	"BLPOP": true, "BRPOP": true, "BLMOVE": true, "XREAD": true, "XREADGROUP": true,
+}
+
+// A client connected over RESP. Replies are written by the goroutine reading
+// commands while messages for subscriptions are written by another one, so
+// writes are serialised by writeMutex.
+type respConnection struct {
+	conn        net.Conn
+	reader      *bufio.Reader
+	writer      *bufio.Writer
+	writeMutex  sync.Mutex
+	store       *InMemoryStore
+	subscriber  *Subscriber
+	transaction *Transaction
+	closed      chan struct{}
+}
+
+// Accepts RESP connections, like redis-cli or any redis client library makes,
+// until the listener is closed
+func ServeRESP(listener net.Listener, store *InMemoryStore) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		atomic.AddUint64(&store.stats.connectionsReceived, 1)
+		atomic.AddInt64(&store.stats.connectedClients, 1)
+		c := &respConnection{
+			conn:        conn,
+			reader:      bufio.NewReader(conn),
+			writer:      bufio.NewWriter(conn),
+			store:       store,
+			transaction: CreateTransaction(),
+			closed:      make(chan struct{}),
+		}
+		go c.serve()
+	}
+}
+
+func (c *respConnection) write(buffer []byte) error {
+	return c.writeReply(buffer, true)
+}
+
+// Buffers a reply, sending the buffered replies if flush is set. Pipelined
+// commands are answered in one write once all those read are run.
+func (c *respConnection) writeReply(buffer []byte, flush bool) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	if _, err := c.writer.Write(buffer); err != nil {
+		return err
+	}
+	if !flush {
+		return nil
+	}
+	return c.writer.Flush()
+}
+
+func (c *respConnection) serve() {
+	defer func() {
+		close(c.closed)
+		if c.subscriber != nil {
+			c.store.pubsub.UnsubscribeAll(c.subscriber)
+		}
+		c.store.DISCARD(c.transaction)
+		c.conn.Close()
+		atomic.AddInt64(&c.store.stats.connectedClients, -1)
+	}()
+	for {
+		args, err := readCommand(c.reader)
+		if err != nil {
+			if isProtocolError(err) {
+				c.write(appendReply(nil, errorReply(err.Error())))
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		// command names are case insensitive, like in redis
+		args[0] = strings.ToUpper(args[0])
+		if args[0] == "QUIT" {
+			c.write(appendReply(nil, okReply))
+			return
+		}
+		if blockingCommandNames[args[0]] && c.write(nil) != nil {
+			return
+		}
+		// commands already received are run before replying
+		if c.writeReply(c.run(args), c.reader.Buffered() == 0) != nil {
+			return
+		}
+	}
+}
+
+// Runs a command and returns its encoded reply. While subscribed only
+// subscription commands and PING are allowed, like redis. After MULTI every
+// command is queued until EXEC or DISCARD.
+func (c *respConnection) run(args []string) []byte {
+	if c.transaction.started {
+		return appendReply(nil, c.store.ProcessTransactionCommandReply(c.transaction, formatCommand(args...)))
+	}
+	pubsub := c.store.pubsub
+	switch args[0] {
+	case "SUBSCRIBE", "PSUBSCRIBE", "SSUBSCRIBE":
+		if len(args) < 2 {
+			return appendReply(nil, errorReply("ERR wrong number of arguments for '"+args[0]+"' command"))
+		}
+		c.startSubscriber()
+		return c.confirm(func() []PubSubMessage {
+			switch args[0] {
+			case "SUBSCRIBE":
+				return pubsub.Subscribe(c.subscriber, args[1:])
+			case "PSUBSCRIBE":
+				return pubsub.PSubscribe(c.subscriber, args[1:])
+			}
+			return pubsub.SSubscribe(c.subscriber, args[1:])
+		})
+	case "UNSUBSCRIBE", "PUNSUBSCRIBE", "SUNSUBSCRIBE":
+		c.startSubscriber()
+		return c.confirm(func() []PubSubMessage {
+			switch args[0] {
+			case "UNSUBSCRIBE":
+				return pubsub.Unsubscribe(c.subscriber, args[1:])
+			case "PUNSUBSCRIBE":
+				return pubsub.PUnsubscribe(c.subscriber, args[1:])
+			}
+			return pubsub.SUnsubscribe(c.subscriber, args[1:])
+		})
+	}
+	subscribed := c.subscriber != nil && pubsub.SubscriptionCount(c.subscriber) > 0
+	if args[0] == "PING" && len(args) <= 2 {
+		message := ""
+		if len(args) == 2 {
+			message = args[1]
+		}
+		if subscribed {
+			return appendBulk(appendBulk(appendArrayHeader(nil, 2), "pong"), message)
+		}
+		if len(args) == 2 {
+			return appendBulk(nil, message)
+		}
+		return appendReply(nil, statusReply("PONG"))
+	}
+	if subscribed {
+		return appendReply(nil, errorReply("ERR Can't execute '"+args[0]+"': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT are allowed in this context"))
+	}
+	return appendReply(nil, c.store.ProcessTransactionCommandReply(c.transaction, formatCommand(args...)))
+}
+
+// Runs a (un)subscription and buffers its confirmations as the reply of the
+// command, after those of the commands before it. The write lock is held
+// meanwhile, so messages published once subscribed are written after them.
+func (c *respConnection) confirm(subscribe func() []PubSubMessage) []byte {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	var reply []byte
+	for _, confirmation := range subscribe() {
+		reply = appendPubSubMessage(reply, confirmation)
+	}
+	// an error writing is returned again by the next write
+	c.writer.Write(reply)
+	return nil
+}
+
+// Creates the connection's subscriber on its first subscription and starts
+// writing its messages. A subscriber which falls too far behind is dropped
+// and its connection closed.
+func (c *respConnection) startSubscriber() {
+	if c.subscriber != nil {
+		return
+	}
+	c.subscriber = CreateSubscriber()
+	c.subscriber.returnsConfirmations = true
+	go func() {
+		for {
+			select {
+			case message := <-c.subscriber.Messages:
+				if c.write(appendPubSubMessage(nil, message)) != nil {
+					c.conn.Close()
+					return
+				}
+			case <-c.subscriber.Dropped:
+				serverLog(LOG_WARNING, "Closing connection of subscriber", c.conn.RemoteAddr(), "which is too slow")
+				c.conn.Close()
+				return
+			case <-c.closed:
+				return
+			}
+		}
+	}()
+}
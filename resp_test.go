@@ -0,0 +1,454 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"github.com/thedeveloperr/redis-clone/respClient"
+	"io"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReadCommand(t *testing.T) {
+	input := "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$5\r\na\r\nb!\r\n" +
+		"GET \"with space\"\r\n" +
+		"\n" +
+		"PING\n"
+	reader := bufio.NewReader(strings.NewReader(input))
+	expected := [][]string{{"SET", "k", "a\r\nb!"}, {"GET", "with space"}, nil, {"PING"}}
+	for _, args := range expected {
+		result, err := readCommand(reader)
+		if err != nil || !reflect.DeepEqual(result, args) {
+			t.Errorf("Expected %q but got %q %v", args, result, err)
+		}
+	}
+	for input, expected := range map[string]error{
+		"GET \"unbalanced\r\n":    ErrUnbalancedQuotes,
+		"*x\r\n":                  ErrInvalidMultibulkLength,
+		"*1\r\n$-5\r\n":           ErrInvalidBulkLength,
+		"*1\r\n$999999999999\r\n": ErrInvalidBulkLength,
+	} {
+		if _, err := readCommand(bufio.NewReader(strings.NewReader(input))); err != expected {
+			t.Errorf("Expected %v for %q but got %v", expected, input, err)
+		}
+	}
+}
+
+func TestAppendReply(t *testing.T) {
+	cases := []struct {
+		reply    Reply
+		expected string
+	}{
+		{okReply, "+OK\r\n"},
+		{nilReply, "$-1\r\n"},
+		{integerReply(42), ":42\r\n"},
+		{integerReply(-7), ":-7\r\n"},
+		{bulkReply("007"), "$3\r\n007\r\n"},
+		{bulkReply("42"), "$2\r\n42\r\n"},
+		{bulkReply("OK"), "$2\r\nOK\r\n"},
+		{bulkReply("ERR x"), "$5\r\nERR x\r\n"},
+		{bulkReply("(nil)"), "$5\r\n(nil)\r\n"},
+		{doubleReply("0.5"), "$3\r\n0.5\r\n"},
+		{errorReply("COMMAND NOT VALID"), "-COMMAND NOT VALID\r\n"},
+		{errorReply("ERR two\nlines"), "-ERR two lines\r\n"},
+		{arrayReply(nil), "*0\r\n"},
+		{arrayReply([]Reply{bulkReply("a\nb"), nilReply, integerReply(3), doubleReply("0.5")}), "*4\r\n$3\r\na\nb\r\n$-1\r\n:3\r\n$3\r\n0.5\r\n"},
+		{arrayReply([]Reply{bulkReply("id"), bulkArrayReply([]string{"f", "v"}), bulkArrayReply([]string{"x"}), arrayReply(nil)}), "*4\r\n$2\r\nid\r\n*2\r\n$1\r\nf\r\n$1\r\nv\r\n*1\r\n$1\r\nx\r\n*0\r\n"},
+		{arrayReply([]Reply{okReply, errorReply("ERR value is not an integer")}), "*2\r\n+OK\r\n-ERR value is not an integer\r\n"},
+	}
+	for _, c := range cases {
+		if result := string(appendReply(nil, c.reply)); result != c.expected {
+			t.Errorf("Expected %q for %v but got %q", c.expected, c.reply, result)
+		}
+	}
+}
+
+// Connects to a RESP server running on the store
+func dialTestRESP(t *testing.T, store *InMemoryStore) (net.Conn, *bufio.Reader) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ServeRESP(listener, store)
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return conn, bufio.NewReader(conn)
+}
+
+// Sends a command and checks the exact bytes received back
+func expectRESP(t *testing.T, conn net.Conn, reader *bufio.Reader, command string, expected string) {
+	if command != "" {
+		conn.Write([]byte(command))
+	}
+	received := make([]byte, len(expected))
+	if n, err := io.ReadFull(reader, received); err != nil {
+		t.Fatalf("Ran:%q. Expected %q but got %q then %v", command, expected, received[:n], err)
+	}
+	if string(received) != expected {
+		t.Errorf("Ran:%q. Expected %q but got %q", command, expected, received)
+	}
+}
+
+func TestRESPServer(t *testing.T) {
+	db := CreateTestDbSetup()
+	conn, reader := dialTestRESP(t, db)
+	defer conn.Close()
+	expectRESP(t, conn, reader, "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$9\r\nhello \"x\"\r\n", "+OK\r\n")
+	expectRESP(t, conn, reader, "GET k\r\n", "$9\r\nhello \"x\"\r\n")
+	expectRESP(t, conn, reader, "PING\r\n", "+PONG\r\n")
+	expectRESP(t, conn, reader, "SADD s a b\r\nSMEMBERS s\r\n", ":2\r\n*2\r\n$1\r\na\r\n$1\r\nb\r\n")
+	expectRESP(t, conn, reader, "NOPE\r\n", "-COMMAND NOT VALID\r\n")
+	expectRESP(t, conn, reader, "set lower case\r\nGet lower\r\nping\r\n", "+OK\r\n$4\r\ncase\r\n+PONG\r\n")
+	expectRESP(t, conn, reader, "quit\r\n", "+OK\r\n")
+	if _, err := reader.ReadByte(); err == nil {
+		t.Errorf("Connection should be closed after QUIT")
+	}
+
+	conn, reader = dialTestRESP(t, db)
+	defer conn.Close()
+	expectRESP(t, conn, reader, "GET \"open\r\n", "-ERR Protocol error: unbalanced quotes in request\r\n")
+	if _, err := reader.ReadByte(); err == nil {
+		t.Errorf("Connection should be closed after a protocol error")
+	}
+}
+
+func TestRESPSubscribe(t *testing.T) {
+	db := CreateTestDbSetup()
+	conn, reader := dialTestRESP(t, db)
+	defer conn.Close()
+	expectRESP(t, conn, reader, "SUBSCRIBE news sports\r\n",
+		"*3\r\n$9\r\nsubscribe\r\n$4\r\nnews\r\n:1\r\n*3\r\n$9\r\nsubscribe\r\n$6\r\nsports\r\n:2\r\n")
+	expectRESP(t, conn, reader, "PSUBSCRIBE n*\r\n", "*3\r\n$10\r\npsubscribe\r\n$2\r\nn*\r\n:3\r\n")
+	expectRESP(t, conn, reader, "GET k\r\n",
+		"-ERR Can't execute 'GET': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT are allowed in this context\r\n")
+	expectRESP(t, conn, reader, "PING\r\n", "*2\r\n$4\r\npong\r\n$0\r\n\r\n")
+
+	if result := db.ProcessCommand("PUBLISH news hi"); result != "2" {
+		t.Errorf("Expected 2 receivers but got " + result)
+	}
+	expectRESP(t, conn, reader, "", "*3\r\n$7\r\nmessage\r\n$4\r\nnews\r\n$2\r\nhi\r\n*4\r\n$8\r\npmessage\r\n$2\r\nn*\r\n$4\r\nnews\r\n$2\r\nhi\r\n")
+
+	expectRESP(t, conn, reader, "UNSUBSCRIBE\r\n",
+		"*3\r\n$11\r\nunsubscribe\r\n$4\r\nnews\r\n:2\r\n*3\r\n$11\r\nunsubscribe\r\n$6\r\nsports\r\n:1\r\n")
+	expectRESP(t, conn, reader, "PUNSUBSCRIBE\r\n", "*3\r\n$12\r\npunsubscribe\r\n$2\r\nn*\r\n:0\r\n")
+	expectRESP(t, conn, reader, "GET k\r\n", "$-1\r\n")
+
+	expectRESP(t, conn, reader, "SSUBSCRIBE orders\r\n", "*3\r\n$10\r\nssubscribe\r\n$6\r\norders\r\n:1\r\n")
+	conn.Close()
+	// subscriptions of closed connections are removed
+	deadline := time.Now().Add(time.Second)
+	for db.ProcessCommand("PUBSUB SHARDCHANNELS") != "(empty list or set)" {
+		if time.Now().After(deadline) {
+			t.Fatalf("Subscriptions of closed connection were not removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Confirmations are replies like any other, so they come in order with
+// those of the commands pipelined around them
+func TestRESPPipelinedSubscribe(t *testing.T) {
+	db := CreateTestDbSetup()
+	conn, reader := dialTestRESP(t, db)
+	defer conn.Close()
+	for i := 0; i < 100; i++ {
+		expectRESP(t, conn, reader, "PING\r\nSUBSCRIBE news\r\nPING\r\nUNSUBSCRIBE news\r\nPING\r\n",
+			"+PONG\r\n*3\r\n$9\r\nsubscribe\r\n$4\r\nnews\r\n:1\r\n*2\r\n$4\r\npong\r\n$0\r\n\r\n"+
+				"*3\r\n$11\r\nunsubscribe\r\n$4\r\nnews\r\n:0\r\n+PONG\r\n")
+	}
+}
+
+func TestRESPTransaction(t *testing.T) {
+	db := CreateTestDbSetup()
+	conn, reader := dialTestRESP(t, db)
+	defer conn.Close()
+	expectRESP(t, conn, reader, "WATCH k\r\n", "+OK\r\n")
+	expectRESP(t, conn, reader, "MULTI\r\n", "+OK\r\n")
+	expectRESP(t, conn, reader, "SET k v\r\nINCR k\r\nGET k\r\n", "+QUEUED\r\n+QUEUED\r\n+QUEUED\r\n")
+	expectRESP(t, conn, reader, "EXEC\r\n", "*3\r\n+OK\r\n-ERR value is not an integer or out of range\r\n$1\r\nv\r\n")
+
+	expectRESP(t, conn, reader, "WATCH k\r\n", "+OK\r\n")
+	db.ProcessCommand("SET k changed")
+	expectRESP(t, conn, reader, "MULTI\r\nSET k v\r\nEXEC\r\n", "+OK\r\n+QUEUED\r\n$-1\r\n")
+	expectRESP(t, conn, reader, "MULTI\r\nNOPE\r\nEXEC\r\n",
+		"+OK\r\n-COMMAND NOT VALID\r\n-EXECABORT Transaction discarded because of previous errors.\r\n")
+	expectRESP(t, conn, reader, "GET k\r\n", "$7\r\nchanged\r\n")
+	// values keep their line breaks and aren't taken for integers or errors
+	expectRESP(t, conn, reader, "*3\r\n$3\r\nSET\r\n$1\r\nm\r\n$3\r\na\nb\r\n", "+OK\r\n")
+	expectRESP(t, conn, reader, "MULTI\r\nGET m\r\nSET n 42\r\nGET n\r\nEXEC\r\n",
+		"+OK\r\n+QUEUED\r\n+QUEUED\r\n+QUEUED\r\n*3\r\n$3\r\na\nb\r\n+OK\r\n$2\r\n42\r\n")
+}
+
+func TestRESPPipelining(t *testing.T) {
+	db := CreateTestDbSetup()
+	conn, reader := dialTestRESP(t, db)
+	defer conn.Close()
+	var commands, expected strings.Builder
+	for i := 1; i <= 1000; i++ {
+		commands.WriteString("INCR counter\r\n")
+		expected.WriteString(":" + strconv.Itoa(i) + "\r\n")
+	}
+	go conn.Write([]byte(commands.String()))
+	expectRESP(t, conn, reader, "", expected.String())
+
+	// replies before a blocking command are sent while it waits
+	expectRESP(t, conn, reader, "SET k v\r\nBLPOP list 0\r\n", "+OK\r\n")
+	db.ProcessCommand("RPUSH list x")
+	expectRESP(t, conn, reader, "", "*2\r\n$4\r\nlist\r\n$1\r\nx\r\n")
+}
+
+// Listener whose accepted connections can be closed, like a restarting server does
+type trackingListener struct {
+	net.Listener
+	mutex sync.Mutex
+	conns []net.Conn
+}
+
+func (l *trackingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.mutex.Lock()
+		l.conns = append(l.conns, conn)
+		l.mutex.Unlock()
+	}
+	return conn, err
+}
+
+func (l *trackingListener) closeConns() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, conn := range l.conns {
+		conn.Close()
+	}
+	l.conns = nil
+}
+
+// Starts a RESP server on the store for clients
+func serveTestRESP(t *testing.T, store *InMemoryStore) *trackingListener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracking := &trackingListener{Listener: listener}
+	go ServeRESP(tracking, store)
+	return tracking
+}
+
+func TestRESPClient(t *testing.T) {
+	listener := serveTestRESP(t, CreateTestDbSetup())
+	defer listener.Close()
+	client := respClient.Create(listener.Addr().String(), nil)
+	defer client.Close()
+	ctx := context.Background()
+
+	if err := client.Set(ctx, "greeting", "hello world"); err != nil {
+		t.Fatal(err)
+	}
+	if value, found, err := client.Get(ctx, "greeting"); value != "hello world" || !found || err != nil {
+		t.Errorf("Expected hello world but got %v %v %v", value, found, err)
+	}
+	if _, found, err := client.Get(ctx, "missing"); found || err != nil {
+		t.Errorf("Expected no value but got %v %v", found, err)
+	}
+	client.Set(ctx, "n", "10")
+	if value, found, _ := client.Get(ctx, "n"); value != "10" || !found {
+		t.Errorf("Expected integers to be read back as text but got %v", value)
+	}
+	if value, err := client.IncrBy(ctx, "n", 5); value != 15 || err != nil {
+		t.Errorf("Expected 15 but got %v %v", value, err)
+	}
+	if _, err := client.Incr(ctx, "greeting"); err == nil || err.Error() != "ERR value is not an integer or out of range" {
+		t.Errorf("Expected an error reply but got %v", err)
+	}
+	if added, err := client.HSet(ctx, "user", map[string]string{"name": "Ada", "born": "1815"}); added != 2 || err != nil {
+		t.Errorf("Expected 2 fields added but got %v %v", added, err)
+	}
+	if fields, err := client.HGetAll(ctx, "user"); !reflect.DeepEqual(fields, map[string]string{"name": "Ada", "born": "1815"}) || err != nil {
+		t.Errorf("Expected the fields of user but got %v %v", fields, err)
+	}
+	client.RPush(ctx, "list", "a", "b 'c'")
+	if items, err := client.LRange(ctx, "list", 0, -1); !reflect.DeepEqual(items, []string{"a", "b 'c'"}) || err != nil {
+		t.Errorf("Expected [a b 'c'] but got %q %v", items, err)
+	}
+	client.ZAdd(ctx, "board", respClient.ScoredMember{Member: "alice", Score: 1.5}, respClient.ScoredMember{Member: "bob", Score: 3})
+	if members, err := client.ZRangeWithScores(ctx, "board", 0, -1); !reflect.DeepEqual(members, []respClient.ScoredMember{{Member: "alice", Score: 1.5}, {Member: "bob", Score: 3}}) || err != nil {
+		t.Errorf("Expected alice and bob with their scores but got %v %v", members, err)
+	}
+	if rank, found, err := client.ZRank(ctx, "board", "bob"); rank != 1 || !found || err != nil {
+		t.Errorf("Expected rank 1 but got %v %v %v", rank, found, err)
+	}
+
+	pipeline := client.Pipeline()
+	for i := 0; i < 1000; i++ {
+		pipeline.Do("INCR", "counter")
+	}
+	pipeline.Do("NOPE")
+	replies, err := pipeline.Exec(ctx)
+	if err != nil || len(replies) != 1001 || replies[999] != int64(1000) {
+		t.Fatalf("Expected 1001 replies ending with 1000 but got %v replies %v", len(replies), err)
+	}
+	if replyError, ok := replies[1000].(*respClient.Error); !ok || replyError.Message != "COMMAND NOT VALID" {
+		t.Errorf("Expected the error reply in the pipeline but got %#v", replies[1000])
+	}
+
+	blocked, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.Do(blocked, "BLPOP", "empty", "0"); err != context.DeadlineExceeded {
+		t.Errorf("Expected the deadline to stop BLPOP but got %v", err)
+	}
+	if err := client.Ping(ctx); err != nil {
+		t.Errorf("Expected the client to work after a timeout but got %v", err)
+	}
+}
+
+func TestRESPClientCommandFamilies(t *testing.T) {
+	listener := serveTestRESP(t, CreateTestDbSetup())
+	defer listener.Close()
+	client := respClient.Create(listener.Addr().String(), nil)
+	defer client.Close()
+	ctx := context.Background()
+	expect := func(what string, result interface{}, err error, expected interface{}) {
+		t.Helper()
+		if err != nil || !reflect.DeepEqual(result, expected) {
+			t.Errorf("%s: expected %#v but got %#v %v", what, expected, result, err)
+		}
+	}
+
+	client.MSet(ctx, map[string]string{"a": "1", "b": "x"})
+	values, present, err := client.MGet(ctx, "a", "missing", "b")
+	expect("MGet", []interface{}{values, present}, err, []interface{}{[]string{"1", "", "x"}, []bool{true, false, true}})
+	length, err := client.Append(ctx, "b", "yz")
+	expect("Append", length, err, int64(3))
+	number, err := client.IncrByFloat(ctx, "a", 0.5)
+	expect("IncrByFloat", number, err, 1.5)
+	kind, err := client.Type(ctx, "a")
+	expect("Type", kind, err, "string")
+	keys, next, err := client.Scan(ctx, 0, "", 100)
+	sort.Strings(keys)
+	expect("Scan", []interface{}{keys, next}, err, []interface{}{[]string{"a", "b"}, uint64(0)})
+
+	client.HSet(ctx, "h", map[string]string{"f": "1", "g": "2"})
+	count, err := client.HIncrBy(ctx, "h", "f", 4)
+	expect("HIncrBy", count, err, int64(5))
+	exists, err := client.HExists(ctx, "h", "g")
+	expect("HExists", exists, err, true)
+	count, err = client.HDel(ctx, "h", "g", "none")
+	expect("HDel", count, err, int64(1))
+	items, next, err := client.HScan(ctx, "h", 0, "", 0)
+	expect("HScan", []interface{}{items, next}, err, []interface{}{[]string{"f", "5"}, uint64(0)})
+
+	client.RPush(ctx, "l", "a", "b", "c")
+	value, found, err := client.LPop(ctx, "l")
+	expect("LPop", []interface{}{value, found}, err, []interface{}{"a", true})
+	count, err = client.LLen(ctx, "l")
+	expect("LLen", count, err, int64(2))
+	key, value, found, err := client.BRPop(ctx, 100*time.Millisecond, "empty", "l")
+	expect("BRPop", []interface{}{key, value, found}, err, []interface{}{"l", "c", true})
+	_, _, found, err = client.BLPop(ctx, 10*time.Millisecond, "empty")
+	expect("BLPop timeout", found, err, false)
+
+	client.SAdd(ctx, "s1", "a", "b")
+	client.SAdd(ctx, "s2", "b", "c")
+	members, err := client.SInter(ctx, "s1", "s2")
+	expect("SInter", members, err, []string{"b"})
+	isMember, err := client.SIsMember(ctx, "s1", "c")
+	expect("SIsMember", isMember, err, false)
+
+	id, err := client.XAdd(ctx, "events", "1-1", "kind", "click", "x", "1")
+	expect("XAdd", id, err, "1-1")
+	client.XAdd(ctx, "events", "2-1", "kind", "view")
+	entries, err := client.XRange(ctx, "events", "-", "+", 0)
+	expect("XRange", entries, err, []respClient.StreamEntry{{ID: "1-1", Fields: []string{"kind", "click", "x", "1"}}, {ID: "2-1", Fields: []string{"kind", "view"}}})
+	streams, err := client.XRead(ctx, 1, []string{"events"}, []string{"1-1"})
+	expect("XRead", streams, err, map[string][]respClient.StreamEntry{"events": {{ID: "2-1", Fields: []string{"kind", "view"}}}})
+	streams, err = client.XRead(ctx, 0, []string{"events"}, []string{"$"})
+	expect("XRead nothing", streams, err, map[string][]respClient.StreamEntry{})
+	expect("XGroupCreate", nil, client.XGroupCreate(ctx, "events", "workers", "0", false), nil)
+	streams, err = client.XReadGroup(ctx, "workers", "w1", 1, []string{"events"}, []string{">"})
+	expect("XReadGroup", streams, err, map[string][]respClient.StreamEntry{"events": {{ID: "1-1", Fields: []string{"kind", "click", "x", "1"}}}})
+	count, err = client.XAck(ctx, "events", "workers", "1-1")
+	expect("XAck", count, err, int64(1))
+
+	client.GeoAdd(ctx, "places", respClient.GeoLocation{Member: "a", Longitude: 13.361389, Latitude: 38.115556},
+		respClient.GeoLocation{Member: "b", Longitude: 15.087269, Latitude: 37.502669})
+	distance, found, err := client.GeoDist(ctx, "places", "a", "b", "km")
+	expect("GeoDist", []interface{}{distance, found}, err, []interface{}{166.2742, true})
+	_, _, found, err = client.GeoPos(ctx, "places", "none")
+	expect("GeoPos missing", found, err, false)
+	if longitude, _, found, err := client.GeoPos(ctx, "places", "a"); !found || err != nil || longitude < 13.36 || longitude > 13.37 {
+		t.Errorf("GeoPos: expected the longitude of a but got %v %v %v", longitude, found, err)
+	}
+	members, err = client.GeoSearchRadius(ctx, "places", 15, 37, 200, "km")
+	expect("GeoSearchRadius", members, err, []string{"b", "a"})
+
+	client.SetBit(ctx, "bits", 7, 1)
+	count, err = client.BitCount(ctx, "bits")
+	expect("BitCount", count, err, int64(1))
+	count, err = client.GetBit(ctx, "bits", 7)
+	expect("GetBit", count, err, int64(1))
+
+	changed, err := client.PFAdd(ctx, "visitors", "a", "b", "c")
+	expect("PFAdd", changed, err, true)
+	count, err = client.PFCount(ctx, "visitors")
+	expect("PFCount", count, err, int64(3))
+
+	reply, err := client.Eval(ctx, "return {KEYS[1], ARGV[1], redis.call('GET', KEYS[1])}", []string{"b"}, "arg")
+	expect("Eval", reply, err, []interface{}{"b", "arg", "xyz"})
+	sha, err := client.ScriptLoad(ctx, "return ARGV[1]")
+	expect("ScriptLoad", sha, err, "098e0f0d1448c0a81dafe820f66d460eb09263da")
+	reply, err = client.EvalSha(ctx, sha, nil, "echo")
+	expect("EvalSha", reply, err, "echo")
+	name, err := client.FunctionLoad(ctx, "#!lua name=lib\nredis.register_function('double', function(keys, args) return args[1] * 2 end)", false)
+	expect("FunctionLoad", name, err, "lib")
+	reply, err = client.FCall(ctx, "double", nil, "21")
+	expect("FCall", reply, err, int64(42))
+}
+
+func TestRESPClientReconnects(t *testing.T) {
+	db := CreateTestDbSetup()
+	listener := serveTestRESP(t, db)
+	defer listener.Close()
+	client := respClient.Create(listener.Addr().String(), nil)
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	subscription, err := client.Subscribe(ctx, "news")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscription.Close()
+	expectMessage := func(expected respClient.Message) {
+		select {
+		case message := <-subscription.Channel():
+			if message != expected {
+				t.Errorf("Expected %+v but got %+v", expected, message)
+			}
+		case <-ctx.Done():
+			t.Fatalf("Expected %+v but got nothing", expected)
+		}
+	}
+	expectMessage(respClient.Message{Kind: "subscribe", Channel: "news", Count: 1})
+	if err := client.Set(ctx, "k", "v"); err != nil {
+		t.Fatal(err)
+	}
+
+	listener.closeConns()
+	if value, _, err := client.Get(ctx, "k"); value != "v" || err != nil {
+		t.Errorf("Expected the pooled connection to be replaced but got %v %v", value, err)
+	}
+	expectMessage(respClient.Message{Kind: "subscribe", Channel: "news", Count: 1})
+	if receivers, err := client.Publish(ctx, "news", "back"); receivers != 1 || err != nil {
+		t.Errorf("Expected 1 receiver but got %v %v", receivers, err)
+	}
+	expectMessage(respClient.Message{Kind: "message", Channel: "news", Payload: "back"})
+}
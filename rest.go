@@ -0,0 +1,339 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/thedeveloperr/redis-clone/hashmap"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Status of an error reply: missing groups and scripts are not found, keys
+// holding another type or existing groups conflict, and the rest, like
+// "COMMAND NOT VALID", are bad requests
+func replyStatus(reply string) int {
+	switch {
+	case strings.HasPrefix(reply, "NOGROUP "), strings.HasPrefix(reply, "NOSCRIPT "):
+		return http.StatusNotFound
+	case strings.HasPrefix(reply, "WRONGTYPE "), strings.HasPrefix(reply, "BUSYGROUP "):
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
+
+// Converts a reply to a JSON value: (nil) is null, integers are numbers,
+// arrays are arrays and the others, doubles included, are strings. Error
+// replies are strings too, callers check them first.
+func replyToJSON(reply Reply) interface{} {
+	switch reply.Kind {
+	case REPLY_NIL:
+		return nil
+	case REPLY_INTEGER:
+		return reply.Integer
+	case REPLY_ARRAY:
+		values := make([]interface{}, len(reply.Items))
+		for i, item := range reply.Items {
+			values[i] = replyToJSON(item)
+		}
+		return values
+	}
+	return reply.Text
+}
+
+// Whether the Accept header of r allows JSON, which it does when there is none
+func acceptsJSON(r *http.Request) bool {
+	return acceptQuality(r, "application/json") > 0
+}
+
+// Quality the Accept header gives to a media type like application/json,
+// from its most specific matching range. 1 without an Accept header.
+func acceptQuality(r *http.Request, mediaType string) float64 {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return 1
+	}
+	quality, specificity := 0.0, -1
+	for _, item := range strings.Split(accept, ",") {
+		accepted, params, err := mime.ParseMediaType(strings.TrimSpace(item))
+		if err != nil {
+			continue
+		}
+		matches := 0
+		switch {
+		case accepted == mediaType:
+			matches = 2
+		case accepted == "*/*":
+		case strings.HasSuffix(accepted, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(accepted, "*")):
+			matches = 1
+		default:
+			continue
+		}
+		if matches < specificity {
+			continue
+		}
+		specificity, quality = matches, 1
+		if q, ok := params["q"]; ok {
+			quality, _ = strconv.ParseFloat(q, 64)
+		}
+	}
+	return quality
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// Replies 405 with the allowed methods unless r uses one of them
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeJSONError(w, http.StatusMethodNotAllowed, "Method "+r.Method+" not allowed, use "+strings.Join(methods, " or ")+".")
+	return false
+}
+
+// Runs a command built from args, writing the error reply with its status
+// if it fails. ok is false if it did.
+func runRESTCommand(w http.ResponseWriter, args ...string) (reply Reply, ok bool) {
+	reply = inMemoryDb.ProcessCommandReply(formatCommand(args...))
+	if reply.isError() {
+		writeJSONError(w, replyStatus(reply.Text), reply.Text)
+		return reply, false
+	}
+	return reply, true
+}
+
+// Unescaped path of r after prefix, keys can contain an escaped slash
+func restPath(r *http.Request, prefix string) (string, bool) {
+	path, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), prefix))
+	return path, err == nil
+}
+
+// String values at /keys/{key}: GET replies {"key": key, "value": value},
+// PUT sets the value to the request body, or to the "value" field of a JSON
+// body, and DELETE removes the key
+func keysHandler(w http.ResponseWriter, r *http.Request) {
+	key, ok := restPath(r, "/keys/")
+	if !ok || key == "" {
+		writeJSONError(w, http.StatusNotFound, "Not found.")
+		return
+	}
+	if !acceptsJSON(r) {
+		http.Error(w, "Only application/json responses are available.", http.StatusNotAcceptable)
+		return
+	}
+	if !allowMethods(w, r, "GET", "HEAD", "PUT", "DELETE") {
+		return
+	}
+	switch r.Method {
+	case "GET", "HEAD":
+		value, ok := runRESTCommand(w, "GET", key)
+		if !ok {
+			return
+		}
+		if value.Kind == REPLY_NIL {
+			writeJSONError(w, http.StatusNotFound, "Key not found.")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"key": key, "value": value.Text})
+	case "PUT":
+		value, status, message := requestValue(w, r)
+		if status != 0 {
+			writeJSONError(w, status, message)
+			return
+		}
+		if _, ok := runRESTCommand(w, "SET", key, value); ok {
+			writeJSON(w, http.StatusOK, map[string]string{"key": key, "value": value})
+		}
+	case "DELETE":
+		// keys of any type are deleted, not only strings
+		deleted, ok := runRESTCommand(w, "DEL", key)
+		if !ok {
+			return
+		}
+		if deleted.Integer == 0 {
+			writeJSONError(w, http.StatusNotFound, "Key not found.")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Largest request body read, enough for a value of the largest size allowed
+// with room for the JSON or the commands around it
+var maxRequestBody int64 = hashmap.MAX_STRING_LENGTH + 1024*1024
+
+// Limits the body of r to maxRequestBody bytes, reading past them fails
+func limitBody(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBody)
+}
+
+// Error status and message for a failure reading a body limited by
+// limitBody: 413 if it was too long, 400 otherwise
+func bodyError(err error) (status int, message string) {
+	if err.Error() == "http: request body too large" {
+		return http.StatusRequestEntityTooLarge, "The request body is too large."
+	}
+	return http.StatusBadRequest, "Could not read the request body."
+}
+
+// Reads the body of r, up to maxRequestBody bytes. status and message tell
+// why it couldn't.
+func readBody(w http.ResponseWriter, r *http.Request) (body []byte, status int, message string) {
+	limitBody(w, r)
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		status, message = bodyError(err)
+		return nil, status, message
+	}
+	return body, 0, ""
+}
+
+// Value of a PUT: the "value" field of a JSON body or any other body as is.
+// status is the error status if there is none.
+func requestValue(w http.ResponseWriter, r *http.Request) (value string, status int, message string) {
+	body, status, message := readBody(w, r)
+	if status != 0 {
+		return "", status, message
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		return string(body), 0, ""
+	}
+	var request struct {
+		Value *string `json:"value"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil || request.Value == nil {
+		return "", http.StatusBadRequest, `Expected a JSON object with a string "value".`
+	}
+	return *request.Value, 0, ""
+}
+
+// A member of a sorted set with its score
+type ScoredMember struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+// Sorted sets at /zsets/{key}: POST /zsets/{key}/members adds the member of
+// a JSON object like {"member": "m", "score": 1.5}, or of an array of them,
+// and replies {"added": count}. GET /zsets/{key}/range?start=0&stop=-1
+// replies {"members": [...]} with the members in the range, which are
+// ScoredMember objects with withscores=true.
+func zsetsHandler(w http.ResponseWriter, r *http.Request) {
+	path, ok := restPath(r, "/zsets/")
+	end := strings.LastIndexByte(path, '/')
+	if !ok || end <= 0 || (path[end+1:] != "members" && path[end+1:] != "range") {
+		writeJSONError(w, http.StatusNotFound, "Not found.")
+		return
+	}
+	key, resource := path[:end], path[end+1:]
+	if !acceptsJSON(r) {
+		http.Error(w, "Only application/json responses are available.", http.StatusNotAcceptable)
+		return
+	}
+	if resource == "members" {
+		if allowMethods(w, r, "POST") {
+			addMembers(w, r, key)
+		}
+		return
+	}
+	if allowMethods(w, r, "GET", "HEAD") {
+		memberRange(w, r, key)
+	}
+}
+
+func addMembers(w http.ResponseWriter, r *http.Request, key string) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		writeJSONError(w, http.StatusUnsupportedMediaType, "Members must be sent as application/json.")
+		return
+	}
+	body, status, message := readBody(w, r)
+	if status != 0 {
+		writeJSONError(w, status, message)
+		return
+	}
+	type member struct {
+		Member *string  `json:"member"`
+		Score  *float64 `json:"score"`
+	}
+	var members []member
+	var err error
+	if trimmed := strings.TrimSpace(string(body)); strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(body, &members)
+	} else {
+		members = make([]member, 1)
+		err = json.Unmarshal(body, &members[0])
+	}
+	if err != nil || len(members) == 0 {
+		writeJSONError(w, http.StatusBadRequest, `Expected a member like {"member": "m", "score": 1.5} or an array of them.`)
+		return
+	}
+	args := []string{"ZADD", key}
+	for _, m := range members {
+		if m.Member == nil || m.Score == nil {
+			writeJSONError(w, http.StatusBadRequest, `Each member needs a string "member" and a number "score".`)
+			return
+		}
+		args = append(args, strconv.FormatFloat(*m.Score, 'g', -1, 64), *m.Member)
+	}
+	if added, ok := runRESTCommand(w, args...); ok {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"added": replyToJSON(added)})
+	}
+}
+
+func memberRange(w http.ResponseWriter, r *http.Request, key string) {
+	query := r.URL.Query()
+	bounds := []string{"0", "-1"}
+	for i, name := range []string{"start", "stop"} {
+		if value := query.Get(name); value != "" {
+			if !isInteger(value) {
+				writeJSONError(w, http.StatusBadRequest, name+" must be an integer.")
+				return
+			}
+			bounds[i] = value
+		}
+	}
+	withScores, err := strconv.ParseBool(query.Get("withscores"))
+	if err != nil && query.Get("withscores") != "" {
+		writeJSONError(w, http.StatusBadRequest, "withscores must be true or false.")
+		return
+	}
+	args := []string{"ZRANGE", key, bounds[0], bounds[1]}
+	if withScores {
+		args = append(args, "WITHSCORES")
+	}
+	reply, ok := runRESTCommand(w, args...)
+	if !ok {
+		return
+	}
+	items, _ := replyToJSON(reply).([]interface{})
+	if !withScores {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"members": items})
+		return
+	}
+	members := make([]ScoredMember, 0, len(items)/2)
+	for i := 0; i+1 < len(items); i += 2 {
+		member, _ := items[i].(string)
+		var score float64
+		switch value := items[i+1].(type) {
+		case int64:
+			score = float64(value)
+		case string:
+			score, _ = strconv.ParseFloat(value, 64)
+		}
+		members = append(members, ScoredMember{Member: member, Score: score})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"members": members})
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Sends a request to the REST handlers and checks the status and body received back
+func expectREST(t *testing.T, method string, target string, headers map[string]string, body string, status int, expected string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	recorder := httptest.NewRecorder()
+	httpHandler().ServeHTTP(recorder, request)
+	if result := strings.TrimSuffix(recorder.Body.String(), "\n"); recorder.Code != status || result != expected {
+		t.Errorf("Sent:%v %v %q. Expected: %v %v but Got result: %v %v", method, target, body, status, expected, recorder.Code, result)
+	}
+	return recorder
+}
+
+var jsonContent = map[string]string{"Content-Type": "application/json"}
+
+func TestKeysHandler(t *testing.T) {
+	inMemoryDb = CreateTestDbSetup()
+	expectREST(t, "GET", "/keys/greeting", nil, "", 404, `{"error":"Key not found."}`)
+	expectREST(t, "PUT", "/keys/greeting", nil, "hello world", 200, `{"key":"greeting","value":"hello world"}`)
+	recorder := expectREST(t, "GET", "/keys/greeting", nil, "", 200, `{"key":"greeting","value":"hello world"}`)
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Expected application/json but got " + contentType)
+	}
+	expectREST(t, "PUT", "/keys/a%2Fb", jsonContent, `{"value":"12"}`, 200, `{"key":"a/b","value":"12"}`)
+	expectREST(t, "GET", "/keys/a%2Fb", nil, "", 200, `{"key":"a/b","value":"12"}`)
+	if result := inMemoryDb.ProcessCommand("INCR a/b"); result != "13" {
+		t.Errorf("Expected the REST value to be stored but INCR gave " + result)
+	}
+	expectREST(t, "PUT", "/keys/e", nil, "ERR not an error", 200, `{"key":"e","value":"ERR not an error"}`)
+	expectREST(t, "GET", "/keys/e", nil, "", 200, `{"key":"e","value":"ERR not an error"}`)
+	expectREST(t, "PUT", "/keys/n", nil, "(nil)", 200, `{"key":"n","value":"(nil)"}`)
+	expectREST(t, "GET", "/keys/n", nil, "", 200, `{"key":"n","value":"(nil)"}`)
+	expectREST(t, "PUT", "/keys/k", jsonContent, `{"other":1}`, 400, `{"error":"Expected a JSON object with a string \"value\"."}`)
+	expectREST(t, "DELETE", "/keys/greeting", nil, "", 204, "")
+	expectREST(t, "DELETE", "/keys/greeting", nil, "", 404, `{"error":"Key not found."}`)
+	recorder = expectREST(t, "POST", "/keys/greeting", nil, "", 405, `{"error":"Method POST not allowed, use GET or HEAD or PUT or DELETE."}`)
+	if allow := recorder.Header().Get("Allow"); allow != "GET, HEAD, PUT, DELETE" {
+		t.Errorf("Expected Allow: GET, HEAD, PUT, DELETE but got " + allow)
+	}
+	expectREST(t, "GET", "/keys/", nil, "", 404, `{"error":"Not found."}`)
+	expectREST(t, "GET", "/keys/greeting", map[string]string{"Accept": "text/html"}, "", 406, "Only application/json responses are available.")
+	expectREST(t, "GET", "/keys/a%2Fb", map[string]string{"Accept": "text/html, application/*;q=0.5"}, "", 200, `{"key":"a/b","value":"13"}`)
+}
+
+// DELETE removes keys of every type, not only strings
+func TestKeysHandlerDeletesAnyType(t *testing.T) {
+	inMemoryDb = CreateTestDbSetup()
+	for _, command := range []string{"HSET hash f v", "RPUSH list a", "SADD set m", "ZADD zset 1 m"} {
+		inMemoryDb.ProcessCommand(command)
+	}
+	for _, key := range []string{"hash", "list", "set", "zset"} {
+		expectREST(t, "DELETE", "/keys/"+key, nil, "", 204, "")
+		expectREST(t, "DELETE", "/keys/"+key, nil, "", 404, `{"error":"Key not found."}`)
+		if result := inMemoryDb.ProcessCommand("TYPE " + key); result != "none" {
+			t.Errorf("Expected " + key + " to be deleted but its type is " + result)
+		}
+	}
+}
+
+func TestZsetsHandler(t *testing.T) {
+	inMemoryDb = CreateTestDbSetup()
+	expectREST(t, "GET", "/zsets/board/range", nil, "", 200, `{"members":[]}`)
+	expectREST(t, "POST", "/zsets/board/members", jsonContent, `{"member":"alice","score":1.5}`, 200, `{"added":1}`)
+	expectREST(t, "POST", "/zsets/board/members", jsonContent, `[{"member":"bob","score":3},{"member":"carol","score":2000000},{"member":"alice","score":1.5}]`, 200, `{"added":2}`)
+	expectREST(t, "GET", "/zsets/board/range?start=0&stop=-1", nil, "", 200, `{"members":["alice","bob","carol"]}`)
+	expectREST(t, "GET", "/zsets/board/range?start=1&withscores=true", nil, "", 200, `{"members":[{"member":"bob","score":3},{"member":"carol","score":2000000}]}`)
+	expectREST(t, "GET", "/zsets/board/range?start=one", nil, "", 400, `{"error":"start must be an integer."}`)
+	expectREST(t, "GET", "/zsets/board/range?withscores=maybe", nil, "", 400, `{"error":"withscores must be true or false."}`)
+	expectREST(t, "POST", "/zsets/board/members", jsonContent, `{"member":"dave"}`, 400, `{"error":"Each member needs a string \"member\" and a number \"score\"."}`)
+	expectREST(t, "POST", "/zsets/board/members", jsonContent, `[]`, 400, `{"error":"Expected a member like {\"member\": \"m\", \"score\": 1.5} or an array of them."}`)
+	expectREST(t, "POST", "/zsets/board/members", nil, `{"member":"dave","score":1}`, 415, `{"error":"Members must be sent as application/json."}`)
+	recorder := expectREST(t, "PUT", "/zsets/board/members", jsonContent, "", 405, `{"error":"Method PUT not allowed, use POST."}`)
+	if allow := recorder.Header().Get("Allow"); allow != "POST" {
+		t.Errorf("Expected Allow: POST but got " + allow)
+	}
+	expectREST(t, "GET", "/zsets/board/other", nil, "", 404, `{"error":"Not found."}`)
+	expectREST(t, "GET", "/zsets/board", nil, "", 404, `{"error":"Not found."}`)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"github.com/thedeveloperr/redis-clone/lua"
+	"strings"
+	"sync"
+)
+
+// Name of scripts in the positions of their error messages
+const SCRIPT_CHUNK_NAME = "user_script"
+
+// Compiled scripts by the SHA1 of their source, which EVALSHA runs. Like in
+// redis they live in memory only and are lost on restart.
+type scriptCache struct {
+	mutex   sync.Mutex
+	scripts map[string]*lua.Chunk
+}
+
+func createScriptCache() *scriptCache {
+	return &scriptCache{scripts: make(map[string]*lua.Chunk)}
+}
+
+func scriptSHA(source string) string {
+	sum := sha1.Sum([]byte(source))
+	return hex.EncodeToString(sum[:])
+}
+
+// Compiles source unless it's cached already and returns its SHA1
+func (cache *scriptCache) load(source string) (sha string, chunk *lua.Chunk, err error) {
+	sha = scriptSHA(source)
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if chunk, exists := cache.scripts[sha]; exists {
+		return sha, chunk, nil
+	}
+	chunk, err = lua.Compile(SCRIPT_CHUNK_NAME, source)
+	if err != nil {
+		return sha, nil, err
+	}
+	cache.scripts[sha] = chunk
+	return sha, chunk, nil
+}
+
+// Cached script with the SHA1 sha, nil if there is none
+func (cache *scriptCache) get(sha string) *lua.Chunk {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	return cache.scripts[strings.ToLower(sha)]
+}
+
+func (cache *scriptCache) flush() {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.scripts = make(map[string]*lua.Chunk)
+}
+
+// Converts a reply of a command called by a script to a Lua value the way
+// redis converts RESP: errors become {err = ...} and status replies
+// {ok = ...} tables, (nil) is false, integers are numbers, strings and
+// doubles are strings and arrays are arrays.
+func replyToLua(reply Reply) lua.Value {
+	switch reply.Kind {
+	case REPLY_ERROR:
+		return replyTable("err", reply.Text)
+	case REPLY_STATUS:
+		return replyTable("ok", reply.Text)
+	case REPLY_NIL:
+		return false
+	case REPLY_INTEGER:
+		return float64(reply.Integer)
+	case REPLY_ARRAY:
+		values := make([]lua.Value, len(reply.Items))
+		for i, item := range reply.Items {
+			values[i] = replyToLua(item)
+		}
+		return lua.NewArray(values)
+	}
+	return reply.Text
+}
+
+func replyTable(field string, text string) *lua.Table {
+	table := lua.NewTable()
+	table.Set(field, text)
+	return table
+}
+
+// Converts what a script returned to a reply, the reverse of replyToLua.
+// Numbers are truncated to integers, true is 1, and arrays stop at their
+// first nil like in redis.
+func luaToReply(value lua.Value) Reply {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return integerReply(1)
+		}
+	case float64:
+		return integerReply(int64(v))
+	case string:
+		return bulkReply(v)
+	case *lua.Table:
+		if text, ok := v.Get("err").(string); ok {
+			if !isErrorReply(text) {
+				return errorReply("ERR " + text)
+			}
+			return errorReply(text)
+		}
+		if text, ok := v.Get("ok").(string); ok {
+			return statusReply(text)
+		}
+		var items []Reply
+		for i := 1; v.Get(float64(i)) != nil; i++ {
+			items = append(items, luaToReply(v.Get(float64(i))))
+		}
+		return arrayReply(items)
+	}
+	return nilReply
+}
+
+func luaStrings(values []string) *lua.Table {
+	items := make([]lua.Value, len(values))
+	for i, value := range values {
+		items[i] = value
+	}
+	return lua.NewArray(items)
+}
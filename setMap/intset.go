@@ -0,0 +1,154 @@
+package setMap
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+	"sync/atomic"
+)
+
+// Largest set kept as an Intset, like set-max-intset-entries
+var maxIntsetEntries int64 = 512
+
+// Change the threshold used for sets. Already converted sets stay as hash tables.
+func SetIntsetThreshold(maxEntries int) {
+	atomic.StoreInt64(&maxIntsetEntries, int64(maxEntries))
+}
+
+func GetIntsetThreshold() int {
+	return int(atomic.LoadInt64(&maxIntsetEntries))
+}
+
+// Compact encoding for small sets of integers inspired by redis's intset.
+// Members are kept sorted in a single byte slice, each using the same
+// width of 2, 4 or 8 little endian bytes. The width is upgraded for every
+// member once a value that doesn't fit is added, and never downgraded.
+type Intset struct {
+	width    int
+	contents []byte
+}
+
+func CreateIntset() *Intset {
+	return &Intset{width: 2}
+}
+
+// Parses member as an integer which can be stored in an Intset. Only the
+// canonical form is accepted so "007" or "+7" stay distinct from "7".
+func parseIntsetMember(member string) (int64, bool) {
+	value, err := strconv.ParseInt(member, 10, 64)
+	if err != nil || strconv.FormatInt(value, 10) != member {
+		return 0, false
+	}
+	return value, true
+}
+
+func widthFor(value int64) int {
+	if value >= math.MinInt16 && value <= math.MaxInt16 {
+		return 2
+	}
+	if value >= math.MinInt32 && value <= math.MaxInt32 {
+		return 4
+	}
+	return 8
+}
+
+func (i *Intset) Length() int {
+	return len(i.contents) / i.width
+}
+
+func (i *Intset) get(position int) int64 {
+	offset := position * i.width
+	switch i.width {
+	case 2:
+		return int64(int16(binary.LittleEndian.Uint16(i.contents[offset:])))
+	case 4:
+		return int64(int32(binary.LittleEndian.Uint32(i.contents[offset:])))
+	}
+	return int64(binary.LittleEndian.Uint64(i.contents[offset:]))
+}
+
+func (i *Intset) set(position int, value int64) {
+	offset := position * i.width
+	switch i.width {
+	case 2:
+		binary.LittleEndian.PutUint16(i.contents[offset:], uint16(value))
+	case 4:
+		binary.LittleEndian.PutUint32(i.contents[offset:], uint32(value))
+	default:
+		binary.LittleEndian.PutUint64(i.contents[offset:], uint64(value))
+	}
+}
+
+// Position of value, or where it would be inserted if missing
+func (i *Intset) search(value int64) (int, bool) {
+	low, high := 0, i.Length()
+	for low < high {
+		middle := (low + high) / 2
+		current := i.get(middle)
+		if current == value {
+			return middle, true
+		}
+		if current < value {
+			low = middle + 1
+		} else {
+			high = middle
+		}
+	}
+	return low, false
+}
+
+// Re-encodes every member with a larger width
+func (i *Intset) upgrade(width int) {
+	upgraded := &Intset{width: width, contents: make([]byte, i.Length()*width)}
+	for position := 0; position < i.Length(); position++ {
+		upgraded.set(position, i.get(position))
+	}
+	*i = *upgraded
+}
+
+func (i *Intset) Contains(value int64) bool {
+	if widthFor(value) > i.width {
+		return false
+	}
+	_, found := i.search(value)
+	return found
+}
+
+// Adds value and returns false if it was already a member
+func (i *Intset) Add(value int64) bool {
+	if width := widthFor(value); width > i.width {
+		i.upgrade(width)
+	}
+	position, found := i.search(value)
+	if found {
+		return false
+	}
+	offset := position * i.width
+	i.contents = append(i.contents, make([]byte, i.width)...)
+	copy(i.contents[offset+i.width:], i.contents[offset:])
+	i.set(position, value)
+	return true
+}
+
+// Removes value and returns false if it wasn't a member
+func (i *Intset) Remove(value int64) bool {
+	if widthFor(value) > i.width {
+		return false
+	}
+	position, found := i.search(value)
+	if !found {
+		return false
+	}
+	offset := position * i.width
+	i.contents = append(i.contents[:offset], i.contents[offset+i.width:]...)
+	return true
+}
+
+// Members in ascending order
+func (i *Intset) Members() []int64 {
+	members := make([]int64, i.Length())
+	for position := range members {
+		members[position] = i.get(position)
+	}
+	return members
+}
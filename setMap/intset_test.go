@@ -0,0 +1,78 @@
+package setMap
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestIntsetUpgradesWidth(t *testing.T) {
+	intset := CreateIntset()
+	intset.Add(5)
+	intset.Add(-3)
+	if intset.width != 2 {
+		t.Errorf("Expected width 2 but got %v", intset.width)
+	}
+	intset.Add(math.MaxInt32)
+	if intset.width != 4 {
+		t.Errorf("Expected width 4 but got %v", intset.width)
+	}
+	intset.Add(math.MinInt64)
+	if intset.width != 8 || len(intset.contents) != 4*8 {
+		t.Errorf("Expected 4 members of width 8 but got width %v and %v bytes", intset.width, len(intset.contents))
+	}
+	expected := []int64{math.MinInt64, -3, 5, math.MaxInt32}
+	if members := intset.Members(); !reflect.DeepEqual(members, expected) {
+		t.Errorf("Expected %v but got %v", expected, members)
+	}
+	if intset.Add(5) {
+		t.Errorf("Adding an existing member should return false")
+	}
+	if !intset.Remove(math.MinInt64) || intset.Contains(math.MinInt64) {
+		t.Errorf("Member should be removed")
+	}
+}
+
+func TestIntsetAgainstMap(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	intset := CreateIntset()
+	model := make(map[int64]bool)
+	for i := 0; i < 5000; i++ {
+		value := random.Int63n(200) - 100
+		if random.Intn(5) == 0 {
+			value <<= 40
+		}
+		if random.Intn(3) == 0 {
+			if intset.Remove(value) != model[value] {
+				t.Fatalf("Remove %v disagreed with model", value)
+			}
+			delete(model, value)
+		} else {
+			if intset.Add(value) == model[value] {
+				t.Fatalf("Add %v disagreed with model", value)
+			}
+			model[value] = true
+		}
+	}
+	var expected []int64
+	for value := range model {
+		expected = append(expected, value)
+	}
+	sort.Slice(expected, func(i, j int) bool { return expected[i] < expected[j] })
+	if members := intset.Members(); !reflect.DeepEqual(members, expected) {
+		t.Errorf("Expected %v but got %v", expected, members)
+	}
+}
+
+func TestParseIntsetMember(t *testing.T) {
+	for _, member := range []string{"007", "+7", " 7", "7.0", "9223372036854775808", ""} {
+		if _, ok := parseIntsetMember(member); ok {
+			t.Errorf("%q should not be stored as an integer", member)
+		}
+	}
+	if value, ok := parseIntsetMember("-42"); !ok || value != -42 {
+		t.Errorf("Expected -42 but got %v", value)
+	}
+}
@@ -0,0 +1,441 @@
+package setMap
+
+import (
+	"github.com/thedeveloperr/redis-clone/keyspace"
+	"math/rand"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Unordered set of distinct members. Small sets of integers are stored
+// in an Intset and converted to a map once a member isn't an integer or
+// the set grows past the intset threshold.
+type Set struct {
+	intset  *Intset
+	members map[string]struct{}
+	order   keyspace.ScanOrder // members of a hashtable in the order Scan visits them
+}
+
+func CreateSet() *Set {
+	return &Set{intset: CreateIntset()}
+}
+
+// "intset" or "hashtable", the names redis uses in OBJECT ENCODING
+func (s *Set) Encoding() string {
+	if s.intset != nil {
+		return "intset"
+	}
+	return "hashtable"
+}
+
+func (s *Set) Length() int {
+	if s.intset != nil {
+		return s.intset.Length()
+	}
+	return len(s.members)
+}
+
+// Whether it holds no member, when the set stops existing
+func (s *Set) IsEmpty() bool {
+	return s.Length() == 0
+}
+
+func (s *Set) convertToHashtable() {
+	s.members = make(map[string]struct{}, s.intset.Length())
+	for _, value := range s.intset.Members() {
+		member := strconv.FormatInt(value, 10)
+		s.members[member] = struct{}{}
+		s.order.Add(member)
+	}
+	s.intset = nil
+}
+
+func (s *Set) Contains(member string) bool {
+	if s.intset != nil {
+		value, ok := parseIntsetMember(member)
+		return ok && s.intset.Contains(value)
+	}
+	_, exists := s.members[member]
+	return exists
+}
+
+// Adds member and returns false if it was already there
+func (s *Set) Add(member string) bool {
+	if s.intset != nil {
+		value, ok := parseIntsetMember(member)
+		if ok && (s.intset.Length() < GetIntsetThreshold() || s.intset.Contains(value)) {
+			return s.intset.Add(value)
+		}
+		s.convertToHashtable()
+	}
+	if _, exists := s.members[member]; exists {
+		return false
+	}
+	s.members[member] = struct{}{}
+	s.order.Add(member)
+	return true
+}
+
+// Removes member and returns false if it wasn't there
+func (s *Set) Remove(member string) bool {
+	if s.intset != nil {
+		value, ok := parseIntsetMember(member)
+		return ok && s.intset.Remove(value)
+	}
+	if _, exists := s.members[member]; !exists {
+		return false
+	}
+	delete(s.members, member)
+	s.order.Remove(member)
+	return true
+}
+
+// All members, numerically ordered for intsets and lexicographically otherwise
+func (s *Set) Members() []string {
+	members := make([]string, 0, s.Length())
+	if s.intset != nil {
+		for _, value := range s.intset.Members() {
+			members = append(members, strconv.FormatInt(value, 10))
+		}
+		return members
+	}
+	for member := range s.members {
+		members = append(members, member)
+	}
+	sort.Strings(members)
+	return members
+}
+
+type ConcurrentSetMap struct {
+	keyspace *keyspace.Keyspace
+}
+
+// Sets stored in a keyspace of their own
+func Create() *ConcurrentSetMap {
+	return CreateInKeyspace(keyspace.New())
+}
+
+// Sets stored in keyspace, along with the values of other types
+func CreateInKeyspace(keyspace *keyspace.Keyspace) *ConcurrentSetMap {
+	return &ConcurrentSetMap{keyspace: keyspace}
+}
+
+// Set at key. Caller must hold the lock of the shard.
+func getUnsafe(s *keyspace.Shard, key string) (*Set, bool) {
+	entry, exists := s.Get(key)
+	if !exists {
+		return nil, false
+	}
+	set, isSet := entry.Value.(*Set)
+	return set, isSet
+}
+
+// Keys which exist, in no particular order
+func (c *ConcurrentSetMap) Keys() []string {
+	return c.keyspace.Keys(IsSet)
+}
+
+// Whether value, read from a keyspace, is a set
+func IsSet(value interface{}) bool {
+	_, isSet := value.(*Set)
+	return isSet
+}
+
+// Returns set at key, creating an empty one if missing. Caller must hold the write lock.
+func getOrCreateUnsafe(s *keyspace.Shard, key string) *Set {
+	set, exists := getUnsafe(s, key)
+	if !exists {
+		set = CreateSet()
+		s.Set(key, set)
+	}
+	return set
+}
+
+// Removes key once its set is empty, like redis. Caller must hold the write lock.
+func deleteIfEmptyUnsafe(s *keyspace.Shard, key string, set *Set) {
+	if set.IsEmpty() {
+		s.Delete(key)
+	}
+}
+
+// Adds members and returns how many are new
+func (c *ConcurrentSetMap) Add(key string, members []string) int {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	set := getOrCreateUnsafe(s, key)
+	added := 0
+	for _, member := range members {
+		if set.Add(member) {
+			added++
+		}
+	}
+	return added
+}
+
+// Removes members and returns how many existed
+func (c *ConcurrentSetMap) Remove(key string, members []string) int {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	valueItem, exists := getUnsafe(s, key)
+	if !exists {
+		return 0
+	}
+	removed := 0
+	for _, member := range members {
+		if valueItem.Remove(member) {
+			removed++
+		}
+	}
+	deleteIfEmptyUnsafe(s, key, valueItem)
+	return removed
+}
+
+func (c *ConcurrentSetMap) IsMember(key string, member string) bool {
+	return c.MIsMember(key, []string{member})[0]
+}
+
+func (c *ConcurrentSetMap) MIsMember(key string, members []string) []bool {
+	s := c.keyspace.Shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	results := make([]bool, len(members))
+	if valueItem, exists := getUnsafe(s, key); exists {
+		for i, member := range members {
+			results[i] = valueItem.Contains(member)
+		}
+	}
+	return results
+}
+
+func (c *ConcurrentSetMap) Members(key string) []string {
+	s := c.keyspace.Shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	if valueItem, exists := getUnsafe(s, key); exists {
+		return valueItem.Members()
+	}
+	return []string{}
+}
+
+func (c *ConcurrentSetMap) Card(key string) int {
+	s := c.keyspace.Shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	if valueItem, exists := getUnsafe(s, key); exists {
+		return valueItem.Length()
+	}
+	return 0
+}
+
+// Encoding of set at key, "" if missing
+func (c *ConcurrentSetMap) Encoding(key string) string {
+	s := c.keyspace.Shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	if valueItem, exists := getUnsafe(s, key); exists {
+		return valueItem.Encoding()
+	}
+	return ""
+}
+
+// Removes and returns up to count random members. exists is false if there is no set at key.
+func (c *ConcurrentSetMap) Pop(key string, count int64) (members []string, exists bool) {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	valueItem, exists := getUnsafe(s, key)
+	if !exists {
+		return nil, false
+	}
+	members = pickRandom(valueItem.Members(), count)
+	for _, member := range members {
+		valueItem.Remove(member)
+	}
+	deleteIfEmptyUnsafe(s, key, valueItem)
+	return members, true
+}
+
+// Random members like SRANDMEMBER. Positive count returns distinct members,
+// up to all of them. Negative count returns -count members which may repeat.
+func (c *ConcurrentSetMap) RandomMembers(key string, count int64) []string {
+	all := c.Members(key)
+	if len(all) == 0 || count >= 0 {
+		return pickRandom(all, count)
+	}
+	members := make([]string, -count)
+	for i := range members {
+		members[i] = all[rand.Intn(len(all))]
+	}
+	return members
+}
+
+func pickRandom(all []string, count int64) []string {
+	members := []string{}
+	order := rand.Perm(len(all))
+	for i := 0; i < len(order) && int64(i) < count; i++ {
+		members = append(members, all[order[i]])
+	}
+	return members
+}
+
+// Atomically moves member from source to destination. Returns false if it
+// isn't a member of source.
+func (c *ConcurrentSetMap) Move(source string, destination string, member string) bool {
+	unlock := c.keyspace.LockShards([]string{source, destination}, true)
+	defer unlock()
+	sourceShard := c.keyspace.Shard(source)
+	valueItem, exists := getUnsafe(sourceShard, source)
+	if !exists || !valueItem.Remove(member) {
+		return false
+	}
+	deleteIfEmptyUnsafe(sourceShard, source, valueItem)
+	getOrCreateUnsafe(c.keyspace.Shard(destination), destination).Add(member)
+	return true
+}
+
+// Set algebra operations of SINTER, SUNION and SDIFF
+const (
+	INTER = iota
+	UNION
+	DIFF
+)
+
+// Combines sets at keys with operation. Missing keys are empty sets.
+// Caller must hold the locks of all keys.
+func (c *ConcurrentSetMap) combineUnsafe(operation int, keys []string) *Set {
+	sets := make([]*Set, len(keys))
+	for i, key := range keys {
+		if set, exists := getUnsafe(c.keyspace.Shard(key), key); exists {
+			sets[i] = set
+		} else {
+			sets[i] = CreateSet()
+		}
+	}
+	result := CreateSet()
+	switch operation {
+	case INTER:
+		// walk the smallest set and probe the others
+		smallest := sets[0]
+		for _, set := range sets {
+			if set.Length() < smallest.Length() {
+				smallest = set
+			}
+		}
+		for _, member := range smallest.Members() {
+			inAll := true
+			for _, set := range sets {
+				if !set.Contains(member) {
+					inAll = false
+					break
+				}
+			}
+			if inAll {
+				result.Add(member)
+			}
+		}
+	case UNION:
+		for _, set := range sets {
+			for _, member := range set.Members() {
+				result.Add(member)
+			}
+		}
+	case DIFF:
+		for _, member := range sets[0].Members() {
+			inOther := false
+			for _, set := range sets[1:] {
+				if set.Contains(member) {
+					inOther = true
+					break
+				}
+			}
+			if !inOther {
+				result.Add(member)
+			}
+		}
+	}
+	return result
+}
+
+// Members of the INTER, UNION or DIFF of sets at keys
+func (c *ConcurrentSetMap) Combine(operation int, keys []string) []string {
+	unlock := c.keyspace.LockShards(keys, false)
+	defer unlock()
+	return c.combineUnsafe(operation, keys).Members()
+}
+
+// Stores the INTER, UNION or DIFF of sets at keys in destination, replacing
+// it, and returns its size. An empty result removes destination.
+func (c *ConcurrentSetMap) CombineStore(operation int, destination string, keys []string) int {
+	unlock := c.keyspace.LockShards(append([]string{destination}, keys...), true)
+	defer unlock()
+	result := c.combineUnsafe(operation, keys)
+	destinationShard := c.keyspace.Shard(destination)
+	if result.IsEmpty() {
+		destinationShard.Delete(destination)
+		return 0
+	}
+	destinationShard.Set(destination, result)
+	return result.Length()
+}
+
+// Size of the intersection of sets at keys, counting at most limit members
+// when limit isn't 0
+func (c *ConcurrentSetMap) InterCard(keys []string, limit int) int {
+	unlock := c.keyspace.LockShards(keys, false)
+	defer unlock()
+	length := c.combineUnsafe(INTER, keys).Length()
+	if limit != 0 && length > limit {
+		return limit
+	}
+	return length
+}
+
+// Returns about count members starting at cursor, and the cursor to continue
+// from, 0 once iteration is complete. A member present for the whole
+// iteration is always returned, see keyspace.ScanOrder. Intsets are small so
+// like redis they are returned whole in a single call.
+func (c *ConcurrentSetMap) Scan(key string, cursor uint64, count int) (nextCursor uint64, members []string) {
+	s := c.keyspace.Shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	valueItem, exists := getUnsafe(s, key)
+	if !exists {
+		return 0, members
+	}
+	if valueItem.intset != nil {
+		return 0, valueItem.Members()
+	}
+	nextCursor = valueItem.order.Scan(cursor, count, func(member string) {
+		members = append(members, member)
+	})
+	return nextCursor, members
+}
+
+func (c *ConcurrentSetMap) Expire(key string, timeoutSeconds int) int {
+	return c.ExpireAt(key, time.Now().Add(time.Duration(timeoutSeconds)*time.Second))
+}
+
+// Expire key at an absolute deadline. A deadline in the past removes the key right away.
+func (c *ConcurrentSetMap) ExpireAt(key string, deadline time.Time) int {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	if _, exists := getUnsafe(s, key); !exists || !s.ExpireAt(key, deadline) {
+		return 0
+	}
+	return 1
+}
+
+// Remove timeout of key. Returns 1 if a timeout was removed
+func (c *ConcurrentSetMap) Persist(key string) int {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	if _, exists := getUnsafe(s, key); !exists || !s.Persist(key) {
+		return 0
+	}
+	return 1
+}
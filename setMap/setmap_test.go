@@ -0,0 +1,104 @@
+package setMap
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func TestSetEncodingConversion(t *testing.T) {
+	defer SetIntsetThreshold(GetIntsetThreshold())
+	SetIntsetThreshold(4)
+	sets := Create()
+	sets.Add("ids", []string{"3", "1", "2"})
+	if sets.Encoding("ids") != "intset" {
+		t.Errorf("Expected intset but got %v", sets.Encoding("ids"))
+	}
+	if members := sets.Members("ids"); !reflect.DeepEqual(members, []string{"1", "2", "3"}) {
+		t.Errorf("Unexpected members %v", members)
+	}
+	sets.Add("ids", []string{"4", "5"})
+	if sets.Encoding("ids") != "hashtable" || sets.Card("ids") != 5 {
+		t.Errorf("Expected 5 members in a hashtable after passing threshold")
+	}
+
+	sets.Add("mixed", []string{"1", "a"})
+	if sets.Encoding("mixed") != "hashtable" || !sets.IsMember("mixed", "1") {
+		t.Errorf("Non integer member should convert to hashtable keeping members")
+	}
+	sets.Add("padded", []string{"1", "01"})
+	if sets.Card("padded") != 2 {
+		t.Errorf("1 and 01 should be different members")
+	}
+}
+
+func TestSetAlgebra(t *testing.T) {
+	sets := Create()
+	sets.Add("a", []string{"1", "2", "3", "x"})
+	sets.Add("b", []string{"2", "3", "4"})
+	sets.Add("c", []string{"3", "x"})
+	cases := []struct {
+		operation int
+		keys      []string
+		expected  []string
+	}{
+		{INTER, []string{"a", "b"}, []string{"2", "3"}},
+		{INTER, []string{"a", "b", "c"}, []string{"3"}},
+		{INTER, []string{"a", "missing"}, []string{}},
+		{UNION, []string{"b", "c", "missing"}, []string{"2", "3", "4", "x"}},
+		{DIFF, []string{"a", "b"}, []string{"1", "x"}},
+		{DIFF, []string{"a", "b", "c"}, []string{"1"}},
+	}
+	for _, c := range cases {
+		if members := sets.Combine(c.operation, c.keys); !reflect.DeepEqual(members, c.expected) {
+			t.Errorf("Operation %v on %v expected %v but got %v", c.operation, c.keys, c.expected, members)
+		}
+	}
+	if size := sets.CombineStore(INTER, "a", []string{"a", "b"}); size != 2 {
+		t.Errorf("Expected stored size 2 but got %v", size)
+	}
+	if size := sets.CombineStore(INTER, "a", []string{"a", "missing"}); size != 0 || sets.Card("a") != 0 {
+		t.Errorf("Empty result should remove destination")
+	}
+	if sets.InterCard([]string{"b", "c"}, 0) != 1 || sets.InterCard([]string{"b", "b"}, 2) != 2 {
+		t.Errorf("Unexpected InterCard result")
+	}
+}
+
+func TestPopMoveAndScan(t *testing.T) {
+	sets := Create()
+	sets.Add("s", []string{"a", "b", "c"})
+	popped, _ := sets.Pop("s", 2)
+	if len(popped) != 2 || sets.Card("s") != 1 {
+		t.Errorf("Expected 2 members popped and 1 left but got %v", popped)
+	}
+	remaining := sets.Members("s")[0]
+	if !sets.Move("s", "t", remaining) || sets.Card("s") != 0 || !sets.IsMember("t", remaining) {
+		t.Errorf("Move should transfer the last member and remove source")
+	}
+	if sets.Move("s", "t", remaining) {
+		t.Errorf("Move from missing set should fail")
+	}
+
+	var members []string
+	for i := 0; i < 100; i++ {
+		members = append(members, "m"+strconv.Itoa(i))
+	}
+	sets.Add("big", members)
+	var scanned []string
+	cursor := uint64(0)
+	for {
+		var batch []string
+		cursor, batch = sets.Scan("big", cursor, 10)
+		scanned = append(scanned, batch...)
+		if cursor == 0 {
+			break
+		}
+	}
+	sort.Strings(scanned)
+	sort.Strings(members)
+	if !reflect.DeepEqual(scanned, members) {
+		t.Errorf("Scan should visit every member exactly once")
+	}
+}
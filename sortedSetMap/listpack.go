@@ -0,0 +1,157 @@
+package sortedSetMap
+
+import (
+	"encoding/binary"
+	"math"
+	"sync/atomic"
+)
+
+// Default thresholds after which a sorted set is converted from the compact
+// listpack encoding to Skiplist + map. Same defaults as redis's
+// zset-max-listpack-entries and zset-max-listpack-value.
+var maxListpackEntries int64 = 128
+var maxListpackValue int64 = 64
+
+// Change the thresholds used for sorted sets. Already converted sets stay as skiplists.
+func SetListpackThresholds(maxEntries int, maxValue int) {
+	atomic.StoreInt64(&maxListpackEntries, int64(maxEntries))
+	atomic.StoreInt64(&maxListpackValue, int64(maxValue))
+}
+
+func GetListpackThresholds() (maxEntries int, maxValue int) {
+	return int(atomic.LoadInt64(&maxListpackEntries)), int(atomic.LoadInt64(&maxListpackValue))
+}
+
+// Compact encoding for small sorted sets inspired by redis's listpack.
+// Entries are packed back to back in a single byte slice ordered by score
+// and then member:
+//
+//	[uvarint length of member][member bytes][score as 8 byte float64]
+//
+// Every operation is a linear scan, which for a few dozen entries is cheaper
+// than chasing skiplist pointers and saves all the per member allocations.
+type Listpack struct {
+	buf    []byte
+	length uint64
+}
+
+func CreateListpack() *Listpack {
+	return &Listpack{}
+}
+
+// Decodes the entry at offset and returns offset of the next one
+func (lp *Listpack) entryAt(offset int) (member []byte, score float64, next int) {
+	memberLen, n := binary.Uvarint(lp.buf[offset:])
+	start := offset + n
+	end := start + int(memberLen)
+	member = lp.buf[start:end]
+	score = math.Float64frombits(binary.LittleEndian.Uint64(lp.buf[end : end+8]))
+	return member, score, end + 8
+}
+
+// Score of member if present
+func (lp *Listpack) Find(member string) (float64, bool) {
+	for offset := 0; offset < len(lp.buf); {
+		entryMember, score, next := lp.entryAt(offset)
+		if string(entryMember) == member {
+			return score, true
+		}
+		offset = next
+	}
+	return 0, false
+}
+
+// Inserts member keeping entries sorted. Caller makes sure member is not present.
+func (lp *Listpack) Insert(score float64, member string) {
+	offset := 0
+	for offset < len(lp.buf) {
+		entryMember, entryScore, next := lp.entryAt(offset)
+		if entryScore > score || (entryScore == score && string(entryMember) > member) {
+			break
+		}
+		offset = next
+	}
+
+	var header [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(header[:], uint64(len(member)))
+	entrySize := n + len(member) + 8
+
+	buf := make([]byte, len(lp.buf)+entrySize)
+	copy(buf, lp.buf[:offset])
+	copy(buf[offset:], header[:n])
+	copy(buf[offset+n:], member)
+	binary.LittleEndian.PutUint64(buf[offset+n+len(member):], math.Float64bits(score))
+	copy(buf[offset+entrySize:], lp.buf[offset:])
+	lp.buf = buf
+	lp.length++
+}
+
+// 0 based rank of member
+func (lp *Listpack) GetRank(member string) (uint64, bool) {
+	var rank uint64 = 0
+	for offset := 0; offset < len(lp.buf); rank++ {
+		entryMember, _, next := lp.entryAt(offset)
+		if string(entryMember) == member {
+			return rank, true
+		}
+		offset = next
+	}
+	return 0, false
+}
+
+// pos is 0 based, same semantics as Skiplist.GetMembersAndScoreInRange
+func (lp *Listpack) GetMembersAndScoreInRange(posStart int64, posEnd int64) (members []string, scores []float64) {
+	start, end, ok := normaliseRange(posStart, posEnd, lp.length)
+	if !ok {
+		return members, scores
+	}
+	var i int64 = 0
+	for offset := 0; offset < len(lp.buf) && i <= end; i++ {
+		entryMember, score, next := lp.entryAt(offset)
+		if i >= start {
+			members = append(members, string(entryMember))
+			scores = append(scores, score)
+		}
+		offset = next
+	}
+	return members, scores
+}
+
+// Removes member. Returns false if it is missing.
+func (lp *Listpack) Delete(member string) bool {
+	for offset := 0; offset < len(lp.buf); {
+		entryMember, _, next := lp.entryAt(offset)
+		if string(entryMember) == member {
+			lp.buf = append(lp.buf[:offset:offset], lp.buf[next:]...)
+			lp.length--
+			return true
+		}
+		offset = next
+	}
+	return false
+}
+
+// Entries with scores within r in order, same semantics as Skiplist.GetMembersAndScoreInScoreRange
+func (lp *Listpack) GetMembersAndScoreInScoreRange(r ScoreRange) (members []string, scores []float64) {
+	for offset := 0; offset < len(lp.buf); {
+		entryMember, score, next := lp.entryAt(offset)
+		if !r.belowMax(score) {
+			break
+		}
+		if r.aboveMin(score) {
+			members = append(members, string(entryMember))
+			scores = append(scores, score)
+		}
+		offset = next
+	}
+	return members, scores
+}
+
+// Calls fn for every entry in order
+func (lp *Listpack) forEach(fn func(member string, score float64)) {
+	for offset := 0; offset < len(lp.buf); {
+		entryMember, score, next := lp.entryAt(offset)
+		fn(string(entryMember), score)
+		offset = next
+	}
+}
@@ -0,0 +1,114 @@
+package sortedSetMap
+
+import (
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func TestListpackInsertKeepsOrder(t *testing.T) {
+	lp := CreateListpack()
+	lp.Insert(2.1, "member4")
+	lp.Insert(0.5, "member1")
+	lp.Insert(4.0, "member5")
+	lp.Insert(2.1, "member3")
+	lp.Insert(1.3, "member2")
+
+	members, scores := lp.GetMembersAndScoreInRange(0, -1)
+	expectedMembers := []string{"member1", "member2", "member3", "member4", "member5"}
+	expectedScores := []float64{0.5, 1.3, 2.1, 2.1, 4.0}
+	if len(members) != len(expectedMembers) {
+		t.Fatalf("Expected %v members but got %v", len(expectedMembers), len(members))
+	}
+	for i := range expectedMembers {
+		if members[i] != expectedMembers[i] || scores[i] != expectedScores[i] {
+			t.Errorf("Expected %v with score %v at %v but got %v with score %v",
+				expectedMembers[i], expectedScores[i], i, members[i], scores[i])
+		}
+	}
+}
+
+func TestListpackFindAndRank(t *testing.T) {
+	lp := CreateListpack()
+	lp.Insert(3, "c")
+	lp.Insert(1, "a")
+	lp.Insert(2, "b")
+
+	if score, exists := lp.Find("b"); !exists || score != 2 {
+		t.Errorf("Expected b with score 2 but got %v, exists: %v", score, exists)
+	}
+	if _, exists := lp.Find("d"); exists {
+		t.Errorf("Should not find member d")
+	}
+	if rank, exists := lp.GetRank("c"); !exists || rank != 2 {
+		t.Errorf("Expected rank 2 for c but got %v, exists: %v", rank, exists)
+	}
+	if _, exists := lp.GetRank("d"); exists {
+		t.Errorf("Should not find rank of member d")
+	}
+}
+
+func TestSortedsetConvertsToSkiplist(t *testing.T) {
+	maxEntries, maxValue := GetListpackThresholds()
+	defer SetListpackThresholds(maxEntries, maxValue)
+	SetListpackThresholds(4, 8)
+
+	set := CreateSortedset()
+	for i := 0; i < 4; i++ {
+		set.Add("member"+strconv.Itoa(i), float64(i))
+	}
+	if set.Encoding() != "listpack" {
+		t.Errorf("Expected listpack encoding but got %v", set.Encoding())
+	}
+	set.Add("member4", 4)
+	if set.Encoding() != "skiplist" {
+		t.Errorf("Expected skiplist encoding after exceeding entries but got %v", set.Encoding())
+	}
+	for i := 0; i < 5; i++ {
+		rank, exists := set.GetRank("member" + strconv.Itoa(i))
+		if !exists || rank != uint64(i) {
+			t.Errorf("Expected rank %v for member%v but got %v, exists: %v", i, i, rank, exists)
+		}
+	}
+	if set.Add("member2", 10) != 0 {
+		t.Errorf("Should not be able to add duplicate member after conversion")
+	}
+
+	set = CreateSortedset()
+	set.Add("short", 1)
+	set.Add("a very long member", 2)
+	if set.Encoding() != "skiplist" {
+		t.Errorf("Expected skiplist encoding after exceeding value length but got %v", set.Encoding())
+	}
+	if set.Length() != 2 {
+		t.Errorf("Expected length 2 but got %v", set.Length())
+	}
+}
+
+// Reports heap bytes used per member for b.N sets of the given size
+func benchmarkMemoryPerMember(b *testing.B, membersPerSet int, forceSkiplist bool) {
+	if forceSkiplist {
+		maxEntries, maxValue := GetListpackThresholds()
+		defer SetListpackThresholds(maxEntries, maxValue)
+		SetListpackThresholds(0, 0)
+	}
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	sets := make([]*Sortedset, b.N)
+	for i := range sets {
+		sets[i] = CreateSortedset()
+		for j := 0; j < membersPerSet; j++ {
+			sets[i].Add("member"+strconv.Itoa(j), float64(j))
+		}
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/float64(b.N*membersPerSet), "B/member")
+	runtime.KeepAlive(sets)
+}
+
+func BenchmarkMemoryListpack3(b *testing.B)   { benchmarkMemoryPerMember(b, 3, false) }
+func BenchmarkMemorySkiplist3(b *testing.B)   { benchmarkMemoryPerMember(b, 3, true) }
+func BenchmarkMemoryListpack100(b *testing.B) { benchmarkMemoryPerMember(b, 100, false) }
+func BenchmarkMemorySkiplist100(b *testing.B) { benchmarkMemoryPerMember(b, 100, true) }
@@ -0,0 +1,189 @@
+package sortedSetMap
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+type testEntry struct {
+	member string
+	score  float64
+}
+
+// Checks invariants plus ranks and full range against a sorted copy of entries
+func checkAgainstReference(t *testing.T, list *Skiplist, entries []testEntry) {
+	t.Helper()
+	if err := list.checkInvariants(); err != nil {
+		t.Fatalf("Invariant broken after %v inserts: %v", len(entries), err)
+	}
+	sorted := make([]testEntry, len(entries))
+	copy(sorted, entries)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].score < sorted[j].score ||
+			(sorted[i].score == sorted[j].score && sorted[i].member < sorted[j].member)
+	})
+	members, scores := list.GetMembersAndScoreInRange(0, -1)
+	if len(members) != len(sorted) {
+		t.Fatalf("Expected %v members in range but got %v", len(sorted), len(members))
+	}
+	for i, entry := range sorted {
+		if members[i] != entry.member || scores[i] != entry.score {
+			t.Fatalf("Expected %v (%v) at %v but got %v (%v)", entry.member, entry.score, i, members[i], scores[i])
+		}
+		if rank := list.GetRank(entry.score, entry.member); rank != uint64(i+1) {
+			t.Fatalf("Expected rank %v for %v but got %v", i+1, entry.member, rank)
+		}
+	}
+}
+
+func TestSkiplistSameSeedSameShape(t *testing.T) {
+	first := CreateSkiplist(WithRandomSeed(42))
+	second := CreateSkiplist(WithRandomSeed(42))
+	for i := 0; i < 200; i++ {
+		first.Insert(float64(i), "member"+strconv.Itoa(i))
+		second.Insert(float64(i), "member"+strconv.Itoa(i))
+	}
+	if first.level != second.level {
+		t.Errorf("Expected same list level but got %v and %v", first.level, second.level)
+	}
+	firstNode := first.header.levels[0].nextNode
+	secondNode := second.header.levels[0].nextNode
+	for firstNode != nil && secondNode != nil {
+		if len(firstNode.levels) != len(secondNode.levels) {
+			t.Fatalf("Node %v has %v levels in one list and %v in the other",
+				firstNode.member, len(firstNode.levels), len(secondNode.levels))
+		}
+		firstNode = firstNode.levels[0].nextNode
+		secondNode = secondNode.levels[0].nextNode
+	}
+}
+
+func TestRandomLevelDistribution(t *testing.T) {
+	list := CreateSkiplist(WithRandomSeed(7))
+	draws := 100000
+	counts := make([]int, MAX_LEVEL+1)
+	for i := 0; i < draws; i++ {
+		counts[list.randomLevel()]++
+	}
+	// Fraction of nodes reaching at least level n should be SKIPLIST_P^(n-1)
+	atLeast := draws
+	expected := 1.0
+	for level := 1; level <= 4; level++ {
+		fraction := float64(atLeast) / float64(draws)
+		if fraction < expected*0.9 || fraction > expected*1.1 {
+			t.Errorf("Expected about %v of nodes at level >= %v but got %v", expected, level, fraction)
+		}
+		atLeast -= counts[level]
+		expected *= SKIPLIST_P
+	}
+	if counts[MAX_LEVEL] > 0 {
+		t.Errorf("Expected no node at MAX_LEVEL in %v draws but got %v", draws, counts[MAX_LEVEL])
+	}
+}
+
+func TestSkiplistRandomInsertKeepsInvariants(t *testing.T) {
+	for seed := int64(1); seed <= 20; seed++ {
+		random := rand.New(rand.NewSource(seed))
+		list := CreateSkiplist(WithRandomSeed(seed))
+		var entries []testEntry
+		seen := make(map[string]bool)
+		for i := 0; i < 300; i++ {
+			// few distinct scores so ties are ordered by member
+			entry := testEntry{
+				member: "m" + strconv.Itoa(random.Intn(1000)),
+				score:  float64(random.Intn(20)),
+			}
+			if seen[entry.member] {
+				continue
+			}
+			seen[entry.member] = true
+			list.Insert(entry.score, entry.member)
+			entries = append(entries, entry)
+			if err := list.checkInvariants(); err != nil {
+				t.Fatalf("Seed %v: invariant broken after inserting %v: %v", seed, entry.member, err)
+			}
+		}
+		checkAgainstReference(t, list, entries)
+	}
+}
+
+func TestSkiplistRandomDeleteKeepsInvariants(t *testing.T) {
+	for seed := int64(1); seed <= 20; seed++ {
+		random := rand.New(rand.NewSource(seed))
+		list := CreateSkiplist(WithRandomSeed(seed))
+		var entries []testEntry
+		for i := 0; i < 200; i++ {
+			entry := testEntry{member: "m" + strconv.Itoa(i), score: float64(random.Intn(20))}
+			list.Insert(entry.score, entry.member)
+			entries = append(entries, entry)
+		}
+		for len(entries) > 0 {
+			i := random.Intn(len(entries))
+			if list.Delete(entries[i].score+1, entries[i].member) {
+				t.Fatalf("Seed %v: deleted %v with the wrong score", seed, entries[i].member)
+			}
+			if !list.Delete(entries[i].score, entries[i].member) {
+				t.Fatalf("Seed %v: %v not found", seed, entries[i].member)
+			}
+			entries = append(entries[:i], entries[i+1:]...)
+			if err := list.checkInvariants(); err != nil {
+				t.Fatalf("Seed %v: invariant broken after delete: %v", seed, err)
+			}
+		}
+		checkAgainstReference(t, list, entries)
+	}
+}
+
+func TestScoreRange(t *testing.T) {
+	list := CreateSkiplist()
+	lp := CreateListpack()
+	for i := 0; i < 10; i++ {
+		list.Insert(float64(i), "m"+strconv.Itoa(i))
+		lp.Insert(float64(i), "m"+strconv.Itoa(i))
+	}
+	cases := []struct {
+		r        ScoreRange
+		expected int
+	}{
+		{ScoreRange{Min: 2, Max: 5}, 4},
+		{ScoreRange{Min: 2, Max: 5, MinExclusive: true, MaxExclusive: true}, 2},
+		{ScoreRange{Min: -100, Max: 100}, 10},
+		{ScoreRange{Min: 5, Max: 2}, 0},
+		{ScoreRange{Min: 9, Max: 9, MaxExclusive: true}, 0},
+	}
+	for _, c := range cases {
+		members, _ := list.GetMembersAndScoreInScoreRange(c.r)
+		lpMembers, _ := lp.GetMembersAndScoreInScoreRange(c.r)
+		if len(members) != c.expected || len(lpMembers) != c.expected {
+			t.Errorf("Range %v expected %v members but got %v and %v", c.r, c.expected, members, lpMembers)
+		}
+	}
+}
+
+// Every 3 bytes of input is one insert: member id from the first two and score from the last.
+func FuzzSkiplistInsert(f *testing.F) {
+	f.Add(int64(1), []byte{0, 1, 5, 0, 2, 5, 0, 3, 1})
+	f.Add(int64(99), []byte{1, 1, 1, 2, 2, 2, 3, 3, 3, 4, 4, 4})
+	f.Fuzz(func(t *testing.T, seed int64, ops []byte) {
+		list := CreateSkiplist(WithRandomSeed(seed))
+		var entries []testEntry
+		seen := make(map[string]bool)
+		for i := 0; i+2 < len(ops); i += 3 {
+			member := "m" + strconv.Itoa(int(binary.BigEndian.Uint16(ops[i:i+2])))
+			if seen[member] {
+				continue
+			}
+			seen[member] = true
+			score := float64(int8(ops[i+2]))
+			list.Insert(score, member)
+			entries = append(entries, testEntry{member: member, score: score})
+			if err := list.checkInvariants(); err != nil {
+				t.Fatalf("Invariant broken after inserting %v: %v", member, err)
+			}
+		}
+		checkAgainstReference(t, list, entries)
+	})
+}
@@ -1,8 +1,10 @@
 package sortedSetMap
 
 import (
+	"fmt"
+	"github.com/thedeveloperr/redis-clone/keyspace"
 	"math/rand"
-	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,35 +18,51 @@ type Level struct {
 type SkiplistNode struct {
 	member string
 	score  float64
-	levels []*Level // Array of Level stacked up on the Node
+	levels []Level // Levels stacked up on the Node, stored inline to avoid an allocation per level
 }
 
+// Probability of a node being promoted to the next level, same as redis
+const SKIPLIST_P = 0.25
+
 type Skiplist struct {
 	header, tail *SkiplistNode // Start and end
 	length       uint64        // number of nodes
 	level        uint          // level at which current list is at
+	// levels are drawn from a source of the list's own, as lists of different
+	// shards are written concurrently
 	randomSource rand.Source
 }
 
-func CreateSkiplist() *Skiplist {
+// Seeds of the sources of skiplists created without WithRandomSeed, which
+// differ from one list to the next
+var nextSeed = time.Now().UnixNano()
+
+type SkiplistOption func(list *Skiplist)
+
+// Draw node levels from a source seeded with seed instead of the time,
+// eg. 42 to get the same skiplist shape on every run
+func WithRandomSeed(seed int64) SkiplistOption {
+	return func(list *Skiplist) {
+		list.randomSource = rand.NewSource(seed)
+	}
+}
+
+func CreateSkiplist(options ...SkiplistOption) *Skiplist {
 	result := &Skiplist{
 		header: &SkiplistNode{
 			score:  0.0,
 			member: "",
-			levels: make([]*Level, MAX_LEVEL),
+			levels: make([]Level, MAX_LEVEL),
 		},
 
-		level:        1,
-		length:       0,
-		randomSource: rand.New(rand.NewSource(time.Now().UnixNano())),
+		level:  1,
+		length: 0,
 	}
-
-	// initialise each level to empty list
-	for j := 0; j < MAX_LEVEL; j++ {
-		result.header.levels[j] = &Level{
-			nextNode:         nil,
-			distanceNextNode: 0,
-		}
+	for _, option := range options {
+		option(result)
+	}
+	if result.randomSource == nil {
+		result.randomSource = rand.NewSource(atomic.AddInt64(&nextSeed, 1))
 	}
 
 	result.tail = nil
@@ -55,28 +73,17 @@ func CreateSkiplistNode(level uint, score float64, member string) *SkiplistNode
 	result := &SkiplistNode{
 		score:  score,
 		member: member,
-		levels: make([]*Level, level),
-	}
-	// initialise each level to empty list
-	for j := 0; j < int(level); j++ {
-		result.levels[j] = &Level{
-			nextNode:         nil,
-			distanceNextNode: 0,
-		}
+		levels: make([]Level, level),
 	}
-
 	return result
 }
 
+// Geometric distribution, level n+1 is reached with probability SKIPLIST_P from level n
 func (list *Skiplist) randomLevel() uint {
-
-	// Float64() for some reason not buidling so used Int63 to get Float64
-	r := float64(list.randomSource.Int63()) / (1 << 63)
-
 	var level uint = 1
 
-	// 1/2 ratio between nodes at each level for skiplist
-	for level < MAX_LEVEL && r < 0.5 {
+	// Float64() for some reason not buidling so used Int63 to get Float64
+	for level < MAX_LEVEL && float64(list.randomSource.Int63())/(1<<63) < SKIPLIST_P {
 		level++
 	}
 	return level
@@ -91,7 +98,7 @@ func (list *Skiplist) GetPreviousNodesAndRanks(score float64, member string) ([M
 
 	// Start from top level and move down and right
 	for i := int(list.level) - 1; i >= 0; i-- {
-		level := iteratorNode.levels[i]
+		level := &iteratorNode.levels[i]
 		if i == int(list.level)-1 {
 			rank[i] = 0
 		} else {
@@ -102,7 +109,7 @@ func (list *Skiplist) GetPreviousNodesAndRanks(score float64, member string) ([M
 				(level.nextNode.score == score && level.nextNode.member < member)) { // ZADD needs lexograohical sorting if score is same
 			rank[i] += level.distanceNextNode
 			iteratorNode = level.nextNode
-			level = iteratorNode.levels[i]
+			level = &iteratorNode.levels[i]
 		}
 		// Found the correct node to insert after
 		// at i level, continue to downward level
@@ -161,13 +168,13 @@ func (list *Skiplist) GetRank(score float64, member string) uint64 {
 
 	// Start from top level and move down and right
 	for i := int(list.level) - 1; i >= 0; i-- {
-		level := iteratorNode.levels[i]
+		level := &iteratorNode.levels[i]
 		for (level.nextNode != nil) &&
 			((level.nextNode.score < score) ||
 				(level.nextNode.score == score && level.nextNode.member <= member)) {
 			rank += level.distanceNextNode
 			iteratorNode = level.nextNode
-			level = iteratorNode.levels[i]
+			level = &iteratorNode.levels[i]
 		}
 		if iteratorNode.member == member {
 			return rank
@@ -185,12 +192,12 @@ func (list *Skiplist) GetNodeAtRank(rank uint64) *SkiplistNode {
 
 	// Start from top level and move down and right
 	for i := int(list.level) - 1; i >= 0; i-- {
-		level := iteratorNode.levels[i]
+		level := &iteratorNode.levels[i]
 		for (level.nextNode != nil) &&
 			(distanceTravelled+level.distanceNextNode <= rank) {
 			distanceTravelled += level.distanceNextNode
 			iteratorNode = level.nextNode
-			level = iteratorNode.levels[i]
+			level = &iteratorNode.levels[i]
 		}
 	}
 	if rank == distanceTravelled {
@@ -202,21 +209,8 @@ func (list *Skiplist) GetNodeAtRank(rank uint64) *SkiplistNode {
 
 // pos is 0 based
 func (list *Skiplist) GetMembersAndScoreInRange(posStart int64, posEnd int64) (members []string, scores []float64) {
-	correctedStartPos := posStart
-	correctedEndPos := posEnd
-	if posStart < 0 {
-		correctedStartPos = posStart + int64(list.length)
-	}
-
-	if posEnd < 0 {
-		correctedEndPos = posEnd + int64(list.length)
-	}
-
-	if correctedStartPos < 0 {
-		correctedStartPos = 0
-	}
-
-	if correctedStartPos > correctedEndPos {
+	correctedStartPos, correctedEndPos, ok := normaliseRange(posStart, posEnd, list.length)
+	if !ok {
 		return members, scores
 	}
 
@@ -232,6 +226,158 @@ func (list *Skiplist) GetMembersAndScoreInRange(posStart int64, posEnd int64) (m
 	return members, scores
 }
 
+// Removes the node with score and member. Returns false if it isn't in the list.
+func (list *Skiplist) Delete(score float64, member string) bool {
+	previousNodes, _ := list.GetPreviousNodesAndRanks(score, member)
+	node := previousNodes[0].levels[0].nextNode
+	if node == nil || node.score != score || node.member != member {
+		return false
+	}
+	for i := 0; i < int(list.level); i++ {
+		level := &previousNodes[i].levels[i]
+		if level.nextNode == node {
+			level.distanceNextNode += node.levels[i].distanceNextNode - 1
+			level.nextNode = node.levels[i].nextNode
+		} else {
+			level.distanceNextNode--
+		}
+	}
+	if list.tail == node {
+		list.tail = previousNodes[0]
+		if list.tail == list.header {
+			list.tail = nil
+		}
+	}
+	// drop levels only the removed node used
+	for list.level > 1 && list.header.levels[list.level-1].nextNode == nil {
+		list.header.levels[list.level-1].distanceNextNode = 0
+		list.level--
+	}
+	list.length--
+	if list.length == 0 {
+		list.header.levels[0].distanceNextNode = 0
+	}
+	return true
+}
+
+// Scores from Min to Max, like ZRANGEBYSCORE. A bound is excluded when its
+// Exclusive flag is set, like the "(" prefix of ZRANGEBYSCORE.
+type ScoreRange struct {
+	Min, Max                   float64
+	MinExclusive, MaxExclusive bool
+}
+
+func (r ScoreRange) aboveMin(score float64) bool {
+	return score > r.Min || (!r.MinExclusive && score == r.Min)
+}
+
+func (r ScoreRange) belowMax(score float64) bool {
+	return score < r.Max || (!r.MaxExclusive && score == r.Max)
+}
+
+// Nodes with scores within r in order. Finding the first one is O(log(N)).
+func (list *Skiplist) GetMembersAndScoreInScoreRange(r ScoreRange) (members []string, scores []float64) {
+	iteratorNode := list.header
+	for i := int(list.level) - 1; i >= 0; i-- {
+		for iteratorNode.levels[i].nextNode != nil && !r.aboveMin(iteratorNode.levels[i].nextNode.score) {
+			iteratorNode = iteratorNode.levels[i].nextNode
+		}
+	}
+	for node := iteratorNode.levels[0].nextNode; node != nil && r.belowMax(node.score); node = node.levels[0].nextNode {
+		members = append(members, node.member)
+		scores = append(scores, node.score)
+	}
+	return members, scores
+}
+
+// Walks the whole list and verifies ordering, length, tail, list level and the
+// distanceNextNode of every level. Returns the first broken invariant found.
+func (list *Skiplist) checkInvariants() error {
+	if list.level < 1 || list.level > MAX_LEVEL {
+		return fmt.Errorf("list level %v out of range", list.level)
+	}
+
+	// rank of every node by walking level 0
+	ranks := make(map[*SkiplistNode]uint64)
+	var length uint64 = 0
+	var maxLevel uint = 1
+	var last *SkiplistNode
+	for node := list.header.levels[0].nextNode; node != nil; node = node.levels[0].nextNode {
+		length++
+		ranks[node] = length
+		if len(node.levels) < 1 || len(node.levels) > MAX_LEVEL {
+			return fmt.Errorf("node %v has %v levels", node.member, len(node.levels))
+		}
+		if uint(len(node.levels)) > maxLevel {
+			maxLevel = uint(len(node.levels))
+		}
+		if last != nil && (last.score > node.score || (last.score == node.score && last.member >= node.member)) {
+			return fmt.Errorf("node %v (%v) is not ordered after %v (%v)", node.member, node.score, last.member, last.score)
+		}
+		last = node
+	}
+	if length != list.length {
+		return fmt.Errorf("length is %v but found %v nodes", list.length, length)
+	}
+	if last != list.tail {
+		return fmt.Errorf("tail does not point to the last node")
+	}
+	if maxLevel != list.level && length > 0 {
+		return fmt.Errorf("list level is %v but tallest node has %v levels", list.level, maxLevel)
+	}
+
+	for i := 0; i < MAX_LEVEL; i++ {
+		if uint(i) >= list.level {
+			if list.header.levels[i].nextNode != nil || list.header.levels[i].distanceNextNode != 0 {
+				return fmt.Errorf("header level %v is above list level %v but not empty", i, list.level)
+			}
+			continue
+		}
+		var rank uint64 = 0
+		for node := list.header; node != nil; node = node.levels[i].nextNode {
+			level := node.levels[i]
+			expected := length - rank
+			if level.nextNode != nil {
+				nextRank, exists := ranks[level.nextNode]
+				if !exists {
+					return fmt.Errorf("level %v links to a node missing from level 0", i)
+				}
+				expected = nextRank - rank
+			}
+			if level.distanceNextNode != expected {
+				return fmt.Errorf("distanceNextNode at level %v after rank %v is %v, expected %v", i, rank, level.distanceNextNode, expected)
+			}
+			if level.nextNode != nil {
+				rank = ranks[level.nextNode]
+			}
+		}
+	}
+	return nil
+}
+
+// Resolves negative positions from the end of a list of given length.
+// ok is false if the range is empty.
+func normaliseRange(posStart int64, posEnd int64, length uint64) (start int64, end int64, ok bool) {
+	start = posStart
+	end = posEnd
+	if posStart < 0 {
+		start = posStart + int64(length)
+	}
+
+	if posEnd < 0 {
+		end = posEnd + int64(length)
+	}
+
+	if start < 0 {
+		start = 0
+	}
+
+	if start > end {
+		return start, end, false
+	}
+	return start, end, true
+}
+
 type SortedsetMap interface {
 	GetRank(key string, member string) uint64
 	Add(key string, member string, score float64) int
@@ -239,116 +385,327 @@ type SortedsetMap interface {
 	Expire(key string, timeoutSeconds int) int
 }
 
-type Value struct {
-	value        *Sortedset
-	setAt        time.Time
-	expireAfter  time.Duration
-	shouldExpire bool
+// Small sets are stored in a Listpack, once they outgrow the listpack thresholds
+// they are converted to a Skiplist for ordering plus a map for member lookups.
+type Sortedset struct {
+	listpack        *Listpack
+	memberScoreMap  map[string]float64
+	skiplist        *Skiplist
+	skiplistOptions []SkiplistOption // used when converting to skiplist
 }
 
-type Sortedset struct {
-	memberScoreMap map[string]float64
-	skiplist       *Skiplist
+func CreateSortedset(options ...SkiplistOption) *Sortedset {
+	return &Sortedset{
+		listpack:        CreateListpack(),
+		skiplistOptions: options,
+	}
 }
 
-type ConcurrentSortedsetMap struct {
-	mutex sync.RWMutex
-	data  map[string]*Value
+// "listpack" or "skiplist"
+func (set *Sortedset) Encoding() string {
+	if set.listpack != nil {
+		return "listpack"
+	}
+	return "skiplist"
 }
 
-func Create() *ConcurrentSortedsetMap {
-	sortedsetmap := &ConcurrentSortedsetMap{
-		data: make(map[string]*Value),
+func (set *Sortedset) Length() uint64 {
+	if set.listpack != nil {
+		return set.listpack.length
 	}
-	return sortedsetmap
+	return set.skiplist.length
 }
 
-func (c *ConcurrentSortedsetMap) Add(key string, member string, score float64) int {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-	var skiplist *Skiplist
-	if _, exists := c.data[key]; !exists {
-		skiplist = CreateSkiplist()
+// Whether it holds no member, when the set stops existing
+func (set *Sortedset) IsEmpty() bool {
+	return set.Length() == 0
+}
+
+func (set *Sortedset) convertToSkiplist() {
+	skiplist := CreateSkiplist(set.skiplistOptions...)
+	memberScoreMap := make(map[string]float64, set.listpack.length)
+	set.listpack.forEach(func(member string, score float64) {
 		skiplist.Insert(score, member)
-		sortedset := &Sortedset{
-			memberScoreMap: make(map[string]float64),
-			skiplist:       skiplist,
+		memberScoreMap[member] = score
+	})
+	set.skiplist = skiplist
+	set.memberScoreMap = memberScoreMap
+	set.listpack = nil
+}
+
+// Returns 1 if member is added and 0 if it already exists
+func (set *Sortedset) Add(member string, score float64) int {
+	if set.listpack != nil {
+		if _, exists := set.listpack.Find(member); exists {
+			return 0
 		}
-		sortedset.memberScoreMap[member] = score
-		c.data[key] = &Value{
-			value:        sortedset,
-			setAt:        time.Now(),
-			expireAfter:  0,
-			shouldExpire: false,
+		maxEntries, maxValue := GetListpackThresholds()
+		if int(set.listpack.length) < maxEntries && len(member) <= maxValue {
+			set.listpack.Insert(score, member)
+			return 1
 		}
-		return 1
+		set.convertToSkiplist()
 	}
 
-	if _, exists := c.data[key].value.memberScoreMap[member]; exists {
+	if _, exists := set.memberScoreMap[member]; exists {
 		return 0
 	}
-
-	skiplist = c.data[key].value.skiplist
-	c.data[key].value.memberScoreMap[member] = score
-	skiplist.Insert(score, member)
+	set.memberScoreMap[member] = score
+	set.skiplist.Insert(score, member)
 	return 1
 }
 
-func (c *ConcurrentSortedsetMap) GetRank(key string, member string) (uint64, bool) {
-	c.mutex.RLock()
-	defer c.mutex.RUnlock()
-
-	valueItem, exists := c.GetUnsafe(key)
+// 0 based rank of member
+func (set *Sortedset) GetRank(member string) (uint64, bool) {
+	if set.listpack != nil {
+		return set.listpack.GetRank(member)
+	}
+	score, exists := set.memberScoreMap[member]
 	if !exists {
 		return 0, false
 	}
-	score := valueItem.value.memberScoreMap[member]
-	rank := valueItem.value.skiplist.GetRank(score, member)
+	rank := set.skiplist.GetRank(score, member)
 	if rank == 0 {
 		return 0, false
 	}
 	return rank - 1, true
 }
 
+func (set *Sortedset) GetMembersAndScoreInRange(start int64, end int64) (members []string, scores []float64) {
+	if set.listpack != nil {
+		return set.listpack.GetMembersAndScoreInRange(start, end)
+	}
+	return set.skiplist.GetMembersAndScoreInRange(start, end)
+}
+
+// Score of member if present
+func (set *Sortedset) Score(member string) (float64, bool) {
+	if set.listpack != nil {
+		return set.listpack.Find(member)
+	}
+	score, exists := set.memberScoreMap[member]
+	return score, exists
+}
+
+// Changes the score of an existing member. Returns false if it is missing.
+func (set *Sortedset) UpdateScore(member string, score float64) bool {
+	oldScore, exists := set.Score(member)
+	if !exists {
+		return false
+	}
+	if set.listpack != nil {
+		set.listpack.Delete(member)
+		set.listpack.Insert(score, member)
+		return true
+	}
+	set.skiplist.Delete(oldScore, member)
+	set.skiplist.Insert(score, member)
+	set.memberScoreMap[member] = score
+	return true
+}
+
+func (set *Sortedset) GetMembersAndScoreInScoreRange(r ScoreRange) (members []string, scores []float64) {
+	if set.listpack != nil {
+		return set.listpack.GetMembersAndScoreInScoreRange(r)
+	}
+	return set.skiplist.GetMembersAndScoreInScoreRange(r)
+}
+
+type ConcurrentSortedsetMap struct {
+	keyspace        *keyspace.Keyspace
+	skiplistOptions []SkiplistOption
+}
+
+// Sorted sets stored in a keyspace of their own. options are applied to the
+// skiplist of every sorted set in the map.
+func Create(options ...SkiplistOption) *ConcurrentSortedsetMap {
+	return CreateInKeyspace(keyspace.New(), options...)
+}
+
+// Sorted sets stored in keyspace, along with the values of other types
+func CreateInKeyspace(keyspace *keyspace.Keyspace, options ...SkiplistOption) *ConcurrentSortedsetMap {
+	return &ConcurrentSortedsetMap{
+		keyspace:        keyspace,
+		skiplistOptions: options,
+	}
+}
+
+// Sorted set at key. Caller must hold the lock of the shard.
+func getUnsafe(s *keyspace.Shard, key string) (*Sortedset, bool) {
+	entry, exists := s.Get(key)
+	if !exists {
+		return nil, false
+	}
+	sortedset, isSortedset := entry.Value.(*Sortedset)
+	return sortedset, isSortedset
+}
+
+func (c *ConcurrentSortedsetMap) Add(key string, member string, score float64) int {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	sortedset, exists := getUnsafe(s, key)
+	if !exists {
+		sortedset = CreateSortedset(c.skiplistOptions...)
+		s.Set(key, sortedset)
+	}
+	return sortedset.Add(member, score)
+}
+
+func (c *ConcurrentSortedsetMap) GetRank(key string, member string) (uint64, bool) {
+	s := c.keyspace.Shard(key)
+	s.RLock()
+	defer s.RUnlock()
+
+	valueItem, exists := getUnsafe(s, key)
+	if !exists {
+		return 0, false
+	}
+	return valueItem.GetRank(member)
+}
+
 func (c *ConcurrentSortedsetMap) GetMembersAndScoreInRange(key string, start int64, end int64) (members []string, scores []float64) {
-	c.mutex.RLock()
-	defer c.mutex.RUnlock()
-	valueItem, exists := c.GetUnsafe(key)
+	s := c.keyspace.Shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	valueItem, exists := getUnsafe(s, key)
 	if !exists {
 		return members, scores
 	}
-	members, scores = valueItem.value.skiplist.GetMembersAndScoreInRange(start, end)
+	members, scores = valueItem.GetMembersAndScoreInRange(start, end)
 	return members, scores
 }
 
-func (c *ConcurrentSortedsetMap) GetUnsafe(key string) (*Value, bool) {
+// Adds members or updates their scores, creating the set if needed. condition
+// is "NX" to only add new members, "XX" to only update existing ones or "".
+// Returns how many members were added and how many had their score changed.
+func (c *ConcurrentSortedsetMap) AddOrUpdate(key string, members []string, scores []float64, condition string) (added int, updated int) {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	valueItem, exists := getUnsafe(s, key)
+	if !exists {
+		if condition == "XX" {
+			return 0, 0
+		}
+		valueItem = CreateSortedset(c.skiplistOptions...)
+		s.Set(key, valueItem)
+	}
+	for i, member := range members {
+		oldScore, isMember := valueItem.Score(member)
+		switch {
+		case !isMember && condition != "XX":
+			added += valueItem.Add(member, scores[i])
+		case isMember && condition != "NX" && oldScore != scores[i]:
+			valueItem.UpdateScore(member, scores[i])
+			updated++
+		}
+	}
+	if valueItem.IsEmpty() {
+		s.Delete(key)
+	}
+	return added, updated
+}
+
+// Scores of members, exists[i] is false for missing members
+func (c *ConcurrentSortedsetMap) Scores(key string, members []string) (scores []float64, exists []bool) {
+	s := c.keyspace.Shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	scores = make([]float64, len(members))
+	exists = make([]bool, len(members))
+	valueItem, ok := getUnsafe(s, key)
+	if !ok {
+		return scores, exists
+	}
+	for i, member := range members {
+		scores[i], exists[i] = valueItem.Score(member)
+	}
+	return scores, exists
+}
 
-	valueItem, exists := c.data[key]
+// Members and scores within each of ranges, read at one point in time.
+// Members in overlapping ranges are returned once per range.
+func (c *ConcurrentSortedsetMap) GetMembersAndScoreInScoreRanges(key string, ranges []ScoreRange) (members []string, scores []float64) {
+	s := c.keyspace.Shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	valueItem, exists := getUnsafe(s, key)
 	if !exists {
-		return nil, false
+		return members, scores
 	}
+	for _, r := range ranges {
+		rangeMembers, rangeScores := valueItem.GetMembersAndScoreInScoreRange(r)
+		members = append(members, rangeMembers...)
+		scores = append(scores, rangeScores...)
+	}
+	return members, scores
+}
 
-	// To improve accuracy of EXPIRE, in case time.AfterFunc runs later and a get call is made earlier
-	if valueItem.shouldExpire && time.Now().Sub(valueItem.setAt) > valueItem.expireAfter {
-		return nil, false
+// Replaces the set at key with members, removing its timeout. An empty
+// members removes the key.
+func (c *ConcurrentSortedsetMap) Replace(key string, members []string, scores []float64) {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	if len(members) == 0 {
+		s.Delete(key)
+		return
+	}
+	sortedset := CreateSortedset(c.skiplistOptions...)
+	for i, member := range members {
+		if sortedset.Add(member, scores[i]) == 0 {
+			sortedset.UpdateScore(member, scores[i])
+		}
+	}
+	s.Set(key, sortedset)
+}
+
+// Keys which exist, in no particular order
+func (c *ConcurrentSortedsetMap) Keys() []string {
+	return c.keyspace.Keys(IsSortedset)
+}
+
+// Whether value, read from a keyspace, is a sorted set
+func IsSortedset(value interface{}) bool {
+	_, isSortedset := value.(*Sortedset)
+	return isSortedset
+}
+
+// Number of members of a sorted set, 0 if there is none
+func (c *ConcurrentSortedsetMap) Card(key string) uint64 {
+	s := c.keyspace.Shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	valueItem, exists := getUnsafe(s, key)
+	if !exists {
+		return 0
 	}
-	return valueItem, exists
+	return valueItem.Length()
 }
 
 func (c *ConcurrentSortedsetMap) Expire(key string, timeoutSeconds int) int {
-	c.mutex.Lock()
-	if val, ok := c.GetUnsafe(key); !ok {
-		_ = val
+	return c.ExpireAt(key, time.Now().Add(time.Duration(timeoutSeconds)*time.Second))
+}
+
+// Expire key at an absolute deadline. A deadline in the past removes the key right away.
+func (c *ConcurrentSortedsetMap) ExpireAt(key string, deadline time.Time) int {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	if _, exists := getUnsafe(s, key); !exists || !s.ExpireAt(key, deadline) {
 		return 0
 	}
-	c.data[key].shouldExpire = true
-	c.data[key].expireAfter = time.Duration(timeoutSeconds) * time.Second
-	c.mutex.Unlock()
-	time.AfterFunc(time.Duration(timeoutSeconds)*time.Second, func() {
-		c.mutex.Lock()
+	return 1
+}
 
-		delete(c.data, key)
-		c.mutex.Unlock()
-	})
+// Remove timeout of key. Returns 1 if a timeout was removed
+func (c *ConcurrentSortedsetMap) Persist(key string) int {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	if _, exists := getUnsafe(s, key); !exists || !s.Persist(key) {
+		return 0
+	}
 	return 1
 }
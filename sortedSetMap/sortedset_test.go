@@ -1,18 +1,20 @@
 package sortedSetMap
 
 import (
+	"strconv"
+	"sync/atomic"
 	"testing"
 )
 
 func TestNonExistingKeyMemberRank(t *testing.T) {
-	zset := Create()
+	zset := Create(WithRandomSeed(1))
 	if _, exists := zset.GetRank("NonExistingKey", "member"); exists {
 		t.Errorf("Should not fetch non existent key.")
 	}
 }
 
 func TestNonAddOfDuplicateMember(t *testing.T) {
-	zset := Create()
+	zset := Create(WithRandomSeed(1))
 	zset.Add("zset", "member1", 0.5)
 	if zset.Add("zset", "member1", 0.5) != 0 {
 		t.Errorf("Should not be able to add duplicate Member member1")
@@ -23,7 +25,7 @@ func TestNonAddOfDuplicateMember(t *testing.T) {
 }
 
 func TestExistingKeyMemberRank(t *testing.T) {
-	zset := Create()
+	zset := Create(WithRandomSeed(1))
 	members := [5]string{
 		"member1",
 		"member2",
@@ -51,7 +53,7 @@ func TestExistingKeyMemberRank(t *testing.T) {
 }
 
 func TestExistingKeyRange(t *testing.T) {
-	zset := Create()
+	zset := Create(WithRandomSeed(1))
 	zset.Add("zset", "member1", 0.5)
 	zset.Add("zset", "member2", 1.3)
 	zset.Add("zset", "member3", 2.1)
@@ -131,7 +133,7 @@ func TestExistingKeyRange(t *testing.T) {
 }
 
 func TestExistingKeyNegativeRange(t *testing.T) {
-	zset := Create()
+	zset := Create(WithRandomSeed(1))
 	zset.Add("zset", "member1", 0.5)
 	zset.Add("zset", "member2", 1.3)
 	zset.Add("zset", "member3", 2.1)
@@ -187,3 +189,97 @@ func TestExistingKeyNegativeRange(t *testing.T) {
 		t.Errorf("Member should be member4 with score 4.0 but got %v, with score %v.", members[0], scores[0])
 	}
 }
+
+// ZADD on one hot set while other goroutines rank members of different sets.
+// Run with `go test -bench Parallel -cpu 1,2,4,8 ./sortedSetMap` to compare
+// throughput across GOMAXPROCS values.
+func BenchmarkParallelAddAndRank(b *testing.B) {
+	zset := Create(WithRandomSeed(1))
+	keys := make([]string, 256)
+	for i := range keys {
+		keys[i] = "zset" + strconv.Itoa(i)
+		for j := 0; j < 100; j++ {
+			zset.Add(keys[i], "member"+strconv.Itoa(j), float64(j))
+		}
+	}
+	var worker int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		id := atomic.AddInt64(&worker, 1)
+		i := 0
+		for pb.Next() {
+			if id == 1 {
+				zset.Add("hot", "member"+strconv.Itoa(i%1000), float64(i))
+			} else {
+				zset.GetRank(keys[i%len(keys)], "member"+strconv.Itoa(i%100))
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkParallelAdd(b *testing.B) {
+	zset := Create(WithRandomSeed(1))
+	keys := make([]string, 256)
+	for i := range keys {
+		keys[i] = "zset" + strconv.Itoa(i)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			zset.Add(keys[i%len(keys)], "member"+strconv.Itoa(i%1000), float64(i))
+			i++
+		}
+	})
+}
+
+func TestAddOrUpdateMovesExistingMembers(t *testing.T) {
+	zset := Create(WithRandomSeed(1))
+	added, updated := zset.AddOrUpdate("zset", []string{"a", "b"}, []float64{1, 2}, "")
+	if added != 2 || updated != 0 {
+		t.Errorf("Expected 2 added got %v added %v updated", added, updated)
+	}
+	added, updated = zset.AddOrUpdate("zset", []string{"a", "c"}, []float64{3, 0}, "XX")
+	if added != 0 || updated != 1 {
+		t.Errorf("XX should only move a, got %v added %v updated", added, updated)
+	}
+	added, updated = zset.AddOrUpdate("zset", []string{"a", "c"}, []float64{5, 0}, "NX")
+	if added != 1 || updated != 0 {
+		t.Errorf("NX should only add c, got %v added %v updated", added, updated)
+	}
+	members, scores := zset.GetMembersAndScoreInRange("zset", 0, -1)
+	if len(members) != 3 || members[0] != "c" || members[1] != "b" || members[2] != "a" || scores[2] != 3 {
+		t.Errorf("Unexpected members %v scores %v", members, scores)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	zset := Create(WithRandomSeed(1))
+	zset.Add("zset", "old", 1)
+	zset.Replace("zset", []string{"x", "y"}, []float64{2, 1})
+	members, _ := zset.GetMembersAndScoreInRange("zset", 0, -1)
+	if len(members) != 2 || members[0] != "y" || members[1] != "x" {
+		t.Errorf("Unexpected members %v", members)
+	}
+	zset.Replace("zset", nil, nil)
+	if keys := zset.Keys(); len(keys) != 0 {
+		t.Errorf("Replacing with no members should remove the key")
+	}
+}
+
+func TestKeysAndCard(t *testing.T) {
+	zset := Create(WithRandomSeed(1))
+	zset.Add("board", "alice", 1)
+	zset.Add("board", "bob", 2)
+	zset.Add("other", "carol", 3)
+	if card := zset.Card("board"); card != 2 {
+		t.Errorf("Expected 2 members but got %v", card)
+	}
+	if card := zset.Card("missing"); card != 0 {
+		t.Errorf("Expected 0 members of a missing key but got %v", card)
+	}
+	if keys := zset.Keys(); len(keys) != 2 {
+		t.Errorf("Expected 2 keys but got %v", keys)
+	}
+}
@@ -0,0 +1,365 @@
+package streamMap
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+var ErrIDZero = errors.New("ERR The ID specified in XADD must be greater than 0-0")
+var ErrIDTooSmall = errors.New("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+var ErrStreamExhausted = errors.New("ERR The stream has exhausted the last possible ID, unable to add more items")
+
+// Field value pairs added to a stream under one ID
+type Entry struct {
+	ID     StreamID
+	Fields []string // field, value, field, value ...
+}
+
+// Delivered but not yet acknowledged entry of a consumer group
+type PendingEntry struct {
+	ID            StreamID
+	Consumer      string
+	DeliveryTime  time.Time
+	DeliveryCount uint64
+}
+
+type ConsumerGroup struct {
+	lastDelivered StreamID
+	pending       map[StreamID]*PendingEntry
+	consumers     map[string]time.Time // name to when it last read or claimed
+}
+
+// Entries ordered by ID, which only ever grows, and the consumer groups reading them
+type Stream struct {
+	entries []Entry
+	lastID  StreamID
+	groups  map[string]*ConsumerGroup
+}
+
+func CreateStream() *Stream {
+	return &Stream{groups: make(map[string]*ConsumerGroup)}
+}
+
+func (s *Stream) Length() int {
+	return len(s.entries)
+}
+
+func (s *Stream) LastID() StreamID {
+	return s.lastID
+}
+
+// Index of the first entry with an ID of at least id
+func (s *Stream) search(id StreamID) int {
+	return sort.Search(len(s.entries), func(i int) bool {
+		return !s.entries[i].ID.Less(id)
+	})
+}
+
+func (s *Stream) get(id StreamID) (Entry, bool) {
+	i := s.search(id)
+	if i < len(s.entries) && s.entries[i].ID == id {
+		return s.entries[i], true
+	}
+	return Entry{}, false
+}
+
+// Works out the ID of a new entry from the clock and the last ID
+func (s *Stream) nextID(addID AddID, now time.Time) (StreamID, error) {
+	if addID.AutoMs {
+		ms := uint64(now.UnixNano() / int64(time.Millisecond))
+		if ms > s.lastID.Ms {
+			return StreamID{ms, 0}, nil
+		}
+		next, ok := s.lastID.Next()
+		if !ok {
+			return next, ErrStreamExhausted
+		}
+		return next, nil
+	}
+	id := addID.ID
+	if addID.AutoSeq {
+		if id.Ms == s.lastID.Ms {
+			next, ok := s.lastID.Next()
+			if !ok || next.Ms != id.Ms {
+				return id, ErrIDTooSmall
+			}
+			return next, nil
+		}
+	}
+	if id == MinID {
+		return id, ErrIDZero
+	}
+	if !s.lastID.Less(id) {
+		return id, ErrIDTooSmall
+	}
+	return id, nil
+}
+
+// Appends an entry and returns its ID
+func (s *Stream) Add(addID AddID, fields []string, now time.Time) (StreamID, error) {
+	id, err := s.nextID(addID, now)
+	if err != nil {
+		return id, err
+	}
+	s.entries = append(s.entries, Entry{ID: id, Fields: fields})
+	s.lastID = id
+	return id, nil
+}
+
+// How XADD and XTRIM evict old entries. Strategy is MAXLEN, MINID or "" for none.
+// Limit caps the entries evicted at once, 0 meaning no cap.
+type TrimOptions struct {
+	Strategy string
+	MaxLen   int64
+	MinID    StreamID
+	Limit    int64
+}
+
+// Evicts the oldest entries and returns how many were removed.
+// Trimming is always exact, "~" is accepted but treated like "=".
+func (s *Stream) Trim(options TrimOptions) int64 {
+	removed := 0
+	switch options.Strategy {
+	case "MAXLEN":
+		if int64(len(s.entries)) > options.MaxLen {
+			removed = len(s.entries) - int(options.MaxLen)
+		}
+	case "MINID":
+		removed = s.search(options.MinID)
+	}
+	if options.Limit > 0 && int64(removed) > options.Limit {
+		removed = int(options.Limit)
+	}
+	s.entries = append([]Entry(nil), s.entries[removed:]...)
+	return int64(removed)
+}
+
+// Removes entries by ID and returns how many existed
+func (s *Stream) Delete(ids []StreamID) int {
+	deleted := 0
+	for _, id := range ids {
+		i := s.search(id)
+		if i < len(s.entries) && s.entries[i].ID == id {
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// Entries with IDs from start to end inclusive, newest first if reverse.
+// count limits the entries returned, 0 meaning all.
+func (s *Stream) Range(start StreamID, end StreamID, count int, reverse bool) []Entry {
+	entries := []Entry{}
+	if end.Less(start) {
+		return entries
+	}
+	first, last := s.search(start), s.search(end)
+	if last < len(s.entries) && s.entries[last].ID == end {
+		last++
+	}
+	for i := first; i < last; i++ {
+		if count > 0 && len(entries) == count {
+			break
+		}
+		if reverse {
+			entries = append(entries, s.entries[last-1-(i-first)])
+		} else {
+			entries = append(entries, s.entries[i])
+		}
+	}
+	return entries
+}
+
+// Entries with an ID greater than after, like XREAD
+func (s *Stream) After(after StreamID, count int) []Entry {
+	start, ok := after.Next()
+	if !ok {
+		return []Entry{}
+	}
+	return s.Range(start, MaxID, count, false)
+}
+
+// Creates a group which delivers entries after lastDelivered. Returns false if it exists.
+func (s *Stream) CreateGroup(name string, lastDelivered StreamID) bool {
+	if _, exists := s.groups[name]; exists {
+		return false
+	}
+	s.groups[name] = &ConsumerGroup{
+		lastDelivered: lastDelivered,
+		pending:       make(map[StreamID]*PendingEntry),
+		consumers:     make(map[string]time.Time),
+	}
+	return true
+}
+
+// Creates consumer in group. Returns false if it exists.
+func (g *ConsumerGroup) createConsumer(consumer string, now time.Time) bool {
+	if _, exists := g.consumers[consumer]; exists {
+		g.consumers[consumer] = now
+		return false
+	}
+	g.consumers[consumer] = now
+	return true
+}
+
+// Removes consumer and its pending entries, returning how many it had
+func (g *ConsumerGroup) deleteConsumer(consumer string) int {
+	pending := 0
+	for id, entry := range g.pending {
+		if entry.Consumer == consumer {
+			delete(g.pending, id)
+			pending++
+		}
+	}
+	delete(g.consumers, consumer)
+	return pending
+}
+
+// Pending entries ordered by ID
+func (g *ConsumerGroup) sortedPending() []*PendingEntry {
+	entries := make([]*PendingEntry, 0, len(g.pending))
+	for _, entry := range g.pending {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID.Less(entries[j].ID)
+	})
+	return entries
+}
+
+// Delivers entries after the group's last delivered ID to consumer, adding
+// them to the pending entries unless noAck
+func (s *Stream) readNew(g *ConsumerGroup, consumer string, count int, noAck bool, now time.Time) []Entry {
+	entries := s.After(g.lastDelivered, count)
+	for _, entry := range entries {
+		g.lastDelivered = entry.ID
+		if noAck {
+			continue
+		}
+		g.pending[entry.ID] = &PendingEntry{
+			ID:            entry.ID,
+			Consumer:      consumer,
+			DeliveryTime:  now,
+			DeliveryCount: 1,
+		}
+	}
+	return entries
+}
+
+// Entries pending for consumer with IDs greater than after. Entries deleted
+// from the stream since are returned with nil Fields.
+func (s *Stream) readHistory(g *ConsumerGroup, consumer string, after StreamID, count int) []Entry {
+	entries := []Entry{}
+	for _, pending := range g.sortedPending() {
+		if count > 0 && len(entries) == count {
+			break
+		}
+		if pending.Consumer != consumer || !after.Less(pending.ID) {
+			continue
+		}
+		entry, exists := s.get(pending.ID)
+		if !exists {
+			entry = Entry{ID: pending.ID}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// Changes how XCLAIM updates the pending entries it claims
+type ClaimOptions struct {
+	Idle       time.Duration // sets idle time instead of resetting it when not negative
+	Time       time.Time     // sets delivery time when not zero
+	RetryCount int64         // sets delivery count when not negative
+	Force      bool          // claims entries which aren't pending for anyone
+	JustID     bool          // doesn't increment delivery count
+	LastID     StreamID      // raises the group's last delivered ID
+}
+
+// Entry claimed by XCLAIM or XAUTOCLAIM with its updated pending state
+type Claimed struct {
+	Entry   Entry
+	Pending PendingEntry
+}
+
+// Hands pending entry over to consumer and updates its delivery time and count
+func (g *ConsumerGroup) claim(pending *PendingEntry, consumer string, deliveryTime time.Time, retryCount int64, justID bool) {
+	pending.Consumer = consumer
+	pending.DeliveryTime = deliveryTime
+	if retryCount >= 0 {
+		pending.DeliveryCount = uint64(retryCount)
+	} else if !justID {
+		pending.DeliveryCount++
+	}
+}
+
+// Claims entries in ids idle for at least minIdle, like XCLAIM. Pending
+// entries deleted from the stream are dropped and returned as deleted.
+func (s *Stream) claim(g *ConsumerGroup, consumer string, minIdle time.Duration, ids []StreamID, options ClaimOptions, now time.Time) (claimed []Claimed, deleted []StreamID) {
+	g.createConsumer(consumer, now)
+	if g.lastDelivered.Less(options.LastID) {
+		g.lastDelivered = options.LastID
+	}
+	deliveryTime := now
+	if options.Idle >= 0 {
+		deliveryTime = now.Add(-options.Idle)
+	} else if !options.Time.IsZero() {
+		deliveryTime = options.Time
+	}
+	for _, id := range ids {
+		entry, inStream := s.get(id)
+		pending, isPending := g.pending[id]
+		if !isPending {
+			if !options.Force || !inStream {
+				continue
+			}
+			pending = &PendingEntry{ID: id, DeliveryTime: now}
+			g.pending[id] = pending
+		}
+		if !inStream {
+			delete(g.pending, id)
+			deleted = append(deleted, id)
+			continue
+		}
+		if isPending && now.Sub(pending.DeliveryTime) < minIdle {
+			continue
+		}
+		g.claim(pending, consumer, deliveryTime, options.RetryCount, options.JustID)
+		claimed = append(claimed, Claimed{Entry: entry, Pending: *pending})
+	}
+	return claimed, deleted
+}
+
+// Claims up to count pending entries idle for at least minIdle with IDs from
+// start, like XAUTOCLAIM. next is where to continue from, 0-0 once all pending
+// entries were scanned.
+func (s *Stream) autoClaim(g *ConsumerGroup, consumer string, minIdle time.Duration, start StreamID, count int, justID bool, now time.Time) (next StreamID, claimed []Claimed, deleted []StreamID) {
+	g.createConsumer(consumer, now)
+	// like redis scan at most 10 entries per one requested so a call stays cheap
+	attempts := count * 10
+	pendingEntries := g.sortedPending()
+	i := sort.Search(len(pendingEntries), func(i int) bool {
+		return !pendingEntries[i].ID.Less(start)
+	})
+	for ; i < len(pendingEntries) && attempts > 0 && len(claimed) < count; i++ {
+		attempts--
+		pending := pendingEntries[i]
+		entry, inStream := s.get(pending.ID)
+		if !inStream {
+			delete(g.pending, pending.ID)
+			deleted = append(deleted, pending.ID)
+			continue
+		}
+		if now.Sub(pending.DeliveryTime) < minIdle {
+			continue
+		}
+		g.claim(pending, consumer, now, -1, justID)
+		claimed = append(claimed, Claimed{Entry: entry, Pending: *pending})
+	}
+	if i < len(pendingEntries) {
+		return pendingEntries[i].ID, claimed, deleted
+	}
+	return MinID, claimed, deleted
+}
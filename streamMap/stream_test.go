@@ -0,0 +1,135 @@
+package streamMap
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func ids(entries []Entry) []StreamID {
+	result := []StreamID{}
+	for _, entry := range entries {
+		result = append(result, entry.ID)
+	}
+	return result
+}
+
+func TestStreamAddGeneratesIDs(t *testing.T) {
+	stream := CreateStream()
+	now := time.Unix(0, 5*int64(time.Millisecond))
+	cases := []struct {
+		addID    string
+		expected StreamID
+		err      error
+	}{
+		{"0-0", MinID, ErrIDZero},
+		{"0-*", StreamID{0, 1}, nil},
+		{"*", StreamID{5, 0}, nil},
+		{"*", StreamID{5, 1}, nil},
+		{"5-1", StreamID{5, 1}, ErrIDTooSmall},
+		{"5-*", StreamID{5, 2}, nil},
+		{"4-*", StreamID{4, 0}, ErrIDTooSmall},
+		{"9-0", StreamID{9, 0}, nil},
+		{"*", StreamID{9, 1}, nil},
+	}
+	for _, c := range cases {
+		addID, _ := ParseAddID(c.addID)
+		id, err := stream.Add(addID, []string{"f", "v"}, now)
+		if err != c.err || (err == nil && id != c.expected) {
+			t.Errorf("Adding %v expected %v %v but got %v %v", c.addID, c.expected, c.err, id, err)
+		}
+	}
+	if stream.Length() != 6 || stream.LastID() != (StreamID{9, 1}) {
+		t.Errorf("Expected 6 entries up to 9-1 but got %v up to %v", stream.Length(), stream.LastID())
+	}
+
+	exhausted := CreateStream()
+	exhausted.Add(AddID{ID: MaxID}, []string{"f", "v"}, now)
+	if _, err := exhausted.Add(AddID{AutoMs: true, AutoSeq: true}, []string{"f", "v"}, now); err != ErrStreamExhausted {
+		t.Errorf("Expected exhausted stream but got %v", err)
+	}
+}
+
+func TestStreamRangeTrimDelete(t *testing.T) {
+	stream := CreateStream()
+	for ms := uint64(1); ms <= 6; ms++ {
+		stream.Add(AddID{ID: StreamID{ms, 0}}, []string{"n", "v"}, time.Now())
+	}
+	if got := ids(stream.Range(StreamID{2, 0}, StreamID{4, 0}, 0, false)); !reflect.DeepEqual(got, []StreamID{{2, 0}, {3, 0}, {4, 0}}) {
+		t.Errorf("Unexpected range %v", got)
+	}
+	if got := ids(stream.Range(MinID, MaxID, 2, true)); !reflect.DeepEqual(got, []StreamID{{6, 0}, {5, 0}}) {
+		t.Errorf("Unexpected reverse range %v", got)
+	}
+	if got := ids(stream.After(StreamID{5, 0}, 0)); !reflect.DeepEqual(got, []StreamID{{6, 0}}) {
+		t.Errorf("Unexpected entries after 5-0 %v", got)
+	}
+	if deleted := stream.Delete([]StreamID{{3, 0}, {3, 0}, {7, 0}}); deleted != 1 {
+		t.Errorf("Expected 1 deleted but got %v", deleted)
+	}
+	if removed := stream.Trim(TrimOptions{Strategy: "MAXLEN", MaxLen: 2, Limit: 1}); removed != 1 {
+		t.Errorf("LIMIT should cap trimming at 1 but removed %v", removed)
+	}
+	if removed := stream.Trim(TrimOptions{Strategy: "MINID", MinID: StreamID{5, 0}}); removed != 2 {
+		t.Errorf("Expected 2 removed below 5-0 but got %v", removed)
+	}
+	if got := ids(stream.Range(MinID, MaxID, 0, false)); !reflect.DeepEqual(got, []StreamID{{5, 0}, {6, 0}}) {
+		t.Errorf("Unexpected entries after trim %v", got)
+	}
+	if stream.LastID() != (StreamID{6, 0}) {
+		t.Errorf("Trimming must not change the last ID")
+	}
+}
+
+func TestStreamGroupClaims(t *testing.T) {
+	stream := CreateStream()
+	start := time.Now()
+	for ms := uint64(1); ms <= 4; ms++ {
+		stream.Add(AddID{ID: StreamID{ms, 0}}, []string{"n", "v"}, start)
+	}
+	stream.CreateGroup("g", MinID)
+	if stream.CreateGroup("g", MinID) {
+		t.Errorf("Creating an existing group should fail")
+	}
+	group := stream.groups["g"]
+	if got := ids(stream.readNew(group, "alice", 3, false, start)); len(got) != 3 {
+		t.Errorf("Expected 3 new entries but got %v", got)
+	}
+	if got := ids(stream.readHistory(group, "alice", StreamID{1, 0}, 0)); !reflect.DeepEqual(got, []StreamID{{2, 0}, {3, 0}}) {
+		t.Errorf("Unexpected history %v", got)
+	}
+	stream.Delete([]StreamID{{2, 0}})
+	if history := stream.readHistory(group, "alice", MinID, 0); history[1].Fields != nil {
+		t.Errorf("Deleted pending entry should have nil fields")
+	}
+
+	later := start.Add(time.Second)
+	claimed, deleted := stream.claim(group, "bob", 500*time.Millisecond, []StreamID{{1, 0}, {2, 0}, {4, 0}},
+		ClaimOptions{Idle: -1, RetryCount: -1}, later)
+	if len(claimed) != 1 || claimed[0].Pending.Consumer != "bob" || claimed[0].Pending.DeliveryCount != 2 {
+		t.Errorf("Expected bob to claim 1-0 a second time but got %v", claimed)
+	}
+	if !reflect.DeepEqual(deleted, []StreamID{{2, 0}}) {
+		t.Errorf("Expected deleted 2-0 but got %v", deleted)
+	}
+	claimed, _ = stream.claim(group, "bob", 0, []StreamID{{4, 0}}, ClaimOptions{Idle: -1, RetryCount: 5, Force: true}, later)
+	if len(claimed) != 1 || claimed[0].Pending.DeliveryCount != 5 {
+		t.Errorf("FORCE should claim undelivered 4-0 with RETRYCOUNT but got %v", claimed)
+	}
+
+	// 1-0 and 4-0 were just claimed by bob so only 3-0 is idle long enough
+	next, claimed, _ := stream.autoClaim(group, "carol", 500*time.Millisecond, MinID, 1, true, later)
+	if next != (StreamID{4, 0}) || len(claimed) != 1 || claimed[0].Entry.ID != (StreamID{3, 0}) {
+		t.Errorf("Expected carol to claim 3-0 and continue at 4-0 but got %v %v", next, claimed)
+	}
+	if claimed[0].Pending.DeliveryCount != 1 {
+		t.Errorf("JUSTID must not increment the delivery count")
+	}
+	next, claimed, _ = stream.autoClaim(group, "carol", 500*time.Millisecond, next, 10, false, later.Add(time.Second))
+	if next != MinID || len(claimed) != 1 || claimed[0].Entry.ID != (StreamID{4, 0}) {
+		t.Errorf("Expected carol to claim 4-0 and finish but got %v %v", next, claimed)
+	}
+	if pending := group.deleteConsumer("carol"); pending != 2 {
+		t.Errorf("Expected carol to have 2 pending entries but got %v", pending)
+	}
+}
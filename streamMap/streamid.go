@@ -0,0 +1,116 @@
+package streamMap
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Identifies a stream entry by the millisecond time it was added and a
+// sequence number for entries added within the same millisecond
+type StreamID struct {
+	Ms  uint64
+	Seq uint64
+}
+
+var MinID = StreamID{0, 0}
+var MaxID = StreamID{math.MaxUint64, math.MaxUint64}
+
+func (id StreamID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+func (id StreamID) Less(other StreamID) bool {
+	return id.Ms < other.Ms || (id.Ms == other.Ms && id.Seq < other.Seq)
+}
+
+// Smallest ID greater than id. ok is false if id is MaxID.
+func (id StreamID) Next() (next StreamID, ok bool) {
+	if id.Seq < math.MaxUint64 {
+		return StreamID{id.Ms, id.Seq + 1}, true
+	}
+	if id.Ms < math.MaxUint64 {
+		return StreamID{id.Ms + 1, 0}, true
+	}
+	return id, false
+}
+
+// Largest ID smaller than id. ok is false if id is MinID.
+func (id StreamID) Prev() (prev StreamID, ok bool) {
+	if id.Seq > 0 {
+		return StreamID{id.Ms, id.Seq - 1}, true
+	}
+	if id.Ms > 0 {
+		return StreamID{id.Ms - 1, math.MaxUint64}, true
+	}
+	return id, false
+}
+
+// Parses "ms-seq", or just "ms" in which case the sequence is missingSeq
+func ParseID(text string, missingSeq uint64) (StreamID, bool) {
+	msPart, seqPart := text, ""
+	hasSeq := false
+	if dash := strings.IndexByte(text, '-'); dash >= 0 {
+		msPart, seqPart, hasSeq = text[:dash], text[dash+1:], true
+	}
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return StreamID{}, false
+	}
+	if !hasSeq {
+		return StreamID{ms, missingSeq}, true
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return StreamID{}, false
+	}
+	return StreamID{ms, seq}, true
+}
+
+// Parses a bound of XRANGE: "-", "+", an ID, or an ID prefixed with "(" to
+// exclude it. A start missing its sequence begins at ms-0 and an end at the
+// last possible sequence of ms. ok is false for invalid or empty bounds.
+func ParseRangeBound(text string, isStart bool) (StreamID, bool) {
+	if text == "-" {
+		return MinID, true
+	}
+	if text == "+" {
+		return MaxID, true
+	}
+	exclusive := strings.HasPrefix(text, "(")
+	if exclusive {
+		text = text[1:]
+	}
+	var missingSeq uint64 = math.MaxUint64
+	if isStart {
+		missingSeq = 0
+	}
+	id, ok := ParseID(text, missingSeq)
+	if !ok || !exclusive {
+		return id, ok
+	}
+	if isStart {
+		return id.Next()
+	}
+	return id.Prev()
+}
+
+// XADD id which is either explicit, "*" to generate it from the clock, or
+// "ms-*" to generate only the sequence
+type AddID struct {
+	ID      StreamID
+	AutoMs  bool
+	AutoSeq bool
+}
+
+func ParseAddID(text string) (AddID, bool) {
+	if text == "*" {
+		return AddID{AutoMs: true, AutoSeq: true}, true
+	}
+	if strings.HasSuffix(text, "-*") {
+		ms, err := strconv.ParseUint(text[:len(text)-2], 10, 64)
+		return AddID{ID: StreamID{ms, 0}, AutoSeq: true}, err == nil
+	}
+	id, ok := ParseID(text, 0)
+	return AddID{ID: id}, ok
+}
@@ -0,0 +1,65 @@
+package streamMap
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseID(t *testing.T) {
+	cases := []struct {
+		text     string
+		expected StreamID
+		ok       bool
+	}{
+		{"5-3", StreamID{5, 3}, true},
+		{"5", StreamID{5, 7}, true},
+		{"18446744073709551615-18446744073709551615", MaxID, true},
+		{"5-", StreamID{}, false},
+		{"-3", StreamID{}, false},
+		{"a-1", StreamID{}, false},
+		{"-1-1", StreamID{}, false},
+	}
+	for _, c := range cases {
+		if id, ok := ParseID(c.text, 7); id != c.expected || ok != c.ok {
+			t.Errorf("ParseID %v expected %v %v but got %v %v", c.text, c.expected, c.ok, id, ok)
+		}
+	}
+}
+
+func TestParseRangeBound(t *testing.T) {
+	cases := []struct {
+		text     string
+		isStart  bool
+		expected StreamID
+		ok       bool
+	}{
+		{"-", true, MinID, true},
+		{"+", false, MaxID, true},
+		{"5", true, StreamID{5, 0}, true},
+		{"5", false, StreamID{5, math.MaxUint64}, true},
+		{"(5-1", true, StreamID{5, 2}, true},
+		{"(5-0", false, StreamID{4, math.MaxUint64}, true},
+		{"(0-0", false, MinID, false},
+		{"(", true, StreamID{}, false},
+	}
+	for _, c := range cases {
+		if id, ok := ParseRangeBound(c.text, c.isStart); id != c.expected || ok != c.ok {
+			t.Errorf("ParseRangeBound %v expected %v %v but got %v %v", c.text, c.expected, c.ok, id, ok)
+		}
+	}
+}
+
+func TestParseAddID(t *testing.T) {
+	if addID, ok := ParseAddID("*"); !ok || !addID.AutoMs || !addID.AutoSeq {
+		t.Errorf("* should generate the whole ID")
+	}
+	if addID, ok := ParseAddID("7-*"); !ok || addID.AutoMs || !addID.AutoSeq || addID.ID.Ms != 7 {
+		t.Errorf("7-* should generate the sequence only but got %v", addID)
+	}
+	if addID, ok := ParseAddID("7-2"); !ok || addID.AutoSeq || addID.ID != (StreamID{7, 2}) {
+		t.Errorf("7-2 should be explicit but got %v", addID)
+	}
+	if _, ok := ParseAddID("x-*"); ok {
+		t.Errorf("x-* should be invalid")
+	}
+}
@@ -0,0 +1,525 @@
+package streamMap
+
+import (
+	"errors"
+	"github.com/thedeveloperr/redis-clone/keyspace"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrNoStream = errors.New("ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+var ErrBusyGroup = errors.New("BUSYGROUP Consumer Group name already exists")
+
+func noGroupError(key string, group string) error {
+	return errors.New("NOGROUP No such key '" + key + "' or consumer group '" + group + "'")
+}
+
+type ConcurrentStreamMap struct {
+	keyspace *keyspace.Keyspace
+
+	// Clients blocked in XREAD like calls, woken up when a key they wait on is added to
+	waitersMutex sync.Mutex
+	waiters      map[string][]chan bool
+}
+
+// Streams stored in a keyspace of their own
+func Create() *ConcurrentStreamMap {
+	return CreateInKeyspace(keyspace.New())
+}
+
+// Streams stored in keyspace, along with the values of other types
+func CreateInKeyspace(keyspace *keyspace.Keyspace) *ConcurrentStreamMap {
+	return &ConcurrentStreamMap{
+		keyspace: keyspace,
+		waiters:  make(map[string][]chan bool),
+	}
+}
+
+// Keys which exist, in no particular order
+func (c *ConcurrentStreamMap) Keys() []string {
+	return c.keyspace.Keys(IsStream)
+}
+
+// Whether value, read from a keyspace, is a stream
+func IsStream(value interface{}) bool {
+	_, isStream := value.(*Stream)
+	return isStream
+}
+
+// Caller must hold the lock of the shard. Unlike other types an empty stream
+// keeps existing, along with its last ID and consumer groups.
+func getUnsafe(s *keyspace.Shard, key string) (*Stream, bool) {
+	entry, exists := s.Get(key)
+	if !exists {
+		return nil, false
+	}
+	stream, isStream := entry.Value.(*Stream)
+	return stream, isStream
+}
+
+// Caller must hold the lock of the shard
+func getGroupUnsafe(s *keyspace.Shard, key string, group string) (*Stream, *ConsumerGroup, error) {
+	stream, exists := getUnsafe(s, key)
+	if !exists {
+		return nil, nil, noGroupError(key, group)
+	}
+	consumerGroup, exists := stream.groups[group]
+	if !exists {
+		return nil, nil, noGroupError(key, group)
+	}
+	return stream, consumerGroup, nil
+}
+
+// Appends an entry, creating the stream unless noMkStream, then trims it.
+// exists is false if the stream is missing and noMkStream is set.
+func (c *ConcurrentStreamMap) Add(key string, addID AddID, fields []string, noMkStream bool, trim TrimOptions) (id StreamID, exists bool, err error) {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	stream, exists := getUnsafe(s, key)
+	if !exists {
+		if noMkStream {
+			s.Unlock()
+			return id, false, nil
+		}
+		stream = CreateStream()
+	}
+	id, err = stream.Add(addID, fields, time.Now())
+	if err == nil {
+		if !exists {
+			s.Set(key, stream)
+		}
+		stream.Trim(trim)
+	}
+	s.Unlock()
+	if err == nil {
+		c.wakeWaiters(key)
+	}
+	return id, true, err
+}
+
+// Evicts old entries and returns how many were removed
+func (c *ConcurrentStreamMap) Trim(key string, trim TrimOptions) int64 {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	if stream, exists := getUnsafe(s, key); exists {
+		return stream.Trim(trim)
+	}
+	return 0
+}
+
+func (c *ConcurrentStreamMap) Delete(key string, ids []StreamID) int {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	if stream, exists := getUnsafe(s, key); exists {
+		return stream.Delete(ids)
+	}
+	return 0
+}
+
+// Whether key holds a stream, which can be empty
+func (c *ConcurrentStreamMap) Exists(key string) bool {
+	s := c.keyspace.Shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	_, exists := getUnsafe(s, key)
+	return exists
+}
+
+func (c *ConcurrentStreamMap) Len(key string) int {
+	s := c.keyspace.Shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	if stream, exists := getUnsafe(s, key); exists {
+		return stream.Length()
+	}
+	return 0
+}
+
+// ID of the last entry ever added, 0-0 for missing streams
+func (c *ConcurrentStreamMap) LastID(key string) StreamID {
+	s := c.keyspace.Shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	if stream, exists := getUnsafe(s, key); exists {
+		return stream.LastID()
+	}
+	return MinID
+}
+
+// Entries with IDs from start to end inclusive, see Stream.Range
+func (c *ConcurrentStreamMap) Range(key string, start StreamID, end StreamID, count int, reverse bool) []Entry {
+	s := c.keyspace.Shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	if stream, exists := getUnsafe(s, key); exists {
+		return stream.Range(start, end, count, reverse)
+	}
+	return []Entry{}
+}
+
+// Entries after the given ID of each stream, like XREAD. Streams without
+// new entries get an empty slice.
+func (c *ConcurrentStreamMap) Read(keys []string, after []StreamID, count int) [][]Entry {
+	results := make([][]Entry, len(keys))
+	for i, key := range keys {
+		s := c.keyspace.Shard(key)
+		s.RLock()
+		if stream, exists := getUnsafe(s, key); exists {
+			results[i] = stream.After(after[i], count)
+		}
+		s.RUnlock()
+	}
+	return results
+}
+
+// Like Read but waits up to timeout, 0 meaning forever, for an entry to be
+// added when there is none. ok is false on timeout.
+func (c *ConcurrentStreamMap) BlockingRead(keys []string, after []StreamID, count int, timeout time.Duration) (results [][]Entry, ok bool) {
+	ok = c.Block(keys, timeout, func() bool {
+		results = c.Read(keys, after, count)
+		return hasEntries(results)
+	})
+	return results, ok
+}
+
+func hasEntries(results [][]Entry) bool {
+	for _, entries := range results {
+		if len(entries) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Creates a consumer group delivering entries after lastDelivered, or only
+// entries added from now on when it is nil ("$"). Returns the ID used. Fails with
+// ErrNoStream if the stream is missing and mkStream isn't set, or ErrBusyGroup.
+func (c *ConcurrentStreamMap) CreateGroup(key string, group string, lastDelivered *StreamID, mkStream bool) (StreamID, error) {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	stream, exists := getUnsafe(s, key)
+	if !exists {
+		if !mkStream {
+			return MinID, ErrNoStream
+		}
+		stream = CreateStream()
+		s.Set(key, stream)
+	}
+	id := stream.LastID()
+	if lastDelivered != nil {
+		id = *lastDelivered
+	}
+	if !stream.CreateGroup(group, id) {
+		return id, ErrBusyGroup
+	}
+	return id, nil
+}
+
+// Changes the last delivered ID of a group, to the stream's last ID when
+// lastDelivered is nil ("$"). Returns the ID used.
+func (c *ConcurrentStreamMap) SetGroupID(key string, group string, lastDelivered *StreamID) (StreamID, error) {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	stream, consumerGroup, err := getGroupUnsafe(s, key, group)
+	if err != nil {
+		return MinID, err
+	}
+	consumerGroup.lastDelivered = stream.LastID()
+	if lastDelivered != nil {
+		consumerGroup.lastDelivered = *lastDelivered
+	}
+	return consumerGroup.lastDelivered, nil
+}
+
+// Removes a group and returns 1 if it existed
+func (c *ConcurrentStreamMap) DestroyGroup(key string, group string) int {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	stream, _, err := getGroupUnsafe(s, key, group)
+	if err != nil {
+		return 0
+	}
+	delete(stream.groups, group)
+	return 1
+}
+
+// Adds consumer to group and returns 1 if it is new
+func (c *ConcurrentStreamMap) CreateConsumer(key string, group string, consumer string) (int, error) {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	_, consumerGroup, err := getGroupUnsafe(s, key, group)
+	if err != nil {
+		return 0, err
+	}
+	if consumerGroup.createConsumer(consumer, time.Now()) {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Removes consumer and returns how many pending entries it had
+func (c *ConcurrentStreamMap) DeleteConsumer(key string, group string, consumer string) (int, error) {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	_, consumerGroup, err := getGroupUnsafe(s, key, group)
+	if err != nil {
+		return 0, err
+	}
+	return consumerGroup.deleteConsumer(consumer), nil
+}
+
+// Entries read by a consumer of a group, with enough state to replay the read
+type GroupRead struct {
+	Entries     []Entry
+	NewConsumer bool      // consumer didn't exist before this read
+	DeliveredAt time.Time // delivery time given to new entries
+}
+
+// Reads for consumer of group, like XREADGROUP. A nil after reads entries
+// never delivered to the group (">"), otherwise entries pending for the
+// consumer with IDs greater than after are read again.
+func (c *ConcurrentStreamMap) ReadGroup(keys []string, group string, consumer string, after []*StreamID, count int, noAck bool) ([]GroupRead, error) {
+	results := make([]GroupRead, len(keys))
+	for i, key := range keys {
+		s := c.keyspace.Shard(key)
+		s.Lock()
+		stream, consumerGroup, err := getGroupUnsafe(s, key, group)
+		if err != nil {
+			s.Unlock()
+			return nil, err
+		}
+		now := time.Now()
+		results[i].NewConsumer = consumerGroup.createConsumer(consumer, now)
+		results[i].DeliveredAt = now
+		if after[i] == nil {
+			results[i].Entries = stream.readNew(consumerGroup, consumer, count, noAck, now)
+		} else {
+			results[i].Entries = stream.readHistory(consumerGroup, consumer, *after[i], count)
+		}
+		s.Unlock()
+	}
+	return results, nil
+}
+
+// Like ReadGroup but waits up to timeout, 0 meaning forever, when reading new
+// entries and there are none. ok is false on timeout.
+func (c *ConcurrentStreamMap) BlockingReadGroup(keys []string, group string, consumer string, after []*StreamID, count int, noAck bool, timeout time.Duration) (results []GroupRead, ok bool, err error) {
+	// only the first try can create the consumer, remember it for the final results
+	newConsumer := make([]bool, len(keys))
+	ok = c.Block(keys, timeout, func() bool {
+		results, err = c.ReadGroup(keys, group, consumer, after, count, noAck)
+		if err != nil {
+			return true
+		}
+		delivered := false
+		for i, result := range results {
+			newConsumer[i] = newConsumer[i] || result.NewConsumer
+			delivered = delivered || len(result.Entries) > 0
+		}
+		return delivered
+	})
+	for i := range results {
+		results[i].NewConsumer = newConsumer[i]
+	}
+	return results, ok, err
+}
+
+// Acknowledges pending entries and returns how many were pending
+func (c *ConcurrentStreamMap) Ack(key string, group string, ids []StreamID) int {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	_, consumerGroup, err := getGroupUnsafe(s, key, group)
+	if err != nil {
+		return 0
+	}
+	acknowledged := 0
+	for _, id := range ids {
+		if _, exists := consumerGroup.pending[id]; exists {
+			delete(consumerGroup.pending, id)
+			acknowledged++
+		}
+	}
+	return acknowledged
+}
+
+// Number of pending entries per consumer, for the summary form of XPENDING
+type ConsumerPending struct {
+	Consumer string
+	Count    int
+}
+
+// Pending entries of a group ordered by ID with the count per consumer
+// ordered by name
+func (c *ConcurrentStreamMap) PendingSummary(key string, group string) (pending []PendingEntry, consumers []ConsumerPending, err error) {
+	pending, err = c.Pending(key, group, 0, MinID, MaxID, 0, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	counts := make(map[string]int)
+	for _, entry := range pending {
+		counts[entry.Consumer]++
+	}
+	for consumer, count := range counts {
+		consumers = append(consumers, ConsumerPending{consumer, count})
+	}
+	sort.Slice(consumers, func(i, j int) bool {
+		return consumers[i].Consumer < consumers[j].Consumer
+	})
+	return pending, consumers, nil
+}
+
+// Pending entries with IDs from start to end idle for at least minIdle,
+// optionally only those of consumer. count limits the entries, 0 meaning all.
+func (c *ConcurrentStreamMap) Pending(key string, group string, minIdle time.Duration, start StreamID, end StreamID, count int, consumer string) ([]PendingEntry, error) {
+	s := c.keyspace.Shard(key)
+	s.RLock()
+	defer s.RUnlock()
+	_, consumerGroup, err := getGroupUnsafe(s, key, group)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	pending := []PendingEntry{}
+	for _, entry := range consumerGroup.sortedPending() {
+		if count > 0 && len(pending) == count {
+			break
+		}
+		if entry.ID.Less(start) || end.Less(entry.ID) || (consumer != "" && entry.Consumer != consumer) ||
+			now.Sub(entry.DeliveryTime) < minIdle {
+			continue
+		}
+		pending = append(pending, *entry)
+	}
+	return pending, nil
+}
+
+// Claims pending entries for consumer, like XCLAIM. Pending entries which were
+// deleted from the stream are dropped and returned as deleted.
+func (c *ConcurrentStreamMap) Claim(key string, group string, consumer string, minIdle time.Duration, ids []StreamID, options ClaimOptions) (claimed []Claimed, deleted []StreamID, err error) {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	stream, consumerGroup, err := getGroupUnsafe(s, key, group)
+	if err != nil {
+		return nil, nil, err
+	}
+	claimed, deleted = stream.claim(consumerGroup, consumer, minIdle, ids, options, time.Now())
+	return claimed, deleted, nil
+}
+
+// Claims up to count pending entries idle for minIdle from start, like XAUTOCLAIM
+func (c *ConcurrentStreamMap) AutoClaim(key string, group string, consumer string, minIdle time.Duration, start StreamID, count int, justID bool) (next StreamID, claimed []Claimed, deleted []StreamID, err error) {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	stream, consumerGroup, err := getGroupUnsafe(s, key, group)
+	if err != nil {
+		return MinID, nil, nil, err
+	}
+	next, claimed, deleted = stream.autoClaim(consumerGroup, consumer, minIdle, start, count, justID, time.Now())
+	return next, claimed, deleted, nil
+}
+
+// Registers a channel signalled when an entry is added to any of keys
+func (c *ConcurrentStreamMap) addWaiter(keys []string) chan bool {
+	wake := make(chan bool, 1)
+	c.waitersMutex.Lock()
+	for _, key := range keys {
+		c.waiters[key] = append(c.waiters[key], wake)
+	}
+	c.waitersMutex.Unlock()
+	return wake
+}
+
+func (c *ConcurrentStreamMap) removeWaiter(keys []string, wake chan bool) {
+	c.waitersMutex.Lock()
+	defer c.waitersMutex.Unlock()
+	for _, key := range keys {
+		waiting := c.waiters[key]
+		for i := range waiting {
+			if waiting[i] == wake {
+				waiting = append(waiting[:i], waiting[i+1:]...)
+				break
+			}
+		}
+		if len(waiting) == 0 {
+			delete(c.waiters, key)
+		} else {
+			c.waiters[key] = waiting
+		}
+	}
+}
+
+// Signals every client blocked on key
+func (c *ConcurrentStreamMap) wakeWaiters(key string) {
+	c.waitersMutex.Lock()
+	defer c.waitersMutex.Unlock()
+	for _, wake := range c.waiters[key] {
+		select {
+		case wake <- true:
+		default:
+		}
+	}
+}
+
+// Runs try until it succeeds, waiting for entries added to keys in between.
+// A timeout of 0 waits forever. Returns false on timeout.
+func (c *ConcurrentStreamMap) Block(keys []string, timeout time.Duration, try func() bool) bool {
+	if try() {
+		return true
+	}
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	wake := c.addWaiter(keys)
+	defer c.removeWaiter(keys, wake)
+	for {
+		// retry after registering so an add in between isn't missed
+		if try() {
+			return true
+		}
+		select {
+		case <-wake:
+		case <-expired:
+			return false
+		}
+	}
+}
+
+func (c *ConcurrentStreamMap) Expire(key string, timeoutSeconds int) int {
+	return c.ExpireAt(key, time.Now().Add(time.Duration(timeoutSeconds)*time.Second))
+}
+
+// Expire key at an absolute deadline. A deadline in the past removes the key right away.
+func (c *ConcurrentStreamMap) ExpireAt(key string, deadline time.Time) int {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	if _, exists := getUnsafe(s, key); !exists || !s.ExpireAt(key, deadline) {
+		return 0
+	}
+	return 1
+}
+
+// Remove timeout of key. Returns 1 if a timeout was removed
+func (c *ConcurrentStreamMap) Persist(key string) int {
+	s := c.keyspace.Shard(key)
+	s.Lock()
+	defer s.Unlock()
+	if _, exists := getUnsafe(s, key); !exists || !s.Persist(key) {
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,83 @@
+package streamMap
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEmptyStreamKeepsGroups(t *testing.T) {
+	streams := Create()
+	if _, err := streams.CreateGroup("s", "g", nil, false); err != ErrNoStream {
+		t.Errorf("Expected ErrNoStream but got %v", err)
+	}
+	if id, err := streams.CreateGroup("s", "g", nil, true); err != nil || id != MinID {
+		t.Errorf("MKSTREAM should create the stream but got %v %v", id, err)
+	}
+	if _, err := streams.CreateGroup("s", "g", nil, true); err != ErrBusyGroup {
+		t.Errorf("Expected ErrBusyGroup but got %v", err)
+	}
+	id, _, _ := streams.Add("s", AddID{ID: StreamID{1, 0}}, []string{"f", "v"}, false, TrimOptions{})
+	streams.Delete("s", []StreamID{id})
+	if streams.Len("s") != 0 || streams.LastID("s") != id {
+		t.Errorf("Emptied stream should keep its last ID")
+	}
+	if _, exists, _ := streams.Add("missing", AddID{ID: StreamID{1, 0}}, []string{"f", "v"}, true, TrimOptions{}); exists {
+		t.Errorf("NOMKSTREAM must not create a stream")
+	}
+	if _, err := streams.ReadGroup([]string{"s"}, "other", "c", []*StreamID{nil}, 0, false); err == nil {
+		t.Errorf("Reading a missing group should fail")
+	}
+}
+
+func TestReadGroupAndPending(t *testing.T) {
+	streams := Create()
+	for ms := uint64(1); ms <= 3; ms++ {
+		streams.Add("s", AddID{ID: StreamID{ms, 0}}, []string{"n", "v"}, false, TrimOptions{})
+	}
+	streams.CreateGroup("s", "g", &MinID, false)
+	results, _ := streams.ReadGroup([]string{"s"}, "g", "alice", []*StreamID{nil}, 2, false)
+	if !results[0].NewConsumer || len(results[0].Entries) != 2 {
+		t.Errorf("Expected new consumer alice to read 2 entries but got %v", results)
+	}
+	results, _ = streams.ReadGroup([]string{"s"}, "g", "bob", []*StreamID{nil}, 0, true)
+	if len(results[0].Entries) != 1 {
+		t.Errorf("Expected bob to read the last entry but got %v", results)
+	}
+	pending, consumers, _ := streams.PendingSummary("s", "g")
+	if len(pending) != 2 || !reflect.DeepEqual(consumers, []ConsumerPending{{"alice", 2}}) {
+		t.Errorf("NOACK read must not add pending entries, got %v %v", pending, consumers)
+	}
+	if acknowledged := streams.Ack("s", "g", []StreamID{{1, 0}, {3, 0}}); acknowledged != 1 {
+		t.Errorf("Expected 1 acknowledged but got %v", acknowledged)
+	}
+	if pending, _ := streams.Pending("s", "g", time.Hour, MinID, MaxID, 0, ""); len(pending) != 0 {
+		t.Errorf("No entry should be idle for an hour")
+	}
+	if id, _ := streams.SetGroupID("s", "g", nil); id != (StreamID{3, 0}) {
+		t.Errorf("$ should resolve to the last ID but got %v", id)
+	}
+	if streams.DestroyGroup("s", "g") != 1 || streams.DestroyGroup("s", "g") != 0 {
+		t.Errorf("Expected group to be destroyed once")
+	}
+}
+
+func TestBlockingReadWaitsForAdd(t *testing.T) {
+	streams := Create()
+	streams.CreateGroup("s", "g", nil, true)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		streams.Add("s", AddID{ID: StreamID{1, 0}}, []string{"f", "v"}, false, TrimOptions{})
+	}()
+	results, ok := streams.BlockingRead([]string{"other", "s"}, []StreamID{MinID, MinID}, 0, time.Second)
+	if !ok || len(results[1]) != 1 {
+		t.Errorf("Expected the added entry but got %v %v", results, ok)
+	}
+	groupResults, ok, err := streams.BlockingReadGroup([]string{"s"}, "g", "c", []*StreamID{nil}, 0, false, 20*time.Millisecond)
+	if !ok || err != nil || len(groupResults[0].Entries) != 1 || !groupResults[0].NewConsumer {
+		t.Errorf("Expected new consumer to read the entry but got %v %v %v", groupResults, ok, err)
+	}
+	if _, ok, _ := streams.BlockingReadGroup([]string{"s"}, "g", "c", []*StreamID{nil}, 0, false, 20*time.Millisecond); ok {
+		t.Errorf("Expected timeout with nothing new")
+	}
+}
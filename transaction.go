@@ -0,0 +1,122 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// A client's transaction: the commands queued since MULTI and the keys
+// watched since WATCH. It belongs to one connection and isn't safe for
+// concurrent use, except dirty which is guarded by the watch registry.
+type Transaction struct {
+	started bool
+	queued  []string
+	// a command couldn't be queued, EXEC discards the transaction
+	failed  bool
+	watched []string
+	// a watched key was modified, EXEC aborts the transaction
+	dirty bool
+}
+
+func CreateTransaction() *Transaction {
+	return &Transaction{}
+}
+
+// Transactions watching each key, flagged dirty when it is modified
+type watchRegistry struct {
+	mutex    sync.Mutex
+	watchers map[string]map[*Transaction]bool
+	// number of watched keys, read without the mutex so writes don't
+	// contend for it when nothing is watched
+	count int32
+}
+
+func createWatchRegistry() *watchRegistry {
+	return &watchRegistry{watchers: make(map[string]map[*Transaction]bool)}
+}
+
+func (r *watchRegistry) watch(transaction *Transaction, keys []string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, key := range keys {
+		if r.watchers[key][transaction] {
+			continue
+		}
+		if r.watchers[key] == nil {
+			r.watchers[key] = make(map[*Transaction]bool)
+			atomic.AddInt32(&r.count, 1)
+		}
+		r.watchers[key][transaction] = true
+		transaction.watched = append(transaction.watched, key)
+	}
+}
+
+// Forgets the keys watched by transaction and returns whether one of them
+// was modified since
+func (r *watchRegistry) unwatch(transaction *Transaction) (dirty bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, key := range transaction.watched {
+		delete(r.watchers[key], transaction)
+		if len(r.watchers[key]) == 0 {
+			delete(r.watchers, key)
+			atomic.AddInt32(&r.count, -1)
+		}
+	}
+	dirty = transaction.dirty
+	transaction.watched, transaction.dirty = nil, false
+	return dirty
+}
+
+// Flags the transactions watching keys as dirty
+func (r *watchRegistry) touch(keys ...string) {
+	if atomic.LoadInt32(&r.count) == 0 {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, key := range keys {
+		for transaction := range r.watchers[key] {
+			transaction.dirty = true
+		}
+	}
+}
+
+// Touches the keys modified by a command logged to the AOF
+func (r *watchRegistry) touchCommand(command string) {
+	if atomic.LoadInt32(&r.count) == 0 {
+		return
+	}
+	if args, ok := splitArgs(command); ok {
+		r.touch(loggedCommandKeys(args)...)
+	}
+}
+
+// Keys modified by a command as it is logged to the AOF. Most commands
+// modify only the key following their name.
+func loggedCommandKeys(args []string) []string {
+	if len(args) < 2 {
+		return nil
+	}
+	switch args[0] {
+	case "MSET", "MSETNX":
+		var keys []string
+		for i := 1; i < len(args); i += 2 {
+			keys = append(keys, args[i])
+		}
+		return keys
+	case "SMOVE", "LMOVE":
+		if len(args) > 2 {
+			return args[1:3]
+		}
+	case "BITOP", "XGROUP":
+		if len(args) > 2 {
+			return args[2:3]
+		}
+	case "DEL":
+		return args[1:]
+	case "FUNCTION":
+		return nil
+	}
+	return args[1:2]
+}